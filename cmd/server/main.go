@@ -3,11 +3,14 @@ package main
 import (
 	"context"
 	"log"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/messenger/backend/internal/api/handlers"
 	"github.com/messenger/backend/internal/api/middleware"
+	"github.com/messenger/backend/internal/api/ws"
 	"github.com/messenger/backend/internal/config"
 	"github.com/messenger/backend/internal/db"
 	"github.com/messenger/backend/internal/services"
@@ -53,18 +56,75 @@ func main() {
 	}
 
 	// 4. Setup Dependencies
-	queries := db.New(pool)
+	database := postgres.NewDB(pool)
+	queries := db.New(database)
 
 	// Repositories
 	contactRepo := postgres.NewPostgresContactRepository(queries)
+	chatRepo := postgres.NewPostgresChatRepository(queries)
+	inviteRepo := postgres.NewPostgresInviteRepository(queries)
+	threadRepo := postgres.NewPostgresThreadRepository(queries)
+	chatStateRepo := postgres.NewPostgresChatStateRepository(queries)
+	updateRepo := postgres.NewPostgresUpdateRepository(queries)
+	auditRepo := postgres.NewPostgresAuditRepository(queries)
+	messageRepo := postgres.NewPostgresMessageRepository(queries)
+	idempotencyRepo := postgres.NewPostgresIdempotencyRepository(queries)
+	privacyRepo := postgres.NewPostgresPrivacyRepository(queries)
+	receiptRepo := postgres.NewPostgresReceiptRepository(queries)
+	reactionRepo := postgres.NewPostgresReactionRepository(queries)
+	pinRepo := postgres.NewPostgresPinRepository(queries)
+	pollRepo := postgres.NewPostgresPollRepository(queries)
+	mentionRepo := postgres.NewPostgresMentionRepository(queries)
+	draftRepo := postgres.NewPostgresDraftRepository(queries)
+	scheduledRepo := postgres.NewPostgresScheduledMessageRepository(queries)
+
+	// Realtime
+	hub := ws.NewHub()
 
 	// Services
 	authService := services.NewAuthService(queries, cfg.Auth, cfg.Security)
 	contactsService := services.NewContactsService(contactRepo)
+	updatesService := services.NewUpdatesService(updateRepo, database, hub)
+	chatsService := services.NewChatsService(chatRepo, contactRepo, chatStateRepo, updatesService, auditRepo, cfg.Limits)
+	invitesService := services.NewInvitesService(inviteRepo, chatsService)
+	threadsService := services.NewThreadsService(threadRepo, messageRepo, scheduledRepo, draftRepo, chatsService)
+	privacyService := services.NewPrivacyService(privacyRepo, updatesService)
+	messagesService := services.NewMessagesService(messageRepo, chatsService, updatesService, privacyService, cfg.Limits)
+	idempotencyService := services.NewIdempotencyService(idempotencyRepo, cfg.Limits.IdempotencyWindow, cfg.Limits.IdempotencyStaleAfter)
+	receiptsService := services.NewReceiptsService(receiptRepo, chatsService, privacyService)
+	reactionsService := services.NewReactionsService(reactionRepo, messageRepo, chatsService)
+	pinsService := services.NewPinsService(pinRepo, messageRepo, chatsService)
+	pollsService := services.NewPollsService(pollRepo, messageRepo, chatsService)
+	mentionsService := services.NewMentionsService(mentionRepo, chatsService)
+	draftsService := services.NewDraftsService(draftRepo, threadRepo, messageRepo, chatsService)
+	chatActionsService := services.NewChatActionsService(chatsService, threadRepo)
+	scheduledService := services.NewScheduledService(scheduledRepo, messagesService, chatsService)
+	hub.TrackPresence(scheduledService)
+	hub.RouteChatActions(chatActionsService)
+
+	// Background jobs
+	go chatsService.RunAuditRetention(ctx, time.Hour)
+	go idempotencyService.RunIdempotencyPurge(ctx, time.Hour)
+	go scheduledService.RunScheduler(ctx, 5*time.Second)
+	go messagesService.RunReaper(ctx, 10*time.Second)
 
 	// Handlers
 	authHandler := handlers.NewAuthHandler(authService)
 	contactsHandler := handlers.NewContactsHandler(contactsService)
+	chatsHandler := handlers.NewChatsHandler(chatsService)
+	invitesHandler := handlers.NewInvitesHandler(invitesService)
+	threadsHandler := handlers.NewThreadsHandler(threadsService)
+	messagesHandler := handlers.NewMessagesHandler(messagesService)
+	updatesHandler := handlers.NewUpdatesHandler(updatesService)
+	privacyHandler := handlers.NewPrivacyHandler(privacyService)
+	receiptsHandler := handlers.NewReceiptsHandler(receiptsService)
+	reactionsHandler := handlers.NewReactionsHandler(reactionsService)
+	pinsHandler := handlers.NewPinsHandler(pinsService)
+	pollsHandler := handlers.NewPollsHandler(pollsService)
+	mentionsHandler := handlers.NewMentionsHandler(mentionsService)
+	draftsHandler := handlers.NewDraftsHandler(draftsService)
+	scheduledHandler := handlers.NewScheduledHandler(scheduledService)
+	realtimeHandler := handlers.NewRealtimeHandler(hub)
 
 	// 5. Initialize Router
 	router := gin.Default()
@@ -77,16 +137,37 @@ func main() {
 
 		// Protected routes
 		protected := v1.Group("/")
-		protected.Use(middleware.AuthMiddleware(cfg.Auth))
+		protected.Use(middleware.AuthMiddleware(cfg.Auth), middleware.IdempotencyMiddleware(idempotencyService, cfg.Limits.IdempotencyMaxBodySize, cfg.Server.WriteTimeout))
 		{
 			contactsHandler.RegisterContactRoutes(protected)
+			chatsHandler.RegisterChatRoutes(protected)
+			invitesHandler.RegisterInviteRoutes(protected)
+			threadsHandler.RegisterThreadRoutes(protected)
+			messagesHandler.RegisterMessageRoutes(protected)
+			updatesHandler.RegisterUpdateRoutes(protected)
+			privacyHandler.RegisterPrivacyRoutes(protected)
+			receiptsHandler.RegisterReceiptRoutes(protected)
+			reactionsHandler.RegisterReactionRoutes(protected)
+			pinsHandler.RegisterPinRoutes(protected)
+			pollsHandler.RegisterPollRoutes(protected)
+			mentionsHandler.RegisterMentionRoutes(protected)
+			draftsHandler.RegisterDraftRoutes(protected)
+			scheduledHandler.RegisterScheduledRoutes(protected)
+			realtimeHandler.RegisterRealtimeRoutes(protected)
 			// Other protected handlers would be registered here
 		}
 	}
 
 	// 7. Start Server
+	// The timeouts bound how long a request holds its Idempotency-Key.
+	server := &http.Server{
+		Addr:         cfg.Server.Port,
+		Handler:      router,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+	}
 	log.Printf("Starting server on %s", cfg.Server.Port)
-	if err := router.Run(cfg.Server.Port); err != nil {
+	if err := server.ListenAndServe(); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/services"
+)
+
+type CreateChannelPayload struct {
+	Title    string  `json:"title" binding:"required"`
+	PhotoURL *string `json:"photo_url" binding:"omitempty,url"`
+	Username *string `json:"username"`
+}
+
+// ChannelUsernamePayload sets a channel's public username; null makes the
+// channel private.
+type ChannelUsernamePayload struct {
+	Username *string `json:"username"`
+}
+
+type RecordViewsPayload struct {
+	MessageIDs []string `json:"message_ids" binding:"required"`
+}
+
+func (h *ChatsHandler) CreateChannel(c *gin.Context) {
+	var payload CreateChannelPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	chat, err := h.service.CreateChannel(c.Request.Context(), userID, services.CreateChannelParams{
+		Title:    payload.Title,
+		PhotoURL: payload.PhotoURL,
+		Username: payload.Username,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, chat)
+}
+
+func (h *ChatsHandler) ResolveChannel(c *gin.Context) {
+	chat, err := h.service.ResolveChannel(c.Request.Context(), c.Param("username"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, chat)
+}
+
+func (h *ChatsHandler) Subscribe(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	chat, err := h.service.Subscribe(c.Request.Context(), userID, chatID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, chat)
+}
+
+func (h *ChatsHandler) SetChannelUsername(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload ChannelUsernamePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.SetChannelUsername(c.Request.Context(), userID, chatID, payload.Username); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ChatsHandler) RecordViews(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload RecordViewsPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+	messageIDs, ok := parseULIDs(c, payload.MessageIDs)
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	views, err := h.service.RecordViews(c.Request.Context(), userID, chatID, messageIDs)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": views})
+}
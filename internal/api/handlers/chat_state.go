@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/services"
+)
+
+// ChatStatePayload changes the caller's own state of a chat; missing fields
+// are left unchanged. A mute object with neither until nor forever unmutes.
+type ChatStatePayload struct {
+	Pinned       *bool        `json:"pinned"`
+	Archived     *bool        `json:"archived"`
+	MarkedUnread *bool        `json:"marked_unread"`
+	Mute         *MutePayload `json:"mute"`
+	// MentionNotifications sets whether mentions notify while muted.
+	MentionNotifications *bool `json:"mention_notifications"`
+}
+
+type MutePayload struct {
+	Until   *time.Time `json:"until"`
+	Forever bool       `json:"forever"`
+}
+
+type ChatFolderPayload struct {
+	Title           string   `json:"title" binding:"required"`
+	IncludeRules    []string `json:"include_rules"`
+	ExcludeRules    []string `json:"exclude_rules"`
+	IncludedChatIDs []string `json:"included_chat_ids"`
+	ExcludedChatIDs []string `json:"excluded_chat_ids"`
+}
+
+type ChatOrderPayload struct {
+	ChatIDs []string `json:"chat_ids" binding:"required"`
+}
+
+type FolderOrderPayload struct {
+	FolderIDs []string `json:"folder_ids" binding:"required"`
+}
+
+func (h *ChatsHandler) UpdateChatState(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload ChatStatePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	params := services.ChatStateParams{
+		Pinned:               payload.Pinned,
+		Archived:             payload.Archived,
+		MarkedUnread:         payload.MarkedUnread,
+		MentionNotifications: payload.MentionNotifications,
+	}
+	if payload.Mute != nil {
+		params.Mute = &services.MuteParams{Until: payload.Mute.Until, Forever: payload.Mute.Forever}
+	}
+	state, err := h.service.UpdateChatState(c.Request.Context(), userID, chatID, params)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+func (h *ChatsHandler) ReorderPinnedChats(c *gin.Context) {
+	var payload ChatOrderPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+	chatIDs, ok := parseULIDs(c, payload.ChatIDs)
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.ReorderPinnedChats(c.Request.Context(), userID, chatIDs); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ChatsHandler) ListChatFolders(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	folders, err := h.service.ListChatFolders(c.Request.Context(), userID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": folders})
+}
+
+func (h *ChatsHandler) CreateChatFolder(c *gin.Context) {
+	params, ok := bindChatFolder(c)
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	folder, err := h.service.CreateChatFolder(c.Request.Context(), userID, params)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, folder)
+}
+
+func (h *ChatsHandler) UpdateChatFolder(c *gin.Context) {
+	folderID, ok := parseULIDParam(c, "folder_id")
+	if !ok {
+		return
+	}
+	params, ok := bindChatFolder(c)
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	folder, err := h.service.UpdateChatFolder(c.Request.Context(), userID, folderID, params)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+func (h *ChatsHandler) DeleteChatFolder(c *gin.Context) {
+	folderID, ok := parseULIDParam(c, "folder_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.DeleteChatFolder(c.Request.Context(), userID, folderID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ChatsHandler) ReorderChatFolders(c *gin.Context) {
+	var payload FolderOrderPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+	folderIDs, ok := parseULIDs(c, payload.FolderIDs)
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.ReorderChatFolders(c.Request.Context(), userID, folderIDs); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func bindChatFolder(c *gin.Context) (services.ChatFolderParams, bool) {
+	var payload ChatFolderPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return services.ChatFolderParams{}, false
+	}
+	included, ok := parseULIDs(c, payload.IncludedChatIDs)
+	if !ok {
+		return services.ChatFolderParams{}, false
+	}
+	excluded, ok := parseULIDs(c, payload.ExcludedChatIDs)
+	if !ok {
+		return services.ChatFolderParams{}, false
+	}
+	return services.ChatFolderParams{
+		Title:           payload.Title,
+		IncludeRules:    payload.IncludeRules,
+		ExcludeRules:    payload.ExcludeRules,
+		IncludedChatIDs: included,
+		ExcludedChatIDs: excluded,
+	}, true
+}
@@ -1 +1,544 @@
 package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+// ChatsService defines the interface for chat-related business logic.
+type ChatsService interface {
+	GetOrCreateDirectChat(ctx context.Context, userID, peerID ulid.ULID) (*db.Chat, bool, error)
+	GetOrCreateSavedMessages(ctx context.Context, userID ulid.ULID) (*db.Chat, bool, error)
+	GetChat(ctx context.Context, userID, chatID ulid.ULID) (*db.Chat, error)
+	ListChats(ctx context.Context, userID ulid.ULID, opts services.ChatListOptions, cursor string, limit int) (*services.ChatPage, error)
+	CreateGroup(ctx context.Context, ownerID ulid.ULID, params services.CreateGroupParams) (*db.Chat, error)
+	AddMember(ctx context.Context, actorID, chatID, userID ulid.ULID) error
+	RemoveMember(ctx context.Context, actorID, chatID, userID ulid.ULID) error
+	LeaveGroup(ctx context.Context, userID, chatID ulid.ULID) error
+	SetMemberRole(ctx context.Context, actorID, chatID, userID ulid.ULID, role db.ChatMemberRole) error
+	TransferOwnership(ctx context.Context, ownerID, chatID, newOwnerID ulid.ULID) error
+	ListMembers(ctx context.Context, userID, chatID ulid.ULID, cursor string, limit int) (*services.MemberPage, error)
+	UpdateGroupInfo(ctx context.Context, actorID, chatID ulid.ULID, title, photoURL *string) (*db.Chat, error)
+	GetPermissions(ctx context.Context, userID, chatID ulid.ULID) (*services.ChatPermissionsView, error)
+	SetDefaultPermissions(ctx context.Context, actorID, chatID ulid.ULID, member, admin services.Permission) error
+	SetMemberPermissions(ctx context.Context, actorID, chatID, userID ulid.ULID, params services.MemberPermissionsParams) error
+	CreateChannel(ctx context.Context, ownerID ulid.ULID, params services.CreateChannelParams) (*db.Chat, error)
+	ResolveChannel(ctx context.Context, username string) (*db.Chat, error)
+	Subscribe(ctx context.Context, userID, chatID ulid.ULID) (*db.Chat, error)
+	SetChannelUsername(ctx context.Context, actorID, chatID ulid.ULID, username *string) error
+	RecordViews(ctx context.Context, userID, chatID ulid.ULID, messageIDs []ulid.ULID) ([]services.PostViews, error)
+	UpdateChatState(ctx context.Context, userID, chatID ulid.ULID, params services.ChatStateParams) (*db.ChatUserState, error)
+	ReorderPinnedChats(ctx context.Context, userID ulid.ULID, chatIDs []ulid.ULID) error
+	ListChatFolders(ctx context.Context, userID ulid.ULID) ([]db.ChatFolder, error)
+	CreateChatFolder(ctx context.Context, userID ulid.ULID, params services.ChatFolderParams) (*db.ChatFolder, error)
+	UpdateChatFolder(ctx context.Context, userID, folderID ulid.ULID, params services.ChatFolderParams) (*db.ChatFolder, error)
+	DeleteChatFolder(ctx context.Context, userID, folderID ulid.ULID) error
+	ReorderChatFolders(ctx context.Context, userID ulid.ULID, folderIDs []ulid.ULID) error
+	BanMember(ctx context.Context, actorID, chatID, userID ulid.ULID, until *time.Time) (*db.ChatBan, error)
+	UnbanMember(ctx context.Context, actorID, chatID, userID ulid.ULID) error
+	ListBans(ctx context.Context, actorID, chatID ulid.ULID, cursor string, limit int) (*services.BanPage, error)
+	RestrictMember(ctx context.Context, actorID, chatID, userID ulid.ULID, until *time.Time) error
+	SetSlowMode(ctx context.Context, actorID, chatID ulid.ULID, seconds int) error
+	ListAuditLog(ctx context.Context, actorID, chatID ulid.ULID, filter services.AuditLogFilter, cursor string, limit int) (*services.AuditLogPage, error)
+}
+
+// ChatsHandler handles API requests related to chats.
+type ChatsHandler struct {
+	service ChatsService
+}
+
+// NewChatsHandler creates a new ChatsHandler.
+func NewChatsHandler(service ChatsService) *ChatsHandler {
+	return &ChatsHandler{service: service}
+}
+
+// RegisterChatRoutes registers all chat-related routes with the Gin router.
+func (h *ChatsHandler) RegisterChatRoutes(router *gin.RouterGroup) {
+	chats := router.Group("/chats")
+	{
+		chats.GET("", h.ListChats)
+		chats.POST("/direct", h.CreateDirectChat)
+		chats.POST("/saved", h.CreateSavedMessages)
+		chats.POST("/groups", h.CreateGroup)
+		chats.POST("/channels", h.CreateChannel)
+		chats.PUT("/pinned-order", h.ReorderPinnedChats)
+		chats.GET("/:chat_id", h.GetChat)
+		chats.PATCH("/:chat_id", h.UpdateGroupInfo)
+		chats.GET("/:chat_id/permissions", h.GetPermissions)
+		chats.PUT("/:chat_id/permissions", h.SetDefaultPermissions)
+		chats.POST("/:chat_id/leave", h.LeaveGroup)
+		chats.POST("/:chat_id/transfer-ownership", h.TransferOwnership)
+		chats.POST("/:chat_id/subscribe", h.Subscribe)
+		chats.PUT("/:chat_id/username", h.SetChannelUsername)
+		chats.POST("/:chat_id/views", h.RecordViews)
+		chats.PATCH("/:chat_id/state", h.UpdateChatState)
+		chats.PUT("/:chat_id/slow-mode", h.SetSlowMode)
+		chats.GET("/:chat_id/audit-log", h.ListAuditLog)
+
+		members := chats.Group("/:chat_id/members")
+		{
+			members.GET("", h.ListMembers)
+			members.POST("", h.AddMember)
+			members.PATCH("/:user_id", h.SetMemberRole)
+			members.DELETE("/:user_id", h.RemoveMember)
+			members.PUT("/:user_id/permissions", h.SetMemberPermissions)
+			members.PUT("/:user_id/restriction", h.RestrictMember)
+		}
+
+		bans := chats.Group("/:chat_id/bans")
+		{
+			bans.GET("", h.ListBans)
+			bans.POST("", h.BanMember)
+			bans.DELETE("/:user_id", h.UnbanMember)
+		}
+	}
+
+	router.GET("/channels/:username", h.ResolveChannel)
+
+	folders := router.Group("/chat-folders")
+	{
+		folders.GET("", h.ListChatFolders)
+		folders.POST("", h.CreateChatFolder)
+		folders.PUT("/order", h.ReorderChatFolders)
+		folders.PUT("/:folder_id", h.UpdateChatFolder)
+		folders.DELETE("/:folder_id", h.DeleteChatFolder)
+	}
+}
+
+type CreateDirectChatPayload struct {
+	PeerID string `json:"peer_id" binding:"required"`
+}
+
+type CreateGroupPayload struct {
+	Title     string   `json:"title" binding:"required"`
+	PhotoURL  *string  `json:"photo_url" binding:"omitempty,url"`
+	MemberIDs []string `json:"member_ids"`
+}
+
+type UpdateGroupInfoPayload struct {
+	Title    *string `json:"title"`
+	PhotoURL *string `json:"photo_url" binding:"omitempty,url"`
+}
+
+type DefaultPermissionsPayload struct {
+	MemberPermissions services.Permission `json:"member_permissions"`
+	AdminPermissions  services.Permission `json:"admin_permissions"`
+}
+
+// MemberPermissionsPayload replaces a member's override; a null or missing
+// permissions field resets the member to their role default.
+type MemberPermissionsPayload struct {
+	Permissions *services.Permission `json:"permissions"`
+	CustomTitle *string              `json:"custom_title"`
+}
+
+type UserIDPayload struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
+type SetMemberRolePayload struct {
+	Role db.ChatMemberRole `json:"role" binding:"required,oneof=admin member"`
+}
+
+// ListChats lists the main chat list, the archive (?archived=true) or a
+// folder (?folder_id=...).
+func (h *ChatsHandler) ListChats(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	var opts services.ChatListOptions
+	if opts.FolderID, ok = parseOptionalULIDQuery(c, "folder_id"); !ok {
+		return
+	}
+	opts.Archived, _ = strconv.ParseBool(c.Query("archived"))
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	page, err := h.service.ListChats(c.Request.Context(), userID, opts, c.Query("cursor"), limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// CreateDirectChat returns the direct chat with a peer, creating it if needed.
+// It responds with 201 when the chat was created and 200 when it already existed.
+func (h *ChatsHandler) CreateDirectChat(c *gin.Context) {
+	var payload CreateDirectChatPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+	peerID, err := ulid.Parse(payload.PeerID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: "Invalid peer ID format"})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	chat, created, err := h.service.GetOrCreateDirectChat(c.Request.Context(), userID, peerID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(createdStatus(created), chat)
+}
+
+// CreateSavedMessages returns the user's saved-messages chat, creating it if needed.
+func (h *ChatsHandler) CreateSavedMessages(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	chat, created, err := h.service.GetOrCreateSavedMessages(c.Request.Context(), userID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(createdStatus(created), chat)
+}
+
+func (h *ChatsHandler) GetChat(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	chat, err := h.service.GetChat(c.Request.Context(), userID, chatID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, chat)
+}
+
+func (h *ChatsHandler) CreateGroup(c *gin.Context) {
+	var payload CreateGroupPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+	memberIDs, ok := parseULIDs(c, payload.MemberIDs)
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	chat, err := h.service.CreateGroup(c.Request.Context(), userID, services.CreateGroupParams{
+		Title:     payload.Title,
+		PhotoURL:  payload.PhotoURL,
+		MemberIDs: memberIDs,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, chat)
+}
+
+func (h *ChatsHandler) ListMembers(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	page, err := h.service.ListMembers(c.Request.Context(), userID, chatID, c.Query("cursor"), limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *ChatsHandler) AddMember(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	memberID, ok := bindUserID(c)
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.AddMember(c.Request.Context(), userID, chatID, memberID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ChatsHandler) RemoveMember(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	memberID, ok := parseULIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.RemoveMember(c.Request.Context(), userID, chatID, memberID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ChatsHandler) SetMemberRole(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	memberID, ok := parseULIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	var payload SetMemberRolePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.SetMemberRole(c.Request.Context(), userID, chatID, memberID, payload.Role); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ChatsHandler) LeaveGroup(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.LeaveGroup(c.Request.Context(), userID, chatID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ChatsHandler) TransferOwnership(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	newOwnerID, ok := bindUserID(c)
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.TransferOwnership(c.Request.Context(), userID, chatID, newOwnerID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ChatsHandler) UpdateGroupInfo(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload UpdateGroupInfoPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	chat, err := h.service.UpdateGroupInfo(c.Request.Context(), userID, chatID, payload.Title, payload.PhotoURL)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, chat)
+}
+
+func (h *ChatsHandler) GetPermissions(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	view, err := h.service.GetPermissions(c.Request.Context(), userID, chatID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
+
+func (h *ChatsHandler) SetDefaultPermissions(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload DefaultPermissionsPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.SetDefaultPermissions(c.Request.Context(), userID, chatID, payload.MemberPermissions, payload.AdminPermissions); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ChatsHandler) SetMemberPermissions(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	memberID, ok := parseULIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	var payload MemberPermissionsPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	err := h.service.SetMemberPermissions(c.Request.Context(), userID, chatID, memberID, services.MemberPermissionsParams{
+		Permissions: payload.Permissions,
+		CustomTitle: payload.CustomTitle,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// bindUserID reads a {"user_id": "..."} body.
+func bindUserID(c *gin.Context) (ulid.ULID, bool) {
+	var payload UserIDPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return ulid.ULID{}, false
+	}
+	id, err := ulid.Parse(payload.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: "Invalid user ID format"})
+		return ulid.ULID{}, false
+	}
+	return id, true
+}
+
+func createdStatus(created bool) int {
+	if created {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
@@ -2,11 +2,16 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/messenger/backend/internal/db"
 	"github.com/messenger/backend/internal/services"
+	"github.com/messenger/backend/pkg/vcard"
 	"github.com/oklog/ulid/v2"
 )
 
@@ -19,8 +24,13 @@ type ContactsService interface {
 	BlockPeer(ctx context.Context, ownerID, targetID ulid.ULID) error
 	UnblockPeer(ctx context.Context, ownerID, targetID ulid.ULID) error
 	ListContacts(ctx context.Context, ownerID ulid.ULID, state db.ContactState) ([]db.Contact, error)
+	ImportContacts(ctx context.Context, userID ulid.ULID, cards []vcard.Card, sendRequests bool) (*services.ContactImportResult, error)
+	ExportContacts(ctx context.Context, ownerID ulid.ULID) ([]vcard.Card, error)
 }
 
+// maxVCardImportSize limits the size of an uploaded vCard file.
+const maxVCardImportSize = 2 << 20
+
 // ContactsHandler handles API requests related to contacts.
 type ContactsHandler struct {
 	service ContactsService
@@ -37,6 +47,8 @@ func (h *ContactsHandler) RegisterContactRoutes(router *gin.RouterGroup) {
 	{
 		contacts.GET("", h.ListContacts)
 		contacts.DELETE("/:contact_id", h.DeleteContact)
+		contacts.POST("/import", h.ImportContacts)
+		contacts.GET("/export", h.ExportContacts)
 
 		requests := contacts.Group("/requests")
 		{
@@ -243,3 +255,78 @@ func (h *ContactsHandler) UnblockPeer(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+// ImportContacts accepts a vCard file either as a multipart "file" field or as
+// the raw request body. Pass ?send_requests=true to send contact requests to
+// every matched user.
+func (h *ContactsHandler) ImportContacts(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{ErrorCode: "UNAUTHORIZED", Message: "User ID not found in context"})
+		return
+	}
+
+	sendRequests, _ := strconv.ParseBool(c.DefaultQuery("send_requests", "false"))
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxVCardImportSize)
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: "A vCard file is required in the 'file' field"})
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	cards, err := vcard.Decode(body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: "vCard file is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	result, err := h.service.ImportContacts(c.Request.Context(), userID, cards, sendRequests)
+	if err != nil {
+		if bizErr, ok := err.(*services.BusinessError); ok {
+			c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: bizErr.Code, Message: bizErr.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{ErrorCode: "INTERNAL_ERROR", Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ExportContacts returns the user's accepted contacts as a vCard 3.0 file.
+func (h *ContactsHandler) ExportContacts(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{ErrorCode: "UNAUTHORIZED", Message: "User ID not found in context"})
+		return
+	}
+
+	cards, err := h.service.ExportContacts(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{ErrorCode: "INTERNAL_ERROR", Message: err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="contacts.vcf"`)
+	c.Header("Content-Type", "text/vcard; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := vcard.Encode(c.Writer, cards); err != nil {
+		_ = c.Error(err)
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+// DraftsService defines the interface for synced message drafts.
+type DraftsService interface {
+	SaveDraft(ctx context.Context, userID, chatID ulid.ULID, params services.DraftParams) (*db.Draft, bool, error)
+	ListDrafts(ctx context.Context, userID ulid.ULID, cursor string, limit int) (*services.DraftPage, error)
+}
+
+// DraftsHandler handles API requests related to message drafts.
+type DraftsHandler struct {
+	service DraftsService
+}
+
+// NewDraftsHandler creates a new DraftsHandler.
+func NewDraftsHandler(service DraftsService) *DraftsHandler {
+	return &DraftsHandler{service: service}
+}
+
+// RegisterDraftRoutes registers all draft-related routes with the Gin
+// router.
+func (h *DraftsHandler) RegisterDraftRoutes(router *gin.RouterGroup) {
+	router.GET("/drafts", h.ListDrafts)
+	router.PUT("/chats/:chat_id/draft", h.SaveDraft)
+}
+
+// SaveDraftPayload carries a draft for a chat or, with ThreadID, one of its
+// threads. Empty Ciphertext and no ReplyToID clear the draft.
+type SaveDraftPayload struct {
+	ThreadID        *string    `json:"thread_id"`
+	Ciphertext      []byte     `json:"ciphertext"`
+	ReplyToID       *string    `json:"reply_to_id"`
+	ClientUpdatedAt *time.Time `json:"client_updated_at" binding:"required"`
+}
+
+// SaveDraftResponse is the draft stored after a save. Saved is false when a
+// newer draft from another device was kept instead.
+type SaveDraftResponse struct {
+	Draft *db.Draft `json:"draft"`
+	Saved bool      `json:"saved"`
+}
+
+func (h *DraftsHandler) SaveDraft(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload SaveDraftPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+	params := services.DraftParams{
+		Ciphertext:      payload.Ciphertext,
+		ClientUpdatedAt: *payload.ClientUpdatedAt,
+	}
+	if payload.ThreadID != nil {
+		ids, ok := parseULIDs(c, []string{*payload.ThreadID})
+		if !ok {
+			return
+		}
+		params.ThreadID = &ids[0]
+	}
+	if payload.ReplyToID != nil {
+		ids, ok := parseULIDs(c, []string{*payload.ReplyToID})
+		if !ok {
+			return
+		}
+		params.ReplyToID = &ids[0]
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	draft, saved, err := h.service.SaveDraft(c.Request.Context(), userID, chatID, params)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SaveDraftResponse{Draft: draft, Saved: saved})
+}
+
+// ListDrafts returns the caller's drafts across chats, most recently
+// changed first.
+func (h *DraftsHandler) ListDrafts(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	page, err := h.service.ListDrafts(c.Request.Context(), userID, c.Query("cursor"), limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/services"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+// businessErrorStatus maps business error codes to HTTP status codes.
+// Codes that are not listed are reported as 400 Bad Request.
+var businessErrorStatus = map[utils.ErrorCode]int{
+	utils.ErrUserNotFound:        http.StatusNotFound,
+	utils.ErrChatNotFound:        http.StatusNotFound,
+	utils.ErrMessageNotFound:     http.StatusNotFound,
+	utils.ErrMemberNotFound:      http.StatusNotFound,
+	utils.ErrThreadNotFound:      http.StatusNotFound,
+	utils.ErrNotFound:            http.StatusNotFound,
+	utils.ErrPeerBlocked:         http.StatusForbidden,
+	utils.ErrYouAreBlocked:       http.StatusForbidden,
+	utils.ErrForbidden:           http.StatusForbidden,
+	utils.ErrForbiddenRole:       http.StatusForbidden,
+	utils.ErrUserBanned:          http.StatusForbidden,
+	utils.ErrAuthDeviceRevoked:   http.StatusForbidden,
+	utils.ErrMessageNotEditable:  http.StatusForbidden,
+	utils.ErrMessageNotDeletable: http.StatusForbidden,
+	utils.ErrReactionNotAllowed:  http.StatusForbidden,
+	utils.ErrInviteInvalid:       http.StatusNotFound,
+	utils.ErrInviteExpired:       http.StatusGone,
+	utils.ErrChatAlreadyExists:   http.StatusConflict,
+	utils.ErrSelfChatExists:      http.StatusConflict,
+	utils.ErrMemberExists:        http.StatusConflict,
+	utils.ErrUsernameTaken:       http.StatusConflict,
+	utils.ErrMemberLimitReached:  http.StatusConflict,
+	utils.ErrReactionLimit:       http.StatusConflict,
+	utils.ErrPollClosed:          http.StatusConflict,
+	utils.ErrPollAnswered:        http.StatusConflict,
+	utils.ErrConflict:            http.StatusConflict,
+	utils.ErrRateLimited:         http.StatusTooManyRequests,
+	utils.ErrValidation:          http.StatusBadRequest,
+}
+
+// writeError renders err as an ErrorResponse, using the business error code
+// when there is one.
+func writeError(c *gin.Context, err error) {
+	if bizErr, ok := err.(*services.BusinessError); ok {
+		status, ok := businessErrorStatus[utils.ErrorCode(bizErr.Code)]
+		if !ok {
+			status = http.StatusBadRequest
+		}
+		resp := ErrorResponse{ErrorCode: bizErr.Code, Message: bizErr.Message}
+		if len(bizErr.Details) > 0 {
+			resp.Details = bizErr.Details
+		}
+		if retryAfter, ok := bizErr.Details["retry_after"]; ok {
+			c.Header("Retry-After", fmt.Sprint(retryAfter))
+		}
+		c.JSON(status, resp)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, ErrorResponse{ErrorCode: "INTERNAL_ERROR", Message: err.Error()})
+}
+
+// writeUnauthorized reports a request that reached a protected handler without a user ID.
+func writeUnauthorized(c *gin.Context) {
+	c.JSON(http.StatusUnauthorized, ErrorResponse{ErrorCode: "UNAUTHORIZED", Message: "User ID not found in context"})
+}
+
+// parseULIDs parses a list of ULIDs from a request body, writing a
+// validation error response when any of them is malformed.
+func parseULIDs(c *gin.Context, raw []string) ([]ulid.ULID, bool) {
+	ids := make([]ulid.ULID, 0, len(raw))
+	for _, s := range raw {
+		id, err := ulid.Parse(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: "Invalid ID format: " + s})
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+// parseULIDParam parses a ULID path parameter, writing a validation error
+// response when it is malformed.
+func parseULIDParam(c *gin.Context, name string) (ulid.ULID, bool) {
+	id, err := ulid.Parse(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: "Invalid " + name + " format"})
+		return ulid.ULID{}, false
+	}
+	return id, true
+}
+
+// parseOptionalULIDQuery parses an optional ULID query parameter, writing a
+// validation error response when it is malformed.
+func parseOptionalULIDQuery(c *gin.Context, name string) (*ulid.ULID, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, true
+	}
+	id, err := ulid.Parse(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: "Invalid " + name + " format"})
+		return nil, false
+	}
+	return &id, true
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+// InvitesService defines the interface for invite link and join request business logic.
+type InvitesService interface {
+	CreateInviteLink(ctx context.Context, actorID, chatID ulid.ULID, params services.CreateInviteLinkParams) (*db.ChatInviteLink, error)
+	ListInviteLinks(ctx context.Context, actorID, chatID ulid.ULID) ([]db.ChatInviteLink, error)
+	RevokeInviteLink(ctx context.Context, actorID, chatID, linkID ulid.ULID) error
+	PreviewInvite(ctx context.Context, token string) (*services.InvitePreview, error)
+	JoinByInvite(ctx context.Context, userID ulid.ULID, token string) (*services.JoinResult, error)
+	ListJoinRequests(ctx context.Context, actorID, chatID ulid.ULID, cursor string, limit int) (*services.JoinRequestPage, error)
+	ApproveJoinRequest(ctx context.Context, actorID, chatID, userID ulid.ULID) error
+	DeclineJoinRequest(ctx context.Context, actorID, chatID, userID ulid.ULID) error
+}
+
+// InvitesHandler handles API requests related to invite links and join requests.
+type InvitesHandler struct {
+	service InvitesService
+}
+
+// NewInvitesHandler creates a new InvitesHandler.
+func NewInvitesHandler(service InvitesService) *InvitesHandler {
+	return &InvitesHandler{service: service}
+}
+
+// RegisterInviteRoutes registers all invite-related routes with the Gin router.
+func (h *InvitesHandler) RegisterInviteRoutes(router *gin.RouterGroup) {
+	links := router.Group("/chats/:chat_id/invite-links")
+	{
+		links.GET("", h.ListInviteLinks)
+		links.POST("", h.CreateInviteLink)
+		links.DELETE("/:link_id", h.RevokeInviteLink)
+	}
+
+	requests := router.Group("/chats/:chat_id/join-requests")
+	{
+		requests.GET("", h.ListJoinRequests)
+		requests.POST("/:user_id/approve", h.ApproveJoinRequest)
+		requests.POST("/:user_id/decline", h.DeclineJoinRequest)
+	}
+
+	invites := router.Group("/invites")
+	{
+		invites.GET("/:token", h.PreviewInvite)
+		invites.POST("/:token/join", h.JoinByInvite)
+	}
+}
+
+type CreateInviteLinkPayload struct {
+	Name             *string    `json:"name"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	UsageLimit       *int       `json:"usage_limit"`
+	RequiresApproval bool       `json:"requires_approval"`
+}
+
+func (h *InvitesHandler) CreateInviteLink(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload CreateInviteLinkPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	link, err := h.service.CreateInviteLink(c.Request.Context(), userID, chatID, services.CreateInviteLinkParams{
+		Name:             payload.Name,
+		ExpiresAt:        payload.ExpiresAt,
+		UsageLimit:       payload.UsageLimit,
+		RequiresApproval: payload.RequiresApproval,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, link)
+}
+
+func (h *InvitesHandler) ListInviteLinks(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	links, err := h.service.ListInviteLinks(c.Request.Context(), userID, chatID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": links})
+}
+
+func (h *InvitesHandler) RevokeInviteLink(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	linkID, ok := parseULIDParam(c, "link_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.RevokeInviteLink(c.Request.Context(), userID, chatID, linkID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *InvitesHandler) PreviewInvite(c *gin.Context) {
+	preview, err := h.service.PreviewInvite(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// JoinByInvite answers 200 with the chat when the user joined and 202 when a
+// join request is awaiting approval.
+func (h *InvitesHandler) JoinByInvite(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	result, err := h.service.JoinByInvite(c.Request.Context(), userID, c.Param("token"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if result.Status == services.JoinStatusPending {
+		status = http.StatusAccepted
+	}
+	c.JSON(status, result)
+}
+
+func (h *InvitesHandler) ListJoinRequests(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	page, err := h.service.ListJoinRequests(c.Request.Context(), userID, chatID, c.Query("cursor"), limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *InvitesHandler) ApproveJoinRequest(c *gin.Context) {
+	h.decideJoinRequest(c, h.service.ApproveJoinRequest)
+}
+
+func (h *InvitesHandler) DeclineJoinRequest(c *gin.Context) {
+	h.decideJoinRequest(c, h.service.DeclineJoinRequest)
+}
+
+func (h *InvitesHandler) decideJoinRequest(c *gin.Context, decide func(ctx context.Context, actorID, chatID, userID ulid.ULID) error) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	requesterID, ok := parseULIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := decide(c.Request.Context(), userID, chatID, requesterID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+// MentionsService defines the interface for the mentions feed.
+type MentionsService interface {
+	ListMentions(ctx context.Context, userID ulid.ULID, chatID, deviceID *ulid.ULID, unreadOnly bool, cursor string, limit int) (*services.MentionPage, error)
+	ReadMentions(ctx context.Context, userID, chatID ulid.ULID) error
+}
+
+// MentionsHandler handles API requests related to mentions.
+type MentionsHandler struct {
+	service MentionsService
+}
+
+// NewMentionsHandler creates a new MentionsHandler.
+func NewMentionsHandler(service MentionsService) *MentionsHandler {
+	return &MentionsHandler{service: service}
+}
+
+// RegisterMentionRoutes registers all mention-related routes with the Gin
+// router.
+func (h *MentionsHandler) RegisterMentionRoutes(router *gin.RouterGroup) {
+	router.GET("/mentions", h.ListMentions)
+	router.POST("/chats/:chat_id/mentions/read", h.ReadMentions)
+}
+
+// ListMentions returns the caller's mentions across chats, newest first.
+// chat_id narrows the feed to one chat and unread_only=true to unread
+// mentions.
+func (h *MentionsHandler) ListMentions(c *gin.Context) {
+	chatID, ok := parseOptionalULIDQuery(c, "chat_id")
+	if !ok {
+		return
+	}
+	deviceID, ok := parseOptionalULIDQuery(c, "device_id")
+	if !ok {
+		return
+	}
+	unreadOnly, _ := strconv.ParseBool(c.Query("unread_only"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	page, err := h.service.ListMentions(c.Request.Context(), userID, chatID, deviceID, unreadOnly, c.Query("cursor"), limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *MentionsHandler) ReadMentions(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.ReadMentions(c.Request.Context(), userID, chatID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
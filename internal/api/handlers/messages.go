@@ -1 +1,496 @@
 package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+// MessagesService defines the interface for message business logic.
+type MessagesService interface {
+	SendMessage(ctx context.Context, userID, chatID ulid.ULID, params services.SendMessageParams) (*db.Message, bool, error)
+	GetMessage(ctx context.Context, userID, chatID, messageID ulid.ULID, deviceID *ulid.ULID) (*db.GetMessageForDeviceRow, error)
+	ListHistory(ctx context.Context, userID, chatID ulid.ULID, query services.HistoryQuery) (*services.MessagePage, error)
+	EditMessage(ctx context.Context, userID, chatID, messageID ulid.ULID, params services.EditMessageParams) (*db.Message, error)
+	ListEdits(ctx context.Context, userID, chatID, messageID ulid.ULID, deviceID *ulid.ULID) (*services.EditHistory, error)
+	DeleteMessages(ctx context.Context, userID, chatID ulid.ULID, messageIDs []ulid.ULID, forEveryone bool) error
+	ClearHistory(ctx context.Context, userID, chatID ulid.ULID) (int64, error)
+	SetMessageTTL(ctx context.Context, userID, chatID ulid.ULID, ttl time.Duration) error
+	ViewMessage(ctx context.Context, userID, chatID, messageID ulid.ULID) error
+}
+
+// MessagesHandler handles API requests related to messages.
+type MessagesHandler struct {
+	service MessagesService
+}
+
+// NewMessagesHandler creates a new MessagesHandler.
+func NewMessagesHandler(service MessagesService) *MessagesHandler {
+	return &MessagesHandler{service: service}
+}
+
+// RegisterMessageRoutes registers all message-related routes with the Gin router.
+func (h *MessagesHandler) RegisterMessageRoutes(router *gin.RouterGroup) {
+	messages := router.Group("/chats/:chat_id/messages")
+	{
+		messages.GET("", h.ListHistory)
+		messages.POST("", h.SendMessage)
+		messages.GET("/:message_id", h.GetMessage)
+		messages.PATCH("/:message_id", h.EditMessage)
+		messages.GET("/:message_id/edits", h.ListEdits)
+		messages.DELETE("/:message_id", h.DeleteMessage)
+		messages.POST("/delete", h.DeleteMessages)
+		messages.POST("/:message_id/viewed", h.ViewMessage)
+	}
+	router.POST("/chats/:chat_id/clear-history", h.ClearHistory)
+	router.PUT("/chats/:chat_id/message-ttl", h.SetMessageTTL)
+}
+
+// SendMessagePayload carries an encrypted message. Binary fields are
+// base64-encoded.
+type SendMessagePayload struct {
+	SenderDeviceID string                `json:"sender_device_id" binding:"required"`
+	ThreadID       *string               `json:"thread_id"`
+	ContentType    string                `json:"content_type" binding:"required"`
+	Ciphertext     []byte                `json:"ciphertext"`
+	Recipients     []DevicePayloadSchema `json:"recipients" binding:"dive"`
+	// ClientMessageID is the client's own ID for the message. Resending
+	// with the same ID returns the stored message.
+	ClientMessageID string `json:"client_message_id"`
+	// ReplyToID references an earlier message of the same chat.
+	ReplyToID *string `json:"reply_to_id"`
+	// ForwardFrom marks the message as a re-encrypted copy of another one.
+	ForwardFrom *ForwardSourceSchema `json:"forward_from"`
+	// TTLSeconds makes the message disappear that long after it is sent,
+	// overriding the chat's timer.
+	TTLSeconds int  `json:"ttl_seconds" binding:"min=0"`
+	ViewOnce   bool `json:"view_once"`
+	// Poll is required for poll messages.
+	Poll *PollSchema `json:"poll"`
+	// MentionedUserIDs and MentionedUsernames are the plaintext mentions
+	// of the encrypted message.
+	MentionedUserIDs   []string `json:"mentioned_user_ids"`
+	MentionedUsernames []string `json:"mentioned_usernames"`
+}
+
+// MessageTTLPayload sets a chat's disappearing message timer. Zero turns
+// it off.
+type MessageTTLPayload struct {
+	TTLSeconds int `json:"ttl_seconds" binding:"min=0"`
+}
+
+type ForwardSourceSchema struct {
+	ChatID    string `json:"chat_id" binding:"required"`
+	MessageID string `json:"message_id" binding:"required"`
+}
+
+// PollSchema holds the settings of a new poll. Options is the number of
+// options in the encrypted message; votes refer to them by index. Polls are
+// anonymous unless anonymous is false.
+type PollSchema struct {
+	Options        int        `json:"options" binding:"required"`
+	MultipleChoice bool       `json:"multiple_choice"`
+	Anonymous      *bool      `json:"anonymous"`
+	CorrectOption  *int       `json:"correct_option"`
+	ClosesAt       *time.Time `json:"closes_at"`
+}
+
+type DevicePayloadSchema struct {
+	DeviceID   string `json:"device_id" binding:"required"`
+	Ciphertext []byte `json:"ciphertext" binding:"required"`
+}
+
+// EditMessagePayload carries the new encrypted content of a message.
+type EditMessagePayload struct {
+	SenderDeviceID string                `json:"sender_device_id" binding:"required"`
+	Ciphertext     []byte                `json:"ciphertext"`
+	Recipients     []DevicePayloadSchema `json:"recipients" binding:"dive"`
+}
+
+// parseDevicePayloads converts per-device payloads from a request body.
+func parseDevicePayloads(c *gin.Context, payloads []DevicePayloadSchema) ([]repos.DevicePayload, bool) {
+	out := make([]repos.DevicePayload, len(payloads))
+	for i, r := range payloads {
+		ids, ok := parseULIDs(c, []string{r.DeviceID})
+		if !ok {
+			return nil, false
+		}
+		out[i] = repos.DevicePayload{DeviceID: ids[0], Ciphertext: r.Ciphertext}
+	}
+	return out, true
+}
+
+// SendMessage stores an encrypted message. It responds with 201 when the
+// message was stored and 200 when a retry matched an earlier client_message_id.
+func (h *MessagesHandler) SendMessage(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload SendMessagePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	ids, ok := parseULIDs(c, []string{payload.SenderDeviceID})
+	if !ok {
+		return
+	}
+	recipients, ok := parseDevicePayloads(c, payload.Recipients)
+	if !ok {
+		return
+	}
+	params := services.SendMessageParams{
+		SenderDeviceID:     ids[0],
+		ContentType:        payload.ContentType,
+		Ciphertext:         payload.Ciphertext,
+		Recipients:         recipients,
+		ClientMessageID:    payload.ClientMessageID,
+		TTL:                time.Duration(payload.TTLSeconds) * time.Second,
+		ViewOnce:           payload.ViewOnce,
+		MentionedUsernames: payload.MentionedUsernames,
+	}
+	if payload.ThreadID != nil {
+		ids, ok := parseULIDs(c, []string{*payload.ThreadID})
+		if !ok {
+			return
+		}
+		params.ThreadID = &ids[0]
+	}
+	if payload.ReplyToID != nil {
+		ids, ok := parseULIDs(c, []string{*payload.ReplyToID})
+		if !ok {
+			return
+		}
+		params.ReplyToID = &ids[0]
+	}
+	if payload.ForwardFrom != nil {
+		ids, ok := parseULIDs(c, []string{payload.ForwardFrom.ChatID, payload.ForwardFrom.MessageID})
+		if !ok {
+			return
+		}
+		params.ForwardFrom = &services.ForwardSource{ChatID: ids[0], MessageID: ids[1]}
+	}
+	if len(payload.MentionedUserIDs) > 0 {
+		if params.MentionedUserIDs, ok = parseULIDs(c, payload.MentionedUserIDs); !ok {
+			return
+		}
+	}
+	if p := payload.Poll; p != nil {
+		params.Poll = &services.PollParams{
+			Options:        p.Options,
+			MultipleChoice: p.MultipleChoice,
+			Anonymous:      p.Anonymous == nil || *p.Anonymous,
+			CorrectOption:  p.CorrectOption,
+			ClosesAt:       p.ClosesAt,
+		}
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	msg, created, err := h.service.SendMessage(c.Request.Context(), userID, chatID, params)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(createdStatus(created), msg)
+}
+
+func (h *MessagesHandler) GetMessage(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	messageID, ok := parseULIDParam(c, "message_id")
+	if !ok {
+		return
+	}
+	deviceID, ok := parseOptionalULIDQuery(c, "device_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	msg, err := h.service.GetMessage(c.Request.Context(), userID, chatID, messageID, deviceID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, msg)
+}
+
+// EditMessage replaces the content of a message sent by the caller.
+func (h *MessagesHandler) EditMessage(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	messageID, ok := parseULIDParam(c, "message_id")
+	if !ok {
+		return
+	}
+
+	var payload EditMessagePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+	ids, ok := parseULIDs(c, []string{payload.SenderDeviceID})
+	if !ok {
+		return
+	}
+	recipients, ok := parseDevicePayloads(c, payload.Recipients)
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	msg, err := h.service.EditMessage(c.Request.Context(), userID, chatID, messageID, services.EditMessageParams{
+		SenderDeviceID: ids[0],
+		Ciphertext:     payload.Ciphertext,
+		Recipients:     recipients,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, msg)
+}
+
+// ListEdits returns a message's earlier versions.
+func (h *MessagesHandler) ListEdits(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	messageID, ok := parseULIDParam(c, "message_id")
+	if !ok {
+		return
+	}
+	deviceID, ok := parseOptionalULIDQuery(c, "device_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	history, err := h.service.ListEdits(c.Request.Context(), userID, chatID, messageID, deviceID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// DeleteMessage deletes one message for the caller, or for everyone when
+// the for_everyone query parameter is true.
+func (h *MessagesHandler) DeleteMessage(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	messageID, ok := parseULIDParam(c, "message_id")
+	if !ok {
+		return
+	}
+	forEveryone, _ := strconv.ParseBool(c.Query("for_everyone"))
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.DeleteMessages(c.Request.Context(), userID, chatID, []ulid.ULID{messageID}, forEveryone); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteMessagesPayload lists messages to delete in one request.
+type DeleteMessagesPayload struct {
+	MessageIDs  []string `json:"message_ids" binding:"required"`
+	ForEveryone bool     `json:"for_everyone"`
+}
+
+// DeleteMessages deletes several messages of a chat at once.
+func (h *MessagesHandler) DeleteMessages(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload DeleteMessagesPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+	messageIDs, ok := parseULIDs(c, payload.MessageIDs)
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.DeleteMessages(c.Request.Context(), userID, chatID, messageIDs, payload.ForEveryone); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ClearHistory deletes all of a chat's current messages for the caller.
+func (h *MessagesHandler) ClearHistory(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	seq, err := h.service.ClearHistory(c.Request.Context(), userID, chatID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cleared_seq": seq})
+}
+
+// ListHistory pages through a chat's messages. At most one of the before,
+// after and around query parameters may be set, each a message seq or ID;
+// with none the newest messages are returned.
+func (h *MessagesHandler) ListHistory(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	query := services.HistoryQuery{Direction: services.HistoryBefore}
+	anchors := 0
+	for _, direction := range []string{services.HistoryBefore, services.HistoryAfter, services.HistoryAround} {
+		if anchor := c.Query(direction); anchor != "" {
+			query.Direction, query.Anchor = direction, anchor
+			anchors++
+		}
+	}
+	if anchors > 1 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: "Use only one of before, after and around"})
+		return
+	}
+	if query.ThreadID, ok = parseOptionalULIDQuery(c, "thread_id"); !ok {
+		return
+	}
+	if query.DeviceID, ok = parseOptionalULIDQuery(c, "device_id"); !ok {
+		return
+	}
+	query.Limit, _ = strconv.Atoi(c.Query("limit"))
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	page, err := h.service.ListHistory(c.Request.Context(), userID, chatID, query)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *MessagesHandler) SetMessageTTL(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload MessageTTLPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.SetMessageTTL(c.Request.Context(), userID, chatID, time.Duration(payload.TTLSeconds)*time.Second); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ViewMessage marks a view-once message as viewed, which deletes it for the
+// caller.
+func (h *MessagesHandler) ViewMessage(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	messageID, ok := parseULIDParam(c, "message_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.ViewMessage(c.Request.Context(), userID, chatID, messageID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+// BanPayload bans a user; a missing until bans permanently.
+type BanPayload struct {
+	UserID string     `json:"user_id" binding:"required"`
+	Until  *time.Time `json:"until"`
+}
+
+// RestrictionPayload makes a member read-only; a null until lifts it.
+type RestrictionPayload struct {
+	Until *time.Time `json:"until"`
+}
+
+type SlowModePayload struct {
+	Seconds int `json:"seconds" binding:"min=0"`
+}
+
+func (h *ChatsHandler) BanMember(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload BanPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+	targetID, err := ulid.Parse(payload.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: "Invalid user ID format"})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	ban, err := h.service.BanMember(c.Request.Context(), userID, chatID, targetID, payload.Until)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, ban)
+}
+
+func (h *ChatsHandler) UnbanMember(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	targetID, ok := parseULIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.UnbanMember(c.Request.Context(), userID, chatID, targetID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ChatsHandler) ListBans(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	page, err := h.service.ListBans(c.Request.Context(), userID, chatID, c.Query("cursor"), limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *ChatsHandler) RestrictMember(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	targetID, ok := parseULIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	var payload RestrictionPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.RestrictMember(c.Request.Context(), userID, chatID, targetID, payload.Until); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ChatsHandler) SetSlowMode(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload SlowModePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.SetSlowMode(c.Request.Context(), userID, chatID, payload.Seconds); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListAuditLog lists a chat's admin actions. It accepts repeated or
+// comma-separated action filters and optional actor_id and target_user_id.
+func (h *ChatsHandler) ListAuditLog(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var filter services.AuditLogFilter
+	for _, raw := range c.QueryArray("action") {
+		for _, action := range strings.Split(raw, ",") {
+			if action = strings.TrimSpace(action); action != "" {
+				filter.Actions = append(filter.Actions, action)
+			}
+		}
+	}
+	if filter.ActorID, ok = parseOptionalULIDQuery(c, "actor_id"); !ok {
+		return
+	}
+	if filter.TargetUserID, ok = parseOptionalULIDQuery(c, "target_user_id"); !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	page, err := h.service.ListAuditLog(c.Request.Context(), userID, chatID, filter, c.Query("cursor"), limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
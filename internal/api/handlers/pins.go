@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+// PinsService defines the interface for pinned messages.
+type PinsService interface {
+	PinMessage(ctx context.Context, userID, chatID, messageID ulid.ULID, notify bool) error
+	UnpinMessage(ctx context.Context, userID, chatID, messageID ulid.ULID) error
+	UnpinAllMessages(ctx context.Context, userID, chatID ulid.ULID) error
+	ListPinnedMessages(ctx context.Context, userID, chatID ulid.ULID, deviceID *ulid.ULID, cursor string, limit int) (*services.PinnedPage, error)
+}
+
+// PinsHandler handles API requests related to pinned messages.
+type PinsHandler struct {
+	service PinsService
+}
+
+// NewPinsHandler creates a new PinsHandler.
+func NewPinsHandler(service PinsService) *PinsHandler {
+	return &PinsHandler{service: service}
+}
+
+// RegisterPinRoutes registers all pin-related routes with the Gin router.
+func (h *PinsHandler) RegisterPinRoutes(router *gin.RouterGroup) {
+	pinned := router.Group("/chats/:chat_id/pinned")
+	{
+		pinned.GET("", h.ListPinnedMessages)
+		pinned.DELETE("", h.UnpinAllMessages)
+		pinned.PUT("/:message_id", h.PinMessage)
+		pinned.DELETE("/:message_id", h.UnpinMessage)
+	}
+}
+
+// PinMessage pins a message. With notify=true members are alerted about
+// the pin.
+func (h *PinsHandler) PinMessage(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	messageID, ok := parseULIDParam(c, "message_id")
+	if !ok {
+		return
+	}
+	notify, _ := strconv.ParseBool(c.Query("notify"))
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.PinMessage(c.Request.Context(), userID, chatID, messageID, notify); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *PinsHandler) UnpinMessage(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	messageID, ok := parseULIDParam(c, "message_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.UnpinMessage(c.Request.Context(), userID, chatID, messageID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *PinsHandler) UnpinAllMessages(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.UnpinAllMessages(c.Request.Context(), userID, chatID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *PinsHandler) ListPinnedMessages(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	deviceID, ok := parseOptionalULIDQuery(c, "device_id")
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	page, err := h.service.ListPinnedMessages(c.Request.Context(), userID, chatID, deviceID, c.Query("cursor"), limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
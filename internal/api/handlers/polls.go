@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+// PollsService defines the interface for voting in polls.
+type PollsService interface {
+	GetPoll(ctx context.Context, userID, chatID, messageID ulid.ULID) (*services.PollResults, error)
+	Vote(ctx context.Context, userID, chatID, messageID ulid.ULID, options []int) (*services.PollResults, error)
+	RetractVote(ctx context.Context, userID, chatID, messageID ulid.ULID) (*services.PollResults, error)
+	ClosePoll(ctx context.Context, userID, chatID, messageID ulid.ULID) (*services.PollResults, error)
+	ListVoters(ctx context.Context, userID, chatID, messageID ulid.ULID, option *int, cursor string, limit int) (*services.PollVoterPage, error)
+}
+
+// PollsHandler handles API requests related to polls.
+type PollsHandler struct {
+	service PollsService
+}
+
+// NewPollsHandler creates a new PollsHandler.
+func NewPollsHandler(service PollsService) *PollsHandler {
+	return &PollsHandler{service: service}
+}
+
+// VotePayload lists the chosen option indexes.
+type VotePayload struct {
+	Options []int `json:"options" binding:"required"`
+}
+
+// RegisterPollRoutes registers all poll-related routes with the Gin router.
+func (h *PollsHandler) RegisterPollRoutes(router *gin.RouterGroup) {
+	poll := router.Group("/chats/:chat_id/messages/:message_id/poll")
+	{
+		poll.GET("", h.GetPoll)
+		poll.PUT("/vote", h.Vote)
+		poll.DELETE("/vote", h.RetractVote)
+		poll.POST("/close", h.ClosePoll)
+		poll.GET("/voters", h.ListVoters)
+	}
+}
+
+func (h *PollsHandler) GetPoll(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	messageID, ok := parseULIDParam(c, "message_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	results, err := h.service.GetPoll(c.Request.Context(), userID, chatID, messageID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// Vote replaces the caller's choices. Repeating a vote is a no-op.
+func (h *PollsHandler) Vote(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	messageID, ok := parseULIDParam(c, "message_id")
+	if !ok {
+		return
+	}
+
+	var payload VotePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	results, err := h.service.Vote(c.Request.Context(), userID, chatID, messageID, payload.Options)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+func (h *PollsHandler) RetractVote(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	messageID, ok := parseULIDParam(c, "message_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	results, err := h.service.RetractVote(c.Request.Context(), userID, chatID, messageID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+func (h *PollsHandler) ClosePoll(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	messageID, ok := parseULIDParam(c, "message_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	results, err := h.service.ClosePoll(c.Request.Context(), userID, chatID, messageID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// ListVoters lists who voted in a public poll. The option query parameter
+// narrows the list to one option.
+func (h *PollsHandler) ListVoters(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	messageID, ok := parseULIDParam(c, "message_id")
+	if !ok {
+		return
+	}
+	var option *int
+	if raw := c.Query("option"); raw != "" {
+		o, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: "option must be a number"})
+			return
+		}
+		option = &o
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	page, err := h.service.ListVoters(c.Request.Context(), userID, chatID, messageID, option, c.Query("cursor"), limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+// PrivacyService defines the interface for privacy settings business logic.
+type PrivacyService interface {
+	GetPrivacySettings(ctx context.Context, userID ulid.ULID) (*services.PrivacySettings, error)
+	UpdatePrivacySettings(ctx context.Context, userID ulid.ULID, params services.PrivacySettingsParams) (*services.PrivacySettings, error)
+}
+
+// PrivacyHandler handles API requests for the caller's privacy settings.
+type PrivacyHandler struct {
+	service PrivacyService
+}
+
+// NewPrivacyHandler creates a new PrivacyHandler.
+func NewPrivacyHandler(service PrivacyService) *PrivacyHandler {
+	return &PrivacyHandler{service: service}
+}
+
+// RegisterPrivacyRoutes registers all privacy-related routes with the Gin router.
+func (h *PrivacyHandler) RegisterPrivacyRoutes(router *gin.RouterGroup) {
+	router.GET("/privacy", h.GetPrivacySettings)
+	router.PATCH("/privacy", h.UpdatePrivacySettings)
+}
+
+// PrivacySettingsPayload changes privacy settings. Omitted fields are left
+// unchanged.
+type PrivacySettingsPayload struct {
+	ReadReceipts       *bool `json:"read_receipts"`
+	ForwardAttribution *bool `json:"forward_attribution"`
+}
+
+func (h *PrivacyHandler) GetPrivacySettings(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	settings, err := h.service.GetPrivacySettings(c.Request.Context(), userID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *PrivacyHandler) UpdatePrivacySettings(c *gin.Context) {
+	var payload PrivacySettingsPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	settings, err := h.service.UpdatePrivacySettings(c.Request.Context(), userID, services.PrivacySettingsParams{
+		ReadReceipts:       payload.ReadReceipts,
+		ForwardAttribution: payload.ForwardAttribution,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+// ReactionsService defines the interface for message reactions.
+type ReactionsService interface {
+	AddReaction(ctx context.Context, userID, chatID, messageID ulid.ULID, emoji string) (*services.MessageReactions, error)
+	RemoveReaction(ctx context.Context, userID, chatID, messageID ulid.ULID, emoji string) (*services.MessageReactions, error)
+	ListReactions(ctx context.Context, userID, chatID, messageID ulid.ULID, emoji, cursor string, limit int) (*services.ReactionPage, error)
+	SetAllowedReactions(ctx context.Context, actorID, chatID ulid.ULID, emoji []string) error
+}
+
+// ReactionsHandler handles API requests related to message reactions.
+type ReactionsHandler struct {
+	service ReactionsService
+}
+
+// NewReactionsHandler creates a new ReactionsHandler.
+func NewReactionsHandler(service ReactionsService) *ReactionsHandler {
+	return &ReactionsHandler{service: service}
+}
+
+// RegisterReactionRoutes registers all reaction-related routes with the Gin router.
+func (h *ReactionsHandler) RegisterReactionRoutes(router *gin.RouterGroup) {
+	chat := router.Group("/chats/:chat_id")
+	{
+		chat.PUT("/allowed-reactions", h.SetAllowedReactions)
+
+		message := chat.Group("/messages/:message_id/reactions")
+		message.GET("", h.ListReactions)
+		message.PUT("/:emoji", h.AddReaction)
+		message.DELETE("/:emoji", h.RemoveReaction)
+	}
+}
+
+// SetAllowedReactionsPayload restricts the emoji a chat accepts. A null
+// list allows every emoji and an empty list turns reactions off.
+type SetAllowedReactionsPayload struct {
+	AllowedReactions *[]string `json:"allowed_reactions"`
+}
+
+func (h *ReactionsHandler) AddReaction(c *gin.Context) {
+	h.changeReaction(c, h.service.AddReaction)
+}
+
+func (h *ReactionsHandler) RemoveReaction(c *gin.Context) {
+	h.changeReaction(c, h.service.RemoveReaction)
+}
+
+func (h *ReactionsHandler) changeReaction(c *gin.Context, change func(ctx context.Context, userID, chatID, messageID ulid.ULID, emoji string) (*services.MessageReactions, error)) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	messageID, ok := parseULIDParam(c, "message_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	reactions, err := change(c.Request.Context(), userID, chatID, messageID, c.Param("emoji"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reactions)
+}
+
+func (h *ReactionsHandler) ListReactions(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	messageID, ok := parseULIDParam(c, "message_id")
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	page, err := h.service.ListReactions(c.Request.Context(), userID, chatID, messageID, c.Query("emoji"), c.Query("cursor"), limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *ReactionsHandler) SetAllowedReactions(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload SetAllowedReactionsPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+	var emoji []string
+	if payload.AllowedReactions != nil {
+		emoji = *payload.AllowedReactions
+		if emoji == nil {
+			emoji = []string{}
+		}
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.SetAllowedReactions(c.Request.Context(), userID, chatID, emoji); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oklog/ulid/v2"
+)
+
+// RealtimeHub defines the interface for streaming realtime events to a
+// connected device.
+type RealtimeHub interface {
+	Serve(w http.ResponseWriter, r *http.Request, userID ulid.ULID) error
+}
+
+// RealtimeHandler upgrades requests to WebSocket connections.
+type RealtimeHandler struct {
+	hub RealtimeHub
+}
+
+// NewRealtimeHandler creates a new RealtimeHandler.
+func NewRealtimeHandler(hub RealtimeHub) *RealtimeHandler {
+	return &RealtimeHandler{hub: hub}
+}
+
+// RegisterRealtimeRoutes registers the WebSocket endpoint with the Gin router.
+func (h *RealtimeHandler) RegisterRealtimeRoutes(router *gin.RouterGroup) {
+	router.GET("/ws", h.Connect)
+}
+
+// Connect streams the caller's updates until the connection closes. Devices
+// that were offline catch up from the update log. Clients send chat actions,
+// such as typing, over the same connection.
+func (h *RealtimeHandler) Connect(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	// Serve writes its own response when the upgrade fails.
+	if err := h.hub.Serve(c.Writer, c.Request, userID); err != nil {
+		log.Printf("ws: upgrade failed for user %s: %v", userID, err)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+// ReceiptsService defines the interface for delivery and read receipts.
+type ReceiptsService interface {
+	MarkDelivered(ctx context.Context, userID, chatID, deviceID ulid.ULID, seq int64) (int64, error)
+	MarkRead(ctx context.Context, userID, chatID ulid.ULID, seq int64) (int64, error)
+	GetReceipts(ctx context.Context, userID, chatID ulid.ULID) (*services.ChatReceipts, error)
+}
+
+// ReceiptsHandler handles API requests related to delivery and read receipts.
+type ReceiptsHandler struct {
+	service ReceiptsService
+}
+
+// NewReceiptsHandler creates a new ReceiptsHandler.
+func NewReceiptsHandler(service ReceiptsService) *ReceiptsHandler {
+	return &ReceiptsHandler{service: service}
+}
+
+// RegisterReceiptRoutes registers all receipt-related routes with the Gin router.
+func (h *ReceiptsHandler) RegisterReceiptRoutes(router *gin.RouterGroup) {
+	chat := router.Group("/chats/:chat_id")
+	{
+		chat.POST("/delivered", h.MarkDelivered)
+		chat.POST("/read", h.MarkRead)
+		chat.GET("/receipts", h.GetReceipts)
+	}
+}
+
+// MarkDeliveredPayload acknowledges that a device received a chat up to Seq.
+type MarkDeliveredPayload struct {
+	DeviceID string `json:"device_id" binding:"required"`
+	Seq      int64  `json:"seq" binding:"min=0"`
+}
+
+func (h *ReceiptsHandler) MarkDelivered(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload MarkDeliveredPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+	ids, ok := parseULIDs(c, []string{payload.DeviceID})
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	seq, err := h.service.MarkDelivered(c.Request.Context(), userID, chatID, ids[0], payload.Seq)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"delivered_seq": seq})
+}
+
+// MarkRead moves the caller's read position. A zero seq marks the whole
+// chat read.
+func (h *ReceiptsHandler) MarkRead(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload MarkReadPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	seq, err := h.service.MarkRead(c.Request.Context(), userID, chatID, payload.Seq)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"read_seq": seq})
+}
+
+func (h *ReceiptsHandler) GetReceipts(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	receipts, err := h.service.GetReceipts(c.Request.Context(), userID, chatID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, receipts)
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+// ScheduledService defines the interface for scheduled messages.
+type ScheduledService interface {
+	ScheduleMessage(ctx context.Context, userID, chatID ulid.ULID, params services.ScheduleMessageParams) (*db.ScheduledMessage, error)
+	ListScheduledMessages(ctx context.Context, userID, chatID ulid.ULID) ([]db.ScheduledMessage, error)
+	EditScheduledMessage(ctx context.Context, userID, chatID, id ulid.ULID, params services.EditScheduledParams) (*db.ScheduledMessage, error)
+	CancelScheduledMessage(ctx context.Context, userID, chatID, id ulid.ULID) error
+}
+
+// ScheduledHandler handles API requests related to scheduled messages.
+type ScheduledHandler struct {
+	service ScheduledService
+}
+
+// NewScheduledHandler creates a new ScheduledHandler.
+func NewScheduledHandler(service ScheduledService) *ScheduledHandler {
+	return &ScheduledHandler{service: service}
+}
+
+// RegisterScheduledRoutes registers all scheduled-message routes with the Gin router.
+func (h *ScheduledHandler) RegisterScheduledRoutes(router *gin.RouterGroup) {
+	scheduled := router.Group("/chats/:chat_id/scheduled")
+	{
+		scheduled.GET("", h.ListScheduledMessages)
+		scheduled.POST("", h.ScheduleMessage)
+		scheduled.PATCH("/:scheduled_id", h.EditScheduledMessage)
+		scheduled.DELETE("/:scheduled_id", h.CancelScheduledMessage)
+	}
+}
+
+// ScheduleMessagePayload carries an encrypted message to send at SendAt,
+// or with WhenOnline once the peer of a direct chat is next online.
+type ScheduleMessagePayload struct {
+	SenderDeviceID string                `json:"sender_device_id" binding:"required"`
+	ThreadID       *string               `json:"thread_id"`
+	ReplyToID      *string               `json:"reply_to_id"`
+	ContentType    string                `json:"content_type" binding:"required"`
+	Ciphertext     []byte                `json:"ciphertext"`
+	Recipients     []DevicePayloadSchema `json:"recipients" binding:"dive"`
+	SendAt         *time.Time            `json:"send_at"`
+	WhenOnline     bool                  `json:"when_online"`
+}
+
+// EditScheduledPayload changes the content, the send time or both.
+type EditScheduledPayload struct {
+	Content *EditMessagePayload `json:"content"`
+	SendAt  *time.Time          `json:"send_at"`
+}
+
+func (h *ScheduledHandler) ScheduleMessage(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload ScheduleMessagePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+	ids, ok := parseULIDs(c, []string{payload.SenderDeviceID})
+	if !ok {
+		return
+	}
+	recipients, ok := parseDevicePayloads(c, payload.Recipients)
+	if !ok {
+		return
+	}
+	params := services.ScheduleMessageParams{
+		SenderDeviceID: ids[0],
+		ContentType:    payload.ContentType,
+		Ciphertext:     payload.Ciphertext,
+		Recipients:     recipients,
+		SendAt:         payload.SendAt,
+		WhenOnline:     payload.WhenOnline,
+	}
+	if payload.ThreadID != nil {
+		ids, ok := parseULIDs(c, []string{*payload.ThreadID})
+		if !ok {
+			return
+		}
+		params.ThreadID = &ids[0]
+	}
+	if payload.ReplyToID != nil {
+		ids, ok := parseULIDs(c, []string{*payload.ReplyToID})
+		if !ok {
+			return
+		}
+		params.ReplyToID = &ids[0]
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	msg, err := h.service.ScheduleMessage(c.Request.Context(), userID, chatID, params)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, msg)
+}
+
+func (h *ScheduledHandler) ListScheduledMessages(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	items, err := h.service.ListScheduledMessages(c.Request.Context(), userID, chatID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func (h *ScheduledHandler) EditScheduledMessage(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	scheduledID, ok := parseULIDParam(c, "scheduled_id")
+	if !ok {
+		return
+	}
+
+	var payload EditScheduledPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+	params := services.EditScheduledParams{SendAt: payload.SendAt}
+	if payload.Content != nil {
+		ids, ok := parseULIDs(c, []string{payload.Content.SenderDeviceID})
+		if !ok {
+			return
+		}
+		recipients, ok := parseDevicePayloads(c, payload.Content.Recipients)
+		if !ok {
+			return
+		}
+		params.Content = &services.EditMessageParams{
+			SenderDeviceID: ids[0],
+			Ciphertext:     payload.Content.Ciphertext,
+			Recipients:     recipients,
+		}
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	msg, err := h.service.EditScheduledMessage(c.Request.Context(), userID, chatID, scheduledID, params)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, msg)
+}
+
+func (h *ScheduledHandler) CancelScheduledMessage(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	scheduledID, ok := parseULIDParam(c, "scheduled_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.CancelScheduledMessage(c.Request.Context(), userID, chatID, scheduledID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+// ThreadsService defines the interface for forum topic and thread business logic.
+type ThreadsService interface {
+	SetForum(ctx context.Context, actorID, chatID ulid.ULID, enabled bool) error
+	CreateTopic(ctx context.Context, userID, chatID ulid.ULID, params services.TopicParams) (*db.ChatThread, error)
+	UpdateTopic(ctx context.Context, userID, chatID, threadID ulid.ULID, params services.TopicParams) (*db.ChatThread, error)
+	DeleteTopic(ctx context.Context, userID, chatID, threadID ulid.ULID) error
+	ListTopics(ctx context.Context, userID, chatID ulid.ULID, cursor string, limit int) (*services.ThreadPage, error)
+	GetOrCreateReplyThread(ctx context.Context, userID, chatID, rootMessageID ulid.ULID) (*db.ChatThread, bool, error)
+	GetThread(ctx context.Context, userID, chatID, threadID ulid.ULID) (*db.ListThreadsWithStateRow, error)
+	MarkRead(ctx context.Context, userID, chatID, threadID ulid.ULID, seq int64) (int64, error)
+	SetNotifications(ctx context.Context, userID, chatID, threadID ulid.ULID, level db.ThreadNotifyLevel, mutedUntil *time.Time) error
+}
+
+// ThreadsHandler handles API requests related to forum topics and threads.
+type ThreadsHandler struct {
+	service ThreadsService
+}
+
+// NewThreadsHandler creates a new ThreadsHandler.
+func NewThreadsHandler(service ThreadsService) *ThreadsHandler {
+	return &ThreadsHandler{service: service}
+}
+
+// RegisterThreadRoutes registers all thread-related routes with the Gin router.
+func (h *ThreadsHandler) RegisterThreadRoutes(router *gin.RouterGroup) {
+	chat := router.Group("/chats/:chat_id")
+	{
+		chat.PUT("/forum", h.SetForum)
+
+		topics := chat.Group("/topics")
+		{
+			topics.GET("", h.ListTopics)
+			topics.POST("", h.CreateTopic)
+			topics.PATCH("/:thread_id", h.UpdateTopic)
+			topics.DELETE("/:thread_id", h.DeleteTopic)
+		}
+
+		threads := chat.Group("/threads")
+		{
+			threads.POST("", h.GetOrCreateReplyThread)
+			threads.GET("/:thread_id", h.GetThread)
+			threads.POST("/:thread_id/read", h.MarkRead)
+			threads.PUT("/:thread_id/notifications", h.SetNotifications)
+		}
+	}
+}
+
+type SetForumPayload struct {
+	Enabled bool `json:"enabled"`
+}
+
+type TopicPayload struct {
+	Title     *string `json:"title"`
+	IconColor *int32  `json:"icon_color"`
+	Closed    *bool   `json:"closed"`
+}
+
+type ReplyThreadPayload struct {
+	RootMessageID string `json:"root_message_id" binding:"required"`
+}
+
+type MarkReadPayload struct {
+	Seq int64 `json:"seq" binding:"min=0"`
+}
+
+type ThreadNotificationsPayload struct {
+	Level      db.ThreadNotifyLevel `json:"level" binding:"required,oneof=all mentions none"`
+	MutedUntil *time.Time           `json:"muted_until"`
+}
+
+func (h *ThreadsHandler) SetForum(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload SetForumPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.SetForum(c.Request.Context(), userID, chatID, payload.Enabled); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ThreadsHandler) ListTopics(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	page, err := h.service.ListTopics(c.Request.Context(), userID, chatID, c.Query("cursor"), limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *ThreadsHandler) CreateTopic(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload TopicPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	topic, err := h.service.CreateTopic(c.Request.Context(), userID, chatID, services.TopicParams{
+		Title:     payload.Title,
+		IconColor: payload.IconColor,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, topic)
+}
+
+func (h *ThreadsHandler) UpdateTopic(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	threadID, ok := parseULIDParam(c, "thread_id")
+	if !ok {
+		return
+	}
+
+	var payload TopicPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	topic, err := h.service.UpdateTopic(c.Request.Context(), userID, chatID, threadID, services.TopicParams{
+		Title:     payload.Title,
+		IconColor: payload.IconColor,
+		Closed:    payload.Closed,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, topic)
+}
+
+func (h *ThreadsHandler) DeleteTopic(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	threadID, ok := parseULIDParam(c, "thread_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.DeleteTopic(c.Request.Context(), userID, chatID, threadID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ThreadsHandler) GetOrCreateReplyThread(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+
+	var payload ReplyThreadPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+	rootMessageID, err := ulid.Parse(payload.RootMessageID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: "Invalid message ID format"})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	thread, created, err := h.service.GetOrCreateReplyThread(c.Request.Context(), userID, chatID, rootMessageID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(createdStatus(created), thread)
+}
+
+func (h *ThreadsHandler) GetThread(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	threadID, ok := parseULIDParam(c, "thread_id")
+	if !ok {
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	thread, err := h.service.GetThread(c.Request.Context(), userID, chatID, threadID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, thread)
+}
+
+func (h *ThreadsHandler) MarkRead(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	threadID, ok := parseULIDParam(c, "thread_id")
+	if !ok {
+		return
+	}
+
+	var payload MarkReadPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	seq, err := h.service.MarkRead(c.Request.Context(), userID, chatID, threadID, payload.Seq)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"last_read_seq": seq})
+}
+
+func (h *ThreadsHandler) SetNotifications(c *gin.Context) {
+	chatID, ok := parseULIDParam(c, "chat_id")
+	if !ok {
+		return
+	}
+	threadID, ok := parseULIDParam(c, "thread_id")
+	if !ok {
+		return
+	}
+
+	var payload ThreadNotificationsPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: err.Error()})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	if err := h.service.SetNotifications(c.Request.Context(), userID, chatID, threadID, payload.Level, payload.MutedUntil); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
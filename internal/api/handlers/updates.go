@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+// UpdatesService defines the interface for reading a user's update log.
+type UpdatesService interface {
+	GetState(ctx context.Context, userID ulid.ULID) (int64, error)
+	GetDifference(ctx context.Context, userID ulid.ULID, since int64, channels map[ulid.ULID]int64, limit int) (*services.Difference, error)
+}
+
+// UpdatesHandler handles API requests for syncing a device with the update log.
+type UpdatesHandler struct {
+	service UpdatesService
+}
+
+// NewUpdatesHandler creates a new UpdatesHandler.
+func NewUpdatesHandler(service UpdatesService) *UpdatesHandler {
+	return &UpdatesHandler{service: service}
+}
+
+// RegisterUpdateRoutes registers all update-related routes with the Gin router.
+func (h *UpdatesHandler) RegisterUpdateRoutes(router *gin.RouterGroup) {
+	updates := router.Group("/updates")
+	{
+		updates.GET("/state", h.GetState)
+		updates.GET("/difference", h.GetDifference)
+	}
+}
+
+// GetState returns the seq of the caller's newest update.
+func (h *UpdatesHandler) GetState(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	seq, err := h.service.GetState(c.Request.Context(), userID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"seq": seq})
+}
+
+// GetDifference returns the updates after the since query parameter, and
+// the channel updates after the chat_id:seq pairs in the channels query
+// parameter.
+func (h *UpdatesHandler) GetDifference(c *gin.Context) {
+	since, err := strconv.ParseInt(c.Query("since"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: "since must be an update seq"})
+		return
+	}
+
+	channels, ok := parseChannelSeqs(c.Query("channels"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{ErrorCode: "VALIDATION_ERROR", Message: "channels must be a comma-separated list of chat_id:seq"})
+		return
+	}
+
+	userID, ok := getUserID(c)
+	if !ok {
+		writeUnauthorized(c)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	diff, err := h.service.GetDifference(c.Request.Context(), userID, since, channels, limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+// parseChannelSeqs parses a comma-separated list of chat_id:seq pairs.
+func parseChannelSeqs(raw string) (map[ulid.ULID]int64, bool) {
+	channels := make(map[ulid.ULID]int64)
+	if raw == "" {
+		return channels, true
+	}
+	for _, pair := range strings.Split(raw, ",") {
+		id, seq, found := strings.Cut(pair, ":")
+		if !found {
+			return nil, false
+		}
+		chatID, err := ulid.Parse(id)
+		if err != nil {
+			return nil, false
+		}
+		if channels[chatID], err = strconv.ParseInt(seq, 10, 64); err != nil {
+			return nil, false
+		}
+	}
+	return channels, true
+}
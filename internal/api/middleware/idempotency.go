@@ -1 +1,186 @@
 package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/messenger/backend/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// IdempotencyStore reserves idempotency keys and stores the responses of
+// the requests that used them.
+type IdempotencyStore interface {
+	Begin(ctx context.Context, userID ulid.ULID, key, requestHash string) (*services.StoredResponse, error)
+	Complete(ctx context.Context, userID ulid.ULID, key string, resp services.StoredResponse) error
+	Release(ctx context.Context, userID ulid.ULID, key string) error
+}
+
+// IdempotencyMiddleware makes mutating requests that carry an
+// Idempotency-Key header safe to retry. Keys are scoped to the authenticated
+// user, so it must run after AuthMiddleware. The first request with a key
+// runs normally and its response is stored; retries get that response back
+// with an Idempotent-Replayed header. Reusing a key for a different method,
+// path or body is rejected with 409 CONFLICT. Server errors and rate limit
+// rejections are not stored, so retrying them runs the request again. The
+// body is buffered to fingerprint it, so bodies over maxBodySize are
+// rejected with 413. A request holding a key runs with a deadline of
+// timeout, so that it is over before a retry can take the key over.
+func IdempotencyMiddleware(store IdempotencyStore, maxBodySize int64, timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || !isMutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if !validIdempotencyKey(key) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error_code": "VALIDATION_ERROR", "message": "Idempotency-Key must be 1-255 printable ASCII characters"})
+			return
+		}
+		userID, ok := contextUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error_code": "UNAUTHORIZED", "message": "User ID not found in context"})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodySize))
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error_code": "VALIDATION_ERROR", "message": "Request body is too large"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error_code": "VALIDATION_ERROR", "message": "Could not read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		stored, err := store.Begin(ctx, userID, key, requestHash(c.Request, body))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		if stored != nil {
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(stored.StatusCode, stored.ContentType, stored.Body)
+			c.Abort()
+			return
+		}
+
+		handlerCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(handlerCtx)
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Record the outcome even if the client has gone away, since that
+		// client is exactly the one that will retry.
+		ctx = context.WithoutCancel(ctx)
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+			if err := store.Release(ctx, userID, key); err != nil {
+				log.Printf("idempotency: release failed: %v", err)
+			}
+			return
+		}
+		resp := services.StoredResponse{
+			StatusCode:  status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		}
+		if err := store.Complete(ctx, userID, key, resp); err != nil {
+			log.Printf("idempotency: storing response failed: %v", err)
+		}
+	}
+}
+
+// responseRecorder keeps a copy of the response body as it is written.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// requestHash fingerprints a request so that a reused key can be told apart
+// from a genuine retry.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func contextUserID(c *gin.Context) (ulid.ULID, bool) {
+	raw, ok := c.Get("userID")
+	if !ok {
+		return ulid.ULID{}, false
+	}
+	s, ok := raw.(string)
+	if !ok {
+		return ulid.ULID{}, false
+	}
+	id, err := ulid.Parse(s)
+	return id, err == nil
+}
+
+func abortWithError(c *gin.Context, err error) {
+	bizErr, ok := err.(*services.BusinessError)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error_code": "INTERNAL_ERROR", "message": err.Error()})
+		return
+	}
+	resp := gin.H{"error_code": bizErr.Code, "message": bizErr.Message}
+	if len(bizErr.Details) > 0 {
+		resp["details"] = bizErr.Details
+	}
+	if retryAfter, ok := bizErr.Details["retry_after"]; ok {
+		c.Header("Retry-After", fmt.Sprint(retryAfter))
+	}
+	c.AbortWithStatusJSON(http.StatusConflict, resp)
+}
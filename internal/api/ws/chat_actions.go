@@ -0,0 +1,160 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/messenger/backend/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+// Chat actions clients can send. ChatActionCancel ends the previous action
+// before it expires.
+const (
+	ChatActionTyping          = "typing"
+	ChatActionRecordingVoice  = "recording_voice"
+	ChatActionUploadingMedia  = "uploading_media"
+	ChatActionChoosingSticker = "choosing_sticker"
+	ChatActionCancel          = "cancel"
+)
+
+var chatActions = map[string]bool{
+	ChatActionTyping:          true,
+	ChatActionRecordingVoice:  true,
+	ChatActionUploadingMedia:  true,
+	ChatActionChoosingSticker: true,
+	ChatActionCancel:          true,
+}
+
+const (
+	// chatActionTTL is how long receivers show an action. Clients repeat an
+	// ongoing action before it runs out.
+	chatActionTTL = 6 * time.Second
+	// chatActionThrottle is the minimum time between two identical actions
+	// of one connection in the same chat and thread.
+	chatActionThrottle = 3 * time.Second
+	// maxActionsPerSecond caps all chat actions of one connection.
+	maxActionsPerSecond = 10
+	// maxTrackedActions bounds the throttling state of a connection.
+	maxTrackedActions = 64
+)
+
+// ChatActionRouter decides who sees a user's chat actions. The audience may
+// include the user and must not be modified.
+type ChatActionRouter interface {
+	ChatActionAudience(ctx context.Context, userID, chatID ulid.ULID, threadID *ulid.ULID) ([]ulid.ULID, error)
+}
+
+// ChatAction is what a client sends to show an action in a chat.
+type ChatAction struct {
+	ChatID   string `json:"chat_id"`
+	ThreadID string `json:"thread_id,omitempty"`
+	Action   string `json:"action"`
+}
+
+// ChatActionEvent is the data of a chat_action event. Receivers stop
+// showing the action after ExpiresIn seconds; zero means it was cancelled.
+type ChatActionEvent struct {
+	ChatID    string `json:"chat_id"`
+	ThreadID  string `json:"thread_id,omitempty"`
+	UserID    string `json:"user_id"`
+	Action    string `json:"action"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+type actionKey struct {
+	chatID   ulid.ULID
+	threadID string
+}
+
+type sentAction struct {
+	action string
+	at     time.Time
+}
+
+// actionLimiter throttles the chat actions of one connection.
+type actionLimiter struct {
+	last        map[actionKey]sentAction
+	windowStart time.Time
+	windowCount int
+}
+
+// handleChatAction fans a chat action out to the other online members of
+// the chat. Malformed and throttled actions are dropped silently.
+func (h *Hub) handleChatAction(ctx context.Context, userID ulid.ULID, c *client, data json.RawMessage) {
+	if h.actions == nil {
+		return
+	}
+	var action ChatAction
+	if err := json.Unmarshal(data, &action); err != nil || !chatActions[action.Action] {
+		return
+	}
+	chatID, err := ulid.Parse(action.ChatID)
+	if err != nil {
+		return
+	}
+	var threadID *ulid.ULID
+	if action.ThreadID != "" {
+		id, err := ulid.Parse(action.ThreadID)
+		if err != nil {
+			return
+		}
+		threadID = &id
+	}
+	if !c.actions.allow(actionKey{chatID: chatID, threadID: action.ThreadID}, action.Action, time.Now()) {
+		return
+	}
+
+	members, err := h.actions.ChatActionAudience(ctx, userID, chatID, threadID)
+	if err != nil {
+		// Non-members, users who cannot write there and unknown threads get
+		// no feedback.
+		var bizErr *services.BusinessError
+		if !errors.As(err, &bizErr) && ctx.Err() == nil {
+			log.Printf("ws: dropped chat action of user %s in chat %s: %v", userID, chatID, err)
+		}
+		return
+	}
+	event := ChatActionEvent{
+		ChatID:   action.ChatID,
+		ThreadID: action.ThreadID,
+		UserID:   userID.String(),
+		Action:   action.Action,
+	}
+	if action.Action != ChatActionCancel {
+		event.ExpiresIn = int(chatActionTTL / time.Second)
+	}
+	h.sendToOnline(members, userID, Event{Event: "chat_action", Data: event})
+}
+
+// allow reports whether an action may be sent now. Repeats of the same
+// action in a chat and thread are throttled, and a connection sends at most
+// maxActionsPerSecond actions overall.
+func (l *actionLimiter) allow(key actionKey, action string, now time.Time) bool {
+	last, ok := l.last[key]
+	if ok && last.action == action && now.Sub(last.at) < chatActionThrottle {
+		return false
+	}
+	if now.Sub(l.windowStart) >= time.Second {
+		l.windowStart, l.windowCount = now, 0
+	}
+	if l.windowCount >= maxActionsPerSecond {
+		return false
+	}
+	if !ok && len(l.last) >= maxTrackedActions {
+		for k, a := range l.last {
+			if now.Sub(a.at) >= chatActionThrottle {
+				delete(l.last, k)
+			}
+		}
+		if len(l.last) >= maxTrackedActions {
+			return false
+		}
+	}
+	l.windowCount++
+	l.last[key] = sentAction{action: action, at: now}
+	return true
+}
@@ -1 +1,218 @@
+// Package ws pushes realtime events to users' connected devices over
+// WebSockets.
 package ws
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/messenger/backend/internal/services"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	// sendBuffer is how many events may queue for a connection before it is
+	// considered too slow and dropped.
+	sendBuffer   = 64
+	writeTimeout = 10 * time.Second
+	// presenceInterval is how often a connected user's presence is
+	// refreshed.
+	presenceInterval = time.Minute
+	// readLimit caps the size of frames clients send.
+	readLimit = 4 << 10
+)
+
+// Event is the frame written to clients.
+type Event struct {
+	Event string `json:"event"`
+	Data  any    `json:"data"`
+}
+
+// PresenceTracker records when users are online.
+type PresenceTracker interface {
+	TouchPresence(ctx context.Context, userID ulid.ULID) error
+}
+
+type client struct {
+	send   chan []byte
+	cancel context.CancelFunc
+	// actions throttles the chat actions the client sends. Only the
+	// connection's read loop touches it.
+	actions actionLimiter
+}
+
+// Hub tracks the connections of online users and fans events out to all of
+// a user's connections.
+type Hub struct {
+	mu       sync.RWMutex
+	clients  map[ulid.ULID]map[*client]struct{}
+	presence PresenceTracker
+	actions  ChatActionRouter
+}
+
+// NewHub creates an empty Hub.
+func NewHub() *Hub {
+	return &Hub{clients: make(map[ulid.ULID]map[*client]struct{})}
+}
+
+// TrackPresence makes the hub report connected users to tracker when they
+// connect and every presenceInterval while they stay connected. It must be
+// called before the hub serves connections.
+func (h *Hub) TrackPresence(tracker PresenceTracker) {
+	h.presence = tracker
+}
+
+// RouteChatActions makes the hub fan chat actions clients send out to the
+// audience router returns. Without it chat actions are dropped. It must be
+// called before the hub serves connections.
+func (h *Hub) RouteChatActions(router ChatActionRouter) {
+	h.actions = router
+}
+
+// Statically check that Hub implements services.Notifier.
+var _ services.Notifier = (*Hub)(nil)
+
+// NotifyUser pushes an update to every connection of the user.
+func (h *Hub) NotifyUser(userID ulid.ULID, update services.Update) {
+	h.SendToUser(userID, Event{Event: "update", Data: update})
+}
+
+// SendToUser writes an event to every connection of the user. Connections
+// whose buffer is full are closed; they resync from the update log when
+// they reconnect.
+func (h *Hub) SendToUser(userID ulid.ULID, event Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("ws: failed to encode %s event: %v", event.Event, err)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	h.push(userID, data)
+}
+
+// sendToOnline writes an event to the connections of those users who are
+// online, except skip. Events that are not worth storing, like chat actions,
+// go out this way to chats of any size.
+func (h *Hub) sendToOnline(userIDs []ulid.ULID, skip ulid.ULID, event Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("ws: failed to encode %s event: %v", event.Event, err)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, userID := range userIDs {
+		if userID != skip {
+			h.push(userID, data)
+		}
+	}
+}
+
+// push queues data on every connection of the user. The caller holds h.mu.
+func (h *Hub) push(userID ulid.ULID, data []byte) {
+	for c := range h.clients[userID] {
+		select {
+		case c.send <- data:
+		default:
+			c.cancel()
+		}
+	}
+}
+
+// Serve upgrades the request and streams events to it until the client
+// disconnects. Clients can send chat actions on the same connection.
+func (h *Hub) Serve(w http.ResponseWriter, r *http.Request, userID ulid.ULID) error {
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.CloseNow()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	c := &client{send: make(chan []byte, sendBuffer), cancel: cancel, actions: actionLimiter{last: make(map[actionKey]sentAction)}}
+	h.register(userID, c)
+	defer h.unregister(userID, c)
+
+	conn.SetReadLimit(readLimit)
+	go h.readLoop(ctx, conn, userID, c)
+
+	h.touchPresence(ctx, userID)
+	presence := time.NewTicker(presenceInterval)
+	defer presence.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-presence.C:
+			h.touchPresence(ctx, userID)
+		case data := <-c.send:
+			writeCtx, done := context.WithTimeout(ctx, writeTimeout)
+			err := conn.Write(writeCtx, websocket.MessageText, data)
+			done()
+			if err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// readLoop handles the frames a client sends until the connection fails,
+// then cancels the connection.
+func (h *Hub) readLoop(ctx context.Context, conn *websocket.Conn, userID ulid.ULID, c *client) {
+	defer c.cancel()
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		var frame struct {
+			Event string          `json:"event"`
+			Data  json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(data, &frame); err != nil {
+			continue
+		}
+		switch frame.Event {
+		case "chat_action":
+			h.handleChatAction(ctx, userID, c, frame.Data)
+		}
+	}
+}
+
+func (h *Hub) touchPresence(ctx context.Context, userID ulid.ULID) {
+	if h.presence == nil {
+		return
+	}
+	if err := h.presence.TouchPresence(ctx, userID); err != nil {
+		log.Printf("ws: failed to record presence of user %s: %v", userID, err)
+	}
+}
+
+func (h *Hub) register(userID ulid.ULID, c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[userID] == nil {
+		h.clients[userID] = make(map[*client]struct{})
+	}
+	h.clients[userID][c] = struct{}{}
+}
+
+func (h *Hub) unregister(userID ulid.ULID, c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients[userID], c)
+	if len(h.clients[userID]) == 0 {
+		delete(h.clients, userID)
+	}
+}
@@ -96,6 +96,24 @@ type LimitsConfig struct {
 	MaxAttachmentSize   int64 `mapstructure:"max_attachment_size"`
 	MessageRatePerMin   int   `mapstructure:"message_rate_per_min"`
 	CallMaxParticipants int   `mapstructure:"call_max_participants"`
+	// AuditLogRetention is how long chat audit events are kept.
+	AuditLogRetention time.Duration `mapstructure:"audit_log_retention"`
+	// IdempotencyWindow is how long responses to requests sent with an
+	// Idempotency-Key are replayed.
+	IdempotencyWindow time.Duration `mapstructure:"idempotency_window"`
+	// IdempotencyStaleAfter is how long an unfinished request holds its
+	// Idempotency-Key before a retry may take it over. Requests holding a
+	// key are cut off at the server's write timeout, which must be shorter,
+	// or a retry could run a request that is still in flight.
+	IdempotencyStaleAfter time.Duration `mapstructure:"idempotency_stale_after"`
+	// IdempotencyMaxBodySize limits the body of a request sent with an
+	// Idempotency-Key, which is buffered to fingerprint it.
+	IdempotencyMaxBodySize int64 `mapstructure:"idempotency_max_body_size"`
+	// MessageEditWindow is how long after sending a message can be edited.
+	MessageEditWindow time.Duration `mapstructure:"message_edit_window"`
+	// MessageDeleteWindow is how long after sending a sender can delete a
+	// message for everyone. Admins are not limited by it.
+	MessageDeleteWindow time.Duration `mapstructure:"message_delete_window"`
 }
 
 func Load() (*Config, error) {
@@ -105,6 +123,13 @@ func Load() (*Config, error) {
 	viper.SetDefault("auth.access_token_ttl", 15*time.Minute)
 	viper.SetDefault("auth.refresh_token_ttl", 7*24*time.Hour)
 	viper.SetDefault("limits.max_group_members", 512)
+	viper.SetDefault("limits.max_message_size", 64<<10)
+	viper.SetDefault("limits.audit_log_retention", 180*24*time.Hour)
+	viper.SetDefault("limits.idempotency_window", 24*time.Hour)
+	viper.SetDefault("limits.idempotency_stale_after", time.Minute)
+	viper.SetDefault("limits.idempotency_max_body_size", 4<<20)
+	viper.SetDefault("limits.message_edit_window", 48*time.Hour)
+	viper.SetDefault("limits.message_delete_window", 48*time.Hour)
 	viper.SetDefault("security.bcrypt_cost", 12)
 
 	viper.AutomaticEnv()
@@ -119,6 +144,13 @@ func Load() (*Config, error) {
 	if err := viper.Unmarshal(&cfg); err != nil {
 		return nil, err
 	}
+	if cfg.Server.WriteTimeout <= 0 {
+		return nil, fmt.Errorf("server.write_timeout must be positive")
+	}
+	if cfg.Limits.IdempotencyStaleAfter <= cfg.Server.WriteTimeout {
+		return nil, fmt.Errorf("limits.idempotency_stale_after (%s) must be longer than server.write_timeout (%s)",
+			cfg.Limits.IdempotencyStaleAfter, cfg.Server.WriteTimeout)
+	}
 
 	return &cfg, nil
 }
@@ -0,0 +1,153 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: audit.sql
+
+package db
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createAuditEvent = `-- name: CreateAuditEvent :one
+INSERT INTO chat_audit_events (id, chat_id, actor_id, action, target_user_id, target_id, before, after)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, chat_id, actor_id, action, target_user_id, target_id, before, after, created_at
+`
+
+type CreateAuditEventParams struct {
+	ID           string          `json:"id"`
+	ChatID       string          `json:"chat_id"`
+	ActorID      pgtype.Text     `json:"actor_id"`
+	Action       string          `json:"action"`
+	TargetUserID pgtype.Text     `json:"target_user_id"`
+	TargetID     pgtype.Text     `json:"target_id"`
+	Before       json.RawMessage `json:"before"`
+	After        json.RawMessage `json:"after"`
+}
+
+func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (ChatAuditEvent, error) {
+	row := q.db.QueryRow(ctx, createAuditEvent,
+		arg.ID,
+		arg.ChatID,
+		arg.ActorID,
+		arg.Action,
+		arg.TargetUserID,
+		arg.TargetID,
+		arg.Before,
+		arg.After,
+	)
+	var i ChatAuditEvent
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.ActorID,
+		&i.Action,
+		&i.TargetUserID,
+		&i.TargetID,
+		&i.Before,
+		&i.After,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listAuditEvents = `-- name: ListAuditEvents :many
+SELECT e.id, e.chat_id, e.actor_id, e.action, e.target_user_id, e.target_id, e.before, e.after, e.created_at, actor.username AS actor_username, target.username AS target_username
+FROM chat_audit_events e
+LEFT JOIN users actor ON actor.id = e.actor_id
+LEFT JOIN users target ON target.id = e.target_user_id
+WHERE e.chat_id = $1
+  AND e.created_at > $2
+  AND (cardinality($3::text[]) = 0 OR e.action = ANY($3::text[]))
+  AND ($4::text IS NULL OR e.actor_id = $4::text)
+  AND ($5::text IS NULL OR e.target_user_id = $5::text)
+  AND ($6::timestamptz IS NULL
+       OR (e.created_at, e.id) < ($6::timestamptz, $7::text))
+ORDER BY e.created_at DESC, e.id DESC
+LIMIT $8
+`
+
+type ListAuditEventsParams struct {
+	ChatID          string             `json:"chat_id"`
+	RetainedSince   pgtype.Timestamptz `json:"retained_since"`
+	Actions         []string           `json:"actions"`
+	ActorID         pgtype.Text        `json:"actor_id"`
+	TargetUserID    pgtype.Text        `json:"target_user_id"`
+	CursorCreatedAt pgtype.Timestamptz `json:"cursor_created_at"`
+	CursorID        pgtype.Text        `json:"cursor_id"`
+	PageSize        int32              `json:"page_size"`
+}
+
+type ListAuditEventsRow struct {
+	ID             string             `json:"id"`
+	ChatID         string             `json:"chat_id"`
+	ActorID        pgtype.Text        `json:"actor_id"`
+	Action         string             `json:"action"`
+	TargetUserID   pgtype.Text        `json:"target_user_id"`
+	TargetID       pgtype.Text        `json:"target_id"`
+	Before         json.RawMessage    `json:"before"`
+	After          json.RawMessage    `json:"after"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+	ActorUsername  pgtype.Text        `json:"actor_username"`
+	TargetUsername pgtype.Text        `json:"target_username"`
+}
+
+// Lists a chat's audit events newer than retained_since, newest first.
+// actions, actor_id and target_user_id narrow the list when set.
+func (q *Queries) ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]ListAuditEventsRow, error) {
+	rows, err := q.db.Query(ctx, listAuditEvents,
+		arg.ChatID,
+		arg.RetainedSince,
+		arg.Actions,
+		arg.ActorID,
+		arg.TargetUserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListAuditEventsRow{}
+	for rows.Next() {
+		var i ListAuditEventsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChatID,
+			&i.ActorID,
+			&i.Action,
+			&i.TargetUserID,
+			&i.TargetID,
+			&i.Before,
+			&i.After,
+			&i.CreatedAt,
+			&i.ActorUsername,
+			&i.TargetUsername,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeAuditEvents = `-- name: PurgeAuditEvents :execrows
+DELETE FROM chat_audit_events
+WHERE created_at <= $1
+`
+
+func (q *Queries) PurgeAuditEvents(ctx context.Context, olderThan pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeAuditEvents, olderThan)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
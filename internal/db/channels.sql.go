@@ -0,0 +1,201 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: channels.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createChannelChat = `-- name: CreateChannelChat :one
+WITH chat AS (
+    INSERT INTO chats (id, type, title, photo_url, username, created_by, member_count, member_permissions, admin_permissions)
+    VALUES ($1, 'channel', $2, $3, $4, $5::text, 1, $6, $7)
+    RETURNING id, type, direct_key, created_by, last_activity_at, created_at, updated_at, title, photo_url, member_count, member_permissions, admin_permissions, username, is_forum, slow_mode_seconds, last_seq, allowed_reactions, message_ttl_seconds, update_seq
+), owner AS (
+    INSERT INTO chat_members (chat_id, user_id, role)
+    SELECT chat.id, $5::text, 'owner' FROM chat
+)
+SELECT id, type, direct_key, created_by, last_activity_at, created_at, updated_at, title, photo_url, member_count, member_permissions, admin_permissions, username, is_forum, slow_mode_seconds, last_seq, allowed_reactions, message_ttl_seconds, update_seq FROM chat
+`
+
+type CreateChannelChatParams struct {
+	ID                string      `json:"id"`
+	Title             pgtype.Text `json:"title"`
+	PhotoUrl          pgtype.Text `json:"photo_url"`
+	Username          pgtype.Text `json:"username"`
+	OwnerID           string      `json:"owner_id"`
+	MemberPermissions int32       `json:"member_permissions"`
+	AdminPermissions  int32       `json:"admin_permissions"`
+}
+
+type CreateChannelChatRow struct {
+	ID                string             `json:"id"`
+	Type              ChatType           `json:"type"`
+	DirectKey         pgtype.Text        `json:"direct_key"`
+	CreatedBy         pgtype.Text        `json:"created_by"`
+	LastActivityAt    pgtype.Timestamptz `json:"last_activity_at"`
+	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt         pgtype.Timestamptz `json:"updated_at"`
+	Title             pgtype.Text        `json:"title"`
+	PhotoUrl          pgtype.Text        `json:"photo_url"`
+	MemberCount       int32              `json:"member_count"`
+	MemberPermissions int32              `json:"member_permissions"`
+	AdminPermissions  int32              `json:"admin_permissions"`
+	Username          pgtype.Text        `json:"username"`
+	IsForum           bool               `json:"is_forum"`
+	SlowModeSeconds   int32              `json:"slow_mode_seconds"`
+	LastSeq           int64              `json:"last_seq"`
+	AllowedReactions  []string           `json:"allowed_reactions"`
+	MessageTtlSeconds pgtype.Int4        `json:"message_ttl_seconds"`
+	UpdateSeq         int64              `json:"update_seq"`
+}
+
+func (q *Queries) CreateChannelChat(ctx context.Context, arg CreateChannelChatParams) (CreateChannelChatRow, error) {
+	row := q.db.QueryRow(ctx, createChannelChat,
+		arg.ID,
+		arg.Title,
+		arg.PhotoUrl,
+		arg.Username,
+		arg.OwnerID,
+		arg.MemberPermissions,
+		arg.AdminPermissions,
+	)
+	var i CreateChannelChatRow
+	err := row.Scan(
+		&i.ID,
+		&i.Type,
+		&i.DirectKey,
+		&i.CreatedBy,
+		&i.LastActivityAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.PhotoUrl,
+		&i.MemberCount,
+		&i.MemberPermissions,
+		&i.AdminPermissions,
+		&i.Username,
+		&i.IsForum,
+		&i.SlowModeSeconds,
+		&i.LastSeq,
+		&i.AllowedReactions,
+		&i.MessageTtlSeconds,
+		&i.UpdateSeq,
+	)
+	return i, err
+}
+
+const getChannelByUsername = `-- name: GetChannelByUsername :one
+SELECT id, type, direct_key, created_by, last_activity_at, created_at, updated_at, title, photo_url, member_count, member_permissions, admin_permissions, username, is_forum, slow_mode_seconds, last_seq, allowed_reactions, message_ttl_seconds, update_seq FROM chats
+WHERE type = 'channel' AND lower(username) = lower($1::text)
+`
+
+func (q *Queries) GetChannelByUsername(ctx context.Context, username string) (Chat, error) {
+	row := q.db.QueryRow(ctx, getChannelByUsername, username)
+	var i Chat
+	err := row.Scan(
+		&i.ID,
+		&i.Type,
+		&i.DirectKey,
+		&i.CreatedBy,
+		&i.LastActivityAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.PhotoUrl,
+		&i.MemberCount,
+		&i.MemberPermissions,
+		&i.AdminPermissions,
+		&i.Username,
+		&i.IsForum,
+		&i.SlowModeSeconds,
+		&i.LastSeq,
+		&i.AllowedReactions,
+		&i.MessageTtlSeconds,
+		&i.UpdateSeq,
+	)
+	return i, err
+}
+
+const recordPostViews = `-- name: RecordPostViews :many
+WITH inserted AS (
+    INSERT INTO channel_post_views (chat_id, message_id, user_id)
+    SELECT $1::text, m.id, $2::text
+    FROM unnest($3::text[]) AS message_id
+    JOIN messages m ON m.id = message_id AND m.chat_id = $1::text AND m.deleted_at IS NULL
+    ON CONFLICT DO NOTHING
+    RETURNING message_id
+), bumped AS (
+    INSERT INTO channel_post_stats (chat_id, message_id, view_count)
+    SELECT $1::text, message_id, 1 FROM inserted
+    ON CONFLICT (chat_id, message_id) DO UPDATE
+    SET view_count = channel_post_stats.view_count + 1
+    RETURNING message_id, view_count
+)
+SELECT bumped.message_id, bumped.view_count FROM bumped
+UNION ALL
+SELECT s.message_id, s.view_count
+FROM channel_post_stats s
+WHERE s.chat_id = $1::text
+  AND s.message_id = ANY($3::text[])
+  AND s.message_id NOT IN (SELECT message_id FROM bumped)
+`
+
+type RecordPostViewsParams struct {
+	ChatID     string   `json:"chat_id"`
+	UserID     string   `json:"user_id"`
+	MessageIds []string `json:"message_ids"`
+}
+
+type RecordPostViewsRow struct {
+	MessageID string `json:"message_id"`
+	ViewCount int32  `json:"view_count"`
+}
+
+// Counts a view of each post once per user and returns the current counters.
+// IDs that are not live posts of the channel are ignored.
+// The final SELECT sees the counters as of the statement start, so posts
+// bumped by this call are taken from the bumped CTE instead.
+func (q *Queries) RecordPostViews(ctx context.Context, arg RecordPostViewsParams) ([]RecordPostViewsRow, error) {
+	rows, err := q.db.Query(ctx, recordPostViews, arg.ChatID, arg.UserID, arg.MessageIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []RecordPostViewsRow{}
+	for rows.Next() {
+		var i RecordPostViewsRow
+		if err := rows.Scan(&i.MessageID, &i.ViewCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateChatUsername = `-- name: UpdateChatUsername :execrows
+UPDATE chats
+SET username = $1, updated_at = NOW()
+WHERE id = $2
+`
+
+type UpdateChatUsernameParams struct {
+	Username pgtype.Text `json:"username"`
+	ID       string      `json:"id"`
+}
+
+func (q *Queries) UpdateChatUsername(ctx context.Context, arg UpdateChatUsernameParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateChatUsername, arg.Username, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
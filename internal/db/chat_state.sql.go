@@ -0,0 +1,404 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: chat_state.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createChatFolder = `-- name: CreateChatFolder :one
+INSERT INTO chat_folders (id, user_id, title, position, include_rules, exclude_rules, included_chat_ids, excluded_chat_ids)
+SELECT $1, $2::text, $3, COALESCE(MAX(position), 0) + 1,
+       $4::text[], $5::text[], $6::text[], $7::text[]
+FROM chat_folders
+WHERE user_id = $2::text
+HAVING count(*) < $8::int
+RETURNING id, user_id, title, position, include_rules, exclude_rules, included_chat_ids, excluded_chat_ids, created_at, updated_at
+`
+
+type CreateChatFolderParams struct {
+	ID              string   `json:"id"`
+	UserID          string   `json:"user_id"`
+	Title           string   `json:"title"`
+	IncludeRules    []string `json:"include_rules"`
+	ExcludeRules    []string `json:"exclude_rules"`
+	IncludedChatIds []string `json:"included_chat_ids"`
+	ExcludedChatIds []string `json:"excluded_chat_ids"`
+	MaxFolders      int32    `json:"max_folders"`
+}
+
+// Appends the folder after the user's existing ones. Returns no row when
+// the user already has max_folders folders.
+func (q *Queries) CreateChatFolder(ctx context.Context, arg CreateChatFolderParams) (ChatFolder, error) {
+	row := q.db.QueryRow(ctx, createChatFolder,
+		arg.ID,
+		arg.UserID,
+		arg.Title,
+		arg.IncludeRules,
+		arg.ExcludeRules,
+		arg.IncludedChatIds,
+		arg.ExcludedChatIds,
+		arg.MaxFolders,
+	)
+	var i ChatFolder
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.Position,
+		&i.IncludeRules,
+		&i.ExcludeRules,
+		&i.IncludedChatIds,
+		&i.ExcludedChatIds,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteChatFolder = `-- name: DeleteChatFolder :execrows
+DELETE FROM chat_folders
+WHERE id = $1 AND user_id = $2
+`
+
+type DeleteChatFolderParams struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) DeleteChatFolder(ctx context.Context, arg DeleteChatFolderParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteChatFolder, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getChatFolder = `-- name: GetChatFolder :one
+SELECT id, user_id, title, position, include_rules, exclude_rules, included_chat_ids, excluded_chat_ids, created_at, updated_at FROM chat_folders
+WHERE id = $1 AND user_id = $2
+`
+
+type GetChatFolderParams struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) GetChatFolder(ctx context.Context, arg GetChatFolderParams) (ChatFolder, error) {
+	row := q.db.QueryRow(ctx, getChatFolder, arg.ID, arg.UserID)
+	var i ChatFolder
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.Position,
+		&i.IncludeRules,
+		&i.ExcludeRules,
+		&i.IncludedChatIds,
+		&i.ExcludedChatIds,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getChatState = `-- name: GetChatState :one
+SELECT user_id, chat_id, pinned_rank, archived, muted_until, marked_unread, updated_at, cleared_seq, read_seq, mention_notifications FROM chat_user_states
+WHERE user_id = $1 AND chat_id = $2
+`
+
+type GetChatStateParams struct {
+	UserID string `json:"user_id"`
+	ChatID string `json:"chat_id"`
+}
+
+func (q *Queries) GetChatState(ctx context.Context, arg GetChatStateParams) (ChatUserState, error) {
+	row := q.db.QueryRow(ctx, getChatState, arg.UserID, arg.ChatID)
+	var i ChatUserState
+	err := row.Scan(
+		&i.UserID,
+		&i.ChatID,
+		&i.PinnedRank,
+		&i.Archived,
+		&i.MutedUntil,
+		&i.MarkedUnread,
+		&i.UpdatedAt,
+		&i.ClearedSeq,
+		&i.ReadSeq,
+		&i.MentionNotifications,
+	)
+	return i, err
+}
+
+const listChatFolders = `-- name: ListChatFolders :many
+SELECT id, user_id, title, position, include_rules, exclude_rules, included_chat_ids, excluded_chat_ids, created_at, updated_at FROM chat_folders
+WHERE user_id = $1
+ORDER BY position, id
+`
+
+func (q *Queries) ListChatFolders(ctx context.Context, userID string) ([]ChatFolder, error) {
+	rows, err := q.db.Query(ctx, listChatFolders, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ChatFolder{}
+	for rows.Next() {
+		var i ChatFolder
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.Position,
+			&i.IncludeRules,
+			&i.ExcludeRules,
+			&i.IncludedChatIds,
+			&i.ExcludedChatIds,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPinnedChatIDs = `-- name: ListPinnedChatIDs :many
+SELECT chat_id FROM chat_user_states
+WHERE user_id = $1 AND pinned_rank IS NOT NULL
+ORDER BY pinned_rank
+`
+
+func (q *Queries) ListPinnedChatIDs(ctx context.Context, userID string) ([]string, error) {
+	rows, err := q.db.Query(ctx, listPinnedChatIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var chat_id string
+		if err := rows.Scan(&chat_id); err != nil {
+			return nil, err
+		}
+		items = append(items, chat_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pinChat = `-- name: PinChat :one
+INSERT INTO chat_user_states (user_id, chat_id, pinned_rank)
+SELECT $1::text, $2::text, COALESCE(MAX(pinned_rank), 0) + 1
+FROM chat_user_states
+WHERE user_id = $1::text
+HAVING count(pinned_rank) < $3::int
+ON CONFLICT (user_id, chat_id) DO UPDATE
+SET pinned_rank = EXCLUDED.pinned_rank, updated_at = NOW()
+RETURNING user_id, chat_id, pinned_rank, archived, muted_until, marked_unread, updated_at, cleared_seq, read_seq, mention_notifications
+`
+
+type PinChatParams struct {
+	UserID    string `json:"user_id"`
+	ChatID    string `json:"chat_id"`
+	MaxPinned int32  `json:"max_pinned"`
+}
+
+// Appends the chat to the end of the user's pinned list. Returns no row
+// when the list is already full.
+func (q *Queries) PinChat(ctx context.Context, arg PinChatParams) (ChatUserState, error) {
+	row := q.db.QueryRow(ctx, pinChat, arg.UserID, arg.ChatID, arg.MaxPinned)
+	var i ChatUserState
+	err := row.Scan(
+		&i.UserID,
+		&i.ChatID,
+		&i.PinnedRank,
+		&i.Archived,
+		&i.MutedUntil,
+		&i.MarkedUnread,
+		&i.UpdatedAt,
+		&i.ClearedSeq,
+		&i.ReadSeq,
+		&i.MentionNotifications,
+	)
+	return i, err
+}
+
+const reorderChatFolders = `-- name: ReorderChatFolders :exec
+UPDATE chat_folders f
+SET position = o.position::int, updated_at = NOW()
+FROM unnest($2::text[]) WITH ORDINALITY AS o(id, position)
+WHERE f.user_id = $1 AND f.id = o.id
+`
+
+type ReorderChatFoldersParams struct {
+	UserID    string   `json:"user_id"`
+	FolderIds []string `json:"folder_ids"`
+}
+
+func (q *Queries) ReorderChatFolders(ctx context.Context, arg ReorderChatFoldersParams) error {
+	_, err := q.db.Exec(ctx, reorderChatFolders, arg.UserID, arg.FolderIds)
+	return err
+}
+
+const reorderPinnedChats = `-- name: ReorderPinnedChats :exec
+UPDATE chat_user_states s
+SET pinned_rank = o.rank::int, updated_at = NOW()
+FROM unnest($2::text[]) WITH ORDINALITY AS o(chat_id, rank)
+WHERE s.user_id = $1 AND s.chat_id = o.chat_id AND s.pinned_rank IS NOT NULL
+`
+
+type ReorderPinnedChatsParams struct {
+	UserID  string   `json:"user_id"`
+	ChatIds []string `json:"chat_ids"`
+}
+
+func (q *Queries) ReorderPinnedChats(ctx context.Context, arg ReorderPinnedChatsParams) error {
+	_, err := q.db.Exec(ctx, reorderPinnedChats, arg.UserID, arg.ChatIds)
+	return err
+}
+
+const unpinChat = `-- name: UnpinChat :one
+UPDATE chat_user_states
+SET pinned_rank = NULL, updated_at = NOW()
+WHERE user_id = $1 AND chat_id = $2
+RETURNING user_id, chat_id, pinned_rank, archived, muted_until, marked_unread, updated_at, cleared_seq, read_seq, mention_notifications
+`
+
+type UnpinChatParams struct {
+	UserID string `json:"user_id"`
+	ChatID string `json:"chat_id"`
+}
+
+func (q *Queries) UnpinChat(ctx context.Context, arg UnpinChatParams) (ChatUserState, error) {
+	row := q.db.QueryRow(ctx, unpinChat, arg.UserID, arg.ChatID)
+	var i ChatUserState
+	err := row.Scan(
+		&i.UserID,
+		&i.ChatID,
+		&i.PinnedRank,
+		&i.Archived,
+		&i.MutedUntil,
+		&i.MarkedUnread,
+		&i.UpdatedAt,
+		&i.ClearedSeq,
+		&i.ReadSeq,
+		&i.MentionNotifications,
+	)
+	return i, err
+}
+
+const updateChatFolder = `-- name: UpdateChatFolder :one
+UPDATE chat_folders
+SET title = $1,
+    include_rules = $2::text[],
+    exclude_rules = $3::text[],
+    included_chat_ids = $4::text[],
+    excluded_chat_ids = $5::text[],
+    updated_at = NOW()
+WHERE id = $6 AND user_id = $7
+RETURNING id, user_id, title, position, include_rules, exclude_rules, included_chat_ids, excluded_chat_ids, created_at, updated_at
+`
+
+type UpdateChatFolderParams struct {
+	Title           string   `json:"title"`
+	IncludeRules    []string `json:"include_rules"`
+	ExcludeRules    []string `json:"exclude_rules"`
+	IncludedChatIds []string `json:"included_chat_ids"`
+	ExcludedChatIds []string `json:"excluded_chat_ids"`
+	ID              string   `json:"id"`
+	UserID          string   `json:"user_id"`
+}
+
+func (q *Queries) UpdateChatFolder(ctx context.Context, arg UpdateChatFolderParams) (ChatFolder, error) {
+	row := q.db.QueryRow(ctx, updateChatFolder,
+		arg.Title,
+		arg.IncludeRules,
+		arg.ExcludeRules,
+		arg.IncludedChatIds,
+		arg.ExcludedChatIds,
+		arg.ID,
+		arg.UserID,
+	)
+	var i ChatFolder
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.Position,
+		&i.IncludeRules,
+		&i.ExcludeRules,
+		&i.IncludedChatIds,
+		&i.ExcludedChatIds,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const upsertChatState = `-- name: UpsertChatState :one
+INSERT INTO chat_user_states (user_id, chat_id, archived, marked_unread, muted_until, mention_notifications)
+VALUES (
+    $1, $2,
+    COALESCE($3::bool, false),
+    COALESCE($4::bool, false),
+    CASE WHEN $5::bool THEN $6::timestamptz END,
+    COALESCE($7::bool, true)
+)
+ON CONFLICT (user_id, chat_id) DO UPDATE
+SET archived      = COALESCE($3::bool, chat_user_states.archived),
+    marked_unread = COALESCE($4::bool, chat_user_states.marked_unread),
+    muted_until   = CASE WHEN $5::bool THEN $6::timestamptz ELSE chat_user_states.muted_until END,
+    mention_notifications = COALESCE($7::bool, chat_user_states.mention_notifications),
+    updated_at    = NOW()
+RETURNING user_id, chat_id, pinned_rank, archived, muted_until, marked_unread, updated_at, cleared_seq, read_seq, mention_notifications
+`
+
+type UpsertChatStateParams struct {
+	UserID               string             `json:"user_id"`
+	ChatID               string             `json:"chat_id"`
+	Archived             pgtype.Bool        `json:"archived"`
+	MarkedUnread         pgtype.Bool        `json:"marked_unread"`
+	SetMute              bool               `json:"set_mute"`
+	MutedUntil           pgtype.Timestamptz `json:"muted_until"`
+	MentionNotifications pgtype.Bool        `json:"mention_notifications"`
+}
+
+// Changes the provided fields only; muted_until is replaced when set_mute
+// is true, with NULL meaning unmuted.
+func (q *Queries) UpsertChatState(ctx context.Context, arg UpsertChatStateParams) (ChatUserState, error) {
+	row := q.db.QueryRow(ctx, upsertChatState,
+		arg.UserID,
+		arg.ChatID,
+		arg.Archived,
+		arg.MarkedUnread,
+		arg.SetMute,
+		arg.MutedUntil,
+		arg.MentionNotifications,
+	)
+	var i ChatUserState
+	err := row.Scan(
+		&i.UserID,
+		&i.ChatID,
+		&i.PinnedRank,
+		&i.Archived,
+		&i.MutedUntil,
+		&i.MarkedUnread,
+		&i.UpdatedAt,
+		&i.ClearedSeq,
+		&i.ReadSeq,
+		&i.MentionNotifications,
+	)
+	return i, err
+}
@@ -0,0 +1,743 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: chats.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const addChatMember = `-- name: AddChatMember :execrows
+WITH slot AS (
+    UPDATE chats
+    SET member_count = member_count + 1, updated_at = NOW()
+    WHERE id = $4 AND member_count < $5::int
+    RETURNING id
+)
+INSERT INTO chat_members (chat_id, user_id, role, invited_by)
+SELECT slot.id, $1, $2, $3 FROM slot
+`
+
+type AddChatMemberParams struct {
+	UserID     string         `json:"user_id"`
+	Role       ChatMemberRole `json:"role"`
+	InvitedBy  pgtype.Text    `json:"invited_by"`
+	ChatID     string         `json:"chat_id"`
+	MaxMembers int32          `json:"max_members"`
+}
+
+// The member count is reserved with a conditional UPDATE so concurrent adds
+// cannot exceed max_members; a duplicate member aborts the whole statement.
+func (q *Queries) AddChatMember(ctx context.Context, arg AddChatMemberParams) (int64, error) {
+	result, err := q.db.Exec(ctx, addChatMember,
+		arg.UserID,
+		arg.Role,
+		arg.InvitedBy,
+		arg.ChatID,
+		arg.MaxMembers,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const createChatWithMembers = `-- name: CreateChatWithMembers :one
+WITH chat AS (
+    INSERT INTO chats (id, type, direct_key, created_by, member_count, member_permissions, admin_permissions)
+    VALUES ($1, $2, $3, $4, cardinality($5::text[]), $6, $7)
+    ON CONFLICT (direct_key) DO NOTHING
+    RETURNING id, type, direct_key, created_by, last_activity_at, created_at, updated_at, title, photo_url, member_count, member_permissions, admin_permissions, username, is_forum, slow_mode_seconds, last_seq, allowed_reactions, message_ttl_seconds, update_seq
+), members AS (
+    INSERT INTO chat_members (chat_id, user_id)
+    SELECT chat.id, member_id
+    FROM chat, unnest($5::text[]) AS member_id
+)
+SELECT id, type, direct_key, created_by, last_activity_at, created_at, updated_at, title, photo_url, member_count, member_permissions, admin_permissions, username, is_forum, slow_mode_seconds, last_seq, allowed_reactions, message_ttl_seconds, update_seq FROM chat
+`
+
+type CreateChatWithMembersParams struct {
+	ID                string      `json:"id"`
+	Type              ChatType    `json:"type"`
+	DirectKey         pgtype.Text `json:"direct_key"`
+	CreatedBy         pgtype.Text `json:"created_by"`
+	MemberIds         []string    `json:"member_ids"`
+	MemberPermissions int32       `json:"member_permissions"`
+	AdminPermissions  int32       `json:"admin_permissions"`
+}
+
+type CreateChatWithMembersRow struct {
+	ID                string             `json:"id"`
+	Type              ChatType           `json:"type"`
+	DirectKey         pgtype.Text        `json:"direct_key"`
+	CreatedBy         pgtype.Text        `json:"created_by"`
+	LastActivityAt    pgtype.Timestamptz `json:"last_activity_at"`
+	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt         pgtype.Timestamptz `json:"updated_at"`
+	Title             pgtype.Text        `json:"title"`
+	PhotoUrl          pgtype.Text        `json:"photo_url"`
+	MemberCount       int32              `json:"member_count"`
+	MemberPermissions int32              `json:"member_permissions"`
+	AdminPermissions  int32              `json:"admin_permissions"`
+	Username          pgtype.Text        `json:"username"`
+	IsForum           bool               `json:"is_forum"`
+	SlowModeSeconds   int32              `json:"slow_mode_seconds"`
+	LastSeq           int64              `json:"last_seq"`
+	AllowedReactions  []string           `json:"allowed_reactions"`
+	MessageTtlSeconds pgtype.Int4        `json:"message_ttl_seconds"`
+	UpdateSeq         int64              `json:"update_seq"`
+}
+
+func (q *Queries) CreateChatWithMembers(ctx context.Context, arg CreateChatWithMembersParams) (CreateChatWithMembersRow, error) {
+	row := q.db.QueryRow(ctx, createChatWithMembers,
+		arg.ID,
+		arg.Type,
+		arg.DirectKey,
+		arg.CreatedBy,
+		arg.MemberIds,
+		arg.MemberPermissions,
+		arg.AdminPermissions,
+	)
+	var i CreateChatWithMembersRow
+	err := row.Scan(
+		&i.ID,
+		&i.Type,
+		&i.DirectKey,
+		&i.CreatedBy,
+		&i.LastActivityAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.PhotoUrl,
+		&i.MemberCount,
+		&i.MemberPermissions,
+		&i.AdminPermissions,
+		&i.Username,
+		&i.IsForum,
+		&i.SlowModeSeconds,
+		&i.LastSeq,
+		&i.AllowedReactions,
+		&i.MessageTtlSeconds,
+		&i.UpdateSeq,
+	)
+	return i, err
+}
+
+const createGroupChat = `-- name: CreateGroupChat :one
+WITH chat AS (
+    INSERT INTO chats (id, type, title, photo_url, created_by, member_count, member_permissions, admin_permissions)
+    VALUES ($1, 'group', $2, $3, $4::text, cardinality($5::text[]) + 1, $6, $7)
+    RETURNING id, type, direct_key, created_by, last_activity_at, created_at, updated_at, title, photo_url, member_count, member_permissions, admin_permissions, username, is_forum, slow_mode_seconds, last_seq, allowed_reactions, message_ttl_seconds, update_seq
+), owner AS (
+    INSERT INTO chat_members (chat_id, user_id, role)
+    SELECT chat.id, $4::text, 'owner' FROM chat
+), members AS (
+    INSERT INTO chat_members (chat_id, user_id, role, invited_by)
+    SELECT chat.id, member_id, 'member', $4::text
+    FROM chat, unnest($5::text[]) AS member_id
+)
+SELECT id, type, direct_key, created_by, last_activity_at, created_at, updated_at, title, photo_url, member_count, member_permissions, admin_permissions, username, is_forum, slow_mode_seconds, last_seq, allowed_reactions, message_ttl_seconds, update_seq FROM chat
+`
+
+type CreateGroupChatParams struct {
+	ID                string      `json:"id"`
+	Title             pgtype.Text `json:"title"`
+	PhotoUrl          pgtype.Text `json:"photo_url"`
+	OwnerID           string      `json:"owner_id"`
+	MemberIds         []string    `json:"member_ids"`
+	MemberPermissions int32       `json:"member_permissions"`
+	AdminPermissions  int32       `json:"admin_permissions"`
+}
+
+type CreateGroupChatRow struct {
+	ID                string             `json:"id"`
+	Type              ChatType           `json:"type"`
+	DirectKey         pgtype.Text        `json:"direct_key"`
+	CreatedBy         pgtype.Text        `json:"created_by"`
+	LastActivityAt    pgtype.Timestamptz `json:"last_activity_at"`
+	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt         pgtype.Timestamptz `json:"updated_at"`
+	Title             pgtype.Text        `json:"title"`
+	PhotoUrl          pgtype.Text        `json:"photo_url"`
+	MemberCount       int32              `json:"member_count"`
+	MemberPermissions int32              `json:"member_permissions"`
+	AdminPermissions  int32              `json:"admin_permissions"`
+	Username          pgtype.Text        `json:"username"`
+	IsForum           bool               `json:"is_forum"`
+	SlowModeSeconds   int32              `json:"slow_mode_seconds"`
+	LastSeq           int64              `json:"last_seq"`
+	AllowedReactions  []string           `json:"allowed_reactions"`
+	MessageTtlSeconds pgtype.Int4        `json:"message_ttl_seconds"`
+	UpdateSeq         int64              `json:"update_seq"`
+}
+
+func (q *Queries) CreateGroupChat(ctx context.Context, arg CreateGroupChatParams) (CreateGroupChatRow, error) {
+	row := q.db.QueryRow(ctx, createGroupChat,
+		arg.ID,
+		arg.Title,
+		arg.PhotoUrl,
+		arg.OwnerID,
+		arg.MemberIds,
+		arg.MemberPermissions,
+		arg.AdminPermissions,
+	)
+	var i CreateGroupChatRow
+	err := row.Scan(
+		&i.ID,
+		&i.Type,
+		&i.DirectKey,
+		&i.CreatedBy,
+		&i.LastActivityAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.PhotoUrl,
+		&i.MemberCount,
+		&i.MemberPermissions,
+		&i.AdminPermissions,
+		&i.Username,
+		&i.IsForum,
+		&i.SlowModeSeconds,
+		&i.LastSeq,
+		&i.AllowedReactions,
+		&i.MessageTtlSeconds,
+		&i.UpdateSeq,
+	)
+	return i, err
+}
+
+const deleteChat = `-- name: DeleteChat :exec
+DELETE FROM chats
+WHERE id = $1
+`
+
+func (q *Queries) DeleteChat(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, deleteChat, id)
+	return err
+}
+
+const getChat = `-- name: GetChat :one
+SELECT id, type, direct_key, created_by, last_activity_at, created_at, updated_at, title, photo_url, member_count, member_permissions, admin_permissions, username, is_forum, slow_mode_seconds, last_seq, allowed_reactions, message_ttl_seconds, update_seq FROM chats
+WHERE id = $1
+`
+
+func (q *Queries) GetChat(ctx context.Context, id string) (Chat, error) {
+	row := q.db.QueryRow(ctx, getChat, id)
+	var i Chat
+	err := row.Scan(
+		&i.ID,
+		&i.Type,
+		&i.DirectKey,
+		&i.CreatedBy,
+		&i.LastActivityAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.PhotoUrl,
+		&i.MemberCount,
+		&i.MemberPermissions,
+		&i.AdminPermissions,
+		&i.Username,
+		&i.IsForum,
+		&i.SlowModeSeconds,
+		&i.LastSeq,
+		&i.AllowedReactions,
+		&i.MessageTtlSeconds,
+		&i.UpdateSeq,
+	)
+	return i, err
+}
+
+const getChatByDirectKey = `-- name: GetChatByDirectKey :one
+SELECT id, type, direct_key, created_by, last_activity_at, created_at, updated_at, title, photo_url, member_count, member_permissions, admin_permissions, username, is_forum, slow_mode_seconds, last_seq, allowed_reactions, message_ttl_seconds, update_seq FROM chats
+WHERE direct_key = $1
+`
+
+func (q *Queries) GetChatByDirectKey(ctx context.Context, directKey pgtype.Text) (Chat, error) {
+	row := q.db.QueryRow(ctx, getChatByDirectKey, directKey)
+	var i Chat
+	err := row.Scan(
+		&i.ID,
+		&i.Type,
+		&i.DirectKey,
+		&i.CreatedBy,
+		&i.LastActivityAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.PhotoUrl,
+		&i.MemberCount,
+		&i.MemberPermissions,
+		&i.AdminPermissions,
+		&i.Username,
+		&i.IsForum,
+		&i.SlowModeSeconds,
+		&i.LastSeq,
+		&i.AllowedReactions,
+		&i.MessageTtlSeconds,
+		&i.UpdateSeq,
+	)
+	return i, err
+}
+
+const getChatMember = `-- name: GetChatMember :one
+SELECT chat_id, user_id, joined_at, role, invited_by, permissions, custom_title, restricted_until, last_sent_at FROM chat_members
+WHERE chat_id = $1 AND user_id = $2
+`
+
+type GetChatMemberParams struct {
+	ChatID string `json:"chat_id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) GetChatMember(ctx context.Context, arg GetChatMemberParams) (ChatMember, error) {
+	row := q.db.QueryRow(ctx, getChatMember, arg.ChatID, arg.UserID)
+	var i ChatMember
+	err := row.Scan(
+		&i.ChatID,
+		&i.UserID,
+		&i.JoinedAt,
+		&i.Role,
+		&i.InvitedBy,
+		&i.Permissions,
+		&i.CustomTitle,
+		&i.RestrictedUntil,
+		&i.LastSentAt,
+	)
+	return i, err
+}
+
+const isChatMember = `-- name: IsChatMember :one
+SELECT EXISTS(
+    SELECT 1 FROM chat_members
+    WHERE chat_id = $1 AND user_id = $2
+)
+`
+
+type IsChatMemberParams struct {
+	ChatID string `json:"chat_id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) IsChatMember(ctx context.Context, arg IsChatMemberParams) (bool, error) {
+	row := q.db.QueryRow(ctx, isChatMember, arg.ChatID, arg.UserID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const listChatMemberIDs = `-- name: ListChatMemberIDs :many
+SELECT user_id FROM chat_members
+WHERE chat_id = $1
+`
+
+func (q *Queries) ListChatMemberIDs(ctx context.Context, chatID string) ([]string, error) {
+	rows, err := q.db.Query(ctx, listChatMemberIDs, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var user_id string
+		if err := rows.Scan(&user_id); err != nil {
+			return nil, err
+		}
+		items = append(items, user_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChatMembers = `-- name: ListChatMembers :many
+SELECT m.chat_id, m.user_id, m.joined_at, m.role, m.invited_by, m.permissions, m.custom_title, m.restricted_until, m.last_sent_at, u.username
+FROM chat_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.chat_id = $1
+  AND ($2::timestamptz IS NULL
+       OR (m.joined_at, m.user_id) > ($2::timestamptz, $3::text))
+ORDER BY m.joined_at, m.user_id
+LIMIT $4
+`
+
+type ListChatMembersParams struct {
+	ChatID         string             `json:"chat_id"`
+	CursorJoinedAt pgtype.Timestamptz `json:"cursor_joined_at"`
+	CursorUserID   pgtype.Text        `json:"cursor_user_id"`
+	PageSize       int32              `json:"page_size"`
+}
+
+type ListChatMembersRow struct {
+	ChatID          string             `json:"chat_id"`
+	UserID          string             `json:"user_id"`
+	JoinedAt        pgtype.Timestamptz `json:"joined_at"`
+	Role            ChatMemberRole     `json:"role"`
+	InvitedBy       pgtype.Text        `json:"invited_by"`
+	Permissions     pgtype.Int4        `json:"permissions"`
+	CustomTitle     pgtype.Text        `json:"custom_title"`
+	RestrictedUntil pgtype.Timestamptz `json:"restricted_until"`
+	LastSentAt      pgtype.Timestamptz `json:"last_sent_at"`
+	Username        string             `json:"username"`
+}
+
+func (q *Queries) ListChatMembers(ctx context.Context, arg ListChatMembersParams) ([]ListChatMembersRow, error) {
+	rows, err := q.db.Query(ctx, listChatMembers,
+		arg.ChatID,
+		arg.CursorJoinedAt,
+		arg.CursorUserID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListChatMembersRow{}
+	for rows.Next() {
+		var i ListChatMembersRow
+		if err := rows.Scan(
+			&i.ChatID,
+			&i.UserID,
+			&i.JoinedAt,
+			&i.Role,
+			&i.InvitedBy,
+			&i.Permissions,
+			&i.CustomTitle,
+			&i.RestrictedUntil,
+			&i.LastSentAt,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserChats = `-- name: ListUserChats :many
+SELECT c.id, c.type, c.direct_key, c.created_by, c.last_activity_at, c.created_at, c.updated_at, c.title, c.photo_url, c.member_count, c.member_permissions, c.admin_permissions, c.username, c.is_forum, c.slow_mode_seconds, c.last_seq, c.allowed_reactions, c.message_ttl_seconds, c.update_seq,
+       peer.user_id AS peer_id,
+       s.pinned_rank,
+       COALESCE(s.archived, false)::bool AS archived,
+       s.muted_until,
+       COALESCE(s.marked_unread, false)::bool AS marked_unread,
+       COALESCE(s.read_seq, 0)::bigint AS read_seq,
+       GREATEST(c.last_seq - COALESCE(s.read_seq, 0), 0)::bigint AS unread_count,
+       (SELECT count(*) FROM message_mentions mm
+        JOIN messages mg ON mg.id = mm.message_id AND mg.deleted_at IS NULL
+        WHERE mm.user_id = $1 AND mm.chat_id = c.id AND NOT mm.read
+          AND mm.seq > GREATEST(COALESCE(s.read_seq, 0), COALESCE(s.cleared_seq, 0))
+          AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.user_id = mm.user_id AND h.message_id = mm.message_id)
+          AND NOT EXISTS (
+              SELECT 1 FROM blocks b
+              WHERE (b.owner_id = mm.user_id AND b.target_user_id = mg.sender_id)
+                 OR (b.owner_id = mg.sender_id AND b.target_user_id = mm.user_id)
+          ))::bigint AS unread_mentions
+FROM chats c
+JOIN chat_members m ON m.chat_id = c.id AND m.user_id = $1
+LEFT JOIN chat_members peer ON peer.chat_id = c.id AND c.type = 'direct' AND peer.user_id <> $1
+LEFT JOIN chat_user_states s ON s.chat_id = c.id AND s.user_id = $1
+WHERE ($2::timestamptz IS NULL
+       OR (c.last_activity_at, c.id) < ($2::timestamptz, $3::text))
+  AND ($4::bool IS NULL OR COALESCE(s.archived, false) = $4::bool)
+  AND ($5::bool IS NULL OR (s.pinned_rank IS NOT NULL) = $5::bool)
+  AND (NOT $6::bool OR (
+        NOT c.id = ANY($7::text[])
+        AND (c.id = ANY($8::text[]) OR (
+            (
+                (c.type = 'group' AND 'groups' = ANY($9::text[]))
+                OR (c.type = 'channel' AND 'channels' = ANY($9::text[]))
+                OR (c.type = 'direct' AND (
+                    CASE WHEN EXISTS (
+                        SELECT 1 FROM contacts ct
+                        WHERE ct.owner_id = $1 AND ct.peer_id = peer.user_id AND ct.state = 'accepted'
+                    ) THEN 'contacts' ELSE 'non_contacts' END
+                ) = ANY($9::text[]))
+            )
+            AND NOT ('muted' = ANY($10::text[]) AND COALESCE(s.muted_until > NOW(), false))
+            AND NOT ('read' = ANY($10::text[]) AND NOT COALESCE(s.marked_unread, false) AND c.last_seq <= COALESCE(s.read_seq, 0))
+            AND NOT ('archived' = ANY($10::text[]) AND COALESCE(s.archived, false))
+        ))
+  ))
+ORDER BY c.last_activity_at DESC, c.id DESC
+LIMIT $11
+`
+
+type ListUserChatsParams struct {
+	UserID          string             `json:"user_id"`
+	CursorActivity  pgtype.Timestamptz `json:"cursor_activity"`
+	CursorID        pgtype.Text        `json:"cursor_id"`
+	Archived        pgtype.Bool        `json:"archived"`
+	Pinned          pgtype.Bool        `json:"pinned"`
+	UseFolder       bool               `json:"use_folder"`
+	ExcludedChatIds []string           `json:"excluded_chat_ids"`
+	IncludedChatIds []string           `json:"included_chat_ids"`
+	IncludeRules    []string           `json:"include_rules"`
+	ExcludeRules    []string           `json:"exclude_rules"`
+	PageSize        int32              `json:"page_size"`
+}
+
+type ListUserChatsRow struct {
+	ID                string             `json:"id"`
+	Type              ChatType           `json:"type"`
+	DirectKey         pgtype.Text        `json:"direct_key"`
+	CreatedBy         pgtype.Text        `json:"created_by"`
+	LastActivityAt    pgtype.Timestamptz `json:"last_activity_at"`
+	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt         pgtype.Timestamptz `json:"updated_at"`
+	Title             pgtype.Text        `json:"title"`
+	PhotoUrl          pgtype.Text        `json:"photo_url"`
+	MemberCount       int32              `json:"member_count"`
+	MemberPermissions int32              `json:"member_permissions"`
+	AdminPermissions  int32              `json:"admin_permissions"`
+	Username          pgtype.Text        `json:"username"`
+	IsForum           bool               `json:"is_forum"`
+	SlowModeSeconds   int32              `json:"slow_mode_seconds"`
+	LastSeq           int64              `json:"last_seq"`
+	AllowedReactions  []string           `json:"allowed_reactions"`
+	MessageTtlSeconds pgtype.Int4        `json:"message_ttl_seconds"`
+	UpdateSeq         int64              `json:"update_seq"`
+	PeerID            pgtype.Text        `json:"peer_id"`
+	PinnedRank        pgtype.Int4        `json:"pinned_rank"`
+	Archived          bool               `json:"archived"`
+	MutedUntil        pgtype.Timestamptz `json:"muted_until"`
+	MarkedUnread      bool               `json:"marked_unread"`
+	ReadSeq           int64              `json:"read_seq"`
+	UnreadCount       int64              `json:"unread_count"`
+	UnreadMentions    int64              `json:"unread_mentions"`
+}
+
+// Lists the user's chats with their per-user state. archived and pinned
+// narrow the list when set. With use_folder the folder rules apply:
+// excluded chats never show, included chats always do, and other chats
+// must match an include rule and no exclude rule.
+func (q *Queries) ListUserChats(ctx context.Context, arg ListUserChatsParams) ([]ListUserChatsRow, error) {
+	rows, err := q.db.Query(ctx, listUserChats,
+		arg.UserID,
+		arg.CursorActivity,
+		arg.CursorID,
+		arg.Archived,
+		arg.Pinned,
+		arg.UseFolder,
+		arg.ExcludedChatIds,
+		arg.IncludedChatIds,
+		arg.IncludeRules,
+		arg.ExcludeRules,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListUserChatsRow{}
+	for rows.Next() {
+		var i ListUserChatsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Type,
+			&i.DirectKey,
+			&i.CreatedBy,
+			&i.LastActivityAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.PhotoUrl,
+			&i.MemberCount,
+			&i.MemberPermissions,
+			&i.AdminPermissions,
+			&i.Username,
+			&i.IsForum,
+			&i.SlowModeSeconds,
+			&i.LastSeq,
+			&i.AllowedReactions,
+			&i.MessageTtlSeconds,
+			&i.UpdateSeq,
+			&i.PeerID,
+			&i.PinnedRank,
+			&i.Archived,
+			&i.MutedUntil,
+			&i.MarkedUnread,
+			&i.ReadSeq,
+			&i.UnreadCount,
+			&i.UnreadMentions,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const removeChatMember = `-- name: RemoveChatMember :execrows
+WITH removed AS (
+    DELETE FROM chat_members
+    WHERE chat_members.chat_id = $1 AND chat_members.user_id = $2
+    RETURNING chat_members.chat_id
+)
+UPDATE chats
+SET member_count = member_count - 1, updated_at = NOW()
+FROM removed
+WHERE chats.id = removed.chat_id
+`
+
+type RemoveChatMemberParams struct {
+	ChatID string `json:"chat_id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) RemoveChatMember(ctx context.Context, arg RemoveChatMemberParams) (int64, error) {
+	result, err := q.db.Exec(ctx, removeChatMember, arg.ChatID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const transferChatOwnership = `-- name: TransferChatOwnership :execrows
+UPDATE chat_members
+SET role = CASE WHEN user_id = $1::text THEN 'owner'::chat_member_role ELSE 'admin'::chat_member_role END,
+    permissions = NULL
+WHERE chat_members.chat_id = $2::text
+  AND chat_members.user_id IN ($3::text, $1::text)
+  AND EXISTS (
+      SELECT 1 FROM chat_members owner
+      WHERE owner.chat_id = $2::text AND owner.user_id = $3::text AND owner.role = 'owner'
+  )
+  AND EXISTS (
+      SELECT 1 FROM chat_members successor
+      WHERE successor.chat_id = $2::text AND successor.user_id = $1::text
+  )
+`
+
+type TransferChatOwnershipParams struct {
+	NewOwnerID     string `json:"new_owner_id"`
+	ChatID         string `json:"chat_id"`
+	CurrentOwnerID string `json:"current_owner_id"`
+}
+
+// Swaps roles in one statement: the new owner is promoted and the current
+// owner becomes an admin. Returns 2 affected rows on success.
+func (q *Queries) TransferChatOwnership(ctx context.Context, arg TransferChatOwnershipParams) (int64, error) {
+	result, err := q.db.Exec(ctx, transferChatOwnership, arg.NewOwnerID, arg.ChatID, arg.CurrentOwnerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateChatInfo = `-- name: UpdateChatInfo :one
+UPDATE chats
+SET title = COALESCE($1, title),
+    photo_url = COALESCE($2, photo_url),
+    updated_at = NOW()
+WHERE id = $3
+RETURNING id, type, direct_key, created_by, last_activity_at, created_at, updated_at, title, photo_url, member_count, member_permissions, admin_permissions, username, is_forum, slow_mode_seconds, last_seq, allowed_reactions, message_ttl_seconds, update_seq
+`
+
+type UpdateChatInfoParams struct {
+	Title    pgtype.Text `json:"title"`
+	PhotoUrl pgtype.Text `json:"photo_url"`
+	ID       string      `json:"id"`
+}
+
+func (q *Queries) UpdateChatInfo(ctx context.Context, arg UpdateChatInfoParams) (Chat, error) {
+	row := q.db.QueryRow(ctx, updateChatInfo, arg.Title, arg.PhotoUrl, arg.ID)
+	var i Chat
+	err := row.Scan(
+		&i.ID,
+		&i.Type,
+		&i.DirectKey,
+		&i.CreatedBy,
+		&i.LastActivityAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.PhotoUrl,
+		&i.MemberCount,
+		&i.MemberPermissions,
+		&i.AdminPermissions,
+		&i.Username,
+		&i.IsForum,
+		&i.SlowModeSeconds,
+		&i.LastSeq,
+		&i.AllowedReactions,
+		&i.MessageTtlSeconds,
+		&i.UpdateSeq,
+	)
+	return i, err
+}
+
+const updateChatMemberPermissions = `-- name: UpdateChatMemberPermissions :execrows
+UPDATE chat_members
+SET permissions = $1, custom_title = $2
+WHERE chat_id = $3 AND user_id = $4
+`
+
+type UpdateChatMemberPermissionsParams struct {
+	Permissions pgtype.Int4 `json:"permissions"`
+	CustomTitle pgtype.Text `json:"custom_title"`
+	ChatID      string      `json:"chat_id"`
+	UserID      string      `json:"user_id"`
+}
+
+func (q *Queries) UpdateChatMemberPermissions(ctx context.Context, arg UpdateChatMemberPermissionsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateChatMemberPermissions,
+		arg.Permissions,
+		arg.CustomTitle,
+		arg.ChatID,
+		arg.UserID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateChatMemberRole = `-- name: UpdateChatMemberRole :execrows
+UPDATE chat_members
+SET role = $3, permissions = NULL, custom_title = NULL
+WHERE chat_id = $1 AND user_id = $2
+`
+
+type UpdateChatMemberRoleParams struct {
+	ChatID string         `json:"chat_id"`
+	UserID string         `json:"user_id"`
+	Role   ChatMemberRole `json:"role"`
+}
+
+// Changing a role resets per-member overrides to the new role's defaults.
+func (q *Queries) UpdateChatMemberRole(ctx context.Context, arg UpdateChatMemberRoleParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateChatMemberRole, arg.ChatID, arg.UserID, arg.Role)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateChatPermissions = `-- name: UpdateChatPermissions :exec
+UPDATE chats
+SET member_permissions = $2, admin_permissions = $3, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateChatPermissionsParams struct {
+	ID                string `json:"id"`
+	MemberPermissions int32  `json:"member_permissions"`
+	AdminPermissions  int32  `json:"admin_permissions"`
+}
+
+func (q *Queries) UpdateChatPermissions(ctx context.Context, arg UpdateChatPermissionsParams) error {
+	_, err := q.db.Exec(ctx, updateChatPermissions, arg.ID, arg.MemberPermissions, arg.AdminPermissions)
+	return err
+}
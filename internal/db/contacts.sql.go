@@ -144,6 +144,46 @@ func (q *Queries) FindUserByIdentifier(ctx context.Context, arg FindUserByIdenti
 	return i, err
 }
 
+const findUsersByContactInfo = `-- name: FindUsersByContactInfo :many
+SELECT id, username, phone, email, hashed_password, status, created_at, updated_at
+FROM users
+WHERE phone = ANY($1::text[]) OR lower(email) = ANY($2::text[])
+`
+
+type FindUsersByContactInfoParams struct {
+	Phones []string `json:"phones"`
+	Emails []string `json:"emails"`
+}
+
+func (q *Queries) FindUsersByContactInfo(ctx context.Context, arg FindUsersByContactInfoParams) ([]User, error) {
+	rows, err := q.db.Query(ctx, findUsersByContactInfo, arg.Phones, arg.Emails)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Phone,
+			&i.Email,
+			&i.HashedPassword,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getContactRequest = `-- name: GetContactRequest :one
 SELECT id, from_user_id, to_user_id, state, message, created_at FROM contact_requests
 WHERE id = $1
@@ -163,6 +203,23 @@ func (q *Queries) GetContactRequest(ctx context.Context, id string) (ContactRequ
 	return i, err
 }
 
+const getContactRequestState = `-- name: GetContactRequestState :one
+SELECT state FROM contact_requests
+WHERE from_user_id = $1 AND to_user_id = $2
+`
+
+type GetContactRequestStateParams struct {
+	FromUserID string `json:"from_user_id"`
+	ToUserID   string `json:"to_user_id"`
+}
+
+func (q *Queries) GetContactRequestState(ctx context.Context, arg GetContactRequestStateParams) (ContactRequestState, error) {
+	row := q.db.QueryRow(ctx, getContactRequestState, arg.FromUserID, arg.ToUserID)
+	var state ContactRequestState
+	err := row.Scan(&state)
+	return state, err
+}
+
 const isBlocked = `-- name: IsBlocked :one
 SELECT EXISTS(
     SELECT 1 FROM blocks
@@ -182,6 +239,48 @@ func (q *Queries) IsBlocked(ctx context.Context, arg IsBlockedParams) (bool, err
 	return exists, err
 }
 
+const listAcceptedContactsWithUsers = `-- name: ListAcceptedContactsWithUsers :many
+SELECT c.peer_id, c.alias, u.username, u.phone, u.email
+FROM contacts c
+JOIN users u ON u.id = c.peer_id
+WHERE c.owner_id = $1 AND c.state = 'accepted'
+ORDER BY COALESCE(c.alias, u.username)
+`
+
+type ListAcceptedContactsWithUsersRow struct {
+	PeerID   string      `json:"peer_id"`
+	Alias    pgtype.Text `json:"alias"`
+	Username string      `json:"username"`
+	Phone    pgtype.Text `json:"phone"`
+	Email    pgtype.Text `json:"email"`
+}
+
+func (q *Queries) ListAcceptedContactsWithUsers(ctx context.Context, ownerID string) ([]ListAcceptedContactsWithUsersRow, error) {
+	rows, err := q.db.Query(ctx, listAcceptedContactsWithUsers, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListAcceptedContactsWithUsersRow{}
+	for rows.Next() {
+		var i ListAcceptedContactsWithUsersRow
+		if err := rows.Scan(
+			&i.PeerID,
+			&i.Alias,
+			&i.Username,
+			&i.Phone,
+			&i.Email,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listContacts = `-- name: ListContacts :many
 SELECT id, owner_id, peer_id, alias, state, created_at, updated_at FROM contacts
 WHERE owner_id = $1 AND state = $2
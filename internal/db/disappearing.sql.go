@@ -0,0 +1,85 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: disappearing.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const expireMessages = `-- name: ExpireMessages :many
+WITH target AS (
+    SELECT id FROM messages
+    WHERE expires_at <= NOW() AND deleted_at IS NULL
+    ORDER BY expires_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+), payloads AS (
+    DELETE FROM message_device_payloads p USING target WHERE p.message_id = target.id
+), edits AS (
+    DELETE FROM message_edits e USING target WHERE e.message_id = target.id
+), reactions AS (
+    DELETE FROM message_reactions r USING target WHERE r.message_id = target.id
+), pins AS (
+    DELETE FROM pinned_messages pm USING target WHERE pm.message_id = target.id
+), polls AS (
+    DELETE FROM polls pl USING target WHERE pl.message_id = target.id
+), mentions AS (
+    DELETE FROM message_mentions mm USING target WHERE mm.message_id = target.id
+)
+UPDATE messages m
+SET ciphertext = ''::bytea, reactions = '{}', deleted_at = NOW()
+FROM target
+WHERE m.id = target.id
+RETURNING m.chat_id, m.id
+`
+
+type ExpireMessagesRow struct {
+	ChatID string `json:"chat_id"`
+	ID     string `json:"id"`
+}
+
+// Tombstones up to batch_size expired messages like
+// DeleteMessagesForEveryone. Concurrent reapers skip each other's rows.
+func (q *Queries) ExpireMessages(ctx context.Context, batchSize int32) ([]ExpireMessagesRow, error) {
+	rows, err := q.db.Query(ctx, expireMessages, batchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ExpireMessagesRow{}
+	for rows.Next() {
+		var i ExpireMessagesRow
+		if err := rows.Scan(&i.ChatID, &i.ID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setChatMessageTTL = `-- name: SetChatMessageTTL :execrows
+UPDATE chats
+SET message_ttl_seconds = $1::int, updated_at = NOW()
+WHERE id = $2
+`
+
+type SetChatMessageTTLParams struct {
+	MessageTtlSeconds pgtype.Int4 `json:"message_ttl_seconds"`
+	ID                string      `json:"id"`
+}
+
+func (q *Queries) SetChatMessageTTL(ctx context.Context, arg SetChatMessageTTLParams) (int64, error) {
+	result, err := q.db.Exec(ctx, setChatMessageTTL, arg.MessageTtlSeconds, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,221 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: drafts.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const clearThreadDrafts = `-- name: ClearThreadDrafts :many
+UPDATE drafts
+SET ciphertext        = ''::bytea,
+    reply_to_id       = NULL,
+    client_updated_at = GREATEST(client_updated_at, NOW()),
+    updated_at        = NOW()
+WHERE thread_id = $1 AND (ciphertext <> ''::bytea OR reply_to_id IS NOT NULL)
+RETURNING user_id, chat_id, thread_id, ciphertext, reply_to_id, client_updated_at, updated_at
+`
+
+// Clears every user's draft in a thread. The client timestamp moves to now
+// so that the cleared draft wins over the copies on users' devices.
+func (q *Queries) ClearThreadDrafts(ctx context.Context, threadID pgtype.Text) ([]Draft, error) {
+	rows, err := q.db.Query(ctx, clearThreadDrafts, threadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Draft{}
+	for rows.Next() {
+		var i Draft
+		if err := rows.Scan(
+			&i.UserID,
+			&i.ChatID,
+			&i.ThreadID,
+			&i.Ciphertext,
+			&i.ReplyToID,
+			&i.ClientUpdatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDraft = `-- name: GetDraft :one
+SELECT user_id, chat_id, thread_id, ciphertext, reply_to_id, client_updated_at, updated_at FROM drafts
+WHERE user_id = $1 AND chat_id = $2
+  AND COALESCE(thread_id, '') = COALESCE($3::text, '')
+`
+
+type GetDraftParams struct {
+	UserID   string      `json:"user_id"`
+	ChatID   string      `json:"chat_id"`
+	ThreadID pgtype.Text `json:"thread_id"`
+}
+
+func (q *Queries) GetDraft(ctx context.Context, arg GetDraftParams) (Draft, error) {
+	row := q.db.QueryRow(ctx, getDraft, arg.UserID, arg.ChatID, arg.ThreadID)
+	var i Draft
+	err := row.Scan(
+		&i.UserID,
+		&i.ChatID,
+		&i.ThreadID,
+		&i.Ciphertext,
+		&i.ReplyToID,
+		&i.ClientUpdatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listChatDrafts = `-- name: ListChatDrafts :many
+SELECT user_id, chat_id, thread_id, ciphertext, reply_to_id, client_updated_at, updated_at FROM drafts
+WHERE user_id = $1 AND chat_id = ANY($2::text[])
+  AND (ciphertext <> ''::bytea OR reply_to_id IS NOT NULL)
+ORDER BY chat_id, COALESCE(thread_id, '')
+`
+
+type ListChatDraftsParams struct {
+	UserID  string   `json:"user_id"`
+	ChatIds []string `json:"chat_ids"`
+}
+
+// The user's drafts in the chats of a chat list page. Cleared drafts are
+// left out.
+func (q *Queries) ListChatDrafts(ctx context.Context, arg ListChatDraftsParams) ([]Draft, error) {
+	rows, err := q.db.Query(ctx, listChatDrafts, arg.UserID, arg.ChatIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Draft{}
+	for rows.Next() {
+		var i Draft
+		if err := rows.Scan(
+			&i.UserID,
+			&i.ChatID,
+			&i.ThreadID,
+			&i.Ciphertext,
+			&i.ReplyToID,
+			&i.ClientUpdatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDrafts = `-- name: ListDrafts :many
+SELECT d.user_id, d.chat_id, d.thread_id, d.ciphertext, d.reply_to_id, d.client_updated_at, d.updated_at FROM drafts d
+JOIN chat_members m ON m.chat_id = d.chat_id AND m.user_id = d.user_id
+WHERE d.user_id = $1 AND (d.ciphertext <> ''::bytea OR d.reply_to_id IS NOT NULL)
+  AND ($2::timestamptz IS NULL
+       OR (d.updated_at, d.chat_id, COALESCE(d.thread_id, ''))
+          < ($2::timestamptz, $3::text, $4::text))
+ORDER BY d.updated_at DESC, d.chat_id DESC, COALESCE(d.thread_id, '') DESC
+LIMIT $5
+`
+
+type ListDraftsParams struct {
+	UserID          string             `json:"user_id"`
+	CursorUpdatedAt pgtype.Timestamptz `json:"cursor_updated_at"`
+	CursorChatID    pgtype.Text        `json:"cursor_chat_id"`
+	CursorThreadID  pgtype.Text        `json:"cursor_thread_id"`
+	PageSize        int32              `json:"page_size"`
+}
+
+// Returns a page of the user's drafts in chats they are a member of, most
+// recently changed first. Cleared drafts are left out.
+func (q *Queries) ListDrafts(ctx context.Context, arg ListDraftsParams) ([]Draft, error) {
+	rows, err := q.db.Query(ctx, listDrafts,
+		arg.UserID,
+		arg.CursorUpdatedAt,
+		arg.CursorChatID,
+		arg.CursorThreadID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Draft{}
+	for rows.Next() {
+		var i Draft
+		if err := rows.Scan(
+			&i.UserID,
+			&i.ChatID,
+			&i.ThreadID,
+			&i.Ciphertext,
+			&i.ReplyToID,
+			&i.ClientUpdatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const saveDraft = `-- name: SaveDraft :one
+INSERT INTO drafts (user_id, chat_id, thread_id, ciphertext, reply_to_id, client_updated_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_id, chat_id, (COALESCE(thread_id, ''))) DO UPDATE
+SET ciphertext        = EXCLUDED.ciphertext,
+    reply_to_id       = EXCLUDED.reply_to_id,
+    client_updated_at = EXCLUDED.client_updated_at,
+    updated_at        = NOW()
+WHERE drafts.client_updated_at < EXCLUDED.client_updated_at
+RETURNING user_id, chat_id, thread_id, ciphertext, reply_to_id, client_updated_at, updated_at
+`
+
+type SaveDraftParams struct {
+	UserID          string             `json:"user_id"`
+	ChatID          string             `json:"chat_id"`
+	ThreadID        pgtype.Text        `json:"thread_id"`
+	Ciphertext      []byte             `json:"ciphertext"`
+	ReplyToID       pgtype.Text        `json:"reply_to_id"`
+	ClientUpdatedAt pgtype.Timestamptz `json:"client_updated_at"`
+}
+
+// Stores a draft unless the stored one has the same or a newer client
+// timestamp, in which case no row is returned.
+func (q *Queries) SaveDraft(ctx context.Context, arg SaveDraftParams) (Draft, error) {
+	row := q.db.QueryRow(ctx, saveDraft,
+		arg.UserID,
+		arg.ChatID,
+		arg.ThreadID,
+		arg.Ciphertext,
+		arg.ReplyToID,
+		arg.ClientUpdatedAt,
+	)
+	var i Draft
+	err := row.Scan(
+		&i.UserID,
+		&i.ChatID,
+		&i.ThreadID,
+		&i.Ciphertext,
+		&i.ReplyToID,
+		&i.ClientUpdatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
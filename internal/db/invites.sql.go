@@ -0,0 +1,356 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: invites.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const consumeInviteLink = `-- name: ConsumeInviteLink :one
+UPDATE chat_invite_links
+SET usage_count = usage_count + 1
+WHERE id = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW())
+  AND (usage_limit IS NULL OR usage_count < usage_limit)
+RETURNING id, chat_id, token, name, created_by, expires_at, usage_limit, usage_count, requires_approval, revoked_at, created_at
+`
+
+// Takes one use of a link if it is still valid. Returns no row otherwise.
+func (q *Queries) ConsumeInviteLink(ctx context.Context, id string) (ChatInviteLink, error) {
+	row := q.db.QueryRow(ctx, consumeInviteLink, id)
+	var i ChatInviteLink
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.Token,
+		&i.Name,
+		&i.CreatedBy,
+		&i.ExpiresAt,
+		&i.UsageLimit,
+		&i.UsageCount,
+		&i.RequiresApproval,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createInviteLink = `-- name: CreateInviteLink :one
+INSERT INTO chat_invite_links (id, chat_id, token, name, created_by, expires_at, usage_limit, requires_approval)
+VALUES ($1, $2, $3, $6, $4, $7, $8, $5)
+RETURNING id, chat_id, token, name, created_by, expires_at, usage_limit, usage_count, requires_approval, revoked_at, created_at
+`
+
+type CreateInviteLinkParams struct {
+	ID               string             `json:"id"`
+	ChatID           string             `json:"chat_id"`
+	Token            string             `json:"token"`
+	CreatedBy        pgtype.Text        `json:"created_by"`
+	RequiresApproval bool               `json:"requires_approval"`
+	Name             pgtype.Text        `json:"name"`
+	ExpiresAt        pgtype.Timestamptz `json:"expires_at"`
+	UsageLimit       pgtype.Int4        `json:"usage_limit"`
+}
+
+func (q *Queries) CreateInviteLink(ctx context.Context, arg CreateInviteLinkParams) (ChatInviteLink, error) {
+	row := q.db.QueryRow(ctx, createInviteLink,
+		arg.ID,
+		arg.ChatID,
+		arg.Token,
+		arg.CreatedBy,
+		arg.RequiresApproval,
+		arg.Name,
+		arg.ExpiresAt,
+		arg.UsageLimit,
+	)
+	var i ChatInviteLink
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.Token,
+		&i.Name,
+		&i.CreatedBy,
+		&i.ExpiresAt,
+		&i.UsageLimit,
+		&i.UsageCount,
+		&i.RequiresApproval,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const decideJoinRequest = `-- name: DecideJoinRequest :execrows
+UPDATE chat_join_requests
+SET state = $3, decided_by = $4, decided_at = NOW()
+WHERE chat_id = $1 AND user_id = $2 AND state = 'pending'
+`
+
+type DecideJoinRequestParams struct {
+	ChatID    string           `json:"chat_id"`
+	UserID    string           `json:"user_id"`
+	State     JoinRequestState `json:"state"`
+	DecidedBy pgtype.Text      `json:"decided_by"`
+}
+
+func (q *Queries) DecideJoinRequest(ctx context.Context, arg DecideJoinRequestParams) (int64, error) {
+	result, err := q.db.Exec(ctx, decideJoinRequest,
+		arg.ChatID,
+		arg.UserID,
+		arg.State,
+		arg.DecidedBy,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getInviteLink = `-- name: GetInviteLink :one
+SELECT id, chat_id, token, name, created_by, expires_at, usage_limit, usage_count, requires_approval, revoked_at, created_at FROM chat_invite_links
+WHERE id = $1
+`
+
+func (q *Queries) GetInviteLink(ctx context.Context, id string) (ChatInviteLink, error) {
+	row := q.db.QueryRow(ctx, getInviteLink, id)
+	var i ChatInviteLink
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.Token,
+		&i.Name,
+		&i.CreatedBy,
+		&i.ExpiresAt,
+		&i.UsageLimit,
+		&i.UsageCount,
+		&i.RequiresApproval,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getInviteLinkByToken = `-- name: GetInviteLinkByToken :one
+SELECT id, chat_id, token, name, created_by, expires_at, usage_limit, usage_count, requires_approval, revoked_at, created_at FROM chat_invite_links
+WHERE token = $1
+`
+
+func (q *Queries) GetInviteLinkByToken(ctx context.Context, token string) (ChatInviteLink, error) {
+	row := q.db.QueryRow(ctx, getInviteLinkByToken, token)
+	var i ChatInviteLink
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.Token,
+		&i.Name,
+		&i.CreatedBy,
+		&i.ExpiresAt,
+		&i.UsageLimit,
+		&i.UsageCount,
+		&i.RequiresApproval,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getJoinRequest = `-- name: GetJoinRequest :one
+SELECT chat_id, user_id, invite_link_id, state, decided_by, decided_at, created_at FROM chat_join_requests
+WHERE chat_id = $1 AND user_id = $2
+`
+
+type GetJoinRequestParams struct {
+	ChatID string `json:"chat_id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) GetJoinRequest(ctx context.Context, arg GetJoinRequestParams) (ChatJoinRequest, error) {
+	row := q.db.QueryRow(ctx, getJoinRequest, arg.ChatID, arg.UserID)
+	var i ChatJoinRequest
+	err := row.Scan(
+		&i.ChatID,
+		&i.UserID,
+		&i.InviteLinkID,
+		&i.State,
+		&i.DecidedBy,
+		&i.DecidedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const isBannedFromChat = `-- name: IsBannedFromChat :one
+SELECT EXISTS(
+    SELECT 1 FROM chat_bans
+    WHERE chat_id = $1 AND user_id = $2 AND (until_at IS NULL OR until_at > NOW())
+)
+`
+
+type IsBannedFromChatParams struct {
+	ChatID string `json:"chat_id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) IsBannedFromChat(ctx context.Context, arg IsBannedFromChatParams) (bool, error) {
+	row := q.db.QueryRow(ctx, isBannedFromChat, arg.ChatID, arg.UserID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const listInviteLinks = `-- name: ListInviteLinks :many
+SELECT id, chat_id, token, name, created_by, expires_at, usage_limit, usage_count, requires_approval, revoked_at, created_at FROM chat_invite_links
+WHERE chat_id = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListInviteLinks(ctx context.Context, chatID string) ([]ChatInviteLink, error) {
+	rows, err := q.db.Query(ctx, listInviteLinks, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ChatInviteLink{}
+	for rows.Next() {
+		var i ChatInviteLink
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChatID,
+			&i.Token,
+			&i.Name,
+			&i.CreatedBy,
+			&i.ExpiresAt,
+			&i.UsageLimit,
+			&i.UsageCount,
+			&i.RequiresApproval,
+			&i.RevokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPendingJoinRequests = `-- name: ListPendingJoinRequests :many
+SELECT r.chat_id, r.user_id, r.invite_link_id, r.state, r.decided_by, r.decided_at, r.created_at, u.username
+FROM chat_join_requests r
+JOIN users u ON u.id = r.user_id
+WHERE r.chat_id = $1 AND r.state = 'pending'
+  AND ($2::timestamptz IS NULL
+       OR (r.created_at, r.user_id) > ($2::timestamptz, $3::text))
+ORDER BY r.created_at, r.user_id
+LIMIT $4
+`
+
+type ListPendingJoinRequestsParams struct {
+	ChatID          string             `json:"chat_id"`
+	CursorCreatedAt pgtype.Timestamptz `json:"cursor_created_at"`
+	CursorUserID    pgtype.Text        `json:"cursor_user_id"`
+	PageSize        int32              `json:"page_size"`
+}
+
+type ListPendingJoinRequestsRow struct {
+	ChatID       string             `json:"chat_id"`
+	UserID       string             `json:"user_id"`
+	InviteLinkID pgtype.Text        `json:"invite_link_id"`
+	State        JoinRequestState   `json:"state"`
+	DecidedBy    pgtype.Text        `json:"decided_by"`
+	DecidedAt    pgtype.Timestamptz `json:"decided_at"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+	Username     string             `json:"username"`
+}
+
+func (q *Queries) ListPendingJoinRequests(ctx context.Context, arg ListPendingJoinRequestsParams) ([]ListPendingJoinRequestsRow, error) {
+	rows, err := q.db.Query(ctx, listPendingJoinRequests,
+		arg.ChatID,
+		arg.CursorCreatedAt,
+		arg.CursorUserID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListPendingJoinRequestsRow{}
+	for rows.Next() {
+		var i ListPendingJoinRequestsRow
+		if err := rows.Scan(
+			&i.ChatID,
+			&i.UserID,
+			&i.InviteLinkID,
+			&i.State,
+			&i.DecidedBy,
+			&i.DecidedAt,
+			&i.CreatedAt,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeInviteLink = `-- name: RevokeInviteLink :execrows
+UPDATE chat_invite_links
+SET revoked_at = NOW()
+WHERE id = $1 AND chat_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeInviteLinkParams struct {
+	ID     string `json:"id"`
+	ChatID string `json:"chat_id"`
+}
+
+func (q *Queries) RevokeInviteLink(ctx context.Context, arg RevokeInviteLinkParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeInviteLink, arg.ID, arg.ChatID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const upsertJoinRequest = `-- name: UpsertJoinRequest :one
+INSERT INTO chat_join_requests (chat_id, user_id, invite_link_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (chat_id, user_id) DO UPDATE
+SET state = 'pending', invite_link_id = EXCLUDED.invite_link_id,
+    decided_by = NULL, decided_at = NULL, created_at = NOW()
+WHERE chat_join_requests.state <> 'pending'
+RETURNING chat_id, user_id, invite_link_id, state, decided_by, decided_at, created_at
+`
+
+type UpsertJoinRequestParams struct {
+	ChatID       string      `json:"chat_id"`
+	UserID       string      `json:"user_id"`
+	InviteLinkID pgtype.Text `json:"invite_link_id"`
+}
+
+func (q *Queries) UpsertJoinRequest(ctx context.Context, arg UpsertJoinRequestParams) (ChatJoinRequest, error) {
+	row := q.db.QueryRow(ctx, upsertJoinRequest, arg.ChatID, arg.UserID, arg.InviteLinkID)
+	var i ChatJoinRequest
+	err := row.Scan(
+		&i.ChatID,
+		&i.UserID,
+		&i.InviteLinkID,
+		&i.State,
+		&i.DecidedBy,
+		&i.DecidedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
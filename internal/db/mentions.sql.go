@@ -0,0 +1,212 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: mentions.sql
+
+package db
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const listMentions = `-- name: ListMentions :many
+SELECT mm.is_reply,
+       (NOT mm.read AND mm.seq > COALESCE(s.read_seq, 0))::bool AS unread,
+       mm.created_at AS mentioned_at,
+       m.id, m.chat_id, m.seq, m.sender_id, m.sender_device_id, m.thread_id, m.thread_seq, m.content_type, m.ciphertext, m.created_at, m.client_message_id, m.version, m.edited_at, m.deleted_at, m.reactions, m.reply_to_id, m.forward_from_user_id, m.forward_from_chat_id, m.forward_from_message_id, m.forward_date, m.expires_at, m.view_once, m.service, dp.ciphertext AS device_ciphertext
+FROM message_mentions mm
+JOIN messages m ON m.id = mm.message_id AND m.deleted_at IS NULL
+JOIN chat_members cm ON cm.chat_id = mm.chat_id AND cm.user_id = mm.user_id
+LEFT JOIN chat_user_states s ON s.user_id = mm.user_id AND s.chat_id = mm.chat_id
+LEFT JOIN devices d ON d.id = $1::text AND d.user_id = mm.user_id
+LEFT JOIN message_device_payloads dp ON dp.message_id = m.id AND dp.version = m.version AND dp.device_id = d.id
+WHERE mm.user_id = $2
+  AND ($3::text IS NULL OR mm.chat_id = $3::text)
+  AND (NOT $4::bool OR (NOT mm.read AND mm.seq > COALESCE(s.read_seq, 0)))
+  AND mm.seq > COALESCE(s.cleared_seq, 0)
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.user_id = mm.user_id AND h.message_id = mm.message_id)
+  AND NOT EXISTS (
+      SELECT 1 FROM blocks b
+      WHERE (b.owner_id = mm.user_id AND b.target_user_id = m.sender_id)
+         OR (b.owner_id = m.sender_id AND b.target_user_id = mm.user_id)
+  )
+  AND ($5::timestamptz IS NULL
+       OR (mm.created_at, mm.message_id) < ($5::timestamptz, $6::text))
+ORDER BY mm.created_at DESC, mm.message_id DESC
+LIMIT $7
+`
+
+type ListMentionsParams struct {
+	DeviceID          pgtype.Text        `json:"device_id"`
+	UserID            string             `json:"user_id"`
+	ChatID            pgtype.Text        `json:"chat_id"`
+	UnreadOnly        bool               `json:"unread_only"`
+	CursorMentionedAt pgtype.Timestamptz `json:"cursor_mentioned_at"`
+	CursorMessageID   pgtype.Text        `json:"cursor_message_id"`
+	PageSize          int32              `json:"page_size"`
+}
+
+type ListMentionsRow struct {
+	IsReply              bool               `json:"is_reply"`
+	Unread               bool               `json:"unread"`
+	MentionedAt          pgtype.Timestamptz `json:"mentioned_at"`
+	ID                   string             `json:"id"`
+	ChatID               string             `json:"chat_id"`
+	Seq                  int64              `json:"seq"`
+	SenderID             pgtype.Text        `json:"sender_id"`
+	SenderDeviceID       pgtype.Text        `json:"sender_device_id"`
+	ThreadID             pgtype.Text        `json:"thread_id"`
+	ThreadSeq            pgtype.Int8        `json:"thread_seq"`
+	ContentType          string             `json:"content_type"`
+	Ciphertext           []byte             `json:"ciphertext"`
+	CreatedAt            pgtype.Timestamptz `json:"created_at"`
+	ClientMessageID      pgtype.Text        `json:"client_message_id"`
+	Version              int32              `json:"version"`
+	EditedAt             pgtype.Timestamptz `json:"edited_at"`
+	DeletedAt            pgtype.Timestamptz `json:"deleted_at"`
+	Reactions            json.RawMessage    `json:"reactions"`
+	ReplyToID            pgtype.Text        `json:"reply_to_id"`
+	ForwardFromUserID    pgtype.Text        `json:"forward_from_user_id"`
+	ForwardFromChatID    pgtype.Text        `json:"forward_from_chat_id"`
+	ForwardFromMessageID pgtype.Text        `json:"forward_from_message_id"`
+	ForwardDate          pgtype.Timestamptz `json:"forward_date"`
+	ExpiresAt            pgtype.Timestamptz `json:"expires_at"`
+	ViewOnce             bool               `json:"view_once"`
+	Service              json.RawMessage    `json:"service"`
+	DeviceCiphertext     []byte             `json:"device_ciphertext"`
+}
+
+// Returns a page of the messages mentioning the user, newest first, with
+// the payload addressed to one of the user's devices. Only chats the user
+// is still a member of count, and mentions by users with a block either way
+// are left out, as are deleted, hidden and cleared messages. chat_id
+// narrows the feed to one chat and unread_only to unread mentions.
+func (q *Queries) ListMentions(ctx context.Context, arg ListMentionsParams) ([]ListMentionsRow, error) {
+	rows, err := q.db.Query(ctx, listMentions,
+		arg.DeviceID,
+		arg.UserID,
+		arg.ChatID,
+		arg.UnreadOnly,
+		arg.CursorMentionedAt,
+		arg.CursorMessageID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMentionsRow{}
+	for rows.Next() {
+		var i ListMentionsRow
+		if err := rows.Scan(
+			&i.IsReply,
+			&i.Unread,
+			&i.MentionedAt,
+			&i.ID,
+			&i.ChatID,
+			&i.Seq,
+			&i.SenderID,
+			&i.SenderDeviceID,
+			&i.ThreadID,
+			&i.ThreadSeq,
+			&i.ContentType,
+			&i.Ciphertext,
+			&i.CreatedAt,
+			&i.ClientMessageID,
+			&i.Version,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.Reactions,
+			&i.ReplyToID,
+			&i.ForwardFromUserID,
+			&i.ForwardFromChatID,
+			&i.ForwardFromMessageID,
+			&i.ForwardDate,
+			&i.ExpiresAt,
+			&i.ViewOnce,
+			&i.Service,
+			&i.DeviceCiphertext,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessageMentions = `-- name: ListMessageMentions :many
+SELECT mm.user_id, mm.is_reply,
+       ((NOT COALESCE(s.muted_until > NOW(), false) OR COALESCE(s.mention_notifications, true))
+        AND COALESCE(ts.notify_level, 'all') <> 'none'
+        AND NOT COALESCE(ts.muted_until > NOW(), false))::bool AS notify,
+       (SELECT count(*) FROM message_mentions u
+        WHERE u.user_id = mm.user_id AND u.chat_id = mm.chat_id AND NOT u.read
+          AND u.seq > COALESCE(s.read_seq, 0))::bigint AS unread_mentions
+FROM message_mentions mm
+JOIN messages m ON m.id = mm.message_id
+LEFT JOIN chat_user_states s ON s.user_id = mm.user_id AND s.chat_id = mm.chat_id
+LEFT JOIN thread_member_states ts ON ts.thread_id = m.thread_id AND ts.user_id = mm.user_id
+WHERE mm.message_id = $1
+`
+
+type ListMessageMentionsRow struct {
+	UserID         string `json:"user_id"`
+	IsReply        bool   `json:"is_reply"`
+	Notify         bool   `json:"notify"`
+	UnreadMentions int64  `json:"unread_mentions"`
+}
+
+// Returns the users a message mentions, whether the mention should notify
+// them and their unread mentions in the chat. Mentions notify unless the
+// thread is set to no notifications, or the chat is muted and the user
+// turned off mentions for muted chats.
+func (q *Queries) ListMessageMentions(ctx context.Context, messageID string) ([]ListMessageMentionsRow, error) {
+	rows, err := q.db.Query(ctx, listMessageMentions, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMessageMentionsRow{}
+	for rows.Next() {
+		var i ListMessageMentionsRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.IsReply,
+			&i.Notify,
+			&i.UnreadMentions,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const readChatMentions = `-- name: ReadChatMentions :execrows
+UPDATE message_mentions
+SET read = TRUE
+WHERE user_id = $1 AND chat_id = $2 AND NOT read
+`
+
+type ReadChatMentionsParams struct {
+	UserID string `json:"user_id"`
+	ChatID string `json:"chat_id"`
+}
+
+// Marks all of the user's mentions in a chat read.
+func (q *Queries) ReadChatMentions(ctx context.Context, arg ReadChatMentionsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, readChatMentions, arg.UserID, arg.ChatID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
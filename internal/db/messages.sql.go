@@ -0,0 +1,1065 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: messages.sql
+
+package db
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const clearChatHistory = `-- name: ClearChatHistory :one
+WITH cleared AS (
+    INSERT INTO chat_user_states (user_id, chat_id, cleared_seq)
+    SELECT $1::text, id, last_seq FROM chats WHERE id = $2::text
+    ON CONFLICT (user_id, chat_id) DO UPDATE
+    SET cleared_seq = EXCLUDED.cleared_seq, updated_at = NOW()
+    RETURNING cleared_seq
+), unhidden AS (
+    DELETE FROM message_hidden WHERE user_id = $1::text AND chat_id = $2::text
+)
+SELECT cleared_seq FROM cleared
+`
+
+type ClearChatHistoryParams struct {
+	UserID string `json:"user_id"`
+	ChatID string `json:"chat_id"`
+}
+
+// Hides every current message of the chat from the user and returns the
+// seq up to which the history is cleared. Per-message hides below it are
+// no longer needed.
+func (q *Queries) ClearChatHistory(ctx context.Context, arg ClearChatHistoryParams) (int64, error) {
+	row := q.db.QueryRow(ctx, clearChatHistory, arg.UserID, arg.ChatID)
+	var cleared_seq int64
+	err := row.Scan(&cleared_seq)
+	return cleared_seq, err
+}
+
+const createMessage = `-- name: CreateMessage :one
+WITH thread AS (
+    UPDATE chat_threads
+    SET last_seq = last_seq + 1, last_message_id = $1::text, last_message_at = NOW(), updated_at = NOW()
+    WHERE chat_threads.id = $2::text AND chat_threads.chat_id = $3 AND NOT is_closed
+    RETURNING chat_threads.last_seq
+), next AS (
+    UPDATE chats
+    SET last_seq = last_seq + 1, last_activity_at = NOW()
+    WHERE chats.id = $3
+      AND ($2::text IS NULL OR EXISTS (SELECT 1 FROM thread))
+    RETURNING chats.last_seq
+), msg AS (
+    INSERT INTO messages (id, chat_id, seq, sender_id, sender_device_id, thread_id, thread_seq, content_type, ciphertext, client_message_id,
+                          reply_to_id, forward_from_user_id, forward_from_chat_id, forward_from_message_id, forward_date,
+                          expires_at, view_once, service)
+    SELECT $1::text, $3, next.last_seq, $4::text, $5::text, $2::text,
+           (SELECT last_seq FROM thread), $6, $7, $8::text,
+           $9::text, $10::text, $11::text,
+           $12::text, $13::timestamptz,
+           NOW() + make_interval(secs => $14::int), $15::bool, $16::jsonb
+    FROM next
+    RETURNING id, chat_id, seq, sender_id, sender_device_id, thread_id, thread_seq, content_type, ciphertext, created_at, client_message_id, version, edited_at, deleted_at, reactions, reply_to_id, forward_from_user_id, forward_from_chat_id, forward_from_message_id, forward_date, expires_at, view_once, service
+), payloads AS (
+    INSERT INTO message_device_payloads (message_id, device_id, ciphertext)
+    SELECT msg.id, d.device_id, ($17::bytea[])[d.ord]
+    FROM msg, unnest($18::text[]) WITH ORDINALITY AS d(device_id, ord)
+), sender_read AS (
+    INSERT INTO chat_user_states (user_id, chat_id, read_seq)
+    SELECT msg.sender_id, msg.chat_id, msg.seq FROM msg
+    ON CONFLICT (user_id, chat_id) DO UPDATE
+    SET read_seq = GREATEST(chat_user_states.read_seq, EXCLUDED.read_seq)
+), poll AS (
+    INSERT INTO polls (message_id, chat_id, option_count, multiple_choice, anonymous, correct_option, closes_at)
+    SELECT msg.id, msg.chat_id, $19::smallint, $20::bool, $21::bool,
+           $22::smallint, $23::timestamptz
+    FROM msg
+    WHERE $19::smallint IS NOT NULL
+), reply_target AS (
+    SELECT r.sender_id FROM messages r, msg
+    WHERE r.chat_id = msg.chat_id AND r.id = msg.reply_to_id
+), mentions AS (
+    INSERT INTO message_mentions (user_id, message_id, chat_id, seq, is_reply)
+    SELECT cm.user_id, msg.id, msg.chat_id, msg.seq, cm.user_id IN (SELECT sender_id FROM reply_target)
+    FROM msg
+    JOIN chat_members cm ON cm.chat_id = msg.chat_id
+    JOIN users u ON u.id = cm.user_id
+    WHERE cm.user_id <> msg.sender_id
+      AND (cm.user_id = ANY($24::text[])
+           OR lower(u.username) = ANY($25::text[])
+           OR cm.user_id IN (SELECT sender_id FROM reply_target))
+      AND NOT EXISTS (
+          SELECT 1 FROM blocks b
+          WHERE (b.owner_id = cm.user_id AND b.target_user_id = msg.sender_id)
+             OR (b.owner_id = msg.sender_id AND b.target_user_id = cm.user_id)
+      )
+)
+SELECT id, chat_id, seq, sender_id, sender_device_id, thread_id, thread_seq, content_type, ciphertext, created_at, client_message_id, version, edited_at, deleted_at, reactions, reply_to_id, forward_from_user_id, forward_from_chat_id, forward_from_message_id, forward_date, expires_at, view_once, service FROM msg
+`
+
+type CreateMessageParams struct {
+	ID                   string             `json:"id"`
+	ThreadID             pgtype.Text        `json:"thread_id"`
+	ChatID               string             `json:"chat_id"`
+	SenderID             string             `json:"sender_id"`
+	SenderDeviceID       pgtype.Text        `json:"sender_device_id"`
+	ContentType          string             `json:"content_type"`
+	Ciphertext           []byte             `json:"ciphertext"`
+	ClientMessageID      pgtype.Text        `json:"client_message_id"`
+	ReplyToID            pgtype.Text        `json:"reply_to_id"`
+	ForwardFromUserID    pgtype.Text        `json:"forward_from_user_id"`
+	ForwardFromChatID    pgtype.Text        `json:"forward_from_chat_id"`
+	ForwardFromMessageID pgtype.Text        `json:"forward_from_message_id"`
+	ForwardDate          pgtype.Timestamptz `json:"forward_date"`
+	TtlSeconds           pgtype.Int4        `json:"ttl_seconds"`
+	ViewOnce             bool               `json:"view_once"`
+	Service              json.RawMessage    `json:"service"`
+	DeviceCiphertexts    [][]byte           `json:"device_ciphertexts"`
+	DeviceIds            []string           `json:"device_ids"`
+	PollOptionCount      pgtype.Int2        `json:"poll_option_count"`
+	PollMultipleChoice   bool               `json:"poll_multiple_choice"`
+	PollAnonymous        bool               `json:"poll_anonymous"`
+	PollCorrectOption    pgtype.Int2        `json:"poll_correct_option"`
+	PollClosesAt         pgtype.Timestamptz `json:"poll_closes_at"`
+	MentionedUserIds     []string           `json:"mentioned_user_ids"`
+	MentionedUsernames   []string           `json:"mentioned_usernames"`
+}
+
+type CreateMessageRow struct {
+	ID                   string             `json:"id"`
+	ChatID               string             `json:"chat_id"`
+	Seq                  int64              `json:"seq"`
+	SenderID             pgtype.Text        `json:"sender_id"`
+	SenderDeviceID       pgtype.Text        `json:"sender_device_id"`
+	ThreadID             pgtype.Text        `json:"thread_id"`
+	ThreadSeq            pgtype.Int8        `json:"thread_seq"`
+	ContentType          string             `json:"content_type"`
+	Ciphertext           []byte             `json:"ciphertext"`
+	CreatedAt            pgtype.Timestamptz `json:"created_at"`
+	ClientMessageID      pgtype.Text        `json:"client_message_id"`
+	Version              int32              `json:"version"`
+	EditedAt             pgtype.Timestamptz `json:"edited_at"`
+	DeletedAt            pgtype.Timestamptz `json:"deleted_at"`
+	Reactions            json.RawMessage    `json:"reactions"`
+	ReplyToID            pgtype.Text        `json:"reply_to_id"`
+	ForwardFromUserID    pgtype.Text        `json:"forward_from_user_id"`
+	ForwardFromChatID    pgtype.Text        `json:"forward_from_chat_id"`
+	ForwardFromMessageID pgtype.Text        `json:"forward_from_message_id"`
+	ForwardDate          pgtype.Timestamptz `json:"forward_date"`
+	ExpiresAt            pgtype.Timestamptz `json:"expires_at"`
+	ViewOnce             bool               `json:"view_once"`
+	Service              json.RawMessage    `json:"service"`
+}
+
+// Stores a message with the chat's next seq and its per-device payloads in
+// one statement. With a thread_id the message is also appended to that
+// thread; nothing is written and no row is returned when the thread is
+// missing or closed. The sender's read position moves past the message.
+// With ttl_seconds the message expires that long after it is sent.
+// With poll_option_count the message carries a poll. Members named in
+// mentioned_user_ids or mentioned_usernames, and the sender of the message
+// replied to, get a mention unless they have a block with the sender.
+func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (CreateMessageRow, error) {
+	row := q.db.QueryRow(ctx, createMessage,
+		arg.ID,
+		arg.ThreadID,
+		arg.ChatID,
+		arg.SenderID,
+		arg.SenderDeviceID,
+		arg.ContentType,
+		arg.Ciphertext,
+		arg.ClientMessageID,
+		arg.ReplyToID,
+		arg.ForwardFromUserID,
+		arg.ForwardFromChatID,
+		arg.ForwardFromMessageID,
+		arg.ForwardDate,
+		arg.TtlSeconds,
+		arg.ViewOnce,
+		arg.Service,
+		arg.DeviceCiphertexts,
+		arg.DeviceIds,
+		arg.PollOptionCount,
+		arg.PollMultipleChoice,
+		arg.PollAnonymous,
+		arg.PollCorrectOption,
+		arg.PollClosesAt,
+		arg.MentionedUserIds,
+		arg.MentionedUsernames,
+	)
+	var i CreateMessageRow
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.Seq,
+		&i.SenderID,
+		&i.SenderDeviceID,
+		&i.ThreadID,
+		&i.ThreadSeq,
+		&i.ContentType,
+		&i.Ciphertext,
+		&i.CreatedAt,
+		&i.ClientMessageID,
+		&i.Version,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.Reactions,
+		&i.ReplyToID,
+		&i.ForwardFromUserID,
+		&i.ForwardFromChatID,
+		&i.ForwardFromMessageID,
+		&i.ForwardDate,
+		&i.ExpiresAt,
+		&i.ViewOnce,
+		&i.Service,
+	)
+	return i, err
+}
+
+const deleteMessagesForEveryone = `-- name: DeleteMessagesForEveryone :many
+WITH target AS (
+    SELECT id FROM messages
+    WHERE messages.chat_id = $1 AND messages.id = ANY($2::text[]) AND messages.deleted_at IS NULL
+    FOR UPDATE
+), payloads AS (
+    DELETE FROM message_device_payloads p USING target WHERE p.message_id = target.id
+), edits AS (
+    DELETE FROM message_edits e USING target WHERE e.message_id = target.id
+), reactions AS (
+    DELETE FROM message_reactions r USING target WHERE r.message_id = target.id
+), pins AS (
+    DELETE FROM pinned_messages pm USING target WHERE pm.message_id = target.id
+), polls AS (
+    DELETE FROM polls pl USING target WHERE pl.message_id = target.id
+), mentions AS (
+    DELETE FROM message_mentions mm USING target WHERE mm.message_id = target.id
+)
+UPDATE messages m
+SET ciphertext = ''::bytea, reactions = '{}', deleted_at = NOW()
+FROM target
+WHERE m.id = target.id
+RETURNING m.id, m.chat_id, m.seq, m.sender_id, m.sender_device_id, m.thread_id, m.thread_seq, m.content_type, m.ciphertext, m.created_at, m.client_message_id, m.version, m.edited_at, m.deleted_at, m.reactions, m.reply_to_id, m.forward_from_user_id, m.forward_from_chat_id, m.forward_from_message_id, m.forward_date, m.expires_at, m.view_once, m.service
+`
+
+type DeleteMessagesForEveryoneParams struct {
+	ChatID string   `json:"chat_id"`
+	Ids    []string `json:"ids"`
+}
+
+// Tombstones messages: the ciphertext is wiped, the per-device payloads,
+// edit history, reactions, polls and mentions are deleted and the messages
+// are unpinned.
+// Messages that are already tombstones are skipped.
+func (q *Queries) DeleteMessagesForEveryone(ctx context.Context, arg DeleteMessagesForEveryoneParams) ([]Message, error) {
+	rows, err := q.db.Query(ctx, deleteMessagesForEveryone, arg.ChatID, arg.Ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Message{}
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChatID,
+			&i.Seq,
+			&i.SenderID,
+			&i.SenderDeviceID,
+			&i.ThreadID,
+			&i.ThreadSeq,
+			&i.ContentType,
+			&i.Ciphertext,
+			&i.CreatedAt,
+			&i.ClientMessageID,
+			&i.Version,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.Reactions,
+			&i.ReplyToID,
+			&i.ForwardFromUserID,
+			&i.ForwardFromChatID,
+			&i.ForwardFromMessageID,
+			&i.ForwardDate,
+			&i.ExpiresAt,
+			&i.ViewOnce,
+			&i.Service,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const editMessage = `-- name: EditMessage :one
+WITH old AS (
+    SELECT id, version, ciphertext, COALESCE(edited_at, created_at) AS written_at
+    FROM messages
+    WHERE messages.chat_id = $1 AND messages.id = $2
+      AND messages.sender_id = $3::text AND messages.created_at > $4
+      AND messages.deleted_at IS NULL
+    FOR UPDATE
+), archived AS (
+    INSERT INTO message_edits (message_id, version, ciphertext, created_at)
+    SELECT id, version, ciphertext, written_at FROM old
+), edited AS (
+    UPDATE messages m
+    SET ciphertext = $5, version = m.version + 1, edited_at = NOW(),
+        sender_device_id = $6::text
+    FROM old
+    WHERE m.id = old.id
+    RETURNING m.id, m.chat_id, m.seq, m.sender_id, m.sender_device_id, m.thread_id, m.thread_seq, m.content_type, m.ciphertext, m.created_at, m.client_message_id, m.version, m.edited_at, m.deleted_at, m.reactions, m.reply_to_id, m.forward_from_user_id, m.forward_from_chat_id, m.forward_from_message_id, m.forward_date, m.expires_at, m.view_once, m.service
+), payloads AS (
+    INSERT INTO message_device_payloads (message_id, version, device_id, ciphertext)
+    SELECT edited.id, edited.version, d.device_id, ($7::bytea[])[d.ord]
+    FROM edited, unnest($8::text[]) WITH ORDINALITY AS d(device_id, ord)
+)
+SELECT id, chat_id, seq, sender_id, sender_device_id, thread_id, thread_seq, content_type, ciphertext, created_at, client_message_id, version, edited_at, deleted_at, reactions, reply_to_id, forward_from_user_id, forward_from_chat_id, forward_from_message_id, forward_date, expires_at, view_once, service FROM edited
+`
+
+type EditMessageParams struct {
+	ChatID            string             `json:"chat_id"`
+	ID                string             `json:"id"`
+	SenderID          string             `json:"sender_id"`
+	EditableAfter     pgtype.Timestamptz `json:"editable_after"`
+	Ciphertext        []byte             `json:"ciphertext"`
+	SenderDeviceID    string             `json:"sender_device_id"`
+	DeviceCiphertexts [][]byte           `json:"device_ciphertexts"`
+	DeviceIds         []string           `json:"device_ids"`
+}
+
+type EditMessageRow struct {
+	ID                   string             `json:"id"`
+	ChatID               string             `json:"chat_id"`
+	Seq                  int64              `json:"seq"`
+	SenderID             pgtype.Text        `json:"sender_id"`
+	SenderDeviceID       pgtype.Text        `json:"sender_device_id"`
+	ThreadID             pgtype.Text        `json:"thread_id"`
+	ThreadSeq            pgtype.Int8        `json:"thread_seq"`
+	ContentType          string             `json:"content_type"`
+	Ciphertext           []byte             `json:"ciphertext"`
+	CreatedAt            pgtype.Timestamptz `json:"created_at"`
+	ClientMessageID      pgtype.Text        `json:"client_message_id"`
+	Version              int32              `json:"version"`
+	EditedAt             pgtype.Timestamptz `json:"edited_at"`
+	DeletedAt            pgtype.Timestamptz `json:"deleted_at"`
+	Reactions            json.RawMessage    `json:"reactions"`
+	ReplyToID            pgtype.Text        `json:"reply_to_id"`
+	ForwardFromUserID    pgtype.Text        `json:"forward_from_user_id"`
+	ForwardFromChatID    pgtype.Text        `json:"forward_from_chat_id"`
+	ForwardFromMessageID pgtype.Text        `json:"forward_from_message_id"`
+	ForwardDate          pgtype.Timestamptz `json:"forward_date"`
+	ExpiresAt            pgtype.Timestamptz `json:"expires_at"`
+	ViewOnce             bool               `json:"view_once"`
+	Service              json.RawMessage    `json:"service"`
+}
+
+// Replaces a message's ciphertext and per-device payloads with a new
+// version and archives the previous ciphertext. Only the sender can edit,
+// and only messages sent after editable_after; otherwise no row is returned.
+func (q *Queries) EditMessage(ctx context.Context, arg EditMessageParams) (EditMessageRow, error) {
+	row := q.db.QueryRow(ctx, editMessage,
+		arg.ChatID,
+		arg.ID,
+		arg.SenderID,
+		arg.EditableAfter,
+		arg.Ciphertext,
+		arg.SenderDeviceID,
+		arg.DeviceCiphertexts,
+		arg.DeviceIds,
+	)
+	var i EditMessageRow
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.Seq,
+		&i.SenderID,
+		&i.SenderDeviceID,
+		&i.ThreadID,
+		&i.ThreadSeq,
+		&i.ContentType,
+		&i.Ciphertext,
+		&i.CreatedAt,
+		&i.ClientMessageID,
+		&i.Version,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.Reactions,
+		&i.ReplyToID,
+		&i.ForwardFromUserID,
+		&i.ForwardFromChatID,
+		&i.ForwardFromMessageID,
+		&i.ForwardDate,
+		&i.ExpiresAt,
+		&i.ViewOnce,
+		&i.Service,
+	)
+	return i, err
+}
+
+const filterMemberDevices = `-- name: FilterMemberDevices :many
+SELECT d.id
+FROM devices d
+JOIN chat_members m ON m.user_id = d.user_id AND m.chat_id = $1
+WHERE d.id = ANY($2::text[]) AND d.revoked_at IS NULL
+`
+
+type FilterMemberDevicesParams struct {
+	ChatID    string   `json:"chat_id"`
+	DeviceIds []string `json:"device_ids"`
+}
+
+// Returns the given device IDs that are active devices of the chat's members.
+func (q *Queries) FilterMemberDevices(ctx context.Context, arg FilterMemberDevicesParams) ([]string, error) {
+	rows, err := q.db.Query(ctx, filterMemberDevices, arg.ChatID, arg.DeviceIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getActiveUserDevice = `-- name: GetActiveUserDevice :one
+SELECT id, user_id, name, platform, push_token, created_at, revoked_at FROM devices
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+`
+
+type GetActiveUserDeviceParams struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) GetActiveUserDevice(ctx context.Context, arg GetActiveUserDeviceParams) (Device, error) {
+	row := q.db.QueryRow(ctx, getActiveUserDevice, arg.ID, arg.UserID)
+	var i Device
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.Platform,
+		&i.PushToken,
+		&i.CreatedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getMessage = `-- name: GetMessage :one
+SELECT id, chat_id, seq, sender_id, sender_device_id, thread_id, thread_seq, content_type, ciphertext, created_at, client_message_id, version, edited_at, deleted_at, reactions, reply_to_id, forward_from_user_id, forward_from_chat_id, forward_from_message_id, forward_date, expires_at, view_once, service FROM messages
+WHERE chat_id = $1 AND id = $2
+`
+
+type GetMessageParams struct {
+	ChatID string `json:"chat_id"`
+	ID     string `json:"id"`
+}
+
+func (q *Queries) GetMessage(ctx context.Context, arg GetMessageParams) (Message, error) {
+	row := q.db.QueryRow(ctx, getMessage, arg.ChatID, arg.ID)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.Seq,
+		&i.SenderID,
+		&i.SenderDeviceID,
+		&i.ThreadID,
+		&i.ThreadSeq,
+		&i.ContentType,
+		&i.Ciphertext,
+		&i.CreatedAt,
+		&i.ClientMessageID,
+		&i.Version,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.Reactions,
+		&i.ReplyToID,
+		&i.ForwardFromUserID,
+		&i.ForwardFromChatID,
+		&i.ForwardFromMessageID,
+		&i.ForwardDate,
+		&i.ExpiresAt,
+		&i.ViewOnce,
+		&i.Service,
+	)
+	return i, err
+}
+
+const getMessageByClientID = `-- name: GetMessageByClientID :one
+SELECT id, chat_id, seq, sender_id, sender_device_id, thread_id, thread_seq, content_type, ciphertext, created_at, client_message_id, version, edited_at, deleted_at, reactions, reply_to_id, forward_from_user_id, forward_from_chat_id, forward_from_message_id, forward_date, expires_at, view_once, service FROM messages
+WHERE chat_id = $1 AND sender_id = $2 AND client_message_id = $3
+`
+
+type GetMessageByClientIDParams struct {
+	ChatID          string      `json:"chat_id"`
+	SenderID        pgtype.Text `json:"sender_id"`
+	ClientMessageID pgtype.Text `json:"client_message_id"`
+}
+
+func (q *Queries) GetMessageByClientID(ctx context.Context, arg GetMessageByClientIDParams) (Message, error) {
+	row := q.db.QueryRow(ctx, getMessageByClientID, arg.ChatID, arg.SenderID, arg.ClientMessageID)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.Seq,
+		&i.SenderID,
+		&i.SenderDeviceID,
+		&i.ThreadID,
+		&i.ThreadSeq,
+		&i.ContentType,
+		&i.Ciphertext,
+		&i.CreatedAt,
+		&i.ClientMessageID,
+		&i.Version,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.Reactions,
+		&i.ReplyToID,
+		&i.ForwardFromUserID,
+		&i.ForwardFromChatID,
+		&i.ForwardFromMessageID,
+		&i.ForwardDate,
+		&i.ExpiresAt,
+		&i.ViewOnce,
+		&i.Service,
+	)
+	return i, err
+}
+
+const getMessageForDevice = `-- name: GetMessageForDevice :one
+SELECT m.id, m.chat_id, m.seq, m.sender_id, m.sender_device_id, m.thread_id, m.thread_seq, m.content_type, m.ciphertext, m.created_at, m.client_message_id, m.version, m.edited_at, m.deleted_at, m.reactions, m.reply_to_id, m.forward_from_user_id, m.forward_from_chat_id, m.forward_from_message_id, m.forward_date, m.expires_at, m.view_once, m.service, p.ciphertext AS device_ciphertext,
+       ARRAY(SELECT r.emoji FROM message_reactions r WHERE r.message_id = m.id AND r.user_id = $1::text ORDER BY r.created_at)::text[] AS my_reactions
+FROM messages m
+LEFT JOIN devices d ON d.id = $2::text AND d.user_id = $1
+LEFT JOIN message_device_payloads p ON p.message_id = m.id AND p.version = m.version AND p.device_id = d.id
+WHERE m.chat_id = $3 AND m.id = $4
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.user_id = $1 AND h.message_id = m.id)
+  AND m.seq > COALESCE((SELECT s.cleared_seq FROM chat_user_states s WHERE s.user_id = $1 AND s.chat_id = m.chat_id), 0)
+`
+
+type GetMessageForDeviceParams struct {
+	UserID   string      `json:"user_id"`
+	DeviceID pgtype.Text `json:"device_id"`
+	ChatID   string      `json:"chat_id"`
+	ID       string      `json:"id"`
+}
+
+type GetMessageForDeviceRow struct {
+	ID                   string             `json:"id"`
+	ChatID               string             `json:"chat_id"`
+	Seq                  int64              `json:"seq"`
+	SenderID             pgtype.Text        `json:"sender_id"`
+	SenderDeviceID       pgtype.Text        `json:"sender_device_id"`
+	ThreadID             pgtype.Text        `json:"thread_id"`
+	ThreadSeq            pgtype.Int8        `json:"thread_seq"`
+	ContentType          string             `json:"content_type"`
+	Ciphertext           []byte             `json:"ciphertext"`
+	CreatedAt            pgtype.Timestamptz `json:"created_at"`
+	ClientMessageID      pgtype.Text        `json:"client_message_id"`
+	Version              int32              `json:"version"`
+	EditedAt             pgtype.Timestamptz `json:"edited_at"`
+	DeletedAt            pgtype.Timestamptz `json:"deleted_at"`
+	Reactions            json.RawMessage    `json:"reactions"`
+	ReplyToID            pgtype.Text        `json:"reply_to_id"`
+	ForwardFromUserID    pgtype.Text        `json:"forward_from_user_id"`
+	ForwardFromChatID    pgtype.Text        `json:"forward_from_chat_id"`
+	ForwardFromMessageID pgtype.Text        `json:"forward_from_message_id"`
+	ForwardDate          pgtype.Timestamptz `json:"forward_date"`
+	ExpiresAt            pgtype.Timestamptz `json:"expires_at"`
+	ViewOnce             bool               `json:"view_once"`
+	Service              json.RawMessage    `json:"service"`
+	DeviceCiphertext     []byte             `json:"device_ciphertext"`
+	MyReactions          []string           `json:"my_reactions"`
+}
+
+// Returns a message with the payload addressed to one of the user's devices.
+// Messages the user deleted for themselves or cleared are not returned.
+func (q *Queries) GetMessageForDevice(ctx context.Context, arg GetMessageForDeviceParams) (GetMessageForDeviceRow, error) {
+	row := q.db.QueryRow(ctx, getMessageForDevice,
+		arg.UserID,
+		arg.DeviceID,
+		arg.ChatID,
+		arg.ID,
+	)
+	var i GetMessageForDeviceRow
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.Seq,
+		&i.SenderID,
+		&i.SenderDeviceID,
+		&i.ThreadID,
+		&i.ThreadSeq,
+		&i.ContentType,
+		&i.Ciphertext,
+		&i.CreatedAt,
+		&i.ClientMessageID,
+		&i.Version,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.Reactions,
+		&i.ReplyToID,
+		&i.ForwardFromUserID,
+		&i.ForwardFromChatID,
+		&i.ForwardFromMessageID,
+		&i.ForwardDate,
+		&i.ExpiresAt,
+		&i.ViewOnce,
+		&i.Service,
+		&i.DeviceCiphertext,
+		&i.MyReactions,
+	)
+	return i, err
+}
+
+const hideMessages = `-- name: HideMessages :exec
+INSERT INTO message_hidden (user_id, message_id, chat_id)
+SELECT $1::text, m.id, m.chat_id FROM messages m
+WHERE m.chat_id = $2::text AND m.id = ANY($3::text[])
+ON CONFLICT (user_id, message_id) DO NOTHING
+`
+
+type HideMessagesParams struct {
+	UserID string   `json:"user_id"`
+	ChatID string   `json:"chat_id"`
+	Ids    []string `json:"ids"`
+}
+
+// Deletes messages for one user only.
+func (q *Queries) HideMessages(ctx context.Context, arg HideMessagesParams) error {
+	_, err := q.db.Exec(ctx, hideMessages, arg.UserID, arg.ChatID, arg.Ids)
+	return err
+}
+
+const listMessageEdits = `-- name: ListMessageEdits :many
+SELECT e.message_id, e.version, e.ciphertext, e.created_at, p.ciphertext AS device_ciphertext
+FROM message_edits e
+LEFT JOIN devices d ON d.id = $1::text AND d.user_id = $2
+LEFT JOIN message_device_payloads p ON p.message_id = e.message_id AND p.version = e.version AND p.device_id = d.id
+WHERE e.message_id = $3
+ORDER BY e.version
+`
+
+type ListMessageEditsParams struct {
+	DeviceID  pgtype.Text `json:"device_id"`
+	UserID    string      `json:"user_id"`
+	MessageID string      `json:"message_id"`
+}
+
+type ListMessageEditsRow struct {
+	MessageID        string             `json:"message_id"`
+	Version          int32              `json:"version"`
+	Ciphertext       []byte             `json:"ciphertext"`
+	CreatedAt        pgtype.Timestamptz `json:"created_at"`
+	DeviceCiphertext []byte             `json:"device_ciphertext"`
+}
+
+// Returns the earlier versions of a message, oldest first, with the payload
+// each addressed to one of the reader's devices.
+func (q *Queries) ListMessageEdits(ctx context.Context, arg ListMessageEditsParams) ([]ListMessageEditsRow, error) {
+	rows, err := q.db.Query(ctx, listMessageEdits, arg.DeviceID, arg.UserID, arg.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMessageEditsRow{}
+	for rows.Next() {
+		var i ListMessageEditsRow
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.Version,
+			&i.Ciphertext,
+			&i.CreatedAt,
+			&i.DeviceCiphertext,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessagesAfter = `-- name: ListMessagesAfter :many
+SELECT m.id, m.chat_id, m.seq, m.sender_id, m.sender_device_id, m.thread_id, m.thread_seq, m.content_type, m.ciphertext, m.created_at, m.client_message_id, m.version, m.edited_at, m.deleted_at, m.reactions, m.reply_to_id, m.forward_from_user_id, m.forward_from_chat_id, m.forward_from_message_id, m.forward_date, m.expires_at, m.view_once, m.service, p.ciphertext AS device_ciphertext,
+       ARRAY(SELECT r.emoji FROM message_reactions r WHERE r.message_id = m.id AND r.user_id = $1::text ORDER BY r.created_at)::text[] AS my_reactions
+FROM messages m
+LEFT JOIN devices d ON d.id = $2::text AND d.user_id = $1
+LEFT JOIN message_device_payloads p ON p.message_id = m.id AND p.version = m.version AND p.device_id = d.id
+WHERE m.chat_id = $3
+  AND m.seq > $4
+  AND ($5::text IS NULL OR m.thread_id = $5::text)
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.user_id = $1 AND h.message_id = m.id)
+  AND m.seq > COALESCE((SELECT s.cleared_seq FROM chat_user_states s WHERE s.user_id = $1 AND s.chat_id = m.chat_id), 0)
+ORDER BY m.seq ASC
+LIMIT $6
+`
+
+type ListMessagesAfterParams struct {
+	UserID   string      `json:"user_id"`
+	DeviceID pgtype.Text `json:"device_id"`
+	ChatID   string      `json:"chat_id"`
+	AfterSeq int64       `json:"after_seq"`
+	ThreadID pgtype.Text `json:"thread_id"`
+	Lim      int32       `json:"lim"`
+}
+
+type ListMessagesAfterRow struct {
+	ID                   string             `json:"id"`
+	ChatID               string             `json:"chat_id"`
+	Seq                  int64              `json:"seq"`
+	SenderID             pgtype.Text        `json:"sender_id"`
+	SenderDeviceID       pgtype.Text        `json:"sender_device_id"`
+	ThreadID             pgtype.Text        `json:"thread_id"`
+	ThreadSeq            pgtype.Int8        `json:"thread_seq"`
+	ContentType          string             `json:"content_type"`
+	Ciphertext           []byte             `json:"ciphertext"`
+	CreatedAt            pgtype.Timestamptz `json:"created_at"`
+	ClientMessageID      pgtype.Text        `json:"client_message_id"`
+	Version              int32              `json:"version"`
+	EditedAt             pgtype.Timestamptz `json:"edited_at"`
+	DeletedAt            pgtype.Timestamptz `json:"deleted_at"`
+	Reactions            json.RawMessage    `json:"reactions"`
+	ReplyToID            pgtype.Text        `json:"reply_to_id"`
+	ForwardFromUserID    pgtype.Text        `json:"forward_from_user_id"`
+	ForwardFromChatID    pgtype.Text        `json:"forward_from_chat_id"`
+	ForwardFromMessageID pgtype.Text        `json:"forward_from_message_id"`
+	ForwardDate          pgtype.Timestamptz `json:"forward_date"`
+	ExpiresAt            pgtype.Timestamptz `json:"expires_at"`
+	ViewOnce             bool               `json:"view_once"`
+	Service              json.RawMessage    `json:"service"`
+	DeviceCiphertext     []byte             `json:"device_ciphertext"`
+	MyReactions          []string           `json:"my_reactions"`
+}
+
+// Returns messages newer than after_seq, oldest first. See ListMessagesBefore.
+func (q *Queries) ListMessagesAfter(ctx context.Context, arg ListMessagesAfterParams) ([]ListMessagesAfterRow, error) {
+	rows, err := q.db.Query(ctx, listMessagesAfter,
+		arg.UserID,
+		arg.DeviceID,
+		arg.ChatID,
+		arg.AfterSeq,
+		arg.ThreadID,
+		arg.Lim,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMessagesAfterRow{}
+	for rows.Next() {
+		var i ListMessagesAfterRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChatID,
+			&i.Seq,
+			&i.SenderID,
+			&i.SenderDeviceID,
+			&i.ThreadID,
+			&i.ThreadSeq,
+			&i.ContentType,
+			&i.Ciphertext,
+			&i.CreatedAt,
+			&i.ClientMessageID,
+			&i.Version,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.Reactions,
+			&i.ReplyToID,
+			&i.ForwardFromUserID,
+			&i.ForwardFromChatID,
+			&i.ForwardFromMessageID,
+			&i.ForwardDate,
+			&i.ExpiresAt,
+			&i.ViewOnce,
+			&i.Service,
+			&i.DeviceCiphertext,
+			&i.MyReactions,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessagesBefore = `-- name: ListMessagesBefore :many
+SELECT m.id, m.chat_id, m.seq, m.sender_id, m.sender_device_id, m.thread_id, m.thread_seq, m.content_type, m.ciphertext, m.created_at, m.client_message_id, m.version, m.edited_at, m.deleted_at, m.reactions, m.reply_to_id, m.forward_from_user_id, m.forward_from_chat_id, m.forward_from_message_id, m.forward_date, m.expires_at, m.view_once, m.service, p.ciphertext AS device_ciphertext,
+       ARRAY(SELECT r.emoji FROM message_reactions r WHERE r.message_id = m.id AND r.user_id = $1::text ORDER BY r.created_at)::text[] AS my_reactions
+FROM messages m
+LEFT JOIN devices d ON d.id = $2::text AND d.user_id = $1
+LEFT JOIN message_device_payloads p ON p.message_id = m.id AND p.version = m.version AND p.device_id = d.id
+WHERE m.chat_id = $3
+  AND m.seq < $4
+  AND ($5::text IS NULL OR m.thread_id = $5::text)
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.user_id = $1 AND h.message_id = m.id)
+  AND m.seq > COALESCE((SELECT s.cleared_seq FROM chat_user_states s WHERE s.user_id = $1 AND s.chat_id = m.chat_id), 0)
+ORDER BY m.seq DESC
+LIMIT $6
+`
+
+type ListMessagesBeforeParams struct {
+	UserID    string      `json:"user_id"`
+	DeviceID  pgtype.Text `json:"device_id"`
+	ChatID    string      `json:"chat_id"`
+	BeforeSeq int64       `json:"before_seq"`
+	ThreadID  pgtype.Text `json:"thread_id"`
+	Lim       int32       `json:"lim"`
+}
+
+type ListMessagesBeforeRow struct {
+	ID                   string             `json:"id"`
+	ChatID               string             `json:"chat_id"`
+	Seq                  int64              `json:"seq"`
+	SenderID             pgtype.Text        `json:"sender_id"`
+	SenderDeviceID       pgtype.Text        `json:"sender_device_id"`
+	ThreadID             pgtype.Text        `json:"thread_id"`
+	ThreadSeq            pgtype.Int8        `json:"thread_seq"`
+	ContentType          string             `json:"content_type"`
+	Ciphertext           []byte             `json:"ciphertext"`
+	CreatedAt            pgtype.Timestamptz `json:"created_at"`
+	ClientMessageID      pgtype.Text        `json:"client_message_id"`
+	Version              int32              `json:"version"`
+	EditedAt             pgtype.Timestamptz `json:"edited_at"`
+	DeletedAt            pgtype.Timestamptz `json:"deleted_at"`
+	Reactions            json.RawMessage    `json:"reactions"`
+	ReplyToID            pgtype.Text        `json:"reply_to_id"`
+	ForwardFromUserID    pgtype.Text        `json:"forward_from_user_id"`
+	ForwardFromChatID    pgtype.Text        `json:"forward_from_chat_id"`
+	ForwardFromMessageID pgtype.Text        `json:"forward_from_message_id"`
+	ForwardDate          pgtype.Timestamptz `json:"forward_date"`
+	ExpiresAt            pgtype.Timestamptz `json:"expires_at"`
+	ViewOnce             bool               `json:"view_once"`
+	Service              json.RawMessage    `json:"service"`
+	DeviceCiphertext     []byte             `json:"device_ciphertext"`
+	MyReactions          []string           `json:"my_reactions"`
+}
+
+// Returns messages older than before_seq, newest first, with the payload
+// addressed to one of the reader's devices. thread_id narrows the page to
+// one topic or reply thread. Messages the reader deleted for themselves or
+// cleared are skipped; messages deleted for everyone come back as
+// tombstones.
+func (q *Queries) ListMessagesBefore(ctx context.Context, arg ListMessagesBeforeParams) ([]ListMessagesBeforeRow, error) {
+	rows, err := q.db.Query(ctx, listMessagesBefore,
+		arg.UserID,
+		arg.DeviceID,
+		arg.ChatID,
+		arg.BeforeSeq,
+		arg.ThreadID,
+		arg.Lim,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMessagesBeforeRow{}
+	for rows.Next() {
+		var i ListMessagesBeforeRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChatID,
+			&i.Seq,
+			&i.SenderID,
+			&i.SenderDeviceID,
+			&i.ThreadID,
+			&i.ThreadSeq,
+			&i.ContentType,
+			&i.Ciphertext,
+			&i.CreatedAt,
+			&i.ClientMessageID,
+			&i.Version,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.Reactions,
+			&i.ReplyToID,
+			&i.ForwardFromUserID,
+			&i.ForwardFromChatID,
+			&i.ForwardFromMessageID,
+			&i.ForwardDate,
+			&i.ExpiresAt,
+			&i.ViewOnce,
+			&i.Service,
+			&i.DeviceCiphertext,
+			&i.MyReactions,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listThreadMessageIDs = `-- name: ListThreadMessageIDs :many
+SELECT id FROM messages
+WHERE thread_id = $1 AND deleted_at IS NULL
+ORDER BY thread_seq
+`
+
+// The messages of a thread that are not tombstones yet.
+func (q *Queries) ListThreadMessageIDs(ctx context.Context, threadID pgtype.Text) ([]string, error) {
+	rows, err := q.db.Query(ctx, listThreadMessageIDs, threadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listVisibleMessages = `-- name: ListVisibleMessages :many
+SELECT m.id, m.chat_id, m.seq, m.sender_id, m.sender_device_id, m.thread_id, m.thread_seq, m.content_type, m.ciphertext, m.created_at, m.client_message_id, m.version, m.edited_at, m.deleted_at, m.reactions, m.reply_to_id, m.forward_from_user_id, m.forward_from_chat_id, m.forward_from_message_id, m.forward_date, m.expires_at, m.view_once, m.service
+FROM messages m
+WHERE m.chat_id = $1 AND m.id = ANY($2::text[])
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.user_id = $3 AND h.message_id = m.id)
+  AND m.seq > COALESCE((SELECT s.cleared_seq FROM chat_user_states s WHERE s.user_id = $3 AND s.chat_id = m.chat_id), 0)
+`
+
+type ListVisibleMessagesParams struct {
+	ChatID string   `json:"chat_id"`
+	Ids    []string `json:"ids"`
+	UserID string   `json:"user_id"`
+}
+
+// Returns the given messages of a chat that the user can still see.
+func (q *Queries) ListVisibleMessages(ctx context.Context, arg ListVisibleMessagesParams) ([]Message, error) {
+	rows, err := q.db.Query(ctx, listVisibleMessages, arg.ChatID, arg.Ids, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Message{}
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChatID,
+			&i.Seq,
+			&i.SenderID,
+			&i.SenderDeviceID,
+			&i.ThreadID,
+			&i.ThreadSeq,
+			&i.ContentType,
+			&i.Ciphertext,
+			&i.CreatedAt,
+			&i.ClientMessageID,
+			&i.Version,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.Reactions,
+			&i.ReplyToID,
+			&i.ForwardFromUserID,
+			&i.ForwardFromChatID,
+			&i.ForwardFromMessageID,
+			&i.ForwardDate,
+			&i.ExpiresAt,
+			&i.ViewOnce,
+			&i.Service,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeUnreferencedMessages = `-- name: PurgeUnreferencedMessages :many
+WITH target AS (
+    SELECT m.id FROM messages m
+    WHERE m.chat_id = $1 AND m.seq <= $2 AND m.deleted_at IS NULL
+      AND ($3::text[] IS NULL OR m.id = ANY($3::text[]))
+      AND NOT EXISTS (
+          SELECT 1 FROM chat_members cm
+          LEFT JOIN chat_user_states s ON s.user_id = cm.user_id AND s.chat_id = cm.chat_id
+          WHERE cm.chat_id = m.chat_id
+            AND m.seq > COALESCE(s.cleared_seq, 0)
+            AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.user_id = cm.user_id AND h.message_id = m.id)
+      )
+    FOR UPDATE OF m SKIP LOCKED
+), payloads AS (
+    DELETE FROM message_device_payloads p USING target WHERE p.message_id = target.id
+), edits AS (
+    DELETE FROM message_edits e USING target WHERE e.message_id = target.id
+), reactions AS (
+    DELETE FROM message_reactions r USING target WHERE r.message_id = target.id
+), pins AS (
+    DELETE FROM pinned_messages pm USING target WHERE pm.message_id = target.id
+), polls AS (
+    DELETE FROM polls pl USING target WHERE pl.message_id = target.id
+), mentions AS (
+    DELETE FROM message_mentions mm USING target WHERE mm.message_id = target.id
+)
+UPDATE messages m
+SET ciphertext = ''::bytea, reactions = '{}', deleted_at = NOW()
+FROM target
+WHERE m.id = target.id
+RETURNING m.id
+`
+
+type PurgeUnreferencedMessagesParams struct {
+	ChatID string   `json:"chat_id"`
+	MaxSeq int64    `json:"max_seq"`
+	Ids    []string `json:"ids"`
+}
+
+// Tombstones messages of a chat up to max_seq that no member can see any
+// more because every member deleted them for themselves or cleared the
+// history. ids narrows the check to the given messages.
+func (q *Queries) PurgeUnreferencedMessages(ctx context.Context, arg PurgeUnreferencedMessagesParams) ([]string, error) {
+	rows, err := q.db.Query(ctx, purgeUnreferencedMessages, arg.ChatID, arg.MaxSeq, arg.Ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
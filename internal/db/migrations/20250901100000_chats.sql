@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE chat_type AS ENUM ('direct', 'saved');
+
+CREATE TABLE chats (
+    id                TEXT PRIMARY KEY,
+    type              chat_type NOT NULL,
+    -- Canonical key that makes direct and saved-messages chats unique:
+    -- the two sorted user IDs for direct chats, the owner ID for saved messages.
+    direct_key        TEXT UNIQUE,
+    created_by        TEXT REFERENCES users(id) ON DELETE SET NULL,
+    last_activity_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE chat_members (
+    chat_id     TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    user_id     TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    joined_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chat_id, user_id)
+);
+
+CREATE INDEX idx_chat_members_user_id ON chat_members(user_id);
+CREATE INDEX idx_chats_last_activity ON chats(last_activity_at DESC, id DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS chat_members;
+DROP TABLE IF EXISTS chats;
+
+DROP TYPE IF EXISTS chat_type;
+-- +goose StatementEnd
@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TYPE chat_type ADD VALUE IF NOT EXISTS 'group';
+
+CREATE TYPE chat_member_role AS ENUM ('owner', 'admin', 'member');
+
+ALTER TABLE chats
+    ADD COLUMN title        TEXT,
+    ADD COLUMN photo_url    TEXT,
+    ADD COLUMN member_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE chats SET member_count = (
+    SELECT count(*) FROM chat_members WHERE chat_members.chat_id = chats.id
+);
+
+ALTER TABLE chat_members
+    ADD COLUMN role       chat_member_role NOT NULL DEFAULT 'member',
+    ADD COLUMN invited_by TEXT REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_chat_members_chat_id_joined_at ON chat_members(chat_id, joined_at, user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Enum values cannot be dropped, so 'group' stays in chat_type.
+DELETE FROM chats WHERE type = 'group';
+
+DROP INDEX IF EXISTS idx_chat_members_chat_id_joined_at;
+
+ALTER TABLE chat_members
+    DROP COLUMN IF EXISTS invited_by,
+    DROP COLUMN IF EXISTS role;
+
+ALTER TABLE chats
+    DROP COLUMN IF EXISTS member_count,
+    DROP COLUMN IF EXISTS photo_url,
+    DROP COLUMN IF EXISTS title;
+
+DROP TYPE IF EXISTS chat_member_role;
+-- +goose StatementEnd
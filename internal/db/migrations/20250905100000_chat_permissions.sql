@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Permissions are bitsets, see services.Permission for the bit layout.
+ALTER TABLE chats
+    ADD COLUMN member_permissions INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN admin_permissions  INTEGER NOT NULL DEFAULT 0;
+
+-- NULL permissions means the member uses the default of their role.
+ALTER TABLE chat_members
+    ADD COLUMN permissions  INTEGER,
+    ADD COLUMN custom_title TEXT;
+
+-- Backfill the defaults for existing chats.
+UPDATE chats SET member_permissions = 107, admin_permissions = 0 WHERE type IN ('direct', 'saved');
+UPDATE chats SET member_permissions = 71, admin_permissions = 127 WHERE type = 'group';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE chat_members
+    DROP COLUMN IF EXISTS custom_title,
+    DROP COLUMN IF EXISTS permissions;
+
+ALTER TABLE chats
+    DROP COLUMN IF EXISTS admin_permissions,
+    DROP COLUMN IF EXISTS member_permissions;
+-- +goose StatementEnd
@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE join_request_state AS ENUM ('pending', 'approved', 'declined');
+
+CREATE TABLE chat_invite_links (
+    id                TEXT PRIMARY KEY,
+    chat_id           TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    token             TEXT NOT NULL UNIQUE,
+    name              TEXT,
+    created_by        TEXT REFERENCES users(id) ON DELETE SET NULL,
+    expires_at        TIMESTAMPTZ,
+    usage_limit       INTEGER,
+    usage_count       INTEGER NOT NULL DEFAULT 0,
+    requires_approval BOOLEAN NOT NULL DEFAULT FALSE,
+    revoked_at        TIMESTAMPTZ,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE chat_join_requests (
+    chat_id         TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    user_id         TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    invite_link_id  TEXT REFERENCES chat_invite_links(id) ON DELETE SET NULL,
+    state           join_request_state NOT NULL DEFAULT 'pending',
+    decided_by      TEXT REFERENCES users(id) ON DELETE SET NULL,
+    decided_at      TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chat_id, user_id)
+);
+
+-- A NULL until_at is a permanent ban.
+CREATE TABLE chat_bans (
+    chat_id     TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    user_id     TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    banned_by   TEXT REFERENCES users(id) ON DELETE SET NULL,
+    until_at    TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chat_id, user_id)
+);
+
+CREATE INDEX idx_chat_invite_links_chat_id ON chat_invite_links(chat_id, created_at DESC);
+CREATE INDEX idx_chat_join_requests_pending ON chat_join_requests(chat_id, created_at) WHERE state = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS chat_bans;
+DROP TABLE IF EXISTS chat_join_requests;
+DROP TABLE IF EXISTS chat_invite_links;
+
+DROP TYPE IF EXISTS join_request_state;
+-- +goose StatementEnd
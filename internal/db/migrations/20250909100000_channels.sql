@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TYPE chat_type ADD VALUE IF NOT EXISTS 'channel';
+
+-- A channel with a username is public and can be found and joined by it.
+ALTER TABLE chats
+    ADD COLUMN username TEXT;
+
+CREATE UNIQUE INDEX idx_chats_username ON chats(lower(username)) WHERE username IS NOT NULL;
+
+-- One row per subscriber who has seen a post, so each view is counted once.
+CREATE TABLE channel_post_views (
+    chat_id    TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    message_id TEXT NOT NULL,
+    user_id    TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    viewed_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chat_id, message_id, user_id)
+);
+
+-- Denormalized counters so reading view counts does not scan the views.
+CREATE TABLE channel_post_stats (
+    chat_id    TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    message_id TEXT NOT NULL,
+    view_count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (chat_id, message_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Enum values cannot be dropped, so 'channel' stays in chat_type.
+DROP TABLE IF EXISTS channel_post_stats;
+DROP TABLE IF EXISTS channel_post_views;
+
+DELETE FROM chats WHERE type = 'channel';
+
+DROP INDEX IF EXISTS idx_chats_username;
+
+ALTER TABLE chats
+    DROP COLUMN IF EXISTS username;
+-- +goose StatementEnd
@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Forum groups organize their messages into named topics.
+ALTER TABLE chats
+    ADD COLUMN is_forum BOOLEAN NOT NULL DEFAULT false;
+
+-- A thread is either a named forum topic or the replies to a single message.
+CREATE TYPE thread_kind AS ENUM ('topic', 'replies');
+
+CREATE TYPE thread_notify_level AS ENUM ('all', 'mentions', 'none');
+
+-- last_seq counts the messages posted to the thread so that unread counts
+-- are a subtraction against the reader's last_read_seq.
+CREATE TABLE chat_threads (
+    id              TEXT PRIMARY KEY,
+    chat_id         TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    kind            thread_kind NOT NULL,
+    title           TEXT,
+    icon_color      INTEGER,
+    root_message_id TEXT,
+    created_by      TEXT REFERENCES users(id) ON DELETE SET NULL,
+    is_closed       BOOLEAN NOT NULL DEFAULT false,
+    last_seq        BIGINT NOT NULL DEFAULT 0,
+    last_message_id TEXT,
+    last_message_at TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((kind = 'topic') = (title IS NOT NULL)),
+    CHECK ((kind = 'replies') = (root_message_id IS NOT NULL))
+);
+
+CREATE UNIQUE INDEX idx_chat_threads_root_message ON chat_threads(chat_id, root_message_id) WHERE root_message_id IS NOT NULL;
+CREATE INDEX idx_chat_threads_activity ON chat_threads(chat_id, kind, (COALESCE(last_message_at, created_at)) DESC, id DESC);
+
+-- Per-user read position and notification settings of a thread. A missing
+-- row means nothing has been read and notifications follow the chat.
+CREATE TABLE thread_member_states (
+    thread_id     TEXT NOT NULL REFERENCES chat_threads(id) ON DELETE CASCADE,
+    user_id       TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_read_seq BIGINT NOT NULL DEFAULT 0,
+    notify_level  thread_notify_level NOT NULL DEFAULT 'all',
+    muted_until   TIMESTAMPTZ,
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (thread_id, user_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS thread_member_states;
+DROP TABLE IF EXISTS chat_threads;
+DROP TYPE IF EXISTS thread_notify_level;
+DROP TYPE IF EXISTS thread_kind;
+
+ALTER TABLE chats
+    DROP COLUMN IF EXISTS is_forum;
+-- +goose StatementEnd
@@ -0,0 +1,60 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Each user's own view of a chat. A missing row means the defaults: not
+-- pinned, not archived, not muted and not marked unread. muted_until is
+-- 'infinity' for chats muted forever.
+CREATE TABLE chat_user_states (
+    user_id       TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chat_id       TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    pinned_rank   INTEGER,
+    archived      BOOLEAN NOT NULL DEFAULT false,
+    muted_until   TIMESTAMPTZ,
+    marked_unread BOOLEAN NOT NULL DEFAULT false,
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, chat_id)
+);
+
+CREATE INDEX idx_chat_user_states_pinned ON chat_user_states(user_id, pinned_rank) WHERE pinned_rank IS NOT NULL;
+
+-- User-defined chat list folders. Rules select chats by category
+-- (include_rules) and drop them by state (exclude_rules); explicitly
+-- listed chats override the rules.
+CREATE TABLE chat_folders (
+    id                TEXT PRIMARY KEY,
+    user_id           TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title             TEXT NOT NULL,
+    position          INTEGER NOT NULL,
+    include_rules     TEXT[] NOT NULL DEFAULT '{}',
+    exclude_rules     TEXT[] NOT NULL DEFAULT '{}',
+    included_chat_ids TEXT[] NOT NULL DEFAULT '{}',
+    excluded_chat_ids TEXT[] NOT NULL DEFAULT '{}',
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_chat_folders_user_id ON chat_folders(user_id, position);
+
+-- Durable per-user log of changes every device of the user must apply.
+-- seq is gapless per user; user_update_state holds the latest value.
+CREATE TABLE user_update_state (
+    user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    seq     BIGINT NOT NULL
+);
+
+CREATE TABLE user_updates (
+    user_id    TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    seq        BIGINT NOT NULL,
+    type       TEXT NOT NULL,
+    payload    JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, seq)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_updates;
+DROP TABLE IF EXISTS user_update_state;
+DROP TABLE IF EXISTS chat_folders;
+DROP TABLE IF EXISTS chat_user_states;
+-- +goose StatementEnd
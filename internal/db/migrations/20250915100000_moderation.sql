@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Slow mode lets a member send one message per slow_mode_seconds; 0 is off.
+ALTER TABLE chats ADD COLUMN slow_mode_seconds INTEGER NOT NULL DEFAULT 0;
+
+-- restricted_until makes a member read-only until that time. last_sent_at
+-- is when the member last claimed a slow mode slot.
+ALTER TABLE chat_members ADD COLUMN restricted_until TIMESTAMPTZ;
+ALTER TABLE chat_members ADD COLUMN last_sent_at TIMESTAMPTZ;
+
+CREATE INDEX idx_chat_bans_chat_id ON chat_bans(chat_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_chat_bans_chat_id;
+ALTER TABLE chat_members DROP COLUMN IF EXISTS last_sent_at;
+ALTER TABLE chat_members DROP COLUMN IF EXISTS restricted_until;
+ALTER TABLE chats DROP COLUMN IF EXISTS slow_mode_seconds;
+-- +goose StatementEnd
@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Admin actions in groups and channels. Events are never changed once
+-- written; old ones are purged after the configured retention period.
+CREATE TABLE chat_audit_events (
+    id             TEXT PRIMARY KEY,
+    chat_id        TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    actor_id       TEXT REFERENCES users(id) ON DELETE SET NULL,
+    action         TEXT NOT NULL,
+    target_user_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+    target_id      TEXT,
+    before         JSONB,
+    after          JSONB,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_chat_audit_events_chat_id ON chat_audit_events(chat_id, created_at DESC, id DESC);
+CREATE INDEX idx_chat_audit_events_created_at ON chat_audit_events(created_at);
+
+-- Only the user references may change, when the referenced user is deleted.
+CREATE FUNCTION chat_audit_events_immutable() RETURNS trigger AS $$
+BEGIN
+    IF NEW.id IS DISTINCT FROM OLD.id
+       OR NEW.chat_id IS DISTINCT FROM OLD.chat_id
+       OR NEW.action IS DISTINCT FROM OLD.action
+       OR NEW.target_id IS DISTINCT FROM OLD.target_id
+       OR NEW.before IS DISTINCT FROM OLD.before
+       OR NEW.after IS DISTINCT FROM OLD.after
+       OR NEW.created_at IS DISTINCT FROM OLD.created_at
+       OR (NEW.actor_id IS NOT NULL AND NEW.actor_id IS DISTINCT FROM OLD.actor_id)
+       OR (NEW.target_user_id IS NOT NULL AND NEW.target_user_id IS DISTINCT FROM OLD.target_user_id) THEN
+        RAISE EXCEPTION 'chat audit events are immutable';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER chat_audit_events_immutable
+BEFORE UPDATE ON chat_audit_events
+FOR EACH ROW EXECUTE FUNCTION chat_audit_events_immutable();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS chat_audit_events;
+DROP FUNCTION IF EXISTS chat_audit_events_immutable();
+-- +goose StatementEnd
@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+-- last_seq is the seq of the chat's newest message. Sending a message bumps
+-- it under the chat row lock, so seqs are strictly increasing per chat.
+ALTER TABLE chats ADD COLUMN last_seq BIGINT NOT NULL DEFAULT 0;
+
+-- Messages are end-to-end encrypted; the server stores the opaque envelope
+-- and never sees plaintext. thread_seq is the message's position in its
+-- topic or reply thread.
+CREATE TABLE messages (
+    id               TEXT PRIMARY KEY,
+    chat_id          TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    seq              BIGINT NOT NULL,
+    sender_id        TEXT REFERENCES users(id) ON DELETE SET NULL,
+    sender_device_id TEXT REFERENCES devices(id) ON DELETE SET NULL,
+    thread_id        TEXT REFERENCES chat_threads(id) ON DELETE CASCADE,
+    thread_seq       BIGINT,
+    content_type     TEXT NOT NULL,
+    ciphertext       BYTEA NOT NULL,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (chat_id, seq),
+    CHECK ((thread_id IS NULL) = (thread_seq IS NULL))
+);
+
+CREATE INDEX idx_messages_thread ON messages(thread_id, thread_seq) WHERE thread_id IS NOT NULL;
+
+-- Ciphertext encrypted separately for individual recipient devices, for
+-- protocols that do not share one group key.
+CREATE TABLE message_device_payloads (
+    message_id TEXT NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    device_id  TEXT NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    ciphertext BYTEA NOT NULL,
+    PRIMARY KEY (message_id, device_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS message_device_payloads;
+DROP TABLE IF EXISTS messages;
+ALTER TABLE chats DROP COLUMN IF EXISTS last_seq;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Responses to mutating requests sent with an Idempotency-Key header, kept
+-- so that a retried request gets the original response instead of running
+-- twice. status_code is NULL while the first request is still running.
+CREATE TABLE idempotency_keys (
+    user_id       TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    key           TEXT NOT NULL,
+    request_hash  TEXT NOT NULL,
+    status_code   INTEGER,
+    content_type  TEXT,
+    response_body BYTEA,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at    TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX idx_idempotency_keys_expires ON idempotency_keys(expires_at);
+
+-- Client-generated message IDs make message sends idempotent for as long as
+-- the message exists, independent of the Idempotency-Key window.
+ALTER TABLE messages ADD COLUMN client_message_id TEXT;
+CREATE UNIQUE INDEX idx_messages_client_id ON messages(chat_id, sender_id, client_message_id)
+    WHERE client_message_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_messages_client_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS client_message_id;
+DROP TABLE IF EXISTS idempotency_keys;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+-- version counts a message's edits, starting at 1. Per-device payloads are
+-- stored per version so that earlier versions stay readable.
+ALTER TABLE messages ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE messages ADD COLUMN edited_at TIMESTAMPTZ;
+
+ALTER TABLE message_device_payloads ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE message_device_payloads DROP CONSTRAINT message_device_payloads_pkey;
+ALTER TABLE message_device_payloads ADD PRIMARY KEY (message_id, version, device_id);
+
+-- Earlier versions of edited messages. created_at is when the version was
+-- written, i.e. when the message was sent or last edited before.
+CREATE TABLE message_edits (
+    message_id TEXT NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    version    INTEGER NOT NULL,
+    ciphertext BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (message_id, version)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS message_edits;
+DELETE FROM message_device_payloads p USING messages m WHERE p.message_id = m.id AND p.version <> m.version;
+ALTER TABLE message_device_payloads DROP CONSTRAINT message_device_payloads_pkey;
+ALTER TABLE message_device_payloads DROP COLUMN version;
+ALTER TABLE message_device_payloads ADD PRIMARY KEY (message_id, device_id);
+ALTER TABLE messages DROP COLUMN IF EXISTS edited_at;
+ALTER TABLE messages DROP COLUMN IF EXISTS version;
+-- +goose StatementEnd
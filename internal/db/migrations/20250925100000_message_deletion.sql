@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- deleted_at marks a message deleted for everyone. Its ciphertext, device
+-- payloads and edit history are removed; the row stays as a tombstone so
+-- that the chat's seq has no unexplained gaps.
+ALTER TABLE messages ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- Messages a user deleted only for themselves.
+CREATE TABLE message_hidden (
+    user_id    TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message_id TEXT NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    chat_id    TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, message_id)
+);
+
+CREATE INDEX idx_message_hidden_message_id ON message_hidden(message_id);
+
+-- cleared_seq hides the chat's messages up to that seq from the user after
+-- they cleared the history.
+ALTER TABLE chat_user_states ADD COLUMN cleared_seq BIGINT NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE chat_user_states DROP COLUMN IF EXISTS cleared_seq;
+DROP TABLE IF EXISTS message_hidden;
+ALTER TABLE messages DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+-- read_seq is the user's read position in the chat: every message up to
+-- that seq counts as read. Positions are kept per user and chat rather than
+-- per message so that receipts stay cheap in large groups.
+ALTER TABLE chat_user_states ADD COLUMN read_seq BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_chat_user_states_read ON chat_user_states(chat_id, read_seq DESC);
+
+-- Delivery position of each device in each chat: the device has received
+-- every message up to delivered_seq.
+CREATE TABLE device_chat_deliveries (
+    device_id     TEXT NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    chat_id       TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    user_id       TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    delivered_seq BIGINT NOT NULL,
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (device_id, chat_id)
+);
+
+CREATE INDEX idx_device_chat_deliveries_chat ON device_chat_deliveries(chat_id, user_id);
+
+-- Per-user privacy settings. A missing row means the defaults.
+CREATE TABLE user_privacy_settings (
+    user_id       TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    read_receipts BOOLEAN NOT NULL DEFAULT true,
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_privacy_settings;
+DROP TABLE IF EXISTS device_chat_deliveries;
+DROP INDEX IF EXISTS idx_chat_user_states_read;
+ALTER TABLE chat_user_states DROP COLUMN IF EXISTS read_seq;
+-- +goose StatementEnd
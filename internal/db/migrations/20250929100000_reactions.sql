@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- reactions aggregates the message's reactions as {"emoji": count}. It is
+-- kept in step with message_reactions so that history pages carry the
+-- counts without a join.
+ALTER TABLE messages ADD COLUMN reactions JSONB NOT NULL DEFAULT '{}';
+
+CREATE TABLE message_reactions (
+    message_id TEXT NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id    TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    emoji      TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (message_id, user_id, emoji)
+);
+
+CREATE INDEX idx_message_reactions_list ON message_reactions(message_id, created_at, user_id);
+
+-- allowed_reactions limits the emoji members can react with. NULL allows
+-- every emoji and an empty array turns reactions off.
+ALTER TABLE chats ADD COLUMN allowed_reactions TEXT[];
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE chats DROP COLUMN IF EXISTS allowed_reactions;
+DROP TABLE IF EXISTS message_reactions;
+ALTER TABLE messages DROP COLUMN IF EXISTS reactions;
+-- +goose StatementEnd
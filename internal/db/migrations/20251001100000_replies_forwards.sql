@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+-- reply_to_id points at an earlier message of the same chat. Quoted text is
+-- part of the encrypted content; the server only knows the target.
+ALTER TABLE messages ADD COLUMN reply_to_id TEXT REFERENCES messages(id) ON DELETE SET NULL;
+
+-- A forwarded message has forward_date set to the time the original was
+-- sent. The origin columns name the original sender, chat and message;
+-- they are NULL when the original sender hides forwarding attribution.
+-- Channel posts are attributed to the channel only.
+ALTER TABLE messages ADD COLUMN forward_from_user_id    TEXT REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE messages ADD COLUMN forward_from_chat_id    TEXT REFERENCES chats(id) ON DELETE SET NULL;
+ALTER TABLE messages ADD COLUMN forward_from_message_id TEXT REFERENCES messages(id) ON DELETE SET NULL;
+ALTER TABLE messages ADD COLUMN forward_date            TIMESTAMPTZ;
+
+CREATE INDEX idx_messages_reply_to ON messages(reply_to_id) WHERE reply_to_id IS NOT NULL;
+CREATE INDEX idx_messages_forward_from ON messages(forward_from_message_id) WHERE forward_from_message_id IS NOT NULL;
+
+-- forward_attribution links forwarded copies of the user's messages back
+-- to the user.
+ALTER TABLE user_privacy_settings ADD COLUMN forward_attribution BOOLEAN NOT NULL DEFAULT true;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_privacy_settings DROP COLUMN IF EXISTS forward_attribution;
+DROP INDEX IF EXISTS idx_messages_forward_from;
+DROP INDEX IF EXISTS idx_messages_reply_to;
+ALTER TABLE messages DROP COLUMN IF EXISTS forward_date;
+ALTER TABLE messages DROP COLUMN IF EXISTS forward_from_message_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS forward_from_chat_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS forward_from_user_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS reply_to_id;
+-- +goose StatementEnd
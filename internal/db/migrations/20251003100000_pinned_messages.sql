@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Messages pinned in a chat, shown newest pin first. Deleting a message for
+-- everyone unpins it.
+CREATE TABLE pinned_messages (
+    chat_id    TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    message_id TEXT NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    pinned_by  TEXT REFERENCES users(id) ON DELETE SET NULL,
+    pinned_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chat_id, message_id)
+);
+
+CREATE INDEX idx_pinned_messages_order ON pinned_messages(chat_id, pinned_at DESC, message_id DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS pinned_messages;
+-- +goose StatementEnd
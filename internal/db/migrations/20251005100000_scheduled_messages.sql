@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Messages waiting to be sent at send_at, or, with online_user_id, as soon
+-- as that user is next online. The scheduler claims due rows by setting
+-- locked_until; a row whose lease ran out is claimed again. Rows are
+-- deleted once the message is sent; rows that cannot be sent keep the
+-- error in failed_at and error_code until the sender edits or cancels them.
+CREATE TABLE scheduled_messages (
+    id                 TEXT PRIMARY KEY,
+    chat_id            TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    sender_id          TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    sender_device_id   TEXT NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    thread_id          TEXT REFERENCES chat_threads(id) ON DELETE CASCADE,
+    reply_to_id        TEXT REFERENCES messages(id) ON DELETE SET NULL,
+    content_type       TEXT NOT NULL,
+    ciphertext         BYTEA NOT NULL,
+    device_ids         TEXT[] NOT NULL DEFAULT '{}',
+    device_ciphertexts BYTEA[] NOT NULL DEFAULT '{}',
+    send_at            TIMESTAMPTZ,
+    online_user_id     TEXT REFERENCES users(id) ON DELETE CASCADE,
+    locked_until       TIMESTAMPTZ,
+    failed_at          TIMESTAMPTZ,
+    error_code         TEXT,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (send_at IS NOT NULL OR online_user_id IS NOT NULL)
+);
+
+CREATE INDEX idx_scheduled_messages_sender ON scheduled_messages(sender_id, chat_id, send_at);
+CREATE INDEX idx_scheduled_messages_due ON scheduled_messages(send_at) WHERE failed_at IS NULL;
+CREATE INDEX idx_scheduled_messages_online ON scheduled_messages(online_user_id) WHERE online_user_id IS NOT NULL AND failed_at IS NULL;
+
+-- When each user was last seen connected, shared by all server instances.
+CREATE TABLE user_presence (
+    user_id        TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    last_online_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_presence;
+DROP TABLE IF EXISTS scheduled_messages;
+-- +goose StatementEnd
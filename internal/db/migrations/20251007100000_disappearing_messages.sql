@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- message_ttl_seconds makes new messages of the chat disappear that long
+-- after they are sent. NULL turns the timer off.
+ALTER TABLE chats ADD COLUMN message_ttl_seconds INTEGER;
+
+-- expires_at is when the reaper deletes the message for everyone. A
+-- view_once message is also deleted for each recipient once they viewed
+-- it.
+ALTER TABLE messages ADD COLUMN expires_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN view_once BOOLEAN NOT NULL DEFAULT false;
+
+-- service describes a message written by the server itself, such as a
+-- timer change, as plain JSON. Service messages have no ciphertext.
+ALTER TABLE messages ADD COLUMN service JSONB;
+
+CREATE INDEX idx_messages_expiry ON messages(expires_at) WHERE expires_at IS NOT NULL AND deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_messages_expiry;
+ALTER TABLE messages DROP COLUMN IF EXISTS service;
+ALTER TABLE messages DROP COLUMN IF EXISTS view_once;
+ALTER TABLE messages DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE chats DROP COLUMN IF EXISTS message_ttl_seconds;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Polls attached to poll messages. The option texts live in the message
+-- ciphertext; the server only knows how many options there are, so votes
+-- refer to options by index.
+CREATE TABLE polls (
+    message_id      TEXT PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    chat_id         TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    option_count    SMALLINT NOT NULL CHECK (option_count > 1),
+    multiple_choice BOOLEAN NOT NULL DEFAULT FALSE,
+    anonymous       BOOLEAN NOT NULL DEFAULT TRUE,
+    -- correct_option turns the poll into a quiz.
+    correct_option  SMALLINT CHECK (correct_option >= 0 AND correct_option < option_count),
+    closes_at       TIMESTAMPTZ,
+    closed_at       TIMESTAMPTZ,
+    CHECK (correct_option IS NULL OR NOT multiple_choice)
+);
+
+-- One row per voter holds all of their choices, so changing a vote is a
+-- single upsert.
+CREATE TABLE poll_votes (
+    message_id TEXT NOT NULL REFERENCES polls(message_id) ON DELETE CASCADE,
+    user_id    TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    options    SMALLINT[] NOT NULL,
+    voted_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (message_id, user_id)
+);
+
+CREATE INDEX idx_poll_votes_order ON poll_votes(message_id, voted_at, user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS poll_votes;
+DROP TABLE IF EXISTS polls;
+-- +goose StatementEnd
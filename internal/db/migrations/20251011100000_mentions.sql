@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Users mentioned in a message or replied to. Encrypted messages carry
+-- their mentions as plaintext metadata. A mention is unread until the user
+-- reads the chat past it or marks the chat's mentions read.
+CREATE TABLE message_mentions (
+    user_id    TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message_id TEXT NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    chat_id    TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    seq        BIGINT NOT NULL,
+    is_reply   BOOLEAN NOT NULL DEFAULT FALSE,
+    read       BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, message_id)
+);
+
+CREATE INDEX idx_message_mentions_chat ON message_mentions(user_id, chat_id, seq) WHERE NOT read;
+CREATE INDEX idx_message_mentions_feed ON message_mentions(user_id, created_at DESC, message_id DESC);
+
+-- Whether mentions still notify while the chat is muted.
+ALTER TABLE chat_user_states ADD COLUMN mention_notifications BOOLEAN NOT NULL DEFAULT TRUE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE chat_user_states DROP COLUMN IF EXISTS mention_notifications;
+DROP TABLE IF EXISTS message_mentions;
+-- +goose StatementEnd
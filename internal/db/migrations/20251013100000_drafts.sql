@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- One draft per user, chat and optional thread, synced across the user's
+-- devices. The content is an opaque, usually encrypted blob; an empty blob
+-- without a reply target is a cleared draft that still records when it was
+-- cleared. The draft with the newest client timestamp wins.
+CREATE TABLE drafts (
+    user_id           TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chat_id           TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    thread_id         TEXT REFERENCES chat_threads(id) ON DELETE CASCADE,
+    ciphertext        BYTEA NOT NULL,
+    reply_to_id       TEXT,
+    client_updated_at TIMESTAMPTZ NOT NULL,
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_drafts_key ON drafts(user_id, chat_id, (COALESCE(thread_id, '')));
+CREATE INDEX idx_drafts_user ON drafts(user_id, updated_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS drafts;
+-- +goose StatementEnd
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Deleted topics are kept, closed, so that the messages, scheduled
+-- messages and drafts posted to them keep pointing at a thread. Deleting a
+-- topic tombstones its messages, fails its scheduled messages and clears
+-- its drafts, and tells their owners, instead of cascading silently.
+ALTER TABLE chat_threads ADD COLUMN deleted_at TIMESTAMPTZ;
+
+ALTER TABLE messages DROP CONSTRAINT messages_thread_id_fkey,
+    ADD CONSTRAINT messages_thread_id_fkey
+    FOREIGN KEY (thread_id) REFERENCES chat_threads(id) ON DELETE RESTRICT;
+
+ALTER TABLE scheduled_messages DROP CONSTRAINT scheduled_messages_thread_id_fkey,
+    ADD CONSTRAINT scheduled_messages_thread_id_fkey
+    FOREIGN KEY (thread_id) REFERENCES chat_threads(id) ON DELETE RESTRICT;
+
+ALTER TABLE drafts DROP CONSTRAINT drafts_thread_id_fkey,
+    ADD CONSTRAINT drafts_thread_id_fkey
+    FOREIGN KEY (thread_id) REFERENCES chat_threads(id) ON DELETE RESTRICT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE drafts DROP CONSTRAINT drafts_thread_id_fkey,
+    ADD CONSTRAINT drafts_thread_id_fkey
+    FOREIGN KEY (thread_id) REFERENCES chat_threads(id) ON DELETE CASCADE;
+
+ALTER TABLE scheduled_messages DROP CONSTRAINT scheduled_messages_thread_id_fkey,
+    ADD CONSTRAINT scheduled_messages_thread_id_fkey
+    FOREIGN KEY (thread_id) REFERENCES chat_threads(id) ON DELETE CASCADE;
+
+ALTER TABLE messages DROP CONSTRAINT messages_thread_id_fkey,
+    ADD CONSTRAINT messages_thread_id_fkey
+    FOREIGN KEY (thread_id) REFERENCES chat_threads(id) ON DELETE CASCADE;
+
+DELETE FROM chat_threads WHERE deleted_at IS NOT NULL;
+ALTER TABLE chat_threads DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A channel's updates are stored once in the channel's own stream instead
+-- of in the log of every subscriber, so a post costs the same however many
+-- subscribers the channel has. update_seq is the seq of the newest one.
+ALTER TABLE chats ADD COLUMN update_seq BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE chat_updates (
+    chat_id    TEXT NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    seq        BIGINT NOT NULL,
+    type       TEXT NOT NULL,
+    payload    JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chat_id, seq)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS chat_updates;
+ALTER TABLE chats DROP COLUMN IF EXISTS update_seq;
+-- +goose StatementEnd
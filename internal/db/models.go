@@ -6,11 +6,99 @@ package db
 
 import (
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type ChatMemberRole string
+
+const (
+	ChatMemberRoleOwner  ChatMemberRole = "owner"
+	ChatMemberRoleAdmin  ChatMemberRole = "admin"
+	ChatMemberRoleMember ChatMemberRole = "member"
+)
+
+func (e *ChatMemberRole) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = ChatMemberRole(s)
+	case string:
+		*e = ChatMemberRole(s)
+	default:
+		return fmt.Errorf("unsupported scan type for ChatMemberRole: %T", src)
+	}
+	return nil
+}
+
+type NullChatMemberRole struct {
+	ChatMemberRole ChatMemberRole `json:"chat_member_role"`
+	Valid          bool           `json:"valid"` // Valid is true if ChatMemberRole is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullChatMemberRole) Scan(value interface{}) error {
+	if value == nil {
+		ns.ChatMemberRole, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.ChatMemberRole.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullChatMemberRole) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.ChatMemberRole), nil
+}
+
+type ChatType string
+
+const (
+	ChatTypeDirect  ChatType = "direct"
+	ChatTypeSaved   ChatType = "saved"
+	ChatTypeGroup   ChatType = "group"
+	ChatTypeChannel ChatType = "channel"
+)
+
+func (e *ChatType) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = ChatType(s)
+	case string:
+		*e = ChatType(s)
+	default:
+		return fmt.Errorf("unsupported scan type for ChatType: %T", src)
+	}
+	return nil
+}
+
+type NullChatType struct {
+	ChatType ChatType `json:"chat_type"`
+	Valid    bool     `json:"valid"` // Valid is true if ChatType is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullChatType) Scan(value interface{}) error {
+	if value == nil {
+		ns.ChatType, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.ChatType.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullChatType) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.ChatType), nil
+}
+
 type ContactRequestState string
 
 const (
@@ -98,6 +186,134 @@ func (ns NullContactState) Value() (driver.Value, error) {
 	return string(ns.ContactState), nil
 }
 
+type JoinRequestState string
+
+const (
+	JoinRequestStatePending  JoinRequestState = "pending"
+	JoinRequestStateApproved JoinRequestState = "approved"
+	JoinRequestStateDeclined JoinRequestState = "declined"
+)
+
+func (e *JoinRequestState) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = JoinRequestState(s)
+	case string:
+		*e = JoinRequestState(s)
+	default:
+		return fmt.Errorf("unsupported scan type for JoinRequestState: %T", src)
+	}
+	return nil
+}
+
+type NullJoinRequestState struct {
+	JoinRequestState JoinRequestState `json:"join_request_state"`
+	Valid            bool             `json:"valid"` // Valid is true if JoinRequestState is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullJoinRequestState) Scan(value interface{}) error {
+	if value == nil {
+		ns.JoinRequestState, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.JoinRequestState.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullJoinRequestState) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.JoinRequestState), nil
+}
+
+type ThreadKind string
+
+const (
+	ThreadKindTopic   ThreadKind = "topic"
+	ThreadKindReplies ThreadKind = "replies"
+)
+
+func (e *ThreadKind) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = ThreadKind(s)
+	case string:
+		*e = ThreadKind(s)
+	default:
+		return fmt.Errorf("unsupported scan type for ThreadKind: %T", src)
+	}
+	return nil
+}
+
+type NullThreadKind struct {
+	ThreadKind ThreadKind `json:"thread_kind"`
+	Valid      bool       `json:"valid"` // Valid is true if ThreadKind is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullThreadKind) Scan(value interface{}) error {
+	if value == nil {
+		ns.ThreadKind, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.ThreadKind.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullThreadKind) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.ThreadKind), nil
+}
+
+type ThreadNotifyLevel string
+
+const (
+	ThreadNotifyLevelAll      ThreadNotifyLevel = "all"
+	ThreadNotifyLevelMentions ThreadNotifyLevel = "mentions"
+	ThreadNotifyLevelNone     ThreadNotifyLevel = "none"
+)
+
+func (e *ThreadNotifyLevel) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = ThreadNotifyLevel(s)
+	case string:
+		*e = ThreadNotifyLevel(s)
+	default:
+		return fmt.Errorf("unsupported scan type for ThreadNotifyLevel: %T", src)
+	}
+	return nil
+}
+
+type NullThreadNotifyLevel struct {
+	ThreadNotifyLevel ThreadNotifyLevel `json:"thread_notify_level"`
+	Valid             bool              `json:"valid"` // Valid is true if ThreadNotifyLevel is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullThreadNotifyLevel) Scan(value interface{}) error {
+	if value == nil {
+		ns.ThreadNotifyLevel, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.ThreadNotifyLevel.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullThreadNotifyLevel) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.ThreadNotifyLevel), nil
+}
+
 type AuthSession struct {
 	ID           string             `json:"id"`
 	UserID       string             `json:"user_id"`
@@ -114,6 +330,148 @@ type Block struct {
 	CreatedAt    pgtype.Timestamptz `json:"created_at"`
 }
 
+type ChannelPostStat struct {
+	ChatID    string `json:"chat_id"`
+	MessageID string `json:"message_id"`
+	ViewCount int32  `json:"view_count"`
+}
+
+type ChannelPostView struct {
+	ChatID    string             `json:"chat_id"`
+	MessageID string             `json:"message_id"`
+	UserID    string             `json:"user_id"`
+	ViewedAt  pgtype.Timestamptz `json:"viewed_at"`
+}
+
+type Chat struct {
+	ID                string             `json:"id"`
+	Type              ChatType           `json:"type"`
+	DirectKey         pgtype.Text        `json:"direct_key"`
+	CreatedBy         pgtype.Text        `json:"created_by"`
+	LastActivityAt    pgtype.Timestamptz `json:"last_activity_at"`
+	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt         pgtype.Timestamptz `json:"updated_at"`
+	Title             pgtype.Text        `json:"title"`
+	PhotoUrl          pgtype.Text        `json:"photo_url"`
+	MemberCount       int32              `json:"member_count"`
+	MemberPermissions int32              `json:"member_permissions"`
+	AdminPermissions  int32              `json:"admin_permissions"`
+	Username          pgtype.Text        `json:"username"`
+	IsForum           bool               `json:"is_forum"`
+	SlowModeSeconds   int32              `json:"slow_mode_seconds"`
+	LastSeq           int64              `json:"last_seq"`
+	AllowedReactions  []string           `json:"allowed_reactions"`
+	MessageTtlSeconds pgtype.Int4        `json:"message_ttl_seconds"`
+	UpdateSeq         int64              `json:"update_seq"`
+}
+
+type ChatAuditEvent struct {
+	ID           string             `json:"id"`
+	ChatID       string             `json:"chat_id"`
+	ActorID      pgtype.Text        `json:"actor_id"`
+	Action       string             `json:"action"`
+	TargetUserID pgtype.Text        `json:"target_user_id"`
+	TargetID     pgtype.Text        `json:"target_id"`
+	Before       json.RawMessage    `json:"before"`
+	After        json.RawMessage    `json:"after"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+}
+
+type ChatBan struct {
+	ChatID    string             `json:"chat_id"`
+	UserID    string             `json:"user_id"`
+	BannedBy  pgtype.Text        `json:"banned_by"`
+	UntilAt   pgtype.Timestamptz `json:"until_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type ChatFolder struct {
+	ID              string             `json:"id"`
+	UserID          string             `json:"user_id"`
+	Title           string             `json:"title"`
+	Position        int32              `json:"position"`
+	IncludeRules    []string           `json:"include_rules"`
+	ExcludeRules    []string           `json:"exclude_rules"`
+	IncludedChatIds []string           `json:"included_chat_ids"`
+	ExcludedChatIds []string           `json:"excluded_chat_ids"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
+}
+
+type ChatInviteLink struct {
+	ID               string             `json:"id"`
+	ChatID           string             `json:"chat_id"`
+	Token            string             `json:"token"`
+	Name             pgtype.Text        `json:"name"`
+	CreatedBy        pgtype.Text        `json:"created_by"`
+	ExpiresAt        pgtype.Timestamptz `json:"expires_at"`
+	UsageLimit       pgtype.Int4        `json:"usage_limit"`
+	UsageCount       int32              `json:"usage_count"`
+	RequiresApproval bool               `json:"requires_approval"`
+	RevokedAt        pgtype.Timestamptz `json:"revoked_at"`
+	CreatedAt        pgtype.Timestamptz `json:"created_at"`
+}
+
+type ChatJoinRequest struct {
+	ChatID       string             `json:"chat_id"`
+	UserID       string             `json:"user_id"`
+	InviteLinkID pgtype.Text        `json:"invite_link_id"`
+	State        JoinRequestState   `json:"state"`
+	DecidedBy    pgtype.Text        `json:"decided_by"`
+	DecidedAt    pgtype.Timestamptz `json:"decided_at"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+}
+
+type ChatMember struct {
+	ChatID          string             `json:"chat_id"`
+	UserID          string             `json:"user_id"`
+	JoinedAt        pgtype.Timestamptz `json:"joined_at"`
+	Role            ChatMemberRole     `json:"role"`
+	InvitedBy       pgtype.Text        `json:"invited_by"`
+	Permissions     pgtype.Int4        `json:"permissions"`
+	CustomTitle     pgtype.Text        `json:"custom_title"`
+	RestrictedUntil pgtype.Timestamptz `json:"restricted_until"`
+	LastSentAt      pgtype.Timestamptz `json:"last_sent_at"`
+}
+
+type ChatThread struct {
+	ID            string             `json:"id"`
+	ChatID        string             `json:"chat_id"`
+	Kind          ThreadKind         `json:"kind"`
+	Title         pgtype.Text        `json:"title"`
+	IconColor     pgtype.Int4        `json:"icon_color"`
+	RootMessageID pgtype.Text        `json:"root_message_id"`
+	CreatedBy     pgtype.Text        `json:"created_by"`
+	IsClosed      bool               `json:"is_closed"`
+	LastSeq       int64              `json:"last_seq"`
+	LastMessageID pgtype.Text        `json:"last_message_id"`
+	LastMessageAt pgtype.Timestamptz `json:"last_message_at"`
+	CreatedAt     pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt     pgtype.Timestamptz `json:"updated_at"`
+	DeletedAt     pgtype.Timestamptz `json:"deleted_at"`
+}
+
+type ChatUpdate struct {
+	ChatID    string             `json:"chat_id"`
+	Seq       int64              `json:"seq"`
+	Type      string             `json:"type"`
+	Payload   json.RawMessage    `json:"payload"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type ChatUserState struct {
+	UserID               string             `json:"user_id"`
+	ChatID               string             `json:"chat_id"`
+	PinnedRank           pgtype.Int4        `json:"pinned_rank"`
+	Archived             bool               `json:"archived"`
+	MutedUntil           pgtype.Timestamptz `json:"muted_until"`
+	MarkedUnread         bool               `json:"marked_unread"`
+	UpdatedAt            pgtype.Timestamptz `json:"updated_at"`
+	ClearedSeq           int64              `json:"cleared_seq"`
+	ReadSeq              int64              `json:"read_seq"`
+	MentionNotifications bool               `json:"mention_notifications"`
+}
+
 type Contact struct {
 	ID        string             `json:"id"`
 	OwnerID   string             `json:"owner_id"`
@@ -143,6 +501,153 @@ type Device struct {
 	RevokedAt pgtype.Timestamptz `json:"revoked_at"`
 }
 
+type DeviceChatDelivery struct {
+	DeviceID     string             `json:"device_id"`
+	ChatID       string             `json:"chat_id"`
+	UserID       string             `json:"user_id"`
+	DeliveredSeq int64              `json:"delivered_seq"`
+	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
+}
+
+type Draft struct {
+	UserID          string             `json:"user_id"`
+	ChatID          string             `json:"chat_id"`
+	ThreadID        pgtype.Text        `json:"thread_id"`
+	Ciphertext      []byte             `json:"ciphertext"`
+	ReplyToID       pgtype.Text        `json:"reply_to_id"`
+	ClientUpdatedAt pgtype.Timestamptz `json:"client_updated_at"`
+	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
+}
+
+type IdempotencyKey struct {
+	UserID       string             `json:"user_id"`
+	Key          string             `json:"key"`
+	RequestHash  string             `json:"request_hash"`
+	StatusCode   pgtype.Int4        `json:"status_code"`
+	ContentType  pgtype.Text        `json:"content_type"`
+	ResponseBody []byte             `json:"response_body"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+	ExpiresAt    pgtype.Timestamptz `json:"expires_at"`
+}
+
+type Message struct {
+	ID                   string             `json:"id"`
+	ChatID               string             `json:"chat_id"`
+	Seq                  int64              `json:"seq"`
+	SenderID             pgtype.Text        `json:"sender_id"`
+	SenderDeviceID       pgtype.Text        `json:"sender_device_id"`
+	ThreadID             pgtype.Text        `json:"thread_id"`
+	ThreadSeq            pgtype.Int8        `json:"thread_seq"`
+	ContentType          string             `json:"content_type"`
+	Ciphertext           []byte             `json:"ciphertext"`
+	CreatedAt            pgtype.Timestamptz `json:"created_at"`
+	ClientMessageID      pgtype.Text        `json:"client_message_id"`
+	Version              int32              `json:"version"`
+	EditedAt             pgtype.Timestamptz `json:"edited_at"`
+	DeletedAt            pgtype.Timestamptz `json:"deleted_at"`
+	Reactions            json.RawMessage    `json:"reactions"`
+	ReplyToID            pgtype.Text        `json:"reply_to_id"`
+	ForwardFromUserID    pgtype.Text        `json:"forward_from_user_id"`
+	ForwardFromChatID    pgtype.Text        `json:"forward_from_chat_id"`
+	ForwardFromMessageID pgtype.Text        `json:"forward_from_message_id"`
+	ForwardDate          pgtype.Timestamptz `json:"forward_date"`
+	ExpiresAt            pgtype.Timestamptz `json:"expires_at"`
+	ViewOnce             bool               `json:"view_once"`
+	Service              json.RawMessage    `json:"service"`
+}
+
+type MessageDevicePayload struct {
+	MessageID  string `json:"message_id"`
+	DeviceID   string `json:"device_id"`
+	Ciphertext []byte `json:"ciphertext"`
+	Version    int32  `json:"version"`
+}
+
+type MessageEdit struct {
+	MessageID  string             `json:"message_id"`
+	Version    int32              `json:"version"`
+	Ciphertext []byte             `json:"ciphertext"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
+
+type MessageHidden struct {
+	UserID    string             `json:"user_id"`
+	MessageID string             `json:"message_id"`
+	ChatID    string             `json:"chat_id"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type MessageMention struct {
+	UserID    string             `json:"user_id"`
+	MessageID string             `json:"message_id"`
+	ChatID    string             `json:"chat_id"`
+	Seq       int64              `json:"seq"`
+	IsReply   bool               `json:"is_reply"`
+	Read      bool               `json:"read"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type MessageReaction struct {
+	MessageID string             `json:"message_id"`
+	UserID    string             `json:"user_id"`
+	Emoji     string             `json:"emoji"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type PinnedMessage struct {
+	ChatID    string             `json:"chat_id"`
+	MessageID string             `json:"message_id"`
+	PinnedBy  pgtype.Text        `json:"pinned_by"`
+	PinnedAt  pgtype.Timestamptz `json:"pinned_at"`
+}
+
+type Poll struct {
+	MessageID      string             `json:"message_id"`
+	ChatID         string             `json:"chat_id"`
+	OptionCount    int16              `json:"option_count"`
+	MultipleChoice bool               `json:"multiple_choice"`
+	Anonymous      bool               `json:"anonymous"`
+	CorrectOption  pgtype.Int2        `json:"correct_option"`
+	ClosesAt       pgtype.Timestamptz `json:"closes_at"`
+	ClosedAt       pgtype.Timestamptz `json:"closed_at"`
+}
+
+type PollVote struct {
+	MessageID string             `json:"message_id"`
+	UserID    string             `json:"user_id"`
+	Options   []int16            `json:"options"`
+	VotedAt   pgtype.Timestamptz `json:"voted_at"`
+}
+
+type ScheduledMessage struct {
+	ID                string             `json:"id"`
+	ChatID            string             `json:"chat_id"`
+	SenderID          string             `json:"sender_id"`
+	SenderDeviceID    string             `json:"sender_device_id"`
+	ThreadID          pgtype.Text        `json:"thread_id"`
+	ReplyToID         pgtype.Text        `json:"reply_to_id"`
+	ContentType       string             `json:"content_type"`
+	Ciphertext        []byte             `json:"ciphertext"`
+	DeviceIds         []string           `json:"device_ids"`
+	DeviceCiphertexts [][]byte           `json:"device_ciphertexts"`
+	SendAt            pgtype.Timestamptz `json:"send_at"`
+	OnlineUserID      pgtype.Text        `json:"online_user_id"`
+	LockedUntil       pgtype.Timestamptz `json:"locked_until"`
+	FailedAt          pgtype.Timestamptz `json:"failed_at"`
+	ErrorCode         pgtype.Text        `json:"error_code"`
+	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt         pgtype.Timestamptz `json:"updated_at"`
+}
+
+type ThreadMemberState struct {
+	ThreadID    string             `json:"thread_id"`
+	UserID      string             `json:"user_id"`
+	LastReadSeq int64              `json:"last_read_seq"`
+	NotifyLevel ThreadNotifyLevel  `json:"notify_level"`
+	MutedUntil  pgtype.Timestamptz `json:"muted_until"`
+	UpdatedAt   pgtype.Timestamptz `json:"updated_at"`
+}
+
 type User struct {
 	ID             string             `json:"id"`
 	Username       string             `json:"username"`
@@ -153,3 +658,28 @@ type User struct {
 	CreatedAt      pgtype.Timestamptz `json:"created_at"`
 	UpdatedAt      pgtype.Timestamptz `json:"updated_at"`
 }
+
+type UserPresence struct {
+	UserID       string             `json:"user_id"`
+	LastOnlineAt pgtype.Timestamptz `json:"last_online_at"`
+}
+
+type UserPrivacySetting struct {
+	UserID             string             `json:"user_id"`
+	ReadReceipts       bool               `json:"read_receipts"`
+	UpdatedAt          pgtype.Timestamptz `json:"updated_at"`
+	ForwardAttribution bool               `json:"forward_attribution"`
+}
+
+type UserUpdate struct {
+	UserID    string             `json:"user_id"`
+	Seq       int64              `json:"seq"`
+	Type      string             `json:"type"`
+	Payload   json.RawMessage    `json:"payload"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type UserUpdateState struct {
+	UserID string `json:"user_id"`
+	Seq    int64  `json:"seq"`
+}
@@ -0,0 +1,215 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: moderation.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const banChatMember = `-- name: BanChatMember :one
+WITH removed AS (
+    DELETE FROM chat_members
+    WHERE chat_members.chat_id = $1 AND chat_members.user_id = $2
+    RETURNING chat_members.chat_id
+), counted AS (
+    UPDATE chats
+    SET member_count = member_count - 1, updated_at = NOW()
+    FROM removed
+    WHERE chats.id = removed.chat_id
+), declined AS (
+    UPDATE chat_join_requests
+    SET state = 'declined', decided_by = $3, decided_at = NOW()
+    WHERE chat_join_requests.chat_id = $1 AND chat_join_requests.user_id = $2
+      AND chat_join_requests.state = 'pending'
+)
+INSERT INTO chat_bans (chat_id, user_id, banned_by, until_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (chat_id, user_id) DO UPDATE
+SET banned_by = EXCLUDED.banned_by, until_at = EXCLUDED.until_at, created_at = NOW()
+RETURNING chat_id, user_id, banned_by, until_at, created_at
+`
+
+type BanChatMemberParams struct {
+	ChatID   string             `json:"chat_id"`
+	UserID   string             `json:"user_id"`
+	BannedBy pgtype.Text        `json:"banned_by"`
+	UntilAt  pgtype.Timestamptz `json:"until_at"`
+}
+
+// Bans a user from a chat, removing their membership and declining any
+// pending join request. A NULL until_at bans permanently.
+func (q *Queries) BanChatMember(ctx context.Context, arg BanChatMemberParams) (ChatBan, error) {
+	row := q.db.QueryRow(ctx, banChatMember,
+		arg.ChatID,
+		arg.UserID,
+		arg.BannedBy,
+		arg.UntilAt,
+	)
+	var i ChatBan
+	err := row.Scan(
+		&i.ChatID,
+		&i.UserID,
+		&i.BannedBy,
+		&i.UntilAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const claimSlowModeSlot = `-- name: ClaimSlowModeSlot :one
+WITH claimed AS (
+    UPDATE chat_members
+    SET last_sent_at = NOW()
+    WHERE chat_members.chat_id = $1 AND chat_members.user_id = $2
+      AND (chat_members.last_sent_at IS NULL
+           OR chat_members.last_sent_at <= NOW() - make_interval(secs => $3::int))
+    RETURNING chat_members.user_id
+)
+SELECT EXISTS(SELECT 1 FROM claimed)::bool AS claimed,
+       m.last_sent_at
+FROM chat_members m
+WHERE m.chat_id = $1 AND m.user_id = $2
+`
+
+type ClaimSlowModeSlotParams struct {
+	ChatID          string `json:"chat_id"`
+	UserID          string `json:"user_id"`
+	IntervalSeconds int32  `json:"interval_seconds"`
+}
+
+type ClaimSlowModeSlotRow struct {
+	Claimed    bool               `json:"claimed"`
+	LastSentAt pgtype.Timestamptz `json:"last_sent_at"`
+}
+
+// Records a send when the member's previous one is at least interval_seconds
+// old. last_sent_at is the time of the previous send either way.
+func (q *Queries) ClaimSlowModeSlot(ctx context.Context, arg ClaimSlowModeSlotParams) (ClaimSlowModeSlotRow, error) {
+	row := q.db.QueryRow(ctx, claimSlowModeSlot, arg.ChatID, arg.UserID, arg.IntervalSeconds)
+	var i ClaimSlowModeSlotRow
+	err := row.Scan(&i.Claimed, &i.LastSentAt)
+	return i, err
+}
+
+const listChatBans = `-- name: ListChatBans :many
+SELECT b.chat_id, b.user_id, b.banned_by, b.until_at, b.created_at, u.username
+FROM chat_bans b
+JOIN users u ON u.id = b.user_id
+WHERE b.chat_id = $1
+  AND (b.until_at IS NULL OR b.until_at > NOW())
+  AND ($2::timestamptz IS NULL
+       OR (b.created_at, b.user_id) > ($2::timestamptz, $3::text))
+ORDER BY b.created_at, b.user_id
+LIMIT $4
+`
+
+type ListChatBansParams struct {
+	ChatID          string             `json:"chat_id"`
+	CursorCreatedAt pgtype.Timestamptz `json:"cursor_created_at"`
+	CursorUserID    pgtype.Text        `json:"cursor_user_id"`
+	PageSize        int32              `json:"page_size"`
+}
+
+type ListChatBansRow struct {
+	ChatID    string             `json:"chat_id"`
+	UserID    string             `json:"user_id"`
+	BannedBy  pgtype.Text        `json:"banned_by"`
+	UntilAt   pgtype.Timestamptz `json:"until_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	Username  string             `json:"username"`
+}
+
+// Lists the chat's active bans, oldest first.
+func (q *Queries) ListChatBans(ctx context.Context, arg ListChatBansParams) ([]ListChatBansRow, error) {
+	rows, err := q.db.Query(ctx, listChatBans,
+		arg.ChatID,
+		arg.CursorCreatedAt,
+		arg.CursorUserID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListChatBansRow{}
+	for rows.Next() {
+		var i ListChatBansRow
+		if err := rows.Scan(
+			&i.ChatID,
+			&i.UserID,
+			&i.BannedBy,
+			&i.UntilAt,
+			&i.CreatedAt,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restrictChatMember = `-- name: RestrictChatMember :execrows
+UPDATE chat_members
+SET restricted_until = $1
+WHERE chat_id = $2 AND user_id = $3
+`
+
+type RestrictChatMemberParams struct {
+	RestrictedUntil pgtype.Timestamptz `json:"restricted_until"`
+	ChatID          string             `json:"chat_id"`
+	UserID          string             `json:"user_id"`
+}
+
+func (q *Queries) RestrictChatMember(ctx context.Context, arg RestrictChatMemberParams) (int64, error) {
+	result, err := q.db.Exec(ctx, restrictChatMember, arg.RestrictedUntil, arg.ChatID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const setChatSlowMode = `-- name: SetChatSlowMode :execrows
+UPDATE chats
+SET slow_mode_seconds = $1, updated_at = NOW()
+WHERE id = $2
+`
+
+type SetChatSlowModeParams struct {
+	SlowModeSeconds int32  `json:"slow_mode_seconds"`
+	ID              string `json:"id"`
+}
+
+func (q *Queries) SetChatSlowMode(ctx context.Context, arg SetChatSlowModeParams) (int64, error) {
+	result, err := q.db.Exec(ctx, setChatSlowMode, arg.SlowModeSeconds, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const unbanChatMember = `-- name: UnbanChatMember :execrows
+DELETE FROM chat_bans
+WHERE chat_id = $1 AND user_id = $2
+`
+
+type UnbanChatMemberParams struct {
+	ChatID string `json:"chat_id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) UnbanChatMember(ctx context.Context, arg UnbanChatMemberParams) (int64, error) {
+	result, err := q.db.Exec(ctx, unbanChatMember, arg.ChatID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,191 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: pins.sql
+
+package db
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const listPinnedMessages = `-- name: ListPinnedMessages :many
+SELECT p.pinned_by, p.pinned_at, m.id, m.chat_id, m.seq, m.sender_id, m.sender_device_id, m.thread_id, m.thread_seq, m.content_type, m.ciphertext, m.created_at, m.client_message_id, m.version, m.edited_at, m.deleted_at, m.reactions, m.reply_to_id, m.forward_from_user_id, m.forward_from_chat_id, m.forward_from_message_id, m.forward_date, m.expires_at, m.view_once, m.service, dp.ciphertext AS device_ciphertext
+FROM pinned_messages p
+JOIN messages m ON m.id = p.message_id
+LEFT JOIN devices d ON d.id = $1::text AND d.user_id = $2
+LEFT JOIN message_device_payloads dp ON dp.message_id = m.id AND dp.version = m.version AND dp.device_id = d.id
+WHERE p.chat_id = $3
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.user_id = $2 AND h.message_id = m.id)
+  AND m.seq > COALESCE((SELECT s.cleared_seq FROM chat_user_states s WHERE s.user_id = $2 AND s.chat_id = m.chat_id), 0)
+  AND ($4::timestamptz IS NULL
+       OR (p.pinned_at, p.message_id) < ($4::timestamptz, $5::text))
+ORDER BY p.pinned_at DESC, p.message_id DESC
+LIMIT $6
+`
+
+type ListPinnedMessagesParams struct {
+	DeviceID        pgtype.Text        `json:"device_id"`
+	UserID          string             `json:"user_id"`
+	ChatID          string             `json:"chat_id"`
+	CursorPinnedAt  pgtype.Timestamptz `json:"cursor_pinned_at"`
+	CursorMessageID pgtype.Text        `json:"cursor_message_id"`
+	PageSize        int32              `json:"page_size"`
+}
+
+type ListPinnedMessagesRow struct {
+	PinnedBy             pgtype.Text        `json:"pinned_by"`
+	PinnedAt             pgtype.Timestamptz `json:"pinned_at"`
+	ID                   string             `json:"id"`
+	ChatID               string             `json:"chat_id"`
+	Seq                  int64              `json:"seq"`
+	SenderID             pgtype.Text        `json:"sender_id"`
+	SenderDeviceID       pgtype.Text        `json:"sender_device_id"`
+	ThreadID             pgtype.Text        `json:"thread_id"`
+	ThreadSeq            pgtype.Int8        `json:"thread_seq"`
+	ContentType          string             `json:"content_type"`
+	Ciphertext           []byte             `json:"ciphertext"`
+	CreatedAt            pgtype.Timestamptz `json:"created_at"`
+	ClientMessageID      pgtype.Text        `json:"client_message_id"`
+	Version              int32              `json:"version"`
+	EditedAt             pgtype.Timestamptz `json:"edited_at"`
+	DeletedAt            pgtype.Timestamptz `json:"deleted_at"`
+	Reactions            json.RawMessage    `json:"reactions"`
+	ReplyToID            pgtype.Text        `json:"reply_to_id"`
+	ForwardFromUserID    pgtype.Text        `json:"forward_from_user_id"`
+	ForwardFromChatID    pgtype.Text        `json:"forward_from_chat_id"`
+	ForwardFromMessageID pgtype.Text        `json:"forward_from_message_id"`
+	ForwardDate          pgtype.Timestamptz `json:"forward_date"`
+	ExpiresAt            pgtype.Timestamptz `json:"expires_at"`
+	ViewOnce             bool               `json:"view_once"`
+	Service              json.RawMessage    `json:"service"`
+	DeviceCiphertext     []byte             `json:"device_ciphertext"`
+}
+
+// Returns a page of a chat's pinned messages, newest pin first, with the
+// payload addressed to one of the user's devices. Messages the user deleted
+// for themselves or cleared are left out.
+func (q *Queries) ListPinnedMessages(ctx context.Context, arg ListPinnedMessagesParams) ([]ListPinnedMessagesRow, error) {
+	rows, err := q.db.Query(ctx, listPinnedMessages,
+		arg.DeviceID,
+		arg.UserID,
+		arg.ChatID,
+		arg.CursorPinnedAt,
+		arg.CursorMessageID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListPinnedMessagesRow{}
+	for rows.Next() {
+		var i ListPinnedMessagesRow
+		if err := rows.Scan(
+			&i.PinnedBy,
+			&i.PinnedAt,
+			&i.ID,
+			&i.ChatID,
+			&i.Seq,
+			&i.SenderID,
+			&i.SenderDeviceID,
+			&i.ThreadID,
+			&i.ThreadSeq,
+			&i.ContentType,
+			&i.Ciphertext,
+			&i.CreatedAt,
+			&i.ClientMessageID,
+			&i.Version,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.Reactions,
+			&i.ReplyToID,
+			&i.ForwardFromUserID,
+			&i.ForwardFromChatID,
+			&i.ForwardFromMessageID,
+			&i.ForwardDate,
+			&i.ExpiresAt,
+			&i.ViewOnce,
+			&i.Service,
+			&i.DeviceCiphertext,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pinMessage = `-- name: PinMessage :execrows
+INSERT INTO pinned_messages (chat_id, message_id, pinned_by)
+SELECT m.chat_id, m.id, $1::text FROM messages m
+WHERE m.chat_id = $2 AND m.id = $3 AND m.deleted_at IS NULL
+ON CONFLICT (chat_id, message_id) DO NOTHING
+`
+
+type PinMessageParams struct {
+	PinnedBy  string `json:"pinned_by"`
+	ChatID    string `json:"chat_id"`
+	MessageID string `json:"message_id"`
+}
+
+// Pins a message that has not been deleted. Pinning a pinned message again
+// changes nothing.
+func (q *Queries) PinMessage(ctx context.Context, arg PinMessageParams) (int64, error) {
+	result, err := q.db.Exec(ctx, pinMessage, arg.PinnedBy, arg.ChatID, arg.MessageID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const unpinAllMessages = `-- name: UnpinAllMessages :many
+DELETE FROM pinned_messages
+WHERE chat_id = $1
+RETURNING message_id
+`
+
+// Unpins every message of a chat and returns the unpinned message IDs.
+func (q *Queries) UnpinAllMessages(ctx context.Context, chatID string) ([]string, error) {
+	rows, err := q.db.Query(ctx, unpinAllMessages, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var message_id string
+		if err := rows.Scan(&message_id); err != nil {
+			return nil, err
+		}
+		items = append(items, message_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const unpinMessage = `-- name: UnpinMessage :execrows
+DELETE FROM pinned_messages
+WHERE chat_id = $1 AND message_id = $2
+`
+
+type UnpinMessageParams struct {
+	ChatID    string `json:"chat_id"`
+	MessageID string `json:"message_id"`
+}
+
+func (q *Queries) UnpinMessage(ctx context.Context, arg UnpinMessageParams) (int64, error) {
+	result, err := q.db.Exec(ctx, unpinMessage, arg.ChatID, arg.MessageID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
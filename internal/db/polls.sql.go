@@ -0,0 +1,196 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: polls.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const closePoll = `-- name: ClosePoll :execrows
+UPDATE polls SET closed_at = NOW()
+WHERE chat_id = $1 AND message_id = $2
+  AND closed_at IS NULL AND (closes_at IS NULL OR closes_at > NOW())
+`
+
+type ClosePollParams struct {
+	ChatID    string `json:"chat_id"`
+	MessageID string `json:"message_id"`
+}
+
+// Closes an open poll for good.
+func (q *Queries) ClosePoll(ctx context.Context, arg ClosePollParams) (int64, error) {
+	result, err := q.db.Exec(ctx, closePoll, arg.ChatID, arg.MessageID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getPoll = `-- name: GetPoll :one
+SELECT p.message_id, p.chat_id, p.option_count, p.multiple_choice, p.anonymous, p.correct_option, p.closes_at, p.closed_at,
+       v.options AS my_options,
+       (SELECT count(*) FROM poll_votes t WHERE t.message_id = p.message_id)::int AS total_voters,
+       ARRAY(
+           SELECT count(c.user_id)::int
+           FROM generate_series(0, p.option_count - 1) AS o(i)
+           LEFT JOIN poll_votes c ON c.message_id = p.message_id AND o.i = ANY(c.options)
+           GROUP BY o.i ORDER BY o.i
+       )::int[] AS counts
+FROM polls p
+LEFT JOIN poll_votes v ON v.message_id = p.message_id AND v.user_id = $1::text
+WHERE p.chat_id = $2 AND p.message_id = $3
+`
+
+type GetPollParams struct {
+	UserID    string `json:"user_id"`
+	ChatID    string `json:"chat_id"`
+	MessageID string `json:"message_id"`
+}
+
+type GetPollRow struct {
+	MessageID      string             `json:"message_id"`
+	ChatID         string             `json:"chat_id"`
+	OptionCount    int16              `json:"option_count"`
+	MultipleChoice bool               `json:"multiple_choice"`
+	Anonymous      bool               `json:"anonymous"`
+	CorrectOption  pgtype.Int2        `json:"correct_option"`
+	ClosesAt       pgtype.Timestamptz `json:"closes_at"`
+	ClosedAt       pgtype.Timestamptz `json:"closed_at"`
+	MyOptions      []int16            `json:"my_options"`
+	TotalVoters    int32              `json:"total_voters"`
+	Counts         []int32            `json:"counts"`
+}
+
+// Returns a poll with its vote count per option and the user's own choices.
+func (q *Queries) GetPoll(ctx context.Context, arg GetPollParams) (GetPollRow, error) {
+	row := q.db.QueryRow(ctx, getPoll, arg.UserID, arg.ChatID, arg.MessageID)
+	var i GetPollRow
+	err := row.Scan(
+		&i.MessageID,
+		&i.ChatID,
+		&i.OptionCount,
+		&i.MultipleChoice,
+		&i.Anonymous,
+		&i.CorrectOption,
+		&i.ClosesAt,
+		&i.ClosedAt,
+		&i.MyOptions,
+		&i.TotalVoters,
+		&i.Counts,
+	)
+	return i, err
+}
+
+const listPollVoters = `-- name: ListPollVoters :many
+SELECT user_id, options, voted_at FROM poll_votes
+WHERE message_id = $1
+  AND ($2::smallint IS NULL OR $2::smallint = ANY(options))
+  AND ($3::timestamptz IS NULL
+       OR (voted_at, user_id) > ($3::timestamptz, $4::text))
+ORDER BY voted_at, user_id
+LIMIT $5
+`
+
+type ListPollVotersParams struct {
+	MessageID     string             `json:"message_id"`
+	Option        pgtype.Int2        `json:"option"`
+	CursorVotedAt pgtype.Timestamptz `json:"cursor_voted_at"`
+	CursorUserID  pgtype.Text        `json:"cursor_user_id"`
+	PageSize      int32              `json:"page_size"`
+}
+
+type ListPollVotersRow struct {
+	UserID  string             `json:"user_id"`
+	Options []int16            `json:"options"`
+	VotedAt pgtype.Timestamptz `json:"voted_at"`
+}
+
+// Returns a page of a poll's voters, earliest vote first. option narrows
+// the page to the voters who chose it.
+func (q *Queries) ListPollVoters(ctx context.Context, arg ListPollVotersParams) ([]ListPollVotersRow, error) {
+	rows, err := q.db.Query(ctx, listPollVoters,
+		arg.MessageID,
+		arg.Option,
+		arg.CursorVotedAt,
+		arg.CursorUserID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListPollVotersRow{}
+	for rows.Next() {
+		var i ListPollVotersRow
+		if err := rows.Scan(&i.UserID, &i.Options, &i.VotedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const retractPollVote = `-- name: RetractPollVote :execrows
+DELETE FROM poll_votes v
+USING polls p
+WHERE p.message_id = v.message_id AND p.chat_id = $1 AND v.message_id = $2 AND v.user_id = $3
+  AND p.correct_option IS NULL
+  AND p.closed_at IS NULL AND (p.closes_at IS NULL OR p.closes_at > NOW())
+`
+
+type RetractPollVoteParams struct {
+	ChatID    string `json:"chat_id"`
+	MessageID string `json:"message_id"`
+	UserID    string `json:"user_id"`
+}
+
+// Removes the user's vote from an open poll that is not a quiz.
+func (q *Queries) RetractPollVote(ctx context.Context, arg RetractPollVoteParams) (int64, error) {
+	result, err := q.db.Exec(ctx, retractPollVote, arg.ChatID, arg.MessageID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const votePoll = `-- name: VotePoll :execrows
+INSERT INTO poll_votes (message_id, user_id, options)
+SELECT p.message_id, $1::text, $2::smallint[] FROM polls p
+WHERE p.chat_id = $3 AND p.message_id = $4
+  AND p.closed_at IS NULL AND (p.closes_at IS NULL OR p.closes_at > NOW())
+ON CONFLICT (message_id, user_id) DO UPDATE
+SET options = EXCLUDED.options, voted_at = NOW()
+WHERE poll_votes.options IS DISTINCT FROM EXCLUDED.options
+  AND NOT EXISTS (SELECT 1 FROM polls q WHERE q.message_id = poll_votes.message_id AND q.correct_option IS NOT NULL)
+`
+
+type VotePollParams struct {
+	UserID    string  `json:"user_id"`
+	Options   []int16 `json:"options"`
+	ChatID    string  `json:"chat_id"`
+	MessageID string  `json:"message_id"`
+}
+
+// Stores the user's choices on an open poll, replacing earlier ones. A quiz
+// answer cannot be changed. Nothing is written when the poll is closed or
+// the choices are unchanged, so repeating a vote is a no-op.
+func (q *Queries) VotePoll(ctx context.Context, arg VotePollParams) (int64, error) {
+	result, err := q.db.Exec(ctx, votePoll,
+		arg.UserID,
+		arg.Options,
+		arg.ChatID,
+		arg.MessageID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
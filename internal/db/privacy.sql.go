@@ -0,0 +1,58 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: privacy.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const getPrivacySettings = `-- name: GetPrivacySettings :one
+SELECT user_id, read_receipts, updated_at, forward_attribution FROM user_privacy_settings
+WHERE user_id = $1
+`
+
+func (q *Queries) GetPrivacySettings(ctx context.Context, userID string) (UserPrivacySetting, error) {
+	row := q.db.QueryRow(ctx, getPrivacySettings, userID)
+	var i UserPrivacySetting
+	err := row.Scan(
+		&i.UserID,
+		&i.ReadReceipts,
+		&i.UpdatedAt,
+		&i.ForwardAttribution,
+	)
+	return i, err
+}
+
+const upsertPrivacySettings = `-- name: UpsertPrivacySettings :one
+INSERT INTO user_privacy_settings (user_id, read_receipts, forward_attribution)
+VALUES ($1, COALESCE($2::bool, true), COALESCE($3::bool, true))
+ON CONFLICT (user_id) DO UPDATE
+SET read_receipts       = COALESCE($2::bool, user_privacy_settings.read_receipts),
+    forward_attribution = COALESCE($3::bool, user_privacy_settings.forward_attribution),
+    updated_at          = NOW()
+RETURNING user_id, read_receipts, updated_at, forward_attribution
+`
+
+type UpsertPrivacySettingsParams struct {
+	UserID             string      `json:"user_id"`
+	ReadReceipts       pgtype.Bool `json:"read_receipts"`
+	ForwardAttribution pgtype.Bool `json:"forward_attribution"`
+}
+
+// Changes the provided settings only.
+func (q *Queries) UpsertPrivacySettings(ctx context.Context, arg UpsertPrivacySettingsParams) (UserPrivacySetting, error) {
+	row := q.db.QueryRow(ctx, upsertPrivacySettings, arg.UserID, arg.ReadReceipts, arg.ForwardAttribution)
+	var i UserPrivacySetting
+	err := row.Scan(
+		&i.UserID,
+		&i.ReadReceipts,
+		&i.UpdatedAt,
+		&i.ForwardAttribution,
+	)
+	return i, err
+}
@@ -6,20 +6,310 @@ package db
 
 import (
 	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
+	// The member count is reserved with a conditional UPDATE so concurrent adds
+	// cannot exceed max_members; a duplicate member aborts the whole statement.
+	AddChatMember(ctx context.Context, arg AddChatMemberParams) (int64, error)
+	// Adds a reaction and bumps the message's count for the emoji. The message
+	// row is locked so that concurrent reactions count correctly. No row is
+	// returned when the reaction exists already, the user has max_per_user
+	// reactions on the message, or the message already has max_per_message
+	// distinct emoji without this one.
+	AddReaction(ctx context.Context, arg AddReactionParams) (json.RawMessage, error)
+	// Takes the channel's next update seq and records the update in its
+	// stream. Returns no row when the chat is not a channel.
+	AppendChannelUpdate(ctx context.Context, arg AppendChannelUpdateParams) (ChatUpdate, error)
+	// Appends the same update to the log of every member of a chat. Members are
+	// visited in a fixed order so that concurrent fan-outs lock their
+	// user_update_state rows consistently.
+	AppendChatUpdate(ctx context.Context, arg AppendChatUpdateParams) ([]UserUpdate, error)
+	// Takes the user's next update seq and records the update. The row lock on
+	// user_update_state keeps seq gapless under concurrent writers.
+	AppendUserUpdate(ctx context.Context, arg AppendUserUpdateParams) (UserUpdate, error)
+	// Bans a user from a chat, removing their membership and declining any
+	// pending join request. A NULL until_at bans permanently.
+	BanChatMember(ctx context.Context, arg BanChatMemberParams) (ChatBan, error)
+	// Leases up to batch_size due messages to the caller. Concurrent schedulers
+	// skip each other's rows, and a row whose lease ran out without being
+	// finished is claimed again. A message waiting for its recipient is due
+	// once the recipient was online after it was scheduled.
+	ClaimDueScheduledMessages(ctx context.Context, arg ClaimDueScheduledMessagesParams) ([]ScheduledMessage, error)
+	// Claims a key for a new request. An expired entry, or one whose request
+	// never finished, is taken over; a live entry leaves the claim at zero rows.
+	ClaimIdempotencyKey(ctx context.Context, arg ClaimIdempotencyKeyParams) (int64, error)
+	// Records a send when the member's previous one is at least interval_seconds
+	// old. last_sent_at is the time of the previous send either way.
+	ClaimSlowModeSlot(ctx context.Context, arg ClaimSlowModeSlotParams) (ClaimSlowModeSlotRow, error)
+	// Hides every current message of the chat from the user and returns the
+	// seq up to which the history is cleared. Per-message hides below it are
+	// no longer needed.
+	ClearChatHistory(ctx context.Context, arg ClearChatHistoryParams) (int64, error)
+	// Clears every user's draft in a thread. The client timestamp moves to now
+	// so that the cleared draft wins over the copies on users' devices.
+	ClearThreadDrafts(ctx context.Context, threadID pgtype.Text) ([]Draft, error)
+	// Closes an open poll for good.
+	ClosePoll(ctx context.Context, arg ClosePollParams) (int64, error)
+	CompleteIdempotencyKey(ctx context.Context, arg CompleteIdempotencyKeyParams) (int64, error)
+	// Takes one use of a link if it is still valid. Returns no row otherwise.
+	ConsumeInviteLink(ctx context.Context, id string) (ChatInviteLink, error)
+	CountScheduledMessages(ctx context.Context, arg CountScheduledMessagesParams) (int64, error)
+	CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (ChatAuditEvent, error)
 	CreateBlock(ctx context.Context, arg CreateBlockParams) error
+	CreateChannelChat(ctx context.Context, arg CreateChannelChatParams) (CreateChannelChatRow, error)
+	// Appends the folder after the user's existing ones. Returns no row when
+	// the user already has max_folders folders.
+	CreateChatFolder(ctx context.Context, arg CreateChatFolderParams) (ChatFolder, error)
+	CreateChatWithMembers(ctx context.Context, arg CreateChatWithMembersParams) (CreateChatWithMembersRow, error)
 	CreateContact(ctx context.Context, arg CreateContactParams) (Contact, error)
 	CreateContactRequest(ctx context.Context, arg CreateContactRequestParams) (ContactRequest, error)
+	CreateGroupChat(ctx context.Context, arg CreateGroupChatParams) (CreateGroupChatRow, error)
+	CreateInviteLink(ctx context.Context, arg CreateInviteLinkParams) (ChatInviteLink, error)
+	// Stores a message with the chat's next seq and its per-device payloads in
+	// one statement. With a thread_id the message is also appended to that
+	// thread; nothing is written and no row is returned when the thread is
+	// missing or closed. The sender's read position moves past the message.
+	// With ttl_seconds the message expires that long after it is sent.
+	// With poll_option_count the message carries a poll. Members named in
+	// mentioned_user_ids or mentioned_usernames, and the sender of the message
+	// replied to, get a mention unless they have a block with the sender.
+	CreateMessage(ctx context.Context, arg CreateMessageParams) (CreateMessageRow, error)
+	CreateReplyThread(ctx context.Context, arg CreateReplyThreadParams) (ChatThread, error)
+	CreateScheduledMessage(ctx context.Context, arg CreateScheduledMessageParams) (ScheduledMessage, error)
+	CreateTopic(ctx context.Context, arg CreateTopicParams) (ChatThread, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	DecideJoinRequest(ctx context.Context, arg DecideJoinRequestParams) (int64, error)
 	DeleteBlock(ctx context.Context, arg DeleteBlockParams) error
+	DeleteChat(ctx context.Context, id string) error
+	DeleteChatFolder(ctx context.Context, arg DeleteChatFolderParams) (int64, error)
 	DeleteContact(ctx context.Context, arg DeleteContactParams) error
+	// Tombstones messages: the ciphertext is wiped, the per-device payloads,
+	// edit history, reactions, polls and mentions are deleted and the messages
+	// are unpinned.
+	// Messages that are already tombstones are skipped.
+	DeleteMessagesForEveryone(ctx context.Context, arg DeleteMessagesForEveryoneParams) ([]Message, error)
+	// Cancels a scheduled message that is not being sent right now.
+	DeleteScheduledMessage(ctx context.Context, arg DeleteScheduledMessageParams) (int64, error)
+	// Closes the topic and hides it. The row stays because the topic's
+	// tombstoned messages still belong to it.
+	DeleteTopic(ctx context.Context, arg DeleteTopicParams) (int64, error)
+	// Replaces a message's ciphertext and per-device payloads with a new
+	// version and archives the previous ciphertext. Only the sender can edit,
+	// and only messages sent after editable_after; otherwise no row is returned.
+	EditMessage(ctx context.Context, arg EditMessageParams) (EditMessageRow, error)
+	// Tombstones up to batch_size expired messages like
+	// DeleteMessagesForEveryone. Concurrent reapers skip each other's rows.
+	ExpireMessages(ctx context.Context, batchSize int32) ([]ExpireMessagesRow, error)
+	FailScheduledMessage(ctx context.Context, arg FailScheduledMessageParams) error
+	// Fails the scheduled messages of a thread that are not being sent right
+	// now. A message being sent fails on its own once the thread is closed.
+	FailThreadScheduledMessages(ctx context.Context, arg FailThreadScheduledMessagesParams) ([]ScheduledMessage, error)
+	// Returns the given device IDs that are active devices of the chat's members.
+	FilterMemberDevices(ctx context.Context, arg FilterMemberDevicesParams) ([]string, error)
 	FindUserByIdentifier(ctx context.Context, arg FindUserByIdentifierParams) (User, error)
+	FindUsersByContactInfo(ctx context.Context, arg FindUsersByContactInfoParams) ([]User, error)
+	FinishScheduledMessage(ctx context.Context, id string) error
+	GetActiveUserDevice(ctx context.Context, arg GetActiveUserDeviceParams) (Device, error)
+	GetChannelByUsername(ctx context.Context, username string) (Chat, error)
+	GetChat(ctx context.Context, id string) (Chat, error)
+	GetChatByDirectKey(ctx context.Context, directKey pgtype.Text) (Chat, error)
+	GetChatFolder(ctx context.Context, arg GetChatFolderParams) (ChatFolder, error)
+	GetChatMember(ctx context.Context, arg GetChatMemberParams) (ChatMember, error)
+	// Returns how far the chat's other members have received and read it.
+	// Members who turned read receipts off are left out of the read position.
+	GetChatReceipts(ctx context.Context, arg GetChatReceiptsParams) (GetChatReceiptsRow, error)
+	GetChatState(ctx context.Context, arg GetChatStateParams) (ChatUserState, error)
 	GetContactRequest(ctx context.Context, id string) (ContactRequest, error)
+	GetContactRequestState(ctx context.Context, arg GetContactRequestStateParams) (ContactRequestState, error)
+	GetDraft(ctx context.Context, arg GetDraftParams) (Draft, error)
+	GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error)
+	GetInviteLink(ctx context.Context, id string) (ChatInviteLink, error)
+	GetInviteLinkByToken(ctx context.Context, token string) (ChatInviteLink, error)
+	GetJoinRequest(ctx context.Context, arg GetJoinRequestParams) (ChatJoinRequest, error)
+	GetMessage(ctx context.Context, arg GetMessageParams) (Message, error)
+	GetMessageByClientID(ctx context.Context, arg GetMessageByClientIDParams) (Message, error)
+	// Returns a message with the payload addressed to one of the user's devices.
+	// Messages the user deleted for themselves or cleared are not returned.
+	GetMessageForDevice(ctx context.Context, arg GetMessageForDeviceParams) (GetMessageForDeviceRow, error)
+	// Returns a poll with its vote count per option and the user's own choices.
+	GetPoll(ctx context.Context, arg GetPollParams) (GetPollRow, error)
+	GetPrivacySettings(ctx context.Context, userID string) (UserPrivacySetting, error)
+	GetReplyThread(ctx context.Context, arg GetReplyThreadParams) (ChatThread, error)
+	GetThread(ctx context.Context, arg GetThreadParams) (ChatThread, error)
+	GetThreadWithState(ctx context.Context, arg GetThreadWithStateParams) (GetThreadWithStateRow, error)
+	GetUserByID(ctx context.Context, id string) (User, error)
+	// Returns the seq of the user's newest update, 0 when there is none.
+	GetUserUpdateSeq(ctx context.Context, userID string) (int64, error)
+	// Deletes messages for one user only.
+	HideMessages(ctx context.Context, arg HideMessagesParams) error
+	IsBannedFromChat(ctx context.Context, arg IsBannedFromChatParams) (bool, error)
 	IsBlocked(ctx context.Context, arg IsBlockedParams) (bool, error)
+	IsChatMember(ctx context.Context, arg IsChatMemberParams) (bool, error)
+	ListAcceptedContactsWithUsers(ctx context.Context, ownerID string) ([]ListAcceptedContactsWithUsersRow, error)
+	// Lists a chat's audit events newer than retained_since, newest first.
+	// actions, actor_id and target_user_id narrow the list when set.
+	ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]ListAuditEventsRow, error)
+	ListChannelUpdates(ctx context.Context, arg ListChannelUpdatesParams) ([]ChatUpdate, error)
+	// Lists the chat's active bans, oldest first.
+	ListChatBans(ctx context.Context, arg ListChatBansParams) ([]ListChatBansRow, error)
+	// The user's drafts in the chats of a chat list page. Cleared drafts are
+	// left out.
+	ListChatDrafts(ctx context.Context, arg ListChatDraftsParams) ([]Draft, error)
+	ListChatFolders(ctx context.Context, userID string) ([]ChatFolder, error)
+	ListChatMemberIDs(ctx context.Context, chatID string) ([]string, error)
+	ListChatMembers(ctx context.Context, arg ListChatMembersParams) ([]ListChatMembersRow, error)
 	ListContacts(ctx context.Context, arg ListContactsParams) ([]Contact, error)
+	// Returns a page of the user's drafts in chats they are a member of, most
+	// recently changed first. Cleared drafts are left out.
+	ListDrafts(ctx context.Context, arg ListDraftsParams) ([]Draft, error)
+	ListInviteLinks(ctx context.Context, chatID string) ([]ChatInviteLink, error)
+	// Returns a page of the messages mentioning the user, newest first, with
+	// the payload addressed to one of the user's devices. Only chats the user
+	// is still a member of count, and mentions by users with a block either way
+	// are left out, as are deleted, hidden and cleared messages. chat_id
+	// narrows the feed to one chat and unread_only to unread mentions.
+	ListMentions(ctx context.Context, arg ListMentionsParams) ([]ListMentionsRow, error)
+	// Returns the earlier versions of a message, oldest first, with the payload
+	// each addressed to one of the reader's devices.
+	ListMessageEdits(ctx context.Context, arg ListMessageEditsParams) ([]ListMessageEditsRow, error)
+	// Returns the users a message mentions, whether the mention should notify
+	// them and their unread mentions in the chat. Mentions notify unless the
+	// thread is set to no notifications, or the chat is muted and the user
+	// turned off mentions for muted chats.
+	ListMessageMentions(ctx context.Context, messageID string) ([]ListMessageMentionsRow, error)
+	// Returns the other senders of the chat's messages with a seq in
+	// (after_seq, up_to_seq] and whether they share read receipts.
+	ListMessageSenders(ctx context.Context, arg ListMessageSendersParams) ([]ListMessageSendersRow, error)
+	// Returns messages newer than after_seq, oldest first. See ListMessagesBefore.
+	ListMessagesAfter(ctx context.Context, arg ListMessagesAfterParams) ([]ListMessagesAfterRow, error)
+	// Returns messages older than before_seq, newest first, with the payload
+	// addressed to one of the reader's devices. thread_id narrows the page to
+	// one topic or reply thread. Messages the reader deleted for themselves or
+	// cleared are skipped; messages deleted for everyone come back as
+	// tombstones.
+	ListMessagesBefore(ctx context.Context, arg ListMessagesBeforeParams) ([]ListMessagesBeforeRow, error)
+	ListPendingJoinRequests(ctx context.Context, arg ListPendingJoinRequestsParams) ([]ListPendingJoinRequestsRow, error)
+	ListPinnedChatIDs(ctx context.Context, userID string) ([]string, error)
+	// Returns a page of a chat's pinned messages, newest pin first, with the
+	// payload addressed to one of the user's devices. Messages the user deleted
+	// for themselves or cleared are left out.
+	ListPinnedMessages(ctx context.Context, arg ListPinnedMessagesParams) ([]ListPinnedMessagesRow, error)
+	// Returns a page of a poll's voters, earliest vote first. option narrows
+	// the page to the voters who chose it.
+	ListPollVoters(ctx context.Context, arg ListPollVotersParams) ([]ListPollVotersRow, error)
+	// Lists who reacted to a message, oldest first. emoji narrows the list to
+	// one emoji.
+	ListReactions(ctx context.Context, arg ListReactionsParams) ([]ListReactionsRow, error)
+	// Returns the sender's scheduled messages in a chat, timed ones first in
+	// sending order, then those waiting for the recipient to come online.
+	ListScheduledMessages(ctx context.Context, arg ListScheduledMessagesParams) ([]ScheduledMessage, error)
+	// The messages of a thread that are not tombstones yet.
+	ListThreadMessageIDs(ctx context.Context, threadID pgtype.Text) ([]string, error)
+	// Threads of one kind with the reader's unread count and settings, most
+	// recently active first.
+	ListThreadsWithState(ctx context.Context, arg ListThreadsWithStateParams) ([]ListThreadsWithStateRow, error)
+	// The most recently active topics of each forum chat in a chat list page.
+	ListTopicSummaries(ctx context.Context, arg ListTopicSummariesParams) ([]ListTopicSummariesRow, error)
+	// Returns the seq of the newest update of every channel the user is
+	// subscribed to.
+	ListUserChannelSeqs(ctx context.Context, userID string) ([]ListUserChannelSeqsRow, error)
+	// Lists the user's chats with their per-user state. archived and pinned
+	// narrow the list when set. With use_folder the folder rules apply:
+	// excluded chats never show, included chats always do, and other chats
+	// must match an include rule and no exclude rule.
+	ListUserChats(ctx context.Context, arg ListUserChatsParams) ([]ListUserChatsRow, error)
+	ListUserReactions(ctx context.Context, arg ListUserReactionsParams) ([]string, error)
+	ListUserUpdates(ctx context.Context, arg ListUserUpdatesParams) ([]UserUpdate, error)
+	// Returns the given messages of a chat that the user can still see.
+	ListVisibleMessages(ctx context.Context, arg ListVisibleMessagesParams) ([]Message, error)
+	// Locks a message for a change to its reactions and returns its counts.
+	// Statements after it in the transaction see the reactions that were
+	// committed while it waited.
+	LockMessageReactions(ctx context.Context, arg LockMessageReactionsParams) (json.RawMessage, error)
+	// Moves a device's delivery position forward. Returns the device's new
+	// position and the highest position any of the user's devices had before,
+	// or no row when the device is not an active device of the user.
+	MarkChatDelivered(ctx context.Context, arg MarkChatDeliveredParams) (MarkChatDeliveredRow, error)
+	// Moves the user's read position forward to read_seq and clears the
+	// marked-unread flag. Returns the new position and the one before.
+	MarkChatRead(ctx context.Context, arg MarkChatReadParams) (MarkChatReadRow, error)
+	// Moves the read position forward only, never past the thread's last message.
+	MarkThreadRead(ctx context.Context, arg MarkThreadReadParams) (int64, error)
+	// Appends the chat to the end of the user's pinned list. Returns no row
+	// when the list is already full.
+	PinChat(ctx context.Context, arg PinChatParams) (ChatUserState, error)
+	// Pins a message that has not been deleted. Pinning a pinned message again
+	// changes nothing.
+	PinMessage(ctx context.Context, arg PinMessageParams) (int64, error)
+	PurgeAuditEvents(ctx context.Context, olderThan pgtype.Timestamptz) (int64, error)
+	PurgeExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+	// Tombstones messages of a chat up to max_seq that no member can see any
+	// more because every member deleted them for themselves or cleared the
+	// history. ids narrows the check to the given messages.
+	PurgeUnreferencedMessages(ctx context.Context, arg PurgeUnreferencedMessagesParams) ([]string, error)
+	// Marks all of the user's mentions in a chat read.
+	ReadChatMentions(ctx context.Context, arg ReadChatMentionsParams) (int64, error)
+	// Counts a view of each post once per user and returns the current counters.
+	// IDs that are not live posts of the channel are ignored.
+	// The final SELECT sees the counters as of the statement start, so posts
+	// bumped by this call are taken from the bumped CTE instead.
+	RecordPostViews(ctx context.Context, arg RecordPostViewsParams) ([]RecordPostViewsRow, error)
+	ReleaseIdempotencyKey(ctx context.Context, arg ReleaseIdempotencyKeyParams) error
+	RemoveChatMember(ctx context.Context, arg RemoveChatMemberParams) (int64, error)
+	// Removes a reaction and lowers the message's count for the emoji, dropping
+	// emoji nobody reacts with any more. No row is returned when the user had
+	// no such reaction.
+	RemoveReaction(ctx context.Context, arg RemoveReactionParams) (json.RawMessage, error)
+	ReorderChatFolders(ctx context.Context, arg ReorderChatFoldersParams) error
+	ReorderPinnedChats(ctx context.Context, arg ReorderPinnedChatsParams) error
+	RestrictChatMember(ctx context.Context, arg RestrictChatMemberParams) (int64, error)
+	// Removes the user's vote from an open poll that is not a quiz.
+	RetractPollVote(ctx context.Context, arg RetractPollVoteParams) (int64, error)
+	RevokeInviteLink(ctx context.Context, arg RevokeInviteLinkParams) (int64, error)
+	// Stores a draft unless the stored one has the same or a newer client
+	// timestamp, in which case no row is returned.
+	SaveDraft(ctx context.Context, arg SaveDraftParams) (Draft, error)
+	SetChatAllowedReactions(ctx context.Context, arg SetChatAllowedReactionsParams) (int64, error)
+	SetChatForum(ctx context.Context, arg SetChatForumParams) (int64, error)
+	SetChatMessageTTL(ctx context.Context, arg SetChatMessageTTLParams) (int64, error)
+	SetChatSlowMode(ctx context.Context, arg SetChatSlowModeParams) (int64, error)
+	TouchUserPresence(ctx context.Context, userID string) error
+	// Swaps roles in one statement: the new owner is promoted and the current
+	// owner becomes an admin. Returns 2 affected rows on success.
+	TransferChatOwnership(ctx context.Context, arg TransferChatOwnershipParams) (int64, error)
+	UnbanChatMember(ctx context.Context, arg UnbanChatMemberParams) (int64, error)
+	// Unpins every message of a chat and returns the unpinned message IDs.
+	UnpinAllMessages(ctx context.Context, chatID string) ([]string, error)
+	UnpinChat(ctx context.Context, arg UnpinChatParams) (ChatUserState, error)
+	UnpinMessage(ctx context.Context, arg UnpinMessageParams) (int64, error)
+	UpdateChatFolder(ctx context.Context, arg UpdateChatFolderParams) (ChatFolder, error)
+	UpdateChatInfo(ctx context.Context, arg UpdateChatInfoParams) (Chat, error)
+	UpdateChatMemberPermissions(ctx context.Context, arg UpdateChatMemberPermissionsParams) (int64, error)
+	// Changing a role resets per-member overrides to the new role's defaults.
+	UpdateChatMemberRole(ctx context.Context, arg UpdateChatMemberRoleParams) (int64, error)
+	UpdateChatPermissions(ctx context.Context, arg UpdateChatPermissionsParams) error
+	UpdateChatUsername(ctx context.Context, arg UpdateChatUsernameParams) (int64, error)
 	UpdateContactRequestState(ctx context.Context, arg UpdateContactRequestStateParams) error
+	// Changes the content or the time of a scheduled message that is not being
+	// sent right now, and clears an earlier failure. A new send_at replaces
+	// waiting for the recipient to come online.
+	UpdateScheduledMessage(ctx context.Context, arg UpdateScheduledMessageParams) (ScheduledMessage, error)
+	UpdateThreadNotifications(ctx context.Context, arg UpdateThreadNotificationsParams) error
+	UpdateTopic(ctx context.Context, arg UpdateTopicParams) (ChatThread, error)
+	// Changes the provided fields only; muted_until is replaced when set_mute
+	// is true, with NULL meaning unmuted.
+	UpsertChatState(ctx context.Context, arg UpsertChatStateParams) (ChatUserState, error)
+	UpsertJoinRequest(ctx context.Context, arg UpsertJoinRequestParams) (ChatJoinRequest, error)
+	// Changes the provided settings only.
+	UpsertPrivacySettings(ctx context.Context, arg UpsertPrivacySettingsParams) (UserPrivacySetting, error)
+	// Stores the user's choices on an open poll, replacing earlier ones. A quiz
+	// answer cannot be changed. Nothing is written when the poll is closed or
+	// the choices are unchanged, so repeating a vote is a no-op.
+	VotePoll(ctx context.Context, arg VotePollParams) (int64, error)
 }
 
 var _ Querier = (*Queries)(nil)
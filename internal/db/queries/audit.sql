@@ -0,0 +1,25 @@
+-- name: CreateAuditEvent :one
+INSERT INTO chat_audit_events (id, chat_id, actor_id, action, target_user_id, target_id, before, after)
+VALUES (@id, @chat_id, @actor_id, @action, sqlc.narg(target_user_id), sqlc.narg(target_id), @before, @after)
+RETURNING *;
+
+-- name: ListAuditEvents :many
+-- Lists a chat's audit events newer than retained_since, newest first.
+-- actions, actor_id and target_user_id narrow the list when set.
+SELECT e.*, actor.username AS actor_username, target.username AS target_username
+FROM chat_audit_events e
+LEFT JOIN users actor ON actor.id = e.actor_id
+LEFT JOIN users target ON target.id = e.target_user_id
+WHERE e.chat_id = @chat_id
+  AND e.created_at > @retained_since
+  AND (cardinality(@actions::text[]) = 0 OR e.action = ANY(@actions::text[]))
+  AND (sqlc.narg(actor_id)::text IS NULL OR e.actor_id = sqlc.narg(actor_id)::text)
+  AND (sqlc.narg(target_user_id)::text IS NULL OR e.target_user_id = sqlc.narg(target_user_id)::text)
+  AND (sqlc.narg(cursor_created_at)::timestamptz IS NULL
+       OR (e.created_at, e.id) < (sqlc.narg(cursor_created_at)::timestamptz, sqlc.narg(cursor_id)::text))
+ORDER BY e.created_at DESC, e.id DESC
+LIMIT @page_size;
+
+-- name: PurgeAuditEvents :execrows
+DELETE FROM chat_audit_events
+WHERE created_at <= @older_than;
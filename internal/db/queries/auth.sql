@@ -2,3 +2,7 @@
 INSERT INTO users (id, username, email, phone, hashed_password)
 VALUES ($1, $2, $3, $4, $5)
 RETURNING *;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
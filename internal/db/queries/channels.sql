@@ -0,0 +1,46 @@
+-- name: CreateChannelChat :one
+WITH chat AS (
+    INSERT INTO chats (id, type, title, photo_url, username, created_by, member_count, member_permissions, admin_permissions)
+    VALUES (@id, 'channel', @title, sqlc.narg(photo_url), sqlc.narg(username), @owner_id::text, 1, @member_permissions, @admin_permissions)
+    RETURNING *
+), owner AS (
+    INSERT INTO chat_members (chat_id, user_id, role)
+    SELECT chat.id, @owner_id::text, 'owner' FROM chat
+)
+SELECT * FROM chat;
+
+-- name: GetChannelByUsername :one
+SELECT * FROM chats
+WHERE type = 'channel' AND lower(username) = lower(@username::text);
+
+-- name: UpdateChatUsername :execrows
+UPDATE chats
+SET username = sqlc.narg(username), updated_at = NOW()
+WHERE id = @id;
+
+-- name: RecordPostViews :many
+-- Counts a view of each post once per user and returns the current counters.
+-- IDs that are not live posts of the channel are ignored.
+-- The final SELECT sees the counters as of the statement start, so posts
+-- bumped by this call are taken from the bumped CTE instead.
+WITH inserted AS (
+    INSERT INTO channel_post_views (chat_id, message_id, user_id)
+    SELECT @chat_id::text, m.id, @user_id::text
+    FROM unnest(@message_ids::text[]) AS message_id
+    JOIN messages m ON m.id = message_id AND m.chat_id = @chat_id::text AND m.deleted_at IS NULL
+    ON CONFLICT DO NOTHING
+    RETURNING message_id
+), bumped AS (
+    INSERT INTO channel_post_stats (chat_id, message_id, view_count)
+    SELECT @chat_id::text, message_id, 1 FROM inserted
+    ON CONFLICT (chat_id, message_id) DO UPDATE
+    SET view_count = channel_post_stats.view_count + 1
+    RETURNING message_id, view_count
+)
+SELECT bumped.message_id, bumped.view_count FROM bumped
+UNION ALL
+SELECT s.message_id, s.view_count
+FROM channel_post_stats s
+WHERE s.chat_id = @chat_id::text
+  AND s.message_id = ANY(@message_ids::text[])
+  AND s.message_id NOT IN (SELECT message_id FROM bumped);
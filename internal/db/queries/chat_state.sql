@@ -0,0 +1,92 @@
+-- name: GetChatState :one
+SELECT * FROM chat_user_states
+WHERE user_id = @user_id AND chat_id = @chat_id;
+
+-- name: UpsertChatState :one
+-- Changes the provided fields only; muted_until is replaced when set_mute
+-- is true, with NULL meaning unmuted.
+INSERT INTO chat_user_states (user_id, chat_id, archived, marked_unread, muted_until, mention_notifications)
+VALUES (
+    @user_id, @chat_id,
+    COALESCE(sqlc.narg(archived)::bool, false),
+    COALESCE(sqlc.narg(marked_unread)::bool, false),
+    CASE WHEN @set_mute::bool THEN sqlc.narg(muted_until)::timestamptz END,
+    COALESCE(sqlc.narg(mention_notifications)::bool, true)
+)
+ON CONFLICT (user_id, chat_id) DO UPDATE
+SET archived      = COALESCE(sqlc.narg(archived)::bool, chat_user_states.archived),
+    marked_unread = COALESCE(sqlc.narg(marked_unread)::bool, chat_user_states.marked_unread),
+    muted_until   = CASE WHEN @set_mute::bool THEN sqlc.narg(muted_until)::timestamptz ELSE chat_user_states.muted_until END,
+    mention_notifications = COALESCE(sqlc.narg(mention_notifications)::bool, chat_user_states.mention_notifications),
+    updated_at    = NOW()
+RETURNING *;
+
+-- name: PinChat :one
+-- Appends the chat to the end of the user's pinned list. Returns no row
+-- when the list is already full.
+INSERT INTO chat_user_states (user_id, chat_id, pinned_rank)
+SELECT @user_id::text, @chat_id::text, COALESCE(MAX(pinned_rank), 0) + 1
+FROM chat_user_states
+WHERE user_id = @user_id::text
+HAVING count(pinned_rank) < @max_pinned::int
+ON CONFLICT (user_id, chat_id) DO UPDATE
+SET pinned_rank = EXCLUDED.pinned_rank, updated_at = NOW()
+RETURNING *;
+
+-- name: UnpinChat :one
+UPDATE chat_user_states
+SET pinned_rank = NULL, updated_at = NOW()
+WHERE user_id = @user_id AND chat_id = @chat_id
+RETURNING *;
+
+-- name: ListPinnedChatIDs :many
+SELECT chat_id FROM chat_user_states
+WHERE user_id = @user_id AND pinned_rank IS NOT NULL
+ORDER BY pinned_rank;
+
+-- name: ReorderPinnedChats :exec
+UPDATE chat_user_states s
+SET pinned_rank = o.rank::int, updated_at = NOW()
+FROM unnest(@chat_ids::text[]) WITH ORDINALITY AS o(chat_id, rank)
+WHERE s.user_id = @user_id AND s.chat_id = o.chat_id AND s.pinned_rank IS NOT NULL;
+
+-- name: CreateChatFolder :one
+-- Appends the folder after the user's existing ones. Returns no row when
+-- the user already has max_folders folders.
+INSERT INTO chat_folders (id, user_id, title, position, include_rules, exclude_rules, included_chat_ids, excluded_chat_ids)
+SELECT @id, @user_id::text, @title, COALESCE(MAX(position), 0) + 1,
+       @include_rules::text[], @exclude_rules::text[], @included_chat_ids::text[], @excluded_chat_ids::text[]
+FROM chat_folders
+WHERE user_id = @user_id::text
+HAVING count(*) < @max_folders::int
+RETURNING *;
+
+-- name: UpdateChatFolder :one
+UPDATE chat_folders
+SET title = @title,
+    include_rules = @include_rules::text[],
+    exclude_rules = @exclude_rules::text[],
+    included_chat_ids = @included_chat_ids::text[],
+    excluded_chat_ids = @excluded_chat_ids::text[],
+    updated_at = NOW()
+WHERE id = @id AND user_id = @user_id
+RETURNING *;
+
+-- name: GetChatFolder :one
+SELECT * FROM chat_folders
+WHERE id = @id AND user_id = @user_id;
+
+-- name: ListChatFolders :many
+SELECT * FROM chat_folders
+WHERE user_id = @user_id
+ORDER BY position, id;
+
+-- name: DeleteChatFolder :execrows
+DELETE FROM chat_folders
+WHERE id = @id AND user_id = @user_id;
+
+-- name: ReorderChatFolders :exec
+UPDATE chat_folders f
+SET position = o.position::int, updated_at = NOW()
+FROM unnest(@folder_ids::text[]) WITH ORDINALITY AS o(id, position)
+WHERE f.user_id = @user_id AND f.id = o.id;
@@ -0,0 +1,179 @@
+-- name: CreateChatWithMembers :one
+WITH chat AS (
+    INSERT INTO chats (id, type, direct_key, created_by, member_count, member_permissions, admin_permissions)
+    VALUES (@id, @type, sqlc.narg(direct_key), sqlc.narg(created_by), cardinality(@member_ids::text[]), @member_permissions, @admin_permissions)
+    ON CONFLICT (direct_key) DO NOTHING
+    RETURNING *
+), members AS (
+    INSERT INTO chat_members (chat_id, user_id)
+    SELECT chat.id, member_id
+    FROM chat, unnest(@member_ids::text[]) AS member_id
+)
+SELECT * FROM chat;
+
+-- name: GetChat :one
+SELECT * FROM chats
+WHERE id = $1;
+
+-- name: GetChatByDirectKey :one
+SELECT * FROM chats
+WHERE direct_key = $1;
+
+-- name: IsChatMember :one
+SELECT EXISTS(
+    SELECT 1 FROM chat_members
+    WHERE chat_id = $1 AND user_id = $2
+);
+
+-- name: ListUserChats :many
+-- Lists the user's chats with their per-user state. archived and pinned
+-- narrow the list when set. With use_folder the folder rules apply:
+-- excluded chats never show, included chats always do, and other chats
+-- must match an include rule and no exclude rule.
+SELECT c.*,
+       peer.user_id AS peer_id,
+       s.pinned_rank,
+       COALESCE(s.archived, false)::bool AS archived,
+       s.muted_until,
+       COALESCE(s.marked_unread, false)::bool AS marked_unread,
+       COALESCE(s.read_seq, 0)::bigint AS read_seq,
+       GREATEST(c.last_seq - COALESCE(s.read_seq, 0), 0)::bigint AS unread_count,
+       (SELECT count(*) FROM message_mentions mm
+        JOIN messages mg ON mg.id = mm.message_id AND mg.deleted_at IS NULL
+        WHERE mm.user_id = @user_id AND mm.chat_id = c.id AND NOT mm.read
+          AND mm.seq > GREATEST(COALESCE(s.read_seq, 0), COALESCE(s.cleared_seq, 0))
+          AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.user_id = mm.user_id AND h.message_id = mm.message_id)
+          AND NOT EXISTS (
+              SELECT 1 FROM blocks b
+              WHERE (b.owner_id = mm.user_id AND b.target_user_id = mg.sender_id)
+                 OR (b.owner_id = mg.sender_id AND b.target_user_id = mm.user_id)
+          ))::bigint AS unread_mentions
+FROM chats c
+JOIN chat_members m ON m.chat_id = c.id AND m.user_id = @user_id
+LEFT JOIN chat_members peer ON peer.chat_id = c.id AND c.type = 'direct' AND peer.user_id <> @user_id
+LEFT JOIN chat_user_states s ON s.chat_id = c.id AND s.user_id = @user_id
+WHERE (sqlc.narg(cursor_activity)::timestamptz IS NULL
+       OR (c.last_activity_at, c.id) < (sqlc.narg(cursor_activity)::timestamptz, sqlc.narg(cursor_id)::text))
+  AND (sqlc.narg(archived)::bool IS NULL OR COALESCE(s.archived, false) = sqlc.narg(archived)::bool)
+  AND (sqlc.narg(pinned)::bool IS NULL OR (s.pinned_rank IS NOT NULL) = sqlc.narg(pinned)::bool)
+  AND (NOT @use_folder::bool OR (
+        NOT c.id = ANY(@excluded_chat_ids::text[])
+        AND (c.id = ANY(@included_chat_ids::text[]) OR (
+            (
+                (c.type = 'group' AND 'groups' = ANY(@include_rules::text[]))
+                OR (c.type = 'channel' AND 'channels' = ANY(@include_rules::text[]))
+                OR (c.type = 'direct' AND (
+                    CASE WHEN EXISTS (
+                        SELECT 1 FROM contacts ct
+                        WHERE ct.owner_id = @user_id AND ct.peer_id = peer.user_id AND ct.state = 'accepted'
+                    ) THEN 'contacts' ELSE 'non_contacts' END
+                ) = ANY(@include_rules::text[]))
+            )
+            AND NOT ('muted' = ANY(@exclude_rules::text[]) AND COALESCE(s.muted_until > NOW(), false))
+            AND NOT ('read' = ANY(@exclude_rules::text[]) AND NOT COALESCE(s.marked_unread, false) AND c.last_seq <= COALESCE(s.read_seq, 0))
+            AND NOT ('archived' = ANY(@exclude_rules::text[]) AND COALESCE(s.archived, false))
+        ))
+  ))
+ORDER BY c.last_activity_at DESC, c.id DESC
+LIMIT @page_size;
+
+-- name: CreateGroupChat :one
+WITH chat AS (
+    INSERT INTO chats (id, type, title, photo_url, created_by, member_count, member_permissions, admin_permissions)
+    VALUES (@id, 'group', @title, sqlc.narg(photo_url), @owner_id::text, cardinality(@member_ids::text[]) + 1, @member_permissions, @admin_permissions)
+    RETURNING *
+), owner AS (
+    INSERT INTO chat_members (chat_id, user_id, role)
+    SELECT chat.id, @owner_id::text, 'owner' FROM chat
+), members AS (
+    INSERT INTO chat_members (chat_id, user_id, role, invited_by)
+    SELECT chat.id, member_id, 'member', @owner_id::text
+    FROM chat, unnest(@member_ids::text[]) AS member_id
+)
+SELECT * FROM chat;
+
+-- name: DeleteChat :exec
+DELETE FROM chats
+WHERE id = $1;
+
+-- name: GetChatMember :one
+SELECT * FROM chat_members
+WHERE chat_id = $1 AND user_id = $2;
+
+-- name: AddChatMember :execrows
+-- The member count is reserved with a conditional UPDATE so concurrent adds
+-- cannot exceed max_members; a duplicate member aborts the whole statement.
+WITH slot AS (
+    UPDATE chats
+    SET member_count = member_count + 1, updated_at = NOW()
+    WHERE id = @chat_id AND member_count < @max_members::int
+    RETURNING id
+)
+INSERT INTO chat_members (chat_id, user_id, role, invited_by)
+SELECT slot.id, @user_id, @role, sqlc.narg(invited_by) FROM slot;
+
+-- name: RemoveChatMember :execrows
+WITH removed AS (
+    DELETE FROM chat_members
+    WHERE chat_members.chat_id = @chat_id AND chat_members.user_id = @user_id
+    RETURNING chat_members.chat_id
+)
+UPDATE chats
+SET member_count = member_count - 1, updated_at = NOW()
+FROM removed
+WHERE chats.id = removed.chat_id;
+
+-- name: UpdateChatMemberRole :execrows
+-- Changing a role resets per-member overrides to the new role's defaults.
+UPDATE chat_members
+SET role = $3, permissions = NULL, custom_title = NULL
+WHERE chat_id = $1 AND user_id = $2;
+
+-- name: UpdateChatMemberPermissions :execrows
+UPDATE chat_members
+SET permissions = sqlc.narg(permissions), custom_title = sqlc.narg(custom_title)
+WHERE chat_id = @chat_id AND user_id = @user_id;
+
+-- name: UpdateChatPermissions :exec
+UPDATE chats
+SET member_permissions = $2, admin_permissions = $3, updated_at = NOW()
+WHERE id = $1;
+
+-- name: UpdateChatInfo :one
+UPDATE chats
+SET title = COALESCE(sqlc.narg(title), title),
+    photo_url = COALESCE(sqlc.narg(photo_url), photo_url),
+    updated_at = NOW()
+WHERE id = @id
+RETURNING *;
+
+-- name: TransferChatOwnership :execrows
+-- Swaps roles in one statement: the new owner is promoted and the current
+-- owner becomes an admin. Returns 2 affected rows on success.
+UPDATE chat_members
+SET role = CASE WHEN user_id = @new_owner_id::text THEN 'owner'::chat_member_role ELSE 'admin'::chat_member_role END,
+    permissions = NULL
+WHERE chat_members.chat_id = @chat_id::text
+  AND chat_members.user_id IN (@current_owner_id::text, @new_owner_id::text)
+  AND EXISTS (
+      SELECT 1 FROM chat_members owner
+      WHERE owner.chat_id = @chat_id::text AND owner.user_id = @current_owner_id::text AND owner.role = 'owner'
+  )
+  AND EXISTS (
+      SELECT 1 FROM chat_members successor
+      WHERE successor.chat_id = @chat_id::text AND successor.user_id = @new_owner_id::text
+  );
+
+-- name: ListChatMembers :many
+SELECT m.*, u.username
+FROM chat_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.chat_id = @chat_id
+  AND (sqlc.narg(cursor_joined_at)::timestamptz IS NULL
+       OR (m.joined_at, m.user_id) > (sqlc.narg(cursor_joined_at)::timestamptz, sqlc.narg(cursor_user_id)::text))
+ORDER BY m.joined_at, m.user_id
+LIMIT @page_size;
+
+-- name: ListChatMemberIDs :many
+SELECT user_id FROM chat_members
+WHERE chat_id = @chat_id;
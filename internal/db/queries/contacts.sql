@@ -44,3 +44,19 @@ SELECT EXISTS(
     SELECT 1 FROM blocks
     WHERE (owner_id = $1 AND target_user_id = $2) OR (owner_id = $2 AND target_user_id = $1)
 );
+
+-- name: FindUsersByContactInfo :many
+SELECT *
+FROM users
+WHERE phone = ANY(@phones::text[]) OR lower(email) = ANY(@emails::text[]);
+
+-- name: GetContactRequestState :one
+SELECT state FROM contact_requests
+WHERE from_user_id = $1 AND to_user_id = $2;
+
+-- name: ListAcceptedContactsWithUsers :many
+SELECT c.peer_id, c.alias, u.username, u.phone, u.email
+FROM contacts c
+JOIN users u ON u.id = c.peer_id
+WHERE c.owner_id = $1 AND c.state = 'accepted'
+ORDER BY COALESCE(c.alias, u.username);
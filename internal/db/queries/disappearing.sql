@@ -0,0 +1,32 @@
+-- name: SetChatMessageTTL :execrows
+UPDATE chats
+SET message_ttl_seconds = sqlc.narg(message_ttl_seconds)::int, updated_at = NOW()
+WHERE id = @id;
+
+-- name: ExpireMessages :many
+-- Tombstones up to batch_size expired messages like
+-- DeleteMessagesForEveryone. Concurrent reapers skip each other's rows.
+WITH target AS (
+    SELECT id FROM messages
+    WHERE expires_at <= NOW() AND deleted_at IS NULL
+    ORDER BY expires_at
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+), payloads AS (
+    DELETE FROM message_device_payloads p USING target WHERE p.message_id = target.id
+), edits AS (
+    DELETE FROM message_edits e USING target WHERE e.message_id = target.id
+), reactions AS (
+    DELETE FROM message_reactions r USING target WHERE r.message_id = target.id
+), pins AS (
+    DELETE FROM pinned_messages pm USING target WHERE pm.message_id = target.id
+), polls AS (
+    DELETE FROM polls pl USING target WHERE pl.message_id = target.id
+), mentions AS (
+    DELETE FROM message_mentions mm USING target WHERE mm.message_id = target.id
+)
+UPDATE messages m
+SET ciphertext = ''::bytea, reactions = '{}', deleted_at = NOW()
+FROM target
+WHERE m.id = target.id
+RETURNING m.chat_id, m.id;
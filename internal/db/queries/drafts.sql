@@ -0,0 +1,48 @@
+-- name: SaveDraft :one
+-- Stores a draft unless the stored one has the same or a newer client
+-- timestamp, in which case no row is returned.
+INSERT INTO drafts (user_id, chat_id, thread_id, ciphertext, reply_to_id, client_updated_at)
+VALUES (@user_id, @chat_id, sqlc.narg(thread_id), @ciphertext, sqlc.narg(reply_to_id), @client_updated_at)
+ON CONFLICT (user_id, chat_id, (COALESCE(thread_id, ''))) DO UPDATE
+SET ciphertext        = EXCLUDED.ciphertext,
+    reply_to_id       = EXCLUDED.reply_to_id,
+    client_updated_at = EXCLUDED.client_updated_at,
+    updated_at        = NOW()
+WHERE drafts.client_updated_at < EXCLUDED.client_updated_at
+RETURNING *;
+
+-- name: GetDraft :one
+SELECT * FROM drafts
+WHERE user_id = @user_id AND chat_id = @chat_id
+  AND COALESCE(thread_id, '') = COALESCE(sqlc.narg(thread_id)::text, '');
+
+-- name: ListDrafts :many
+-- Returns a page of the user's drafts in chats they are a member of, most
+-- recently changed first. Cleared drafts are left out.
+SELECT d.* FROM drafts d
+JOIN chat_members m ON m.chat_id = d.chat_id AND m.user_id = d.user_id
+WHERE d.user_id = @user_id AND (d.ciphertext <> ''::bytea OR d.reply_to_id IS NOT NULL)
+  AND (sqlc.narg(cursor_updated_at)::timestamptz IS NULL
+       OR (d.updated_at, d.chat_id, COALESCE(d.thread_id, ''))
+          < (sqlc.narg(cursor_updated_at)::timestamptz, sqlc.narg(cursor_chat_id)::text, sqlc.narg(cursor_thread_id)::text))
+ORDER BY d.updated_at DESC, d.chat_id DESC, COALESCE(d.thread_id, '') DESC
+LIMIT @page_size;
+
+-- name: ListChatDrafts :many
+-- The user's drafts in the chats of a chat list page. Cleared drafts are
+-- left out.
+SELECT * FROM drafts
+WHERE user_id = @user_id AND chat_id = ANY(@chat_ids::text[])
+  AND (ciphertext <> ''::bytea OR reply_to_id IS NOT NULL)
+ORDER BY chat_id, COALESCE(thread_id, '');
+
+-- name: ClearThreadDrafts :many
+-- Clears every user's draft in a thread. The client timestamp moves to now
+-- so that the cleared draft wins over the copies on users' devices.
+UPDATE drafts
+SET ciphertext        = ''::bytea,
+    reply_to_id       = NULL,
+    client_updated_at = GREATEST(client_updated_at, NOW()),
+    updated_at        = NOW()
+WHERE thread_id = @thread_id AND (ciphertext <> ''::bytea OR reply_to_id IS NOT NULL)
+RETURNING *;
@@ -0,0 +1,31 @@
+-- name: ClaimIdempotencyKey :execrows
+-- Claims a key for a new request. An expired entry, or one whose request
+-- never finished, is taken over; a live entry leaves the claim at zero rows.
+INSERT INTO idempotency_keys (user_id, key, request_hash, expires_at)
+VALUES (@user_id, @key, @request_hash, @expires_at)
+ON CONFLICT (user_id, key) DO UPDATE
+SET request_hash = EXCLUDED.request_hash,
+    status_code = NULL,
+    content_type = NULL,
+    response_body = NULL,
+    created_at = NOW(),
+    expires_at = EXCLUDED.expires_at
+WHERE idempotency_keys.expires_at <= NOW()
+   OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < @stale_before);
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE user_id = @user_id AND key = @key;
+
+-- name: CompleteIdempotencyKey :execrows
+UPDATE idempotency_keys
+SET status_code = @status_code, content_type = @content_type, response_body = @response_body
+WHERE user_id = @user_id AND key = @key AND status_code IS NULL;
+
+-- name: ReleaseIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE user_id = @user_id AND key = @key AND status_code IS NULL;
+
+-- name: PurgeExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys
+WHERE expires_at <= NOW();
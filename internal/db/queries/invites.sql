@@ -0,0 +1,66 @@
+-- name: CreateInviteLink :one
+INSERT INTO chat_invite_links (id, chat_id, token, name, created_by, expires_at, usage_limit, requires_approval)
+VALUES ($1, $2, $3, sqlc.narg(name), $4, sqlc.narg(expires_at), sqlc.narg(usage_limit), $5)
+RETURNING *;
+
+-- name: GetInviteLink :one
+SELECT * FROM chat_invite_links
+WHERE id = $1;
+
+-- name: GetInviteLinkByToken :one
+SELECT * FROM chat_invite_links
+WHERE token = $1;
+
+-- name: ListInviteLinks :many
+SELECT * FROM chat_invite_links
+WHERE chat_id = $1
+ORDER BY created_at DESC;
+
+-- name: RevokeInviteLink :execrows
+UPDATE chat_invite_links
+SET revoked_at = NOW()
+WHERE id = $1 AND chat_id = $2 AND revoked_at IS NULL;
+
+-- name: ConsumeInviteLink :one
+-- Takes one use of a link if it is still valid. Returns no row otherwise.
+UPDATE chat_invite_links
+SET usage_count = usage_count + 1
+WHERE id = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW())
+  AND (usage_limit IS NULL OR usage_count < usage_limit)
+RETURNING *;
+
+-- name: UpsertJoinRequest :one
+INSERT INTO chat_join_requests (chat_id, user_id, invite_link_id)
+VALUES ($1, $2, sqlc.narg(invite_link_id))
+ON CONFLICT (chat_id, user_id) DO UPDATE
+SET state = 'pending', invite_link_id = EXCLUDED.invite_link_id,
+    decided_by = NULL, decided_at = NULL, created_at = NOW()
+WHERE chat_join_requests.state <> 'pending'
+RETURNING *;
+
+-- name: GetJoinRequest :one
+SELECT * FROM chat_join_requests
+WHERE chat_id = $1 AND user_id = $2;
+
+-- name: DecideJoinRequest :execrows
+UPDATE chat_join_requests
+SET state = $3, decided_by = $4, decided_at = NOW()
+WHERE chat_id = $1 AND user_id = $2 AND state = 'pending';
+
+-- name: ListPendingJoinRequests :many
+SELECT r.*, u.username
+FROM chat_join_requests r
+JOIN users u ON u.id = r.user_id
+WHERE r.chat_id = @chat_id AND r.state = 'pending'
+  AND (sqlc.narg(cursor_created_at)::timestamptz IS NULL
+       OR (r.created_at, r.user_id) > (sqlc.narg(cursor_created_at)::timestamptz, sqlc.narg(cursor_user_id)::text))
+ORDER BY r.created_at, r.user_id
+LIMIT @page_size;
+
+-- name: IsBannedFromChat :one
+SELECT EXISTS(
+    SELECT 1 FROM chat_bans
+    WHERE chat_id = $1 AND user_id = $2 AND (until_at IS NULL OR until_at > NOW())
+);
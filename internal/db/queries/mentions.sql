@@ -0,0 +1,54 @@
+-- name: ListMessageMentions :many
+-- Returns the users a message mentions, whether the mention should notify
+-- them and their unread mentions in the chat. Mentions notify unless the
+-- thread is set to no notifications, or the chat is muted and the user
+-- turned off mentions for muted chats.
+SELECT mm.user_id, mm.is_reply,
+       ((NOT COALESCE(s.muted_until > NOW(), false) OR COALESCE(s.mention_notifications, true))
+        AND COALESCE(ts.notify_level, 'all') <> 'none'
+        AND NOT COALESCE(ts.muted_until > NOW(), false))::bool AS notify,
+       (SELECT count(*) FROM message_mentions u
+        WHERE u.user_id = mm.user_id AND u.chat_id = mm.chat_id AND NOT u.read
+          AND u.seq > COALESCE(s.read_seq, 0))::bigint AS unread_mentions
+FROM message_mentions mm
+JOIN messages m ON m.id = mm.message_id
+LEFT JOIN chat_user_states s ON s.user_id = mm.user_id AND s.chat_id = mm.chat_id
+LEFT JOIN thread_member_states ts ON ts.thread_id = m.thread_id AND ts.user_id = mm.user_id
+WHERE mm.message_id = @message_id;
+
+-- name: ListMentions :many
+-- Returns a page of the messages mentioning the user, newest first, with
+-- the payload addressed to one of the user's devices. Only chats the user
+-- is still a member of count, and mentions by users with a block either way
+-- are left out, as are deleted, hidden and cleared messages. chat_id
+-- narrows the feed to one chat and unread_only to unread mentions.
+SELECT mm.is_reply,
+       (NOT mm.read AND mm.seq > COALESCE(s.read_seq, 0))::bool AS unread,
+       mm.created_at AS mentioned_at,
+       m.*, dp.ciphertext AS device_ciphertext
+FROM message_mentions mm
+JOIN messages m ON m.id = mm.message_id AND m.deleted_at IS NULL
+JOIN chat_members cm ON cm.chat_id = mm.chat_id AND cm.user_id = mm.user_id
+LEFT JOIN chat_user_states s ON s.user_id = mm.user_id AND s.chat_id = mm.chat_id
+LEFT JOIN devices d ON d.id = sqlc.narg(device_id)::text AND d.user_id = mm.user_id
+LEFT JOIN message_device_payloads dp ON dp.message_id = m.id AND dp.version = m.version AND dp.device_id = d.id
+WHERE mm.user_id = @user_id
+  AND (sqlc.narg(chat_id)::text IS NULL OR mm.chat_id = sqlc.narg(chat_id)::text)
+  AND (NOT @unread_only::bool OR (NOT mm.read AND mm.seq > COALESCE(s.read_seq, 0)))
+  AND mm.seq > COALESCE(s.cleared_seq, 0)
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.user_id = mm.user_id AND h.message_id = mm.message_id)
+  AND NOT EXISTS (
+      SELECT 1 FROM blocks b
+      WHERE (b.owner_id = mm.user_id AND b.target_user_id = m.sender_id)
+         OR (b.owner_id = m.sender_id AND b.target_user_id = mm.user_id)
+  )
+  AND (sqlc.narg(cursor_mentioned_at)::timestamptz IS NULL
+       OR (mm.created_at, mm.message_id) < (sqlc.narg(cursor_mentioned_at)::timestamptz, sqlc.narg(cursor_message_id)::text))
+ORDER BY mm.created_at DESC, mm.message_id DESC
+LIMIT @page_size;
+
+-- name: ReadChatMentions :execrows
+-- Marks all of the user's mentions in a chat read.
+UPDATE message_mentions
+SET read = TRUE
+WHERE user_id = @user_id AND chat_id = @chat_id AND NOT read;
@@ -0,0 +1,268 @@
+-- name: CreateMessage :one
+-- Stores a message with the chat's next seq and its per-device payloads in
+-- one statement. With a thread_id the message is also appended to that
+-- thread; nothing is written and no row is returned when the thread is
+-- missing or closed. The sender's read position moves past the message.
+-- With ttl_seconds the message expires that long after it is sent.
+-- With poll_option_count the message carries a poll. Members named in
+-- mentioned_user_ids or mentioned_usernames, and the sender of the message
+-- replied to, get a mention unless they have a block with the sender.
+WITH thread AS (
+    UPDATE chat_threads
+    SET last_seq = last_seq + 1, last_message_id = @id::text, last_message_at = NOW(), updated_at = NOW()
+    WHERE chat_threads.id = sqlc.narg(thread_id)::text AND chat_threads.chat_id = @chat_id AND NOT is_closed
+    RETURNING chat_threads.last_seq
+), next AS (
+    UPDATE chats
+    SET last_seq = last_seq + 1, last_activity_at = NOW()
+    WHERE chats.id = @chat_id
+      AND (sqlc.narg(thread_id)::text IS NULL OR EXISTS (SELECT 1 FROM thread))
+    RETURNING chats.last_seq
+), msg AS (
+    INSERT INTO messages (id, chat_id, seq, sender_id, sender_device_id, thread_id, thread_seq, content_type, ciphertext, client_message_id,
+                          reply_to_id, forward_from_user_id, forward_from_chat_id, forward_from_message_id, forward_date,
+                          expires_at, view_once, service)
+    SELECT @id::text, @chat_id, next.last_seq, @sender_id::text, sqlc.narg(sender_device_id)::text, sqlc.narg(thread_id)::text,
+           (SELECT last_seq FROM thread), @content_type, @ciphertext, sqlc.narg(client_message_id)::text,
+           sqlc.narg(reply_to_id)::text, sqlc.narg(forward_from_user_id)::text, sqlc.narg(forward_from_chat_id)::text,
+           sqlc.narg(forward_from_message_id)::text, sqlc.narg(forward_date)::timestamptz,
+           NOW() + make_interval(secs => sqlc.narg(ttl_seconds)::int), @view_once::bool, sqlc.narg(service)::jsonb
+    FROM next
+    RETURNING *
+), payloads AS (
+    INSERT INTO message_device_payloads (message_id, device_id, ciphertext)
+    SELECT msg.id, d.device_id, (@device_ciphertexts::bytea[])[d.ord]
+    FROM msg, unnest(@device_ids::text[]) WITH ORDINALITY AS d(device_id, ord)
+), sender_read AS (
+    INSERT INTO chat_user_states (user_id, chat_id, read_seq)
+    SELECT msg.sender_id, msg.chat_id, msg.seq FROM msg
+    ON CONFLICT (user_id, chat_id) DO UPDATE
+    SET read_seq = GREATEST(chat_user_states.read_seq, EXCLUDED.read_seq)
+), poll AS (
+    INSERT INTO polls (message_id, chat_id, option_count, multiple_choice, anonymous, correct_option, closes_at)
+    SELECT msg.id, msg.chat_id, sqlc.narg(poll_option_count)::smallint, @poll_multiple_choice::bool, @poll_anonymous::bool,
+           sqlc.narg(poll_correct_option)::smallint, sqlc.narg(poll_closes_at)::timestamptz
+    FROM msg
+    WHERE sqlc.narg(poll_option_count)::smallint IS NOT NULL
+), reply_target AS (
+    SELECT r.sender_id FROM messages r, msg
+    WHERE r.chat_id = msg.chat_id AND r.id = msg.reply_to_id
+), mentions AS (
+    INSERT INTO message_mentions (user_id, message_id, chat_id, seq, is_reply)
+    SELECT cm.user_id, msg.id, msg.chat_id, msg.seq, cm.user_id IN (SELECT sender_id FROM reply_target)
+    FROM msg
+    JOIN chat_members cm ON cm.chat_id = msg.chat_id
+    JOIN users u ON u.id = cm.user_id
+    WHERE cm.user_id <> msg.sender_id
+      AND (cm.user_id = ANY(@mentioned_user_ids::text[])
+           OR lower(u.username) = ANY(@mentioned_usernames::text[])
+           OR cm.user_id IN (SELECT sender_id FROM reply_target))
+      AND NOT EXISTS (
+          SELECT 1 FROM blocks b
+          WHERE (b.owner_id = cm.user_id AND b.target_user_id = msg.sender_id)
+             OR (b.owner_id = msg.sender_id AND b.target_user_id = cm.user_id)
+      )
+)
+SELECT * FROM msg;
+
+-- name: GetMessage :one
+SELECT * FROM messages
+WHERE chat_id = @chat_id AND id = @id;
+
+-- name: GetMessageByClientID :one
+SELECT * FROM messages
+WHERE chat_id = @chat_id AND sender_id = @sender_id AND client_message_id = @client_message_id;
+
+-- name: GetMessageForDevice :one
+-- Returns a message with the payload addressed to one of the user's devices.
+-- Messages the user deleted for themselves or cleared are not returned.
+SELECT m.*, p.ciphertext AS device_ciphertext,
+       ARRAY(SELECT r.emoji FROM message_reactions r WHERE r.message_id = m.id AND r.user_id = @user_id::text ORDER BY r.created_at)::text[] AS my_reactions
+FROM messages m
+LEFT JOIN devices d ON d.id = sqlc.narg(device_id)::text AND d.user_id = @user_id
+LEFT JOIN message_device_payloads p ON p.message_id = m.id AND p.version = m.version AND p.device_id = d.id
+WHERE m.chat_id = @chat_id AND m.id = @id
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.user_id = @user_id AND h.message_id = m.id)
+  AND m.seq > COALESCE((SELECT s.cleared_seq FROM chat_user_states s WHERE s.user_id = @user_id AND s.chat_id = m.chat_id), 0);
+
+-- name: GetActiveUserDevice :one
+SELECT * FROM devices
+WHERE id = @id AND user_id = @user_id AND revoked_at IS NULL;
+
+-- name: FilterMemberDevices :many
+-- Returns the given device IDs that are active devices of the chat's members.
+SELECT d.id
+FROM devices d
+JOIN chat_members m ON m.user_id = d.user_id AND m.chat_id = @chat_id
+WHERE d.id = ANY(@device_ids::text[]) AND d.revoked_at IS NULL;
+
+-- name: ListMessagesBefore :many
+-- Returns messages older than before_seq, newest first, with the payload
+-- addressed to one of the reader's devices. thread_id narrows the page to
+-- one topic or reply thread. Messages the reader deleted for themselves or
+-- cleared are skipped; messages deleted for everyone come back as
+-- tombstones.
+SELECT m.*, p.ciphertext AS device_ciphertext,
+       ARRAY(SELECT r.emoji FROM message_reactions r WHERE r.message_id = m.id AND r.user_id = @user_id::text ORDER BY r.created_at)::text[] AS my_reactions
+FROM messages m
+LEFT JOIN devices d ON d.id = sqlc.narg(device_id)::text AND d.user_id = @user_id
+LEFT JOIN message_device_payloads p ON p.message_id = m.id AND p.version = m.version AND p.device_id = d.id
+WHERE m.chat_id = @chat_id
+  AND m.seq < @before_seq
+  AND (sqlc.narg(thread_id)::text IS NULL OR m.thread_id = sqlc.narg(thread_id)::text)
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.user_id = @user_id AND h.message_id = m.id)
+  AND m.seq > COALESCE((SELECT s.cleared_seq FROM chat_user_states s WHERE s.user_id = @user_id AND s.chat_id = m.chat_id), 0)
+ORDER BY m.seq DESC
+LIMIT @lim;
+
+-- name: ListMessagesAfter :many
+-- Returns messages newer than after_seq, oldest first. See ListMessagesBefore.
+SELECT m.*, p.ciphertext AS device_ciphertext,
+       ARRAY(SELECT r.emoji FROM message_reactions r WHERE r.message_id = m.id AND r.user_id = @user_id::text ORDER BY r.created_at)::text[] AS my_reactions
+FROM messages m
+LEFT JOIN devices d ON d.id = sqlc.narg(device_id)::text AND d.user_id = @user_id
+LEFT JOIN message_device_payloads p ON p.message_id = m.id AND p.version = m.version AND p.device_id = d.id
+WHERE m.chat_id = @chat_id
+  AND m.seq > @after_seq
+  AND (sqlc.narg(thread_id)::text IS NULL OR m.thread_id = sqlc.narg(thread_id)::text)
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.user_id = @user_id AND h.message_id = m.id)
+  AND m.seq > COALESCE((SELECT s.cleared_seq FROM chat_user_states s WHERE s.user_id = @user_id AND s.chat_id = m.chat_id), 0)
+ORDER BY m.seq ASC
+LIMIT @lim;
+
+-- name: EditMessage :one
+-- Replaces a message's ciphertext and per-device payloads with a new
+-- version and archives the previous ciphertext. Only the sender can edit,
+-- and only messages sent after editable_after; otherwise no row is returned.
+WITH old AS (
+    SELECT id, version, ciphertext, COALESCE(edited_at, created_at) AS written_at
+    FROM messages
+    WHERE messages.chat_id = @chat_id AND messages.id = @id
+      AND messages.sender_id = @sender_id::text AND messages.created_at > @editable_after
+      AND messages.deleted_at IS NULL
+    FOR UPDATE
+), archived AS (
+    INSERT INTO message_edits (message_id, version, ciphertext, created_at)
+    SELECT id, version, ciphertext, written_at FROM old
+), edited AS (
+    UPDATE messages m
+    SET ciphertext = @ciphertext, version = m.version + 1, edited_at = NOW(),
+        sender_device_id = @sender_device_id::text
+    FROM old
+    WHERE m.id = old.id
+    RETURNING m.*
+), payloads AS (
+    INSERT INTO message_device_payloads (message_id, version, device_id, ciphertext)
+    SELECT edited.id, edited.version, d.device_id, (@device_ciphertexts::bytea[])[d.ord]
+    FROM edited, unnest(@device_ids::text[]) WITH ORDINALITY AS d(device_id, ord)
+)
+SELECT * FROM edited;
+
+-- name: ListMessageEdits :many
+-- Returns the earlier versions of a message, oldest first, with the payload
+-- each addressed to one of the reader's devices.
+SELECT e.*, p.ciphertext AS device_ciphertext
+FROM message_edits e
+LEFT JOIN devices d ON d.id = sqlc.narg(device_id)::text AND d.user_id = @user_id
+LEFT JOIN message_device_payloads p ON p.message_id = e.message_id AND p.version = e.version AND p.device_id = d.id
+WHERE e.message_id = @message_id
+ORDER BY e.version;
+
+-- name: ListVisibleMessages :many
+-- Returns the given messages of a chat that the user can still see.
+SELECT m.*
+FROM messages m
+WHERE m.chat_id = @chat_id AND m.id = ANY(@ids::text[])
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.user_id = @user_id AND h.message_id = m.id)
+  AND m.seq > COALESCE((SELECT s.cleared_seq FROM chat_user_states s WHERE s.user_id = @user_id AND s.chat_id = m.chat_id), 0);
+
+-- name: DeleteMessagesForEveryone :many
+-- Tombstones messages: the ciphertext is wiped, the per-device payloads,
+-- edit history, reactions, polls and mentions are deleted and the messages
+-- are unpinned.
+-- Messages that are already tombstones are skipped.
+WITH target AS (
+    SELECT id FROM messages
+    WHERE messages.chat_id = @chat_id AND messages.id = ANY(@ids::text[]) AND messages.deleted_at IS NULL
+    FOR UPDATE
+), payloads AS (
+    DELETE FROM message_device_payloads p USING target WHERE p.message_id = target.id
+), edits AS (
+    DELETE FROM message_edits e USING target WHERE e.message_id = target.id
+), reactions AS (
+    DELETE FROM message_reactions r USING target WHERE r.message_id = target.id
+), pins AS (
+    DELETE FROM pinned_messages pm USING target WHERE pm.message_id = target.id
+), polls AS (
+    DELETE FROM polls pl USING target WHERE pl.message_id = target.id
+), mentions AS (
+    DELETE FROM message_mentions mm USING target WHERE mm.message_id = target.id
+)
+UPDATE messages m
+SET ciphertext = ''::bytea, reactions = '{}', deleted_at = NOW()
+FROM target
+WHERE m.id = target.id
+RETURNING m.*;
+
+-- name: ListThreadMessageIDs :many
+-- The messages of a thread that are not tombstones yet.
+SELECT id FROM messages
+WHERE thread_id = @thread_id AND deleted_at IS NULL
+ORDER BY thread_seq;
+
+-- name: HideMessages :exec
+-- Deletes messages for one user only.
+INSERT INTO message_hidden (user_id, message_id, chat_id)
+SELECT @user_id::text, m.id, m.chat_id FROM messages m
+WHERE m.chat_id = @chat_id::text AND m.id = ANY(@ids::text[])
+ON CONFLICT (user_id, message_id) DO NOTHING;
+
+-- name: ClearChatHistory :one
+-- Hides every current message of the chat from the user and returns the
+-- seq up to which the history is cleared. Per-message hides below it are
+-- no longer needed.
+WITH cleared AS (
+    INSERT INTO chat_user_states (user_id, chat_id, cleared_seq)
+    SELECT @user_id::text, id, last_seq FROM chats WHERE id = @chat_id::text
+    ON CONFLICT (user_id, chat_id) DO UPDATE
+    SET cleared_seq = EXCLUDED.cleared_seq, updated_at = NOW()
+    RETURNING cleared_seq
+), unhidden AS (
+    DELETE FROM message_hidden WHERE user_id = @user_id::text AND chat_id = @chat_id::text
+)
+SELECT cleared_seq FROM cleared;
+
+-- name: PurgeUnreferencedMessages :many
+-- Tombstones messages of a chat up to max_seq that no member can see any
+-- more because every member deleted them for themselves or cleared the
+-- history. ids narrows the check to the given messages.
+WITH target AS (
+    SELECT m.id FROM messages m
+    WHERE m.chat_id = @chat_id AND m.seq <= @max_seq AND m.deleted_at IS NULL
+      AND (sqlc.narg(ids)::text[] IS NULL OR m.id = ANY(sqlc.narg(ids)::text[]))
+      AND NOT EXISTS (
+          SELECT 1 FROM chat_members cm
+          LEFT JOIN chat_user_states s ON s.user_id = cm.user_id AND s.chat_id = cm.chat_id
+          WHERE cm.chat_id = m.chat_id
+            AND m.seq > COALESCE(s.cleared_seq, 0)
+            AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.user_id = cm.user_id AND h.message_id = m.id)
+      )
+    FOR UPDATE OF m SKIP LOCKED
+), payloads AS (
+    DELETE FROM message_device_payloads p USING target WHERE p.message_id = target.id
+), edits AS (
+    DELETE FROM message_edits e USING target WHERE e.message_id = target.id
+), reactions AS (
+    DELETE FROM message_reactions r USING target WHERE r.message_id = target.id
+), pins AS (
+    DELETE FROM pinned_messages pm USING target WHERE pm.message_id = target.id
+), polls AS (
+    DELETE FROM polls pl USING target WHERE pl.message_id = target.id
+), mentions AS (
+    DELETE FROM message_mentions mm USING target WHERE mm.message_id = target.id
+)
+UPDATE messages m
+SET ciphertext = ''::bytea, reactions = '{}', deleted_at = NOW()
+FROM target
+WHERE m.id = target.id
+RETURNING m.id;
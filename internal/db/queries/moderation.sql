@@ -0,0 +1,65 @@
+-- name: BanChatMember :one
+-- Bans a user from a chat, removing their membership and declining any
+-- pending join request. A NULL until_at bans permanently.
+WITH removed AS (
+    DELETE FROM chat_members
+    WHERE chat_members.chat_id = @chat_id AND chat_members.user_id = @user_id
+    RETURNING chat_members.chat_id
+), counted AS (
+    UPDATE chats
+    SET member_count = member_count - 1, updated_at = NOW()
+    FROM removed
+    WHERE chats.id = removed.chat_id
+), declined AS (
+    UPDATE chat_join_requests
+    SET state = 'declined', decided_by = @banned_by, decided_at = NOW()
+    WHERE chat_join_requests.chat_id = @chat_id AND chat_join_requests.user_id = @user_id
+      AND chat_join_requests.state = 'pending'
+)
+INSERT INTO chat_bans (chat_id, user_id, banned_by, until_at)
+VALUES (@chat_id, @user_id, @banned_by, sqlc.narg(until_at))
+ON CONFLICT (chat_id, user_id) DO UPDATE
+SET banned_by = EXCLUDED.banned_by, until_at = EXCLUDED.until_at, created_at = NOW()
+RETURNING *;
+
+-- name: UnbanChatMember :execrows
+DELETE FROM chat_bans
+WHERE chat_id = @chat_id AND user_id = @user_id;
+
+-- name: ListChatBans :many
+-- Lists the chat's active bans, oldest first.
+SELECT b.*, u.username
+FROM chat_bans b
+JOIN users u ON u.id = b.user_id
+WHERE b.chat_id = @chat_id
+  AND (b.until_at IS NULL OR b.until_at > NOW())
+  AND (sqlc.narg(cursor_created_at)::timestamptz IS NULL
+       OR (b.created_at, b.user_id) > (sqlc.narg(cursor_created_at)::timestamptz, sqlc.narg(cursor_user_id)::text))
+ORDER BY b.created_at, b.user_id
+LIMIT @page_size;
+
+-- name: RestrictChatMember :execrows
+UPDATE chat_members
+SET restricted_until = sqlc.narg(restricted_until)
+WHERE chat_id = @chat_id AND user_id = @user_id;
+
+-- name: SetChatSlowMode :execrows
+UPDATE chats
+SET slow_mode_seconds = @slow_mode_seconds, updated_at = NOW()
+WHERE id = @id;
+
+-- name: ClaimSlowModeSlot :one
+-- Records a send when the member's previous one is at least interval_seconds
+-- old. last_sent_at is the time of the previous send either way.
+WITH claimed AS (
+    UPDATE chat_members
+    SET last_sent_at = NOW()
+    WHERE chat_members.chat_id = @chat_id AND chat_members.user_id = @user_id
+      AND (chat_members.last_sent_at IS NULL
+           OR chat_members.last_sent_at <= NOW() - make_interval(secs => @interval_seconds::int))
+    RETURNING chat_members.user_id
+)
+SELECT EXISTS(SELECT 1 FROM claimed)::bool AS claimed,
+       m.last_sent_at
+FROM chat_members m
+WHERE m.chat_id = @chat_id AND m.user_id = @user_id;
@@ -0,0 +1,34 @@
+-- name: PinMessage :execrows
+-- Pins a message that has not been deleted. Pinning a pinned message again
+-- changes nothing.
+INSERT INTO pinned_messages (chat_id, message_id, pinned_by)
+SELECT m.chat_id, m.id, @pinned_by::text FROM messages m
+WHERE m.chat_id = @chat_id AND m.id = @message_id AND m.deleted_at IS NULL
+ON CONFLICT (chat_id, message_id) DO NOTHING;
+
+-- name: UnpinMessage :execrows
+DELETE FROM pinned_messages
+WHERE chat_id = @chat_id AND message_id = @message_id;
+
+-- name: UnpinAllMessages :many
+-- Unpins every message of a chat and returns the unpinned message IDs.
+DELETE FROM pinned_messages
+WHERE chat_id = @chat_id
+RETURNING message_id;
+
+-- name: ListPinnedMessages :many
+-- Returns a page of a chat's pinned messages, newest pin first, with the
+-- payload addressed to one of the user's devices. Messages the user deleted
+-- for themselves or cleared are left out.
+SELECT p.pinned_by, p.pinned_at, m.*, dp.ciphertext AS device_ciphertext
+FROM pinned_messages p
+JOIN messages m ON m.id = p.message_id
+LEFT JOIN devices d ON d.id = sqlc.narg(device_id)::text AND d.user_id = @user_id
+LEFT JOIN message_device_payloads dp ON dp.message_id = m.id AND dp.version = m.version AND dp.device_id = d.id
+WHERE p.chat_id = @chat_id
+  AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.user_id = @user_id AND h.message_id = m.id)
+  AND m.seq > COALESCE((SELECT s.cleared_seq FROM chat_user_states s WHERE s.user_id = @user_id AND s.chat_id = m.chat_id), 0)
+  AND (sqlc.narg(cursor_pinned_at)::timestamptz IS NULL
+       OR (p.pinned_at, p.message_id) < (sqlc.narg(cursor_pinned_at)::timestamptz, sqlc.narg(cursor_message_id)::text))
+ORDER BY p.pinned_at DESC, p.message_id DESC
+LIMIT @page_size;
@@ -0,0 +1,52 @@
+-- name: GetPoll :one
+-- Returns a poll with its vote count per option and the user's own choices.
+SELECT p.*,
+       v.options AS my_options,
+       (SELECT count(*) FROM poll_votes t WHERE t.message_id = p.message_id)::int AS total_voters,
+       ARRAY(
+           SELECT count(c.user_id)::int
+           FROM generate_series(0, p.option_count - 1) AS o(i)
+           LEFT JOIN poll_votes c ON c.message_id = p.message_id AND o.i = ANY(c.options)
+           GROUP BY o.i ORDER BY o.i
+       )::int[] AS counts
+FROM polls p
+LEFT JOIN poll_votes v ON v.message_id = p.message_id AND v.user_id = @user_id::text
+WHERE p.chat_id = @chat_id AND p.message_id = @message_id;
+
+-- name: VotePoll :execrows
+-- Stores the user's choices on an open poll, replacing earlier ones. A quiz
+-- answer cannot be changed. Nothing is written when the poll is closed or
+-- the choices are unchanged, so repeating a vote is a no-op.
+INSERT INTO poll_votes (message_id, user_id, options)
+SELECT p.message_id, @user_id::text, @options::smallint[] FROM polls p
+WHERE p.chat_id = @chat_id AND p.message_id = @message_id
+  AND p.closed_at IS NULL AND (p.closes_at IS NULL OR p.closes_at > NOW())
+ON CONFLICT (message_id, user_id) DO UPDATE
+SET options = EXCLUDED.options, voted_at = NOW()
+WHERE poll_votes.options IS DISTINCT FROM EXCLUDED.options
+  AND NOT EXISTS (SELECT 1 FROM polls q WHERE q.message_id = poll_votes.message_id AND q.correct_option IS NOT NULL);
+
+-- name: RetractPollVote :execrows
+-- Removes the user's vote from an open poll that is not a quiz.
+DELETE FROM poll_votes v
+USING polls p
+WHERE p.message_id = v.message_id AND p.chat_id = @chat_id AND v.message_id = @message_id AND v.user_id = @user_id
+  AND p.correct_option IS NULL
+  AND p.closed_at IS NULL AND (p.closes_at IS NULL OR p.closes_at > NOW());
+
+-- name: ClosePoll :execrows
+-- Closes an open poll for good.
+UPDATE polls SET closed_at = NOW()
+WHERE chat_id = @chat_id AND message_id = @message_id
+  AND closed_at IS NULL AND (closes_at IS NULL OR closes_at > NOW());
+
+-- name: ListPollVoters :many
+-- Returns a page of a poll's voters, earliest vote first. option narrows
+-- the page to the voters who chose it.
+SELECT user_id, options, voted_at FROM poll_votes
+WHERE message_id = @message_id
+  AND (sqlc.narg(option)::smallint IS NULL OR sqlc.narg(option)::smallint = ANY(options))
+  AND (sqlc.narg(cursor_voted_at)::timestamptz IS NULL
+       OR (voted_at, user_id) > (sqlc.narg(cursor_voted_at)::timestamptz, sqlc.narg(cursor_user_id)::text))
+ORDER BY voted_at, user_id
+LIMIT @page_size;
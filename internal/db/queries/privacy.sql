@@ -0,0 +1,13 @@
+-- name: GetPrivacySettings :one
+SELECT * FROM user_privacy_settings
+WHERE user_id = $1;
+
+-- name: UpsertPrivacySettings :one
+-- Changes the provided settings only.
+INSERT INTO user_privacy_settings (user_id, read_receipts, forward_attribution)
+VALUES (@user_id, COALESCE(sqlc.narg(read_receipts)::bool, true), COALESCE(sqlc.narg(forward_attribution)::bool, true))
+ON CONFLICT (user_id) DO UPDATE
+SET read_receipts       = COALESCE(sqlc.narg(read_receipts)::bool, user_privacy_settings.read_receipts),
+    forward_attribution = COALESCE(sqlc.narg(forward_attribution)::bool, user_privacy_settings.forward_attribution),
+    updated_at          = NOW()
+RETURNING *;
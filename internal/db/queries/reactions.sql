@@ -0,0 +1,75 @@
+-- name: LockMessageReactions :one
+-- Locks a message for a change to its reactions and returns its counts.
+-- Statements after it in the transaction see the reactions that were
+-- committed while it waited.
+SELECT reactions FROM messages
+WHERE chat_id = @chat_id AND id = @message_id AND deleted_at IS NULL
+FOR UPDATE;
+
+-- name: AddReaction :one
+-- Adds a reaction and bumps the message's count for the emoji. The message
+-- row is locked so that concurrent reactions count correctly. No row is
+-- returned when the reaction exists already, the user has max_per_user
+-- reactions on the message, or the message already has max_per_message
+-- distinct emoji without this one.
+WITH msg AS (
+    SELECT messages.id, messages.reactions FROM messages
+    WHERE messages.chat_id = @chat_id::text AND messages.id = @message_id::text AND messages.deleted_at IS NULL
+    FOR UPDATE
+), added AS (
+    INSERT INTO message_reactions (message_id, user_id, emoji)
+    SELECT msg.id, @user_id::text, @emoji::text FROM msg
+    WHERE (SELECT count(*) FROM message_reactions r WHERE r.message_id = msg.id AND r.user_id = @user_id::text) < @max_per_user::int
+      AND ((msg.reactions -> @emoji::text) IS NOT NULL
+           OR (SELECT count(*) FROM jsonb_object_keys(msg.reactions)) < @max_per_message::int)
+    ON CONFLICT DO NOTHING
+    RETURNING message_id
+)
+UPDATE messages m
+SET reactions = jsonb_set(m.reactions, ARRAY[@emoji::text], to_jsonb(COALESCE((m.reactions ->> @emoji::text)::int, 0) + 1))
+FROM added
+WHERE m.id = added.message_id
+RETURNING m.reactions;
+
+-- name: RemoveReaction :one
+-- Removes a reaction and lowers the message's count for the emoji, dropping
+-- emoji nobody reacts with any more. No row is returned when the user had
+-- no such reaction.
+WITH removed AS (
+    DELETE FROM message_reactions r
+    USING messages msg
+    WHERE r.message_id = msg.id AND msg.chat_id = @chat_id::text AND msg.id = @message_id::text
+      AND r.user_id = @user_id::text AND r.emoji = @emoji::text
+    RETURNING r.message_id
+)
+UPDATE messages m
+SET reactions = CASE
+        WHEN COALESCE((m.reactions ->> @emoji::text)::int, 0) <= 1 THEN m.reactions - @emoji::text
+        ELSE jsonb_set(m.reactions, ARRAY[@emoji::text], to_jsonb((m.reactions ->> @emoji::text)::int - 1))
+    END
+FROM removed
+WHERE m.id = removed.message_id
+RETURNING m.reactions;
+
+-- name: ListUserReactions :many
+SELECT emoji FROM message_reactions
+WHERE message_id = @message_id AND user_id = @user_id
+ORDER BY created_at;
+
+-- name: ListReactions :many
+-- Lists who reacted to a message, oldest first. emoji narrows the list to
+-- one emoji.
+SELECT r.user_id, u.username, r.emoji, r.created_at
+FROM message_reactions r
+JOIN users u ON u.id = r.user_id
+WHERE r.message_id = @message_id
+  AND (sqlc.narg(emoji)::text IS NULL OR r.emoji = sqlc.narg(emoji)::text)
+  AND (sqlc.narg(cursor_created_at)::timestamptz IS NULL
+       OR (r.created_at, r.user_id, r.emoji) > (sqlc.narg(cursor_created_at)::timestamptz, sqlc.narg(cursor_user_id)::text, sqlc.narg(cursor_emoji)::text))
+ORDER BY r.created_at, r.user_id, r.emoji
+LIMIT @page_size;
+
+-- name: SetChatAllowedReactions :execrows
+UPDATE chats
+SET allowed_reactions = sqlc.narg(allowed_reactions)::text[], updated_at = NOW()
+WHERE id = @id;
@@ -0,0 +1,59 @@
+-- name: MarkChatRead :one
+-- Moves the user's read position forward to read_seq and clears the
+-- marked-unread flag. Returns the new position and the one before.
+WITH prev AS (
+    SELECT s.read_seq FROM chat_user_states s
+    WHERE s.user_id = @user_id::text AND s.chat_id = @chat_id::text
+), updated AS (
+    INSERT INTO chat_user_states (user_id, chat_id, read_seq)
+    VALUES (@user_id::text, @chat_id::text, @read_seq)
+    ON CONFLICT (user_id, chat_id) DO UPDATE
+    SET read_seq      = GREATEST(chat_user_states.read_seq, EXCLUDED.read_seq),
+        marked_unread = false,
+        updated_at    = NOW()
+    RETURNING chat_user_states.read_seq
+)
+SELECT updated.read_seq, COALESCE((SELECT read_seq FROM prev), 0)::bigint AS previous_read_seq
+FROM updated;
+
+-- name: MarkChatDelivered :one
+-- Moves a device's delivery position forward. Returns the device's new
+-- position and the highest position any of the user's devices had before,
+-- or no row when the device is not an active device of the user.
+WITH prev AS (
+    SELECT COALESCE(MAX(d.delivered_seq), 0)::bigint AS delivered_seq
+    FROM device_chat_deliveries d
+    WHERE d.chat_id = @chat_id::text AND d.user_id = @user_id::text
+), updated AS (
+    INSERT INTO device_chat_deliveries (device_id, chat_id, user_id, delivered_seq)
+    SELECT dev.id, @chat_id::text, dev.user_id, @delivered_seq
+    FROM devices dev
+    WHERE dev.id = @device_id::text AND dev.user_id = @user_id::text AND dev.revoked_at IS NULL
+    ON CONFLICT (device_id, chat_id) DO UPDATE
+    SET delivered_seq = GREATEST(device_chat_deliveries.delivered_seq, EXCLUDED.delivered_seq),
+        updated_at    = NOW()
+    RETURNING device_chat_deliveries.delivered_seq
+)
+SELECT updated.delivered_seq, prev.delivered_seq AS previous_user_seq
+FROM updated, prev;
+
+-- name: ListMessageSenders :many
+-- Returns the other senders of the chat's messages with a seq in
+-- (after_seq, up_to_seq] and whether they share read receipts.
+SELECT DISTINCT m.sender_id::text AS sender_id, COALESCE(p.read_receipts, true)::bool AS read_receipts
+FROM messages m
+LEFT JOIN user_privacy_settings p ON p.user_id = m.sender_id
+WHERE m.chat_id = @chat_id AND m.seq > @after_seq AND m.seq <= @up_to_seq
+  AND m.sender_id IS NOT NULL AND m.sender_id <> @user_id::text AND m.deleted_at IS NULL;
+
+-- name: GetChatReceipts :one
+-- Returns how far the chat's other members have received and read it.
+-- Members who turned read receipts off are left out of the read position.
+SELECT
+    (SELECT COALESCE(MAX(d.delivered_seq), 0)
+     FROM device_chat_deliveries d
+     WHERE d.chat_id = @chat_id::text AND d.user_id <> @user_id::text)::bigint AS delivered_seq,
+    (SELECT COALESCE(MAX(s.read_seq), 0)
+     FROM chat_user_states s
+     LEFT JOIN user_privacy_settings p ON p.user_id = s.user_id
+     WHERE s.chat_id = @chat_id::text AND s.user_id <> @user_id::text AND COALESCE(p.read_receipts, true))::bigint AS read_seq;
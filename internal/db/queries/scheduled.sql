@@ -0,0 +1,84 @@
+-- name: CreateScheduledMessage :one
+INSERT INTO scheduled_messages (id, chat_id, sender_id, sender_device_id, thread_id, reply_to_id, content_type,
+                                ciphertext, device_ids, device_ciphertexts, send_at, online_user_id)
+VALUES (@id, @chat_id, @sender_id, @sender_device_id, sqlc.narg(thread_id), sqlc.narg(reply_to_id), @content_type,
+        @ciphertext, @device_ids::text[], @device_ciphertexts::bytea[], sqlc.narg(send_at), sqlc.narg(online_user_id))
+RETURNING *;
+
+-- name: CountScheduledMessages :one
+SELECT COUNT(*) FROM scheduled_messages
+WHERE chat_id = @chat_id AND sender_id = @sender_id;
+
+-- name: ListScheduledMessages :many
+-- Returns the sender's scheduled messages in a chat, timed ones first in
+-- sending order, then those waiting for the recipient to come online.
+SELECT * FROM scheduled_messages
+WHERE chat_id = @chat_id AND sender_id = @sender_id
+ORDER BY send_at ASC NULLS LAST, created_at ASC, id ASC;
+
+-- name: UpdateScheduledMessage :one
+-- Changes the content or the time of a scheduled message that is not being
+-- sent right now, and clears an earlier failure. A new send_at replaces
+-- waiting for the recipient to come online.
+UPDATE scheduled_messages
+SET sender_device_id   = COALESCE(sqlc.narg(sender_device_id)::text, sender_device_id),
+    ciphertext         = COALESCE(sqlc.narg(ciphertext)::bytea, ciphertext),
+    device_ids         = COALESCE(sqlc.narg(device_ids)::text[], device_ids),
+    device_ciphertexts = COALESCE(sqlc.narg(device_ciphertexts)::bytea[], device_ciphertexts),
+    send_at            = COALESCE(sqlc.narg(send_at)::timestamptz, send_at),
+    online_user_id     = CASE WHEN sqlc.narg(send_at)::timestamptz IS NULL THEN online_user_id END,
+    failed_at          = NULL,
+    error_code         = NULL,
+    updated_at         = NOW()
+WHERE id = @id AND chat_id = @chat_id AND sender_id = @sender_id
+  AND (locked_until IS NULL OR locked_until < NOW())
+RETURNING *;
+
+-- name: DeleteScheduledMessage :execrows
+-- Cancels a scheduled message that is not being sent right now.
+DELETE FROM scheduled_messages
+WHERE id = @id AND chat_id = @chat_id AND sender_id = @sender_id
+  AND (locked_until IS NULL OR locked_until < NOW());
+
+-- name: ClaimDueScheduledMessages :many
+-- Leases up to batch_size due messages to the caller. Concurrent schedulers
+-- skip each other's rows, and a row whose lease ran out without being
+-- finished is claimed again. A message waiting for its recipient is due
+-- once the recipient was online after it was scheduled.
+WITH due AS (
+    SELECT s.id FROM scheduled_messages s
+    WHERE s.failed_at IS NULL
+      AND (s.locked_until IS NULL OR s.locked_until < NOW())
+      AND (s.send_at <= NOW()
+           OR EXISTS (SELECT 1 FROM user_presence p
+                      WHERE p.user_id = s.online_user_id AND p.last_online_at > s.created_at))
+    ORDER BY s.send_at ASC NULLS FIRST, s.created_at ASC
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE scheduled_messages s
+SET locked_until = NOW() + make_interval(secs => @lease_seconds::float8)
+FROM due
+WHERE s.id = due.id
+RETURNING s.*;
+
+-- name: FinishScheduledMessage :exec
+DELETE FROM scheduled_messages WHERE id = @id;
+
+-- name: FailScheduledMessage :exec
+UPDATE scheduled_messages
+SET failed_at = NOW(), error_code = @error_code, locked_until = NULL, updated_at = NOW()
+WHERE id = @id;
+
+-- name: FailThreadScheduledMessages :many
+-- Fails the scheduled messages of a thread that are not being sent right
+-- now. A message being sent fails on its own once the thread is closed.
+UPDATE scheduled_messages
+SET failed_at = NOW(), error_code = @error_code, locked_until = NULL, updated_at = NOW()
+WHERE thread_id = @thread_id AND failed_at IS NULL
+  AND (locked_until IS NULL OR locked_until < NOW())
+RETURNING *;
+
+-- name: TouchUserPresence :exec
+INSERT INTO user_presence (user_id) VALUES (@user_id)
+ON CONFLICT (user_id) DO UPDATE SET last_online_at = NOW();
@@ -0,0 +1,98 @@
+-- name: SetChatForum :execrows
+UPDATE chats
+SET is_forum = @is_forum, updated_at = NOW()
+WHERE id = @id AND type = 'group';
+
+-- name: CreateTopic :one
+INSERT INTO chat_threads (id, chat_id, kind, title, icon_color, created_by)
+VALUES (@id, @chat_id, 'topic', @title, sqlc.narg(icon_color), @created_by)
+RETURNING *;
+
+-- name: CreateReplyThread :one
+INSERT INTO chat_threads (id, chat_id, kind, root_message_id, created_by)
+VALUES (@id, @chat_id, 'replies', @root_message_id, @created_by)
+RETURNING *;
+
+-- name: GetThread :one
+SELECT * FROM chat_threads
+WHERE id = @id AND chat_id = @chat_id AND deleted_at IS NULL;
+
+-- name: GetReplyThread :one
+SELECT * FROM chat_threads
+WHERE chat_id = @chat_id AND root_message_id = @root_message_id;
+
+-- name: UpdateTopic :one
+UPDATE chat_threads
+SET title      = COALESCE(sqlc.narg(title), title),
+    icon_color = COALESCE(sqlc.narg(icon_color), icon_color),
+    is_closed  = COALESCE(sqlc.narg(is_closed), is_closed),
+    updated_at = NOW()
+WHERE id = @id AND chat_id = @chat_id AND kind = 'topic' AND deleted_at IS NULL
+RETURNING *;
+
+-- name: DeleteTopic :execrows
+-- Closes the topic and hides it. The row stays because the topic's
+-- tombstoned messages still belong to it.
+UPDATE chat_threads
+SET is_closed = TRUE, deleted_at = NOW(), updated_at = NOW()
+WHERE id = @id AND chat_id = @chat_id AND kind = 'topic' AND deleted_at IS NULL;
+
+-- name: ListThreadsWithState :many
+-- Threads of one kind with the reader's unread count and settings, most
+-- recently active first.
+SELECT t.*,
+       (t.last_seq - COALESCE(s.last_read_seq, 0))::bigint AS unread_count,
+       COALESCE(s.last_read_seq, 0)::bigint AS last_read_seq,
+       COALESCE(s.notify_level, 'all')::thread_notify_level AS notify_level,
+       s.muted_until
+FROM chat_threads t
+LEFT JOIN thread_member_states s ON s.thread_id = t.id AND s.user_id = @user_id
+WHERE t.chat_id = @chat_id AND t.kind = @kind AND t.deleted_at IS NULL
+  AND (sqlc.narg(cursor_activity)::timestamptz IS NULL
+       OR (COALESCE(t.last_message_at, t.created_at), t.id) < (sqlc.narg(cursor_activity)::timestamptz, sqlc.narg(cursor_id)::text))
+ORDER BY COALESCE(t.last_message_at, t.created_at) DESC, t.id DESC
+LIMIT @page_size;
+
+-- name: GetThreadWithState :one
+SELECT t.*,
+       (t.last_seq - COALESCE(s.last_read_seq, 0))::bigint AS unread_count,
+       COALESCE(s.last_read_seq, 0)::bigint AS last_read_seq,
+       COALESCE(s.notify_level, 'all')::thread_notify_level AS notify_level,
+       s.muted_until
+FROM chat_threads t
+LEFT JOIN thread_member_states s ON s.thread_id = t.id AND s.user_id = @user_id
+WHERE t.id = @id AND t.chat_id = @chat_id AND t.deleted_at IS NULL;
+
+-- name: ListTopicSummaries :many
+-- The most recently active topics of each forum chat in a chat list page.
+SELECT t.chat_id, t.id, t.title, t.icon_color, t.is_closed,
+       t.last_message_id, t.last_message_at,
+       (t.last_seq - COALESCE(s.last_read_seq, 0))::bigint AS unread_count
+FROM unnest(@chat_ids::text[]) AS c(id)
+CROSS JOIN LATERAL (
+    SELECT * FROM chat_threads
+    WHERE chat_threads.chat_id = c.id AND chat_threads.kind = 'topic' AND chat_threads.deleted_at IS NULL
+    ORDER BY COALESCE(chat_threads.last_message_at, chat_threads.created_at) DESC, chat_threads.id DESC
+    LIMIT @per_chat
+) t
+LEFT JOIN thread_member_states s ON s.thread_id = t.id AND s.user_id = @user_id
+ORDER BY t.chat_id, COALESCE(t.last_message_at, t.created_at) DESC, t.id DESC;
+
+-- name: MarkThreadRead :one
+-- Moves the read position forward only, never past the thread's last message.
+INSERT INTO thread_member_states (thread_id, user_id, last_read_seq)
+SELECT t.id, @user_id, LEAST(@seq::bigint, t.last_seq)
+FROM chat_threads t
+WHERE t.id = @thread_id AND t.deleted_at IS NULL
+ON CONFLICT (thread_id, user_id) DO UPDATE
+SET last_read_seq = GREATEST(thread_member_states.last_read_seq, EXCLUDED.last_read_seq),
+    updated_at = NOW()
+RETURNING last_read_seq;
+
+-- name: UpdateThreadNotifications :exec
+INSERT INTO thread_member_states (thread_id, user_id, notify_level, muted_until)
+VALUES (@thread_id, @user_id, @notify_level, sqlc.narg(muted_until))
+ON CONFLICT (thread_id, user_id) DO UPDATE
+SET notify_level = EXCLUDED.notify_level,
+    muted_until = EXCLUDED.muted_until,
+    updated_at = NOW();
@@ -0,0 +1,69 @@
+-- name: AppendUserUpdate :one
+-- Takes the user's next update seq and records the update. The row lock on
+-- user_update_state keeps seq gapless under concurrent writers.
+WITH next AS (
+    INSERT INTO user_update_state (user_id, seq)
+    VALUES (@user_id, 1)
+    ON CONFLICT (user_id) DO UPDATE
+    SET seq = user_update_state.seq + 1
+    RETURNING seq
+)
+INSERT INTO user_updates (user_id, seq, type, payload)
+SELECT @user_id, next.seq, @type, @payload FROM next
+RETURNING *;
+
+-- name: AppendChatUpdate :many
+-- Appends the same update to the log of every member of a chat. Members are
+-- visited in a fixed order so that concurrent fan-outs lock their
+-- user_update_state rows consistently.
+WITH next AS (
+    INSERT INTO user_update_state (user_id, seq)
+    SELECT m.user_id, 1
+    FROM chat_members m
+    WHERE m.chat_id = @chat_id
+    ORDER BY m.user_id
+    ON CONFLICT (user_id) DO UPDATE
+    SET seq = user_update_state.seq + 1
+    RETURNING user_id, seq
+)
+INSERT INTO user_updates (user_id, seq, type, payload)
+SELECT next.user_id, next.seq, @type, @payload FROM next
+RETURNING *;
+
+-- name: GetUserUpdateSeq :one
+-- Returns the seq of the user's newest update, 0 when there is none.
+SELECT COALESCE((SELECT seq FROM user_update_state WHERE user_id = @user_id), 0)::bigint;
+
+-- name: ListUserUpdates :many
+SELECT * FROM user_updates
+WHERE user_id = @user_id AND seq > @after_seq
+ORDER BY seq
+LIMIT @lim;
+
+-- name: AppendChannelUpdate :one
+-- Takes the channel's next update seq and records the update in its
+-- stream. Returns no row when the chat is not a channel.
+WITH next AS (
+    UPDATE chats
+    SET update_seq = update_seq + 1
+    WHERE id = @chat_id AND type = 'channel'
+    RETURNING id, update_seq
+)
+INSERT INTO chat_updates (chat_id, seq, type, payload)
+SELECT next.id, next.update_seq, @type, @payload FROM next
+RETURNING *;
+
+-- name: ListUserChannelSeqs :many
+-- Returns the seq of the newest update of every channel the user is
+-- subscribed to.
+SELECT c.id, c.update_seq
+FROM chat_members m
+JOIN chats c ON c.id = m.chat_id
+WHERE m.user_id = @user_id AND c.type = 'channel'
+ORDER BY c.id;
+
+-- name: ListChannelUpdates :many
+SELECT * FROM chat_updates
+WHERE chat_id = @chat_id AND seq > @after_seq
+ORDER BY seq
+LIMIT @lim;
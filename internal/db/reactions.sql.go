@@ -0,0 +1,235 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: reactions.sql
+
+package db
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const addReaction = `-- name: AddReaction :one
+WITH msg AS (
+    SELECT messages.id, messages.reactions FROM messages
+    WHERE messages.chat_id = $2::text AND messages.id = $3::text AND messages.deleted_at IS NULL
+    FOR UPDATE
+), added AS (
+    INSERT INTO message_reactions (message_id, user_id, emoji)
+    SELECT msg.id, $4::text, $1::text FROM msg
+    WHERE (SELECT count(*) FROM message_reactions r WHERE r.message_id = msg.id AND r.user_id = $4::text) < $5::int
+      AND ((msg.reactions -> $1::text) IS NOT NULL
+           OR (SELECT count(*) FROM jsonb_object_keys(msg.reactions)) < $6::int)
+    ON CONFLICT DO NOTHING
+    RETURNING message_id
+)
+UPDATE messages m
+SET reactions = jsonb_set(m.reactions, ARRAY[$1::text], to_jsonb(COALESCE((m.reactions ->> $1::text)::int, 0) + 1))
+FROM added
+WHERE m.id = added.message_id
+RETURNING m.reactions
+`
+
+type AddReactionParams struct {
+	Emoji         string `json:"emoji"`
+	ChatID        string `json:"chat_id"`
+	MessageID     string `json:"message_id"`
+	UserID        string `json:"user_id"`
+	MaxPerUser    int32  `json:"max_per_user"`
+	MaxPerMessage int32  `json:"max_per_message"`
+}
+
+// Adds a reaction and bumps the message's count for the emoji. The message
+// row is locked so that concurrent reactions count correctly. No row is
+// returned when the reaction exists already, the user has max_per_user
+// reactions on the message, or the message already has max_per_message
+// distinct emoji without this one.
+func (q *Queries) AddReaction(ctx context.Context, arg AddReactionParams) (json.RawMessage, error) {
+	row := q.db.QueryRow(ctx, addReaction,
+		arg.Emoji,
+		arg.ChatID,
+		arg.MessageID,
+		arg.UserID,
+		arg.MaxPerUser,
+		arg.MaxPerMessage,
+	)
+	var reactions json.RawMessage
+	err := row.Scan(&reactions)
+	return reactions, err
+}
+
+const listReactions = `-- name: ListReactions :many
+SELECT r.user_id, u.username, r.emoji, r.created_at
+FROM message_reactions r
+JOIN users u ON u.id = r.user_id
+WHERE r.message_id = $1
+  AND ($2::text IS NULL OR r.emoji = $2::text)
+  AND ($3::timestamptz IS NULL
+       OR (r.created_at, r.user_id, r.emoji) > ($3::timestamptz, $4::text, $5::text))
+ORDER BY r.created_at, r.user_id, r.emoji
+LIMIT $6
+`
+
+type ListReactionsParams struct {
+	MessageID       string             `json:"message_id"`
+	Emoji           pgtype.Text        `json:"emoji"`
+	CursorCreatedAt pgtype.Timestamptz `json:"cursor_created_at"`
+	CursorUserID    pgtype.Text        `json:"cursor_user_id"`
+	CursorEmoji     pgtype.Text        `json:"cursor_emoji"`
+	PageSize        int32              `json:"page_size"`
+}
+
+type ListReactionsRow struct {
+	UserID    string             `json:"user_id"`
+	Username  string             `json:"username"`
+	Emoji     string             `json:"emoji"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+// Lists who reacted to a message, oldest first. emoji narrows the list to
+// one emoji.
+func (q *Queries) ListReactions(ctx context.Context, arg ListReactionsParams) ([]ListReactionsRow, error) {
+	rows, err := q.db.Query(ctx, listReactions,
+		arg.MessageID,
+		arg.Emoji,
+		arg.CursorCreatedAt,
+		arg.CursorUserID,
+		arg.CursorEmoji,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListReactionsRow{}
+	for rows.Next() {
+		var i ListReactionsRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Username,
+			&i.Emoji,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserReactions = `-- name: ListUserReactions :many
+SELECT emoji FROM message_reactions
+WHERE message_id = $1 AND user_id = $2
+ORDER BY created_at
+`
+
+type ListUserReactionsParams struct {
+	MessageID string `json:"message_id"`
+	UserID    string `json:"user_id"`
+}
+
+func (q *Queries) ListUserReactions(ctx context.Context, arg ListUserReactionsParams) ([]string, error) {
+	rows, err := q.db.Query(ctx, listUserReactions, arg.MessageID, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var emoji string
+		if err := rows.Scan(&emoji); err != nil {
+			return nil, err
+		}
+		items = append(items, emoji)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockMessageReactions = `-- name: LockMessageReactions :one
+SELECT reactions FROM messages
+WHERE chat_id = $1 AND id = $2 AND deleted_at IS NULL
+FOR UPDATE
+`
+
+type LockMessageReactionsParams struct {
+	ChatID    string `json:"chat_id"`
+	MessageID string `json:"message_id"`
+}
+
+// Locks a message for a change to its reactions and returns its counts.
+// Statements after it in the transaction see the reactions that were
+// committed while it waited.
+func (q *Queries) LockMessageReactions(ctx context.Context, arg LockMessageReactionsParams) (json.RawMessage, error) {
+	row := q.db.QueryRow(ctx, lockMessageReactions, arg.ChatID, arg.MessageID)
+	var reactions json.RawMessage
+	err := row.Scan(&reactions)
+	return reactions, err
+}
+
+const removeReaction = `-- name: RemoveReaction :one
+WITH removed AS (
+    DELETE FROM message_reactions r
+    USING messages msg
+    WHERE r.message_id = msg.id AND msg.chat_id = $2::text AND msg.id = $3::text
+      AND r.user_id = $4::text AND r.emoji = $1::text
+    RETURNING r.message_id
+)
+UPDATE messages m
+SET reactions = CASE
+        WHEN COALESCE((m.reactions ->> $1::text)::int, 0) <= 1 THEN m.reactions - $1::text
+        ELSE jsonb_set(m.reactions, ARRAY[$1::text], to_jsonb((m.reactions ->> $1::text)::int - 1))
+    END
+FROM removed
+WHERE m.id = removed.message_id
+RETURNING m.reactions
+`
+
+type RemoveReactionParams struct {
+	Emoji     string `json:"emoji"`
+	ChatID    string `json:"chat_id"`
+	MessageID string `json:"message_id"`
+	UserID    string `json:"user_id"`
+}
+
+// Removes a reaction and lowers the message's count for the emoji, dropping
+// emoji nobody reacts with any more. No row is returned when the user had
+// no such reaction.
+func (q *Queries) RemoveReaction(ctx context.Context, arg RemoveReactionParams) (json.RawMessage, error) {
+	row := q.db.QueryRow(ctx, removeReaction,
+		arg.Emoji,
+		arg.ChatID,
+		arg.MessageID,
+		arg.UserID,
+	)
+	var reactions json.RawMessage
+	err := row.Scan(&reactions)
+	return reactions, err
+}
+
+const setChatAllowedReactions = `-- name: SetChatAllowedReactions :execrows
+UPDATE chats
+SET allowed_reactions = $1::text[], updated_at = NOW()
+WHERE id = $2
+`
+
+type SetChatAllowedReactionsParams struct {
+	AllowedReactions []string `json:"allowed_reactions"`
+	ID               string   `json:"id"`
+}
+
+func (q *Queries) SetChatAllowedReactions(ctx context.Context, arg SetChatAllowedReactionsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, setChatAllowedReactions, arg.AllowedReactions, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,170 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: receipts.sql
+
+package db
+
+import (
+	"context"
+)
+
+const getChatReceipts = `-- name: GetChatReceipts :one
+SELECT
+    (SELECT COALESCE(MAX(d.delivered_seq), 0)
+     FROM device_chat_deliveries d
+     WHERE d.chat_id = $1::text AND d.user_id <> $2::text)::bigint AS delivered_seq,
+    (SELECT COALESCE(MAX(s.read_seq), 0)
+     FROM chat_user_states s
+     LEFT JOIN user_privacy_settings p ON p.user_id = s.user_id
+     WHERE s.chat_id = $1::text AND s.user_id <> $2::text AND COALESCE(p.read_receipts, true))::bigint AS read_seq
+`
+
+type GetChatReceiptsParams struct {
+	ChatID string `json:"chat_id"`
+	UserID string `json:"user_id"`
+}
+
+type GetChatReceiptsRow struct {
+	DeliveredSeq int64 `json:"delivered_seq"`
+	ReadSeq      int64 `json:"read_seq"`
+}
+
+// Returns how far the chat's other members have received and read it.
+// Members who turned read receipts off are left out of the read position.
+func (q *Queries) GetChatReceipts(ctx context.Context, arg GetChatReceiptsParams) (GetChatReceiptsRow, error) {
+	row := q.db.QueryRow(ctx, getChatReceipts, arg.ChatID, arg.UserID)
+	var i GetChatReceiptsRow
+	err := row.Scan(&i.DeliveredSeq, &i.ReadSeq)
+	return i, err
+}
+
+const listMessageSenders = `-- name: ListMessageSenders :many
+SELECT DISTINCT m.sender_id::text AS sender_id, COALESCE(p.read_receipts, true)::bool AS read_receipts
+FROM messages m
+LEFT JOIN user_privacy_settings p ON p.user_id = m.sender_id
+WHERE m.chat_id = $1 AND m.seq > $2 AND m.seq <= $3
+  AND m.sender_id IS NOT NULL AND m.sender_id <> $4::text AND m.deleted_at IS NULL
+`
+
+type ListMessageSendersParams struct {
+	ChatID   string `json:"chat_id"`
+	AfterSeq int64  `json:"after_seq"`
+	UpToSeq  int64  `json:"up_to_seq"`
+	UserID   string `json:"user_id"`
+}
+
+type ListMessageSendersRow struct {
+	SenderID     string `json:"sender_id"`
+	ReadReceipts bool   `json:"read_receipts"`
+}
+
+// Returns the other senders of the chat's messages with a seq in
+// (after_seq, up_to_seq] and whether they share read receipts.
+func (q *Queries) ListMessageSenders(ctx context.Context, arg ListMessageSendersParams) ([]ListMessageSendersRow, error) {
+	rows, err := q.db.Query(ctx, listMessageSenders,
+		arg.ChatID,
+		arg.AfterSeq,
+		arg.UpToSeq,
+		arg.UserID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMessageSendersRow{}
+	for rows.Next() {
+		var i ListMessageSendersRow
+		if err := rows.Scan(&i.SenderID, &i.ReadReceipts); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markChatDelivered = `-- name: MarkChatDelivered :one
+WITH prev AS (
+    SELECT COALESCE(MAX(d.delivered_seq), 0)::bigint AS delivered_seq
+    FROM device_chat_deliveries d
+    WHERE d.chat_id = $1::text AND d.user_id = $2::text
+), updated AS (
+    INSERT INTO device_chat_deliveries (device_id, chat_id, user_id, delivered_seq)
+    SELECT dev.id, $1::text, dev.user_id, $3
+    FROM devices dev
+    WHERE dev.id = $4::text AND dev.user_id = $2::text AND dev.revoked_at IS NULL
+    ON CONFLICT (device_id, chat_id) DO UPDATE
+    SET delivered_seq = GREATEST(device_chat_deliveries.delivered_seq, EXCLUDED.delivered_seq),
+        updated_at    = NOW()
+    RETURNING device_chat_deliveries.delivered_seq
+)
+SELECT updated.delivered_seq, prev.delivered_seq AS previous_user_seq
+FROM updated, prev
+`
+
+type MarkChatDeliveredParams struct {
+	ChatID       string `json:"chat_id"`
+	UserID       string `json:"user_id"`
+	DeliveredSeq int64  `json:"delivered_seq"`
+	DeviceID     string `json:"device_id"`
+}
+
+type MarkChatDeliveredRow struct {
+	DeliveredSeq    int64 `json:"delivered_seq"`
+	PreviousUserSeq int64 `json:"previous_user_seq"`
+}
+
+// Moves a device's delivery position forward. Returns the device's new
+// position and the highest position any of the user's devices had before,
+// or no row when the device is not an active device of the user.
+func (q *Queries) MarkChatDelivered(ctx context.Context, arg MarkChatDeliveredParams) (MarkChatDeliveredRow, error) {
+	row := q.db.QueryRow(ctx, markChatDelivered,
+		arg.ChatID,
+		arg.UserID,
+		arg.DeliveredSeq,
+		arg.DeviceID,
+	)
+	var i MarkChatDeliveredRow
+	err := row.Scan(&i.DeliveredSeq, &i.PreviousUserSeq)
+	return i, err
+}
+
+const markChatRead = `-- name: MarkChatRead :one
+WITH prev AS (
+    SELECT s.read_seq FROM chat_user_states s
+    WHERE s.user_id = $1::text AND s.chat_id = $2::text
+), updated AS (
+    INSERT INTO chat_user_states (user_id, chat_id, read_seq)
+    VALUES ($1::text, $2::text, $3)
+    ON CONFLICT (user_id, chat_id) DO UPDATE
+    SET read_seq      = GREATEST(chat_user_states.read_seq, EXCLUDED.read_seq),
+        marked_unread = false,
+        updated_at    = NOW()
+    RETURNING chat_user_states.read_seq
+)
+SELECT updated.read_seq, COALESCE((SELECT read_seq FROM prev), 0)::bigint AS previous_read_seq
+FROM updated
+`
+
+type MarkChatReadParams struct {
+	UserID  string `json:"user_id"`
+	ChatID  string `json:"chat_id"`
+	ReadSeq int64  `json:"read_seq"`
+}
+
+type MarkChatReadRow struct {
+	ReadSeq         int64 `json:"read_seq"`
+	PreviousReadSeq int64 `json:"previous_read_seq"`
+}
+
+// Moves the user's read position forward to read_seq and clears the
+// marked-unread flag. Returns the new position and the one before.
+func (q *Queries) MarkChatRead(ctx context.Context, arg MarkChatReadParams) (MarkChatReadRow, error) {
+	row := q.db.QueryRow(ctx, markChatRead, arg.UserID, arg.ChatID, arg.ReadSeq)
+	var i MarkChatReadRow
+	err := row.Scan(&i.ReadSeq, &i.PreviousReadSeq)
+	return i, err
+}
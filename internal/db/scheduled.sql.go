@@ -0,0 +1,380 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: scheduled.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimDueScheduledMessages = `-- name: ClaimDueScheduledMessages :many
+WITH due AS (
+    SELECT s.id FROM scheduled_messages s
+    WHERE s.failed_at IS NULL
+      AND (s.locked_until IS NULL OR s.locked_until < NOW())
+      AND (s.send_at <= NOW()
+           OR EXISTS (SELECT 1 FROM user_presence p
+                      WHERE p.user_id = s.online_user_id AND p.last_online_at > s.created_at))
+    ORDER BY s.send_at ASC NULLS FIRST, s.created_at ASC
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE scheduled_messages s
+SET locked_until = NOW() + make_interval(secs => $1::float8)
+FROM due
+WHERE s.id = due.id
+RETURNING s.id, s.chat_id, s.sender_id, s.sender_device_id, s.thread_id, s.reply_to_id, s.content_type, s.ciphertext, s.device_ids, s.device_ciphertexts, s.send_at, s.online_user_id, s.locked_until, s.failed_at, s.error_code, s.created_at, s.updated_at
+`
+
+type ClaimDueScheduledMessagesParams struct {
+	LeaseSeconds float64 `json:"lease_seconds"`
+	BatchSize    int32   `json:"batch_size"`
+}
+
+// Leases up to batch_size due messages to the caller. Concurrent schedulers
+// skip each other's rows, and a row whose lease ran out without being
+// finished is claimed again. A message waiting for its recipient is due
+// once the recipient was online after it was scheduled.
+func (q *Queries) ClaimDueScheduledMessages(ctx context.Context, arg ClaimDueScheduledMessagesParams) ([]ScheduledMessage, error) {
+	rows, err := q.db.Query(ctx, claimDueScheduledMessages, arg.LeaseSeconds, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ScheduledMessage{}
+	for rows.Next() {
+		var i ScheduledMessage
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChatID,
+			&i.SenderID,
+			&i.SenderDeviceID,
+			&i.ThreadID,
+			&i.ReplyToID,
+			&i.ContentType,
+			&i.Ciphertext,
+			&i.DeviceIds,
+			&i.DeviceCiphertexts,
+			&i.SendAt,
+			&i.OnlineUserID,
+			&i.LockedUntil,
+			&i.FailedAt,
+			&i.ErrorCode,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countScheduledMessages = `-- name: CountScheduledMessages :one
+SELECT COUNT(*) FROM scheduled_messages
+WHERE chat_id = $1 AND sender_id = $2
+`
+
+type CountScheduledMessagesParams struct {
+	ChatID   string `json:"chat_id"`
+	SenderID string `json:"sender_id"`
+}
+
+func (q *Queries) CountScheduledMessages(ctx context.Context, arg CountScheduledMessagesParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countScheduledMessages, arg.ChatID, arg.SenderID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createScheduledMessage = `-- name: CreateScheduledMessage :one
+INSERT INTO scheduled_messages (id, chat_id, sender_id, sender_device_id, thread_id, reply_to_id, content_type,
+                                ciphertext, device_ids, device_ciphertexts, send_at, online_user_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7,
+        $8, $9::text[], $10::bytea[], $11, $12)
+RETURNING id, chat_id, sender_id, sender_device_id, thread_id, reply_to_id, content_type, ciphertext, device_ids, device_ciphertexts, send_at, online_user_id, locked_until, failed_at, error_code, created_at, updated_at
+`
+
+type CreateScheduledMessageParams struct {
+	ID                string             `json:"id"`
+	ChatID            string             `json:"chat_id"`
+	SenderID          string             `json:"sender_id"`
+	SenderDeviceID    string             `json:"sender_device_id"`
+	ThreadID          pgtype.Text        `json:"thread_id"`
+	ReplyToID         pgtype.Text        `json:"reply_to_id"`
+	ContentType       string             `json:"content_type"`
+	Ciphertext        []byte             `json:"ciphertext"`
+	DeviceIds         []string           `json:"device_ids"`
+	DeviceCiphertexts [][]byte           `json:"device_ciphertexts"`
+	SendAt            pgtype.Timestamptz `json:"send_at"`
+	OnlineUserID      pgtype.Text        `json:"online_user_id"`
+}
+
+func (q *Queries) CreateScheduledMessage(ctx context.Context, arg CreateScheduledMessageParams) (ScheduledMessage, error) {
+	row := q.db.QueryRow(ctx, createScheduledMessage,
+		arg.ID,
+		arg.ChatID,
+		arg.SenderID,
+		arg.SenderDeviceID,
+		arg.ThreadID,
+		arg.ReplyToID,
+		arg.ContentType,
+		arg.Ciphertext,
+		arg.DeviceIds,
+		arg.DeviceCiphertexts,
+		arg.SendAt,
+		arg.OnlineUserID,
+	)
+	var i ScheduledMessage
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.SenderID,
+		&i.SenderDeviceID,
+		&i.ThreadID,
+		&i.ReplyToID,
+		&i.ContentType,
+		&i.Ciphertext,
+		&i.DeviceIds,
+		&i.DeviceCiphertexts,
+		&i.SendAt,
+		&i.OnlineUserID,
+		&i.LockedUntil,
+		&i.FailedAt,
+		&i.ErrorCode,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteScheduledMessage = `-- name: DeleteScheduledMessage :execrows
+DELETE FROM scheduled_messages
+WHERE id = $1 AND chat_id = $2 AND sender_id = $3
+  AND (locked_until IS NULL OR locked_until < NOW())
+`
+
+type DeleteScheduledMessageParams struct {
+	ID       string `json:"id"`
+	ChatID   string `json:"chat_id"`
+	SenderID string `json:"sender_id"`
+}
+
+// Cancels a scheduled message that is not being sent right now.
+func (q *Queries) DeleteScheduledMessage(ctx context.Context, arg DeleteScheduledMessageParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteScheduledMessage, arg.ID, arg.ChatID, arg.SenderID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const failScheduledMessage = `-- name: FailScheduledMessage :exec
+UPDATE scheduled_messages
+SET failed_at = NOW(), error_code = $1, locked_until = NULL, updated_at = NOW()
+WHERE id = $2
+`
+
+type FailScheduledMessageParams struct {
+	ErrorCode pgtype.Text `json:"error_code"`
+	ID        string      `json:"id"`
+}
+
+func (q *Queries) FailScheduledMessage(ctx context.Context, arg FailScheduledMessageParams) error {
+	_, err := q.db.Exec(ctx, failScheduledMessage, arg.ErrorCode, arg.ID)
+	return err
+}
+
+const failThreadScheduledMessages = `-- name: FailThreadScheduledMessages :many
+UPDATE scheduled_messages
+SET failed_at = NOW(), error_code = $1, locked_until = NULL, updated_at = NOW()
+WHERE thread_id = $2 AND failed_at IS NULL
+  AND (locked_until IS NULL OR locked_until < NOW())
+RETURNING id, chat_id, sender_id, sender_device_id, thread_id, reply_to_id, content_type, ciphertext, device_ids, device_ciphertexts, send_at, online_user_id, locked_until, failed_at, error_code, created_at, updated_at
+`
+
+type FailThreadScheduledMessagesParams struct {
+	ErrorCode pgtype.Text `json:"error_code"`
+	ThreadID  pgtype.Text `json:"thread_id"`
+}
+
+// Fails the scheduled messages of a thread that are not being sent right
+// now. A message being sent fails on its own once the thread is closed.
+func (q *Queries) FailThreadScheduledMessages(ctx context.Context, arg FailThreadScheduledMessagesParams) ([]ScheduledMessage, error) {
+	rows, err := q.db.Query(ctx, failThreadScheduledMessages, arg.ErrorCode, arg.ThreadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ScheduledMessage{}
+	for rows.Next() {
+		var i ScheduledMessage
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChatID,
+			&i.SenderID,
+			&i.SenderDeviceID,
+			&i.ThreadID,
+			&i.ReplyToID,
+			&i.ContentType,
+			&i.Ciphertext,
+			&i.DeviceIds,
+			&i.DeviceCiphertexts,
+			&i.SendAt,
+			&i.OnlineUserID,
+			&i.LockedUntil,
+			&i.FailedAt,
+			&i.ErrorCode,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const finishScheduledMessage = `-- name: FinishScheduledMessage :exec
+DELETE FROM scheduled_messages WHERE id = $1
+`
+
+func (q *Queries) FinishScheduledMessage(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, finishScheduledMessage, id)
+	return err
+}
+
+const listScheduledMessages = `-- name: ListScheduledMessages :many
+SELECT id, chat_id, sender_id, sender_device_id, thread_id, reply_to_id, content_type, ciphertext, device_ids, device_ciphertexts, send_at, online_user_id, locked_until, failed_at, error_code, created_at, updated_at FROM scheduled_messages
+WHERE chat_id = $1 AND sender_id = $2
+ORDER BY send_at ASC NULLS LAST, created_at ASC, id ASC
+`
+
+type ListScheduledMessagesParams struct {
+	ChatID   string `json:"chat_id"`
+	SenderID string `json:"sender_id"`
+}
+
+// Returns the sender's scheduled messages in a chat, timed ones first in
+// sending order, then those waiting for the recipient to come online.
+func (q *Queries) ListScheduledMessages(ctx context.Context, arg ListScheduledMessagesParams) ([]ScheduledMessage, error) {
+	rows, err := q.db.Query(ctx, listScheduledMessages, arg.ChatID, arg.SenderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ScheduledMessage{}
+	for rows.Next() {
+		var i ScheduledMessage
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChatID,
+			&i.SenderID,
+			&i.SenderDeviceID,
+			&i.ThreadID,
+			&i.ReplyToID,
+			&i.ContentType,
+			&i.Ciphertext,
+			&i.DeviceIds,
+			&i.DeviceCiphertexts,
+			&i.SendAt,
+			&i.OnlineUserID,
+			&i.LockedUntil,
+			&i.FailedAt,
+			&i.ErrorCode,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const touchUserPresence = `-- name: TouchUserPresence :exec
+INSERT INTO user_presence (user_id) VALUES ($1)
+ON CONFLICT (user_id) DO UPDATE SET last_online_at = NOW()
+`
+
+func (q *Queries) TouchUserPresence(ctx context.Context, userID string) error {
+	_, err := q.db.Exec(ctx, touchUserPresence, userID)
+	return err
+}
+
+const updateScheduledMessage = `-- name: UpdateScheduledMessage :one
+UPDATE scheduled_messages
+SET sender_device_id   = COALESCE($1::text, sender_device_id),
+    ciphertext         = COALESCE($2::bytea, ciphertext),
+    device_ids         = COALESCE($3::text[], device_ids),
+    device_ciphertexts = COALESCE($4::bytea[], device_ciphertexts),
+    send_at            = COALESCE($5::timestamptz, send_at),
+    online_user_id     = CASE WHEN $5::timestamptz IS NULL THEN online_user_id END,
+    failed_at          = NULL,
+    error_code         = NULL,
+    updated_at         = NOW()
+WHERE id = $6 AND chat_id = $7 AND sender_id = $8
+  AND (locked_until IS NULL OR locked_until < NOW())
+RETURNING id, chat_id, sender_id, sender_device_id, thread_id, reply_to_id, content_type, ciphertext, device_ids, device_ciphertexts, send_at, online_user_id, locked_until, failed_at, error_code, created_at, updated_at
+`
+
+type UpdateScheduledMessageParams struct {
+	SenderDeviceID    pgtype.Text        `json:"sender_device_id"`
+	Ciphertext        []byte             `json:"ciphertext"`
+	DeviceIds         []string           `json:"device_ids"`
+	DeviceCiphertexts [][]byte           `json:"device_ciphertexts"`
+	SendAt            pgtype.Timestamptz `json:"send_at"`
+	ID                string             `json:"id"`
+	ChatID            string             `json:"chat_id"`
+	SenderID          string             `json:"sender_id"`
+}
+
+// Changes the content or the time of a scheduled message that is not being
+// sent right now, and clears an earlier failure. A new send_at replaces
+// waiting for the recipient to come online.
+func (q *Queries) UpdateScheduledMessage(ctx context.Context, arg UpdateScheduledMessageParams) (ScheduledMessage, error) {
+	row := q.db.QueryRow(ctx, updateScheduledMessage,
+		arg.SenderDeviceID,
+		arg.Ciphertext,
+		arg.DeviceIds,
+		arg.DeviceCiphertexts,
+		arg.SendAt,
+		arg.ID,
+		arg.ChatID,
+		arg.SenderID,
+	)
+	var i ScheduledMessage
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.SenderID,
+		&i.SenderDeviceID,
+		&i.ThreadID,
+		&i.ReplyToID,
+		&i.ContentType,
+		&i.Ciphertext,
+		&i.DeviceIds,
+		&i.DeviceCiphertexts,
+		&i.SendAt,
+		&i.OnlineUserID,
+		&i.LockedUntil,
+		&i.FailedAt,
+		&i.ErrorCode,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
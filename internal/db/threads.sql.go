@@ -0,0 +1,514 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: threads.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createReplyThread = `-- name: CreateReplyThread :one
+INSERT INTO chat_threads (id, chat_id, kind, root_message_id, created_by)
+VALUES ($1, $2, 'replies', $3, $4)
+RETURNING id, chat_id, kind, title, icon_color, root_message_id, created_by, is_closed, last_seq, last_message_id, last_message_at, created_at, updated_at, deleted_at
+`
+
+type CreateReplyThreadParams struct {
+	ID            string      `json:"id"`
+	ChatID        string      `json:"chat_id"`
+	RootMessageID pgtype.Text `json:"root_message_id"`
+	CreatedBy     pgtype.Text `json:"created_by"`
+}
+
+func (q *Queries) CreateReplyThread(ctx context.Context, arg CreateReplyThreadParams) (ChatThread, error) {
+	row := q.db.QueryRow(ctx, createReplyThread,
+		arg.ID,
+		arg.ChatID,
+		arg.RootMessageID,
+		arg.CreatedBy,
+	)
+	var i ChatThread
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.Kind,
+		&i.Title,
+		&i.IconColor,
+		&i.RootMessageID,
+		&i.CreatedBy,
+		&i.IsClosed,
+		&i.LastSeq,
+		&i.LastMessageID,
+		&i.LastMessageAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const createTopic = `-- name: CreateTopic :one
+INSERT INTO chat_threads (id, chat_id, kind, title, icon_color, created_by)
+VALUES ($1, $2, 'topic', $3, $4, $5)
+RETURNING id, chat_id, kind, title, icon_color, root_message_id, created_by, is_closed, last_seq, last_message_id, last_message_at, created_at, updated_at, deleted_at
+`
+
+type CreateTopicParams struct {
+	ID        string      `json:"id"`
+	ChatID    string      `json:"chat_id"`
+	Title     pgtype.Text `json:"title"`
+	IconColor pgtype.Int4 `json:"icon_color"`
+	CreatedBy pgtype.Text `json:"created_by"`
+}
+
+func (q *Queries) CreateTopic(ctx context.Context, arg CreateTopicParams) (ChatThread, error) {
+	row := q.db.QueryRow(ctx, createTopic,
+		arg.ID,
+		arg.ChatID,
+		arg.Title,
+		arg.IconColor,
+		arg.CreatedBy,
+	)
+	var i ChatThread
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.Kind,
+		&i.Title,
+		&i.IconColor,
+		&i.RootMessageID,
+		&i.CreatedBy,
+		&i.IsClosed,
+		&i.LastSeq,
+		&i.LastMessageID,
+		&i.LastMessageAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const deleteTopic = `-- name: DeleteTopic :execrows
+UPDATE chat_threads
+SET is_closed = TRUE, deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND chat_id = $2 AND kind = 'topic' AND deleted_at IS NULL
+`
+
+type DeleteTopicParams struct {
+	ID     string `json:"id"`
+	ChatID string `json:"chat_id"`
+}
+
+// Closes the topic and hides it. The row stays because the topic's
+// tombstoned messages still belong to it.
+func (q *Queries) DeleteTopic(ctx context.Context, arg DeleteTopicParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteTopic, arg.ID, arg.ChatID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getReplyThread = `-- name: GetReplyThread :one
+SELECT id, chat_id, kind, title, icon_color, root_message_id, created_by, is_closed, last_seq, last_message_id, last_message_at, created_at, updated_at, deleted_at FROM chat_threads
+WHERE chat_id = $1 AND root_message_id = $2
+`
+
+type GetReplyThreadParams struct {
+	ChatID        string      `json:"chat_id"`
+	RootMessageID pgtype.Text `json:"root_message_id"`
+}
+
+func (q *Queries) GetReplyThread(ctx context.Context, arg GetReplyThreadParams) (ChatThread, error) {
+	row := q.db.QueryRow(ctx, getReplyThread, arg.ChatID, arg.RootMessageID)
+	var i ChatThread
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.Kind,
+		&i.Title,
+		&i.IconColor,
+		&i.RootMessageID,
+		&i.CreatedBy,
+		&i.IsClosed,
+		&i.LastSeq,
+		&i.LastMessageID,
+		&i.LastMessageAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getThread = `-- name: GetThread :one
+SELECT id, chat_id, kind, title, icon_color, root_message_id, created_by, is_closed, last_seq, last_message_id, last_message_at, created_at, updated_at, deleted_at FROM chat_threads
+WHERE id = $1 AND chat_id = $2 AND deleted_at IS NULL
+`
+
+type GetThreadParams struct {
+	ID     string `json:"id"`
+	ChatID string `json:"chat_id"`
+}
+
+func (q *Queries) GetThread(ctx context.Context, arg GetThreadParams) (ChatThread, error) {
+	row := q.db.QueryRow(ctx, getThread, arg.ID, arg.ChatID)
+	var i ChatThread
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.Kind,
+		&i.Title,
+		&i.IconColor,
+		&i.RootMessageID,
+		&i.CreatedBy,
+		&i.IsClosed,
+		&i.LastSeq,
+		&i.LastMessageID,
+		&i.LastMessageAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getThreadWithState = `-- name: GetThreadWithState :one
+SELECT t.id, t.chat_id, t.kind, t.title, t.icon_color, t.root_message_id, t.created_by, t.is_closed, t.last_seq, t.last_message_id, t.last_message_at, t.created_at, t.updated_at, t.deleted_at,
+       (t.last_seq - COALESCE(s.last_read_seq, 0))::bigint AS unread_count,
+       COALESCE(s.last_read_seq, 0)::bigint AS last_read_seq,
+       COALESCE(s.notify_level, 'all')::thread_notify_level AS notify_level,
+       s.muted_until
+FROM chat_threads t
+LEFT JOIN thread_member_states s ON s.thread_id = t.id AND s.user_id = $1
+WHERE t.id = $2 AND t.chat_id = $3 AND t.deleted_at IS NULL
+`
+
+type GetThreadWithStateParams struct {
+	UserID string `json:"user_id"`
+	ID     string `json:"id"`
+	ChatID string `json:"chat_id"`
+}
+
+type GetThreadWithStateRow struct {
+	ID            string             `json:"id"`
+	ChatID        string             `json:"chat_id"`
+	Kind          ThreadKind         `json:"kind"`
+	Title         pgtype.Text        `json:"title"`
+	IconColor     pgtype.Int4        `json:"icon_color"`
+	RootMessageID pgtype.Text        `json:"root_message_id"`
+	CreatedBy     pgtype.Text        `json:"created_by"`
+	IsClosed      bool               `json:"is_closed"`
+	LastSeq       int64              `json:"last_seq"`
+	LastMessageID pgtype.Text        `json:"last_message_id"`
+	LastMessageAt pgtype.Timestamptz `json:"last_message_at"`
+	CreatedAt     pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt     pgtype.Timestamptz `json:"updated_at"`
+	DeletedAt     pgtype.Timestamptz `json:"deleted_at"`
+	UnreadCount   int64              `json:"unread_count"`
+	LastReadSeq   int64              `json:"last_read_seq"`
+	NotifyLevel   ThreadNotifyLevel  `json:"notify_level"`
+	MutedUntil    pgtype.Timestamptz `json:"muted_until"`
+}
+
+func (q *Queries) GetThreadWithState(ctx context.Context, arg GetThreadWithStateParams) (GetThreadWithStateRow, error) {
+	row := q.db.QueryRow(ctx, getThreadWithState, arg.UserID, arg.ID, arg.ChatID)
+	var i GetThreadWithStateRow
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.Kind,
+		&i.Title,
+		&i.IconColor,
+		&i.RootMessageID,
+		&i.CreatedBy,
+		&i.IsClosed,
+		&i.LastSeq,
+		&i.LastMessageID,
+		&i.LastMessageAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.UnreadCount,
+		&i.LastReadSeq,
+		&i.NotifyLevel,
+		&i.MutedUntil,
+	)
+	return i, err
+}
+
+const listThreadsWithState = `-- name: ListThreadsWithState :many
+SELECT t.id, t.chat_id, t.kind, t.title, t.icon_color, t.root_message_id, t.created_by, t.is_closed, t.last_seq, t.last_message_id, t.last_message_at, t.created_at, t.updated_at, t.deleted_at,
+       (t.last_seq - COALESCE(s.last_read_seq, 0))::bigint AS unread_count,
+       COALESCE(s.last_read_seq, 0)::bigint AS last_read_seq,
+       COALESCE(s.notify_level, 'all')::thread_notify_level AS notify_level,
+       s.muted_until
+FROM chat_threads t
+LEFT JOIN thread_member_states s ON s.thread_id = t.id AND s.user_id = $1
+WHERE t.chat_id = $2 AND t.kind = $3 AND t.deleted_at IS NULL
+  AND ($4::timestamptz IS NULL
+       OR (COALESCE(t.last_message_at, t.created_at), t.id) < ($4::timestamptz, $5::text))
+ORDER BY COALESCE(t.last_message_at, t.created_at) DESC, t.id DESC
+LIMIT $6
+`
+
+type ListThreadsWithStateParams struct {
+	UserID         string             `json:"user_id"`
+	ChatID         string             `json:"chat_id"`
+	Kind           ThreadKind         `json:"kind"`
+	CursorActivity pgtype.Timestamptz `json:"cursor_activity"`
+	CursorID       pgtype.Text        `json:"cursor_id"`
+	PageSize       int32              `json:"page_size"`
+}
+
+type ListThreadsWithStateRow struct {
+	ID            string             `json:"id"`
+	ChatID        string             `json:"chat_id"`
+	Kind          ThreadKind         `json:"kind"`
+	Title         pgtype.Text        `json:"title"`
+	IconColor     pgtype.Int4        `json:"icon_color"`
+	RootMessageID pgtype.Text        `json:"root_message_id"`
+	CreatedBy     pgtype.Text        `json:"created_by"`
+	IsClosed      bool               `json:"is_closed"`
+	LastSeq       int64              `json:"last_seq"`
+	LastMessageID pgtype.Text        `json:"last_message_id"`
+	LastMessageAt pgtype.Timestamptz `json:"last_message_at"`
+	CreatedAt     pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt     pgtype.Timestamptz `json:"updated_at"`
+	DeletedAt     pgtype.Timestamptz `json:"deleted_at"`
+	UnreadCount   int64              `json:"unread_count"`
+	LastReadSeq   int64              `json:"last_read_seq"`
+	NotifyLevel   ThreadNotifyLevel  `json:"notify_level"`
+	MutedUntil    pgtype.Timestamptz `json:"muted_until"`
+}
+
+// Threads of one kind with the reader's unread count and settings, most
+// recently active first.
+func (q *Queries) ListThreadsWithState(ctx context.Context, arg ListThreadsWithStateParams) ([]ListThreadsWithStateRow, error) {
+	rows, err := q.db.Query(ctx, listThreadsWithState,
+		arg.UserID,
+		arg.ChatID,
+		arg.Kind,
+		arg.CursorActivity,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListThreadsWithStateRow{}
+	for rows.Next() {
+		var i ListThreadsWithStateRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChatID,
+			&i.Kind,
+			&i.Title,
+			&i.IconColor,
+			&i.RootMessageID,
+			&i.CreatedBy,
+			&i.IsClosed,
+			&i.LastSeq,
+			&i.LastMessageID,
+			&i.LastMessageAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.UnreadCount,
+			&i.LastReadSeq,
+			&i.NotifyLevel,
+			&i.MutedUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTopicSummaries = `-- name: ListTopicSummaries :many
+SELECT t.chat_id, t.id, t.title, t.icon_color, t.is_closed,
+       t.last_message_id, t.last_message_at,
+       (t.last_seq - COALESCE(s.last_read_seq, 0))::bigint AS unread_count
+FROM unnest($1::text[]) AS c(id)
+CROSS JOIN LATERAL (
+    SELECT id, chat_id, kind, title, icon_color, root_message_id, created_by, is_closed, last_seq, last_message_id, last_message_at, created_at, updated_at, deleted_at FROM chat_threads
+    WHERE chat_threads.chat_id = c.id AND chat_threads.kind = 'topic' AND chat_threads.deleted_at IS NULL
+    ORDER BY COALESCE(chat_threads.last_message_at, chat_threads.created_at) DESC, chat_threads.id DESC
+    LIMIT $2
+) t
+LEFT JOIN thread_member_states s ON s.thread_id = t.id AND s.user_id = $3
+ORDER BY t.chat_id, COALESCE(t.last_message_at, t.created_at) DESC, t.id DESC
+`
+
+type ListTopicSummariesParams struct {
+	ChatIds []string `json:"chat_ids"`
+	PerChat int32    `json:"per_chat"`
+	UserID  string   `json:"user_id"`
+}
+
+type ListTopicSummariesRow struct {
+	ChatID        string             `json:"chat_id"`
+	ID            string             `json:"id"`
+	Title         pgtype.Text        `json:"title"`
+	IconColor     pgtype.Int4        `json:"icon_color"`
+	IsClosed      bool               `json:"is_closed"`
+	LastMessageID pgtype.Text        `json:"last_message_id"`
+	LastMessageAt pgtype.Timestamptz `json:"last_message_at"`
+	UnreadCount   int64              `json:"unread_count"`
+}
+
+// The most recently active topics of each forum chat in a chat list page.
+func (q *Queries) ListTopicSummaries(ctx context.Context, arg ListTopicSummariesParams) ([]ListTopicSummariesRow, error) {
+	rows, err := q.db.Query(ctx, listTopicSummaries, arg.ChatIds, arg.PerChat, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListTopicSummariesRow{}
+	for rows.Next() {
+		var i ListTopicSummariesRow
+		if err := rows.Scan(
+			&i.ChatID,
+			&i.ID,
+			&i.Title,
+			&i.IconColor,
+			&i.IsClosed,
+			&i.LastMessageID,
+			&i.LastMessageAt,
+			&i.UnreadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markThreadRead = `-- name: MarkThreadRead :one
+INSERT INTO thread_member_states (thread_id, user_id, last_read_seq)
+SELECT t.id, $1, LEAST($2::bigint, t.last_seq)
+FROM chat_threads t
+WHERE t.id = $3 AND t.deleted_at IS NULL
+ON CONFLICT (thread_id, user_id) DO UPDATE
+SET last_read_seq = GREATEST(thread_member_states.last_read_seq, EXCLUDED.last_read_seq),
+    updated_at = NOW()
+RETURNING last_read_seq
+`
+
+type MarkThreadReadParams struct {
+	UserID   string `json:"user_id"`
+	Seq      int64  `json:"seq"`
+	ThreadID string `json:"thread_id"`
+}
+
+// Moves the read position forward only, never past the thread's last message.
+func (q *Queries) MarkThreadRead(ctx context.Context, arg MarkThreadReadParams) (int64, error) {
+	row := q.db.QueryRow(ctx, markThreadRead, arg.UserID, arg.Seq, arg.ThreadID)
+	var last_read_seq int64
+	err := row.Scan(&last_read_seq)
+	return last_read_seq, err
+}
+
+const setChatForum = `-- name: SetChatForum :execrows
+UPDATE chats
+SET is_forum = $1, updated_at = NOW()
+WHERE id = $2 AND type = 'group'
+`
+
+type SetChatForumParams struct {
+	IsForum bool   `json:"is_forum"`
+	ID      string `json:"id"`
+}
+
+func (q *Queries) SetChatForum(ctx context.Context, arg SetChatForumParams) (int64, error) {
+	result, err := q.db.Exec(ctx, setChatForum, arg.IsForum, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateThreadNotifications = `-- name: UpdateThreadNotifications :exec
+INSERT INTO thread_member_states (thread_id, user_id, notify_level, muted_until)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (thread_id, user_id) DO UPDATE
+SET notify_level = EXCLUDED.notify_level,
+    muted_until = EXCLUDED.muted_until,
+    updated_at = NOW()
+`
+
+type UpdateThreadNotificationsParams struct {
+	ThreadID    string             `json:"thread_id"`
+	UserID      string             `json:"user_id"`
+	NotifyLevel ThreadNotifyLevel  `json:"notify_level"`
+	MutedUntil  pgtype.Timestamptz `json:"muted_until"`
+}
+
+func (q *Queries) UpdateThreadNotifications(ctx context.Context, arg UpdateThreadNotificationsParams) error {
+	_, err := q.db.Exec(ctx, updateThreadNotifications,
+		arg.ThreadID,
+		arg.UserID,
+		arg.NotifyLevel,
+		arg.MutedUntil,
+	)
+	return err
+}
+
+const updateTopic = `-- name: UpdateTopic :one
+UPDATE chat_threads
+SET title      = COALESCE($1, title),
+    icon_color = COALESCE($2, icon_color),
+    is_closed  = COALESCE($3, is_closed),
+    updated_at = NOW()
+WHERE id = $4 AND chat_id = $5 AND kind = 'topic' AND deleted_at IS NULL
+RETURNING id, chat_id, kind, title, icon_color, root_message_id, created_by, is_closed, last_seq, last_message_id, last_message_at, created_at, updated_at, deleted_at
+`
+
+type UpdateTopicParams struct {
+	Title     pgtype.Text `json:"title"`
+	IconColor pgtype.Int4 `json:"icon_color"`
+	IsClosed  pgtype.Bool `json:"is_closed"`
+	ID        string      `json:"id"`
+	ChatID    string      `json:"chat_id"`
+}
+
+func (q *Queries) UpdateTopic(ctx context.Context, arg UpdateTopicParams) (ChatThread, error) {
+	row := q.db.QueryRow(ctx, updateTopic,
+		arg.Title,
+		arg.IconColor,
+		arg.IsClosed,
+		arg.ID,
+		arg.ChatID,
+	)
+	var i ChatThread
+	err := row.Scan(
+		&i.ID,
+		&i.ChatID,
+		&i.Kind,
+		&i.Title,
+		&i.IconColor,
+		&i.RootMessageID,
+		&i.CreatedBy,
+		&i.IsClosed,
+		&i.LastSeq,
+		&i.LastMessageID,
+		&i.LastMessageAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
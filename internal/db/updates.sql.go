@@ -0,0 +1,254 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: updates.sql
+
+package db
+
+import (
+	"context"
+	"encoding/json"
+)
+
+const appendChannelUpdate = `-- name: AppendChannelUpdate :one
+WITH next AS (
+    UPDATE chats
+    SET update_seq = update_seq + 1
+    WHERE id = $3 AND type = 'channel'
+    RETURNING id, update_seq
+)
+INSERT INTO chat_updates (chat_id, seq, type, payload)
+SELECT next.id, next.update_seq, $1, $2 FROM next
+RETURNING chat_id, seq, type, payload, created_at
+`
+
+type AppendChannelUpdateParams struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+	ChatID  string          `json:"chat_id"`
+}
+
+// Takes the channel's next update seq and records the update in its
+// stream. Returns no row when the chat is not a channel.
+func (q *Queries) AppendChannelUpdate(ctx context.Context, arg AppendChannelUpdateParams) (ChatUpdate, error) {
+	row := q.db.QueryRow(ctx, appendChannelUpdate, arg.Type, arg.Payload, arg.ChatID)
+	var i ChatUpdate
+	err := row.Scan(
+		&i.ChatID,
+		&i.Seq,
+		&i.Type,
+		&i.Payload,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const appendChatUpdate = `-- name: AppendChatUpdate :many
+WITH next AS (
+    INSERT INTO user_update_state (user_id, seq)
+    SELECT m.user_id, 1
+    FROM chat_members m
+    WHERE m.chat_id = $3
+    ORDER BY m.user_id
+    ON CONFLICT (user_id) DO UPDATE
+    SET seq = user_update_state.seq + 1
+    RETURNING user_id, seq
+)
+INSERT INTO user_updates (user_id, seq, type, payload)
+SELECT next.user_id, next.seq, $1, $2 FROM next
+RETURNING user_id, seq, type, payload, created_at
+`
+
+type AppendChatUpdateParams struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+	ChatID  string          `json:"chat_id"`
+}
+
+// Appends the same update to the log of every member of a chat. Members are
+// visited in a fixed order so that concurrent fan-outs lock their
+// user_update_state rows consistently.
+func (q *Queries) AppendChatUpdate(ctx context.Context, arg AppendChatUpdateParams) ([]UserUpdate, error) {
+	rows, err := q.db.Query(ctx, appendChatUpdate, arg.Type, arg.Payload, arg.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []UserUpdate{}
+	for rows.Next() {
+		var i UserUpdate
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Seq,
+			&i.Type,
+			&i.Payload,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const appendUserUpdate = `-- name: AppendUserUpdate :one
+WITH next AS (
+    INSERT INTO user_update_state (user_id, seq)
+    VALUES ($1, 1)
+    ON CONFLICT (user_id) DO UPDATE
+    SET seq = user_update_state.seq + 1
+    RETURNING seq
+)
+INSERT INTO user_updates (user_id, seq, type, payload)
+SELECT $1, next.seq, $2, $3 FROM next
+RETURNING user_id, seq, type, payload, created_at
+`
+
+type AppendUserUpdateParams struct {
+	UserID  string          `json:"user_id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Takes the user's next update seq and records the update. The row lock on
+// user_update_state keeps seq gapless under concurrent writers.
+func (q *Queries) AppendUserUpdate(ctx context.Context, arg AppendUserUpdateParams) (UserUpdate, error) {
+	row := q.db.QueryRow(ctx, appendUserUpdate, arg.UserID, arg.Type, arg.Payload)
+	var i UserUpdate
+	err := row.Scan(
+		&i.UserID,
+		&i.Seq,
+		&i.Type,
+		&i.Payload,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getUserUpdateSeq = `-- name: GetUserUpdateSeq :one
+SELECT COALESCE((SELECT seq FROM user_update_state WHERE user_id = $1), 0)::bigint
+`
+
+// Returns the seq of the user's newest update, 0 when there is none.
+func (q *Queries) GetUserUpdateSeq(ctx context.Context, userID string) (int64, error) {
+	row := q.db.QueryRow(ctx, getUserUpdateSeq, userID)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const listChannelUpdates = `-- name: ListChannelUpdates :many
+SELECT chat_id, seq, type, payload, created_at FROM chat_updates
+WHERE chat_id = $1 AND seq > $2
+ORDER BY seq
+LIMIT $3
+`
+
+type ListChannelUpdatesParams struct {
+	ChatID   string `json:"chat_id"`
+	AfterSeq int64  `json:"after_seq"`
+	Lim      int32  `json:"lim"`
+}
+
+func (q *Queries) ListChannelUpdates(ctx context.Context, arg ListChannelUpdatesParams) ([]ChatUpdate, error) {
+	rows, err := q.db.Query(ctx, listChannelUpdates, arg.ChatID, arg.AfterSeq, arg.Lim)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ChatUpdate{}
+	for rows.Next() {
+		var i ChatUpdate
+		if err := rows.Scan(
+			&i.ChatID,
+			&i.Seq,
+			&i.Type,
+			&i.Payload,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserChannelSeqs = `-- name: ListUserChannelSeqs :many
+SELECT c.id, c.update_seq
+FROM chat_members m
+JOIN chats c ON c.id = m.chat_id
+WHERE m.user_id = $1 AND c.type = 'channel'
+ORDER BY c.id
+`
+
+type ListUserChannelSeqsRow struct {
+	ID        string `json:"id"`
+	UpdateSeq int64  `json:"update_seq"`
+}
+
+// Returns the seq of the newest update of every channel the user is
+// subscribed to.
+func (q *Queries) ListUserChannelSeqs(ctx context.Context, userID string) ([]ListUserChannelSeqsRow, error) {
+	rows, err := q.db.Query(ctx, listUserChannelSeqs, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListUserChannelSeqsRow{}
+	for rows.Next() {
+		var i ListUserChannelSeqsRow
+		if err := rows.Scan(&i.ID, &i.UpdateSeq); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserUpdates = `-- name: ListUserUpdates :many
+SELECT user_id, seq, type, payload, created_at FROM user_updates
+WHERE user_id = $1 AND seq > $2
+ORDER BY seq
+LIMIT $3
+`
+
+type ListUserUpdatesParams struct {
+	UserID   string `json:"user_id"`
+	AfterSeq int64  `json:"after_seq"`
+	Lim      int32  `json:"lim"`
+}
+
+func (q *Queries) ListUserUpdates(ctx context.Context, arg ListUserUpdatesParams) ([]UserUpdate, error) {
+	rows, err := q.db.Query(ctx, listUserUpdates, arg.UserID, arg.AfterSeq, arg.Lim)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []UserUpdate{}
+	for rows.Next() {
+		var i UserUpdate
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Seq,
+			&i.Type,
+			&i.Payload,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,43 @@
+package repos
+
+import (
+	"context"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+)
+
+// AuditEventParams describes an admin action to record. Before and After
+// hold JSON documents and may be nil.
+type AuditEventParams struct {
+	ChatID       ulid.ULID
+	ActorID      ulid.ULID
+	Action       string
+	TargetUserID *ulid.ULID
+	TargetID     string
+	Before       []byte
+	After        []byte
+}
+
+// AuditFilter narrows an audit log listing. Events at or before
+// RetainedSince are hidden even if they have not been purged yet.
+type AuditFilter struct {
+	RetainedSince time.Time
+	Actions       []string
+	ActorID       *ulid.ULID
+	TargetUserID  *ulid.ULID
+}
+
+// AuditCursor is the position after which an audit log page starts.
+type AuditCursor struct {
+	CreatedAt time.Time
+	EventID   string
+}
+
+// AuditRepository defines the interface for database operations on chat audit logs.
+type AuditRepository interface {
+	CreateAuditEvent(ctx context.Context, params AuditEventParams) (*db.ChatAuditEvent, error)
+	ListAuditEvents(ctx context.Context, chatID ulid.ULID, filter AuditFilter, cursor *AuditCursor, limit int32) ([]db.ListAuditEventsRow, error)
+	PurgeAuditEvents(ctx context.Context, olderThan time.Time) (int64, error)
+}
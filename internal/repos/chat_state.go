@@ -0,0 +1,51 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+)
+
+// ChatStateUpdate holds the per-user chat state fields to change; invalid
+// fields are kept. When SetMute is true the mute is replaced: an invalid
+// MutedUntil unmutes, MuteForever mutes without an end.
+type ChatStateUpdate struct {
+	Archived     sql.NullBool
+	MarkedUnread sql.NullBool
+	SetMute      bool
+	MutedUntil   sql.NullTime
+	MuteForever  bool
+	// MentionNotifications sets whether mentions notify while muted.
+	MentionNotifications sql.NullBool
+}
+
+// ChatFolderParams describes the title and rules of a chat folder.
+type ChatFolderParams struct {
+	Title           string
+	IncludeRules    []string
+	ExcludeRules    []string
+	IncludedChatIDs []ulid.ULID
+	ExcludedChatIDs []ulid.ULID
+}
+
+// ChatStateRepository defines the interface for database operations on
+// each user's own view of their chats.
+type ChatStateRepository interface {
+	// Chat state
+	GetChatState(ctx context.Context, userID, chatID ulid.ULID) (*db.ChatUserState, error)
+	UpdateChatState(ctx context.Context, userID, chatID ulid.ULID, update ChatStateUpdate) (*db.ChatUserState, error)
+	PinChat(ctx context.Context, userID, chatID ulid.ULID, maxPinned int) (*db.ChatUserState, error)
+	UnpinChat(ctx context.Context, userID, chatID ulid.ULID) (*db.ChatUserState, error)
+	ListPinnedChatIDs(ctx context.Context, userID ulid.ULID) ([]string, error)
+	ReorderPinnedChats(ctx context.Context, userID ulid.ULID, chatIDs []ulid.ULID) error
+
+	// Folders
+	CreateChatFolder(ctx context.Context, userID ulid.ULID, params ChatFolderParams, maxFolders int) (*db.ChatFolder, error)
+	UpdateChatFolder(ctx context.Context, userID, folderID ulid.ULID, params ChatFolderParams) (*db.ChatFolder, error)
+	GetChatFolder(ctx context.Context, userID, folderID ulid.ULID) (*db.ChatFolder, error)
+	ListChatFolders(ctx context.Context, userID ulid.ULID) ([]db.ChatFolder, error)
+	DeleteChatFolder(ctx context.Context, userID, folderID ulid.ULID) error
+	ReorderChatFolders(ctx context.Context, userID ulid.ULID, folderIDs []ulid.ULID) error
+}
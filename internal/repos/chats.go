@@ -0,0 +1,84 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+)
+
+// ChatCursor is the position after which a chat list page starts.
+type ChatCursor struct {
+	LastActivityAt time.Time
+	ChatID         string
+}
+
+// ChatListFilter narrows a chat list. Invalid Archived and Pinned do not
+// filter; a non-nil Folder applies the folder's rules.
+type ChatListFilter struct {
+	Archived sql.NullBool
+	Pinned   sql.NullBool
+	Folder   *db.ChatFolder
+}
+
+// MemberCursor is the position after which a member list page starts. Join
+// request and ban lists use it too, with JoinedAt holding the request or ban
+// time.
+type MemberCursor struct {
+	JoinedAt time.Time
+	UserID   string
+}
+
+// ChatPermissions holds the default permission bitsets of a chat's roles.
+type ChatPermissions struct {
+	Member int32
+	Admin  int32
+}
+
+// ChatRepository defines the interface for database operations on chats and their members.
+type ChatRepository interface {
+	// Users
+	GetUser(ctx context.Context, userID ulid.ULID) (*db.User, error)
+
+	// Chats
+	CreateUniqueChat(ctx context.Context, chatType db.ChatType, directKey string, createdBy ulid.ULID, memberIDs []ulid.ULID, perms ChatPermissions) (*db.Chat, error)
+	CreateGroupChat(ctx context.Context, ownerID ulid.ULID, title string, photoURL sql.NullString, memberIDs []ulid.ULID, perms ChatPermissions) (*db.Chat, error)
+	UpdateChatInfo(ctx context.Context, chatID ulid.ULID, title, photoURL sql.NullString) (*db.Chat, error)
+	UpdateChatPermissions(ctx context.Context, chatID ulid.ULID, perms ChatPermissions) error
+	DeleteChat(ctx context.Context, chatID ulid.ULID) error
+	GetChat(ctx context.Context, chatID ulid.ULID) (*db.Chat, error)
+	GetChatByDirectKey(ctx context.Context, directKey string) (*db.Chat, error)
+	ListUserChats(ctx context.Context, userID ulid.ULID, filter ChatListFilter, cursor *ChatCursor, limit int32) ([]db.ListUserChatsRow, error)
+	ListTopicSummaries(ctx context.Context, userID ulid.ULID, chatIDs []string, perChat int32) ([]db.ListTopicSummariesRow, error)
+	// ListChatDrafts returns the user's drafts in the given chats. Cleared
+	// drafts are left out.
+	ListChatDrafts(ctx context.Context, userID ulid.ULID, chatIDs []string) ([]db.Draft, error)
+
+	// Channels
+	CreateChannelChat(ctx context.Context, ownerID ulid.ULID, title string, photoURL, username sql.NullString, perms ChatPermissions) (*db.Chat, error)
+	GetChannelByUsername(ctx context.Context, username string) (*db.Chat, error)
+	UpdateChatUsername(ctx context.Context, chatID ulid.ULID, username sql.NullString) error
+	RecordPostViews(ctx context.Context, chatID, userID ulid.ULID, messageIDs []ulid.ULID) ([]db.RecordPostViewsRow, error)
+
+	// Members
+	IsChatMember(ctx context.Context, chatID, userID ulid.ULID) (bool, error)
+	GetChatMember(ctx context.Context, chatID, userID ulid.ULID) (*db.ChatMember, error)
+	AddChatMember(ctx context.Context, chatID, userID ulid.ULID, role db.ChatMemberRole, invitedBy ulid.ULID, maxMembers int) error
+	RemoveChatMember(ctx context.Context, chatID, userID ulid.ULID) error
+	UpdateChatMemberRole(ctx context.Context, chatID, userID ulid.ULID, role db.ChatMemberRole) error
+	UpdateChatMemberPermissions(ctx context.Context, chatID, userID ulid.ULID, permissions sql.NullInt32, customTitle sql.NullString) error
+	TransferChatOwnership(ctx context.Context, chatID, currentOwnerID, newOwnerID ulid.ULID) error
+	ListChatMembers(ctx context.Context, chatID ulid.ULID, cursor *MemberCursor, limit int32) ([]db.ListChatMembersRow, error)
+	ListChatMemberIDs(ctx context.Context, chatID ulid.ULID) ([]string, error)
+
+	// Moderation
+	IsBannedFromChat(ctx context.Context, chatID, userID ulid.ULID) (bool, error)
+	BanChatMember(ctx context.Context, chatID, userID, bannedBy ulid.ULID, until sql.NullTime) (*db.ChatBan, error)
+	UnbanChatMember(ctx context.Context, chatID, userID ulid.ULID) error
+	ListChatBans(ctx context.Context, chatID ulid.ULID, cursor *MemberCursor, limit int32) ([]db.ListChatBansRow, error)
+	RestrictChatMember(ctx context.Context, chatID, userID ulid.ULID, until sql.NullTime) error
+	SetChatSlowMode(ctx context.Context, chatID ulid.ULID, seconds int32) error
+	ClaimSlowModeSlot(ctx context.Context, chatID, userID ulid.ULID, intervalSeconds int32) (*db.ClaimSlowModeSlotRow, error)
+}
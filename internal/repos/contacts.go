@@ -13,15 +13,20 @@ import (
 type ContactRepository interface {
 	// User related
 	FindUserByIdentifier(ctx context.Context, username, email, phone sql.NullString) (*db.User, error)
+	FindUsersByContactInfo(ctx context.Context, phones, emails []string) ([]db.User, error)
 
 	// Contact requests
 	CreateContactRequest(ctx context.Context, from, to ulid.ULID, message sql.NullString) (*db.ContactRequest, error)
 	GetContactRequest(ctx context.Context, requestID ulid.ULID) (*db.ContactRequest, error)
 	UpdateContactRequestState(ctx context.Context, requestID ulid.ULID, state db.ContactRequestState) error
+	// GetContactRequestState returns ErrNotFound when from has never sent to
+	// a request.
+	GetContactRequestState(ctx context.Context, from, to ulid.ULID) (db.ContactRequestState, error)
 
 	// Contacts
 	CreateContact(ctx context.Context, ownerID, peerID ulid.ULID) (*db.Contact, error)
 	ListContacts(ctx context.Context, ownerID ulid.ULID, state db.ContactState) ([]db.Contact, error)
+	ListContactsWithUsers(ctx context.Context, ownerID ulid.ULID) ([]db.ListAcceptedContactsWithUsersRow, error)
 	DeleteContact(ctx context.Context, ownerID, peerID ulid.ULID) error
 
 	// Blocking
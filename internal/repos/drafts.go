@@ -0,0 +1,41 @@
+package repos
+
+import (
+	"context"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+)
+
+// NewDraft is a draft to store for a user in a chat or one of its threads.
+type NewDraft struct {
+	UserID          ulid.ULID
+	ChatID          ulid.ULID
+	ThreadID        *ulid.ULID
+	Ciphertext      []byte
+	ReplyToID       *ulid.ULID
+	ClientUpdatedAt time.Time
+}
+
+// DraftCursor is the position after which a page of drafts starts.
+type DraftCursor struct {
+	UpdatedAt time.Time
+	ChatID    string
+	ThreadID  string
+}
+
+// DraftRepository defines the interface for database operations on the
+// message drafts users sync across their devices.
+type DraftRepository interface {
+	// SaveDraft stores a draft unless the stored one has the same or a newer
+	// client timestamp, in which case it returns ErrNotFound.
+	SaveDraft(ctx context.Context, draft NewDraft) (*db.Draft, error)
+	GetDraft(ctx context.Context, userID, chatID ulid.ULID, threadID *ulid.ULID) (*db.Draft, error)
+	// ListDrafts returns the user's drafts in chats they are a member of,
+	// most recently changed first. Cleared drafts are left out.
+	ListDrafts(ctx context.Context, userID ulid.ULID, cursor *DraftCursor, limit int32) ([]db.Draft, error)
+	// ClearThreadDrafts clears every user's draft in a thread and returns
+	// the cleared drafts.
+	ClearThreadDrafts(ctx context.Context, threadID ulid.ULID) ([]db.Draft, error)
+}
@@ -0,0 +1,11 @@
+package repos
+
+import "errors"
+
+// Sentinel errors returned by repository implementations so that services do
+// not depend on driver-specific error values.
+var (
+	ErrNotFound      = errors.New("record not found")
+	ErrAlreadyExists = errors.New("record already exists")
+	ErrLimitExceeded = errors.New("limit exceeded")
+)
@@ -0,0 +1,23 @@
+package repos
+
+import (
+	"context"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+)
+
+// IdempotencyRepository defines the interface for database operations on
+// stored responses of idempotent requests.
+type IdempotencyRepository interface {
+	// ClaimIdempotencyKey reserves a key for a new request. It returns
+	// ErrAlreadyExists when a live entry holds the key; entries past
+	// expiresAt, or unfinished ones created before staleBefore, are taken over.
+	ClaimIdempotencyKey(ctx context.Context, userID ulid.ULID, key, requestHash string, expiresAt, staleBefore time.Time) error
+	GetIdempotencyKey(ctx context.Context, userID ulid.ULID, key string) (*db.IdempotencyKey, error)
+	CompleteIdempotencyKey(ctx context.Context, userID ulid.ULID, key string, statusCode int, contentType string, body []byte) error
+	// ReleaseIdempotencyKey drops an unfinished claim so the request can be retried.
+	ReleaseIdempotencyKey(ctx context.Context, userID ulid.ULID, key string) error
+	PurgeExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+}
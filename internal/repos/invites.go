@@ -0,0 +1,34 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+)
+
+// InviteLinkParams holds the optional settings of a new invite link.
+type InviteLinkParams struct {
+	Name             sql.NullString
+	ExpiresAt        sql.NullTime
+	UsageLimit       sql.NullInt32
+	RequiresApproval bool
+}
+
+// InviteRepository defines the interface for database operations on invite
+// links and join requests.
+type InviteRepository interface {
+	// Invite links
+	CreateInviteLink(ctx context.Context, chatID, createdBy ulid.ULID, token string, params InviteLinkParams) (*db.ChatInviteLink, error)
+	GetInviteLinkByToken(ctx context.Context, token string) (*db.ChatInviteLink, error)
+	ListInviteLinks(ctx context.Context, chatID ulid.ULID) ([]db.ChatInviteLink, error)
+	RevokeInviteLink(ctx context.Context, chatID, linkID ulid.ULID) error
+	ConsumeInviteLink(ctx context.Context, linkID ulid.ULID) (*db.ChatInviteLink, error)
+
+	// Join requests
+	CreateJoinRequest(ctx context.Context, chatID, userID, linkID ulid.ULID) (*db.ChatJoinRequest, error)
+	GetJoinRequest(ctx context.Context, chatID, userID ulid.ULID) (*db.ChatJoinRequest, error)
+	DecideJoinRequest(ctx context.Context, chatID, userID ulid.ULID, state db.JoinRequestState, decidedBy ulid.ULID) error
+	ListPendingJoinRequests(ctx context.Context, chatID ulid.ULID, cursor *MemberCursor, limit int32) ([]db.ListPendingJoinRequestsRow, error)
+}
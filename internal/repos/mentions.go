@@ -0,0 +1,33 @@
+package repos
+
+import (
+	"context"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+)
+
+// MentionCursor is the position after which a page of mentions starts.
+type MentionCursor struct {
+	MentionedAt time.Time
+	MessageID   string
+}
+
+// MentionFilter narrows a user's mentions feed.
+type MentionFilter struct {
+	ChatID     *ulid.ULID
+	UnreadOnly bool
+}
+
+// MentionRepository defines the interface for database operations on the
+// mentions of users in messages.
+type MentionRepository interface {
+	// ListMentions returns the messages mentioning the user that they can
+	// still see, newest first. DeviceID picks the user's per-device
+	// payloads.
+	ListMentions(ctx context.Context, userID ulid.ULID, filter MentionFilter, deviceID *ulid.ULID, cursor *MentionCursor, limit int32) ([]db.ListMentionsRow, error)
+	// ReadChatMentions marks the user's mentions in a chat read and reports
+	// whether any were unread.
+	ReadChatMentions(ctx context.Context, userID, chatID ulid.ULID) (bool, error)
+}
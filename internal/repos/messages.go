@@ -0,0 +1,141 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+)
+
+// DevicePayload is ciphertext addressed to a single recipient device.
+type DevicePayload struct {
+	DeviceID   ulid.ULID
+	Ciphertext []byte
+}
+
+// NewMessage describes a message to store.
+type NewMessage struct {
+	ChatID   ulid.ULID
+	SenderID ulid.ULID
+	// SenderDeviceID is zero for service messages.
+	SenderDeviceID ulid.ULID
+	ThreadID       *ulid.ULID
+	ContentType    string
+	Ciphertext     []byte
+	DevicePayloads []DevicePayload
+	// ClientMessageID is the sender's own ID for the message, unique per
+	// sender and chat. Empty when the client did not set one.
+	ClientMessageID string
+	ReplyToID       *ulid.ULID
+	// Forward is set for a forwarded copy of another message.
+	Forward *ForwardOrigin
+	// TTL makes the message expire that long after it is sent.
+	TTL      time.Duration
+	ViewOnce bool
+	// Service is the plain JSON description of a service message.
+	Service json.RawMessage
+	// Poll is set for poll messages.
+	Poll *NewPoll
+	// MentionedUserIDs and MentionedUsernames name the members the message
+	// mentions. Usernames are matched in lower case.
+	MentionedUserIDs   []ulid.ULID
+	MentionedUsernames []string
+}
+
+// NewPoll is the part of a poll the server keeps. The option texts stay in
+// the message ciphertext.
+type NewPoll struct {
+	Options        int
+	MultipleChoice bool
+	Anonymous      bool
+	// CorrectOption turns the poll into a quiz.
+	CorrectOption *int
+	ClosesAt      *time.Time
+}
+
+// ForwardOrigin attributes a forwarded message to the message it copies.
+// The IDs are nil when the original sender hides forwarding attribution.
+type ForwardOrigin struct {
+	UserID    *ulid.ULID
+	ChatID    *ulid.ULID
+	MessageID *ulid.ULID
+	// Date is when the original message was sent.
+	Date time.Time
+}
+
+// MessageEdit describes a new version of a message.
+type MessageEdit struct {
+	ChatID         ulid.ULID
+	MessageID      ulid.ULID
+	SenderID       ulid.ULID
+	SenderDeviceID ulid.ULID
+	// EditableAfter rejects the edit for messages sent before it.
+	EditableAfter  time.Time
+	Ciphertext     []byte
+	DevicePayloads []DevicePayload
+}
+
+// MessageListQuery selects the messages of a history page. DeviceID picks
+// the reader's per-device payloads; ThreadID narrows the page to a thread.
+type MessageListQuery struct {
+	ChatID   ulid.ULID
+	UserID   ulid.ULID
+	DeviceID *ulid.ULID
+	ThreadID *ulid.ULID
+}
+
+// MessageRepository defines the interface for database operations on messages.
+type MessageRepository interface {
+	// CreateMessage stores a message with the chat's next seq. It returns
+	// ErrNotFound when the message targets a missing or closed thread and
+	// ErrAlreadyExists when the sender already used its client message ID.
+	CreateMessage(ctx context.Context, msg NewMessage) (*db.Message, error)
+	GetMessage(ctx context.Context, chatID, messageID ulid.ULID) (*db.Message, error)
+	GetMessageByClientID(ctx context.Context, chatID, senderID ulid.ULID, clientMessageID string) (*db.Message, error)
+	// EditMessage stores a new version of a message and archives the
+	// previous one. It returns ErrNotFound unless the message was sent by
+	// the editor after EditableAfter.
+	EditMessage(ctx context.Context, edit MessageEdit) (*db.Message, error)
+	ListMessageEdits(ctx context.Context, messageID, userID ulid.ULID, deviceID *ulid.ULID) ([]db.ListMessageEditsRow, error)
+	// GetMessageForDevice returns a message unless the user deleted it for
+	// themselves or cleared it.
+	GetMessageForDevice(ctx context.Context, chatID, messageID, userID ulid.ULID, deviceID *ulid.ULID) (*db.GetMessageForDeviceRow, error)
+	// ListVisibleMessages returns those of the given messages the user can
+	// still see, in no particular order.
+	ListVisibleMessages(ctx context.Context, chatID, userID ulid.ULID, messageIDs []ulid.ULID) ([]db.Message, error)
+	// ListMessagesBefore returns messages with a seq below beforeSeq, newest first.
+	ListMessagesBefore(ctx context.Context, query MessageListQuery, beforeSeq int64, limit int) ([]db.GetMessageForDeviceRow, error)
+	// ListMessagesAfter returns messages with a seq above afterSeq, oldest first.
+	ListMessagesAfter(ctx context.Context, query MessageListQuery, afterSeq int64, limit int) ([]db.GetMessageForDeviceRow, error)
+
+	// DeleteMessagesForEveryone tombstones messages and removes their
+	// payloads and edit history. It returns the messages it tombstoned.
+	DeleteMessagesForEveryone(ctx context.Context, chatID ulid.ULID, messageIDs []ulid.ULID) ([]db.Message, error)
+	// DeleteThreadMessages tombstones every message of a thread like
+	// DeleteMessagesForEveryone and returns the messages it tombstoned.
+	DeleteThreadMessages(ctx context.Context, chatID, threadID ulid.ULID) ([]db.Message, error)
+	// HideMessages deletes messages for one user only.
+	HideMessages(ctx context.Context, chatID, userID ulid.ULID, messageIDs []ulid.ULID) error
+	// ExpireMessages tombstones up to limit messages whose time ran out and
+	// returns them.
+	ExpireMessages(ctx context.Context, limit int32) ([]db.ExpireMessagesRow, error)
+	// SetChatMessageTTL sets the disappearing message timer of a chat; zero
+	// turns it off.
+	SetChatMessageTTL(ctx context.Context, chatID ulid.ULID, ttl time.Duration) error
+	// ListMessageMentions returns who a message mentions and whether each
+	// of them should be notified.
+	ListMessageMentions(ctx context.Context, messageID ulid.ULID) ([]db.ListMessageMentionsRow, error)
+	// ClearChatHistory hides all current messages of a chat from the user
+	// and returns the seq up to which the history is cleared.
+	ClearChatHistory(ctx context.Context, chatID, userID ulid.ULID) (int64, error)
+	// PurgeUnreferencedMessages tombstones the messages up to maxSeq that
+	// every member has deleted for themselves or cleared. A nil messageIDs
+	// checks all of them. It returns the IDs of the purged messages.
+	PurgeUnreferencedMessages(ctx context.Context, chatID ulid.ULID, maxSeq int64, messageIDs []ulid.ULID) ([]string, error)
+
+	// Devices
+	GetActiveUserDevice(ctx context.Context, userID, deviceID ulid.ULID) (*db.Device, error)
+	FilterMemberDevices(ctx context.Context, chatID ulid.ULID, deviceIDs []ulid.ULID) ([]string, error)
+}
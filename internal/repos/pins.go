@@ -0,0 +1,30 @@
+package repos
+
+import (
+	"context"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+)
+
+// PinCursor is the position after which a page of pinned messages starts.
+type PinCursor struct {
+	PinnedAt  time.Time
+	MessageID string
+}
+
+// PinRepository defines the interface for database operations on pinned
+// messages.
+type PinRepository interface {
+	// PinMessage pins a message and reports whether it was newly pinned.
+	// Deleted messages are not pinned.
+	PinMessage(ctx context.Context, chatID, messageID, userID ulid.ULID) (bool, error)
+	// UnpinMessage returns ErrNotFound when the message was not pinned.
+	UnpinMessage(ctx context.Context, chatID, messageID ulid.ULID) error
+	// UnpinAllMessages unpins every message of a chat and returns their IDs.
+	UnpinAllMessages(ctx context.Context, chatID ulid.ULID) ([]string, error)
+	// ListPinnedMessages returns the pinned messages the user can see, newest
+	// pin first. DeviceID picks the user's per-device payloads.
+	ListPinnedMessages(ctx context.Context, chatID, userID ulid.ULID, deviceID *ulid.ULID, cursor *PinCursor, limit int32) ([]db.ListPinnedMessagesRow, error)
+}
@@ -0,0 +1,33 @@
+package repos
+
+import (
+	"context"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+)
+
+// PollVoterCursor is the position after which a page of poll voters starts.
+type PollVoterCursor struct {
+	VotedAt time.Time
+	UserID  string
+}
+
+// PollRepository defines the interface for database operations on polls and
+// their votes.
+type PollRepository interface {
+	// GetPoll returns a poll with its results and the user's own choices.
+	GetPoll(ctx context.Context, chatID, messageID, userID ulid.ULID) (*db.GetPollRow, error)
+	// Vote stores the user's choices and reports whether anything changed.
+	// Nothing changes when the poll is closed, the choices are the same or
+	// the user already answered the quiz.
+	Vote(ctx context.Context, chatID, messageID, userID ulid.ULID, options []int16) (bool, error)
+	// RetractVote removes the user's vote from an open poll that is not a
+	// quiz and reports whether there was one.
+	RetractVote(ctx context.Context, chatID, messageID, userID ulid.ULID) (bool, error)
+	// ClosePoll returns ErrNotFound when the poll is missing or closed.
+	ClosePoll(ctx context.Context, chatID, messageID ulid.ULID) error
+	// ListVoters lists who voted, optionally only for one option.
+	ListVoters(ctx context.Context, messageID ulid.ULID, option *int16, cursor *PollVoterCursor, limit int32) ([]db.ListPollVotersRow, error)
+}
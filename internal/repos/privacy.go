@@ -0,0 +1,25 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+)
+
+// PrivacySettingsUpdate holds the privacy settings to change; invalid
+// fields are kept.
+type PrivacySettingsUpdate struct {
+	ReadReceipts       sql.NullBool
+	ForwardAttribution sql.NullBool
+}
+
+// PrivacyRepository defines the interface for database operations on users'
+// privacy settings.
+type PrivacyRepository interface {
+	// GetPrivacySettings returns ErrNotFound for a user who never changed
+	// the defaults.
+	GetPrivacySettings(ctx context.Context, userID ulid.ULID) (*db.UserPrivacySetting, error)
+	UpdatePrivacySettings(ctx context.Context, userID ulid.ULID, update PrivacySettingsUpdate) (*db.UserPrivacySetting, error)
+}
@@ -0,0 +1,41 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+)
+
+// ReactionCursor is the position after which a page of reactions starts.
+type ReactionCursor struct {
+	CreatedAt time.Time
+	UserID    string
+	Emoji     string
+}
+
+// ReactionRepository defines the interface for database operations on
+// message reactions.
+type ReactionRepository interface {
+	// LockMessageReactions locks a message until the end of the transaction
+	// and returns its reaction counts, so that the limits can be checked
+	// against the reactions added concurrently. It returns ErrNotFound when
+	// the message is missing or deleted.
+	LockMessageReactions(ctx context.Context, chatID, messageID ulid.ULID) (json.RawMessage, error)
+	// AddReaction adds a reaction and returns the message's updated counts.
+	// It returns ErrNotFound when nothing was added: the message is missing
+	// or deleted, the reaction exists, or a limit was reached.
+	AddReaction(ctx context.Context, chatID, messageID, userID ulid.ULID, emoji string, maxPerUser, maxPerMessage int) (json.RawMessage, error)
+	// RemoveReaction removes a reaction and returns the message's updated
+	// counts. It returns ErrNotFound when the user had no such reaction.
+	RemoveReaction(ctx context.Context, chatID, messageID, userID ulid.ULID, emoji string) (json.RawMessage, error)
+	ListUserReactions(ctx context.Context, messageID, userID ulid.ULID) ([]string, error)
+	// ListReactions lists who reacted to a message. An empty emoji lists
+	// every reaction.
+	ListReactions(ctx context.Context, messageID ulid.ULID, emoji string, cursor *ReactionCursor, limit int32) ([]db.ListReactionsRow, error)
+	// SetChatAllowedReactions limits the emoji of a chat. A nil list allows
+	// every emoji.
+	SetChatAllowedReactions(ctx context.Context, chatID ulid.ULID, emoji []string) error
+}
@@ -0,0 +1,25 @@
+package repos
+
+import (
+	"context"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+)
+
+// ReceiptRepository defines the interface for database operations on
+// delivery and read positions.
+type ReceiptRepository interface {
+	// MarkChatRead moves the user's read position forward and clears the
+	// marked-unread flag.
+	MarkChatRead(ctx context.Context, userID, chatID ulid.ULID, seq int64) (*db.MarkChatReadRow, error)
+	// MarkChatDelivered moves a device's delivery position forward. It
+	// returns ErrNotFound unless the device is an active device of the user.
+	MarkChatDelivered(ctx context.Context, userID, chatID, deviceID ulid.ULID, seq int64) (*db.MarkChatDeliveredRow, error)
+	// ListMessageSenders returns the senders other than userID of the
+	// messages with a seq in (afterSeq, upToSeq].
+	ListMessageSenders(ctx context.Context, chatID, userID ulid.ULID, afterSeq, upToSeq int64) ([]db.ListMessageSendersRow, error)
+	// GetChatReceipts returns the delivery and read positions of the chat's
+	// members other than userID.
+	GetChatReceipts(ctx context.Context, chatID, userID ulid.ULID) (*db.GetChatReceiptsRow, error)
+}
@@ -0,0 +1,67 @@
+package repos
+
+import (
+	"context"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+)
+
+// NewScheduledMessage describes a message to send later: at SendAt, or
+// when OnlineUserID is next online.
+type NewScheduledMessage struct {
+	ChatID         ulid.ULID
+	SenderID       ulid.ULID
+	SenderDeviceID ulid.ULID
+	ThreadID       *ulid.ULID
+	ReplyToID      *ulid.ULID
+	ContentType    string
+	Ciphertext     []byte
+	DevicePayloads []DevicePayload
+	SendAt         *time.Time
+	OnlineUserID   *ulid.ULID
+}
+
+// ScheduledMessageUpdate changes a scheduled message. A nil Content keeps
+// the content and a nil SendAt keeps the time.
+type ScheduledMessageUpdate struct {
+	Content *ScheduledContent
+	SendAt  *time.Time
+}
+
+// ScheduledContent is the new encrypted content of a scheduled message.
+type ScheduledContent struct {
+	SenderDeviceID ulid.ULID
+	Ciphertext     []byte
+	DevicePayloads []DevicePayload
+}
+
+// ScheduledMessageRepository defines the interface for database operations
+// on scheduled messages.
+type ScheduledMessageRepository interface {
+	CreateScheduledMessage(ctx context.Context, msg NewScheduledMessage) (*db.ScheduledMessage, error)
+	CountScheduledMessages(ctx context.Context, chatID, senderID ulid.ULID) (int64, error)
+	ListScheduledMessages(ctx context.Context, chatID, senderID ulid.ULID) ([]db.ScheduledMessage, error)
+	// UpdateScheduledMessage returns ErrNotFound when the message does not
+	// exist or is being sent right now.
+	UpdateScheduledMessage(ctx context.Context, chatID, senderID, id ulid.ULID, update ScheduledMessageUpdate) (*db.ScheduledMessage, error)
+	// DeleteScheduledMessage returns ErrNotFound when the message does not
+	// exist or is being sent right now.
+	DeleteScheduledMessage(ctx context.Context, chatID, senderID, id ulid.ULID) error
+
+	// ClaimDueScheduledMessages leases up to limit due messages for lease.
+	// Other callers skip leased messages until the lease runs out.
+	ClaimDueScheduledMessages(ctx context.Context, lease time.Duration, limit int32) ([]db.ScheduledMessage, error)
+	// FinishScheduledMessage removes a message that was sent.
+	FinishScheduledMessage(ctx context.Context, id string) error
+	// FailScheduledMessage keeps a message that cannot be sent with the
+	// reason, until its sender edits or cancels it.
+	FailScheduledMessage(ctx context.Context, id, errorCode string) error
+	// FailThreadScheduledMessages fails the scheduled messages of a thread
+	// that are not being sent right now and returns them.
+	FailThreadScheduledMessages(ctx context.Context, threadID ulid.ULID, errorCode string) ([]db.ScheduledMessage, error)
+
+	// TouchUserPresence records that the user is online now.
+	TouchUserPresence(ctx context.Context, userID ulid.ULID) error
+}
@@ -0,0 +1,43 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+)
+
+// ThreadCursor is the position after which a thread list page starts.
+type ThreadCursor struct {
+	LastActivityAt time.Time
+	ThreadID       string
+}
+
+// TopicUpdate holds the topic fields to change; invalid fields are kept.
+type TopicUpdate struct {
+	Title     sql.NullString
+	IconColor sql.NullInt32
+	Closed    sql.NullBool
+}
+
+// ThreadRepository defines the interface for database operations on forum
+// topics and reply threads.
+type ThreadRepository interface {
+	SetChatForum(ctx context.Context, chatID ulid.ULID, enabled bool) error
+
+	// Threads
+	CreateTopic(ctx context.Context, chatID, createdBy ulid.ULID, title string, iconColor sql.NullInt32) (*db.ChatThread, error)
+	CreateReplyThread(ctx context.Context, chatID, rootMessageID, createdBy ulid.ULID) (*db.ChatThread, error)
+	GetThread(ctx context.Context, chatID, threadID ulid.ULID) (*db.ChatThread, error)
+	GetReplyThread(ctx context.Context, chatID, rootMessageID ulid.ULID) (*db.ChatThread, error)
+	UpdateTopic(ctx context.Context, chatID, threadID ulid.ULID, update TopicUpdate) (*db.ChatThread, error)
+	DeleteTopic(ctx context.Context, chatID, threadID ulid.ULID) error
+
+	// Per-user state
+	GetThreadWithState(ctx context.Context, chatID, threadID, userID ulid.ULID) (*db.ListThreadsWithStateRow, error)
+	ListThreadsWithState(ctx context.Context, chatID, userID ulid.ULID, kind db.ThreadKind, cursor *ThreadCursor, limit int32) ([]db.ListThreadsWithStateRow, error)
+	MarkThreadRead(ctx context.Context, threadID, userID ulid.ULID, seq int64) (int64, error)
+	UpdateThreadNotifications(ctx context.Context, threadID, userID ulid.ULID, level db.ThreadNotifyLevel, mutedUntil sql.NullTime) error
+}
@@ -0,0 +1,42 @@
+package repos
+
+import "context"
+
+// Transactor runs units of work in a database transaction.
+type Transactor interface {
+	// InTx runs fn in a transaction. Repository calls made with the context
+	// passed to fn take part in it. The transaction commits when fn returns
+	// nil and rolls back otherwise. A call nested in fn runs in a savepoint
+	// of the outer transaction, so when it fails only its own work is
+	// undone and the outer transaction can go on.
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type commitHooksKey struct{}
+
+type commitHooks struct {
+	fns []func()
+}
+
+// AfterCommit runs fn once the transaction of ctx commits, or right away
+// when ctx belongs to no transaction. Side effects that must not be seen
+// before the data they describe, such as pushes to devices, go here.
+func AfterCommit(ctx context.Context, fn func()) {
+	if hooks, ok := ctx.Value(commitHooksKey{}).(*commitHooks); ok {
+		hooks.fns = append(hooks.fns, fn)
+		return
+	}
+	fn()
+}
+
+// WithCommitHooks returns a context that collects AfterCommit functions and
+// a function that runs them. Transactor implementations call it when a
+// transaction begins and run the hooks after it commits.
+func WithCommitHooks(ctx context.Context) (context.Context, func()) {
+	hooks := &commitHooks{}
+	return context.WithValue(ctx, commitHooksKey{}, hooks), func() {
+		for _, fn := range hooks.fns {
+			fn()
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package repos
+
+import (
+	"context"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+)
+
+// UpdateRepository defines the interface for the durable per-user update log
+// and the update streams of channels.
+type UpdateRepository interface {
+	AppendUserUpdate(ctx context.Context, userID ulid.ULID, updateType string, payload []byte) (*db.UserUpdate, error)
+	AppendChatUpdate(ctx context.Context, chatID ulid.ULID, updateType string, payload []byte) ([]db.UserUpdate, error)
+	// GetUserUpdateSeq returns the seq of the user's newest update, 0 when there is none.
+	GetUserUpdateSeq(ctx context.Context, userID ulid.ULID) (int64, error)
+	ListUserUpdates(ctx context.Context, userID ulid.ULID, afterSeq int64, limit int) ([]db.UserUpdate, error)
+
+	// AppendChannelUpdate records an update once in a channel's stream. It
+	// returns ErrNotFound when the chat is not a channel.
+	AppendChannelUpdate(ctx context.Context, chatID ulid.ULID, updateType string, payload []byte) (*db.ChatUpdate, error)
+	// ListUserChannelSeqs returns the newest seq of each channel the user is subscribed to.
+	ListUserChannelSeqs(ctx context.Context, userID ulid.ULID) ([]db.ListUserChannelSeqsRow, error)
+	ListChannelUpdates(ctx context.Context, chatID ulid.ULID, afterSeq int64, limit int) ([]db.ChatUpdate, error)
+	ListChatMemberIDs(ctx context.Context, chatID ulid.ULID) ([]string, error)
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+// defaultAuditRetention applies when no retention period is configured.
+const defaultAuditRetention = 180 * 24 * time.Hour
+
+// Audit log actions.
+const (
+	AuditInfoChanged          = "info_changed"
+	AuditUsernameChanged      = "username_changed"
+	AuditForumToggled         = "forum_toggled"
+	AuditMemberAdded          = "member_added"
+	AuditMemberRemoved        = "member_removed"
+	AuditRoleChanged          = "role_changed"
+	AuditOwnershipTransferred = "ownership_transferred"
+	AuditDefaultPermissions   = "default_permissions_changed"
+	AuditMemberPermissions    = "member_permissions_changed"
+	AuditMemberBanned         = "member_banned"
+	AuditMemberUnbanned       = "member_unbanned"
+	AuditMemberRestricted     = "member_restricted"
+	AuditSlowModeChanged      = "slow_mode_changed"
+	AuditInviteLinkCreated    = "invite_link_created"
+	AuditInviteLinkRevoked    = "invite_link_revoked"
+	AuditJoinRequestApproved  = "join_request_approved"
+	AuditJoinRequestDeclined  = "join_request_declined"
+	AuditTopicDeleted         = "topic_deleted"
+	AuditMessagePinned        = "message_pinned"
+	AuditMessageUnpinned      = "message_unpinned"
+	AuditMessageDeleted       = "message_deleted"
+	AuditReactionsChanged     = "reactions_changed"
+	AuditMessageTTLChanged    = "message_ttl_changed"
+)
+
+var auditActions = map[string]bool{
+	AuditInfoChanged: true, AuditUsernameChanged: true, AuditForumToggled: true,
+	AuditMemberAdded: true, AuditMemberRemoved: true, AuditRoleChanged: true,
+	AuditOwnershipTransferred: true, AuditDefaultPermissions: true, AuditMemberPermissions: true,
+	AuditMemberBanned: true, AuditMemberUnbanned: true, AuditMemberRestricted: true,
+	AuditSlowModeChanged: true, AuditInviteLinkCreated: true, AuditInviteLinkRevoked: true,
+	AuditJoinRequestApproved: true, AuditJoinRequestDeclined: true, AuditTopicDeleted: true,
+	AuditMessagePinned: true, AuditMessageUnpinned: true, AuditMessageDeleted: true,
+	AuditReactionsChanged: true, AuditMessageTTLChanged: true,
+}
+
+// AuditEvent is one admin action in a chat's audit log.
+type AuditEvent struct {
+	ID             string          `json:"id"`
+	Action         string          `json:"action"`
+	ActorID        string          `json:"actor_id,omitempty"`
+	ActorUsername  string          `json:"actor_username,omitempty"`
+	TargetUserID   string          `json:"target_user_id,omitempty"`
+	TargetUsername string          `json:"target_username,omitempty"`
+	TargetID       string          `json:"target_id,omitempty"`
+	Before         json.RawMessage `json:"before,omitempty"`
+	After          json.RawMessage `json:"after,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// AuditLogPage is one page of a chat's audit log.
+type AuditLogPage struct {
+	Items      []AuditEvent `json:"items"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// AuditLogFilter narrows the audit log. Empty fields do not filter.
+type AuditLogFilter struct {
+	Actions      []string
+	ActorID      *ulid.ULID
+	TargetUserID *ulid.ULID
+}
+
+// auditTarget is the subject of an audited action: a user, another object
+// such as an invite link, or both.
+type auditTarget struct {
+	UserID *ulid.ULID
+	ID     string
+}
+
+func targetUser(id ulid.ULID) auditTarget {
+	return auditTarget{UserID: &id}
+}
+
+// ListAuditLog returns a page of a group's or channel's audit log, newest
+// first. Only owners and admins can read it.
+func (s *ChatsService) ListAuditLog(ctx context.Context, actorID, chatID ulid.ULID, filter AuditLogFilter, cursor string, limit int) (*AuditLogPage, error) {
+	if _, err := s.getModerator(ctx, chatID, actorID); err != nil {
+		return nil, err
+	}
+	for _, action := range filter.Actions {
+		if !auditActions[action] {
+			return nil, &BusinessError{Code: string(utils.ErrValidation), Message: "Unknown audit action: " + action}
+		}
+	}
+	limit = clampPageSize(limit, defaultChatPageSize, maxChatPageSize)
+
+	var after *repos.AuditCursor
+	if cursor != "" {
+		parts, err := utils.DecodeCursor(cursor, 2)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		micros, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		after = &repos.AuditCursor{CreatedAt: time.UnixMicro(micros), EventID: parts[1]}
+	}
+
+	rows, err := s.audit.ListAuditEvents(ctx, chatID, repos.AuditFilter{
+		RetainedSince: time.Now().Add(-s.auditRetention()),
+		Actions:       filter.Actions,
+		ActorID:       filter.ActorID,
+		TargetUserID:  filter.TargetUserID,
+	}, after, int32(limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	page := &AuditLogPage{}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[limit-1]
+		page.NextCursor = utils.EncodeCursor(strconv.FormatInt(last.CreatedAt.Time.UnixMicro(), 10), last.ID)
+	}
+	page.Items = make([]AuditEvent, len(rows))
+	for i, row := range rows {
+		page.Items[i] = AuditEvent{
+			ID:             row.ID,
+			Action:         row.Action,
+			ActorID:        row.ActorID.String,
+			ActorUsername:  row.ActorUsername.String,
+			TargetUserID:   row.TargetUserID.String,
+			TargetUsername: row.TargetUsername.String,
+			TargetID:       row.TargetID.String,
+			Before:         row.Before,
+			After:          row.After,
+			CreatedAt:      row.CreatedAt.Time,
+		}
+	}
+	return page, nil
+}
+
+// PurgeAuditLog deletes audit events older than the retention period.
+func (s *ChatsService) PurgeAuditLog(ctx context.Context) (int64, error) {
+	return s.audit.PurgeAuditEvents(ctx, time.Now().Add(-s.auditRetention()))
+}
+
+// RunAuditRetention purges expired audit events every interval until ctx
+// is done.
+func (s *ChatsService) RunAuditRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := s.PurgeAuditLog(ctx); err != nil {
+				log.Printf("audit: purge failed: %v", err)
+			} else if n > 0 {
+				log.Printf("audit: purged %d expired events", n)
+			}
+		}
+	}
+}
+
+// recordAudit appends an admin action to the chat's audit log. before and
+// after are stored as JSON and may be nil.
+func (s *ChatsService) recordAudit(ctx context.Context, chatID, actorID ulid.ULID, action string, target auditTarget, before, after any) error {
+	params := repos.AuditEventParams{
+		ChatID:       chatID,
+		ActorID:      actorID,
+		Action:       action,
+		TargetUserID: target.UserID,
+		TargetID:     target.ID,
+	}
+	var err error
+	if params.Before, err = marshalAuditValue(before); err != nil {
+		return err
+	}
+	if params.After, err = marshalAuditValue(after); err != nil {
+		return err
+	}
+	_, err = s.audit.CreateAuditEvent(ctx, params)
+	return err
+}
+
+func (s *ChatsService) auditRetention() time.Duration {
+	if s.limits.AuditLogRetention <= 0 {
+		return defaultAuditRetention
+	}
+	return s.limits.AuditLogRetention
+}
+
+// nullableString maps an empty string to a JSON null.
+func nullableString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func marshalAuditValue(v any) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListAuditLog_RecordsAdminActions_RealDB(t *testing.T) {
+	service := setupChatsService()
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	memberID := createUser(t, ctx, "member")
+
+	group, err := service.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team"})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+
+	title := "Renamed"
+	_, err = service.UpdateGroupInfo(ctx, ownerID, groupID, &title, nil)
+	require.NoError(t, err)
+	diff, err := service.updates.GetDifference(ctx, ownerID, 0, nil, 10)
+	require.NoError(t, err)
+	require.NotEmpty(t, diff.Updates)
+	assert.Equal(t, UpdateChatInfo, diff.Updates[len(diff.Updates)-1].Type)
+	require.NoError(t, service.AddMember(ctx, ownerID, groupID, memberID))
+	require.NoError(t, service.SetMemberRole(ctx, ownerID, groupID, memberID, db.ChatMemberRoleAdmin))
+	require.NoError(t, service.SetMemberRole(ctx, ownerID, groupID, memberID, db.ChatMemberRoleMember))
+
+	// Regular members cannot read the log.
+	_, err = service.ListAuditLog(ctx, memberID, groupID, AuditLogFilter{}, "", 10)
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+
+	page, err := service.ListAuditLog(ctx, ownerID, groupID, AuditLogFilter{}, "", 2)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	require.NotEmpty(t, page.NextCursor)
+	assert.Equal(t, AuditRoleChanged, page.Items[0].Action)
+
+	rest, err := service.ListAuditLog(ctx, ownerID, groupID, AuditLogFilter{}, page.NextCursor, 10)
+	require.NoError(t, err)
+	require.Len(t, rest.Items, 2)
+	assert.Equal(t, AuditInfoChanged, rest.Items[1].Action)
+
+	var before, after map[string]string
+	require.NoError(t, json.Unmarshal(rest.Items[1].Before, &before))
+	require.NoError(t, json.Unmarshal(rest.Items[1].After, &after))
+	assert.Equal(t, "Team", before["title"])
+	assert.Equal(t, "Renamed", after["title"])
+
+	filtered, err := service.ListAuditLog(ctx, ownerID, groupID, AuditLogFilter{Actions: []string{AuditMemberAdded}}, "", 10)
+	require.NoError(t, err)
+	require.Len(t, filtered.Items, 1)
+	assert.Equal(t, memberID.String(), filtered.Items[0].TargetUserID)
+	assert.Equal(t, "owner", filtered.Items[0].ActorUsername)
+
+	_, err = service.ListAuditLog(ctx, ownerID, groupID, AuditLogFilter{Actions: []string{"nope"}}, "", 10)
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+// maxViewBatch limits how many posts a single view report may cover.
+const maxViewBatch = 100
+
+// channelUsernamePattern accepts 5-32 letters, digits and underscores
+// starting with a letter.
+var channelUsernamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{4,31}$`)
+
+// CreateChannelParams describes a new broadcast channel. A channel with a
+// username is public.
+type CreateChannelParams struct {
+	Title    string
+	PhotoURL *string
+	Username *string
+}
+
+// PostViews is the view counter of a single channel post.
+type PostViews struct {
+	MessageID string `json:"message_id"`
+	Views     int32  `json:"views"`
+}
+
+// CreateChannel creates a broadcast channel owned by the caller. Channels
+// start with the owner as their only member and have no member limit.
+func (s *ChatsService) CreateChannel(ctx context.Context, ownerID ulid.ULID, params CreateChannelParams) (*db.Chat, error) {
+	title := strings.TrimSpace(params.Title)
+	if title == "" || utf8.RuneCountInString(title) > maxChatTitleLength {
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("Title must be 1-%d characters", maxChatTitleLength)}
+	}
+	username, err := parseChannelUsername(params.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	var photoURL sql.NullString
+	if params.PhotoURL != nil {
+		photoURL = sql.NullString{String: *params.PhotoURL, Valid: true}
+	}
+	var chat *db.Chat
+	err = s.updates.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if chat, err = s.repo.CreateChannelChat(ctx, ownerID, title, photoURL, username, DefaultPermissions(db.ChatTypeChannel)); err != nil {
+			return err
+		}
+		// The owner's devices do not follow the new channel's stream yet.
+		return s.updates.Publish(ctx, ownerID, UpdateNewChat, chat)
+	})
+	if errors.Is(err, repos.ErrAlreadyExists) {
+		return nil, usernameTaken()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return chat, nil
+}
+
+// ResolveChannel finds a public channel by its username. Private channels
+// can only be reached through invite links.
+func (s *ChatsService) ResolveChannel(ctx context.Context, username string) (*db.Chat, error) {
+	chat, err := s.repo.GetChannelByUsername(ctx, username)
+	if errors.Is(err, repos.ErrNotFound) {
+		return nil, chatNotFound()
+	}
+	return chat, err
+}
+
+// Subscribe adds the caller to a public channel as a subscriber.
+func (s *ChatsService) Subscribe(ctx context.Context, userID, chatID ulid.ULID) (*db.Chat, error) {
+	chat, err := s.repo.GetChat(ctx, chatID)
+	if err != nil {
+		if errors.Is(err, repos.ErrNotFound) {
+			return nil, chatNotFound()
+		}
+		return nil, err
+	}
+	if chat.Type != db.ChatTypeChannel || !chat.Username.Valid {
+		return nil, chatNotFound()
+	}
+	if err := s.admitMember(ctx, chat, userID, ulid.ULID{}); err != nil {
+		return nil, err
+	}
+	return s.repo.GetChat(ctx, chatID)
+}
+
+// SetChannelUsername makes a channel public under the given username, or
+// private when username is nil. Only the owner can change it.
+func (s *ChatsService) SetChannelUsername(ctx context.Context, actorID, chatID ulid.ULID, username *string) error {
+	chat, actor, err := s.getGroupMember(ctx, chatID, actorID)
+	if err != nil {
+		return err
+	}
+	if chat.Type != db.ChatTypeChannel {
+		return notInChannel()
+	}
+	if actor.Role != db.ChatMemberRoleOwner {
+		return forbiddenRole("Only the owner can change the channel username")
+	}
+	name, err := parseChannelUsername(username)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.UpdateChatUsername(ctx, chatID, name)
+	switch {
+	case errors.Is(err, repos.ErrAlreadyExists):
+		return usernameTaken()
+	case errors.Is(err, repos.ErrNotFound):
+		return chatNotFound()
+	case err != nil:
+		return err
+	}
+	return s.recordAudit(ctx, chatID, actorID, AuditUsernameChanged, auditTarget{},
+		map[string]any{"username": nullableString(chat.Username.String)},
+		map[string]any{"username": nullableString(name.String)})
+}
+
+// RecordViews counts the caller's view of channel posts, once per post, and
+// returns the current counters. Subscribers never learn who else viewed.
+func (s *ChatsService) RecordViews(ctx context.Context, userID, chatID ulid.ULID, messageIDs []ulid.ULID) ([]PostViews, error) {
+	access, err := s.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return nil, err
+	}
+	if access.Chat.Type != db.ChatTypeChannel {
+		return nil, notInChannel()
+	}
+	if len(messageIDs) == 0 || len(messageIDs) > maxViewBatch {
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("Provide 1-%d message IDs", maxViewBatch)}
+	}
+
+	seen := make(map[ulid.ULID]bool, len(messageIDs))
+	unique := make([]ulid.ULID, 0, len(messageIDs))
+	for _, id := range messageIDs {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	rows, err := s.repo.RecordPostViews(ctx, chatID, userID, unique)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]PostViews, 0, len(rows))
+	for _, row := range rows {
+		views = append(views, PostViews{MessageID: row.MessageID, Views: row.ViewCount})
+	}
+	return views, nil
+}
+
+// parseChannelUsername validates an optional username. Nil or empty means
+// the channel is private.
+func parseChannelUsername(username *string) (sql.NullString, error) {
+	if username == nil || *username == "" {
+		return sql.NullString{}, nil
+	}
+	if !channelUsernamePattern.MatchString(*username) {
+		return sql.NullString{}, &BusinessError{Code: string(utils.ErrValidation), Message: "Username must be 5-32 letters, digits or underscores and start with a letter"}
+	}
+	return sql.NullString{String: *username, Valid: true}, nil
+}
+
+func usernameTaken() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrUsernameTaken), Message: "This username is already taken"}
+}
+
+func notInChannel() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrValidation), Message: "This operation is only available in channels"}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannel_SubscribeAndViews_RealDB(t *testing.T) {
+	service := setupChatsService()
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	username := "CompanyNews"
+	channel, err := service.CreateChannel(ctx, ownerID, CreateChannelParams{Title: "News", Username: &username})
+	require.NoError(t, err)
+	assert.Equal(t, db.ChatTypeChannel, channel.Type)
+	channelID := ulid.MustParse(channel.ID)
+
+	resolved, err := service.ResolveChannel(ctx, "companynews")
+	require.NoError(t, err)
+	assert.Equal(t, channel.ID, resolved.ID)
+
+	// Channels are not capped by MaxGroupMembers.
+	var subscribers []ulid.ULID
+	for i := 0; i < 4; i++ {
+		userID := createUser(t, ctx, fmt.Sprintf("sub%d", i))
+		_, err := service.Subscribe(ctx, userID, channelID)
+		require.NoError(t, err)
+		subscribers = append(subscribers, userID)
+	}
+
+	_, err = service.Authorize(ctx, subscribers[0], channelID, PermSendMessages)
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+	_, err = service.ListMembers(ctx, subscribers[0], channelID, "", 10)
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+
+	// A device that does not follow the channel yet starts at its seq.
+	diff, err := service.updates.GetDifference(ctx, subscribers[0], 0, nil, 10)
+	require.NoError(t, err)
+	require.Len(t, diff.Channels, 1)
+	assert.Equal(t, channel.ID, diff.Channels[0].ChatID)
+	assert.Empty(t, diff.Channels[0].Updates)
+	since, channelSeq := diff.Seq, diff.Channels[0].Seq
+
+	post, _, err := setupMessagesService(service).SendMessage(ctx, ownerID, channelID, SendMessageParams{
+		SenderDeviceID: createDevice(t, ctx, ownerID),
+		ContentType:    "text",
+		Ciphertext:     testCiphertext(),
+	})
+	require.NoError(t, err)
+	postID := ulid.MustParse(post.ID)
+
+	// The post is stored once in the channel's stream, not in each
+	// subscriber's log.
+	diff, err = service.updates.GetDifference(ctx, subscribers[0], since, map[ulid.ULID]int64{channelID: channelSeq}, 10)
+	require.NoError(t, err)
+	assert.Empty(t, diff.Updates)
+	require.Len(t, diff.Channels, 1)
+	require.Len(t, diff.Channels[0].Updates, 1)
+	assert.Equal(t, UpdateNewMessage, diff.Channels[0].Updates[0].Type)
+	assert.Equal(t, channel.ID, diff.Channels[0].Updates[0].ChatID)
+	assert.Equal(t, channelSeq+1, diff.Channels[0].Seq)
+
+	_, err = service.updates.GetDifference(ctx, subscribers[0], since, map[ulid.ULID]int64{channelID: channelSeq + 2}, 10)
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+	for _, userID := range []ulid.ULID{subscribers[0], subscribers[1], subscribers[0]} {
+		_, err := service.RecordViews(ctx, userID, channelID, []ulid.ULID{postID})
+		require.NoError(t, err)
+	}
+	// IDs that are not posts of the channel are not counted.
+	views, err := service.RecordViews(ctx, ownerID, channelID, []ulid.ULID{postID, postID, ulid.Make()})
+	require.NoError(t, err)
+	require.Len(t, views, 1)
+	assert.Equal(t, post.ID, views[0].MessageID)
+	assert.EqualValues(t, 3, views[0].Views)
+
+	// A second channel cannot take the same username in another case.
+	other := "companyNEWS"
+	_, err = service.CreateChannel(ctx, ownerID, CreateChannelParams{Title: "Copy", Username: &other})
+	requireBusinessCode(t, err, "USERNAME_TAKEN")
+}
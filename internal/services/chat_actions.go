@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// chatActionAudienceTTL is how long a chat's members, and a user's right to
+// send actions there, are reused before they are looked up again. Clients
+// repeat actions every few seconds, so this keeps them off the database.
+// Membership changes made through this instance drop the cached entries
+// right away.
+const chatActionAudienceTTL = 30 * time.Second
+
+type senderKey struct {
+	userID ulid.ULID
+	chatID ulid.ULID
+}
+
+type sender struct {
+	// audience is false when nobody sees the user's actions, e.g. in a
+	// direct chat with a block.
+	audience bool
+	expires  time.Time
+}
+
+type chatAudience struct {
+	members []ulid.ULID
+	// threads are the threads of the chat known to exist.
+	threads map[ulid.ULID]bool
+	expires time.Time
+}
+
+// ChatActionsService decides who sees the ephemeral chat actions, such as
+// typing, that a user sends. The actions themselves are never stored.
+type ChatActionsService struct {
+	chats   *ChatsService
+	threads repos.ThreadRepository
+
+	mu      sync.Mutex
+	senders map[senderKey]sender
+	members map[ulid.ULID]*chatAudience
+}
+
+// NewChatActionsService creates a new ChatActionsService.
+func NewChatActionsService(chats *ChatsService, threads repos.ThreadRepository) *ChatActionsService {
+	s := &ChatActionsService{
+		chats:   chats,
+		threads: threads,
+		senders: make(map[senderKey]sender),
+		members: make(map[ulid.ULID]*chatAudience),
+	}
+	chats.OnMembersChanged(s.forgetChat)
+	return s
+}
+
+// ChatActionAudience returns the members of a chat who see the user's chat
+// actions. The list includes the user, who the caller skips, and is shared,
+// so it must not be modified. The user must be able to send messages in the
+// chat, and threadID, if set, must be a thread of the chat. Direct chats
+// with a block have no audience. Chats of any size have one; the caller
+// delivers the actions to the members who are online.
+func (s *ChatActionsService) ChatActionAudience(ctx context.Context, userID, chatID ulid.ULID, threadID *ulid.ULID) ([]ulid.ULID, error) {
+	now := time.Now()
+	ok, err := s.authorizeSender(ctx, userID, chatID, now)
+	if err != nil || !ok {
+		return nil, err
+	}
+	audience, err := s.chatAudience(ctx, chatID, now)
+	if err != nil {
+		return nil, err
+	}
+	if threadID != nil {
+		if err := s.checkThread(ctx, chatID, *threadID, audience); err != nil {
+			return nil, err
+		}
+	}
+	return audience.members, nil
+}
+
+// authorizeSender reports whether anyone sees the user's actions in the
+// chat, failing when the user may not send messages there.
+func (s *ChatActionsService) authorizeSender(ctx context.Context, userID, chatID ulid.ULID, now time.Time) (bool, error) {
+	key := senderKey{userID: userID, chatID: chatID}
+	s.mu.Lock()
+	cached, ok := s.senders[key]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.audience, nil
+	}
+
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermSendMessages)
+	if err != nil {
+		return false, err
+	}
+	audience := true
+	if peerID, ok := directPeer(access.Chat, userID); ok {
+		blocked, err := s.chats.contacts.IsBlocked(ctx, userID, peerID)
+		if err != nil {
+			return false, err
+		}
+		audience = !blocked
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range s.senders {
+		if !now.Before(v.expires) {
+			delete(s.senders, k)
+		}
+	}
+	s.senders[key] = sender{audience: audience, expires: now.Add(chatActionAudienceTTL)}
+	return audience, nil
+}
+
+// chatAudience returns the members of the chat.
+func (s *ChatActionsService) chatAudience(ctx context.Context, chatID ulid.ULID, now time.Time) (*chatAudience, error) {
+	s.mu.Lock()
+	cached, ok := s.members[chatID]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached, nil
+	}
+
+	ids, err := s.chats.repo.ListChatMemberIDs(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	audience := &chatAudience{members: make([]ulid.ULID, len(ids)), threads: make(map[ulid.ULID]bool), expires: now.Add(chatActionAudienceTTL)}
+	for i, id := range ids {
+		audience.members[i] = ulid.MustParse(id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range s.members {
+		if !now.Before(v.expires) {
+			delete(s.members, k)
+		}
+	}
+	s.members[chatID] = audience
+	return audience, nil
+}
+
+// checkThread verifies that threadID is a thread of the chat.
+func (s *ChatActionsService) checkThread(ctx context.Context, chatID, threadID ulid.ULID, audience *chatAudience) error {
+	s.mu.Lock()
+	known := audience.threads[threadID]
+	s.mu.Unlock()
+	if known {
+		return nil
+	}
+	_, err := s.threads.GetThread(ctx, chatID, threadID)
+	if errors.Is(err, repos.ErrNotFound) {
+		return threadNotFound()
+	}
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	audience.threads[threadID] = true
+	s.mu.Unlock()
+	return nil
+}
+
+// forgetChat drops what is cached about a chat whose members changed.
+func (s *ChatActionsService) forgetChat(chatID ulid.ULID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.members, chatID)
+	for k := range s.senders {
+		if k.chatID == chatID {
+			delete(s.senders, k)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/messenger/backend/internal/storage/postgres"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatActionAudience_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	threads := postgres.NewPostgresThreadRepository(testQueries)
+	service := NewChatActionsService(chats, threads)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	memberID := createUser(t, ctx, "member")
+	strangerID := createUser(t, ctx, "stranger")
+	group, err := chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{memberID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+
+	members, err := service.ChatActionAudience(ctx, ownerID, groupID, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []ulid.ULID{ownerID, memberID}, members)
+	_, err = service.ChatActionAudience(ctx, strangerID, groupID, nil)
+	requireBusinessCode(t, err, "CHAT_NOT_FOUND")
+
+	// The audience is cached, so a member added behind the service's back
+	// shows up later, while one added through it shows up at once.
+	_, err = testPool.Exec(ctx, `INSERT INTO chat_members (chat_id, user_id) VALUES ($1, $2)`, group.ID, strangerID.String())
+	require.NoError(t, err)
+	members, err = service.ChatActionAudience(ctx, ownerID, groupID, nil)
+	require.NoError(t, err)
+	assert.Len(t, members, 2)
+	lateID := createUser(t, ctx, "late")
+	require.NoError(t, chats.AddMember(ctx, ownerID, groupID, lateID))
+	members, err = service.ChatActionAudience(ctx, ownerID, groupID, nil)
+	require.NoError(t, err)
+	assert.Contains(t, members, lateID)
+
+	// A removed member neither sees nor sends actions any more.
+	require.NoError(t, chats.RemoveMember(ctx, ownerID, groupID, memberID))
+	members, err = service.ChatActionAudience(ctx, ownerID, groupID, nil)
+	require.NoError(t, err)
+	assert.NotContains(t, members, memberID)
+	_, err = service.ChatActionAudience(ctx, memberID, groupID, nil)
+	requireBusinessCode(t, err, "CHAT_NOT_FOUND")
+
+	// Threads must belong to the chat.
+	unknown := ulid.Make()
+	_, err = service.ChatActionAudience(ctx, ownerID, groupID, &unknown)
+	requireBusinessCode(t, err, "THREAD_NOT_FOUND")
+
+	// Nobody sees the actions of a blocked user in a direct chat.
+	direct, _, err := chats.GetOrCreateDirectChat(ctx, ownerID, memberID)
+	require.NoError(t, err)
+	_, err = testPool.Exec(ctx, `INSERT INTO blocks (owner_id, target_user_id) VALUES ($1, $2)`, memberID.String(), ownerID.String())
+	require.NoError(t, err)
+	members, err = service.ChatActionAudience(ctx, ownerID, ulid.MustParse(direct.ID), nil)
+	require.NoError(t, err)
+	assert.Empty(t, members)
+}
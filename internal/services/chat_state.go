@@ -0,0 +1,337 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	maxPinnedChats       = 5
+	maxChatFolders       = 10
+	maxFolderTitleLength = 32
+	// maxFolderChats caps the explicitly included and excluded chats of a folder.
+	maxFolderChats = 100
+)
+
+// Folder rules. Include rules select chats by category; exclude rules drop
+// chats by the user's state.
+var (
+	folderIncludeRules = map[string]bool{"contacts": true, "non_contacts": true, "groups": true, "channels": true}
+	folderExcludeRules = map[string]bool{"muted": true, "read": true, "archived": true}
+)
+
+// ChatStateParams changes the user's own state of a chat. Nil fields are
+// left unchanged.
+type ChatStateParams struct {
+	Pinned       *bool
+	Archived     *bool
+	MarkedUnread *bool
+	Mute         *MuteParams
+	// MentionNotifications sets whether mentions notify while the chat is
+	// muted.
+	MentionNotifications *bool
+}
+
+// MuteParams mutes a chat until a time or forever. Neither set unmutes.
+type MuteParams struct {
+	Until   *time.Time
+	Forever bool
+}
+
+// ChatFolderParams describes a chat folder.
+type ChatFolderParams struct {
+	Title           string
+	IncludeRules    []string
+	ExcludeRules    []string
+	IncludedChatIDs []ulid.ULID
+	ExcludedChatIDs []ulid.ULID
+}
+
+// UpdateChatState changes the user's pinned, archived, muted and unread
+// state of a chat and syncs it to the user's devices.
+func (s *ChatsService) UpdateChatState(ctx context.Context, userID, chatID ulid.ULID, params ChatStateParams) (*db.ChatUserState, error) {
+	if _, err := s.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return nil, err
+	}
+
+	var update repos.ChatStateUpdate
+	if params.Archived != nil {
+		update.Archived = sql.NullBool{Bool: *params.Archived, Valid: true}
+	}
+	if params.MarkedUnread != nil {
+		update.MarkedUnread = sql.NullBool{Bool: *params.MarkedUnread, Valid: true}
+	}
+	if m := params.Mute; m != nil {
+		update.SetMute = true
+		switch {
+		case m.Forever:
+			update.MuteForever = true
+		case m.Until != nil:
+			if !m.Until.After(time.Now()) {
+				return nil, &BusinessError{Code: string(utils.ErrValidation), Message: "Mute end must be in the future"}
+			}
+			update.MutedUntil = sql.NullTime{Time: *m.Until, Valid: true}
+		}
+	}
+	if params.MentionNotifications != nil {
+		update.MentionNotifications = sql.NullBool{Bool: *params.MentionNotifications, Valid: true}
+	}
+	var state *db.ChatUserState
+	err := s.updates.InTx(ctx, func(ctx context.Context) error {
+		if update.Archived.Valid || update.MarkedUnread.Valid || update.SetMute || update.MentionNotifications.Valid {
+			if _, err := s.states.UpdateChatState(ctx, userID, chatID, update); err != nil {
+				return err
+			}
+		}
+		if params.Pinned != nil {
+			if err := s.setPinned(ctx, userID, chatID, *params.Pinned); err != nil {
+				return err
+			}
+		}
+		var err error
+		if state, err = s.getChatState(ctx, userID, chatID); err != nil {
+			return err
+		}
+		return s.updates.Publish(ctx, userID, UpdateChatState, state)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// ReorderPinnedChats sets the order of the user's pinned chats. chatIDs must
+// list exactly the currently pinned chats.
+func (s *ChatsService) ReorderPinnedChats(ctx context.Context, userID ulid.ULID, chatIDs []ulid.ULID) error {
+	current, err := s.states.ListPinnedChatIDs(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !sameIDSet(current, chatIDs) {
+		return &BusinessError{Code: string(utils.ErrValidation), Message: "The order must list every pinned chat exactly once"}
+	}
+	return s.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.states.ReorderPinnedChats(ctx, userID, chatIDs); err != nil {
+			return err
+		}
+		return s.updates.Publish(ctx, userID, UpdatePinnedOrder, map[string][]string{"chat_ids": ulidStrings(chatIDs)})
+	})
+}
+
+// ListChatFolders returns the user's folders in order.
+func (s *ChatsService) ListChatFolders(ctx context.Context, userID ulid.ULID) ([]db.ChatFolder, error) {
+	return s.states.ListChatFolders(ctx, userID)
+}
+
+// CreateChatFolder adds a folder after the user's existing ones.
+func (s *ChatsService) CreateChatFolder(ctx context.Context, userID ulid.ULID, params ChatFolderParams) (*db.ChatFolder, error) {
+	folderParams, err := validateFolder(params)
+	if err != nil {
+		return nil, err
+	}
+	var folder *db.ChatFolder
+	err = s.updates.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if folder, err = s.states.CreateChatFolder(ctx, userID, folderParams, maxChatFolders); err != nil {
+			return err
+		}
+		return s.updates.Publish(ctx, userID, UpdateFolder, folder)
+	})
+	if errors.Is(err, repos.ErrLimitExceeded) {
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("You can have at most %d folders", maxChatFolders)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+// UpdateChatFolder replaces a folder's title and rules.
+func (s *ChatsService) UpdateChatFolder(ctx context.Context, userID, folderID ulid.ULID, params ChatFolderParams) (*db.ChatFolder, error) {
+	folderParams, err := validateFolder(params)
+	if err != nil {
+		return nil, err
+	}
+	var folder *db.ChatFolder
+	err = s.updates.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if folder, err = s.states.UpdateChatFolder(ctx, userID, folderID, folderParams); err != nil {
+			return err
+		}
+		return s.updates.Publish(ctx, userID, UpdateFolder, folder)
+	})
+	if errors.Is(err, repos.ErrNotFound) {
+		return nil, folderNotFound()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+// DeleteChatFolder removes a folder. Its chats are not affected.
+func (s *ChatsService) DeleteChatFolder(ctx context.Context, userID, folderID ulid.ULID) error {
+	err := s.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.states.DeleteChatFolder(ctx, userID, folderID); err != nil {
+			return err
+		}
+		return s.updates.Publish(ctx, userID, UpdateFolderGone, map[string]string{"folder_id": folderID.String()})
+	})
+	if errors.Is(err, repos.ErrNotFound) {
+		return folderNotFound()
+	}
+	return err
+}
+
+// ReorderChatFolders sets the order of the user's folders. folderIDs must
+// list every folder exactly once.
+func (s *ChatsService) ReorderChatFolders(ctx context.Context, userID ulid.ULID, folderIDs []ulid.ULID) error {
+	folders, err := s.states.ListChatFolders(ctx, userID)
+	if err != nil {
+		return err
+	}
+	current := make([]string, len(folders))
+	for i, f := range folders {
+		current[i] = f.ID
+	}
+	if !sameIDSet(current, folderIDs) {
+		return &BusinessError{Code: string(utils.ErrValidation), Message: "The order must list every folder exactly once"}
+	}
+	return s.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.states.ReorderChatFolders(ctx, userID, folderIDs); err != nil {
+			return err
+		}
+		return s.updates.Publish(ctx, userID, UpdateFolderOrder, map[string][]string{"folder_ids": ulidStrings(folderIDs)})
+	})
+}
+
+// setPinned pins a chat at the end of the pinned list or unpins it. Pinning
+// an already pinned chat keeps its position.
+func (s *ChatsService) setPinned(ctx context.Context, userID, chatID ulid.ULID, pinned bool) error {
+	state, err := s.getChatState(ctx, userID, chatID)
+	if err != nil {
+		return err
+	}
+	if state.PinnedRank.Valid == pinned {
+		return nil
+	}
+	if !pinned {
+		_, err := s.states.UnpinChat(ctx, userID, chatID)
+		return err
+	}
+	_, err = s.states.PinChat(ctx, userID, chatID, maxPinnedChats)
+	if errors.Is(err, repos.ErrLimitExceeded) {
+		return &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("You can pin at most %d chats", maxPinnedChats)}
+	}
+	return err
+}
+
+// getChatState returns the user's state of a chat, with the defaults when
+// the user never changed it.
+func (s *ChatsService) getChatState(ctx context.Context, userID, chatID ulid.ULID) (*db.ChatUserState, error) {
+	state, err := s.states.GetChatState(ctx, userID, chatID)
+	if errors.Is(err, repos.ErrNotFound) {
+		return &db.ChatUserState{UserID: userID.String(), ChatID: chatID.String()}, nil
+	}
+	return state, err
+}
+
+// validateFolder normalizes a folder's title and rules.
+func validateFolder(params ChatFolderParams) (repos.ChatFolderParams, error) {
+	title := strings.TrimSpace(params.Title)
+	if title == "" || utf8.RuneCountInString(title) > maxFolderTitleLength {
+		return repos.ChatFolderParams{}, &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("Folder title must be 1-%d characters", maxFolderTitleLength)}
+	}
+
+	include, err := folderRules(params.IncludeRules, folderIncludeRules)
+	if err != nil {
+		return repos.ChatFolderParams{}, err
+	}
+	exclude, err := folderRules(params.ExcludeRules, folderExcludeRules)
+	if err != nil {
+		return repos.ChatFolderParams{}, err
+	}
+	if len(params.IncludedChatIDs) > maxFolderChats || len(params.ExcludedChatIDs) > maxFolderChats {
+		return repos.ChatFolderParams{}, &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("A folder can list at most %d chats", maxFolderChats)}
+	}
+	if len(include) == 0 && len(params.IncludedChatIDs) == 0 {
+		return repos.ChatFolderParams{}, &BusinessError{Code: string(utils.ErrValidation), Message: "A folder needs at least one include rule or chat"}
+	}
+
+	return repos.ChatFolderParams{
+		Title:           title,
+		IncludeRules:    include,
+		ExcludeRules:    exclude,
+		IncludedChatIDs: dedupeIDs(params.IncludedChatIDs),
+		ExcludedChatIDs: dedupeIDs(params.ExcludedChatIDs),
+	}, nil
+}
+
+// folderRules checks rules against the allowed set and removes duplicates.
+func folderRules(rules []string, allowed map[string]bool) ([]string, error) {
+	seen := make(map[string]bool, len(rules))
+	out := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		if !allowed[rule] {
+			return nil, &BusinessError{Code: string(utils.ErrValidation), Message: "Unknown folder rule: " + rule}
+		}
+		if seen[rule] {
+			continue
+		}
+		seen[rule] = true
+		out = append(out, rule)
+	}
+	return out, nil
+}
+
+func dedupeIDs(ids []ulid.ULID) []ulid.ULID {
+	seen := make(map[ulid.ULID]bool, len(ids))
+	out := make([]ulid.ULID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// sameIDSet reports whether ids lists each of current exactly once.
+func sameIDSet(current []string, ids []ulid.ULID) bool {
+	if len(current) != len(ids) {
+		return false
+	}
+	want := make(map[string]bool, len(current))
+	for _, id := range current {
+		want[id] = true
+	}
+	for _, id := range ids {
+		if !want[id.String()] {
+			return false
+		}
+		delete(want, id.String())
+	}
+	return true
+}
+
+func ulidStrings(ids []ulid.ULID) []string {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = id.String()
+	}
+	return out
+}
+
+func folderNotFound() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrNotFound), Message: "Folder not found"}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingNotifier struct {
+	mu      sync.Mutex
+	updates map[ulid.ULID][]Update
+}
+
+func (n *recordingNotifier) NotifyUser(userID ulid.ULID, update Update) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.updates == nil {
+		n.updates = make(map[ulid.ULID][]Update)
+	}
+	n.updates[userID] = append(n.updates[userID], update)
+}
+
+func TestChatState_PinArchiveAndSync_RealDB(t *testing.T) {
+	service := setupChatsService()
+	notifier := &recordingNotifier{}
+	service.updates.notifier = notifier
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	userID := createUser(t, ctx, "user1")
+	var chatIDs []ulid.ULID
+	for _, name := range []string{"user2", "user3", "user4"} {
+		chat, _, err := service.GetOrCreateDirectChat(ctx, userID, createUser(t, ctx, name))
+		require.NoError(t, err)
+		chatIDs = append(chatIDs, ulid.MustParse(chat.ID))
+	}
+
+	yes := true
+	_, err := service.UpdateChatState(ctx, userID, chatIDs[0], ChatStateParams{Pinned: &yes})
+	require.NoError(t, err)
+	_, err = service.UpdateChatState(ctx, userID, chatIDs[1], ChatStateParams{Pinned: &yes})
+	require.NoError(t, err)
+	state, err := service.UpdateChatState(ctx, userID, chatIDs[2], ChatStateParams{Archived: &yes, Mute: &MuteParams{Forever: true}})
+	require.NoError(t, err)
+	assert.True(t, state.Archived)
+	assert.True(t, state.MutedUntil.Valid)
+
+	require.NoError(t, service.ReorderPinnedChats(ctx, userID, []ulid.ULID{chatIDs[1], chatIDs[0]}))
+	err = service.ReorderPinnedChats(ctx, userID, []ulid.ULID{chatIDs[1]})
+	requireBusinessCode(t, err, string(utils.ErrValidation))
+
+	page, err := service.ListChats(ctx, userID, ChatListOptions{}, "", 10)
+	require.NoError(t, err)
+	require.Len(t, page.Pinned, 2)
+	assert.Equal(t, chatIDs[1].String(), page.Pinned[0].ID)
+	assert.Equal(t, chatIDs[0].String(), page.Pinned[1].ID)
+	assert.Empty(t, page.Items, "pinned and archived chats are not repeated in the main list")
+
+	archive, err := service.ListChats(ctx, userID, ChatListOptions{Archived: true}, "", 10)
+	require.NoError(t, err)
+	require.Len(t, archive.Items, 1)
+	assert.Equal(t, chatIDs[2].String(), archive.Items[0].ID)
+
+	// Every change reached the user's devices in seq order.
+	updates := notifier.updates[userID]
+	require.Len(t, updates, 4)
+	for i, update := range updates {
+		assert.Equal(t, int64(i+1), update.Seq)
+	}
+	assert.Equal(t, UpdatePinnedOrder, updates[3].Type)
+}
+
+func TestChatFolders_FilterChatList_RealDB(t *testing.T) {
+	service := setupChatsService()
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	userID := createUser(t, ctx, "user1")
+	peerID := createUser(t, ctx, "user2")
+	direct, _, err := service.GetOrCreateDirectChat(ctx, userID, peerID)
+	require.NoError(t, err)
+	group, err := service.CreateGroup(ctx, userID, CreateGroupParams{Title: "Team"})
+	require.NoError(t, err)
+	muted, err := service.CreateGroup(ctx, userID, CreateGroupParams{Title: "Noise"})
+	require.NoError(t, err)
+	_, err = service.UpdateChatState(ctx, userID, ulid.MustParse(muted.ID), ChatStateParams{Mute: &MuteParams{Forever: true}})
+	require.NoError(t, err)
+
+	_, err = service.CreateChatFolder(ctx, userID, ChatFolderParams{Title: "Bad", IncludeRules: []string{"everything"}})
+	requireBusinessCode(t, err, string(utils.ErrValidation))
+
+	folder, err := service.CreateChatFolder(ctx, userID, ChatFolderParams{
+		Title:           "Groups",
+		IncludeRules:    []string{"groups"},
+		ExcludeRules:    []string{"muted"},
+		IncludedChatIDs: []ulid.ULID{ulid.MustParse(direct.ID)},
+	})
+	require.NoError(t, err)
+
+	folderID := ulid.MustParse(folder.ID)
+	page, err := service.ListChats(ctx, userID, ChatListOptions{FolderID: &folderID}, "", 10)
+	require.NoError(t, err)
+	var ids []string
+	for _, item := range page.Items {
+		ids = append(ids, item.ID)
+	}
+	assert.ElementsMatch(t, []string{direct.ID, group.ID}, ids)
+
+	require.NoError(t, service.DeleteChatFolder(ctx, userID, folderID))
+	_, err = service.ListChats(ctx, userID, ChatListOptions{FolderID: &folderID}, "", 10)
+	requireBusinessCode(t, err, string(utils.ErrNotFound))
+}
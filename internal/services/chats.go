@@ -1 +1,803 @@
 package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/messenger/backend/internal/config"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	defaultChatPageSize = 50
+	maxChatPageSize     = 100
+	maxChatTitleLength  = 128
+	// chatListTopics is how many recently active topics a forum carries in
+	// the chat list.
+	chatListTopics = 3
+)
+
+// ChatsService provides business logic for chats and chat membership.
+type ChatsService struct {
+	repo     repos.ChatRepository
+	contacts repos.ContactRepository
+	states   repos.ChatStateRepository
+	updates  *UpdatesService
+	audit    repos.AuditRepository
+	limits   config.LimitsConfig
+	// memberHooks run after a chat's members or their permissions change.
+	memberHooks []func(chatID ulid.ULID)
+}
+
+// NewChatsService creates a new ChatsService.
+func NewChatsService(repo repos.ChatRepository, contacts repos.ContactRepository, states repos.ChatStateRepository, updates *UpdatesService, audit repos.AuditRepository, limits config.LimitsConfig) *ChatsService {
+	return &ChatsService{repo: repo, contacts: contacts, states: states, updates: updates, audit: audit, limits: limits}
+}
+
+// ChatListItem is a chat in the user's chat list. Forum chats carry their
+// most recently active topics with the user's unread counts.
+type ChatListItem struct {
+	db.ListUserChatsRow
+	Topics []db.ListTopicSummariesRow `json:"topics,omitempty"`
+	// Drafts are the user's drafts in the chat and its threads.
+	Drafts []db.Draft `json:"drafts,omitempty"`
+}
+
+// ChatPage is one page of the user's chat list. The first page of the main
+// list and of a folder carries the pinned chats separately, in the user's
+// order; they are left out of Items.
+type ChatPage struct {
+	Pinned     []ChatListItem `json:"pinned,omitempty"`
+	Items      []ChatListItem `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// ChatListOptions selects which of the user's chats ListChats returns. With
+// a folder the folder's rules apply; otherwise Archived picks the archive
+// or the main list.
+type ChatListOptions struct {
+	FolderID *ulid.ULID
+	Archived bool
+}
+
+// MemberPage is one page of a chat's member list.
+type MemberPage struct {
+	Items      []db.ListChatMembersRow `json:"items"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
+}
+
+// Membership changes reported in ChatMemberUpdate.
+const (
+	MemberJoined      = "joined"
+	MemberLeft        = "left"
+	MemberRemoved     = "removed"
+	MemberBanned      = "banned"
+	MemberRoleChanged = "role_changed"
+)
+
+// ChatMemberUpdate syncs a change to a chat's membership to devices.
+type ChatMemberUpdate struct {
+	ChatID string            `json:"chat_id"`
+	UserID string            `json:"user_id"`
+	Status string            `json:"status"`
+	Role   db.ChatMemberRole `json:"role,omitempty"`
+}
+
+// CreateGroupParams describes a new group chat.
+type CreateGroupParams struct {
+	Title     string
+	PhotoURL  *string
+	MemberIDs []ulid.ULID
+}
+
+// GetOrCreateDirectChat returns the single direct chat between two users,
+// creating it on first use. The boolean result reports whether the chat was
+// created by this call. A chat with oneself is the saved-messages chat.
+func (s *ChatsService) GetOrCreateDirectChat(ctx context.Context, userID, peerID ulid.ULID) (*db.Chat, bool, error) {
+	if userID == peerID {
+		return s.GetOrCreateSavedMessages(ctx, userID)
+	}
+
+	if _, err := s.repo.GetUser(ctx, peerID); err != nil {
+		if errors.Is(err, repos.ErrNotFound) {
+			return nil, false, &BusinessError{Code: string(utils.ErrUserNotFound), Message: "User not found"}
+		}
+		return nil, false, err
+	}
+
+	blocked, err := s.contacts.IsBlocked(ctx, userID, peerID)
+	if err != nil {
+		return nil, false, err
+	}
+	if blocked {
+		return nil, false, &BusinessError{Code: string(utils.ErrPeerBlocked), Message: "Chat is unavailable because one of the users has blocked the other"}
+	}
+
+	return s.getOrCreateUniqueChat(ctx, db.ChatTypeDirect, directChatKey(userID, peerID), userID, []ulid.ULID{userID, peerID})
+}
+
+// GetOrCreateSavedMessages returns the user's "saved messages" self-chat,
+// creating it on first use.
+func (s *ChatsService) GetOrCreateSavedMessages(ctx context.Context, userID ulid.ULID) (*db.Chat, bool, error) {
+	return s.getOrCreateUniqueChat(ctx, db.ChatTypeSaved, userID.String(), userID, []ulid.ULID{userID})
+}
+
+func (s *ChatsService) getOrCreateUniqueChat(ctx context.Context, chatType db.ChatType, key string, createdBy ulid.ULID, members []ulid.ULID) (*db.Chat, bool, error) {
+	chat, err := s.repo.GetChatByDirectKey(ctx, key)
+	if err == nil {
+		return chat, false, nil
+	}
+	if !errors.Is(err, repos.ErrNotFound) {
+		return nil, false, err
+	}
+
+	err = s.updates.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if chat, err = s.repo.CreateUniqueChat(ctx, chatType, key, createdBy, members, DefaultPermissions(chatType)); err != nil {
+			return err
+		}
+		return s.publishNewChat(ctx, chat)
+	})
+	if errors.Is(err, repos.ErrAlreadyExists) {
+		// Lost a race with a concurrent request creating the same chat.
+		chat, err = s.repo.GetChatByDirectKey(ctx, key)
+		return chat, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return chat, true, nil
+}
+
+// GetChat returns a chat the user is a member of.
+func (s *ChatsService) GetChat(ctx context.Context, userID, chatID ulid.ULID) (*db.Chat, error) {
+	access, err := s.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return nil, err
+	}
+	return access.Chat, nil
+}
+
+// ListChats returns the user's chats ordered by last activity, newest first.
+func (s *ChatsService) ListChats(ctx context.Context, userID ulid.ULID, opts ChatListOptions, cursor string, limit int) (*ChatPage, error) {
+	limit = clampPageSize(limit, defaultChatPageSize, maxChatPageSize)
+
+	var after *repos.ChatCursor
+	if cursor != "" {
+		parts, err := utils.DecodeCursor(cursor, 2)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		micros, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		after = &repos.ChatCursor{LastActivityAt: time.UnixMicro(micros), ChatID: parts[1]}
+	}
+
+	var filter repos.ChatListFilter
+	switch {
+	case opts.FolderID != nil:
+		folder, err := s.states.GetChatFolder(ctx, userID, *opts.FolderID)
+		if errors.Is(err, repos.ErrNotFound) {
+			return nil, folderNotFound()
+		}
+		if err != nil {
+			return nil, err
+		}
+		filter.Folder = folder
+	default:
+		filter.Archived = sql.NullBool{Bool: opts.Archived, Valid: true}
+	}
+	// The archive is a plain list; elsewhere pinned chats come first.
+	separatePinned := !opts.Archived || opts.FolderID != nil
+	if separatePinned {
+		filter.Pinned = sql.NullBool{Bool: false, Valid: true}
+	}
+
+	// Fetch one extra row to learn whether another page exists.
+	rows, err := s.repo.ListUserChats(ctx, userID, filter, after, int32(limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ChatPage{}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[limit-1]
+		page.NextCursor = utils.EncodeCursor(strconv.FormatInt(last.LastActivityAt.Time.UnixMicro(), 10), last.ID)
+	}
+	page.Items = make([]ChatListItem, len(rows))
+	for i, row := range rows {
+		page.Items[i].ListUserChatsRow = row
+	}
+
+	if separatePinned && cursor == "" {
+		filter.Pinned.Bool = true
+		pinned, err := s.repo.ListUserChats(ctx, userID, filter, nil, maxPinnedChats)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(pinned, func(i, j int) bool { return pinned[i].PinnedRank.Int32 < pinned[j].PinnedRank.Int32 })
+		page.Pinned = make([]ChatListItem, len(pinned))
+		for i, row := range pinned {
+			page.Pinned[i].ListUserChatsRow = row
+		}
+	}
+
+	if err := s.attachTopics(ctx, userID, page.Pinned); err != nil {
+		return nil, err
+	}
+	if err := s.attachTopics(ctx, userID, page.Items); err != nil {
+		return nil, err
+	}
+	if err := s.attachDrafts(ctx, userID, page.Pinned); err != nil {
+		return nil, err
+	}
+	if err := s.attachDrafts(ctx, userID, page.Items); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// attachTopics fills in the most recently active topics of forum chats.
+func (s *ChatsService) attachTopics(ctx context.Context, userID ulid.ULID, items []ChatListItem) error {
+	var forums []string
+	for _, item := range items {
+		if item.IsForum {
+			forums = append(forums, item.ID)
+		}
+	}
+	if len(forums) == 0 {
+		return nil
+	}
+	topics, err := s.repo.ListTopicSummaries(ctx, userID, forums, chatListTopics)
+	if err != nil {
+		return err
+	}
+	byChat := make(map[string][]db.ListTopicSummariesRow, len(forums))
+	for _, topic := range topics {
+		byChat[topic.ChatID] = append(byChat[topic.ChatID], topic)
+	}
+	for i := range items {
+		items[i].Topics = byChat[items[i].ID]
+	}
+	return nil
+}
+
+// attachDrafts fills in the user's drafts.
+func (s *ChatsService) attachDrafts(ctx context.Context, userID ulid.ULID, items []ChatListItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	chatIDs := make([]string, len(items))
+	for i, item := range items {
+		chatIDs[i] = item.ID
+	}
+	drafts, err := s.repo.ListChatDrafts(ctx, userID, chatIDs)
+	if err != nil {
+		return err
+	}
+	byChat := make(map[string][]db.Draft, len(drafts))
+	for _, draft := range drafts {
+		byChat[draft.ChatID] = append(byChat[draft.ChatID], draft)
+	}
+	for i := range items {
+		items[i].Drafts = byChat[items[i].ID]
+	}
+	return nil
+}
+
+// CreateGroup creates a group owned by ownerID with the given initial members.
+func (s *ChatsService) CreateGroup(ctx context.Context, ownerID ulid.ULID, params CreateGroupParams) (*db.Chat, error) {
+	title := strings.TrimSpace(params.Title)
+	if title == "" || utf8.RuneCountInString(title) > maxChatTitleLength {
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("Title must be 1-%d characters", maxChatTitleLength)}
+	}
+
+	seen := map[ulid.ULID]bool{ownerID: true}
+	members := make([]ulid.ULID, 0, len(params.MemberIDs))
+	for _, id := range params.MemberIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		members = append(members, id)
+	}
+	if len(members)+1 > s.maxGroupMembers() {
+		return nil, memberLimitReached(s.maxGroupMembers())
+	}
+
+	for _, id := range members {
+		if err := s.checkCanAdd(ctx, ownerID, id); err != nil {
+			return nil, err
+		}
+	}
+
+	var photoURL sql.NullString
+	if params.PhotoURL != nil {
+		photoURL = sql.NullString{String: *params.PhotoURL, Valid: true}
+	}
+	var chat *db.Chat
+	err := s.updates.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if chat, err = s.repo.CreateGroupChat(ctx, ownerID, title, photoURL, members, DefaultPermissions(db.ChatTypeGroup)); err != nil {
+			return err
+		}
+		return s.publishNewChat(ctx, chat)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chat, nil
+}
+
+// UpdateGroupInfo changes a group's title and/or photo and tells the members.
+// Nil fields are left unchanged.
+func (s *ChatsService) UpdateGroupInfo(ctx context.Context, actorID, chatID ulid.ULID, title, photoURL *string) (*db.Chat, error) {
+	access, err := s.authorizeGroup(ctx, actorID, chatID, PermChangeInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	var newTitle, newPhoto sql.NullString
+	before, after := map[string]any{}, map[string]any{}
+	if title != nil {
+		t := strings.TrimSpace(*title)
+		if t == "" || utf8.RuneCountInString(t) > maxChatTitleLength {
+			return nil, &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("Title must be 1-%d characters", maxChatTitleLength)}
+		}
+		newTitle = sql.NullString{String: t, Valid: true}
+		before["title"], after["title"] = access.Chat.Title.String, t
+	}
+	if photoURL != nil {
+		newPhoto = sql.NullString{String: *photoURL, Valid: true}
+		before["photo_url"], after["photo_url"] = nullableString(access.Chat.PhotoUrl.String), nullableString(*photoURL)
+	}
+
+	if len(after) == 0 {
+		return access.Chat, nil
+	}
+	var chat *db.Chat
+	err = s.updates.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if chat, err = s.repo.UpdateChatInfo(ctx, chatID, newTitle, newPhoto); err != nil {
+			return err
+		}
+		if err := s.updates.PublishToChat(ctx, chatID, UpdateChatInfo, chat); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, chatID, actorID, AuditInfoChanged, auditTarget{}, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chat, nil
+}
+
+// AddMember adds a user to a group. It requires the add-members permission.
+func (s *ChatsService) AddMember(ctx context.Context, actorID, chatID, userID ulid.ULID) error {
+	access, err := s.authorizeGroup(ctx, actorID, chatID, PermAddMembers)
+	if err != nil {
+		return err
+	}
+	if err := s.checkCanAdd(ctx, actorID, userID); err != nil {
+		return err
+	}
+	return s.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.admitMember(ctx, access.Chat, userID, actorID); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, chatID, actorID, AuditMemberAdded, targetUser(userID), nil, nil)
+	})
+}
+
+// RemoveMember removes another member from a group. Owners can remove anyone;
+// admins can only remove regular members.
+func (s *ChatsService) RemoveMember(ctx context.Context, actorID, chatID, userID ulid.ULID) error {
+	if actorID == userID {
+		return s.LeaveGroup(ctx, userID, chatID)
+	}
+
+	chat, actor, err := s.getGroupMember(ctx, chatID, actorID)
+	if err != nil {
+		return err
+	}
+	target, err := s.getTargetMember(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+	if !outranks(actor.Role, target.Role) {
+		return forbiddenRole("You cannot remove this member")
+	}
+
+	return s.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.removeMember(ctx, chat, userID, MemberRemoved); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, chatID, actorID, AuditMemberRemoved, targetUser(userID), map[string]any{"role": target.Role}, nil)
+	})
+}
+
+// LeaveGroup removes the user from a group. The owner must transfer
+// ownership first unless they are the last member, in which case the group
+// is deleted.
+func (s *ChatsService) LeaveGroup(ctx context.Context, userID, chatID ulid.ULID) error {
+	chat, member, err := s.getGroupMember(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+	if member.Role == db.ChatMemberRoleOwner {
+		if chat.MemberCount > 1 {
+			return forbiddenRole("Transfer ownership before leaving the group")
+		}
+		return s.updates.InTx(ctx, func(ctx context.Context) error {
+			if err := s.repo.DeleteChat(ctx, chatID); err != nil {
+				return err
+			}
+			return s.updates.Publish(ctx, userID, UpdateChatMember, memberUpdate(chat, userID, MemberLeft, ""))
+		})
+	}
+	return s.removeMember(ctx, chat, userID, MemberLeft)
+}
+
+// SetMemberRole promotes a member to admin or demotes an admin. Only the
+// owner can change roles; ownership changes go through TransferOwnership.
+func (s *ChatsService) SetMemberRole(ctx context.Context, actorID, chatID, userID ulid.ULID, role db.ChatMemberRole) error {
+	if role != db.ChatMemberRoleAdmin && role != db.ChatMemberRoleMember {
+		return &BusinessError{Code: string(utils.ErrValidation), Message: "Role must be admin or member"}
+	}
+
+	chat, actor, err := s.getGroupMember(ctx, chatID, actorID)
+	if err != nil {
+		return err
+	}
+	if actor.Role != db.ChatMemberRoleOwner {
+		return forbiddenRole("Only the owner can change member roles")
+	}
+	if actorID == userID {
+		return forbiddenRole("The owner's role can only change through an ownership transfer")
+	}
+	target, err := s.getTargetMember(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.UpdateChatMemberRole(ctx, chatID, userID, role); err != nil {
+			return err
+		}
+		if err := s.publishMemberChange(ctx, chat, userID, MemberRoleChanged, role); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, chatID, actorID, AuditRoleChanged, targetUser(userID),
+			map[string]any{"role": target.Role}, map[string]any{"role": role})
+	})
+	if errors.Is(err, repos.ErrNotFound) {
+		return memberNotFound()
+	}
+	return err
+}
+
+// TransferOwnership hands the group over to another member. The previous
+// owner stays in the group as an admin.
+func (s *ChatsService) TransferOwnership(ctx context.Context, ownerID, chatID, newOwnerID ulid.ULID) error {
+	chat, actor, err := s.getGroupMember(ctx, chatID, ownerID)
+	if err != nil {
+		return err
+	}
+	if actor.Role != db.ChatMemberRoleOwner {
+		return forbiddenRole("Only the owner can transfer ownership")
+	}
+	if ownerID == newOwnerID {
+		return &BusinessError{Code: string(utils.ErrValidation), Message: "You already own this group"}
+	}
+
+	err = s.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.TransferChatOwnership(ctx, chatID, ownerID, newOwnerID); err != nil {
+			return err
+		}
+		if err := s.publishMemberChange(ctx, chat, newOwnerID, MemberRoleChanged, db.ChatMemberRoleOwner); err != nil {
+			return err
+		}
+		if err := s.publishMemberChange(ctx, chat, ownerID, MemberRoleChanged, db.ChatMemberRoleAdmin); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, chatID, ownerID, AuditOwnershipTransferred, targetUser(newOwnerID),
+			map[string]any{"owner_id": ownerID.String()}, map[string]any{"owner_id": newOwnerID.String()})
+	})
+	if errors.Is(err, repos.ErrNotFound) {
+		return memberNotFound()
+	}
+	return err
+}
+
+// ListMembers returns a page of a chat's members in join order. Channel
+// subscribers cannot see each other, so only channel admins may list them.
+func (s *ChatsService) ListMembers(ctx context.Context, userID, chatID ulid.ULID, cursor string, limit int) (*MemberPage, error) {
+	access, err := s.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return nil, err
+	}
+	if access.Chat.Type == db.ChatTypeChannel && access.Member.Role == db.ChatMemberRoleMember {
+		return nil, forbiddenRole("Only admins can list channel subscribers")
+	}
+	limit = clampPageSize(limit, defaultChatPageSize, maxChatPageSize)
+
+	var after *repos.MemberCursor
+	if cursor != "" {
+		parts, err := utils.DecodeCursor(cursor, 2)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		micros, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		after = &repos.MemberCursor{JoinedAt: time.UnixMicro(micros), UserID: parts[1]}
+	}
+
+	rows, err := s.repo.ListChatMembers(ctx, chatID, after, int32(limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	page := &MemberPage{Items: rows}
+	if len(rows) > limit {
+		page.Items = rows[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = utils.EncodeCursor(strconv.FormatInt(last.JoinedAt.Time.UnixMicro(), 10), last.UserID)
+	}
+	return page, nil
+}
+
+// getMember returns the user's membership, hiding chats they are not part of.
+func (s *ChatsService) getMember(ctx context.Context, chatID, userID ulid.ULID) (*db.ChatMember, error) {
+	member, err := s.repo.GetChatMember(ctx, chatID, userID)
+	if errors.Is(err, repos.ErrNotFound) {
+		return nil, chatNotFound()
+	}
+	return member, err
+}
+
+// getTargetMember returns the membership of the user an operation acts on.
+func (s *ChatsService) getTargetMember(ctx context.Context, chatID, userID ulid.ULID) (*db.ChatMember, error) {
+	member, err := s.repo.GetChatMember(ctx, chatID, userID)
+	if errors.Is(err, repos.ErrNotFound) {
+		return nil, memberNotFound()
+	}
+	return member, err
+}
+
+// getGroupMember is getMember for operations that only exist in groups and
+// channels.
+func (s *ChatsService) getGroupMember(ctx context.Context, chatID, userID ulid.ULID) (*db.Chat, *db.ChatMember, error) {
+	member, err := s.getMember(ctx, chatID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	chat, err := s.repo.GetChat(ctx, chatID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !hasMemberRoles(chat.Type) {
+		return nil, nil, notInGroup()
+	}
+	return chat, member, nil
+}
+
+// authorizeGroup is Authorize for operations that only exist in groups and
+// channels.
+func (s *ChatsService) authorizeGroup(ctx context.Context, userID, chatID ulid.ULID, want Permission) (*ChatAccess, error) {
+	access, err := s.Authorize(ctx, userID, chatID, want)
+	if err != nil {
+		return nil, err
+	}
+	if !hasMemberRoles(access.Chat.Type) {
+		return nil, notInGroup()
+	}
+	return access, nil
+}
+
+// admitMember adds a regular member to a group or channel unless they are
+// banned, already a member, or the chat is full.
+func (s *ChatsService) admitMember(ctx context.Context, chat *db.Chat, userID, invitedBy ulid.ULID) error {
+	chatID := ulid.MustParse(chat.ID)
+	banned, err := s.repo.IsBannedFromChat(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+	if banned {
+		return userBanned()
+	}
+	exists, err := s.repo.IsChatMember(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return memberExists()
+	}
+
+	limit := s.maxMembers(chat)
+	err = s.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.AddChatMember(ctx, chatID, userID, db.ChatMemberRoleMember, invitedBy, limit); err != nil {
+			return err
+		}
+		return s.publishMemberChange(ctx, chat, userID, MemberJoined, db.ChatMemberRoleMember)
+	})
+	switch {
+	case errors.Is(err, repos.ErrAlreadyExists):
+		return memberExists()
+	case errors.Is(err, repos.ErrLimitExceeded):
+		return memberLimitReached(limit)
+	}
+	return err
+}
+
+// removeMember drops userID from the chat; status says why.
+func (s *ChatsService) removeMember(ctx context.Context, chat *db.Chat, userID ulid.ULID, status string) error {
+	err := s.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.RemoveChatMember(ctx, ulid.MustParse(chat.ID), userID); err != nil {
+			return err
+		}
+		return s.publishMemberChange(ctx, chat, userID, status, "")
+	})
+	if errors.Is(err, repos.ErrNotFound) {
+		return memberNotFound()
+	}
+	return err
+}
+
+// publishNewChat tells the members of a newly created chat about it.
+func (s *ChatsService) publishNewChat(ctx context.Context, chat *db.Chat) error {
+	return s.updates.PublishToChat(ctx, ulid.MustParse(chat.ID), UpdateNewChat, chat)
+}
+
+// publishMemberChange syncs a membership change. Group members all see it;
+// channel subscribers cannot see each other, so only the affected user is
+// told. A user who is no longer a member is told directly.
+func (s *ChatsService) publishMemberChange(ctx context.Context, chat *db.Chat, userID ulid.ULID, status string, role db.ChatMemberRole) error {
+	s.membersChanged(ctx, ulid.MustParse(chat.ID))
+	update := memberUpdate(chat, userID, status, role)
+	if chat.Type != db.ChatTypeChannel {
+		if err := s.updates.PublishToChat(ctx, ulid.MustParse(chat.ID), UpdateChatMember, update); err != nil {
+			return err
+		}
+		if status != MemberLeft && status != MemberRemoved && status != MemberBanned {
+			return nil
+		}
+	}
+	return s.updates.Publish(ctx, userID, UpdateChatMember, update)
+}
+
+// OnMembersChanged registers fn to run after a chat's members or their
+// permissions change. It must be called before the service is used.
+func (s *ChatsService) OnMembersChanged(fn func(chatID ulid.ULID)) {
+	s.memberHooks = append(s.memberHooks, fn)
+}
+
+// membersChanged runs the OnMembersChanged hooks once the change commits.
+func (s *ChatsService) membersChanged(ctx context.Context, chatID ulid.ULID) {
+	repos.AfterCommit(ctx, func() {
+		for _, fn := range s.memberHooks {
+			fn(chatID)
+		}
+	})
+}
+
+// checkCanAdd verifies that userID exists and has no block with actorID.
+func (s *ChatsService) checkCanAdd(ctx context.Context, actorID, userID ulid.ULID) error {
+	if _, err := s.repo.GetUser(ctx, userID); err != nil {
+		if errors.Is(err, repos.ErrNotFound) {
+			return &BusinessError{Code: string(utils.ErrUserNotFound), Message: "User not found"}
+		}
+		return err
+	}
+	blocked, err := s.contacts.IsBlocked(ctx, actorID, userID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return &BusinessError{Code: string(utils.ErrPeerBlocked), Message: "You cannot add a user you have a block with"}
+	}
+	return nil
+}
+
+func (s *ChatsService) maxGroupMembers() int {
+	if s.limits.MaxGroupMembers <= 0 {
+		return math.MaxInt32
+	}
+	return s.limits.MaxGroupMembers
+}
+
+var roleRank = map[db.ChatMemberRole]int{
+	db.ChatMemberRoleMember: 0,
+	db.ChatMemberRoleAdmin:  1,
+	db.ChatMemberRoleOwner:  2,
+}
+
+// maxMembers returns the member limit of a chat. Channels have no limit.
+func (s *ChatsService) maxMembers(chat *db.Chat) int {
+	if chat.Type == db.ChatTypeChannel {
+		return math.MaxInt32
+	}
+	return s.maxGroupMembers()
+}
+
+// hasMemberRoles reports whether a chat type has owners, admins and managed
+// membership.
+func hasMemberRoles(t db.ChatType) bool {
+	return t == db.ChatTypeGroup || t == db.ChatTypeChannel
+}
+
+// outranks reports whether a member with role a may manage a member with role b.
+func outranks(a, b db.ChatMemberRole) bool {
+	return a != db.ChatMemberRoleMember && roleRank[a] > roleRank[b]
+}
+
+// memberUpdate describes a change to userID's membership of chat.
+func memberUpdate(chat *db.Chat, userID ulid.ULID, status string, role db.ChatMemberRole) ChatMemberUpdate {
+	return ChatMemberUpdate{ChatID: chat.ID, UserID: userID.String(), Status: status, Role: role}
+}
+
+// directChatKey returns the same key for both participants regardless of who
+// opens the chat.
+func directChatKey(a, b ulid.ULID) string {
+	if a.Compare(b) > 0 {
+		a, b = b, a
+	}
+	return a.String() + ":" + b.String()
+}
+
+func clampPageSize(limit, def, max int) int {
+	if limit <= 0 {
+		return def
+	}
+	if limit > max {
+		return max
+	}
+	return limit
+}
+
+func chatNotFound() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrChatNotFound), Message: "Chat not found"}
+}
+
+func memberExists() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrMemberExists), Message: "User is already a member of this chat"}
+}
+
+func memberNotFound() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrMemberNotFound), Message: "Member not found"}
+}
+
+func forbiddenRole(message string) *BusinessError {
+	return &BusinessError{Code: string(utils.ErrForbiddenRole), Message: message}
+}
+
+func memberLimitReached(max int) *BusinessError {
+	return &BusinessError{Code: string(utils.ErrMemberLimitReached), Message: fmt.Sprintf("A group can have at most %d members", max)}
+}
+
+func userBanned() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrUserBanned), Message: "User is banned from this chat"}
+}
+
+func notInGroup() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrValidation), Message: "This operation is only available in groups and channels"}
+}
+
+func invalidCursor() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrValidation), Message: "Invalid cursor"}
+}
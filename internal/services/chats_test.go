@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/messenger/backend/internal/config"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/storage/postgres"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupChatsService() *ChatsService {
+	return NewChatsService(
+		postgres.NewPostgresChatRepository(testQueries),
+		postgres.NewPostgresContactRepository(testQueries),
+		postgres.NewPostgresChatStateRepository(testQueries),
+		NewUpdatesService(postgres.NewPostgresUpdateRepository(testQueries), testDB, nil),
+		postgres.NewPostgresAuditRepository(testQueries),
+		config.LimitsConfig{MaxGroupMembers: 3},
+	)
+}
+
+func TestGetOrCreateDirectChat_ReturnsSameChat_RealDB(t *testing.T) {
+	service := setupChatsService()
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	user1ID := createUser(t, ctx, "user1")
+	user2ID := createUser(t, ctx, "user2")
+
+	chat, created, err := service.GetOrCreateDirectChat(ctx, user1ID, user2ID)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, db.ChatTypeDirect, chat.Type)
+
+	// The peer opening the chat gets the same one back.
+	again, created, err := service.GetOrCreateDirectChat(ctx, user2ID, user1ID)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, chat.ID, again.ID)
+}
+
+func TestGetOrCreateDirectChat_Blocked_RealDB(t *testing.T) {
+	service := setupChatsService()
+	contacts := setupRealService()
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	user1ID := createUser(t, ctx, "user1")
+	user2ID := createUser(t, ctx, "user2")
+	require.NoError(t, contacts.BlockPeer(ctx, user2ID, user1ID))
+
+	_, _, err := service.GetOrCreateDirectChat(ctx, user1ID, user2ID)
+	require.Error(t, err)
+	bizErr, ok := err.(*BusinessError)
+	require.True(t, ok)
+	assert.Equal(t, "PEER_BLOCKED", bizErr.Code)
+}
+
+func TestGetOrCreateDirectChat_WithSelfIsSavedMessages_RealDB(t *testing.T) {
+	service := setupChatsService()
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	user1ID := createUser(t, ctx, "user1")
+
+	saved, created, err := service.GetOrCreateSavedMessages(ctx, user1ID)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, db.ChatTypeSaved, saved.Type)
+
+	self, created, err := service.GetOrCreateDirectChat(ctx, user1ID, user1ID)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, saved.ID, self.ID)
+}
+
+func TestListChats_Paginates_RealDB(t *testing.T) {
+	service := setupChatsService()
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	user1ID := createUser(t, ctx, "user1")
+	for _, name := range []string{"user2", "user3", "user4"} {
+		peerID := createUser(t, ctx, name)
+		_, _, err := service.GetOrCreateDirectChat(ctx, user1ID, peerID)
+		require.NoError(t, err)
+	}
+
+	first, err := service.ListChats(ctx, user1ID, ChatListOptions{}, "", 2)
+	require.NoError(t, err)
+	require.Len(t, first.Items, 2)
+	require.NotEmpty(t, first.NextCursor)
+	assert.True(t, first.Items[0].PeerID.Valid)
+
+	second, err := service.ListChats(ctx, user1ID, ChatListOptions{}, first.NextCursor, 2)
+	require.NoError(t, err)
+	require.Len(t, second.Items, 1)
+	assert.Empty(t, second.NextCursor)
+	assert.NotEqual(t, first.Items[1].ID, second.Items[0].ID)
+}
+
+func requireBusinessCode(t *testing.T, err error, code string) {
+	t.Helper()
+	require.Error(t, err)
+	bizErr, ok := err.(*BusinessError)
+	require.True(t, ok, "expected BusinessError, got %v", err)
+	assert.Equal(t, code, bizErr.Code)
+}
+
+func TestCreateGroup_EnforcesMemberLimit_RealDB(t *testing.T) {
+	service := setupChatsService()
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	user2ID := createUser(t, ctx, "user2")
+	user3ID := createUser(t, ctx, "user3")
+	user4ID := createUser(t, ctx, "user4")
+
+	_, err := service.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Too big", MemberIDs: []ulid.ULID{user2ID, user3ID, user4ID}})
+	requireBusinessCode(t, err, "MEMBER_LIMIT_REACHED")
+
+	group, err := service.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{user2ID, user3ID}})
+	require.NoError(t, err)
+	assert.Equal(t, db.ChatTypeGroup, group.Type)
+	assert.EqualValues(t, 3, group.MemberCount)
+
+	groupID := ulid.MustParse(group.ID)
+	err = service.AddMember(ctx, ownerID, groupID, user4ID)
+	requireBusinessCode(t, err, "MEMBER_LIMIT_REACHED")
+
+	err = service.AddMember(ctx, ownerID, groupID, user2ID)
+	requireBusinessCode(t, err, "MEMBER_EXISTS")
+}
+
+func TestGroupRoles_RealDB(t *testing.T) {
+	service := setupChatsService()
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	adminID := createUser(t, ctx, "admin")
+	memberID := createUser(t, ctx, "member")
+
+	group, err := service.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{adminID, memberID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+
+	// Members cannot manage others.
+	err = service.RemoveMember(ctx, memberID, groupID, adminID)
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+
+	require.NoError(t, service.SetMemberRole(ctx, ownerID, groupID, adminID, db.ChatMemberRoleAdmin))
+
+	// Admins cannot remove the owner, but can remove members.
+	err = service.RemoveMember(ctx, adminID, groupID, ownerID)
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+	require.NoError(t, service.RemoveMember(ctx, adminID, groupID, memberID))
+
+	err = service.RemoveMember(ctx, adminID, groupID, memberID)
+	requireBusinessCode(t, err, "MEMBER_NOT_FOUND")
+
+	// The owner has to hand over the group before leaving.
+	err = service.LeaveGroup(ctx, ownerID, groupID)
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+	require.NoError(t, service.TransferOwnership(ctx, ownerID, groupID, adminID))
+	require.NoError(t, service.LeaveGroup(ctx, ownerID, groupID))
+
+	page, err := service.ListMembers(ctx, adminID, groupID, "", 10)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, db.ChatMemberRoleOwner, page.Items[0].Role)
+}
+
+func TestPermission_JSONRoundTrip(t *testing.T) {
+	perms := PermSendMessages | PermPinMessages
+
+	data, err := json.Marshal(perms)
+	require.NoError(t, err)
+	assert.JSONEq(t, `["pin_messages","send_messages"]`, string(data))
+
+	var decoded Permission
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, perms, decoded)
+
+	assert.Error(t, json.Unmarshal([]byte(`["fly"]`), &decoded))
+}
+
+func TestGroupPermissions_RealDB(t *testing.T) {
+	service := setupChatsService()
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	adminID := createUser(t, ctx, "admin")
+	memberID := createUser(t, ctx, "member")
+
+	group, err := service.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{adminID, memberID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+
+	// Members can add others by default but cannot change the group info.
+	_, err = service.Authorize(ctx, memberID, groupID, PermAddMembers)
+	require.NoError(t, err)
+	title := "Renamed"
+	_, err = service.UpdateGroupInfo(ctx, memberID, groupID, &title, nil)
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+
+	// Restricting a single member overrides the role default.
+	readOnly := PermNone
+	require.NoError(t, service.SetMemberPermissions(ctx, ownerID, groupID, memberID, MemberPermissionsParams{Permissions: &readOnly}))
+	_, err = service.Authorize(ctx, memberID, groupID, PermSendMessages)
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+
+	// Admins get a custom title but cannot grant more than they have.
+	require.NoError(t, service.SetMemberRole(ctx, ownerID, groupID, adminID, db.ChatMemberRoleAdmin))
+	limited := PermSendMessages | PermPinMessages
+	customTitle := "Moderator"
+	require.NoError(t, service.SetMemberPermissions(ctx, ownerID, groupID, adminID, MemberPermissionsParams{Permissions: &limited, CustomTitle: &customTitle}))
+
+	all := PermAll
+	err = service.SetMemberPermissions(ctx, adminID, groupID, memberID, MemberPermissionsParams{Permissions: &all})
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+
+	view, err := service.GetPermissions(ctx, adminID, groupID)
+	require.NoError(t, err)
+	assert.Equal(t, limited, view.Effective)
+	assert.Equal(t, "Moderator", view.CustomTitle)
+}
@@ -3,10 +3,14 @@ package services
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/messenger/backend/internal/db"
 	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/messenger/backend/pkg/vcard"
 	"github.com/oklog/ulid/v2"
 )
 
@@ -141,10 +145,217 @@ func (s *ContactsService) ListContacts(ctx context.Context, ownerID ulid.ULID, s
 	return s.repo.ListContacts(ctx, ownerID, state)
 }
 
+// maxImportEntries caps the number of vCards accepted in a single import.
+const maxImportEntries = 5000
+
+// ImportStatus describes what happened to a matched vCard entry.
+type ImportStatus string
+
+const (
+	ImportStatusMatched        ImportStatus = "matched"
+	ImportStatusRequestSent    ImportStatus = "request_sent"
+	ImportStatusRequestPending ImportStatus = "request_pending"
+	ImportStatusAlreadyContact ImportStatus = "already_contact"
+	ImportStatusBlocked        ImportStatus = "blocked"
+	ImportStatusSelf           ImportStatus = "self"
+
+	// ImportStatusRequestDeclined means an earlier request to the user was
+	// declined. Only one request can be sent to a user, so none is sent.
+	ImportStatusRequestDeclined ImportStatus = "request_declined"
+)
+
+// ImportedContact is one vCard entry together with the user it resolved to, if any.
+type ImportedContact struct {
+	Name     string       `json:"name"`
+	Phones   []string     `json:"phones,omitempty"`
+	Emails   []string     `json:"emails,omitempty"`
+	UserID   string       `json:"user_id,omitempty"`
+	Username string       `json:"username,omitempty"`
+	Status   ImportStatus `json:"status,omitempty"`
+}
+
+// ContactImportResult splits imported entries into registered users and
+// people who can be invited. Entries without a usable phone or email are
+// only counted.
+type ContactImportResult struct {
+	Matched   []ImportedContact `json:"matched"`
+	Invitable []ImportedContact `json:"invitable"`
+	Skipped   int               `json:"skipped"`
+}
+
+// ImportContacts resolves vCard entries to registered users by normalized
+// phone number and email. When sendRequests is set, a contact request is
+// created for every matched user that is not already a contact.
+func (s *ContactsService) ImportContacts(ctx context.Context, userID ulid.ULID, cards []vcard.Card, sendRequests bool) (*ContactImportResult, error) {
+	if len(cards) > maxImportEntries {
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("At most %d contacts can be imported at once", maxImportEntries)}
+	}
+
+	entries := make([]ImportedContact, 0, len(cards))
+	var phones, emails []string
+	for _, card := range cards {
+		entry := ImportedContact{Name: card.FormattedName}
+		for _, raw := range card.Phones {
+			if phone, ok := utils.NormalizePhone(raw); ok {
+				entry.Phones = append(entry.Phones, phone)
+			}
+		}
+		for _, raw := range card.Emails {
+			if email, ok := utils.NormalizeEmail(raw); ok {
+				entry.Emails = append(entry.Emails, email)
+			}
+		}
+		phones = append(phones, entry.Phones...)
+		emails = append(emails, entry.Emails...)
+		entries = append(entries, entry)
+	}
+
+	users, err := s.repo.FindUsersByContactInfo(ctx, phones, emails)
+	if err != nil {
+		return nil, err
+	}
+	byPhone := make(map[string]*db.User, len(users))
+	byEmail := make(map[string]*db.User, len(users))
+	for i := range users {
+		if users[i].Phone.Valid {
+			byPhone[users[i].Phone.String] = &users[i]
+		}
+		if users[i].Email.Valid {
+			byEmail[strings.ToLower(users[i].Email.String)] = &users[i]
+		}
+	}
+
+	existing, err := s.repo.ListContacts(ctx, userID, db.ContactStateAccepted)
+	if err != nil {
+		return nil, err
+	}
+	contacts := make(map[string]bool, len(existing))
+	for _, c := range existing {
+		contacts[c.PeerID] = true
+	}
+
+	result := &ContactImportResult{Matched: []ImportedContact{}, Invitable: []ImportedContact{}}
+	// The same user may appear in several cards; only one request is sent.
+	resolved := make(map[string]ImportStatus)
+	for _, entry := range entries {
+		if len(entry.Phones) == 0 && len(entry.Emails) == 0 {
+			result.Skipped++
+			continue
+		}
+
+		user := matchUser(entry, byPhone, byEmail)
+		if user == nil {
+			result.Invitable = append(result.Invitable, entry)
+			continue
+		}
+
+		entry.UserID = user.ID
+		entry.Username = user.Username
+		status, seen := resolved[user.ID]
+		if !seen {
+			status, err = s.importMatchedUser(ctx, userID, user, contacts[user.ID], sendRequests)
+			if err != nil {
+				return nil, err
+			}
+			resolved[user.ID] = status
+		}
+		entry.Status = status
+		result.Matched = append(result.Matched, entry)
+	}
+
+	return result, nil
+}
+
+func matchUser(entry ImportedContact, byPhone, byEmail map[string]*db.User) *db.User {
+	for _, phone := range entry.Phones {
+		if user, ok := byPhone[phone]; ok {
+			return user
+		}
+	}
+	for _, email := range entry.Emails {
+		if user, ok := byEmail[email]; ok {
+			return user
+		}
+	}
+	return nil
+}
+
+func (s *ContactsService) importMatchedUser(ctx context.Context, userID ulid.ULID, user *db.User, isContact, sendRequests bool) (ImportStatus, error) {
+	peerID, err := ulid.Parse(user.ID)
+	if err != nil {
+		return "", fmt.Errorf("internal: failed to parse peer ID: %w", err)
+	}
+
+	switch {
+	case peerID == userID:
+		return ImportStatusSelf, nil
+	case isContact:
+		return ImportStatusAlreadyContact, nil
+	case !sendRequests:
+		return ImportStatusMatched, nil
+	}
+
+	blocked, err := s.repo.IsBlocked(ctx, peerID, userID)
+	if err != nil {
+		return "", err
+	}
+	if blocked {
+		return ImportStatusBlocked, nil
+	}
+
+	state, err := s.repo.GetContactRequestState(ctx, userID, peerID)
+	switch {
+	case errors.Is(err, repos.ErrNotFound):
+	case err != nil:
+		return "", err
+	case state == db.ContactRequestStatePending:
+		return ImportStatusRequestPending, nil
+	case state == db.ContactRequestStateAccepted:
+		// The user removed the contact after it was accepted.
+		return ImportStatusMatched, nil
+	case state == db.ContactRequestStateBlocked:
+		return ImportStatusBlocked, nil
+	default:
+		return ImportStatusRequestDeclined, nil
+	}
+
+	if _, err := s.repo.CreateContactRequest(ctx, userID, peerID, sql.NullString{}); err != nil {
+		return "", err
+	}
+	return ImportStatusRequestSent, nil
+}
+
+// ExportContacts returns the user's accepted contacts as vCards. The alias the
+// user gave a contact takes precedence over the contact's username.
+func (s *ContactsService) ExportContacts(ctx context.Context, ownerID ulid.ULID) ([]vcard.Card, error) {
+	rows, err := s.repo.ListContactsWithUsers(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	cards := make([]vcard.Card, 0, len(rows))
+	for _, row := range rows {
+		card := vcard.Card{FormattedName: row.Username, Nickname: row.Username}
+		if row.Alias.Valid && row.Alias.String != "" {
+			card.FormattedName = row.Alias.String
+		}
+		if row.Phone.Valid {
+			card.Phones = []string{row.Phone.String}
+		}
+		if row.Email.Valid {
+			card.Emails = []string{row.Email.String}
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
 // BusinessError for custom error types
 type BusinessError struct {
 	Code    string
 	Message string
+	// Details carries machine-readable context such as retry_after.
+	Details map[string]any
 }
 
 func (e *BusinessError) Error() string {
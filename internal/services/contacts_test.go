@@ -5,8 +5,10 @@ import (
 	"database/sql"
 	"testing"
 
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/messenger/backend/internal/db"
 	"github.com/messenger/backend/internal/storage/postgres"
+	"github.com/messenger/backend/pkg/vcard"
 	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -148,3 +150,53 @@ func TestBlockPeer_RealDB(t *testing.T) {
 	require.True(t, ok)
 	assert.Equal(t, "YOU_ARE_BLOCKED", bizErr.Code)
 }
+
+func TestImportContacts_MatchesAndSendsRequests_RealDB(t *testing.T) {
+	service := setupRealService()
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	user1ID := createUser(t, ctx, "user1")
+	peer, err := testQueries.CreateUser(ctx, db.CreateUserParams{
+		ID:             ulid.Make().String(),
+		Username:       "user2",
+		Phone:          pgtype.Text{String: "+12125552368", Valid: true},
+		HashedPassword: "password",
+	})
+	require.NoError(t, err)
+
+	cards := []vcard.Card{
+		{FormattedName: "Peer", Phones: []string{"+1 (212) 555-2368"}},
+		{FormattedName: "Stranger", Emails: []string{"Stranger@Example.com"}},
+		{FormattedName: "No identifiers"},
+	}
+
+	result, err := service.ImportContacts(ctx, user1ID, cards, true)
+	require.NoError(t, err)
+
+	require.Len(t, result.Matched, 1)
+	assert.Equal(t, peer.ID, result.Matched[0].UserID)
+	assert.Equal(t, ImportStatusRequestSent, result.Matched[0].Status)
+	require.Len(t, result.Invitable, 1)
+	assert.Equal(t, []string{"stranger@example.com"}, result.Invitable[0].Emails)
+	assert.Equal(t, 1, result.Skipped)
+
+	state, err := testQueries.GetContactRequestState(ctx, db.GetContactRequestStateParams{
+		FromUserID: user1ID.String(),
+		ToUserID:   peer.ID,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, db.ContactRequestStatePending, state)
+
+	// A second import reports the pending request instead of failing on the duplicate.
+	result, err = service.ImportContacts(ctx, user1ID, cards[:1], true)
+	require.NoError(t, err)
+	assert.Equal(t, ImportStatusRequestPending, result.Matched[0].Status)
+
+	// Once declined, the request is reported as such rather than as pending.
+	_, err = testPool.Exec(ctx, `UPDATE contact_requests SET state = 'rejected' WHERE from_user_id = $1`, user1ID.String())
+	require.NoError(t, err)
+	result, err = service.ImportContacts(ctx, user1ID, cards[:1], true)
+	require.NoError(t, err)
+	assert.Equal(t, ImportStatusRequestDeclined, result.Matched[0].Status)
+}
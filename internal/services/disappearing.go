@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	minMessageTTL = time.Minute
+	maxMessageTTL = 366 * 24 * time.Hour
+	// reaperBatchSize is how many expired messages one reaper run deletes.
+	reaperBatchSize = 500
+)
+
+// ServiceMessageTTLChanged is the service message action for a change of
+// a chat's disappearing message timer.
+const ServiceMessageTTLChanged = "message_ttl_changed"
+
+// serviceContentType is the content type of messages written by the server.
+const serviceContentType = "service"
+
+// TTLChangedService is the body of the service message announcing a new
+// disappearing message timer. Zero means the timer was turned off.
+type TTLChangedService struct {
+	Action     string `json:"action"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+}
+
+// SetMessageTTL sets a chat's disappearing message timer; zero turns it
+// off. Messages sent afterwards disappear that long after they are sent.
+// Both users of a direct chat can set it, in groups and channels members
+// who can change the chat info. The change is announced with a service
+// message in the chat.
+func (s *MessagesService) SetMessageTTL(ctx context.Context, userID, chatID ulid.ULID, ttl time.Duration) error {
+	if ttl != 0 {
+		if err := validateMessageTTL(ttl); err != nil {
+			return err
+		}
+	}
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return err
+	}
+	if access.Chat.Type != db.ChatTypeDirect && !access.Permissions.Has(PermChangeInfo) {
+		return forbiddenRole(fmt.Sprintf("Missing permission: %v", PermChangeInfo.Names()))
+	}
+	before := messageTTL(access.Chat, 0)
+	if before == ttl {
+		return nil
+	}
+	service, err := json.Marshal(TTLChangedService{Action: ServiceMessageTTLChanged, TTLSeconds: int64(ttl / time.Second)})
+	if err != nil {
+		return err
+	}
+	return s.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.SetChatMessageTTL(ctx, chatID, ttl); err != nil {
+			return err
+		}
+		msg, err := s.repo.CreateMessage(ctx, repos.NewMessage{
+			ChatID:      chatID,
+			SenderID:    userID,
+			ContentType: serviceContentType,
+			Service:     service,
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.updates.PublishToChat(ctx, chatID, UpdateNewMessage, newMessageUpdate(msg)); err != nil {
+			return err
+		}
+		if access.Chat.Type == db.ChatTypeDirect {
+			return nil
+		}
+		return s.chats.recordAudit(ctx, chatID, userID, AuditMessageTTLChanged, auditTarget{},
+			map[string]int64{"ttl_seconds": int64(before / time.Second)}, map[string]int64{"ttl_seconds": int64(ttl / time.Second)})
+	})
+}
+
+// ViewMessage records that the user viewed a view-once message. In a
+// direct chat the message is then deleted for both users; in groups it is
+// deleted for the viewer and disappears for good once nobody can see it.
+func (s *MessagesService) ViewMessage(ctx context.Context, userID, chatID, messageID ulid.ULID) error {
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return err
+	}
+	msg, err := s.visibleMessage(ctx, userID, chatID, messageID)
+	if err != nil {
+		return err
+	}
+	if msg == nil {
+		return messageNotFound()
+	}
+	if !msg.ViewOnce {
+		return &BusinessError{Code: string(utils.ErrValidation), Message: "This message is not a view-once message"}
+	}
+	if msg.SenderID.String == userID.String() {
+		return nil
+	}
+
+	ids := []ulid.ULID{messageID}
+	if access.Chat.Type == db.ChatTypeDirect {
+		return s.updates.InTx(ctx, func(ctx context.Context) error {
+			deleted, err := s.repo.DeleteMessagesForEveryone(ctx, chatID, ids)
+			if err != nil || len(deleted) == 0 {
+				return err
+			}
+			return s.publishDeleted(ctx, chatID, deleted)
+		})
+	}
+	err = s.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.HideMessages(ctx, chatID, userID, ids); err != nil {
+			return err
+		}
+		update := MessagesDeletedUpdate{ChatID: chatID.String(), MessageIDs: []string{msg.ID}}
+		return s.updates.Publish(ctx, userID, UpdateMessagesDeleted, update)
+	})
+	if err != nil {
+		return err
+	}
+	s.purgeUnreferenced(ctx, chatID, msg.Seq, ids)
+	return nil
+}
+
+// ReapExpiredMessages deletes the ciphertext of messages whose time ran out
+// and tells the chats' members. The messages stay until every update is
+// stored, so a failure leaves the whole batch for the next run. It returns
+// how many messages were deleted.
+//
+// TODO: Also delete the expired messages' attachment objects once
+// attachments are stored; see package storage.
+func (s *MessagesService) ReapExpiredMessages(ctx context.Context) (int, error) {
+	var n int
+	err := s.updates.InTx(ctx, func(ctx context.Context) error {
+		expired, err := s.repo.ExpireMessages(ctx, reaperBatchSize)
+		if err != nil {
+			return err
+		}
+		byChat := make(map[string][]string)
+		for _, row := range expired {
+			byChat[row.ChatID] = append(byChat[row.ChatID], row.ID)
+		}
+		for chatID, ids := range byChat {
+			update := MessagesDeletedUpdate{ChatID: chatID, MessageIDs: ids, ForEveryone: true}
+			if err := s.updates.PublishToChat(ctx, ulid.MustParse(chatID), UpdateMessagesDeleted, update); err != nil {
+				return err
+			}
+		}
+		n = len(expired)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// RunReaper deletes expired messages every interval until ctx is
+// cancelled. Any number of instances can run it side by side.
+func (s *MessagesService) RunReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := s.ReapExpiredMessages(ctx); err != nil {
+				log.Printf("messages: reaping expired messages failed: %v", err)
+			} else if n > 0 {
+				log.Printf("messages: deleted %d expired messages", n)
+			}
+		}
+	}
+}
+
+// messageTTL returns how long a new message lives: its own TTL, else the
+// chat's timer. Zero means forever.
+func messageTTL(chat *db.Chat, ttl time.Duration) time.Duration {
+	if ttl == 0 && chat.MessageTtlSeconds.Valid {
+		ttl = time.Duration(chat.MessageTtlSeconds.Int32) * time.Second
+	}
+	return ttl
+}
+
+func validateMessageTTL(ttl time.Duration) error {
+	if ttl < minMessageTTL || ttl > maxMessageTTL || ttl%time.Second != 0 {
+		return &BusinessError{
+			Code:    string(utils.ErrValidation),
+			Message: fmt.Sprintf("Disappearing message timers must be whole seconds between %s and %s", minMessageTTL, maxMessageTTL),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisappearingMessages_TimerViewOnceAndReaper_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	service := setupMessagesService(chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	memberID := createUser(t, ctx, "member")
+	ownerDevice := createDevice(t, ctx, ownerID)
+	memberDevice := createDevice(t, ctx, memberID)
+	group, err := chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{memberID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+
+	// Group members cannot change the timer by default.
+	err = service.SetMessageTTL(ctx, memberID, groupID, 24*time.Hour)
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+	err = service.SetMessageTTL(ctx, ownerID, groupID, time.Second)
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+	require.NoError(t, service.SetMessageTTL(ctx, ownerID, groupID, 24*time.Hour))
+	require.NoError(t, service.SetMessageTTL(ctx, ownerID, groupID, 24*time.Hour))
+
+	// The change shows up once as a service message.
+	page, err := service.ListHistory(ctx, memberID, groupID, HistoryQuery{Direction: HistoryBefore, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "service", page.Items[0].ContentType)
+	var body TTLChangedService
+	require.NoError(t, json.Unmarshal(page.Items[0].Service, &body))
+	assert.Equal(t, TTLChangedService{Action: ServiceMessageTTLChanged, TTLSeconds: 86400}, body)
+	_, err = service.EditMessage(ctx, ownerID, groupID, ulid.MustParse(page.Items[0].ID), EditMessageParams{SenderDeviceID: ownerDevice, Ciphertext: testCiphertext()})
+	requireBusinessCode(t, err, "MESSAGE_NOT_EDITABLE")
+
+	// Messages sent afterwards expire, and the reaper deletes them.
+	msg, _, err := service.SendMessage(ctx, ownerID, groupID, SendMessageParams{SenderDeviceID: ownerDevice, ContentType: "text", Ciphertext: testCiphertext()})
+	require.NoError(t, err)
+	require.True(t, msg.ExpiresAt.Valid)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), msg.ExpiresAt.Time, time.Minute)
+	reaped, err := service.ReapExpiredMessages(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, reaped)
+	_, err = testPool.Exec(ctx, `UPDATE messages SET expires_at = now() - interval '1 second' WHERE id = $1`, msg.ID)
+	require.NoError(t, err)
+	reaped, err = service.ReapExpiredMessages(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reaped)
+	tombstone, err := service.GetMessage(ctx, memberID, groupID, ulid.MustParse(msg.ID), nil)
+	require.NoError(t, err)
+	assert.True(t, tombstone.DeletedAt.Valid)
+	assert.Empty(t, tombstone.Ciphertext)
+
+	// View-once messages in a direct chat disappear for both users once the
+	// recipient viewed them.
+	direct, _, err := chats.GetOrCreateDirectChat(ctx, ownerID, memberID)
+	require.NoError(t, err)
+	directID := ulid.MustParse(direct.ID)
+	photo, _, err := service.SendMessage(ctx, ownerID, directID, SendMessageParams{SenderDeviceID: ownerDevice, ContentType: "photo", Ciphertext: testCiphertext(), ViewOnce: true})
+	require.NoError(t, err)
+	photoID := ulid.MustParse(photo.ID)
+	require.NoError(t, service.ViewMessage(ctx, ownerID, directID, photoID))
+	got, err := service.GetMessage(ctx, ownerID, directID, photoID, nil)
+	require.NoError(t, err)
+	assert.False(t, got.DeletedAt.Valid)
+	require.NoError(t, service.ViewMessage(ctx, memberID, directID, photoID))
+	got, err = service.GetMessage(ctx, ownerID, directID, photoID, nil)
+	require.NoError(t, err)
+	assert.True(t, got.DeletedAt.Valid)
+
+	plain, _, err := service.SendMessage(ctx, memberID, directID, SendMessageParams{SenderDeviceID: memberDevice, ContentType: "text", Ciphertext: testCiphertext()})
+	require.NoError(t, err)
+	err = service.ViewMessage(ctx, ownerID, directID, ulid.MustParse(plain.ID))
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+
+	// Either user of a direct chat can set its timer.
+	require.NoError(t, service.SetMessageTTL(ctx, memberID, directID, 7*24*time.Hour))
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	// maxDraftSize caps the encrypted content of a draft.
+	maxDraftSize = 64 << 10
+	// maxDraftClockSkew is how far ahead of the server a client timestamp
+	// may be. A draft from far in the future would otherwise win against
+	// every later edit.
+	maxDraftClockSkew = 5 * time.Minute
+
+	defaultDraftPageSize = 50
+	maxDraftPageSize     = 100
+)
+
+// DraftParams is a draft a user saves for a chat or one of its threads.
+// Empty Ciphertext and no ReplyToID clear the draft.
+type DraftParams struct {
+	ThreadID        *ulid.ULID
+	Ciphertext      []byte
+	ReplyToID       *ulid.ULID
+	ClientUpdatedAt time.Time
+}
+
+// DraftPage is one page of a user's drafts.
+type DraftPage struct {
+	Items      []db.Draft `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// DraftsService provides business logic for the message drafts users sync
+// across their devices. Drafts are opaque to the server; of two versions
+// of a draft the one with the newer client timestamp wins.
+type DraftsService struct {
+	repo     repos.DraftRepository
+	threads  repos.ThreadRepository
+	messages repos.MessageRepository
+	chats    *ChatsService
+}
+
+// NewDraftsService creates a new DraftsService.
+func NewDraftsService(repo repos.DraftRepository, threads repos.ThreadRepository, messages repos.MessageRepository, chats *ChatsService) *DraftsService {
+	return &DraftsService{repo: repo, threads: threads, messages: messages, chats: chats}
+}
+
+// SaveDraft stores the user's draft for a chat or thread and sends it to
+// their other devices. If the stored draft is at least as new, it is kept
+// and returned instead, and saved is false.
+func (s *DraftsService) SaveDraft(ctx context.Context, userID, chatID ulid.ULID, params DraftParams) (draft *db.Draft, saved bool, err error) {
+	if len(params.Ciphertext) > maxDraftSize {
+		return nil, false, &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("Drafts can be at most %d bytes", maxDraftSize)}
+	}
+	if params.ClientUpdatedAt.IsZero() {
+		return nil, false, &BusinessError{Code: string(utils.ErrValidation), Message: "client_updated_at is required"}
+	}
+	if params.ClientUpdatedAt.After(time.Now().Add(maxDraftClockSkew)) {
+		return nil, false, &BusinessError{Code: string(utils.ErrValidation), Message: "client_updated_at is in the future"}
+	}
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return nil, false, err
+	}
+	if params.ThreadID != nil {
+		_, err := s.threads.GetThread(ctx, chatID, *params.ThreadID)
+		if errors.Is(err, repos.ErrNotFound) {
+			return nil, false, threadNotFound()
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	if params.ReplyToID != nil {
+		visible, err := s.messages.ListVisibleMessages(ctx, chatID, userID, []ulid.ULID{*params.ReplyToID})
+		if err != nil {
+			return nil, false, err
+		}
+		if len(visible) == 0 {
+			return nil, false, &BusinessError{Code: string(utils.ErrMessageNotFound), Message: "The message being replied to was not found"}
+		}
+	}
+
+	err = s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		draft, err = s.repo.SaveDraft(ctx, repos.NewDraft{
+			UserID:          userID,
+			ChatID:          chatID,
+			ThreadID:        params.ThreadID,
+			Ciphertext:      params.Ciphertext,
+			ReplyToID:       params.ReplyToID,
+			ClientUpdatedAt: params.ClientUpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		return s.chats.updates.Publish(ctx, userID, UpdateDraft, draft)
+	})
+	if errors.Is(err, repos.ErrNotFound) {
+		// Another device saved a newer version first.
+		draft, err = s.repo.GetDraft(ctx, userID, chatID, params.ThreadID)
+		return draft, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return draft, true, nil
+}
+
+// ListDrafts returns a page of the user's drafts across their chats, most
+// recently changed first, so a device can catch up on all of them.
+func (s *DraftsService) ListDrafts(ctx context.Context, userID ulid.ULID, cursor string, limit int) (*DraftPage, error) {
+	limit = clampPageSize(limit, defaultDraftPageSize, maxDraftPageSize)
+
+	var after *repos.DraftCursor
+	if cursor != "" {
+		parts, err := utils.DecodeCursor(cursor, 3)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		micros, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		after = &repos.DraftCursor{UpdatedAt: time.UnixMicro(micros), ChatID: parts[1], ThreadID: parts[2]}
+	}
+
+	rows, err := s.repo.ListDrafts(ctx, userID, after, int32(limit+1))
+	if err != nil {
+		return nil, err
+	}
+	page := &DraftPage{Items: rows}
+	if len(rows) > limit {
+		page.Items = rows[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = utils.EncodeCursor(strconv.FormatInt(last.UpdatedAt.Time.UnixMicro(), 10), last.ChatID, last.ThreadID.String)
+	}
+	return page, nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/messenger/backend/internal/storage/postgres"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrafts_NewestWinsSyncAndChatList_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	notifier := &recordingNotifier{}
+	chats.updates.notifier = notifier
+	messages := setupMessagesService(chats)
+	service := NewDraftsService(postgres.NewPostgresDraftRepository(testQueries), postgres.NewPostgresThreadRepository(testQueries), postgres.NewPostgresMessageRepository(testQueries), chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	memberID := createUser(t, ctx, "member")
+	memberDevice := createDevice(t, ctx, memberID)
+	group, err := chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{memberID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+	msg, _, err := messages.SendMessage(ctx, memberID, groupID, SendMessageParams{SenderDeviceID: memberDevice, ContentType: "text", Ciphertext: testCiphertext()})
+	require.NoError(t, err)
+	msgID := ulid.MustParse(msg.ID)
+
+	// A save reaches the user's other devices.
+	now := time.Now()
+	draft, saved, err := service.SaveDraft(ctx, ownerID, groupID, DraftParams{Ciphertext: []byte("hello"), ReplyToID: &msgID, ClientUpdatedAt: now})
+	require.NoError(t, err)
+	assert.True(t, saved)
+	assert.Equal(t, msg.ID, draft.ReplyToID.String)
+	updates := notifier.updates[ownerID]
+	require.NotEmpty(t, updates)
+	assert.Equal(t, UpdateDraft, updates[len(updates)-1].Type)
+
+	// An older save from another device loses and is not sent.
+	draft, saved, err = service.SaveDraft(ctx, ownerID, groupID, DraftParams{Ciphertext: []byte("stale"), ClientUpdatedAt: now.Add(-time.Minute)})
+	require.NoError(t, err)
+	assert.False(t, saved)
+	assert.Equal(t, []byte("hello"), draft.Ciphertext)
+	assert.Len(t, notifier.updates[ownerID], len(updates))
+
+	_, _, err = service.SaveDraft(ctx, ownerID, groupID, DraftParams{ClientUpdatedAt: now.Add(time.Hour)})
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+	_, _, err = service.SaveDraft(ctx, ownerID, groupID, DraftParams{ThreadID: &msgID, ClientUpdatedAt: now})
+	requireBusinessCode(t, err, "THREAD_NOT_FOUND")
+
+	// The chat list carries the draft.
+	page, err := chats.ListChats(ctx, ownerID, ChatListOptions{}, "", 10)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	require.Len(t, page.Items[0].Drafts, 1)
+	assert.Equal(t, []byte("hello"), page.Items[0].Drafts[0].Ciphertext)
+	drafts, err := service.ListDrafts(ctx, ownerID, "", 10)
+	require.NoError(t, err)
+	assert.Len(t, drafts.Items, 1)
+
+	// A newer clear wins and leaves the lists; an older save cannot bring
+	// the draft back.
+	_, saved, err = service.SaveDraft(ctx, ownerID, groupID, DraftParams{ClientUpdatedAt: now.Add(time.Second)})
+	require.NoError(t, err)
+	assert.True(t, saved)
+	_, saved, err = service.SaveDraft(ctx, ownerID, groupID, DraftParams{Ciphertext: []byte("late"), ClientUpdatedAt: now.Add(time.Millisecond)})
+	require.NoError(t, err)
+	assert.False(t, saved)
+	page, err = chats.ListChats(ctx, ownerID, ChatListOptions{}, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, page.Items[0].Drafts)
+	drafts, err = service.ListDrafts(ctx, ownerID, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, drafts.Items)
+
+	// Drafts are private to their user.
+	page, err = chats.ListChats(ctx, memberID, ChatListOptions{}, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, page.Items[0].Drafts)
+}
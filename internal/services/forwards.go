@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+// checkReplyTarget makes sure a reply references a message of the same chat
+// that the sender can still see.
+func (s *MessagesService) checkReplyTarget(ctx context.Context, userID, chatID, replyToID ulid.ULID) error {
+	msg, err := s.visibleMessage(ctx, userID, chatID, replyToID)
+	if err != nil {
+		return err
+	}
+	if msg == nil {
+		return &BusinessError{Code: string(utils.ErrMessageNotFound), Message: "The message being replied to was not found"}
+	}
+	return nil
+}
+
+// forwardOrigin checks that the user can see the message being forwarded
+// and returns the attribution to record on the copy. Forwarding a
+// forwarded message keeps the original attribution. Channel posts are
+// attributed to the channel; other messages to their sender and chat
+// unless the sender hides forwarding attribution.
+func (s *MessagesService) forwardOrigin(ctx context.Context, userID ulid.ULID, source ForwardSource, contentType string) (*repos.ForwardOrigin, error) {
+	access, err := s.chats.Authorize(ctx, userID, source.ChatID, PermNone)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := s.visibleMessage(ctx, userID, source.ChatID, source.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg == nil {
+		return nil, &BusinessError{Code: string(utils.ErrMessageNotFound), Message: "The message being forwarded was not found"}
+	}
+	if msg.ContentType != contentType {
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: "A forwarded message must keep the content type of the original"}
+	}
+
+	if msg.ForwardDate.Valid {
+		return &repos.ForwardOrigin{
+			UserID:    optionalID(msg.ForwardFromUserID.String),
+			ChatID:    optionalID(msg.ForwardFromChatID.String),
+			MessageID: optionalID(msg.ForwardFromMessageID.String),
+			Date:      msg.ForwardDate.Time,
+		}, nil
+	}
+
+	origin := &repos.ForwardOrigin{Date: msg.CreatedAt.Time}
+	if access.Chat.Type == db.ChatTypeChannel {
+		origin.ChatID, origin.MessageID = &source.ChatID, &source.MessageID
+		return origin, nil
+	}
+	senderID := optionalID(msg.SenderID.String)
+	if senderID == nil {
+		return origin, nil
+	}
+	if *senderID != userID {
+		settings, err := s.privacy.GetPrivacySettings(ctx, *senderID)
+		if err != nil {
+			return nil, err
+		}
+		if !settings.ForwardAttribution {
+			return origin, nil
+		}
+	}
+	origin.UserID, origin.ChatID, origin.MessageID = senderID, &source.ChatID, &source.MessageID
+	return origin, nil
+}
+
+// visibleMessage returns a message of a chat the user can still see that
+// has not been deleted for everyone, or nil when there is none.
+func (s *MessagesService) visibleMessage(ctx context.Context, userID, chatID, messageID ulid.ULID) (*db.Message, error) {
+	msgs, err := s.repo.ListVisibleMessages(ctx, chatID, userID, []ulid.ULID{messageID})
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 || msgs[0].DeletedAt.Valid {
+		return nil, nil
+	}
+	return &msgs[0], nil
+}
+
+// optionalID parses an ID stored in a nullable column.
+func optionalID(id string) *ulid.ULID {
+	if id == "" {
+		return nil
+	}
+	parsed := ulid.MustParse(id)
+	return &parsed
+}
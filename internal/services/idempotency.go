@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	// defaultIdempotencyWindow applies when no replay window is configured.
+	defaultIdempotencyWindow = 24 * time.Hour
+	// defaultIdempotencyStaleAfter applies when no stale time is configured.
+	defaultIdempotencyStaleAfter = time.Minute
+)
+
+// StoredResponse is a response recorded for an idempotency key.
+type StoredResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// IdempotencyService records responses to mutating requests so that retries
+// with the same Idempotency-Key replay the original response.
+type IdempotencyService struct {
+	repo       repos.IdempotencyRepository
+	window     time.Duration
+	staleAfter time.Duration
+}
+
+// NewIdempotencyService creates a new IdempotencyService. Responses are
+// replayed for window after the first request. An unfinished request holds
+// its key for staleAfter before a retry may take it over, e.g. after the
+// server crashed mid-request.
+func NewIdempotencyService(repo repos.IdempotencyRepository, window, staleAfter time.Duration) *IdempotencyService {
+	if window <= 0 {
+		window = defaultIdempotencyWindow
+	}
+	if staleAfter <= 0 {
+		staleAfter = defaultIdempotencyStaleAfter
+	}
+	return &IdempotencyService{repo: repo, window: window, staleAfter: staleAfter}
+}
+
+// Begin reserves key for a request with the given hash. A nil response means
+// the request is new and must run, followed by Complete or Release. A stored
+// response is returned for a retry of a finished request. Reusing a key for a
+// different request, or while the first one is still running, is a conflict.
+func (s *IdempotencyService) Begin(ctx context.Context, userID ulid.ULID, key, requestHash string) (*StoredResponse, error) {
+	now := time.Now()
+	err := s.repo.ClaimIdempotencyKey(ctx, userID, key, requestHash, now.Add(s.window), now.Add(-s.staleAfter))
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, repos.ErrAlreadyExists) {
+		return nil, err
+	}
+
+	entry, err := s.repo.GetIdempotencyKey(ctx, userID, key)
+	if errors.Is(err, repos.ErrNotFound) {
+		// Purged between the claim and the read; let the client retry.
+		return nil, idempotencyInProgress()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if entry.RequestHash != requestHash {
+		return nil, &BusinessError{Code: string(utils.ErrConflict), Message: "This idempotency key was already used for a different request"}
+	}
+	if !entry.StatusCode.Valid {
+		return nil, idempotencyInProgress()
+	}
+	return &StoredResponse{
+		StatusCode:  int(entry.StatusCode.Int32),
+		ContentType: entry.ContentType.String,
+		Body:        entry.ResponseBody,
+	}, nil
+}
+
+// Complete stores the response of a request started with Begin.
+func (s *IdempotencyService) Complete(ctx context.Context, userID ulid.ULID, key string, resp StoredResponse) error {
+	return s.repo.CompleteIdempotencyKey(ctx, userID, key, resp.StatusCode, resp.ContentType, resp.Body)
+}
+
+// Release gives up the key of a request that should not be replayed, such
+// as one that failed with a server error, so a retry runs it again.
+func (s *IdempotencyService) Release(ctx context.Context, userID ulid.ULID, key string) error {
+	return s.repo.ReleaseIdempotencyKey(ctx, userID, key)
+}
+
+// RunIdempotencyPurge deletes expired keys every interval until ctx is done.
+func (s *IdempotencyService) RunIdempotencyPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := s.repo.PurgeExpiredIdempotencyKeys(ctx); err != nil {
+				log.Printf("idempotency: purge failed: %v", err)
+			} else if n > 0 {
+				log.Printf("idempotency: purged %d expired keys", n)
+			}
+		}
+	}
+}
+
+func idempotencyInProgress() *BusinessError {
+	return &BusinessError{
+		Code:    string(utils.ErrConflict),
+		Message: "A request with this idempotency key is still in progress",
+		Details: map[string]any{"retry_after": 1},
+	}
+}
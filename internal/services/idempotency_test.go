@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/messenger/backend/internal/storage/postgres"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotency_ReplayAndConflict_RealDB(t *testing.T) {
+	repo := postgres.NewPostgresIdempotencyRepository(testQueries)
+	service := NewIdempotencyService(repo, time.Hour, time.Minute)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	aliceID := createUser(t, ctx, "alice")
+	bobID := createUser(t, ctx, "bob")
+
+	stored, err := service.Begin(ctx, aliceID, "key-1", "hash-a")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+	// A retry while the first request runs must wait.
+	_, err = service.Begin(ctx, aliceID, "key-1", "hash-a")
+	requireBusinessCode(t, err, "CONFLICT")
+
+	resp := StoredResponse{StatusCode: http.StatusCreated, ContentType: "application/json", Body: []byte(`{"id":"1"}`)}
+	require.NoError(t, service.Complete(ctx, aliceID, "key-1", resp))
+
+	stored, err = service.Begin(ctx, aliceID, "key-1", "hash-a")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, resp, *stored)
+
+	_, err = service.Begin(ctx, aliceID, "key-1", "hash-b")
+	requireBusinessCode(t, err, "CONFLICT")
+
+	// Keys are scoped per user.
+	stored, err = service.Begin(ctx, bobID, "key-1", "hash-b")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+
+	// A released key runs again on retry.
+	require.NoError(t, service.Release(ctx, bobID, "key-1"))
+	stored, err = service.Begin(ctx, bobID, "key-1", "hash-b")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+
+	// An unfinished request is taken over once it is stale.
+	stale := NewIdempotencyService(repo, time.Hour, 10*time.Millisecond)
+	_, err = stale.Begin(ctx, aliceID, "key-2", "hash-a")
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	stored, err = stale.Begin(ctx, aliceID, "key-2", "hash-a")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+}
@@ -0,0 +1,391 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/messenger/backend/internal/crypto"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	inviteTokenBytes        = 16
+	maxInviteLinkNameLength = 32
+	maxInviteLinkUsage      = 100000
+)
+
+// JoinStatus tells a user who followed an invite link what happened.
+type JoinStatus string
+
+const (
+	JoinStatusJoined  JoinStatus = "joined"
+	JoinStatusPending JoinStatus = "pending"
+)
+
+// InvitesService provides business logic for invite links and join requests.
+type InvitesService struct {
+	repo  repos.InviteRepository
+	chats *ChatsService
+}
+
+// NewInvitesService creates a new InvitesService.
+func NewInvitesService(repo repos.InviteRepository, chats *ChatsService) *InvitesService {
+	return &InvitesService{repo: repo, chats: chats}
+}
+
+// CreateInviteLinkParams describes a new invite link. Nil fields mean no
+// name, no expiry and no usage limit.
+type CreateInviteLinkParams struct {
+	Name             *string
+	ExpiresAt        *time.Time
+	UsageLimit       *int
+	RequiresApproval bool
+}
+
+// InvitePreview is what anyone holding a valid link can see before joining.
+type InvitePreview struct {
+	ChatID           string      `json:"chat_id"`
+	Type             db.ChatType `json:"type"`
+	Title            string      `json:"title"`
+	PhotoURL         string      `json:"photo_url,omitempty"`
+	MemberCount      int32       `json:"member_count"`
+	RequiresApproval bool        `json:"requires_approval"`
+}
+
+// JoinResult is the outcome of following an invite link.
+type JoinResult struct {
+	Status JoinStatus `json:"status"`
+	Chat   *db.Chat   `json:"chat,omitempty"`
+}
+
+// JoinRequestPage is one page of a chat's pending join requests.
+type JoinRequestPage struct {
+	Items      []db.ListPendingJoinRequestsRow `json:"items"`
+	NextCursor string                          `json:"next_cursor,omitempty"`
+}
+
+// CreateInviteLink creates a new invite link for a group or channel. Only
+// admins with the add-members permission can manage links.
+func (s *InvitesService) CreateInviteLink(ctx context.Context, actorID, chatID ulid.ULID, params CreateInviteLinkParams) (*db.ChatInviteLink, error) {
+	if _, err := s.authorizeInviteAdmin(ctx, actorID, chatID); err != nil {
+		return nil, err
+	}
+
+	var linkParams repos.InviteLinkParams
+	linkParams.RequiresApproval = params.RequiresApproval
+	if params.Name != nil && *params.Name != "" {
+		if utf8.RuneCountInString(*params.Name) > maxInviteLinkNameLength {
+			return nil, &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("Link name must be at most %d characters", maxInviteLinkNameLength)}
+		}
+		linkParams.Name = sql.NullString{String: *params.Name, Valid: true}
+	}
+	if params.ExpiresAt != nil {
+		if !params.ExpiresAt.After(time.Now()) {
+			return nil, &BusinessError{Code: string(utils.ErrValidation), Message: "Expiry must be in the future"}
+		}
+		linkParams.ExpiresAt = sql.NullTime{Time: *params.ExpiresAt, Valid: true}
+	}
+	if params.UsageLimit != nil {
+		if *params.UsageLimit < 1 || *params.UsageLimit > maxInviteLinkUsage {
+			return nil, &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("Usage limit must be between 1 and %d", maxInviteLinkUsage)}
+		}
+		linkParams.UsageLimit = sql.NullInt32{Int32: int32(*params.UsageLimit), Valid: true}
+	}
+
+	token, err := crypto.RandomToken(inviteTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+	link, err := s.repo.CreateInviteLink(ctx, chatID, actorID, token, linkParams)
+	if err != nil {
+		return nil, err
+	}
+	after := map[string]any{
+		"name":              link.Name.String,
+		"requires_approval": link.RequiresApproval,
+	}
+	if link.ExpiresAt.Valid {
+		after["expires_at"] = link.ExpiresAt.Time
+	}
+	if link.UsageLimit.Valid {
+		after["usage_limit"] = link.UsageLimit.Int32
+	}
+	if err := s.chats.recordAudit(ctx, chatID, actorID, AuditInviteLinkCreated, auditTarget{ID: link.ID}, nil, after); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// ListInviteLinks returns every link of a group, including revoked ones.
+func (s *InvitesService) ListInviteLinks(ctx context.Context, actorID, chatID ulid.ULID) ([]db.ChatInviteLink, error) {
+	if _, err := s.authorizeInviteAdmin(ctx, actorID, chatID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListInviteLinks(ctx, chatID)
+}
+
+// RevokeInviteLink makes a link unusable. Pending join requests made through
+// it stay open.
+func (s *InvitesService) RevokeInviteLink(ctx context.Context, actorID, chatID, linkID ulid.ULID) error {
+	if _, err := s.authorizeInviteAdmin(ctx, actorID, chatID); err != nil {
+		return err
+	}
+	err := s.repo.RevokeInviteLink(ctx, chatID, linkID)
+	if errors.Is(err, repos.ErrNotFound) {
+		return inviteInvalid()
+	}
+	if err != nil {
+		return err
+	}
+	return s.chats.recordAudit(ctx, chatID, actorID, AuditInviteLinkRevoked, auditTarget{ID: linkID.String()}, nil, nil)
+}
+
+// PreviewInvite describes the chat behind a valid link without joining it.
+func (s *InvitesService) PreviewInvite(ctx context.Context, token string) (*InvitePreview, error) {
+	link, err := s.getUsableLink(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	chat, err := s.chats.repo.GetChat(ctx, ulid.MustParse(link.ChatID))
+	if err != nil {
+		if errors.Is(err, repos.ErrNotFound) {
+			return nil, inviteInvalid()
+		}
+		return nil, err
+	}
+	return &InvitePreview{
+		ChatID:           chat.ID,
+		Type:             chat.Type,
+		Title:            chat.Title.String,
+		PhotoURL:         chat.PhotoUrl.String,
+		MemberCount:      chat.MemberCount,
+		RequiresApproval: link.RequiresApproval,
+	}, nil
+}
+
+// JoinByInvite follows an invite link. The user joins immediately unless the
+// link requires approval, in which case a pending join request is opened.
+// Each join or request takes one use of the link.
+func (s *InvitesService) JoinByInvite(ctx context.Context, userID ulid.ULID, token string) (*JoinResult, error) {
+	link, err := s.getUsableLink(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	chatID := ulid.MustParse(link.ChatID)
+	linkID := ulid.MustParse(link.ID)
+
+	banned, err := s.chats.repo.IsBannedFromChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if banned {
+		return nil, userBanned()
+	}
+	member, err := s.chats.repo.IsChatMember(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member {
+		return nil, memberExists()
+	}
+
+	// The use of the link is only taken when the join or request happens.
+	err = s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.consumeLink(ctx, linkID); err != nil {
+			return err
+		}
+		if link.RequiresApproval {
+			_, err := s.repo.CreateJoinRequest(ctx, chatID, userID, linkID)
+			return err
+		}
+		chat, err := s.chats.repo.GetChat(ctx, chatID)
+		if err != nil {
+			return err
+		}
+		var invitedBy ulid.ULID
+		if link.CreatedBy.Valid {
+			invitedBy = ulid.MustParse(link.CreatedBy.String)
+		}
+		return s.chats.admitMember(ctx, chat, userID, invitedBy)
+	})
+	if link.RequiresApproval && (err == nil || errors.Is(err, repos.ErrAlreadyExists)) {
+		// Asking again while a request is pending takes no use.
+		return &JoinResult{Status: JoinStatusPending}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Reload for the updated member count.
+	chat, err := s.chats.repo.GetChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return &JoinResult{Status: JoinStatusJoined, Chat: chat}, nil
+}
+
+// ListJoinRequests returns a page of pending join requests, oldest first.
+func (s *InvitesService) ListJoinRequests(ctx context.Context, actorID, chatID ulid.ULID, cursor string, limit int) (*JoinRequestPage, error) {
+	if _, err := s.authorizeInviteAdmin(ctx, actorID, chatID); err != nil {
+		return nil, err
+	}
+	limit = clampPageSize(limit, defaultChatPageSize, maxChatPageSize)
+
+	var after *repos.MemberCursor
+	if cursor != "" {
+		parts, err := utils.DecodeCursor(cursor, 2)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		micros, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		after = &repos.MemberCursor{JoinedAt: time.UnixMicro(micros), UserID: parts[1]}
+	}
+
+	rows, err := s.repo.ListPendingJoinRequests(ctx, chatID, after, int32(limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	page := &JoinRequestPage{Items: rows}
+	if len(rows) > limit {
+		page.Items = rows[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = utils.EncodeCursor(strconv.FormatInt(last.CreatedAt.Time.UnixMicro(), 10), last.UserID)
+	}
+	return page, nil
+}
+
+// ApproveJoinRequest adds the requesting user to the group. Bans and the
+// member limit are checked again at approval time.
+func (s *InvitesService) ApproveJoinRequest(ctx context.Context, actorID, chatID, userID ulid.ULID) error {
+	access, err := s.authorizeInviteAdmin(ctx, actorID, chatID)
+	if err != nil {
+		return err
+	}
+	if err := s.getPendingRequest(ctx, chatID, userID); err != nil {
+		return err
+	}
+
+	err = s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.chats.admitMember(ctx, access.Chat, userID, actorID); err != nil {
+			return err
+		}
+		return s.approve(ctx, chatID, userID, actorID)
+	})
+	var bErr *BusinessError
+	if errors.As(err, &bErr) && bErr.Code == string(utils.ErrMemberExists) {
+		// A user who joined some other way in the meantime still gets their
+		// request resolved. The failed add ended the first transaction.
+		err = s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+			return s.approve(ctx, chatID, userID, actorID)
+		})
+	}
+	return err
+}
+
+// approve resolves a join request as approved and records it.
+func (s *InvitesService) approve(ctx context.Context, chatID, userID, actorID ulid.ULID) error {
+	if err := s.decide(ctx, chatID, userID, db.JoinRequestStateApproved, actorID); err != nil {
+		return err
+	}
+	return s.chats.recordAudit(ctx, chatID, actorID, AuditJoinRequestApproved, targetUser(userID), nil, nil)
+}
+
+// DeclineJoinRequest rejects a pending join request. The user may ask again
+// through any valid link.
+func (s *InvitesService) DeclineJoinRequest(ctx context.Context, actorID, chatID, userID ulid.ULID) error {
+	if _, err := s.authorizeInviteAdmin(ctx, actorID, chatID); err != nil {
+		return err
+	}
+	if err := s.decide(ctx, chatID, userID, db.JoinRequestStateDeclined, actorID); err != nil {
+		return err
+	}
+	return s.chats.recordAudit(ctx, chatID, actorID, AuditJoinRequestDeclined, targetUser(userID), nil, nil)
+}
+
+// authorizeInviteAdmin allows group admins and the owner holding the
+// add-members permission.
+func (s *InvitesService) authorizeInviteAdmin(ctx context.Context, actorID, chatID ulid.ULID) (*ChatAccess, error) {
+	access, err := s.chats.authorizeGroup(ctx, actorID, chatID, PermAddMembers)
+	if err != nil {
+		return nil, err
+	}
+	if access.Member.Role == db.ChatMemberRoleMember {
+		return nil, forbiddenRole("Only admins can manage invite links and join requests")
+	}
+	return access, nil
+}
+
+// getUsableLink resolves a token to a link that can still be followed.
+func (s *InvitesService) getUsableLink(ctx context.Context, token string) (*db.ChatInviteLink, error) {
+	link, err := s.repo.GetInviteLinkByToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, repos.ErrNotFound) {
+			return nil, inviteInvalid()
+		}
+		return nil, err
+	}
+	if link.RevokedAt.Valid {
+		return nil, inviteInvalid()
+	}
+	if (link.ExpiresAt.Valid && !link.ExpiresAt.Time.After(time.Now())) ||
+		(link.UsageLimit.Valid && link.UsageCount >= link.UsageLimit.Int32) {
+		return nil, inviteExpired()
+	}
+	return link, nil
+}
+
+// consumeLink takes one use of a link. Losing a race for its last use or
+// expiring in the meantime reports the link as expired.
+func (s *InvitesService) consumeLink(ctx context.Context, linkID ulid.ULID) error {
+	_, err := s.repo.ConsumeInviteLink(ctx, linkID)
+	if errors.Is(err, repos.ErrNotFound) {
+		return inviteExpired()
+	}
+	return err
+}
+
+func (s *InvitesService) getPendingRequest(ctx context.Context, chatID, userID ulid.ULID) error {
+	req, err := s.repo.GetJoinRequest(ctx, chatID, userID)
+	if err != nil {
+		if errors.Is(err, repos.ErrNotFound) {
+			return joinRequestNotFound()
+		}
+		return err
+	}
+	if req.State != db.JoinRequestStatePending {
+		return joinRequestNotFound()
+	}
+	return nil
+}
+
+func (s *InvitesService) decide(ctx context.Context, chatID, userID ulid.ULID, state db.JoinRequestState, actorID ulid.ULID) error {
+	err := s.repo.DecideJoinRequest(ctx, chatID, userID, state, actorID)
+	if errors.Is(err, repos.ErrNotFound) {
+		return joinRequestNotFound()
+	}
+	return err
+}
+
+func inviteInvalid() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrInviteInvalid), Message: "Invite link is invalid or has been revoked"}
+}
+
+func inviteExpired() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrInviteExpired), Message: "Invite link has expired or reached its usage limit"}
+}
+
+func joinRequestNotFound() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrNotFound), Message: "Join request not found"}
+}
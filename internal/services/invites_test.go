@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/messenger/backend/internal/storage/postgres"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupInvitesService() *InvitesService {
+	return NewInvitesService(postgres.NewPostgresInviteRepository(testQueries), setupChatsService())
+}
+
+func TestJoinByInvite_UsageLimit_RealDB(t *testing.T) {
+	service := setupInvitesService()
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	user2ID := createUser(t, ctx, "user2")
+	user3ID := createUser(t, ctx, "user3")
+
+	group, err := service.chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team"})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+
+	limit := 1
+	link, err := service.CreateInviteLink(ctx, ownerID, groupID, CreateInviteLinkParams{UsageLimit: &limit})
+	require.NoError(t, err)
+
+	preview, err := service.PreviewInvite(ctx, link.Token)
+	require.NoError(t, err)
+	assert.Equal(t, "Team", preview.Title)
+	assert.False(t, preview.RequiresApproval)
+
+	result, err := service.JoinByInvite(ctx, user2ID, link.Token)
+	require.NoError(t, err)
+	assert.Equal(t, JoinStatusJoined, result.Status)
+	assert.EqualValues(t, 2, result.Chat.MemberCount)
+
+	_, err = service.JoinByInvite(ctx, user3ID, link.Token)
+	requireBusinessCode(t, err, "INVITE_EXPIRED")
+
+	// Members cannot manage links.
+	_, err = service.CreateInviteLink(ctx, user2ID, groupID, CreateInviteLinkParams{})
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+
+	require.NoError(t, service.RevokeInviteLink(ctx, ownerID, groupID, ulid.MustParse(link.ID)))
+	_, err = service.PreviewInvite(ctx, link.Token)
+	requireBusinessCode(t, err, "INVITE_INVALID")
+}
+
+func TestJoinByInvite_Approval_RealDB(t *testing.T) {
+	service := setupInvitesService()
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	user2ID := createUser(t, ctx, "user2")
+	user3ID := createUser(t, ctx, "user3")
+
+	group, err := service.chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team"})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+
+	link, err := service.CreateInviteLink(ctx, ownerID, groupID, CreateInviteLinkParams{RequiresApproval: true})
+	require.NoError(t, err)
+
+	for _, userID := range []ulid.ULID{user2ID, user3ID} {
+		result, err := service.JoinByInvite(ctx, userID, link.Token)
+		require.NoError(t, err)
+		assert.Equal(t, JoinStatusPending, result.Status)
+	}
+
+	// Following the link again while pending keeps the single request.
+	result, err := service.JoinByInvite(ctx, user2ID, link.Token)
+	require.NoError(t, err)
+	assert.Equal(t, JoinStatusPending, result.Status)
+
+	page, err := service.ListJoinRequests(ctx, ownerID, groupID, "", 1)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.NotEmpty(t, page.NextCursor)
+
+	require.NoError(t, service.ApproveJoinRequest(ctx, ownerID, groupID, user2ID))
+	require.NoError(t, service.DeclineJoinRequest(ctx, ownerID, groupID, user3ID))
+
+	isMember, err := service.chats.repo.IsChatMember(ctx, groupID, user2ID)
+	require.NoError(t, err)
+	assert.True(t, isMember)
+	isMember, err = service.chats.repo.IsChatMember(ctx, groupID, user3ID)
+	require.NoError(t, err)
+	assert.False(t, isMember)
+
+	err = service.ApproveJoinRequest(ctx, ownerID, groupID, user3ID)
+	requireBusinessCode(t, err, "NOT_FOUND")
+}
+
+func TestJoinByInvite_FailedJoinTakesNoUse_RealDB(t *testing.T) {
+	service := setupInvitesService()
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	user2ID := createUser(t, ctx, "user2")
+	user3ID := createUser(t, ctx, "user3")
+	user4ID := createUser(t, ctx, "user4")
+
+	group, err := service.chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{user2ID, user3ID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+
+	limit := 1
+	link, err := service.CreateInviteLink(ctx, ownerID, groupID, CreateInviteLinkParams{UsageLimit: &limit})
+	require.NoError(t, err)
+
+	// The group is full, so the join fails and the link keeps its use.
+	_, err = service.JoinByInvite(ctx, user4ID, link.Token)
+	requireBusinessCode(t, err, "MEMBER_LIMIT_REACHED")
+
+	require.NoError(t, service.chats.RemoveMember(ctx, ownerID, groupID, user3ID))
+	result, err := service.JoinByInvite(ctx, user4ID, link.Token)
+	require.NoError(t, err)
+	assert.Equal(t, JoinStatusJoined, result.Status)
+}
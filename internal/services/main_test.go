@@ -10,10 +10,12 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/jackc/pgx/v5/stdlib" // Standard library driver for goose
 	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/storage/postgres"
 	"github.com/pressly/goose/v3"
 )
 
 var testQueries *db.Queries
+var testDB *postgres.DB
 var testPool *pgxpool.Pool
 
 func TestMain(m *testing.M) {
@@ -58,7 +60,8 @@ func TestMain(m *testing.M) {
 	}
 	log.Println("Test database migrations applied successfully.")
 
-	testQueries = db.New(testPool)
+	testDB = postgres.NewDB(testPool)
+	testQueries = db.New(testDB)
 
 	exitCode := m.Run()
 
@@ -71,6 +74,36 @@ func truncateTables(ctx context.Context, pool *pgxpool.Pool) error {
 		return fmt.Errorf("test database pool is nil")
 	}
 	tables := []string{
+		"idempotency_keys",
+		"user_privacy_settings",
+		"device_chat_deliveries",
+		"message_edits",
+		"message_device_payloads",
+		"message_hidden",
+		"message_reactions",
+		"pinned_messages",
+		"poll_votes",
+		"polls",
+		"message_mentions",
+		"drafts",
+		"scheduled_messages",
+		"user_presence",
+		"messages",
+		"chat_audit_events",
+		"chat_updates",
+		"user_updates",
+		"user_update_state",
+		"chat_folders",
+		"chat_user_states",
+		"thread_member_states",
+		"chat_threads",
+		"channel_post_stats",
+		"channel_post_views",
+		"chat_bans",
+		"chat_join_requests",
+		"chat_invite_links",
+		"chat_members",
+		"chats",
 		"blocks",
 		"contact_requests",
 		"contacts",
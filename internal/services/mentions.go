@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	// maxMentions caps the users one message can mention.
+	maxMentions = 50
+
+	defaultMentionPageSize = 50
+	maxMentionPageSize     = 100
+)
+
+// MentionUpdate tells a user that a message mentions them or replies to
+// them. Notify is set when the mention should alert the user even though
+// the chat may be muted.
+type MentionUpdate struct {
+	ChatID         string `json:"chat_id"`
+	MessageID      string `json:"message_id"`
+	ThreadID       string `json:"thread_id,omitempty"`
+	SenderID       string `json:"sender_id"`
+	Reply          bool   `json:"reply"`
+	Notify         bool   `json:"notify"`
+	UnreadMentions int64  `json:"unread_mentions"`
+}
+
+// MentionsReadUpdate tells the user's devices that the mentions of a chat
+// were marked read.
+type MentionsReadUpdate struct {
+	ChatID string `json:"chat_id"`
+}
+
+// MentionPage is one page of a user's mentions feed.
+type MentionPage struct {
+	Items      []db.ListMentionsRow `json:"items"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// MentionsService provides business logic for the feed of messages that
+// mention a user or reply to them.
+type MentionsService struct {
+	repo  repos.MentionRepository
+	chats *ChatsService
+}
+
+// NewMentionsService creates a new MentionsService.
+func NewMentionsService(repo repos.MentionRepository, chats *ChatsService) *MentionsService {
+	return &MentionsService{repo: repo, chats: chats}
+}
+
+// ListMentions returns a page of the messages mentioning the user across
+// their chats, newest first. chatID narrows the feed to one chat. Chats the
+// user left and users they have a block with are left out.
+func (s *MentionsService) ListMentions(ctx context.Context, userID ulid.ULID, chatID, deviceID *ulid.ULID, unreadOnly bool, cursor string, limit int) (*MentionPage, error) {
+	if chatID != nil {
+		if _, err := s.chats.Authorize(ctx, userID, *chatID, PermNone); err != nil {
+			return nil, err
+		}
+	}
+	limit = clampPageSize(limit, defaultMentionPageSize, maxMentionPageSize)
+
+	var after *repos.MentionCursor
+	if cursor != "" {
+		parts, err := utils.DecodeCursor(cursor, 2)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		micros, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		after = &repos.MentionCursor{MentionedAt: time.UnixMicro(micros), MessageID: parts[1]}
+	}
+
+	filter := repos.MentionFilter{ChatID: chatID, UnreadOnly: unreadOnly}
+	rows, err := s.repo.ListMentions(ctx, userID, filter, deviceID, after, int32(limit+1))
+	if err != nil {
+		return nil, err
+	}
+	page := &MentionPage{Items: rows}
+	if len(rows) > limit {
+		page.Items = rows[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = utils.EncodeCursor(strconv.FormatInt(last.MentionedAt.Time.UnixMicro(), 10), last.ID)
+	}
+	return page, nil
+}
+
+// ReadMentions marks all of the user's mentions in a chat read without
+// reading the chat itself.
+func (s *MentionsService) ReadMentions(ctx context.Context, userID, chatID ulid.ULID) error {
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return err
+	}
+	return s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		read, err := s.repo.ReadChatMentions(ctx, userID, chatID)
+		if err != nil || !read {
+			return err
+		}
+		return s.chats.updates.Publish(ctx, userID, UpdateMentionsRead, MentionsReadUpdate{ChatID: chatID.String()})
+	})
+}
+
+// publishMentions tells the users a new message mentions or replies to
+// about it.
+func (s *MessagesService) publishMentions(ctx context.Context, msg *db.Message) error {
+	mentions, err := s.repo.ListMessageMentions(ctx, ulid.MustParse(msg.ID))
+	if err != nil {
+		return err
+	}
+	for _, m := range mentions {
+		update := MentionUpdate{
+			ChatID:         msg.ChatID,
+			MessageID:      msg.ID,
+			ThreadID:       msg.ThreadID.String,
+			SenderID:       msg.SenderID.String,
+			Reply:          m.IsReply,
+			Notify:         m.Notify,
+			UnreadMentions: m.UnreadMentions,
+		}
+		if err := s.updates.Publish(ctx, ulid.MustParse(m.UserID), UpdateMention, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeMentions checks the mentions of a new message and returns the
+// usernames without their leading @.
+func normalizeMentions(userIDs []ulid.ULID, usernames []string) ([]string, error) {
+	if len(userIDs)+len(usernames) > maxMentions {
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("A message can mention at most %d users", maxMentions)}
+	}
+	names := make([]string, 0, len(usernames))
+	for _, name := range usernames {
+		name = strings.TrimPrefix(name, "@")
+		if name == "" {
+			return nil, &BusinessError{Code: string(utils.ErrValidation), Message: "Mentioned usernames must not be empty"}
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
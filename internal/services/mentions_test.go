@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/messenger/backend/internal/storage/postgres"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMentions_FeedCountersAndMute_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	notifier := &recordingNotifier{}
+	chats.updates = NewUpdatesService(postgres.NewPostgresUpdateRepository(testQueries), testDB, notifier)
+	messages := setupMessagesService(chats)
+	service := NewMentionsService(postgres.NewPostgresMentionRepository(testQueries), chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	aliceID := createUser(t, ctx, "alice")
+	bobID := createUser(t, ctx, "bob")
+	ownerDevice := createDevice(t, ctx, ownerID)
+	aliceDevice := createDevice(t, ctx, aliceID)
+	group, err := chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{aliceID, bobID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+	send := func(senderID, deviceID ulid.ULID, params SendMessageParams) ulid.ULID {
+		params.SenderDeviceID, params.ContentType, params.Ciphertext = deviceID, "text", testCiphertext()
+		msg, _, err := messages.SendMessage(ctx, senderID, groupID, params)
+		require.NoError(t, err)
+		return ulid.MustParse(msg.ID)
+	}
+	lastMention := func(userID ulid.ULID) MentionUpdate {
+		t.Helper()
+		updates := notifier.updates[userID]
+		for i := len(updates) - 1; i >= 0; i-- {
+			if updates[i].Type == UpdateMention {
+				var update MentionUpdate
+				require.NoError(t, json.Unmarshal(updates[i].Payload, &update))
+				return update
+			}
+		}
+		t.Fatal("no mention update")
+		return MentionUpdate{}
+	}
+
+	// Mentions by username or ID, and replies, reach members only.
+	_, err = chats.UpdateChatState(ctx, aliceID, groupID, ChatStateParams{Mute: &MuteParams{Forever: true}})
+	require.NoError(t, err)
+	first := send(ownerID, ownerDevice, SendMessageParams{MentionedUsernames: []string{"@Alice"}, MentionedUserIDs: []ulid.ULID{ownerID}})
+	update := lastMention(aliceID)
+	assert.Equal(t, MentionUpdate{ChatID: group.ID, MessageID: first.String(), SenderID: ownerID.String(), Notify: true, UnreadMentions: 1}, update)
+	for _, u := range notifier.updates[ownerID] {
+		assert.NotEqual(t, UpdateMention, u.Type, "senders do not mention themselves")
+	}
+
+	// Muted chats can turn mention notifications off.
+	off := false
+	_, err = chats.UpdateChatState(ctx, aliceID, groupID, ChatStateParams{MentionNotifications: &off})
+	require.NoError(t, err)
+	reply := send(bobID, createDevice(t, ctx, bobID), SendMessageParams{ReplyToID: &first, MentionedUserIDs: []ulid.ULID{aliceID}})
+	assert.False(t, lastMention(aliceID).Notify)
+	update = lastMention(ownerID)
+	assert.True(t, update.Reply)
+	assert.True(t, update.Notify)
+
+	page, err := service.ListMentions(ctx, aliceID, nil, &aliceDevice, true, "", 1)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, reply.String(), page.Items[0].ID)
+	page, err = service.ListMentions(ctx, aliceID, nil, nil, true, page.NextCursor, 1)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, first.String(), page.Items[0].ID)
+	chatList, err := chats.ListChats(ctx, aliceID, ChatListOptions{}, "", 10)
+	require.NoError(t, err)
+	require.Len(t, chatList.Items, 1)
+	assert.Equal(t, int64(2), chatList.Items[0].UnreadMentions)
+
+	// Blocks hide mentions either way.
+	_, err = testPool.Exec(ctx, `INSERT INTO blocks (owner_id, target_user_id) VALUES ($1, $2)`, bobID.String(), aliceID.String())
+	require.NoError(t, err)
+	page, err = service.ListMentions(ctx, aliceID, &groupID, nil, false, "", 10)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	chatList, err = chats.ListChats(ctx, aliceID, ChatListOptions{}, "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), chatList.Items[0].UnreadMentions)
+
+	// Marking mentions read keeps them in the feed but not as unread.
+	require.NoError(t, service.ReadMentions(ctx, aliceID, groupID))
+	page, err = service.ListMentions(ctx, aliceID, nil, nil, true, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+	page, err = service.ListMentions(ctx, aliceID, nil, nil, false, "", 10)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.False(t, page.Items[0].Unread)
+
+	// Members who leave lose the chat's mentions.
+	_, err = testPool.Exec(ctx, `DELETE FROM chat_members WHERE chat_id = $1 AND user_id = $2`, group.ID, aliceID.String())
+	require.NoError(t, err)
+	page, err = service.ListMentions(ctx, aliceID, nil, nil, false, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+}
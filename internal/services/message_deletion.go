@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	// defaultMessageDeleteWindow applies when no delete window is configured.
+	defaultMessageDeleteWindow = 48 * time.Hour
+	// maxBulkDelete caps the messages deleted by one request.
+	maxBulkDelete = 100
+)
+
+// MessagesDeletedUpdate reports deleted messages. For everyone it goes to
+// every member, who drop the content and show a tombstone; for me it goes
+// only to the user's own devices, which drop the messages entirely.
+type MessagesDeletedUpdate struct {
+	ChatID      string   `json:"chat_id"`
+	MessageIDs  []string `json:"message_ids"`
+	ForEveryone bool     `json:"for_everyone"`
+}
+
+// HistoryClearedUpdate tells the user's devices to drop a chat's messages
+// up to Seq.
+type HistoryClearedUpdate struct {
+	ChatID string `json:"chat_id"`
+	Seq    int64  `json:"seq"`
+}
+
+// DeleteMessages deletes messages for the user only or, with forEveryone,
+// for all members. Deleting for everyone wipes the content from the server
+// and leaves a tombstone in the history; the sender can do so within the
+// delete window, and in groups and channels members allowed to delete
+// other members' messages can do so at any time. Either all messages are
+// deleted or none.
+func (s *MessagesService) DeleteMessages(ctx context.Context, userID, chatID ulid.ULID, messageIDs []ulid.ULID, forEveryone bool) error {
+	if len(messageIDs) == 0 || len(messageIDs) > maxBulkDelete {
+		return &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("Delete between 1 and %d messages at a time", maxBulkDelete)}
+	}
+	ids := slices.Clone(messageIDs)
+	slices.SortFunc(ids, func(a, b ulid.ULID) int { return a.Compare(b) })
+	ids = slices.Compact(ids)
+
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return err
+	}
+	msgs, err := s.repo.ListVisibleMessages(ctx, chatID, userID, ids)
+	if err != nil {
+		return err
+	}
+	if len(msgs) != len(ids) {
+		return messageNotFound()
+	}
+
+	if !forEveryone {
+		err := s.updates.InTx(ctx, func(ctx context.Context) error {
+			if err := s.repo.HideMessages(ctx, chatID, userID, ids); err != nil {
+				return err
+			}
+			update := MessagesDeletedUpdate{ChatID: chatID.String(), MessageIDs: ulidStrings(ids)}
+			return s.updates.Publish(ctx, userID, UpdateMessagesDeleted, update)
+		})
+		if err != nil {
+			return err
+		}
+		s.purgeUnreferenced(ctx, chatID, math.MaxInt64, ids)
+		return nil
+	}
+
+	moderator := canModerateMessages(access)
+	deletableAfter := time.Now().Add(-s.deleteWindow())
+	for _, msg := range msgs {
+		if moderator {
+			continue
+		}
+		if msg.SenderID.String != userID.String() {
+			return forbiddenRole("Only the sender and chat admins can delete this message for everyone")
+		}
+		if msg.CreatedAt.Time.Before(deletableAfter) {
+			return &BusinessError{
+				Code:    string(utils.ErrMessageNotDeletable),
+				Message: fmt.Sprintf("Messages can only be deleted for everyone within %s of sending", s.deleteWindow()),
+			}
+		}
+	}
+
+	return s.updates.InTx(ctx, func(ctx context.Context) error {
+		deleted, err := s.repo.DeleteMessagesForEveryone(ctx, chatID, ids)
+		if err != nil {
+			return err
+		}
+		if len(deleted) == 0 {
+			// All of them were tombstones already.
+			return nil
+		}
+		if err := s.publishDeleted(ctx, chatID, deleted); err != nil {
+			return err
+		}
+		if access.Chat.Type == db.ChatTypeDirect {
+			return nil
+		}
+		for _, msg := range deleted {
+			senderID, err := ulid.Parse(msg.SenderID.String)
+			if err != nil || senderID == userID {
+				continue
+			}
+			before := map[string]any{"seq": msg.Seq, "content_type": msg.ContentType}
+			if err := s.chats.recordAudit(ctx, chatID, userID, AuditMessageDeleted, auditTarget{UserID: &senderID, ID: msg.ID}, before, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// publishDeleted tells every member of a chat that messages were deleted
+// for everyone.
+func (s *MessagesService) publishDeleted(ctx context.Context, chatID ulid.ULID, deleted []db.Message) error {
+	update := MessagesDeletedUpdate{ChatID: chatID.String(), MessageIDs: make([]string, len(deleted)), ForEveryone: true}
+	for i, msg := range deleted {
+		update.MessageIDs[i] = msg.ID
+	}
+	return s.updates.PublishToChat(ctx, chatID, UpdateMessagesDeleted, update)
+}
+
+// ClearHistory deletes all current messages of a chat for the user only and
+// returns the seq up to which the history is cleared. Messages sent later
+// show up as usual.
+func (s *MessagesService) ClearHistory(ctx context.Context, userID, chatID ulid.ULID) (int64, error) {
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return 0, err
+	}
+	var seq int64
+	err := s.updates.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if seq, err = s.repo.ClearChatHistory(ctx, chatID, userID); err != nil {
+			return err
+		}
+		return s.updates.Publish(ctx, userID, UpdateHistoryCleared, HistoryClearedUpdate{ChatID: chatID.String(), Seq: seq})
+	})
+	if err != nil {
+		return 0, err
+	}
+	s.purgeUnreferenced(ctx, chatID, seq, nil)
+	return seq, nil
+}
+
+// purgeUnreferenced removes the content of messages that every member has
+// deleted for themselves, since nobody can read them any more. Failures
+// are only logged: the caller's deletion already took effect, and a later
+// history clear in the chat checks the messages again.
+//
+// TODO: Delete attachment objects that no message references any more once
+// attachments are stored; see package storage.
+func (s *MessagesService) purgeUnreferenced(ctx context.Context, chatID ulid.ULID, maxSeq int64, messageIDs []ulid.ULID) {
+	if _, err := s.repo.PurgeUnreferencedMessages(ctx, chatID, maxSeq, messageIDs); err != nil {
+		log.Printf("messages: purging unreferenced messages of chat %s failed: %v", chatID, err)
+	}
+}
+
+func (s *MessagesService) deleteWindow() time.Duration {
+	if s.limits.MessageDeleteWindow > 0 {
+		return s.limits.MessageDeleteWindow
+	}
+	return defaultMessageDeleteWindow
+}
+
+// canModerateMessages reports whether a member may act on other members'
+// messages. Direct chats have no moderators.
+func canModerateMessages(access *ChatAccess) bool {
+	return access.Chat.Type != db.ChatTypeDirect && access.Permissions.Has(PermDeleteMessages)
+}
@@ -1 +1,692 @@
 package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/messenger/backend/internal/config"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	// minCiphertextSize rejects payloads too short to hold an
+	// authenticated ciphertext.
+	minCiphertextSize     = 16
+	defaultMaxMessageSize = 64 << 10
+	// maxRecipientDevices caps the per-device payloads of one message.
+	maxRecipientDevices = 1024
+
+	maxClientMessageIDLength = 64
+
+	defaultHistoryPageSize = 50
+	maxHistoryPageSize     = 100
+
+	// defaultMessageEditWindow applies when no edit window is configured.
+	defaultMessageEditWindow = 48 * time.Hour
+)
+
+// History directions. Before and after exclude the anchor; around centers
+// the page on it and includes it.
+const (
+	HistoryBefore = "before"
+	HistoryAfter  = "after"
+	HistoryAround = "around"
+)
+
+// contentTypePermissions lists the content types a message may declare and
+// the permissions needed to send each. The server never sees the content;
+// the declared type only drives permission checks and previews.
+var contentTypePermissions = map[string]Permission{
+	"text":       PermSendMessages,
+	"location":   PermSendMessages,
+	"contact":    PermSendMessages,
+	"photo":      PermSendMessages | PermSendMedia,
+	"video":      PermSendMessages | PermSendMedia,
+	"audio":      PermSendMessages | PermSendMedia,
+	"voice":      PermSendMessages | PermSendMedia,
+	"video_note": PermSendMessages | PermSendMedia,
+	"file":       PermSendMessages | PermSendMedia,
+	"sticker":    PermSendMessages | PermSendMedia,
+	"gif":        PermSendMessages | PermSendMedia,
+	"poll":       PermSendMessages,
+}
+
+// MessagesService provides business logic for storing and reading
+// end-to-end encrypted messages.
+type MessagesService struct {
+	repo    repos.MessageRepository
+	chats   *ChatsService
+	updates *UpdatesService
+	privacy *PrivacyService
+	limits  config.LimitsConfig
+}
+
+// NewMessagesService creates a new MessagesService.
+func NewMessagesService(repo repos.MessageRepository, chats *ChatsService, updates *UpdatesService, privacy *PrivacyService, limits config.LimitsConfig) *MessagesService {
+	return &MessagesService{repo: repo, chats: chats, updates: updates, privacy: privacy, limits: limits}
+}
+
+// SendMessageParams describes an encrypted message. Ciphertext is the
+// shared (group-key) payload; Recipients carry per-device payloads for
+// chats that encrypt to each device separately. Either may be empty, not
+// both.
+type SendMessageParams struct {
+	SenderDeviceID ulid.ULID
+	ThreadID       *ulid.ULID
+	ContentType    string
+	Ciphertext     []byte
+	Recipients     []repos.DevicePayload
+	// ClientMessageID makes the send idempotent: resending with the same ID
+	// returns the stored message instead of posting it twice.
+	ClientMessageID string
+	// ReplyToID references an earlier message of the same chat.
+	ReplyToID *ulid.ULID
+	// ForwardFrom makes the message a forwarded copy of another one. The
+	// client re-encrypts the content for this chat; the server checks the
+	// source and records where it came from.
+	ForwardFrom *ForwardSource
+	// TTL makes the message disappear that long after it is sent. Zero
+	// uses the chat's timer.
+	TTL time.Duration
+	// ViewOnce deletes the message for each recipient once they viewed it.
+	ViewOnce bool
+	// Poll holds the settings of a poll message.
+	Poll *PollParams
+	// MentionedUserIDs and MentionedUsernames are the plaintext mentions
+	// of the encrypted message. Mentioned members and the sender of the
+	// message replied to get the message in their mentions feed.
+	MentionedUserIDs   []ulid.ULID
+	MentionedUsernames []string
+}
+
+// ForwardSource names the message being forwarded.
+type ForwardSource struct {
+	ChatID    ulid.ULID
+	MessageID ulid.ULID
+}
+
+// HistoryQuery selects a page of a chat's history. Anchor is a message seq
+// or message ID; an empty anchor with HistoryBefore returns the newest
+// messages. ThreadID narrows the history to one thread, and DeviceID picks
+// the reader's per-device payloads.
+type HistoryQuery struct {
+	Direction string
+	Anchor    string
+	ThreadID  *ulid.ULID
+	DeviceID  *ulid.ULID
+	Limit     int
+}
+
+// MessagePage is a page of history in ascending seq order. HasMoreBefore
+// and HasMoreAfter report whether older or newer messages exist beyond it.
+type MessagePage struct {
+	Items         []db.GetMessageForDeviceRow `json:"items"`
+	HasMoreBefore bool                        `json:"has_more_before"`
+	HasMoreAfter  bool                        `json:"has_more_after"`
+}
+
+// EditMessageParams carries the new encrypted content of an edited
+// message, shaped like SendMessageParams.
+type EditMessageParams struct {
+	SenderDeviceID ulid.ULID
+	Ciphertext     []byte
+	Recipients     []repos.DevicePayload
+}
+
+// EditHistory is a message with its earlier versions, oldest first.
+type EditHistory struct {
+	Current  *db.GetMessageForDeviceRow `json:"current"`
+	Versions []db.ListMessageEditsRow   `json:"versions"`
+}
+
+// MessageEditedUpdate tells the chat's members that a message has a new
+// version to fetch.
+type MessageEditedUpdate struct {
+	ChatID    string    `json:"chat_id"`
+	MessageID string    `json:"message_id"`
+	Seq       int64     `json:"seq"`
+	Version   int32     `json:"version"`
+	EditedAt  time.Time `json:"edited_at"`
+}
+
+// NewMessageUpdate announces a stored message to the chat's members. It
+// carries no ciphertext; devices fetch the message they can decrypt.
+type NewMessageUpdate struct {
+	ChatID      string          `json:"chat_id"`
+	MessageID   string          `json:"message_id"`
+	Seq         int64           `json:"seq"`
+	ThreadID    string          `json:"thread_id,omitempty"`
+	ThreadSeq   int64           `json:"thread_seq,omitempty"`
+	SenderID    string          `json:"sender_id"`
+	ContentType string          `json:"content_type"`
+	ReplyToID   string          `json:"reply_to_id,omitempty"`
+	Forwarded   bool            `json:"forwarded,omitempty"`
+	ExpiresAt   *time.Time      `json:"expires_at,omitempty"`
+	ViewOnce    bool            `json:"view_once,omitempty"`
+	Service     json.RawMessage `json:"service,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// SendMessage stores an encrypted message in a chat and announces it to
+// every member. The message gets the chat's next seq, so members can detect
+// gaps; a message in a thread also gets the thread's next seq. The boolean
+// result is false when the message was already sent with the same client
+// message ID and the stored one is returned.
+func (s *MessagesService) SendMessage(ctx context.Context, userID, chatID ulid.ULID, params SendMessageParams) (*db.Message, bool, error) {
+	access, err := s.authorizeSend(ctx, userID, chatID, params)
+	if err != nil {
+		return nil, false, err
+	}
+
+	usernames, err := normalizeMentions(params.MentionedUserIDs, params.MentionedUsernames)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if params.ClientMessageID != "" {
+		existing, err := s.repo.GetMessageByClientID(ctx, chatID, userID, params.ClientMessageID)
+		if err == nil {
+			return replayMessage(existing, params)
+		}
+		if !errors.Is(err, repos.ErrNotFound) {
+			return nil, false, err
+		}
+	}
+
+	if err := s.checkDevices(ctx, userID, chatID, params.SenderDeviceID, params.Recipients); err != nil {
+		return nil, false, err
+	}
+	if params.ReplyToID != nil {
+		if err := s.checkReplyTarget(ctx, userID, chatID, *params.ReplyToID); err != nil {
+			return nil, false, err
+		}
+	}
+	var forward *repos.ForwardOrigin
+	if params.ForwardFrom != nil {
+		if forward, err = s.forwardOrigin(ctx, userID, *params.ForwardFrom, params.ContentType); err != nil {
+			return nil, false, err
+		}
+	}
+
+	// The message and its updates are stored together, so a stored message
+	// is never missing from the members' update logs and a retry can
+	// simply replay it. The slow mode slot is only used up by a send that
+	// is stored.
+	var msg *db.Message
+	err = s.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.chats.claimSlowMode(ctx, access); err != nil {
+			return err
+		}
+		msg, err = s.repo.CreateMessage(ctx, repos.NewMessage{
+			ChatID:             chatID,
+			SenderID:           userID,
+			SenderDeviceID:     params.SenderDeviceID,
+			ThreadID:           params.ThreadID,
+			ContentType:        params.ContentType,
+			Ciphertext:         params.Ciphertext,
+			DevicePayloads:     params.Recipients,
+			ClientMessageID:    params.ClientMessageID,
+			ReplyToID:          params.ReplyToID,
+			Forward:            forward,
+			TTL:                messageTTL(access.Chat, params.TTL),
+			ViewOnce:           params.ViewOnce,
+			Poll:               params.Poll.newPoll(),
+			MentionedUserIDs:   params.MentionedUserIDs,
+			MentionedUsernames: usernames,
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.updates.PublishToChat(ctx, chatID, UpdateNewMessage, newMessageUpdate(msg)); err != nil {
+			return err
+		}
+		if len(params.MentionedUserIDs) > 0 || len(usernames) > 0 || params.ReplyToID != nil {
+			return s.publishMentions(ctx, msg)
+		}
+		return nil
+	})
+	if errors.Is(err, repos.ErrAlreadyExists) {
+		// A concurrent retry stored the message first.
+		existing, err := s.repo.GetMessageByClientID(ctx, chatID, userID, params.ClientMessageID)
+		if err != nil {
+			return nil, false, err
+		}
+		return replayMessage(existing, params)
+	}
+	if errors.Is(err, repos.ErrNotFound) {
+		return nil, false, &BusinessError{Code: string(utils.ErrThreadNotFound), Message: "Thread not found or closed"}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return msg, true, nil
+}
+
+// GetMessage returns a message of a chat the user belongs to. With a
+// deviceID, the payload addressed to that device of the user is included.
+func (s *MessagesService) GetMessage(ctx context.Context, userID, chatID, messageID ulid.ULID, deviceID *ulid.ULID) (*db.GetMessageForDeviceRow, error) {
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return nil, err
+	}
+	msg, err := s.repo.GetMessageForDevice(ctx, chatID, messageID, userID, deviceID)
+	if errors.Is(err, repos.ErrNotFound) {
+		return nil, messageNotFound()
+	}
+	return msg, err
+}
+
+// EditMessage replaces the content of a message the user sent within the
+// edit window. The previous version is kept in the edit history and every
+// member is told to fetch the new one.
+func (s *MessagesService) EditMessage(ctx context.Context, userID, chatID, messageID ulid.ULID, params EditMessageParams) (*db.Message, error) {
+	if err := s.validateEnvelope(SendMessageParams{Ciphertext: params.Ciphertext, Recipients: params.Recipients}); err != nil {
+		return nil, err
+	}
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return nil, err
+	}
+	msg, err := s.repo.GetMessage(ctx, chatID, messageID)
+	if errors.Is(err, repos.ErrNotFound) {
+		return nil, messageNotFound()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if msg.DeletedAt.Valid {
+		return nil, messageNotFound()
+	}
+	if msg.SenderID.String != userID.String() {
+		return nil, &BusinessError{Code: string(utils.ErrForbidden), Message: "Only the sender can edit this message"}
+	}
+	if msg.Service != nil {
+		return nil, &BusinessError{Code: string(utils.ErrMessageNotEditable), Message: "Service messages cannot be edited"}
+	}
+	if msg.ContentType == pollContentType {
+		return nil, &BusinessError{Code: string(utils.ErrMessageNotEditable), Message: "Polls cannot be edited"}
+	}
+	// Senders who lost the permission to post this content cannot edit it.
+	if _, err := s.chats.Authorize(ctx, userID, chatID, contentTypePermissions[msg.ContentType]); err != nil {
+		return nil, err
+	}
+	editableAfter := time.Now().Add(-s.editWindow())
+	if msg.CreatedAt.Time.Before(editableAfter) {
+		return nil, messageNotEditable(s.editWindow())
+	}
+	if err := s.checkDevices(ctx, userID, chatID, params.SenderDeviceID, params.Recipients); err != nil {
+		return nil, err
+	}
+
+	var edited *db.Message
+	err = s.updates.InTx(ctx, func(ctx context.Context) error {
+		edited, err = s.repo.EditMessage(ctx, repos.MessageEdit{
+			ChatID:         chatID,
+			MessageID:      messageID,
+			SenderID:       userID,
+			SenderDeviceID: params.SenderDeviceID,
+			EditableAfter:  editableAfter,
+			Ciphertext:     params.Ciphertext,
+			DevicePayloads: params.Recipients,
+		})
+		if err != nil {
+			return err
+		}
+		update := MessageEditedUpdate{
+			ChatID:    edited.ChatID,
+			MessageID: edited.ID,
+			Seq:       edited.Seq,
+			Version:   edited.Version,
+			EditedAt:  edited.EditedAt.Time,
+		}
+		return s.updates.PublishToChat(ctx, chatID, UpdateMessageEdited, update)
+	})
+	if errors.Is(err, repos.ErrNotFound) {
+		// The window closed between the check and the update.
+		return nil, messageNotEditable(s.editWindow())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return edited, nil
+}
+
+// ListEdits returns the edit history of a message. The sender can always
+// read it; in groups and channels so can members who may delete other
+// members' messages.
+func (s *MessagesService) ListEdits(ctx context.Context, userID, chatID, messageID ulid.ULID, deviceID *ulid.ULID) (*EditHistory, error) {
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := s.repo.GetMessageForDevice(ctx, chatID, messageID, userID, deviceID)
+	if errors.Is(err, repos.ErrNotFound) {
+		return nil, messageNotFound()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if msg.SenderID.String != userID.String() && !canModerateMessages(access) {
+		return nil, forbiddenRole("Only the sender and chat admins can see the edit history")
+	}
+	versions, err := s.repo.ListMessageEdits(ctx, messageID, userID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	return &EditHistory{Current: msg, Versions: versions}, nil
+}
+
+func (s *MessagesService) editWindow() time.Duration {
+	if s.limits.MessageEditWindow > 0 {
+		return s.limits.MessageEditWindow
+	}
+	return defaultMessageEditWindow
+}
+
+// ListHistory returns a page of a chat's messages before, after or around
+// an anchor.
+func (s *MessagesService) ListHistory(ctx context.Context, userID, chatID ulid.ULID, query HistoryQuery) (*MessagePage, error) {
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return nil, err
+	}
+	limit := clampPageSize(query.Limit, defaultHistoryPageSize, maxHistoryPageSize)
+	list := repos.MessageListQuery{ChatID: chatID, UserID: userID, DeviceID: query.DeviceID, ThreadID: query.ThreadID}
+
+	var anchor int64 = math.MaxInt64
+	if query.Anchor != "" {
+		var err error
+		if anchor, err = s.resolveAnchor(ctx, chatID, query.Anchor); err != nil {
+			return nil, err
+		}
+	} else if query.Direction != HistoryBefore {
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: "An anchor is required to page " + query.Direction + " a message"}
+	}
+
+	page := &MessagePage{}
+	switch query.Direction {
+	case HistoryBefore:
+		older, hasMore, err := s.listBefore(ctx, list, anchor, limit)
+		if err != nil {
+			return nil, err
+		}
+		page.Items, page.HasMoreBefore = older, hasMore
+	case HistoryAfter:
+		newer, hasMore, err := s.listAfter(ctx, list, anchor, limit)
+		if err != nil {
+			return nil, err
+		}
+		page.Items, page.HasMoreAfter = newer, hasMore
+	case HistoryAround:
+		older, hasMoreBefore, err := s.listBefore(ctx, list, anchor, limit/2)
+		if err != nil {
+			return nil, err
+		}
+		newer, hasMoreAfter, err := s.listAfter(ctx, list, anchor-1, limit-limit/2)
+		if err != nil {
+			return nil, err
+		}
+		page.Items = append(older, newer...)
+		page.HasMoreBefore, page.HasMoreAfter = hasMoreBefore, hasMoreAfter
+	default:
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: "Direction must be before, after or around"}
+	}
+	return page, nil
+}
+
+// listBefore returns up to limit messages below seq in ascending order.
+func (s *MessagesService) listBefore(ctx context.Context, query repos.MessageListQuery, seq int64, limit int) ([]db.GetMessageForDeviceRow, bool, error) {
+	if limit == 0 {
+		return []db.GetMessageForDeviceRow{}, false, nil
+	}
+	rows, err := s.repo.ListMessagesBefore(ctx, query, seq, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+	slices.Reverse(rows)
+	return rows, hasMore, nil
+}
+
+// listAfter returns up to limit messages above seq in ascending order.
+func (s *MessagesService) listAfter(ctx context.Context, query repos.MessageListQuery, seq int64, limit int) ([]db.GetMessageForDeviceRow, bool, error) {
+	rows, err := s.repo.ListMessagesAfter(ctx, query, seq, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+	return rows, hasMore, nil
+}
+
+// resolveAnchor turns a seq or message ID into a seq of the chat.
+func (s *MessagesService) resolveAnchor(ctx context.Context, chatID ulid.ULID, anchor string) (int64, error) {
+	if seq, err := strconv.ParseInt(anchor, 10, 64); err == nil {
+		if seq < 0 {
+			return 0, &BusinessError{Code: string(utils.ErrValidation), Message: "Anchor seq must not be negative"}
+		}
+		return seq, nil
+	}
+	messageID, err := ulid.Parse(anchor)
+	if err != nil {
+		return 0, &BusinessError{Code: string(utils.ErrValidation), Message: "Anchor must be a message seq or ID"}
+	}
+	msg, err := s.repo.GetMessage(ctx, chatID, messageID)
+	if errors.Is(err, repos.ErrNotFound) {
+		return 0, messageNotFound()
+	}
+	if err != nil {
+		return 0, err
+	}
+	return msg.Seq, nil
+}
+
+// checkDevices verifies that the sender device is an active device of the
+// sender and that every recipient is an active device of a chat member.
+func (s *MessagesService) checkDevices(ctx context.Context, userID, chatID, senderDeviceID ulid.ULID, recipients []repos.DevicePayload) error {
+	if _, err := s.repo.GetActiveUserDevice(ctx, userID, senderDeviceID); err != nil {
+		if errors.Is(err, repos.ErrNotFound) {
+			return &BusinessError{Code: string(utils.ErrAuthDeviceRevoked), Message: "Sender device is unknown or revoked"}
+		}
+		return err
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+	ids := make([]ulid.ULID, len(recipients))
+	for i, r := range recipients {
+		ids[i] = r.DeviceID
+	}
+	valid, err := s.repo.FilterMemberDevices(ctx, chatID, ids)
+	if err != nil {
+		return err
+	}
+	if len(valid) != len(ids) {
+		return invalidCiphertext("Every recipient must be an active device of a chat member")
+	}
+	return nil
+}
+
+// authorizeSend validates a message and checks that the user may post it
+// in the chat.
+func (s *MessagesService) authorizeSend(ctx context.Context, userID, chatID ulid.ULID, params SendMessageParams) (*ChatAccess, error) {
+	want, ok := contentTypePermissions[params.ContentType]
+	if !ok {
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: "Unknown content type: " + params.ContentType}
+	}
+	if len(params.ClientMessageID) > maxClientMessageIDLength {
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("Client message ID must be at most %d characters", maxClientMessageIDLength)}
+	}
+	if err := s.validateEnvelope(params); err != nil {
+		return nil, err
+	}
+	if params.TTL != 0 {
+		if err := validateMessageTTL(params.TTL); err != nil {
+			return nil, err
+		}
+	}
+	if err := validatePoll(params.ContentType, params.Poll); err != nil {
+		return nil, err
+	}
+
+	access, err := s.chats.Authorize(ctx, userID, chatID, want)
+	if err != nil {
+		return nil, err
+	}
+	if params.Poll != nil && !params.Poll.Anonymous && access.Chat.Type == db.ChatTypeChannel {
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: "Polls in channels must be anonymous"}
+	}
+	if err := checkDirectBlock(ctx, s.chats.contacts, access.Chat, userID); err != nil {
+		return nil, err
+	}
+	return access, nil
+}
+
+// validateEnvelope checks the sizes and recipients of a message before
+// anything is stored.
+func (s *MessagesService) validateEnvelope(params SendMessageParams) error {
+	if len(params.Ciphertext) == 0 && len(params.Recipients) == 0 {
+		return invalidCiphertext("A message needs a ciphertext or per-device payloads")
+	}
+	if len(params.Recipients) > maxRecipientDevices {
+		return invalidCiphertext(fmt.Sprintf("A message can address at most %d devices", maxRecipientDevices))
+	}
+	if err := s.checkCiphertext(params.Ciphertext, len(params.Recipients) > 0); err != nil {
+		return err
+	}
+	seen := make(map[ulid.ULID]bool, len(params.Recipients))
+	for _, r := range params.Recipients {
+		if seen[r.DeviceID] {
+			return invalidCiphertext("Each device can be addressed only once")
+		}
+		seen[r.DeviceID] = true
+		if err := s.checkCiphertext(r.Ciphertext, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MessagesService) checkCiphertext(blob []byte, optional bool) error {
+	if optional && len(blob) == 0 {
+		return nil
+	}
+	if len(blob) < minCiphertextSize {
+		return invalidCiphertext("Ciphertext is too short")
+	}
+	if int64(len(blob)) > s.maxMessageSize() {
+		return invalidCiphertext(fmt.Sprintf("Ciphertext exceeds %d bytes", s.maxMessageSize()))
+	}
+	return nil
+}
+
+func (s *MessagesService) maxMessageSize() int64 {
+	if s.limits.MaxMessageSize <= 0 {
+		return defaultMaxMessageSize
+	}
+	return s.limits.MaxMessageSize
+}
+
+// replayMessage returns a message stored under the client message ID of a
+// retried send, which must describe the same message.
+func replayMessage(msg *db.Message, params SendMessageParams) (*db.Message, bool, error) {
+	threadID, replyToID := "", ""
+	if params.ThreadID != nil {
+		threadID = params.ThreadID.String()
+	}
+	if params.ReplyToID != nil {
+		replyToID = params.ReplyToID.String()
+	}
+	// Edited and deleted messages no longer hold the ciphertext first sent.
+	sameContent := msg.Version > 1 || msg.DeletedAt.Valid || bytes.Equal(msg.Ciphertext, params.Ciphertext)
+	// A deleted reply target clears reply_to_id, so only a set one can differ.
+	sameReply := !msg.ReplyToID.Valid || msg.ReplyToID.String == replyToID
+	if msg.ContentType != params.ContentType || msg.ThreadID.String != threadID || !sameContent || !sameReply {
+		return nil, false, &BusinessError{Code: string(utils.ErrConflict), Message: "This client message ID was already used for a different message"}
+	}
+	return msg, false, nil
+}
+
+func messageNotEditable(window time.Duration) *BusinessError {
+	return &BusinessError{
+		Code:    string(utils.ErrMessageNotEditable),
+		Message: fmt.Sprintf("Messages can only be edited within %s of sending", window),
+	}
+}
+
+func newMessageUpdate(msg *db.Message) NewMessageUpdate {
+	var expiresAt *time.Time
+	if msg.ExpiresAt.Valid {
+		expiresAt = &msg.ExpiresAt.Time
+	}
+	return NewMessageUpdate{
+		ChatID:      msg.ChatID,
+		MessageID:   msg.ID,
+		Seq:         msg.Seq,
+		ThreadID:    msg.ThreadID.String,
+		ThreadSeq:   msg.ThreadSeq.Int64,
+		SenderID:    msg.SenderID.String,
+		ContentType: msg.ContentType,
+		ReplyToID:   msg.ReplyToID.String,
+		Forwarded:   msg.ForwardDate.Valid,
+		ExpiresAt:   expiresAt,
+		ViewOnce:    msg.ViewOnce,
+		Service:     msg.Service,
+		CreatedAt:   msg.CreatedAt.Time,
+	}
+}
+
+// checkDirectBlock rejects interacting with the peer of a direct chat when
+// either side has blocked the other.
+func checkDirectBlock(ctx context.Context, contacts repos.ContactRepository, chat *db.Chat, userID ulid.ULID) error {
+	peerID, ok := directPeer(chat, userID)
+	if !ok {
+		return nil
+	}
+	blocked, err := contacts.IsBlocked(ctx, userID, peerID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return &BusinessError{Code: string(utils.ErrPeerBlocked), Message: "You cannot message a user you have a block with"}
+	}
+	return nil
+}
+
+// directPeer returns the other member of a direct chat.
+func directPeer(chat *db.Chat, userID ulid.ULID) (ulid.ULID, bool) {
+	if chat.Type != db.ChatTypeDirect || !chat.DirectKey.Valid {
+		return ulid.ULID{}, false
+	}
+	a, b, ok := strings.Cut(chat.DirectKey.String, ":")
+	if !ok {
+		return ulid.ULID{}, false
+	}
+	peer := a
+	if a == userID.String() {
+		peer = b
+	}
+	id, err := ulid.Parse(peer)
+	return id, err == nil
+}
+
+func invalidCiphertext(message string) *BusinessError {
+	return &BusinessError{Code: string(utils.ErrInvalidCiphertext), Message: message}
+}
+
+func messageNotFound() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrMessageNotFound), Message: "Message not found"}
+}
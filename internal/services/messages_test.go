@@ -0,0 +1,408 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/messenger/backend/internal/config"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/storage/postgres"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupMessagesService(chats *ChatsService) *MessagesService {
+	privacy := NewPrivacyService(postgres.NewPostgresPrivacyRepository(testQueries), chats.updates)
+	return NewMessagesService(postgres.NewPostgresMessageRepository(testQueries), chats, chats.updates, privacy, config.LimitsConfig{MaxMessageSize: 1024})
+}
+
+// createDevice registers a device for userID directly in the database.
+func createDevice(t *testing.T, ctx context.Context, userID ulid.ULID) ulid.ULID {
+	t.Helper()
+	id := ulid.Make()
+	_, err := testPool.Exec(ctx, `INSERT INTO devices (id, user_id, name) VALUES ($1, $2, 'test')`, id.String(), userID.String())
+	require.NoError(t, err)
+	return id
+}
+
+func testCiphertext() []byte {
+	return bytes.Repeat([]byte{0xAB}, 32)
+}
+
+func TestSendMessage_DirectChat_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	notifier := &recordingNotifier{}
+	chats.updates = NewUpdatesService(postgres.NewPostgresUpdateRepository(testQueries), testDB, notifier)
+	service := setupMessagesService(chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	aliceID := createUser(t, ctx, "alice")
+	bobID := createUser(t, ctx, "bob")
+	aliceDevice := createDevice(t, ctx, aliceID)
+	bobDevice := createDevice(t, ctx, bobID)
+	chat, _, err := chats.GetOrCreateDirectChat(ctx, aliceID, bobID)
+	require.NoError(t, err)
+	chatID := ulid.MustParse(chat.ID)
+
+	send := func(params SendMessageParams) error {
+		_, _, err := service.SendMessage(ctx, aliceID, chatID, params)
+		return err
+	}
+	requireBusinessCode(t, send(SendMessageParams{SenderDeviceID: aliceDevice, ContentType: "text", Ciphertext: []byte("short")}), "INVALID_CIPHERTEXT")
+	requireBusinessCode(t, send(SendMessageParams{SenderDeviceID: aliceDevice, ContentType: "text", Ciphertext: make([]byte, 2048)}), "INVALID_CIPHERTEXT")
+	requireBusinessCode(t, send(SendMessageParams{SenderDeviceID: aliceDevice, ContentType: "hologram", Ciphertext: testCiphertext()}), "VALIDATION_ERROR")
+	requireBusinessCode(t, send(SendMessageParams{SenderDeviceID: bobDevice, ContentType: "text", Ciphertext: testCiphertext()}), "AUTH_DEVICE_REVOKED")
+	// Payloads may only address devices of the chat's members.
+	strangerDevice := createDevice(t, ctx, createUser(t, ctx, "stranger"))
+	requireBusinessCode(t, send(SendMessageParams{
+		SenderDeviceID: aliceDevice,
+		ContentType:    "text",
+		Recipients:     []repos.DevicePayload{{DeviceID: strangerDevice, Ciphertext: testCiphertext()}},
+	}), "INVALID_CIPHERTEXT")
+
+	for i := 0; i < 2; i++ {
+		msg, _, err := service.SendMessage(ctx, aliceID, chatID, SendMessageParams{
+			SenderDeviceID: aliceDevice,
+			ContentType:    "text",
+			Recipients: []repos.DevicePayload{
+				{DeviceID: aliceDevice, Ciphertext: testCiphertext()},
+				{DeviceID: bobDevice, Ciphertext: bytes.Repeat([]byte{byte(i)}, 20)},
+			},
+		})
+		require.NoError(t, err)
+		assert.EqualValues(t, i+1, msg.Seq)
+	}
+	// Both members were told about both messages.
+	assert.Len(t, notifier.updates[aliceID], 2)
+	require.Len(t, notifier.updates[bobID], 2)
+	assert.Equal(t, UpdateNewMessage, notifier.updates[bobID][1].Type)
+
+	page, err := chats.ListChats(ctx, bobID, ChatListOptions{}, "", 10)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+
+	var lastID string
+	require.NoError(t, testPool.QueryRow(ctx, `SELECT id FROM messages WHERE chat_id = $1 AND seq = 2`, chat.ID).Scan(&lastID))
+	last := ulid.MustParse(lastID)
+	got, err := service.GetMessage(ctx, bobID, chatID, last, &bobDevice)
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte{1}, 20), got.DeviceCiphertext)
+	// Another user's device never receives someone else's payload.
+	got, err = service.GetMessage(ctx, bobID, chatID, last, &aliceDevice)
+	require.NoError(t, err)
+	assert.Nil(t, got.DeviceCiphertext)
+
+	require.NoError(t, setupRealService().BlockPeer(ctx, bobID, aliceID))
+	requireBusinessCode(t, send(SendMessageParams{SenderDeviceID: aliceDevice, ContentType: "text", Ciphertext: testCiphertext()}), "PEER_BLOCKED")
+}
+
+func TestSendMessage_MediaPermission_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	service := setupMessagesService(chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	memberID := createUser(t, ctx, "member")
+	memberDevice := createDevice(t, ctx, memberID)
+	group, err := chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{memberID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+
+	require.NoError(t, chats.SetDefaultPermissions(ctx, ownerID, groupID, PermSendMessages, PermAll))
+	_, _, err = service.SendMessage(ctx, memberID, groupID, SendMessageParams{SenderDeviceID: memberDevice, ContentType: "photo", Ciphertext: testCiphertext()})
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+	_, _, err = service.SendMessage(ctx, memberID, groupID, SendMessageParams{SenderDeviceID: memberDevice, ContentType: "text", Ciphertext: testCiphertext()})
+	require.NoError(t, err)
+}
+
+func TestSendMessage_ClientMessageIDIsIdempotent_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	service := setupMessagesService(chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	aliceID := createUser(t, ctx, "alice")
+	bobID := createUser(t, ctx, "bob")
+	aliceDevice := createDevice(t, ctx, aliceID)
+	chat, _, err := chats.GetOrCreateDirectChat(ctx, aliceID, bobID)
+	require.NoError(t, err)
+	chatID := ulid.MustParse(chat.ID)
+
+	params := SendMessageParams{SenderDeviceID: aliceDevice, ContentType: "text", Ciphertext: testCiphertext(), ClientMessageID: "c-1"}
+	first, created, err := service.SendMessage(ctx, aliceID, chatID, params)
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	again, created, err := service.SendMessage(ctx, aliceID, chatID, params)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, first.ID, again.ID)
+	assert.EqualValues(t, 1, again.Seq)
+
+	params.Ciphertext = bytes.Repeat([]byte{0xCD}, 32)
+	_, _, err = service.SendMessage(ctx, aliceID, chatID, params)
+	requireBusinessCode(t, err, "CONFLICT")
+}
+
+func TestListHistory_BeforeAfterAround_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	service := setupMessagesService(chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	aliceID := createUser(t, ctx, "alice")
+	aliceDevice := createDevice(t, ctx, aliceID)
+	chat, _, err := chats.GetOrCreateSavedMessages(ctx, aliceID)
+	require.NoError(t, err)
+	chatID := ulid.MustParse(chat.ID)
+
+	ids := make([]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		msg, _, err := service.SendMessage(ctx, aliceID, chatID, SendMessageParams{SenderDeviceID: aliceDevice, ContentType: "text", Ciphertext: testCiphertext()})
+		require.NoError(t, err)
+		ids = append(ids, msg.ID)
+	}
+	seqs := func(page *MessagePage) []int64 {
+		out := make([]int64, len(page.Items))
+		for i, m := range page.Items {
+			out[i] = m.Seq
+		}
+		return out
+	}
+
+	page, err := service.ListHistory(ctx, aliceID, chatID, HistoryQuery{Direction: HistoryBefore, Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{8, 9, 10}, seqs(page))
+	assert.True(t, page.HasMoreBefore)
+
+	page, err = service.ListHistory(ctx, aliceID, chatID, HistoryQuery{Direction: HistoryBefore, Anchor: "3", Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, seqs(page))
+	assert.False(t, page.HasMoreBefore)
+
+	page, err = service.ListHistory(ctx, aliceID, chatID, HistoryQuery{Direction: HistoryAfter, Anchor: "7", Limit: 5})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{8, 9, 10}, seqs(page))
+	assert.False(t, page.HasMoreAfter)
+
+	// Around a message ID, for jumping to a reply.
+	page, err = service.ListHistory(ctx, aliceID, chatID, HistoryQuery{Direction: HistoryAround, Anchor: ids[4], Limit: 4})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3, 4, 5, 6}, seqs(page))
+	assert.True(t, page.HasMoreBefore)
+	assert.True(t, page.HasMoreAfter)
+
+	_, err = service.ListHistory(ctx, aliceID, chatID, HistoryQuery{Direction: HistoryAround, Anchor: ulid.Make().String()})
+	requireBusinessCode(t, err, "MESSAGE_NOT_FOUND")
+	_, err = service.ListHistory(ctx, aliceID, chatID, HistoryQuery{Direction: HistoryAfter})
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+}
+
+func TestEditMessage_HistoryAndWindow_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	notifier := &recordingNotifier{}
+	chats.updates = NewUpdatesService(postgres.NewPostgresUpdateRepository(testQueries), testDB, notifier)
+	service := setupMessagesService(chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	aliceID := createUser(t, ctx, "alice")
+	bobID := createUser(t, ctx, "bob")
+	aliceDevice := createDevice(t, ctx, aliceID)
+	bobDevice := createDevice(t, ctx, bobID)
+	group, err := chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{aliceID, bobID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+
+	original := testCiphertext()
+	msg, _, err := service.SendMessage(ctx, aliceID, groupID, SendMessageParams{SenderDeviceID: aliceDevice, ContentType: "text", Ciphertext: original})
+	require.NoError(t, err)
+	messageID := ulid.MustParse(msg.ID)
+
+	edit := EditMessageParams{SenderDeviceID: aliceDevice, Ciphertext: bytes.Repeat([]byte{0xEE}, 32)}
+	_, err = service.EditMessage(ctx, bobID, groupID, messageID, EditMessageParams{SenderDeviceID: bobDevice, Ciphertext: testCiphertext()})
+	requireBusinessCode(t, err, "FORBIDDEN")
+
+	edited, err := service.EditMessage(ctx, aliceID, groupID, messageID, edit)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, edited.Version)
+	assert.True(t, edited.EditedAt.Valid)
+	assert.Equal(t, edit.Ciphertext, edited.Ciphertext)
+	require.NotEmpty(t, notifier.updates[bobID])
+	assert.Equal(t, UpdateMessageEdited, notifier.updates[bobID][len(notifier.updates[bobID])-1].Type)
+
+	history, err := service.ListEdits(ctx, aliceID, groupID, messageID, nil)
+	require.NoError(t, err)
+	require.Len(t, history.Versions, 1)
+	assert.EqualValues(t, 1, history.Versions[0].Version)
+	assert.Equal(t, original, history.Versions[0].Ciphertext)
+	// The owner moderates the group; plain members do not see others' history.
+	_, err = service.ListEdits(ctx, ownerID, groupID, messageID, nil)
+	require.NoError(t, err)
+	_, err = service.ListEdits(ctx, bobID, groupID, messageID, nil)
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+
+	_, err = testPool.Exec(ctx, `UPDATE messages SET created_at = now() - interval '3 days' WHERE id = $1`, msg.ID)
+	require.NoError(t, err)
+	_, err = service.EditMessage(ctx, aliceID, groupID, messageID, edit)
+	requireBusinessCode(t, err, "MESSAGE_NOT_EDITABLE")
+}
+
+func TestDeleteMessages_ScopesAndClearHistory_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	notifier := &recordingNotifier{}
+	chats.updates = NewUpdatesService(postgres.NewPostgresUpdateRepository(testQueries), testDB, notifier)
+	service := setupMessagesService(chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	aliceID := createUser(t, ctx, "alice")
+	bobID := createUser(t, ctx, "bob")
+	aliceDevice := createDevice(t, ctx, aliceID)
+	bobDevice := createDevice(t, ctx, bobID)
+	group, err := chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{aliceID, bobID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+
+	send := func(userID, deviceID ulid.ULID) ulid.ULID {
+		msg, _, err := service.SendMessage(ctx, userID, groupID, SendMessageParams{
+			SenderDeviceID: deviceID,
+			ContentType:    "text",
+			Ciphertext:     testCiphertext(),
+			Recipients:     []repos.DevicePayload{{DeviceID: bobDevice, Ciphertext: testCiphertext()}},
+		})
+		require.NoError(t, err)
+		return ulid.MustParse(msg.ID)
+	}
+	first := send(aliceID, aliceDevice)
+	second := send(aliceID, aliceDevice)
+	third := send(bobID, bobDevice)
+
+	// For me: only Bob loses the message.
+	require.NoError(t, service.DeleteMessages(ctx, bobID, groupID, []ulid.ULID{first}, false))
+	_, err = service.GetMessage(ctx, bobID, groupID, first, nil)
+	requireBusinessCode(t, err, "MESSAGE_NOT_FOUND")
+	_, err = service.GetMessage(ctx, aliceID, groupID, first, nil)
+	require.NoError(t, err)
+	assert.Equal(t, UpdateMessagesDeleted, notifier.updates[bobID][len(notifier.updates[bobID])-1].Type)
+
+	// For everyone: Bob cannot delete Alice's message, Alice can.
+	err = service.DeleteMessages(ctx, bobID, groupID, []ulid.ULID{second}, true)
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+	require.NoError(t, service.DeleteMessages(ctx, aliceID, groupID, []ulid.ULID{second}, true))
+	tombstone, err := service.GetMessage(ctx, bobID, groupID, second, &bobDevice)
+	require.NoError(t, err)
+	assert.True(t, tombstone.DeletedAt.Valid)
+	assert.Empty(t, tombstone.Ciphertext)
+	assert.Nil(t, tombstone.DeviceCiphertext)
+	var payloads int
+	require.NoError(t, testPool.QueryRow(ctx, `SELECT count(*) FROM message_device_payloads WHERE message_id = $1`, second.String()).Scan(&payloads))
+	assert.Zero(t, payloads)
+
+	// Past the window only admins can delete for everyone, and that is audited.
+	_, err = testPool.Exec(ctx, `UPDATE messages SET created_at = now() - interval '3 days' WHERE id = $1`, third.String())
+	require.NoError(t, err)
+	err = service.DeleteMessages(ctx, bobID, groupID, []ulid.ULID{third}, true)
+	requireBusinessCode(t, err, "MESSAGE_NOT_DELETABLE")
+	require.NoError(t, service.DeleteMessages(ctx, ownerID, groupID, []ulid.ULID{third}, true))
+	log, err := chats.ListAuditLog(ctx, ownerID, groupID, AuditLogFilter{Actions: []string{AuditMessageDeleted}}, "", 10)
+	require.NoError(t, err)
+	require.Len(t, log.Items, 1)
+	assert.Equal(t, third.String(), log.Items[0].TargetID)
+
+	err = service.DeleteMessages(ctx, aliceID, groupID, []ulid.ULID{ulid.Make()}, false)
+	requireBusinessCode(t, err, "MESSAGE_NOT_FOUND")
+
+	// Once every member has cleared the history, nothing references the
+	// remaining content and it is purged.
+	for _, userID := range []ulid.ULID{ownerID, aliceID, bobID} {
+		seq, err := service.ClearHistory(ctx, userID, groupID)
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, seq)
+	}
+	page, err := service.ListHistory(ctx, aliceID, groupID, HistoryQuery{Direction: HistoryBefore})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+	var remaining int
+	require.NoError(t, testPool.QueryRow(ctx, `SELECT count(*) FROM messages WHERE chat_id = $1 AND deleted_at IS NULL`, group.ID).Scan(&remaining))
+	assert.Zero(t, remaining)
+
+	send(aliceID, aliceDevice)
+	page, err = service.ListHistory(ctx, bobID, groupID, HistoryQuery{Direction: HistoryBefore})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.EqualValues(t, 4, page.Items[0].Seq)
+}
+
+func TestSendMessage_RepliesAndForwards_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	service := setupMessagesService(chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	aliceID := createUser(t, ctx, "alice")
+	bobID := createUser(t, ctx, "bob")
+	aliceDevice := createDevice(t, ctx, aliceID)
+	bobDevice := createDevice(t, ctx, bobID)
+	group, err := chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{aliceID, bobID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+	direct, _, err := chats.GetOrCreateDirectChat(ctx, aliceID, bobID)
+	require.NoError(t, err)
+	directID := ulid.MustParse(direct.ID)
+	saved, _, err := chats.GetOrCreateSavedMessages(ctx, aliceID)
+	require.NoError(t, err)
+	savedID := ulid.MustParse(saved.ID)
+
+	original, _, err := service.SendMessage(ctx, bobID, groupID, SendMessageParams{SenderDeviceID: bobDevice, ContentType: "text", Ciphertext: testCiphertext()})
+	require.NoError(t, err)
+	originalID := ulid.MustParse(original.ID)
+
+	// Replies must target a visible message of the same chat.
+	_, _, err = service.SendMessage(ctx, aliceID, directID, SendMessageParams{SenderDeviceID: aliceDevice, ContentType: "text", Ciphertext: testCiphertext(), ReplyToID: &originalID})
+	requireBusinessCode(t, err, "MESSAGE_NOT_FOUND")
+	reply, _, err := service.SendMessage(ctx, aliceID, groupID, SendMessageParams{SenderDeviceID: aliceDevice, ContentType: "text", Ciphertext: testCiphertext(), ReplyToID: &originalID})
+	require.NoError(t, err)
+	assert.Equal(t, original.ID, reply.ReplyToID.String)
+
+	forward := func(chatID ulid.ULID, source ForwardSource, contentType string) (*db.Message, error) {
+		msg, _, err := service.SendMessage(ctx, aliceID, chatID, SendMessageParams{SenderDeviceID: aliceDevice, ContentType: contentType, Ciphertext: testCiphertext(), ForwardFrom: &source})
+		return msg, err
+	}
+	source := ForwardSource{ChatID: groupID, MessageID: originalID}
+	_, err = forward(directID, source, "image")
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+
+	// Forwards are attributed to the original sender and chat.
+	copied, err := forward(directID, source, "text")
+	require.NoError(t, err)
+	assert.Equal(t, bobID.String(), copied.ForwardFromUserID.String)
+	assert.Equal(t, group.ID, copied.ForwardFromChatID.String)
+	assert.Equal(t, original.ID, copied.ForwardFromMessageID.String)
+	assert.Equal(t, original.CreatedAt.Time.UnixMicro(), copied.ForwardDate.Time.UnixMicro())
+
+	// Forwarding a forward keeps the original attribution.
+	again, err := forward(savedID, ForwardSource{ChatID: directID, MessageID: ulid.MustParse(copied.ID)}, "text")
+	require.NoError(t, err)
+	assert.Equal(t, group.ID, again.ForwardFromChatID.String)
+	assert.Equal(t, original.ID, again.ForwardFromMessageID.String)
+
+	// The sender can hide forwarding attribution.
+	hide := false
+	_, err = service.privacy.UpdatePrivacySettings(ctx, bobID, PrivacySettingsParams{ForwardAttribution: &hide})
+	require.NoError(t, err)
+	hidden, err := forward(savedID, source, "text")
+	require.NoError(t, err)
+	assert.True(t, hidden.ForwardDate.Valid)
+	assert.False(t, hidden.ForwardFromUserID.Valid)
+	assert.False(t, hidden.ForwardFromChatID.Valid)
+	assert.False(t, hidden.ForwardFromMessageID.Valid)
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+// slowModeIntervals are the slow mode settings a group can choose, in seconds.
+var slowModeIntervals = map[int]bool{0: true, 10: true, 30: true, 60: true, 300: true, 900: true, 3600: true}
+
+// BanPage is one page of a chat's active bans.
+type BanPage struct {
+	Items      []db.ListChatBansRow `json:"items"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// BanMember bans a user from a group or channel until the given time, or
+// permanently when until is nil. Members are removed, pending join requests
+// are declined, and the user cannot come back through invite links while the
+// ban lasts. Removing a member without a ban is a kick (RemoveMember).
+func (s *ChatsService) BanMember(ctx context.Context, actorID, chatID, userID ulid.ULID, until *time.Time) (*db.ChatBan, error) {
+	actor, err := s.getModerator(ctx, chatID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if actorID == userID {
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: "You cannot ban yourself"}
+	}
+	target, err := s.repo.GetChatMember(ctx, chatID, userID)
+	switch {
+	case err == nil:
+		if !outranks(actor.Role, target.Role) {
+			return nil, forbiddenRole("You cannot ban this member")
+		}
+	case errors.Is(err, repos.ErrNotFound):
+		// Users can be banned before they ever join.
+		if _, err := s.repo.GetUser(ctx, userID); err != nil {
+			if errors.Is(err, repos.ErrNotFound) {
+				return nil, &BusinessError{Code: string(utils.ErrUserNotFound), Message: "User not found"}
+			}
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	var untilAt sql.NullTime
+	if until != nil {
+		if !until.After(time.Now()) {
+			return nil, &BusinessError{Code: string(utils.ErrValidation), Message: "Ban end must be in the future"}
+		}
+		untilAt = sql.NullTime{Time: *until, Valid: true}
+	}
+	var ban *db.ChatBan
+	err = s.updates.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if ban, err = s.repo.BanChatMember(ctx, chatID, userID, actorID, untilAt); err != nil {
+			return err
+		}
+		if target != nil {
+			chat, err := s.repo.GetChat(ctx, chatID)
+			if err != nil {
+				return err
+			}
+			if err := s.publishMemberChange(ctx, chat, userID, MemberBanned, ""); err != nil {
+				return err
+			}
+		}
+		return s.recordAudit(ctx, chatID, actorID, AuditMemberBanned, targetUser(userID), nil, map[string]any{"until": until})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ban, nil
+}
+
+// UnbanMember lifts a ban. The user is not re-added to the chat.
+func (s *ChatsService) UnbanMember(ctx context.Context, actorID, chatID, userID ulid.ULID) error {
+	if _, err := s.getModerator(ctx, chatID, actorID); err != nil {
+		return err
+	}
+	err := s.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.UnbanChatMember(ctx, chatID, userID); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, chatID, actorID, AuditMemberUnbanned, targetUser(userID), nil, nil)
+	})
+	if errors.Is(err, repos.ErrNotFound) {
+		return &BusinessError{Code: string(utils.ErrNotFound), Message: "User is not banned"}
+	}
+	return err
+}
+
+// ListBans returns a page of the chat's active bans, oldest first.
+func (s *ChatsService) ListBans(ctx context.Context, actorID, chatID ulid.ULID, cursor string, limit int) (*BanPage, error) {
+	if _, err := s.getModerator(ctx, chatID, actorID); err != nil {
+		return nil, err
+	}
+	limit = clampPageSize(limit, defaultChatPageSize, maxChatPageSize)
+
+	var after *repos.MemberCursor
+	if cursor != "" {
+		parts, err := utils.DecodeCursor(cursor, 2)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		micros, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		after = &repos.MemberCursor{JoinedAt: time.UnixMicro(micros), UserID: parts[1]}
+	}
+
+	rows, err := s.repo.ListChatBans(ctx, chatID, after, int32(limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	page := &BanPage{Items: rows}
+	if len(rows) > limit {
+		page.Items = rows[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = utils.EncodeCursor(strconv.FormatInt(last.CreatedAt.Time.UnixMicro(), 10), last.UserID)
+	}
+	return page, nil
+}
+
+// RestrictMember makes a member read-only until the given time. A nil until
+// lifts the restriction.
+func (s *ChatsService) RestrictMember(ctx context.Context, actorID, chatID, userID ulid.ULID, until *time.Time) error {
+	actor, err := s.getModerator(ctx, chatID, actorID)
+	if err != nil {
+		return err
+	}
+	target, err := s.getTargetMember(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+	if !outranks(actor.Role, target.Role) {
+		return forbiddenRole("You cannot restrict this member")
+	}
+
+	var untilAt sql.NullTime
+	if until != nil {
+		if !until.After(time.Now()) {
+			return &BusinessError{Code: string(utils.ErrValidation), Message: "Restriction end must be in the future"}
+		}
+		untilAt = sql.NullTime{Time: *until, Valid: true}
+	}
+	var before *time.Time
+	if isRestricted(target) {
+		before = &target.RestrictedUntil.Time
+	}
+	err = s.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.RestrictChatMember(ctx, chatID, userID, untilAt); err != nil {
+			return err
+		}
+		s.membersChanged(ctx, chatID)
+		return s.recordAudit(ctx, chatID, actorID, AuditMemberRestricted, targetUser(userID),
+			map[string]any{"until": before}, map[string]any{"until": until})
+	})
+	if errors.Is(err, repos.ErrNotFound) {
+		return memberNotFound()
+	}
+	return err
+}
+
+// SetSlowMode limits each regular member of a group to one message per the
+// given number of seconds; 0 turns slow mode off.
+func (s *ChatsService) SetSlowMode(ctx context.Context, actorID, chatID ulid.ULID, seconds int) error {
+	access, err := s.authorizeGroup(ctx, actorID, chatID, PermChangeInfo)
+	if err != nil {
+		return err
+	}
+	if access.Chat.Type != db.ChatTypeGroup {
+		return &BusinessError{Code: string(utils.ErrValidation), Message: "Slow mode is only available in groups"}
+	}
+	if !slowModeIntervals[seconds] {
+		return &BusinessError{Code: string(utils.ErrValidation), Message: "Slow mode must be one of 0, 10, 30, 60, 300, 900 or 3600 seconds"}
+	}
+	return s.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.SetChatSlowMode(ctx, chatID, int32(seconds)); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, chatID, actorID, AuditSlowModeChanged, auditTarget{},
+			map[string]int32{"seconds": access.Chat.SlowModeSeconds}, map[string]int{"seconds": seconds})
+	})
+}
+
+// claimSlowMode uses up the member's slow mode slot, failing with
+// RATE_LIMITED while the previous one is still running. Owners and admins
+// are exempt from slow mode.
+func (s *ChatsService) claimSlowMode(ctx context.Context, access *ChatAccess) error {
+	interval := access.Chat.SlowModeSeconds
+	if interval <= 0 || access.Member.Role != db.ChatMemberRoleMember {
+		return nil
+	}
+
+	slot, err := s.repo.ClaimSlowModeSlot(ctx, ulid.MustParse(access.Chat.ID), ulid.MustParse(access.Member.UserID), interval)
+	if errors.Is(err, repos.ErrNotFound) {
+		// The member left since the send was authorized.
+		return chatNotFound()
+	}
+	if err != nil {
+		return err
+	}
+	if !slot.Claimed {
+		next := slot.LastSentAt.Time.Add(time.Duration(interval) * time.Second)
+		return rateLimited(time.Until(next))
+	}
+	return nil
+}
+
+// getModerator returns the actor's membership when they may moderate a
+// group or channel, which owners and admins can.
+func (s *ChatsService) getModerator(ctx context.Context, chatID, actorID ulid.ULID) (*db.ChatMember, error) {
+	_, actor, err := s.getGroupMember(ctx, chatID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if actor.Role == db.ChatMemberRoleMember {
+		return nil, forbiddenRole("Only admins can moderate this chat")
+	}
+	return actor, nil
+}
+
+// rateLimited reports a rejected send with the whole seconds until the next
+// one is allowed.
+func rateLimited(wait time.Duration) *BusinessError {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return &BusinessError{
+		Code:    string(utils.ErrRateLimited),
+		Message: fmt.Sprintf("Slow mode is enabled; try again in %d seconds", seconds),
+		Details: map[string]any{"retry_after": seconds},
+	}
+}
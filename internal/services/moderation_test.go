@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBanMember_BlocksRejoinViaInvite_RealDB(t *testing.T) {
+	service := setupInvitesService()
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	memberID := createUser(t, ctx, "member")
+
+	group, err := service.chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{memberID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+
+	// Regular members cannot moderate.
+	_, err = service.chats.BanMember(ctx, memberID, groupID, ownerID, nil)
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+
+	until := time.Now().Add(time.Hour)
+	_, err = service.chats.BanMember(ctx, ownerID, groupID, memberID, &until)
+	require.NoError(t, err)
+
+	chat, err := service.chats.repo.GetChat(ctx, groupID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, chat.MemberCount)
+
+	link, err := service.CreateInviteLink(ctx, ownerID, groupID, CreateInviteLinkParams{})
+	require.NoError(t, err)
+	_, err = service.JoinByInvite(ctx, memberID, link.Token)
+	requireBusinessCode(t, err, "USER_BANNED")
+
+	bans, err := service.chats.ListBans(ctx, ownerID, groupID, "", 10)
+	require.NoError(t, err)
+	require.Len(t, bans.Items, 1)
+	assert.Equal(t, memberID.String(), bans.Items[0].UserID)
+
+	require.NoError(t, service.chats.UnbanMember(ctx, ownerID, groupID, memberID))
+	result, err := service.JoinByInvite(ctx, memberID, link.Token)
+	require.NoError(t, err)
+	assert.Equal(t, JoinStatusJoined, result.Status)
+}
+
+func TestSendMessage_RestrictionAndSlowMode_RealDB(t *testing.T) {
+	service := setupChatsService()
+	messages := setupMessagesService(service)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	memberID := createUser(t, ctx, "member")
+	ownerDevice := createDevice(t, ctx, ownerID)
+	memberDevice := createDevice(t, ctx, memberID)
+
+	group, err := service.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{memberID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+	send := func(userID, deviceID ulid.ULID) error {
+		_, _, err := messages.SendMessage(ctx, userID, groupID, SendMessageParams{SenderDeviceID: deviceID, ContentType: "text", Ciphertext: testCiphertext()})
+		return err
+	}
+
+	until := time.Now().Add(time.Hour)
+	require.NoError(t, service.RestrictMember(ctx, ownerID, groupID, memberID, &until))
+	requireBusinessCode(t, send(memberID, memberDevice), "FORBIDDEN_ROLE")
+	require.NoError(t, service.RestrictMember(ctx, ownerID, groupID, memberID, nil))
+
+	err = service.SetSlowMode(ctx, ownerID, groupID, 7)
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+	require.NoError(t, service.SetSlowMode(ctx, ownerID, groupID, 60))
+
+	// A send that is not stored leaves the slot unused.
+	missingThread := ulid.Make()
+	_, _, err = messages.SendMessage(ctx, memberID, groupID, SendMessageParams{SenderDeviceID: memberDevice, ThreadID: &missingThread, ContentType: "text", Ciphertext: testCiphertext()})
+	requireBusinessCode(t, err, "THREAD_NOT_FOUND")
+	require.NoError(t, send(memberID, memberDevice))
+	err = send(memberID, memberDevice)
+	requireBusinessCode(t, err, "RATE_LIMITED")
+	retryAfter := err.(*BusinessError).Details["retry_after"].(int)
+	assert.True(t, retryAfter > 0 && retryAfter <= 60)
+
+	// Admins are exempt from slow mode.
+	require.NoError(t, send(ownerID, ownerDevice))
+	require.NoError(t, send(ownerID, ownerDevice))
+}
@@ -1 +1,278 @@
 package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+// Update types published to a user's update log.
+const (
+	UpdateChatState       = "chat_state"
+	UpdatePinnedOrder     = "pinned_order"
+	UpdateFolder          = "folder_updated"
+	UpdateFolderGone      = "folder_deleted"
+	UpdateFolderOrder     = "folder_order"
+	UpdateNewMessage      = "new_message"
+	UpdateNewChat         = "new_chat"
+	UpdateChatMember      = "chat_member"
+	UpdateThreadRead      = "thread_read"
+	UpdateMessageEdited   = "message_edited"
+	UpdateMessagesDeleted = "messages_deleted"
+	UpdateHistoryCleared  = "history_cleared"
+	UpdateReceipt         = "receipt"
+	UpdatePrivacy         = "privacy"
+	UpdateReactions       = "reactions"
+	UpdatePinnedMessages  = "pinned_messages"
+	UpdateScheduled       = "scheduled_message"
+	UpdatePoll            = "poll"
+	UpdateMention         = "mention"
+	UpdateMentionsRead    = "mentions_read"
+	UpdateDraft           = "draft"
+	UpdateChatInfo        = "chat_info"
+	UpdateChatReactions   = "chat_reactions"
+)
+
+const (
+	defaultDifferenceSize = 100
+	maxDifferenceSize     = 1000
+)
+
+// Update is one entry of a user's update log. Seq increases by one for
+// every update, so a device that saw seq n knows it is missing n+1 onwards.
+// Updates of a channel's stream carry the channel's ChatID, and their Seq
+// counts within that stream.
+type Update struct {
+	Seq       int64           `json:"seq"`
+	ChatID    string          `json:"chat_id,omitempty"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Difference is the part of a user's update log that a device missed. Seq is
+// the seq of the last update included, or the current seq when there are
+// none; the device passes it as since on its next call. HasMore reports that the
+// difference was cut off at the page size.
+type Difference struct {
+	Updates  []Update            `json:"updates"`
+	Seq      int64               `json:"seq"`
+	HasMore  bool                `json:"has_more"`
+	Channels []ChannelDifference `json:"channels"`
+}
+
+// ChannelDifference is the part of a channel's update stream that a device
+// missed, like Difference is for the user's log. A device that does not
+// follow the channel yet gets no updates and starts following it at Seq.
+type ChannelDifference struct {
+	ChatID  string   `json:"chat_id"`
+	Updates []Update `json:"updates"`
+	Seq     int64    `json:"seq"`
+	HasMore bool     `json:"has_more"`
+}
+
+// Notifier delivers updates to a user's connected devices. Delivery is best
+// effort; devices that miss an update catch up from the log.
+type Notifier interface {
+	NotifyUser(userID ulid.ULID, update Update)
+}
+
+// UpdatesService records per-user updates and pushes them to online devices.
+type UpdatesService struct {
+	repo     repos.UpdateRepository
+	tx       repos.Transactor
+	notifier Notifier
+}
+
+// NewUpdatesService creates a new UpdatesService. notifier may be nil, in
+// which case updates are only logged.
+func NewUpdatesService(repo repos.UpdateRepository, tx repos.Transactor, notifier Notifier) *UpdatesService {
+	return &UpdatesService{repo: repo, tx: tx, notifier: notifier}
+}
+
+// InTx runs fn in a transaction, so that a change and the updates
+// published about it are stored together or not at all. Devices are only
+// pushed the updates once the transaction commits.
+func (s *UpdatesService) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.tx.InTx(ctx, fn)
+}
+
+// Publish appends an update for userID and pushes it to the user's devices.
+func (s *UpdatesService) Publish(ctx context.Context, userID ulid.ULID, updateType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	row, err := s.repo.AppendUserUpdate(ctx, userID, updateType, data)
+	if err != nil {
+		return err
+	}
+	repos.AfterCommit(ctx, func() { s.notify(*row) })
+	return nil
+}
+
+// PublishToChat appends an update for every member of a chat and pushes it
+// to their devices. A channel's update is stored once in the channel's
+// stream, which subscribers read through GetDifference, so it costs the
+// same however many subscribers the channel has.
+func (s *UpdatesService) PublishToChat(ctx context.Context, chatID ulid.ULID, updateType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	update, err := s.repo.AppendChannelUpdate(ctx, chatID, updateType, data)
+	if err == nil {
+		return s.notifyChannel(ctx, chatID, *update)
+	}
+	if !errors.Is(err, repos.ErrNotFound) {
+		return err
+	}
+	rows, err := s.repo.AppendChatUpdate(ctx, chatID, updateType, data)
+	if err != nil {
+		return err
+	}
+	repos.AfterCommit(ctx, func() {
+		for _, row := range rows {
+			s.notify(row)
+		}
+	})
+	return nil
+}
+
+// GetState returns the seq of the user's newest update. A device that has
+// applied everything up to it is in sync.
+func (s *UpdatesService) GetState(ctx context.Context, userID ulid.ULID) (int64, error) {
+	return s.repo.GetUserUpdateSeq(ctx, userID)
+}
+
+// GetDifference returns the user's updates after since, oldest first, so a
+// reconnecting device can catch up without gaps. channels holds the seq the
+// device reached in the stream of each channel it follows; the difference
+// covers every channel the user is subscribed to.
+func (s *UpdatesService) GetDifference(ctx context.Context, userID ulid.ULID, since int64, channels map[ulid.ULID]int64, limit int) (*Difference, error) {
+	current, err := s.repo.GetUserUpdateSeq(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if since < 0 || since > current {
+		return nil, &BusinessError{
+			Code:    string(utils.ErrValidation),
+			Message: "since is not a seq of this user's update log",
+			Details: map[string]any{"seq": current},
+		}
+	}
+
+	limit = clampPageSize(limit, defaultDifferenceSize, maxDifferenceSize)
+	rows, err := s.repo.ListUserUpdates(ctx, userID, since, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	diff := &Difference{Updates: make([]Update, 0, len(rows)), Seq: current}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		diff.HasMore = true
+	}
+	for _, row := range rows {
+		diff.Updates = append(diff.Updates, toUpdate(row))
+	}
+	if len(rows) > 0 {
+		diff.Seq = rows[len(rows)-1].Seq
+	}
+	if diff.Channels, err = s.channelDifferences(ctx, userID, channels, limit); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// channelDifferences returns the channel updates after the seqs in known.
+func (s *UpdatesService) channelDifferences(ctx context.Context, userID ulid.ULID, known map[ulid.ULID]int64, limit int) ([]ChannelDifference, error) {
+	channels, err := s.repo.ListUserChannelSeqs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	diffs := make([]ChannelDifference, 0, len(channels))
+	for _, channel := range channels {
+		chatID := ulid.MustParse(channel.ID)
+		diff := ChannelDifference{ChatID: channel.ID, Updates: []Update{}, Seq: channel.UpdateSeq}
+		since, ok := known[chatID]
+		if !ok || since == channel.UpdateSeq {
+			diffs = append(diffs, diff)
+			continue
+		}
+		if since < 0 || since > channel.UpdateSeq {
+			return nil, &BusinessError{
+				Code:    string(utils.ErrValidation),
+				Message: "A channel seq is not a seq of the channel's updates",
+				Details: map[string]any{"chat_id": channel.ID, "seq": channel.UpdateSeq},
+			}
+		}
+
+		rows, err := s.repo.ListChannelUpdates(ctx, chatID, since, limit+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) > limit {
+			rows = rows[:limit]
+			diff.HasMore = true
+		}
+		for _, row := range rows {
+			diff.Updates = append(diff.Updates, toChannelUpdate(row))
+		}
+		if len(rows) > 0 {
+			diff.Seq = rows[len(rows)-1].Seq
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
+}
+
+// notifyChannel pushes a channel update to the subscribers' devices once it
+// commits.
+func (s *UpdatesService) notifyChannel(ctx context.Context, chatID ulid.ULID, row db.ChatUpdate) error {
+	if s.notifier == nil {
+		return nil
+	}
+	userIDs, err := s.repo.ListChatMemberIDs(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	update := toChannelUpdate(row)
+	repos.AfterCommit(ctx, func() {
+		for _, id := range userIDs {
+			s.notifier.NotifyUser(ulid.MustParse(id), update)
+		}
+	})
+	return nil
+}
+
+func (s *UpdatesService) notify(row db.UserUpdate) {
+	if s.notifier == nil {
+		return
+	}
+	s.notifier.NotifyUser(ulid.MustParse(row.UserID), toUpdate(row))
+}
+
+func toUpdate(row db.UserUpdate) Update {
+	return Update{
+		Seq:       row.Seq,
+		Type:      row.Type,
+		Payload:   row.Payload,
+		CreatedAt: row.CreatedAt.Time,
+	}
+}
+
+func toChannelUpdate(row db.ChatUpdate) Update {
+	return Update{
+		Seq:       row.Seq,
+		ChatID:    row.ChatID,
+		Type:      row.Type,
+		Payload:   row.Payload,
+		CreatedAt: row.CreatedAt.Time,
+	}
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+// Permission is a bitset of actions a chat member may perform. The bit
+// layout is persisted in the database and must not be reordered.
+type Permission uint32
+
+const (
+	PermSendMessages Permission = 1 << iota
+	PermSendMedia
+	PermAddMembers
+	PermPinMessages
+	PermChangeInfo
+	PermDeleteMessages // delete other members' messages
+	PermStartCalls
+
+	PermNone Permission = 0
+	PermAll             = PermSendMessages | PermSendMedia | PermAddMembers | PermPinMessages |
+		PermChangeInfo | PermDeleteMessages | PermStartCalls
+)
+
+var permissionNames = map[Permission]string{
+	PermSendMessages:   "send_messages",
+	PermSendMedia:      "send_media",
+	PermAddMembers:     "add_members",
+	PermPinMessages:    "pin_messages",
+	PermChangeInfo:     "change_info",
+	PermDeleteMessages: "delete_messages",
+	PermStartCalls:     "start_calls",
+}
+
+// maxCustomTitleLength limits the custom title shown next to an admin's name.
+const maxCustomTitleLength = 16
+
+// Has reports whether p includes every permission in want.
+func (p Permission) Has(want Permission) bool {
+	return p&want == want
+}
+
+// Names lists the permissions in p in a stable order.
+func (p Permission) Names() []string {
+	names := []string{}
+	for bit, name := range permissionNames {
+		if p.Has(bit) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParsePermissions converts permission names into a bitset.
+func ParsePermissions(names []string) (Permission, error) {
+	var p Permission
+	for _, name := range names {
+		found := false
+		for bit, n := range permissionNames {
+			if n == name {
+				p |= bit
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown permission %q", name)
+		}
+	}
+	return p, nil
+}
+
+// MarshalJSON encodes the bitset as a list of permission names.
+func (p Permission) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Names())
+}
+
+// UnmarshalJSON decodes a list of permission names.
+func (p *Permission) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	parsed, err := ParsePermissions(names)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// DefaultPermissions returns the member and admin permission defaults for a
+// new chat of the given type.
+func DefaultPermissions(chatType db.ChatType) repos.ChatPermissions {
+	switch chatType {
+	case db.ChatTypeGroup:
+		return repos.ChatPermissions{
+			Member: int32(PermSendMessages | PermSendMedia | PermAddMembers | PermStartCalls),
+			Admin:  int32(PermAll),
+		}
+	case db.ChatTypeChannel:
+		// Only admins post in channels; subscribers just read.
+		return repos.ChatPermissions{
+			Member: int32(PermNone),
+			Admin:  int32(PermAll),
+		}
+	default:
+		// Both participants of a direct chat are equals without admins.
+		return repos.ChatPermissions{
+			Member: int32(PermSendMessages | PermSendMedia | PermPinMessages | PermDeleteMessages | PermStartCalls),
+		}
+	}
+}
+
+// ChatAccess is the result of a successful authorization check.
+type ChatAccess struct {
+	Chat        *db.Chat
+	Member      *db.ChatMember
+	Permissions Permission
+}
+
+// ChatPermissionsView describes a chat's role defaults and the caller's
+// effective permissions.
+type ChatPermissionsView struct {
+	Member      Permission `json:"member_permissions"`
+	Admin       Permission `json:"admin_permissions"`
+	Effective   Permission `json:"effective_permissions"`
+	Role        string     `json:"role"`
+	CustomTitle string     `json:"custom_title,omitempty"`
+}
+
+// effectivePermissions resolves a member's permissions: owners can do
+// everything, restricted members and channel subscribers nothing, and an
+// explicit per-member set wins over the role default.
+func effectivePermissions(chat *db.Chat, member *db.ChatMember) Permission {
+	if member.Role == db.ChatMemberRoleOwner {
+		return PermAll
+	}
+	if isRestricted(member) {
+		return PermNone
+	}
+	if chat.Type == db.ChatTypeChannel && member.Role == db.ChatMemberRoleMember {
+		return PermNone
+	}
+	if member.Permissions.Valid {
+		return Permission(member.Permissions.Int32)
+	}
+	if member.Role == db.ChatMemberRoleAdmin {
+		return Permission(chat.AdminPermissions)
+	}
+	return Permission(chat.MemberPermissions)
+}
+
+// isRestricted reports whether a member is currently read-only.
+func isRestricted(member *db.ChatMember) bool {
+	return member.RestrictedUntil.Valid && member.RestrictedUntil.Time.After(time.Now())
+}
+
+// Authorize loads the user's membership in a chat and checks that they hold
+// every permission in want. Every chat and message operation goes through
+// it; pass PermNone to only require membership. Non-members get
+// CHAT_NOT_FOUND so that private chats are not disclosed.
+func (s *ChatsService) Authorize(ctx context.Context, userID, chatID ulid.ULID, want Permission) (*ChatAccess, error) {
+	member, err := s.getMember(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	chat, err := s.repo.GetChat(ctx, chatID)
+	if err != nil {
+		if errors.Is(err, repos.ErrNotFound) {
+			return nil, chatNotFound()
+		}
+		return nil, err
+	}
+
+	access := &ChatAccess{Chat: chat, Member: member, Permissions: effectivePermissions(chat, member)}
+	if !access.Permissions.Has(want) {
+		if isRestricted(member) {
+			return nil, forbiddenRole(fmt.Sprintf("You are read-only in this chat until %s", member.RestrictedUntil.Time.Format(time.RFC3339)))
+		}
+		missing := want &^ access.Permissions
+		return nil, forbiddenRole(fmt.Sprintf("Missing permission: %v", missing.Names()))
+	}
+	return access, nil
+}
+
+// GetPermissions returns the chat's role defaults and the caller's effective permissions.
+func (s *ChatsService) GetPermissions(ctx context.Context, userID, chatID ulid.ULID) (*ChatPermissionsView, error) {
+	access, err := s.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return nil, err
+	}
+	return &ChatPermissionsView{
+		Member:      Permission(access.Chat.MemberPermissions),
+		Admin:       Permission(access.Chat.AdminPermissions),
+		Effective:   access.Permissions,
+		Role:        string(access.Member.Role),
+		CustomTitle: access.Member.CustomTitle.String,
+	}, nil
+}
+
+// SetDefaultPermissions changes the role defaults of a group. Only the owner
+// can change them.
+func (s *ChatsService) SetDefaultPermissions(ctx context.Context, actorID, chatID ulid.ULID, member, admin Permission) error {
+	chat, actor, err := s.getGroupMember(ctx, chatID, actorID)
+	if err != nil {
+		return err
+	}
+	if actor.Role != db.ChatMemberRoleOwner {
+		return forbiddenRole("Only the owner can change default permissions")
+	}
+	member, admin = member&PermAll, admin&PermAll
+	return s.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.UpdateChatPermissions(ctx, chatID, repos.ChatPermissions{Member: int32(member), Admin: int32(admin)}); err != nil {
+			return err
+		}
+		s.membersChanged(ctx, chatID)
+		return s.recordAudit(ctx, chatID, actorID, AuditDefaultPermissions, auditTarget{},
+			map[string]Permission{"member_permissions": Permission(chat.MemberPermissions), "admin_permissions": Permission(chat.AdminPermissions)},
+			map[string]Permission{"member_permissions": member, "admin_permissions": admin})
+	})
+}
+
+// MemberPermissionsParams overrides a single member's permissions. A nil
+// Permissions resets the member to their role default.
+type MemberPermissionsParams struct {
+	Permissions *Permission
+	CustomTitle *string
+}
+
+// SetMemberPermissions replaces one member's permission override and, for
+// admins, their custom title. The owner can change anyone; admins can only
+// restrict regular members and cannot grant permissions they do not hold.
+func (s *ChatsService) SetMemberPermissions(ctx context.Context, actorID, chatID, userID ulid.ULID, params MemberPermissionsParams) error {
+	chat, actor, err := s.getGroupMember(ctx, chatID, actorID)
+	if err != nil {
+		return err
+	}
+	target, err := s.getTargetMember(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+	if !outranks(actor.Role, target.Role) {
+		return forbiddenRole("You cannot change this member's permissions")
+	}
+
+	var perms sql.NullInt32
+	if params.Permissions != nil {
+		granted := *params.Permissions & PermAll
+		if actor.Role != db.ChatMemberRoleOwner && !effectivePermissions(chat, actor).Has(granted) {
+			return forbiddenRole("You cannot grant permissions you do not have")
+		}
+		perms = sql.NullInt32{Int32: int32(granted), Valid: true}
+	}
+
+	var title sql.NullString
+	if params.CustomTitle != nil && *params.CustomTitle != "" {
+		if target.Role != db.ChatMemberRoleAdmin {
+			return &BusinessError{Code: string(utils.ErrValidation), Message: "Only admins can have a custom title"}
+		}
+		if utf8.RuneCountInString(*params.CustomTitle) > maxCustomTitleLength {
+			return &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("Custom title must be at most %d characters", maxCustomTitleLength)}
+		}
+		title = sql.NullString{String: *params.CustomTitle, Valid: true}
+	}
+
+	err = s.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.UpdateChatMemberPermissions(ctx, chatID, userID, perms, title); err != nil {
+			return err
+		}
+		s.membersChanged(ctx, chatID)
+		return s.recordAudit(ctx, chatID, actorID, AuditMemberPermissions, targetUser(userID),
+			memberPermissionsAudit(target.Permissions.Int32, target.Permissions.Valid, target.CustomTitle.String),
+			memberPermissionsAudit(perms.Int32, perms.Valid, title.String))
+	})
+	if errors.Is(err, repos.ErrNotFound) {
+		return memberNotFound()
+	}
+	return err
+}
+
+// memberPermissionsAudit describes a member override for the audit log; a
+// null permissions value means the role default applies.
+func memberPermissionsAudit(perms int32, overridden bool, customTitle string) map[string]any {
+	v := map[string]any{"permissions": nil, "custom_title": nullableString(customTitle)}
+	if overridden {
+		v["permissions"] = Permission(perms)
+	}
+	return v
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	defaultPinnedPageSize = 50
+	maxPinnedPageSize     = 100
+)
+
+// PinnedMessagesUpdate tells a chat's members that messages were pinned or
+// unpinned. Notify asks clients to alert members about a new pin.
+type PinnedMessagesUpdate struct {
+	ChatID     string   `json:"chat_id"`
+	MessageIDs []string `json:"message_ids"`
+	Pinned     bool     `json:"pinned"`
+	Notify     bool     `json:"notify,omitempty"`
+	ActorID    string   `json:"actor_id"`
+}
+
+// PinnedPage is one page of a chat's pinned messages.
+type PinnedPage struct {
+	Items      []db.ListPinnedMessagesRow `json:"items"`
+	NextCursor string                     `json:"next_cursor,omitempty"`
+}
+
+// PinsService provides business logic for pinned messages.
+type PinsService struct {
+	repo     repos.PinRepository
+	messages repos.MessageRepository
+	chats    *ChatsService
+}
+
+// NewPinsService creates a new PinsService.
+func NewPinsService(repo repos.PinRepository, messages repos.MessageRepository, chats *ChatsService) *PinsService {
+	return &PinsService{repo: repo, messages: messages, chats: chats}
+}
+
+// PinMessage pins a message the user can see. Any number of messages can be
+// pinned; pinning a pinned message changes nothing. With notify, clients
+// alert members about the pin.
+func (s *PinsService) PinMessage(ctx context.Context, userID, chatID, messageID ulid.ULID, notify bool) error {
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermPinMessages)
+	if err != nil {
+		return err
+	}
+	msgs, err := s.messages.ListVisibleMessages(ctx, chatID, userID, []ulid.ULID{messageID})
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 || msgs[0].DeletedAt.Valid {
+		return messageNotFound()
+	}
+
+	return s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		pinned, err := s.repo.PinMessage(ctx, chatID, messageID, userID)
+		if err != nil || !pinned {
+			return err
+		}
+		if err := s.publish(ctx, chatID, userID, []string{messageID.String()}, true, notify); err != nil {
+			return err
+		}
+		return s.audit(ctx, access, userID, AuditMessagePinned, auditTarget{ID: messageID.String()}, nil, map[string]bool{"notify": notify})
+	})
+}
+
+// UnpinMessage unpins a message. Unpinning a message that is not pinned
+// changes nothing.
+func (s *PinsService) UnpinMessage(ctx context.Context, userID, chatID, messageID ulid.ULID) error {
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermPinMessages)
+	if err != nil {
+		return err
+	}
+	err = s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.UnpinMessage(ctx, chatID, messageID); err != nil {
+			return err
+		}
+		if err := s.publish(ctx, chatID, userID, []string{messageID.String()}, false, false); err != nil {
+			return err
+		}
+		return s.audit(ctx, access, userID, AuditMessageUnpinned, auditTarget{ID: messageID.String()}, nil, nil)
+	})
+	if errors.Is(err, repos.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// UnpinAllMessages unpins every message of a chat.
+func (s *PinsService) UnpinAllMessages(ctx context.Context, userID, chatID ulid.ULID) error {
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermPinMessages)
+	if err != nil {
+		return err
+	}
+	return s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		ids, err := s.repo.UnpinAllMessages(ctx, chatID)
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		if err := s.publish(ctx, chatID, userID, ids, false, false); err != nil {
+			return err
+		}
+		return s.audit(ctx, access, userID, AuditMessageUnpinned, auditTarget{}, map[string][]string{"message_ids": ids}, nil)
+	})
+}
+
+// ListPinnedMessages returns a page of a chat's pinned messages, newest pin
+// first. Messages the user deleted for themselves or cleared are left out.
+// With a deviceID, the payloads addressed to that device are included.
+func (s *PinsService) ListPinnedMessages(ctx context.Context, userID, chatID ulid.ULID, deviceID *ulid.ULID, cursor string, limit int) (*PinnedPage, error) {
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return nil, err
+	}
+	limit = clampPageSize(limit, defaultPinnedPageSize, maxPinnedPageSize)
+
+	var after *repos.PinCursor
+	if cursor != "" {
+		parts, err := utils.DecodeCursor(cursor, 2)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		micros, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		after = &repos.PinCursor{PinnedAt: time.UnixMicro(micros), MessageID: parts[1]}
+	}
+
+	rows, err := s.repo.ListPinnedMessages(ctx, chatID, userID, deviceID, after, int32(limit+1))
+	if err != nil {
+		return nil, err
+	}
+	page := &PinnedPage{Items: rows}
+	if len(rows) > limit {
+		page.Items = rows[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = utils.EncodeCursor(strconv.FormatInt(last.PinnedAt.Time.UnixMicro(), 10), last.ID)
+	}
+	return page, nil
+}
+
+func (s *PinsService) publish(ctx context.Context, chatID, userID ulid.ULID, messageIDs []string, pinned, notify bool) error {
+	return s.chats.updates.PublishToChat(ctx, chatID, UpdatePinnedMessages, PinnedMessagesUpdate{
+		ChatID:     chatID.String(),
+		MessageIDs: messageIDs,
+		Pinned:     pinned,
+		Notify:     notify,
+		ActorID:    userID.String(),
+	})
+}
+
+// audit records pin changes in the audit log of groups and channels; direct
+// chats have none.
+func (s *PinsService) audit(ctx context.Context, access *ChatAccess, userID ulid.ULID, action string, target auditTarget, before, after any) error {
+	if access.Chat.Type == db.ChatTypeDirect {
+		return nil
+	}
+	return s.chats.recordAudit(ctx, ulid.MustParse(access.Chat.ID), userID, action, target, before, after)
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/messenger/backend/internal/storage/postgres"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPins_PinListUnpinAndAudit_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	notifier := &recordingNotifier{}
+	chats.updates = NewUpdatesService(postgres.NewPostgresUpdateRepository(testQueries), testDB, notifier)
+	messages := setupMessagesService(chats)
+	service := NewPinsService(postgres.NewPostgresPinRepository(testQueries), postgres.NewPostgresMessageRepository(testQueries), chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	memberID := createUser(t, ctx, "member")
+	ownerDevice := createDevice(t, ctx, ownerID)
+	group, err := chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{memberID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+	ids := make([]ulid.ULID, 3)
+	for i := range ids {
+		msg, _, err := messages.SendMessage(ctx, ownerID, groupID, SendMessageParams{SenderDeviceID: ownerDevice, ContentType: "text", Ciphertext: testCiphertext()})
+		require.NoError(t, err)
+		ids[i] = ulid.MustParse(msg.ID)
+	}
+
+	// Group members cannot pin by default.
+	err = service.PinMessage(ctx, memberID, groupID, ids[0], false)
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+
+	for _, id := range ids {
+		require.NoError(t, service.PinMessage(ctx, ownerID, groupID, id, true))
+	}
+	require.NoError(t, service.PinMessage(ctx, ownerID, groupID, ids[0], true))
+	last := notifier.updates[memberID][len(notifier.updates[memberID])-1]
+	require.Equal(t, UpdatePinnedMessages, last.Type)
+	var update PinnedMessagesUpdate
+	require.NoError(t, json.Unmarshal(last.Payload, &update))
+	assert.Equal(t, PinnedMessagesUpdate{ChatID: group.ID, MessageIDs: []string{ids[2].String()}, Pinned: true, Notify: true, ActorID: ownerID.String()}, update)
+
+	// Newest pin first, paged.
+	page, err := service.ListPinnedMessages(ctx, memberID, groupID, nil, "", 2)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, ids[2].String(), page.Items[0].ID)
+	page, err = service.ListPinnedMessages(ctx, memberID, groupID, nil, page.NextCursor, 2)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, ids[0].String(), page.Items[0].ID)
+
+	// Messages deleted for the reader or for everyone drop out.
+	require.NoError(t, messages.DeleteMessages(ctx, memberID, groupID, []ulid.ULID{ids[1]}, false))
+	require.NoError(t, messages.DeleteMessages(ctx, ownerID, groupID, []ulid.ULID{ids[2]}, true))
+	page, err = service.ListPinnedMessages(ctx, memberID, groupID, nil, "", 10)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	page, err = service.ListPinnedMessages(ctx, ownerID, groupID, nil, "", 10)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+
+	require.NoError(t, service.UnpinMessage(ctx, ownerID, groupID, ids[0]))
+	require.NoError(t, service.UnpinMessage(ctx, ownerID, groupID, ids[0]))
+	require.NoError(t, service.UnpinAllMessages(ctx, ownerID, groupID))
+	page, err = service.ListPinnedMessages(ctx, ownerID, groupID, nil, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+
+	log, err := chats.ListAuditLog(ctx, ownerID, groupID, AuditLogFilter{Actions: []string{AuditMessagePinned, AuditMessageUnpinned}}, "", 10)
+	require.NoError(t, err)
+	require.Len(t, log.Items, 5)
+	assert.Equal(t, AuditMessageUnpinned, log.Items[0].Action)
+
+	// Both users of a direct chat can pin.
+	direct, _, err := chats.GetOrCreateDirectChat(ctx, ownerID, memberID)
+	require.NoError(t, err)
+	directID := ulid.MustParse(direct.ID)
+	msg, _, err := messages.SendMessage(ctx, ownerID, directID, SendMessageParams{SenderDeviceID: ownerDevice, ContentType: "text", Ciphertext: testCiphertext()})
+	require.NoError(t, err)
+	require.NoError(t, service.PinMessage(ctx, memberID, directID, ulid.MustParse(msg.ID), false))
+}
@@ -0,0 +1,401 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	// pollContentType is the content type of poll messages.
+	pollContentType = "poll"
+	minPollOptions  = 2
+	maxPollOptions  = 10
+	// maxPollDuration caps how far ahead a poll's close date can be.
+	maxPollDuration = 366 * 24 * time.Hour
+
+	defaultPollVoterPageSize = 50
+	maxPollVoterPageSize     = 100
+)
+
+// PollParams are the settings of a new poll. The question and option texts
+// are part of the encrypted message; the server only needs the number of
+// options to count votes.
+type PollParams struct {
+	Options        int
+	MultipleChoice bool
+	Anonymous      bool
+	// CorrectOption turns the poll into a quiz with one right answer.
+	CorrectOption *int
+	ClosesAt      *time.Time
+}
+
+// PollResults is a poll as one user sees it. Counts holds the number of
+// voters per option. The correct option of a quiz is only shown to users
+// who answered it, and to everybody once the quiz is closed.
+type PollResults struct {
+	ChatID         string     `json:"chat_id"`
+	MessageID      string     `json:"message_id"`
+	MultipleChoice bool       `json:"multiple_choice"`
+	Anonymous      bool       `json:"anonymous"`
+	Quiz           bool       `json:"quiz"`
+	CorrectOption  *int16     `json:"correct_option,omitempty"`
+	ClosesAt       *time.Time `json:"closes_at,omitempty"`
+	Closed         bool       `json:"closed"`
+	TotalVoters    int32      `json:"total_voters"`
+	Counts         []int32    `json:"counts"`
+	MyOptions      []int16    `json:"my_options"`
+}
+
+// PollUpdate tells a chat's members about a poll's new results.
+type PollUpdate struct {
+	ChatID      string  `json:"chat_id"`
+	MessageID   string  `json:"message_id"`
+	TotalVoters int32   `json:"total_voters"`
+	Counts      []int32 `json:"counts"`
+	Closed      bool    `json:"closed"`
+}
+
+// PollVoterPage is one page of the users who voted in a public poll.
+type PollVoterPage struct {
+	Items      []db.ListPollVotersRow `json:"items"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
+
+// PollsService provides business logic for voting in polls. Polls are
+// created by sending a poll message.
+type PollsService struct {
+	repo     repos.PollRepository
+	messages repos.MessageRepository
+	chats    *ChatsService
+}
+
+// NewPollsService creates a new PollsService.
+func NewPollsService(repo repos.PollRepository, messages repos.MessageRepository, chats *ChatsService) *PollsService {
+	return &PollsService{repo: repo, messages: messages, chats: chats}
+}
+
+// GetPoll returns a poll's current results.
+func (s *PollsService) GetPoll(ctx context.Context, userID, chatID, messageID ulid.ULID) (*PollResults, error) {
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return nil, err
+	}
+	poll, err := s.getPoll(ctx, userID, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	return pollResults(poll), nil
+}
+
+// Vote replaces the user's choices in an open poll. Voting again with the
+// same choices changes nothing, and a quiz can be answered only once. Each
+// user's vote is a single row, so concurrent votes never count twice.
+func (s *PollsService) Vote(ctx context.Context, userID, chatID, messageID ulid.ULID, options []int) (*PollResults, error) {
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDirectBlock(ctx, s.chats.contacts, access.Chat, userID); err != nil {
+		return nil, err
+	}
+	poll, err := s.getPoll(ctx, userID, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	choices, err := pollChoices(poll, options)
+	if err != nil {
+		return nil, err
+	}
+	if pollClosed(poll) {
+		return nil, pollClosedError()
+	}
+
+	var changed bool
+	err = s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if changed, err = s.repo.Vote(ctx, chatID, messageID, userID, choices); err != nil {
+			return err
+		}
+		if poll, err = s.getPoll(ctx, userID, chatID, messageID); err != nil || !changed {
+			return err
+		}
+		return s.publish(ctx, poll)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		// The poll closed, the user repeated their vote or a quiz answer
+		// was already stored.
+		if poll.CorrectOption.Valid && poll.MyOptions != nil && !slices.Equal(poll.MyOptions, choices) {
+			return nil, &BusinessError{Code: string(utils.ErrPollAnswered), Message: "You already answered this quiz"}
+		}
+		if pollClosed(poll) && !slices.Equal(poll.MyOptions, choices) {
+			return nil, pollClosedError()
+		}
+		return pollResults(poll), nil
+	}
+	return pollResults(poll), nil
+}
+
+// RetractVote removes the user's vote from an open poll. Quiz answers are
+// final. Retracting without a vote changes nothing.
+func (s *PollsService) RetractVote(ctx context.Context, userID, chatID, messageID ulid.ULID) (*PollResults, error) {
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return nil, err
+	}
+	poll, err := s.getPoll(ctx, userID, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if poll.CorrectOption.Valid {
+		return nil, &BusinessError{Code: string(utils.ErrPollAnswered), Message: "Quiz answers cannot be retracted"}
+	}
+	if pollClosed(poll) {
+		return nil, pollClosedError()
+	}
+
+	err = s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		retracted, err := s.repo.RetractVote(ctx, chatID, messageID, userID)
+		if err != nil || !retracted {
+			return err
+		}
+		if poll, err = s.getPoll(ctx, userID, chatID, messageID); err != nil {
+			return err
+		}
+		return s.publish(ctx, poll)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pollResults(poll), nil
+}
+
+// ClosePoll stops a poll from taking votes. The sender can close their
+// poll, and in groups and channels so can members who delete messages.
+// Closing a closed poll changes nothing.
+func (s *PollsService) ClosePoll(ctx context.Context, userID, chatID, messageID ulid.ULID) (*PollResults, error) {
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := s.getMessage(ctx, userID, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.SenderID.String != userID.String() &&
+		(access.Chat.Type == db.ChatTypeDirect || !access.Permissions.Has(PermDeleteMessages)) {
+		return nil, forbiddenRole("Only the sender or an admin can close this poll")
+	}
+
+	var poll *db.GetPollRow
+	err = s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		err := s.repo.ClosePoll(ctx, chatID, messageID)
+		closed := err == nil
+		if errors.Is(err, repos.ErrNotFound) {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+		if poll, err = s.getPoll(ctx, userID, chatID, messageID); err != nil || !closed {
+			return err
+		}
+		return s.publish(ctx, poll)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pollResults(poll), nil
+}
+
+// ListVoters returns a page of the users who voted in a public poll,
+// earliest vote first, optionally only those who chose one option.
+func (s *PollsService) ListVoters(ctx context.Context, userID, chatID, messageID ulid.ULID, option *int, cursor string, limit int) (*PollVoterPage, error) {
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return nil, err
+	}
+	poll, err := s.getPoll(ctx, userID, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if poll.Anonymous {
+		return nil, &BusinessError{Code: string(utils.ErrForbidden), Message: "Votes in this poll are anonymous"}
+	}
+	var filter *int16
+	if option != nil {
+		if *option < 0 || *option >= int(poll.OptionCount) {
+			return nil, invalidPollOption()
+		}
+		o := int16(*option)
+		filter = &o
+	}
+	limit = clampPageSize(limit, defaultPollVoterPageSize, maxPollVoterPageSize)
+
+	var after *repos.PollVoterCursor
+	if cursor != "" {
+		parts, err := utils.DecodeCursor(cursor, 2)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		micros, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		after = &repos.PollVoterCursor{VotedAt: time.UnixMicro(micros), UserID: parts[1]}
+	}
+
+	rows, err := s.repo.ListVoters(ctx, messageID, filter, after, int32(limit+1))
+	if err != nil {
+		return nil, err
+	}
+	page := &PollVoterPage{Items: rows}
+	if len(rows) > limit {
+		page.Items = rows[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = utils.EncodeCursor(strconv.FormatInt(last.VotedAt.Time.UnixMicro(), 10), last.UserID)
+	}
+	return page, nil
+}
+
+// getMessage returns a message the user can see that has not been deleted.
+func (s *PollsService) getMessage(ctx context.Context, userID, chatID, messageID ulid.ULID) (*db.Message, error) {
+	msgs, err := s.messages.ListVisibleMessages(ctx, chatID, userID, []ulid.ULID{messageID})
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 || msgs[0].DeletedAt.Valid {
+		return nil, messageNotFound()
+	}
+	return &msgs[0], nil
+}
+
+// getPoll returns the poll of a message the user can see.
+func (s *PollsService) getPoll(ctx context.Context, userID, chatID, messageID ulid.ULID) (*db.GetPollRow, error) {
+	if _, err := s.getMessage(ctx, userID, chatID, messageID); err != nil {
+		return nil, err
+	}
+	poll, err := s.repo.GetPoll(ctx, chatID, messageID, userID)
+	if errors.Is(err, repos.ErrNotFound) {
+		return nil, &BusinessError{Code: string(utils.ErrNotFound), Message: "This message has no poll"}
+	}
+	return poll, err
+}
+
+func (s *PollsService) publish(ctx context.Context, poll *db.GetPollRow) error {
+	return s.chats.updates.PublishToChat(ctx, ulid.MustParse(poll.ChatID), UpdatePoll, PollUpdate{
+		ChatID:      poll.ChatID,
+		MessageID:   poll.MessageID,
+		TotalVoters: poll.TotalVoters,
+		Counts:      poll.Counts,
+		Closed:      pollClosed(poll),
+	})
+}
+
+func pollResults(poll *db.GetPollRow) *PollResults {
+	results := &PollResults{
+		ChatID:         poll.ChatID,
+		MessageID:      poll.MessageID,
+		MultipleChoice: poll.MultipleChoice,
+		Anonymous:      poll.Anonymous,
+		Quiz:           poll.CorrectOption.Valid,
+		Closed:         pollClosed(poll),
+		TotalVoters:    poll.TotalVoters,
+		Counts:         poll.Counts,
+		MyOptions:      poll.MyOptions,
+	}
+	if poll.ClosesAt.Valid {
+		results.ClosesAt = &poll.ClosesAt.Time
+	}
+	if results.MyOptions == nil {
+		results.MyOptions = []int16{}
+	}
+	if poll.CorrectOption.Valid && (poll.MyOptions != nil || results.Closed) {
+		results.CorrectOption = &poll.CorrectOption.Int16
+	}
+	return results
+}
+
+func pollClosed(poll *db.GetPollRow) bool {
+	return poll.ClosedAt.Valid || (poll.ClosesAt.Valid && !poll.ClosesAt.Time.After(time.Now()))
+}
+
+// pollChoices checks a vote against the poll and returns the chosen option
+// indexes sorted and without duplicates.
+func pollChoices(poll *db.GetPollRow, options []int) ([]int16, error) {
+	if len(options) == 0 {
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: "Choose at least one option"}
+	}
+	choices := make([]int16, 0, len(options))
+	for _, o := range options {
+		if o < 0 || o >= int(poll.OptionCount) {
+			return nil, invalidPollOption()
+		}
+		choices = append(choices, int16(o))
+	}
+	slices.Sort(choices)
+	choices = slices.Compact(choices)
+	if len(choices) > 1 && !poll.MultipleChoice {
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: "This poll allows only one choice"}
+	}
+	return choices, nil
+}
+
+// validatePoll checks that poll settings come with, and only with, poll
+// messages.
+func validatePoll(contentType string, poll *PollParams) error {
+	if (contentType == pollContentType) != (poll != nil) {
+		return &BusinessError{Code: string(utils.ErrValidation), Message: "Poll settings are required for poll messages and only allowed there"}
+	}
+	if poll == nil {
+		return nil
+	}
+	if poll.Options < minPollOptions || poll.Options > maxPollOptions {
+		return &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("A poll needs between %d and %d options", minPollOptions, maxPollOptions)}
+	}
+	if c := poll.CorrectOption; c != nil {
+		if *c < 0 || *c >= poll.Options {
+			return invalidPollOption()
+		}
+		if poll.MultipleChoice {
+			return &BusinessError{Code: string(utils.ErrValidation), Message: "A quiz has exactly one correct answer"}
+		}
+	}
+	if poll.ClosesAt != nil {
+		if d := time.Until(*poll.ClosesAt); d <= 0 || d > maxPollDuration {
+			return &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("A poll must close within %s", maxPollDuration)}
+		}
+	}
+	return nil
+}
+
+// newPoll returns the stored part of the poll settings, or nil without a
+// poll.
+func (p *PollParams) newPoll() *repos.NewPoll {
+	if p == nil {
+		return nil
+	}
+	return &repos.NewPoll{
+		Options:        p.Options,
+		MultipleChoice: p.MultipleChoice,
+		Anonymous:      p.Anonymous,
+		CorrectOption:  p.CorrectOption,
+		ClosesAt:       p.ClosesAt,
+	}
+}
+
+func pollClosedError() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrPollClosed), Message: "This poll is closed"}
+}
+
+func invalidPollOption() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrValidation), Message: "No such poll option"}
+}
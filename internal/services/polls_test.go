@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/messenger/backend/internal/storage/postgres"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolls_VoteQuizAndClose_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	messages := setupMessagesService(chats)
+	service := NewPollsService(postgres.NewPostgresPollRepository(testQueries), postgres.NewPostgresMessageRepository(testQueries), chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	memberID := createUser(t, ctx, "member")
+	otherID := createUser(t, ctx, "other")
+	ownerDevice := createDevice(t, ctx, ownerID)
+	group, err := chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{memberID, otherID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+	sendPoll := func(poll *PollParams) ulid.ULID {
+		msg, _, err := messages.SendMessage(ctx, ownerID, groupID, SendMessageParams{SenderDeviceID: ownerDevice, ContentType: "poll", Ciphertext: testCiphertext(), Poll: poll})
+		require.NoError(t, err)
+		return ulid.MustParse(msg.ID)
+	}
+
+	_, _, err = messages.SendMessage(ctx, ownerID, groupID, SendMessageParams{SenderDeviceID: ownerDevice, ContentType: "poll", Ciphertext: testCiphertext()})
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+	_, _, err = messages.SendMessage(ctx, ownerID, groupID, SendMessageParams{SenderDeviceID: ownerDevice, ContentType: "poll", Ciphertext: testCiphertext(), Poll: &PollParams{Options: 1}})
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+
+	// Public multiple choice poll: votes replace earlier ones and repeating
+	// a vote changes nothing.
+	pollID := sendPoll(&PollParams{Options: 3, MultipleChoice: true})
+	_, err = service.Vote(ctx, memberID, groupID, pollID, []int{3})
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+	results, err := service.Vote(ctx, memberID, groupID, pollID, []int{2, 0, 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int32{1, 0, 1}, results.Counts)
+	assert.Equal(t, []int16{0, 2}, results.MyOptions)
+	results, err = service.Vote(ctx, memberID, groupID, pollID, []int{0, 2})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), results.TotalVoters)
+	results, err = service.Vote(ctx, memberID, groupID, pollID, []int{1})
+	require.NoError(t, err)
+	assert.Equal(t, []int32{0, 1, 0}, results.Counts)
+	voters, err := service.ListVoters(ctx, otherID, groupID, pollID, nil, "", 10)
+	require.NoError(t, err)
+	require.Len(t, voters.Items, 1)
+	assert.Equal(t, memberID.String(), voters.Items[0].UserID)
+	results, err = service.RetractVote(ctx, memberID, groupID, pollID)
+	require.NoError(t, err)
+	assert.Zero(t, results.TotalVoters)
+
+	// Concurrent votes by the same user count once.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(option int) {
+			defer wg.Done()
+			_, err := service.Vote(ctx, otherID, groupID, pollID, []int{option % 3})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	results, err = service.GetPoll(ctx, otherID, groupID, pollID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), results.TotalVoters)
+	assert.Len(t, results.MyOptions, 1)
+
+	// Quiz: the answer is final and the correct option shows after it.
+	correct := 1
+	quizID := sendPoll(&PollParams{Options: 2, Anonymous: true, CorrectOption: &correct})
+	results, err = service.GetPoll(ctx, memberID, groupID, quizID)
+	require.NoError(t, err)
+	assert.True(t, results.Quiz)
+	assert.Nil(t, results.CorrectOption)
+	_, err = service.Vote(ctx, memberID, groupID, quizID, []int{0, 1})
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+	results, err = service.Vote(ctx, memberID, groupID, quizID, []int{0})
+	require.NoError(t, err)
+	require.NotNil(t, results.CorrectOption)
+	assert.Equal(t, int16(1), *results.CorrectOption)
+	_, err = service.Vote(ctx, memberID, groupID, quizID, []int{1})
+	requireBusinessCode(t, err, "POLL_ALREADY_ANSWERED")
+	_, err = service.RetractVote(ctx, memberID, groupID, quizID)
+	requireBusinessCode(t, err, "POLL_ALREADY_ANSWERED")
+	_, err = service.ListVoters(ctx, memberID, groupID, quizID, nil, "", 10)
+	requireBusinessCode(t, err, "FORBIDDEN")
+
+	// Only the sender or an admin closes a poll; closed polls take no votes.
+	_, err = service.ClosePoll(ctx, memberID, groupID, quizID)
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+	results, err = service.ClosePoll(ctx, ownerID, groupID, quizID)
+	require.NoError(t, err)
+	assert.True(t, results.Closed)
+	_, err = service.ClosePoll(ctx, ownerID, groupID, quizID)
+	require.NoError(t, err)
+	_, err = service.Vote(ctx, otherID, groupID, quizID, []int{1})
+	requireBusinessCode(t, err, "POLL_CLOSED")
+
+	// A poll past its close date is closed too.
+	closesAt := time.Now().Add(time.Hour)
+	timedID := sendPoll(&PollParams{Options: 2, ClosesAt: &closesAt})
+	_, err = testPool.Exec(ctx, `UPDATE polls SET closes_at = now() - interval '1 second' WHERE message_id = $1`, timedID.String())
+	require.NoError(t, err)
+	_, err = service.Vote(ctx, memberID, groupID, timedID, []int{0})
+	requireBusinessCode(t, err, "POLL_CLOSED")
+
+	// Deleting the message deletes its poll.
+	require.NoError(t, messages.DeleteMessages(ctx, ownerID, groupID, []ulid.ULID{pollID}, true))
+	_, err = service.GetPoll(ctx, memberID, groupID, pollID)
+	requireBusinessCode(t, err, "MESSAGE_NOT_FOUND")
+}
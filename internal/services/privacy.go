@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// PrivacySettings are a user's privacy choices.
+type PrivacySettings struct {
+	// ReadReceipts shares the user's read position with other members.
+	// Users who turn it off also stop seeing other members' positions.
+	ReadReceipts bool `json:"read_receipts"`
+	// ForwardAttribution links forwarded copies of the user's messages
+	// back to the user.
+	ForwardAttribution bool `json:"forward_attribution"`
+}
+
+// PrivacySettingsParams changes privacy settings. Nil fields are left
+// unchanged.
+type PrivacySettingsParams struct {
+	ReadReceipts       *bool
+	ForwardAttribution *bool
+}
+
+// PrivacyService provides business logic for users' privacy settings.
+type PrivacyService struct {
+	repo    repos.PrivacyRepository
+	updates *UpdatesService
+}
+
+// NewPrivacyService creates a new PrivacyService.
+func NewPrivacyService(repo repos.PrivacyRepository, updates *UpdatesService) *PrivacyService {
+	return &PrivacyService{repo: repo, updates: updates}
+}
+
+// GetPrivacySettings returns the user's privacy settings.
+func (s *PrivacyService) GetPrivacySettings(ctx context.Context, userID ulid.ULID) (*PrivacySettings, error) {
+	settings, err := s.repo.GetPrivacySettings(ctx, userID)
+	if errors.Is(err, repos.ErrNotFound) {
+		return defaultPrivacySettings(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return privacySettings(settings), nil
+}
+
+// UpdatePrivacySettings changes the user's privacy settings and syncs them
+// to the user's devices.
+func (s *PrivacyService) UpdatePrivacySettings(ctx context.Context, userID ulid.ULID, params PrivacySettingsParams) (*PrivacySettings, error) {
+	var update repos.PrivacySettingsUpdate
+	if params.ReadReceipts != nil {
+		update.ReadReceipts = sql.NullBool{Bool: *params.ReadReceipts, Valid: true}
+	}
+	if params.ForwardAttribution != nil {
+		update.ForwardAttribution = sql.NullBool{Bool: *params.ForwardAttribution, Valid: true}
+	}
+	var settings *PrivacySettings
+	err := s.updates.InTx(ctx, func(ctx context.Context) error {
+		row, err := s.repo.UpdatePrivacySettings(ctx, userID, update)
+		if err != nil {
+			return err
+		}
+		settings = privacySettings(row)
+		return s.updates.Publish(ctx, userID, UpdatePrivacy, settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func defaultPrivacySettings() *PrivacySettings {
+	return &PrivacySettings{ReadReceipts: true, ForwardAttribution: true}
+}
+
+func privacySettings(row *db.UserPrivacySetting) *PrivacySettings {
+	return &PrivacySettings{ReadReceipts: row.ReadReceipts, ForwardAttribution: row.ForwardAttribution}
+}
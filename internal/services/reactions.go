@@ -0,0 +1,338 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	// maxReactionsPerUser caps the emoji one user can put on a message.
+	maxReactionsPerUser = 3
+	// maxReactionsPerMessage caps the distinct emoji on a message.
+	maxReactionsPerMessage = 20
+	// maxEmojiBytes fits the longest emoji sequences, such as flags and
+	// family emoji with skin tones.
+	maxEmojiBytes = 32
+	// maxAllowedReactions caps the emoji a chat can allow explicitly.
+	maxAllowedReactions = 100
+
+	defaultReactionPageSize = 50
+	maxReactionPageSize     = 100
+)
+
+// Reaction actions reported in a ReactionsUpdate.
+const (
+	ReactionAdded   = "added"
+	ReactionRemoved = "removed"
+)
+
+// MessageReactions is a message's reaction counts per emoji together with
+// the caller's own reactions.
+type MessageReactions struct {
+	ChatID      string          `json:"chat_id"`
+	MessageID   string          `json:"message_id"`
+	Reactions   json.RawMessage `json:"reactions"`
+	MyReactions []string        `json:"my_reactions"`
+}
+
+// ReactionsUpdate tells a chat's members that a reaction was added to or
+// removed from a message, with the message's new counts. Channel
+// subscribers only get the counts; who reacted is sent to the reactor alone.
+type ReactionsUpdate struct {
+	ChatID    string          `json:"chat_id"`
+	MessageID string          `json:"message_id"`
+	UserID    string          `json:"user_id,omitempty"`
+	Emoji     string          `json:"emoji,omitempty"`
+	Action    string          `json:"action,omitempty"`
+	Reactions json.RawMessage `json:"reactions"`
+}
+
+// ChatReactionsUpdate tells a chat's members which emoji they can react
+// with. A null list allows every emoji.
+type ChatReactionsUpdate struct {
+	ChatID           string   `json:"chat_id"`
+	AllowedReactions []string `json:"allowed_reactions"`
+}
+
+// ReactionPage is one page of the users who reacted to a message.
+type ReactionPage struct {
+	Items      []db.ListReactionsRow `json:"items"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+// ReactionsService provides business logic for emoji reactions on messages.
+type ReactionsService struct {
+	repo     repos.ReactionRepository
+	messages repos.MessageRepository
+	chats    *ChatsService
+}
+
+// NewReactionsService creates a new ReactionsService.
+func NewReactionsService(repo repos.ReactionRepository, messages repos.MessageRepository, chats *ChatsService) *ReactionsService {
+	return &ReactionsService{repo: repo, messages: messages, chats: chats}
+}
+
+// AddReaction puts an emoji on a message. Adding a reaction the user already
+// has changes nothing. Members see the new counts in real time and in their
+// update log.
+func (s *ReactionsService) AddReaction(ctx context.Context, userID, chatID, messageID ulid.ULID, emoji string) (*MessageReactions, error) {
+	if err := validateEmoji(emoji); err != nil {
+		return nil, err
+	}
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return nil, err
+	}
+	if allowed := access.Chat.AllowedReactions; allowed != nil && !slices.Contains(allowed, emoji) {
+		return nil, &BusinessError{Code: string(utils.ErrReactionNotAllowed), Message: "This reaction is not allowed in this chat"}
+	}
+	if err := checkDirectBlock(ctx, s.chats.contacts, access.Chat, userID); err != nil {
+		return nil, err
+	}
+	msg, err := s.getMessage(ctx, userID, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The message stays locked while the limits are checked, so concurrent
+	// reactions cannot slip past them together.
+	var counts json.RawMessage
+	var mine []string
+	err = s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if counts, err = s.repo.LockMessageReactions(ctx, chatID, messageID); err != nil {
+			return err
+		}
+		if mine, err = s.repo.ListUserReactions(ctx, messageID, userID); err != nil {
+			return err
+		}
+		if slices.Contains(mine, emoji) {
+			return nil
+		}
+		if len(mine) >= maxReactionsPerUser {
+			return reactionLimit(fmt.Sprintf("You can put at most %d reactions on a message", maxReactionsPerUser))
+		}
+		var current map[string]int
+		if err := json.Unmarshal(counts, &current); err != nil {
+			return err
+		}
+		if _, ok := current[emoji]; !ok && len(current) >= maxReactionsPerMessage {
+			return reactionLimit(fmt.Sprintf("A message can have at most %d different reactions", maxReactionsPerMessage))
+		}
+
+		if counts, err = s.repo.AddReaction(ctx, chatID, messageID, userID, emoji, maxReactionsPerUser, maxReactionsPerMessage); err != nil {
+			return err
+		}
+		mine = append(mine, emoji)
+		return s.publish(ctx, access.Chat, messageID, userID, emoji, ReactionAdded, counts)
+	})
+	if errors.Is(err, repos.ErrNotFound) {
+		return nil, messageNotFound()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &MessageReactions{ChatID: msg.ChatID, MessageID: msg.ID, Reactions: counts, MyReactions: mine}, nil
+}
+
+// RemoveReaction takes an emoji off a message. Removing a reaction the user
+// does not have changes nothing.
+func (s *ReactionsService) RemoveReaction(ctx context.Context, userID, chatID, messageID ulid.ULID, emoji string) (*MessageReactions, error) {
+	if err := validateEmoji(emoji); err != nil {
+		return nil, err
+	}
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := s.getMessage(ctx, userID, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	var counts json.RawMessage
+	var mine []string
+	err = s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		counts, err = s.repo.RemoveReaction(ctx, chatID, messageID, userID, emoji)
+		removed := err == nil
+		if errors.Is(err, repos.ErrNotFound) {
+			counts, err = msg.Reactions, nil
+		}
+		if err != nil {
+			return err
+		}
+		if mine, err = s.repo.ListUserReactions(ctx, messageID, userID); err != nil || !removed {
+			return err
+		}
+		return s.publish(ctx, access.Chat, messageID, userID, emoji, ReactionRemoved, counts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MessageReactions{ChatID: msg.ChatID, MessageID: msg.ID, Reactions: counts, MyReactions: mine}, nil
+}
+
+// ListReactions returns a page of the users who reacted to a message, oldest
+// first, optionally only those who used one emoji. Channel subscribers
+// cannot see each other, so there only admins can list reactions.
+func (s *ReactionsService) ListReactions(ctx context.Context, userID, chatID, messageID ulid.ULID, emoji, cursor string, limit int) (*ReactionPage, error) {
+	if emoji != "" {
+		if err := validateEmoji(emoji); err != nil {
+			return nil, err
+		}
+	}
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return nil, err
+	}
+	if access.Chat.Type == db.ChatTypeChannel && access.Member.Role == db.ChatMemberRoleMember {
+		return nil, forbiddenRole("Only admins can see who reacted in a channel")
+	}
+	if _, err := s.getMessage(ctx, userID, chatID, messageID); err != nil {
+		return nil, err
+	}
+	limit = clampPageSize(limit, defaultReactionPageSize, maxReactionPageSize)
+
+	var after *repos.ReactionCursor
+	if cursor != "" {
+		parts, err := utils.DecodeCursor(cursor, 3)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		micros, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		after = &repos.ReactionCursor{CreatedAt: time.UnixMicro(micros), UserID: parts[1], Emoji: parts[2]}
+	}
+
+	rows, err := s.repo.ListReactions(ctx, messageID, emoji, after, int32(limit+1))
+	if err != nil {
+		return nil, err
+	}
+	page := &ReactionPage{Items: rows}
+	if len(rows) > limit {
+		page.Items = rows[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = utils.EncodeCursor(strconv.FormatInt(last.CreatedAt.Time.UnixMicro(), 10), last.UserID, last.Emoji)
+	}
+	return page, nil
+}
+
+// SetAllowedReactions limits the emoji members of a group or channel can
+// react with. A nil list allows every emoji and an empty list turns
+// reactions off. Reactions already on messages are kept.
+func (s *ReactionsService) SetAllowedReactions(ctx context.Context, actorID, chatID ulid.ULID, emoji []string) error {
+	access, err := s.chats.authorizeGroup(ctx, actorID, chatID, PermChangeInfo)
+	if err != nil {
+		return err
+	}
+	if len(emoji) > maxAllowedReactions {
+		return &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("A chat can allow at most %d reactions", maxAllowedReactions)}
+	}
+	var allowed []string
+	if emoji != nil {
+		allowed = make([]string, 0, len(emoji))
+		for _, e := range emoji {
+			if err := validateEmoji(e); err != nil {
+				return err
+			}
+			if !slices.Contains(allowed, e) {
+				allowed = append(allowed, e)
+			}
+		}
+	}
+	err = s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.SetChatAllowedReactions(ctx, chatID, allowed); err != nil {
+			return err
+		}
+		update := ChatReactionsUpdate{ChatID: chatID.String(), AllowedReactions: allowed}
+		if err := s.chats.updates.PublishToChat(ctx, chatID, UpdateChatReactions, update); err != nil {
+			return err
+		}
+		return s.chats.recordAudit(ctx, chatID, actorID, AuditReactionsChanged, auditTarget{},
+			map[string][]string{"allowed": access.Chat.AllowedReactions}, map[string][]string{"allowed": allowed})
+	})
+	if errors.Is(err, repos.ErrNotFound) {
+		return chatNotFound()
+	}
+	return err
+}
+
+// getMessage returns a message the user can see that has not been deleted.
+func (s *ReactionsService) getMessage(ctx context.Context, userID, chatID, messageID ulid.ULID) (*db.Message, error) {
+	msgs, err := s.messages.ListVisibleMessages(ctx, chatID, userID, []ulid.ULID{messageID})
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 || msgs[0].DeletedAt.Valid {
+		return nil, messageNotFound()
+	}
+	return &msgs[0], nil
+}
+
+// publish sends a reaction change to the chat's members. Channel
+// subscribers cannot see who reacted, so a channel gets only the new counts
+// and the reactor's own devices get the full update.
+func (s *ReactionsService) publish(ctx context.Context, chat *db.Chat, messageID, userID ulid.ULID, emoji, action string, counts json.RawMessage) error {
+	chatID := ulid.MustParse(chat.ID)
+	update := ReactionsUpdate{
+		ChatID:    chat.ID,
+		MessageID: messageID.String(),
+		UserID:    userID.String(),
+		Emoji:     emoji,
+		Action:    action,
+		Reactions: counts,
+	}
+	if chat.Type != db.ChatTypeChannel {
+		return s.chats.updates.PublishToChat(ctx, chatID, UpdateReactions, update)
+	}
+	if err := s.chats.updates.Publish(ctx, userID, UpdateReactions, update); err != nil {
+		return err
+	}
+	return s.chats.updates.PublishToChat(ctx, chatID, UpdateReactions, ReactionsUpdate{
+		ChatID:    chat.ID,
+		MessageID: update.MessageID,
+		Reactions: counts,
+	})
+}
+
+// validateEmoji accepts a single short emoji sequence. The server cannot
+// tell every valid sequence apart, so it only rejects text: an emoji must
+// contain a non-ASCII character and no spaces or control characters.
+func validateEmoji(emoji string) error {
+	invalid := &BusinessError{Code: string(utils.ErrValidation), Message: "Reaction must be a single emoji"}
+	if emoji == "" || len(emoji) > maxEmojiBytes || !utf8.ValidString(emoji) {
+		return invalid
+	}
+	hasEmoji := false
+	for _, r := range emoji {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return invalid
+		}
+		if r >= utf8.RuneSelf {
+			hasEmoji = true
+		}
+	}
+	if !hasEmoji {
+		return invalid
+	}
+	return nil
+}
+
+func reactionLimit(message string) *BusinessError {
+	return &BusinessError{Code: string(utils.ErrReactionLimit), Message: message}
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/messenger/backend/internal/storage/postgres"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReactions_CountsLimitsAndAllowedList_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	notifier := &recordingNotifier{}
+	chats.updates = NewUpdatesService(postgres.NewPostgresUpdateRepository(testQueries), testDB, notifier)
+	messages := setupMessagesService(chats)
+	service := NewReactionsService(postgres.NewPostgresReactionRepository(testQueries), postgres.NewPostgresMessageRepository(testQueries), chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	memberID := createUser(t, ctx, "member")
+	ownerDevice := createDevice(t, ctx, ownerID)
+	group, err := chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team"})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+	require.NoError(t, chats.AddMember(ctx, ownerID, groupID, memberID))
+	msg, _, err := messages.SendMessage(ctx, ownerID, groupID, SendMessageParams{SenderDeviceID: ownerDevice, ContentType: "text", Ciphertext: testCiphertext()})
+	require.NoError(t, err)
+	msgID := ulid.MustParse(msg.ID)
+	counts := func(r *MessageReactions) map[string]int {
+		t.Helper()
+		var m map[string]int
+		require.NoError(t, json.Unmarshal(r.Reactions, &m))
+		return m
+	}
+
+	_, err = service.AddReaction(ctx, memberID, groupID, msgID, "ok")
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+
+	// Counts aggregate across users and adding twice changes nothing.
+	_, err = service.AddReaction(ctx, ownerID, groupID, msgID, "👍")
+	require.NoError(t, err)
+	r, err := service.AddReaction(ctx, memberID, groupID, msgID, "👍")
+	require.NoError(t, err)
+	r, err = service.AddReaction(ctx, memberID, groupID, msgID, "👍")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"👍": 2}, counts(r))
+	assert.Equal(t, []string{"👍"}, r.MyReactions)
+
+	last := notifier.updates[ownerID][len(notifier.updates[ownerID])-1]
+	require.Equal(t, UpdateReactions, last.Type)
+	var update ReactionsUpdate
+	require.NoError(t, json.Unmarshal(last.Payload, &update))
+	assert.Equal(t, memberID.String(), update.UserID)
+	assert.Equal(t, ReactionAdded, update.Action)
+
+	// Each user has a limited number of reactions per message.
+	for _, emoji := range []string{"🔥", "🎉"} {
+		_, err = service.AddReaction(ctx, memberID, groupID, msgID, emoji)
+		require.NoError(t, err)
+	}
+	_, err = service.AddReaction(ctx, memberID, groupID, msgID, "😂")
+	requireBusinessCode(t, err, "REACTION_LIMIT_REACHED")
+	r, err = service.RemoveReaction(ctx, memberID, groupID, msgID, "🎉")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"👍": 2, "🔥": 1}, counts(r))
+	assert.Equal(t, []string{"👍", "🔥"}, r.MyReactions)
+
+	// History sync carries the counts and the caller's own reactions.
+	got, err := messages.GetMessage(ctx, memberID, groupID, msgID, nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"👍": 2, "🔥": 1}`, string(got.Reactions))
+	assert.Equal(t, []string{"👍", "🔥"}, got.MyReactions)
+
+	// Who reacted, paged and filtered by emoji.
+	page, err := service.ListReactions(ctx, memberID, groupID, msgID, "", "", 2)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	require.NotEmpty(t, page.NextCursor)
+	page, err = service.ListReactions(ctx, memberID, groupID, msgID, "", page.NextCursor, 2)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Empty(t, page.NextCursor)
+	page, err = service.ListReactions(ctx, memberID, groupID, msgID, "👍", "", 10)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, ownerID.String(), page.Items[0].UserID)
+
+	// Admins restrict the allowed reactions; existing ones stay.
+	err = service.SetAllowedReactions(ctx, memberID, groupID, []string{"👍"})
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+	require.NoError(t, service.SetAllowedReactions(ctx, ownerID, groupID, []string{"👍", "👍"}))
+	last = notifier.updates[memberID][len(notifier.updates[memberID])-1]
+	require.Equal(t, UpdateChatReactions, last.Type)
+	assert.JSONEq(t, `{"chat_id": "`+group.ID+`", "allowed_reactions": ["👍"]}`, string(last.Payload))
+	_, err = service.AddReaction(ctx, ownerID, groupID, msgID, "🔥")
+	requireBusinessCode(t, err, "REACTION_NOT_ALLOWED")
+	require.NoError(t, service.SetAllowedReactions(ctx, ownerID, groupID, []string{}))
+	_, err = service.AddReaction(ctx, ownerID, groupID, msgID, "👍")
+	requireBusinessCode(t, err, "REACTION_NOT_ALLOWED")
+	require.NoError(t, service.SetAllowedReactions(ctx, ownerID, groupID, nil))
+	_, err = service.AddReaction(ctx, ownerID, groupID, msgID, "🔥")
+	require.NoError(t, err)
+}
+
+func TestReactions_ChannelHidesReactor_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	notifier := &recordingNotifier{}
+	chats.updates.notifier = notifier
+	service := NewReactionsService(postgres.NewPostgresReactionRepository(testQueries), postgres.NewPostgresMessageRepository(testQueries), chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	reactorID := createUser(t, ctx, "reactor")
+	otherID := createUser(t, ctx, "other")
+	channel, err := chats.CreateChannel(ctx, ownerID, CreateChannelParams{Title: "News"})
+	require.NoError(t, err)
+	channelID := ulid.MustParse(channel.ID)
+	for _, userID := range []ulid.ULID{reactorID, otherID} {
+		_, err := chats.Subscribe(ctx, userID, channelID)
+		require.NoError(t, err)
+	}
+	post, _, err := setupMessagesService(chats).SendMessage(ctx, ownerID, channelID, SendMessageParams{
+		SenderDeviceID: createDevice(t, ctx, ownerID),
+		ContentType:    "text",
+		Ciphertext:     testCiphertext(),
+	})
+	require.NoError(t, err)
+
+	_, err = service.AddReaction(ctx, reactorID, channelID, ulid.MustParse(post.ID), "👍")
+	require.NoError(t, err)
+
+	// Other subscribers only learn the new counts.
+	last := notifier.updates[otherID][len(notifier.updates[otherID])-1]
+	require.Equal(t, UpdateReactions, last.Type)
+	assert.Equal(t, channel.ID, last.ChatID)
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal(last.Payload, &payload))
+	assert.NotContains(t, payload, "user_id")
+	assert.NotContains(t, payload, "emoji")
+	assert.Equal(t, map[string]any{"👍": float64(1)}, payload["reactions"])
+
+	// The reactor's own devices learn what they reacted with.
+	diff, err := chats.updates.GetDifference(ctx, reactorID, 0, nil, 100)
+	require.NoError(t, err)
+	mine := diff.Updates[len(diff.Updates)-1]
+	require.Equal(t, UpdateReactions, mine.Type)
+	var update ReactionsUpdate
+	require.NoError(t, json.Unmarshal(mine.Payload, &update))
+	assert.Equal(t, reactorID.String(), update.UserID)
+	assert.Equal(t, "👍", update.Emoji)
+}
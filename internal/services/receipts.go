@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+// Receipt statuses. A message at or below a member's position has that
+// status; above every position it is only sent.
+const (
+	ReceiptDelivered = "delivered"
+	ReceiptRead      = "read"
+)
+
+// ReceiptUpdate tells a sender that another member received or read the
+// chat up to Seq.
+type ReceiptUpdate struct {
+	ChatID string `json:"chat_id"`
+	UserID string `json:"user_id"`
+	Status string `json:"status"`
+	Seq    int64  `json:"seq"`
+}
+
+// ChatReceipts is how far the other members of a chat have received and
+// read it. Messages up to DeliveredSeq reached at least one of their
+// devices and messages up to ReadSeq were read by at least one of them.
+// ReadSeq is 0 when the caller has turned read receipts off.
+type ChatReceipts struct {
+	ChatID       string `json:"chat_id"`
+	DeliveredSeq int64  `json:"delivered_seq"`
+	ReadSeq      int64  `json:"read_seq"`
+}
+
+// ReceiptsService provides business logic for delivery and read receipts.
+type ReceiptsService struct {
+	repo    repos.ReceiptRepository
+	chats   *ChatsService
+	privacy *PrivacyService
+}
+
+// NewReceiptsService creates a new ReceiptsService.
+func NewReceiptsService(repo repos.ReceiptRepository, chats *ChatsService, privacy *PrivacyService) *ReceiptsService {
+	return &ReceiptsService{repo: repo, chats: chats, privacy: privacy}
+}
+
+// MarkDelivered records that one of the user's devices received the chat up
+// to seq and tells the senders of the newly delivered messages. It returns
+// the device's delivery position.
+func (s *ReceiptsService) MarkDelivered(ctx context.Context, userID, chatID, deviceID ulid.ULID, seq int64) (int64, error) {
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkReceiptSeq(access.Chat, seq); err != nil {
+		return 0, err
+	}
+	var delivered int64
+	err = s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		row, err := s.repo.MarkChatDelivered(ctx, userID, chatID, deviceID, seq)
+		if err != nil {
+			return err
+		}
+		delivered = row.DeliveredSeq
+		return s.notifySenders(ctx, access.Chat, userID, ReceiptDelivered, row.PreviousUserSeq, row.DeliveredSeq)
+	})
+	if errors.Is(err, repos.ErrNotFound) {
+		return 0, &BusinessError{Code: string(utils.ErrAuthDeviceRevoked), Message: "Device is unknown or revoked"}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return delivered, nil
+}
+
+// MarkRead moves the user's read position in a chat forward to seq, or to
+// the newest message when seq is 0, and clears the marked-unread flag. The
+// user's devices get the new chat state and, unless the user turned read
+// receipts off, the senders of the newly read messages get a receipt. It
+// returns the read position.
+func (s *ReceiptsService) MarkRead(ctx context.Context, userID, chatID ulid.ULID, seq int64) (int64, error) {
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return 0, err
+	}
+	if seq == 0 {
+		seq = access.Chat.LastSeq
+	}
+	if err := checkReceiptSeq(access.Chat, seq); err != nil {
+		return 0, err
+	}
+	settings, err := s.privacy.GetPrivacySettings(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	var read int64
+	err = s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		row, err := s.repo.MarkChatRead(ctx, userID, chatID, seq)
+		if err != nil {
+			return err
+		}
+		read = row.ReadSeq
+		state, err := s.chats.getChatState(ctx, userID, chatID)
+		if err != nil {
+			return err
+		}
+		if err := s.chats.updates.Publish(ctx, userID, UpdateChatState, state); err != nil {
+			return err
+		}
+		if !settings.ReadReceipts {
+			return nil
+		}
+		return s.notifySenders(ctx, access.Chat, userID, ReceiptRead, row.PreviousReadSeq, row.ReadSeq)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return read, nil
+}
+
+// GetReceipts returns how far the chat's other members have received and
+// read it.
+func (s *ReceiptsService) GetReceipts(ctx context.Context, userID, chatID ulid.ULID) (*ChatReceipts, error) {
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return nil, err
+	}
+	row, err := s.repo.GetChatReceipts(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := s.privacy.GetPrivacySettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	receipts := &ChatReceipts{ChatID: chatID.String(), DeliveredSeq: row.DeliveredSeq}
+	if settings.ReadReceipts {
+		receipts.ReadSeq = row.ReadSeq
+		// A message that was read was delivered too.
+		receipts.DeliveredSeq = max(receipts.DeliveredSeq, row.ReadSeq)
+	}
+	return receipts, nil
+}
+
+// notifySenders sends a receipt to the other senders of the messages in
+// (from, to]. Read receipts only go to senders who share their own.
+// Channels have no receipts.
+func (s *ReceiptsService) notifySenders(ctx context.Context, chat *db.Chat, userID ulid.ULID, status string, from, to int64) error {
+	if to <= from || chat.Type == db.ChatTypeChannel {
+		return nil
+	}
+	chatID := ulid.MustParse(chat.ID)
+	senders, err := s.repo.ListMessageSenders(ctx, chatID, userID, from, to)
+	if err != nil {
+		return err
+	}
+	update := ReceiptUpdate{ChatID: chat.ID, UserID: userID.String(), Status: status, Seq: to}
+	for _, sender := range senders {
+		if status == ReceiptRead && !sender.ReadReceipts {
+			continue
+		}
+		senderID, err := ulid.Parse(sender.SenderID)
+		if err != nil {
+			return err
+		}
+		if err := s.chats.updates.Publish(ctx, senderID, UpdateReceipt, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkReceiptSeq(chat *db.Chat, seq int64) error {
+	if seq < 0 || seq > chat.LastSeq {
+		return &BusinessError{
+			Code:    string(utils.ErrValidation),
+			Message: "Seq must be between 0 and the chat's latest seq",
+			Details: map[string]any{"seq": chat.LastSeq},
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/messenger/backend/internal/storage/postgres"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceipts_DeliveryReadAndPrivacy_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	notifier := &recordingNotifier{}
+	chats.updates = NewUpdatesService(postgres.NewPostgresUpdateRepository(testQueries), testDB, notifier)
+	messages := setupMessagesService(chats)
+	privacy := NewPrivacyService(postgres.NewPostgresPrivacyRepository(testQueries), chats.updates)
+	service := NewReceiptsService(postgres.NewPostgresReceiptRepository(testQueries), chats, privacy)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	aliceID := createUser(t, ctx, "alice")
+	bobID := createUser(t, ctx, "bob")
+	aliceDevice := createDevice(t, ctx, aliceID)
+	bobDevice := createDevice(t, ctx, bobID)
+	chat, _, err := chats.GetOrCreateDirectChat(ctx, aliceID, bobID)
+	require.NoError(t, err)
+	chatID := ulid.MustParse(chat.ID)
+	for i := 0; i < 3; i++ {
+		_, _, err := messages.SendMessage(ctx, aliceID, chatID, SendMessageParams{SenderDeviceID: aliceDevice, ContentType: "text", Ciphertext: testCiphertext()})
+		require.NoError(t, err)
+	}
+	lastReceipt := func() ReceiptUpdate {
+		t.Helper()
+		updates := notifier.updates[aliceID]
+		require.NotEmpty(t, updates)
+		last := updates[len(updates)-1]
+		require.Equal(t, UpdateReceipt, last.Type)
+		var receipt ReceiptUpdate
+		require.NoError(t, json.Unmarshal(last.Payload, &receipt))
+		return receipt
+	}
+
+	// Sent: nobody has received anything yet; the sender's own messages
+	// count as read for them.
+	receipts, err := service.GetReceipts(ctx, aliceID, chatID)
+	require.NoError(t, err)
+	assert.Zero(t, receipts.DeliveredSeq)
+	list, err := chats.ListChats(ctx, aliceID, ChatListOptions{}, "", 10)
+	require.NoError(t, err)
+	assert.Zero(t, list.Items[0].UnreadCount)
+
+	_, err = service.MarkDelivered(ctx, bobID, chatID, aliceDevice, 2)
+	requireBusinessCode(t, err, "AUTH_DEVICE_REVOKED")
+	_, err = service.MarkDelivered(ctx, bobID, chatID, bobDevice, 9)
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+	seq, err := service.MarkDelivered(ctx, bobID, chatID, bobDevice, 2)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, seq)
+	assert.Equal(t, ReceiptUpdate{ChatID: chat.ID, UserID: bobID.String(), Status: ReceiptDelivered, Seq: 2}, lastReceipt())
+
+	// Reading clears marked-unread and moves the unread count.
+	markedUnread := true
+	_, err = chats.UpdateChatState(ctx, bobID, chatID, ChatStateParams{MarkedUnread: &markedUnread})
+	require.NoError(t, err)
+	seq, err = service.MarkRead(ctx, bobID, chatID, 1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, seq)
+	assert.Equal(t, ReceiptUpdate{ChatID: chat.ID, UserID: bobID.String(), Status: ReceiptRead, Seq: 1}, lastReceipt())
+	list, err = chats.ListChats(ctx, bobID, ChatListOptions{}, "", 10)
+	require.NoError(t, err)
+	assert.False(t, list.Items[0].MarkedUnread)
+	assert.EqualValues(t, 2, list.Items[0].UnreadCount)
+
+	receipts, err = service.GetReceipts(ctx, aliceID, chatID)
+	require.NoError(t, err)
+	assert.Equal(t, &ChatReceipts{ChatID: chat.ID, DeliveredSeq: 2, ReadSeq: 1}, receipts)
+
+	// With read receipts off Bob's reads are not reported, and Alice stops
+	// seeing read positions once she turns hers off too.
+	off := false
+	_, err = privacy.UpdatePrivacySettings(ctx, bobID, PrivacySettingsParams{ReadReceipts: &off})
+	require.NoError(t, err)
+	before := len(notifier.updates[aliceID])
+	seq, err = service.MarkRead(ctx, bobID, chatID, 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, seq)
+	assert.Len(t, notifier.updates[aliceID], before)
+	receipts, err = service.GetReceipts(ctx, aliceID, chatID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, receipts.ReadSeq)
+
+	on := true
+	_, err = privacy.UpdatePrivacySettings(ctx, bobID, PrivacySettingsParams{ReadReceipts: &on})
+	require.NoError(t, err)
+	_, err = privacy.UpdatePrivacySettings(ctx, aliceID, PrivacySettingsParams{ReadReceipts: &off})
+	require.NoError(t, err)
+	receipts, err = service.GetReceipts(ctx, aliceID, chatID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, receipts.ReadSeq)
+	assert.EqualValues(t, 2, receipts.DeliveredSeq)
+}
@@ -0,0 +1,361 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	// maxScheduledPerChat caps the messages a user can have scheduled in
+	// one chat.
+	maxScheduledPerChat = 100
+	// maxScheduleAhead is how far in the future a message can be scheduled.
+	maxScheduleAhead = 366 * 24 * time.Hour
+	// schedulerBatchSize is how many due messages one scheduler run claims.
+	schedulerBatchSize = 100
+	// schedulerLease is how long a claimed message is reserved for the
+	// instance sending it. Messages of an instance that died are claimed
+	// again once the lease runs out.
+	schedulerLease = time.Minute
+)
+
+// Scheduled message actions reported in a ScheduledUpdate.
+const (
+	ScheduledCreated   = "created"
+	ScheduledEdited    = "edited"
+	ScheduledCancelled = "cancelled"
+	ScheduledSent      = "sent"
+	ScheduledFailed    = "failed"
+)
+
+// ScheduleMessageParams describes a message to send later: at SendAt, or
+// with WhenOnline as soon as the peer of a direct chat is next online. The
+// content is encrypted now, like for SendMessage.
+type ScheduleMessageParams struct {
+	SenderDeviceID ulid.ULID
+	ThreadID       *ulid.ULID
+	ReplyToID      *ulid.ULID
+	ContentType    string
+	Ciphertext     []byte
+	Recipients     []repos.DevicePayload
+	SendAt         *time.Time
+	WhenOnline     bool
+}
+
+// EditScheduledParams changes a scheduled message. A nil Content keeps the
+// content and a nil SendAt keeps the time; a new SendAt stops waiting for
+// the peer to come online.
+type EditScheduledParams struct {
+	Content *EditMessageParams
+	SendAt  *time.Time
+}
+
+// ScheduledUpdate syncs a user's scheduled messages across their devices.
+// MessageID is set once the message was sent and ErrorCode when sending
+// failed.
+type ScheduledUpdate struct {
+	ChatID      string `json:"chat_id"`
+	ScheduledID string `json:"scheduled_id"`
+	Action      string `json:"action"`
+	MessageID   string `json:"message_id,omitempty"`
+	ErrorCode   string `json:"error_code,omitempty"`
+}
+
+// ScheduledService provides business logic for scheduled and
+// send-when-online messages.
+type ScheduledService struct {
+	repo     repos.ScheduledMessageRepository
+	messages *MessagesService
+	chats    *ChatsService
+}
+
+// NewScheduledService creates a new ScheduledService.
+func NewScheduledService(repo repos.ScheduledMessageRepository, messages *MessagesService, chats *ChatsService) *ScheduledService {
+	return &ScheduledService{repo: repo, messages: messages, chats: chats}
+}
+
+// ScheduleMessage stores a message to be sent later. The message is checked
+// as if it were sent now, and checked again when it is sent.
+func (s *ScheduledService) ScheduleMessage(ctx context.Context, userID, chatID ulid.ULID, params ScheduleMessageParams) (*db.ScheduledMessage, error) {
+	if params.WhenOnline == (params.SendAt != nil) {
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: "A scheduled message needs either a send time or to wait for the recipient to come online"}
+	}
+	if params.SendAt != nil {
+		if err := validateSendAt(*params.SendAt); err != nil {
+			return nil, err
+		}
+	}
+	send := SendMessageParams{
+		SenderDeviceID: params.SenderDeviceID,
+		ThreadID:       params.ThreadID,
+		ReplyToID:      params.ReplyToID,
+		ContentType:    params.ContentType,
+		Ciphertext:     params.Ciphertext,
+		Recipients:     params.Recipients,
+	}
+	access, err := s.messages.authorizeSend(ctx, userID, chatID, send)
+	if err != nil {
+		return nil, err
+	}
+	var onlineUserID *ulid.ULID
+	if params.WhenOnline {
+		peerID, ok := directPeer(access.Chat, userID)
+		if !ok || peerID == userID {
+			return nil, &BusinessError{Code: string(utils.ErrValidation), Message: "Messages can wait for the recipient only in direct chats"}
+		}
+		onlineUserID = &peerID
+	}
+	if err := s.messages.checkDevices(ctx, userID, chatID, params.SenderDeviceID, params.Recipients); err != nil {
+		return nil, err
+	}
+	if params.ReplyToID != nil {
+		if err := s.messages.checkReplyTarget(ctx, userID, chatID, *params.ReplyToID); err != nil {
+			return nil, err
+		}
+	}
+
+	n, err := s.repo.CountScheduledMessages(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if n >= maxScheduledPerChat {
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("You can schedule at most %d messages per chat", maxScheduledPerChat)}
+	}
+
+	var msg *db.ScheduledMessage
+	err = s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		msg, err = s.repo.CreateScheduledMessage(ctx, repos.NewScheduledMessage{
+			ChatID:         chatID,
+			SenderID:       userID,
+			SenderDeviceID: params.SenderDeviceID,
+			ThreadID:       params.ThreadID,
+			ReplyToID:      params.ReplyToID,
+			ContentType:    params.ContentType,
+			Ciphertext:     params.Ciphertext,
+			DevicePayloads: params.Recipients,
+			SendAt:         params.SendAt,
+			OnlineUserID:   onlineUserID,
+		})
+		if err != nil {
+			return err
+		}
+		return s.publish(ctx, msg, ScheduledUpdate{Action: ScheduledCreated})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// ListScheduledMessages returns the user's scheduled messages in a chat,
+// timed ones first in sending order.
+func (s *ScheduledService) ListScheduledMessages(ctx context.Context, userID, chatID ulid.ULID) ([]db.ScheduledMessage, error) {
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return nil, err
+	}
+	return s.repo.ListScheduledMessages(ctx, chatID, userID)
+}
+
+// EditScheduledMessage changes the content or the time of a scheduled
+// message. Editing a message that failed to send schedules it again.
+func (s *ScheduledService) EditScheduledMessage(ctx context.Context, userID, chatID, id ulid.ULID, params EditScheduledParams) (*db.ScheduledMessage, error) {
+	if params.Content == nil && params.SendAt == nil {
+		return nil, &BusinessError{Code: string(utils.ErrValidation), Message: "Nothing to change"}
+	}
+	if params.SendAt != nil {
+		if err := validateSendAt(*params.SendAt); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return nil, err
+	}
+	update := repos.ScheduledMessageUpdate{SendAt: params.SendAt}
+	if c := params.Content; c != nil {
+		if err := s.messages.validateEnvelope(SendMessageParams{Ciphertext: c.Ciphertext, Recipients: c.Recipients}); err != nil {
+			return nil, err
+		}
+		if err := s.messages.checkDevices(ctx, userID, chatID, c.SenderDeviceID, c.Recipients); err != nil {
+			return nil, err
+		}
+		update.Content = &repos.ScheduledContent{SenderDeviceID: c.SenderDeviceID, Ciphertext: c.Ciphertext, DevicePayloads: c.Recipients}
+	}
+
+	var msg *db.ScheduledMessage
+	err := s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if msg, err = s.repo.UpdateScheduledMessage(ctx, chatID, userID, id, update); err != nil {
+			return err
+		}
+		return s.publish(ctx, msg, ScheduledUpdate{Action: ScheduledEdited})
+	})
+	if errors.Is(err, repos.ErrNotFound) {
+		return nil, scheduledNotFound()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// CancelScheduledMessage deletes a scheduled message before it is sent.
+func (s *ScheduledService) CancelScheduledMessage(ctx context.Context, userID, chatID, id ulid.ULID) error {
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return err
+	}
+	err := s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.DeleteScheduledMessage(ctx, chatID, userID, id); err != nil {
+			return err
+		}
+		return s.chats.updates.Publish(ctx, userID, UpdateScheduled, ScheduledUpdate{ChatID: chatID.String(), ScheduledID: id.String(), Action: ScheduledCancelled})
+	})
+	if errors.Is(err, repos.ErrNotFound) {
+		return scheduledNotFound()
+	}
+	return err
+}
+
+// TouchPresence records that the user is online now. Messages waiting for
+// the user are sent on the next scheduler run.
+func (s *ScheduledService) TouchPresence(ctx context.Context, userID ulid.ULID) error {
+	return s.repo.TouchUserPresence(ctx, userID)
+}
+
+// DeliverDue sends the scheduled messages that are due and returns how
+// many were sent. Each message is claimed by a single instance, and is sent
+// with its scheduled ID as client message ID, so a message whose sender
+// crashed halfway is never sent twice.
+func (s *ScheduledService) DeliverDue(ctx context.Context) (int, error) {
+	due, err := s.repo.ClaimDueScheduledMessages(ctx, schedulerLease, schedulerBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for i := range due {
+		ok, err := s.deliver(ctx, &due[i])
+		if err != nil {
+			// The lease runs out and another run retries the message.
+			log.Printf("scheduler: failed to send scheduled message %s: %v", due[i].ID, err)
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// RunScheduler sends due scheduled messages every interval until ctx is
+// cancelled. Any number of instances can run it side by side.
+func (s *ScheduledService) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := s.DeliverDue(ctx); err != nil {
+				log.Printf("scheduler: run failed: %v", err)
+			} else if n > 0 {
+				log.Printf("scheduler: sent %d scheduled messages", n)
+			}
+		}
+	}
+}
+
+// deliver sends one claimed message and reports whether it was sent.
+// Messages that can no longer be sent, for example because the sender left
+// the chat, are marked failed; a slow mode wait is retried later.
+func (s *ScheduledService) deliver(ctx context.Context, scheduled *db.ScheduledMessage) (bool, error) {
+	senderID := ulid.MustParse(scheduled.SenderID)
+	chatID := ulid.MustParse(scheduled.ChatID)
+
+	// The message was already sent, and announced along with it, by an
+	// earlier attempt that did not finish the schedule.
+	if existing, err := s.messages.repo.GetMessageByClientID(ctx, chatID, senderID, scheduled.ID); err == nil {
+		return false, s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+			return s.finish(ctx, scheduled, existing)
+		})
+	} else if !errors.Is(err, repos.ErrNotFound) {
+		return false, err
+	}
+
+	params := SendMessageParams{
+		SenderDeviceID:  ulid.MustParse(scheduled.SenderDeviceID),
+		ThreadID:        optionalID(scheduled.ThreadID.String),
+		ReplyToID:       optionalID(scheduled.ReplyToID.String),
+		ContentType:     scheduled.ContentType,
+		Ciphertext:      scheduled.Ciphertext,
+		Recipients:      make([]repos.DevicePayload, len(scheduled.DeviceIds)),
+		ClientMessageID: scheduled.ID,
+	}
+	for i, id := range scheduled.DeviceIds {
+		params.Recipients[i] = repos.DevicePayload{DeviceID: ulid.MustParse(id), Ciphertext: scheduled.DeviceCiphertexts[i]}
+	}
+
+	// The message, its updates and the end of the schedule are stored
+	// together, so a message is never sent twice or left unannounced. If
+	// another run stores the message first, SendMessage's insert fails in a
+	// savepoint and it replays the stored message, which is then finished.
+	err := s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		msg, _, err := s.messages.SendMessage(ctx, senderID, chatID, params)
+		if err != nil {
+			return err
+		}
+		return s.finish(ctx, scheduled, msg)
+	})
+	var bizErr *BusinessError
+	if errors.As(err, &bizErr) {
+		if bizErr.Code == string(utils.ErrRateLimited) {
+			return false, nil
+		}
+		return false, s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+			if err := s.repo.FailScheduledMessage(ctx, scheduled.ID, bizErr.Code); err != nil {
+				return err
+			}
+			return s.publish(ctx, scheduled, ScheduledUpdate{Action: ScheduledFailed, ErrorCode: bizErr.Code})
+		})
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// finish removes a sent message from the schedule and tells the sender.
+func (s *ScheduledService) finish(ctx context.Context, scheduled *db.ScheduledMessage, msg *db.Message) error {
+	if err := s.repo.FinishScheduledMessage(ctx, scheduled.ID); err != nil {
+		return err
+	}
+	return s.publish(ctx, scheduled, ScheduledUpdate{Action: ScheduledSent, MessageID: msg.ID})
+}
+
+// publish tells the sender's devices about a change to a scheduled message.
+func (s *ScheduledService) publish(ctx context.Context, msg *db.ScheduledMessage, update ScheduledUpdate) error {
+	update.ChatID, update.ScheduledID = msg.ChatID, msg.ID
+	return s.chats.updates.Publish(ctx, ulid.MustParse(msg.SenderID), UpdateScheduled, update)
+}
+
+func validateSendAt(sendAt time.Time) error {
+	now := time.Now()
+	if !sendAt.After(now) {
+		return &BusinessError{Code: string(utils.ErrValidation), Message: "The send time must be in the future"}
+	}
+	if sendAt.After(now.Add(maxScheduleAhead)) {
+		return &BusinessError{Code: string(utils.ErrValidation), Message: "Messages can be scheduled at most a year ahead"}
+	}
+	return nil
+}
+
+func scheduledNotFound() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrNotFound), Message: "Scheduled message not found or already being sent"}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/messenger/backend/internal/storage/postgres"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledMessages_DeliverExactlyOnce_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	messages := setupMessagesService(chats)
+	repo := postgres.NewPostgresScheduledMessageRepository(testQueries)
+	service := NewScheduledService(repo, messages, chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	aliceID := createUser(t, ctx, "alice")
+	bobID := createUser(t, ctx, "bob")
+	aliceDevice := createDevice(t, ctx, aliceID)
+	chat, _, err := chats.GetOrCreateDirectChat(ctx, aliceID, bobID)
+	require.NoError(t, err)
+	chatID := ulid.MustParse(chat.ID)
+	group, err := chats.CreateGroup(ctx, aliceID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{bobID}})
+	require.NoError(t, err)
+	messageCount := func() int {
+		t.Helper()
+		var n int
+		require.NoError(t, testPool.QueryRow(ctx, `SELECT COUNT(*) FROM messages WHERE chat_id = $1`, chat.ID).Scan(&n))
+		return n
+	}
+	makeDue := func(id string) {
+		t.Helper()
+		_, err := testPool.Exec(ctx, `UPDATE scheduled_messages SET send_at = now() - interval '1 second' WHERE id = $1`, id)
+		require.NoError(t, err)
+	}
+	params := func(sendAt *time.Time, whenOnline bool) ScheduleMessageParams {
+		return ScheduleMessageParams{SenderDeviceID: aliceDevice, ContentType: "text", Ciphertext: testCiphertext(), SendAt: sendAt, WhenOnline: whenOnline}
+	}
+
+	past := time.Now().Add(-time.Minute)
+	_, err = service.ScheduleMessage(ctx, aliceID, chatID, params(&past, false))
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+	_, err = service.ScheduleMessage(ctx, aliceID, ulid.MustParse(group.ID), params(nil, true))
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+
+	// Not due yet: nothing is sent.
+	later := time.Now().Add(time.Hour)
+	scheduled, err := service.ScheduleMessage(ctx, aliceID, chatID, params(&later, false))
+	require.NoError(t, err)
+	sent, err := service.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	list, err := service.ListScheduledMessages(ctx, aliceID, chatID)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	// Due: sent once, then gone.
+	makeDue(scheduled.ID)
+	sent, err = service.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, 1, messageCount())
+	sent, err = service.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	list, err = service.ListScheduledMessages(ctx, aliceID, chatID)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	// A message claimed by another instance is skipped; when that instance
+	// dies after sending, the retry finishes without sending again.
+	scheduled, err = service.ScheduleMessage(ctx, aliceID, chatID, params(&later, false))
+	require.NoError(t, err)
+	makeDue(scheduled.ID)
+	claimed, err := repo.ClaimDueScheduledMessages(ctx, time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	requireBusinessCode(t, service.CancelScheduledMessage(ctx, aliceID, chatID, ulid.MustParse(scheduled.ID)), "NOT_FOUND")
+	sent, err = service.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	_, _, err = messages.SendMessage(ctx, aliceID, chatID, SendMessageParams{SenderDeviceID: aliceDevice, ContentType: "text", Ciphertext: testCiphertext(), ClientMessageID: scheduled.ID})
+	require.NoError(t, err)
+	_, err = testPool.Exec(ctx, `UPDATE scheduled_messages SET locked_until = now() - interval '1 second' WHERE id = $1`, scheduled.ID)
+	require.NoError(t, err)
+	_, err = service.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, messageCount())
+	list, err = service.ListScheduledMessages(ctx, aliceID, chatID)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	// Every sent message was announced once, and the sender heard about
+	// both sends.
+	var newMessages, sentUpdates int
+	require.NoError(t, testPool.QueryRow(ctx, `SELECT COUNT(*) FROM user_updates WHERE user_id = $1 AND type = $2`,
+		bobID.String(), UpdateNewMessage).Scan(&newMessages))
+	assert.Equal(t, 2, newMessages)
+	require.NoError(t, testPool.QueryRow(ctx, `SELECT COUNT(*) FROM user_updates WHERE user_id = $1 AND type = $2 AND payload->>'action' = $3`,
+		aliceID.String(), UpdateScheduled, ScheduledSent).Scan(&sentUpdates))
+	assert.Equal(t, 2, sentUpdates)
+
+	// Send when online waits for the recipient.
+	scheduled, err = service.ScheduleMessage(ctx, aliceID, chatID, params(nil, true))
+	require.NoError(t, err)
+	sent, err = service.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	require.NoError(t, service.TouchPresence(ctx, bobID))
+	sent, err = service.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, 3, messageCount())
+
+	// Edit and cancel.
+	scheduled, err = service.ScheduleMessage(ctx, aliceID, chatID, params(&later, false))
+	require.NoError(t, err)
+	sooner := time.Now().Add(time.Minute)
+	edited, err := service.EditScheduledMessage(ctx, aliceID, chatID, ulid.MustParse(scheduled.ID), EditScheduledParams{SendAt: &sooner})
+	require.NoError(t, err)
+	assert.Equal(t, sooner.UnixMicro(), edited.SendAt.Time.UnixMicro())
+	_, err = service.EditScheduledMessage(ctx, bobID, chatID, ulid.MustParse(scheduled.ID), EditScheduledParams{SendAt: &sooner})
+	requireBusinessCode(t, err, "NOT_FOUND")
+	require.NoError(t, service.CancelScheduledMessage(ctx, aliceID, chatID, ulid.MustParse(scheduled.ID)))
+	list, err = service.ListScheduledMessages(ctx, aliceID, chatID)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/messenger/backend/internal/utils"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	maxTopicTitleLength = 128
+	maxTopicIconColor   = 0xFFFFFF
+)
+
+// ThreadsService provides business logic for forum topics and reply threads.
+type ThreadsService struct {
+	repo      repos.ThreadRepository
+	messages  repos.MessageRepository
+	scheduled repos.ScheduledMessageRepository
+	drafts    repos.DraftRepository
+	chats     *ChatsService
+}
+
+// NewThreadsService creates a new ThreadsService.
+func NewThreadsService(repo repos.ThreadRepository, messages repos.MessageRepository, scheduled repos.ScheduledMessageRepository, drafts repos.DraftRepository, chats *ChatsService) *ThreadsService {
+	return &ThreadsService{repo: repo, messages: messages, scheduled: scheduled, drafts: drafts, chats: chats}
+}
+
+// TopicParams describes a forum topic. Nil fields are left unchanged on
+// update.
+type TopicParams struct {
+	Title     *string
+	IconColor *int32
+	Closed    *bool
+}
+
+// ThreadPage is one page of a chat's threads with the reader's unread counts.
+type ThreadPage struct {
+	Items      []db.ListThreadsWithStateRow `json:"items"`
+	NextCursor string                       `json:"next_cursor,omitempty"`
+}
+
+// SetForum turns a group into a forum organized by topics, or back into a
+// plain group. It requires the change-info permission.
+func (s *ThreadsService) SetForum(ctx context.Context, actorID, chatID ulid.ULID, enabled bool) error {
+	access, err := s.chats.authorizeGroup(ctx, actorID, chatID, PermChangeInfo)
+	if err != nil {
+		return err
+	}
+	if access.Chat.Type != db.ChatTypeGroup {
+		return &BusinessError{Code: string(utils.ErrValidation), Message: "Only groups can be forums"}
+	}
+	err = s.repo.SetChatForum(ctx, chatID, enabled)
+	if errors.Is(err, repos.ErrNotFound) {
+		return chatNotFound()
+	}
+	if err != nil {
+		return err
+	}
+	return s.chats.recordAudit(ctx, chatID, actorID, AuditForumToggled, auditTarget{},
+		map[string]bool{"is_forum": access.Chat.IsForum}, map[string]bool{"is_forum": enabled})
+}
+
+// CreateTopic opens a new topic in a forum. Anyone who may send messages
+// can start a topic.
+func (s *ThreadsService) CreateTopic(ctx context.Context, userID, chatID ulid.ULID, params TopicParams) (*db.ChatThread, error) {
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermSendMessages)
+	if err != nil {
+		return nil, err
+	}
+	if !access.Chat.IsForum {
+		return nil, notForum()
+	}
+	if params.Title == nil {
+		return nil, invalidTopicTitle()
+	}
+
+	update, err := parseTopicParams(params)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.CreateTopic(ctx, chatID, userID, update.Title.String, update.IconColor)
+}
+
+// UpdateTopic renames, recolors, closes or reopens a topic. The topic's
+// creator and members with the change-info permission may do so.
+func (s *ThreadsService) UpdateTopic(ctx context.Context, userID, chatID, threadID ulid.ULID, params TopicParams) (*db.ChatThread, error) {
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return nil, err
+	}
+	thread, err := s.getThread(ctx, chatID, threadID)
+	if err != nil {
+		return nil, err
+	}
+	if thread.Kind != db.ThreadKindTopic {
+		return nil, threadNotFound()
+	}
+	if thread.CreatedBy.String != userID.String() && !access.Permissions.Has(PermChangeInfo) {
+		return nil, forbiddenRole("Only the topic creator or admins can edit this topic")
+	}
+
+	update, err := parseTopicParams(params)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.repo.UpdateTopic(ctx, chatID, threadID, update)
+	if errors.Is(err, repos.ErrNotFound) {
+		return nil, threadNotFound()
+	}
+	return updated, err
+}
+
+// DeleteTopic removes a topic. It requires the delete-messages permission.
+// The topic's messages are deleted for everyone, its scheduled messages fail
+// and its drafts are cleared, and everyone affected is told.
+func (s *ThreadsService) DeleteTopic(ctx context.Context, userID, chatID, threadID ulid.ULID) error {
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermDeleteMessages); err != nil {
+		return err
+	}
+	topic, err := s.repo.GetThread(ctx, chatID, threadID)
+	if errors.Is(err, repos.ErrNotFound) || (err == nil && topic.Kind != db.ThreadKindTopic) {
+		return threadNotFound()
+	}
+	if err != nil {
+		return err
+	}
+
+	updates := s.chats.updates
+	err = updates.InTx(ctx, func(ctx context.Context) error {
+		// Closing the topic first keeps new messages out of it.
+		if err := s.repo.DeleteTopic(ctx, chatID, threadID); err != nil {
+			return err
+		}
+		deleted, err := s.messages.DeleteThreadMessages(ctx, chatID, threadID)
+		if err != nil {
+			return err
+		}
+		if len(deleted) > 0 {
+			update := MessagesDeletedUpdate{ChatID: chatID.String(), MessageIDs: make([]string, len(deleted)), ForEveryone: true}
+			for i, msg := range deleted {
+				update.MessageIDs[i] = msg.ID
+			}
+			if err := updates.PublishToChat(ctx, chatID, UpdateMessagesDeleted, update); err != nil {
+				return err
+			}
+		}
+
+		failed, err := s.scheduled.FailThreadScheduledMessages(ctx, threadID, string(utils.ErrThreadNotFound))
+		if err != nil {
+			return err
+		}
+		for _, msg := range failed {
+			update := ScheduledUpdate{ChatID: msg.ChatID, ScheduledID: msg.ID, Action: ScheduledFailed, ErrorCode: string(utils.ErrThreadNotFound)}
+			if err := updates.Publish(ctx, ulid.MustParse(msg.SenderID), UpdateScheduled, update); err != nil {
+				return err
+			}
+		}
+
+		drafts, err := s.drafts.ClearThreadDrafts(ctx, threadID)
+		if err != nil {
+			return err
+		}
+		for _, draft := range drafts {
+			if err := updates.Publish(ctx, ulid.MustParse(draft.UserID), UpdateDraft, draft); err != nil {
+				return err
+			}
+		}
+
+		return s.chats.recordAudit(ctx, chatID, userID, AuditTopicDeleted, auditTarget{ID: threadID.String()},
+			map[string]string{"title": topic.Title.String}, nil)
+	})
+	if errors.Is(err, repos.ErrNotFound) {
+		return threadNotFound()
+	}
+	return err
+}
+
+// ListTopics returns a page of a forum's topics, most recently active first,
+// with the caller's unread counts and notification settings.
+func (s *ThreadsService) ListTopics(ctx context.Context, userID, chatID ulid.ULID, cursor string, limit int) (*ThreadPage, error) {
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return nil, err
+	}
+	if !access.Chat.IsForum {
+		return nil, notForum()
+	}
+	limit = clampPageSize(limit, defaultChatPageSize, maxChatPageSize)
+
+	var after *repos.ThreadCursor
+	if cursor != "" {
+		parts, err := utils.DecodeCursor(cursor, 2)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		micros, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, invalidCursor()
+		}
+		after = &repos.ThreadCursor{LastActivityAt: time.UnixMicro(micros), ThreadID: parts[1]}
+	}
+
+	rows, err := s.repo.ListThreadsWithState(ctx, chatID, userID, db.ThreadKindTopic, after, int32(limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ThreadPage{Items: rows}
+	if len(rows) > limit {
+		page.Items = rows[:limit]
+		last := page.Items[limit-1]
+		activity := last.CreatedAt.Time
+		if last.LastMessageAt.Valid {
+			activity = last.LastMessageAt.Time
+		}
+		page.NextCursor = utils.EncodeCursor(strconv.FormatInt(activity.UnixMicro(), 10), last.ID)
+	}
+	return page, nil
+}
+
+// GetOrCreateReplyThread returns the reply thread of a message in a group or
+// channel, creating it on first use. The boolean result reports whether the
+// thread was created by this call.
+func (s *ThreadsService) GetOrCreateReplyThread(ctx context.Context, userID, chatID, rootMessageID ulid.ULID) (*db.ChatThread, bool, error) {
+	access, err := s.chats.Authorize(ctx, userID, chatID, PermNone)
+	if err != nil {
+		return nil, false, err
+	}
+	if !hasMemberRoles(access.Chat.Type) {
+		return nil, false, notInGroup()
+	}
+
+	thread, err := s.repo.GetReplyThread(ctx, chatID, rootMessageID)
+	if err == nil {
+		return thread, false, nil
+	}
+	if !errors.Is(err, repos.ErrNotFound) {
+		return nil, false, err
+	}
+
+	// The root must be a message the user can see that was not deleted.
+	roots, err := s.messages.ListVisibleMessages(ctx, chatID, userID, []ulid.ULID{rootMessageID})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(roots) == 0 || roots[0].DeletedAt.Valid {
+		return nil, false, messageNotFound()
+	}
+	thread, err = s.repo.CreateReplyThread(ctx, chatID, rootMessageID, userID)
+	if errors.Is(err, repos.ErrAlreadyExists) {
+		// Lost a race with another reply starting the same thread.
+		thread, err = s.repo.GetReplyThread(ctx, chatID, rootMessageID)
+		return thread, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return thread, true, nil
+}
+
+// GetThread returns a topic or reply thread with the caller's unread count
+// and notification settings.
+func (s *ThreadsService) GetThread(ctx context.Context, userID, chatID, threadID ulid.ULID) (*db.ListThreadsWithStateRow, error) {
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return nil, err
+	}
+	thread, err := s.repo.GetThreadWithState(ctx, chatID, threadID, userID)
+	if errors.Is(err, repos.ErrNotFound) {
+		return nil, threadNotFound()
+	}
+	return thread, err
+}
+
+// MarkRead moves the caller's read position in a thread forward to seq and
+// returns the resulting position. Read positions never move backwards.
+func (s *ThreadsService) MarkRead(ctx context.Context, userID, chatID, threadID ulid.ULID, seq int64) (int64, error) {
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return 0, err
+	}
+	if _, err := s.getThread(ctx, chatID, threadID); err != nil {
+		return 0, err
+	}
+	if seq < 0 {
+		return 0, &BusinessError{Code: string(utils.ErrValidation), Message: "Sequence must not be negative"}
+	}
+	var readSeq int64
+	err := s.chats.updates.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if readSeq, err = s.repo.MarkThreadRead(ctx, threadID, userID, seq); err != nil {
+			return err
+		}
+		// Sync the read position to the reader's other devices.
+		update := map[string]any{"chat_id": chatID.String(), "thread_id": threadID.String(), "read_seq": readSeq}
+		return s.chats.updates.Publish(ctx, userID, UpdateThreadRead, update)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return readSeq, nil
+}
+
+// SetNotifications changes how the caller is notified about a thread. A
+// non-nil mutedUntil silences the thread until then regardless of level.
+func (s *ThreadsService) SetNotifications(ctx context.Context, userID, chatID, threadID ulid.ULID, level db.ThreadNotifyLevel, mutedUntil *time.Time) error {
+	if _, err := s.chats.Authorize(ctx, userID, chatID, PermNone); err != nil {
+		return err
+	}
+	if _, err := s.getThread(ctx, chatID, threadID); err != nil {
+		return err
+	}
+	switch level {
+	case db.ThreadNotifyLevelAll, db.ThreadNotifyLevelMentions, db.ThreadNotifyLevelNone:
+	default:
+		return &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("Unknown notification level %q", level)}
+	}
+
+	var until sql.NullTime
+	if mutedUntil != nil {
+		until = sql.NullTime{Time: *mutedUntil, Valid: true}
+	}
+	return s.repo.UpdateThreadNotifications(ctx, threadID, userID, level, until)
+}
+
+func (s *ThreadsService) getThread(ctx context.Context, chatID, threadID ulid.ULID) (*db.ChatThread, error) {
+	thread, err := s.repo.GetThread(ctx, chatID, threadID)
+	if errors.Is(err, repos.ErrNotFound) {
+		return nil, threadNotFound()
+	}
+	return thread, err
+}
+
+// parseTopicParams validates the provided topic fields.
+func parseTopicParams(params TopicParams) (repos.TopicUpdate, error) {
+	var update repos.TopicUpdate
+	if params.Title != nil {
+		title := strings.TrimSpace(*params.Title)
+		if title == "" || utf8.RuneCountInString(title) > maxTopicTitleLength {
+			return update, invalidTopicTitle()
+		}
+		update.Title = sql.NullString{String: title, Valid: true}
+	}
+	if params.IconColor != nil {
+		if *params.IconColor < 0 || *params.IconColor > maxTopicIconColor {
+			return update, &BusinessError{Code: string(utils.ErrValidation), Message: "Icon color must be an RGB value"}
+		}
+		update.IconColor = sql.NullInt32{Int32: *params.IconColor, Valid: true}
+	}
+	if params.Closed != nil {
+		update.Closed = sql.NullBool{Bool: *params.Closed, Valid: true}
+	}
+	return update, nil
+}
+
+func threadNotFound() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrThreadNotFound), Message: "Thread not found"}
+}
+
+func notForum() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrValidation), Message: "This chat is not a forum"}
+}
+
+func invalidTopicTitle() *BusinessError {
+	return &BusinessError{Code: string(utils.ErrValidation), Message: fmt.Sprintf("Topic title must be 1-%d characters", maxTopicTitleLength)}
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/messenger/backend/internal/storage/postgres"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupThreadsService() *ThreadsService {
+	return NewThreadsService(postgres.NewPostgresThreadRepository(testQueries), postgres.NewPostgresMessageRepository(testQueries),
+		postgres.NewPostgresScheduledMessageRepository(testQueries), postgres.NewPostgresDraftRepository(testQueries), setupChatsService())
+}
+
+func TestForumTopics_UnreadCounts_RealDB(t *testing.T) {
+	service := setupThreadsService()
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	memberID := createUser(t, ctx, "member")
+	group, err := service.chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{memberID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+
+	title := "Releases"
+	_, err = service.CreateTopic(ctx, ownerID, groupID, TopicParams{Title: &title})
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+
+	require.NoError(t, service.SetForum(ctx, ownerID, groupID, true))
+	topic, err := service.CreateTopic(ctx, memberID, groupID, TopicParams{Title: &title})
+	require.NoError(t, err)
+	topicID := ulid.MustParse(topic.ID)
+
+	messages := setupMessagesService(service.chats)
+	deviceID := createDevice(t, ctx, memberID)
+	for i := 0; i < 2; i++ {
+		_, _, err := messages.SendMessage(ctx, memberID, groupID, SendMessageParams{
+			SenderDeviceID: deviceID,
+			ThreadID:       &topicID,
+			ContentType:    "text",
+			Ciphertext:     testCiphertext(),
+		})
+		require.NoError(t, err)
+	}
+
+	seq, err := service.MarkRead(ctx, ownerID, groupID, topicID, 1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, seq)
+	// Read positions are clamped and never move backwards.
+	seq, err = service.MarkRead(ctx, memberID, groupID, topicID, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, seq)
+
+	page, err := service.ListTopics(ctx, ownerID, groupID, "", 10)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.EqualValues(t, 1, page.Items[0].UnreadCount)
+
+	chats, err := service.chats.ListChats(ctx, ownerID, ChatListOptions{}, "", 10)
+	require.NoError(t, err)
+	require.Len(t, chats.Items, 1)
+	require.Len(t, chats.Items[0].Topics, 1)
+	assert.Equal(t, topic.ID, chats.Items[0].Topics[0].ID)
+	assert.EqualValues(t, 1, chats.Items[0].Topics[0].UnreadCount)
+}
+
+func TestDeleteTopic_TombstonesAndAnnounces_RealDB(t *testing.T) {
+	service := setupThreadsService()
+	notifier := &recordingNotifier{}
+	service.chats.updates.notifier = notifier
+	messages := setupMessagesService(service.chats)
+	scheduled := NewScheduledService(postgres.NewPostgresScheduledMessageRepository(testQueries), messages, service.chats)
+	drafts := NewDraftsService(postgres.NewPostgresDraftRepository(testQueries), postgres.NewPostgresThreadRepository(testQueries), postgres.NewPostgresMessageRepository(testQueries), service.chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	memberID := createUser(t, ctx, "member")
+	memberDevice := createDevice(t, ctx, memberID)
+	group, err := service.chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{memberID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+	require.NoError(t, service.SetForum(ctx, ownerID, groupID, true))
+	title := "Releases"
+	topic, err := service.CreateTopic(ctx, memberID, groupID, TopicParams{Title: &title})
+	require.NoError(t, err)
+	topicID := ulid.MustParse(topic.ID)
+
+	msg, _, err := messages.SendMessage(ctx, memberID, groupID, SendMessageParams{
+		SenderDeviceID: memberDevice,
+		ThreadID:       &topicID,
+		ContentType:    "text",
+		Ciphertext:     testCiphertext(),
+	})
+	require.NoError(t, err)
+	sendAt := time.Now().Add(time.Hour)
+	later, err := scheduled.ScheduleMessage(ctx, memberID, groupID, ScheduleMessageParams{
+		SenderDeviceID: memberDevice,
+		ThreadID:       &topicID,
+		ContentType:    "text",
+		Ciphertext:     testCiphertext(),
+		SendAt:         &sendAt,
+	})
+	require.NoError(t, err)
+	_, _, err = drafts.SaveDraft(ctx, ownerID, groupID, DraftParams{ThreadID: &topicID, Ciphertext: []byte("hello"), ClientUpdatedAt: time.Now()})
+	require.NoError(t, err)
+
+	err = service.DeleteTopic(ctx, memberID, groupID, topicID)
+	requireBusinessCode(t, err, "FORBIDDEN_ROLE")
+	require.NoError(t, service.DeleteTopic(ctx, ownerID, groupID, topicID))
+	requireBusinessCode(t, service.DeleteTopic(ctx, ownerID, groupID, topicID), "THREAD_NOT_FOUND")
+
+	// The message is a tombstone in the chat's history, and every member
+	// was told.
+	var deletedAt pgtype.Timestamptz
+	require.NoError(t, testPool.QueryRow(ctx, `SELECT deleted_at FROM messages WHERE id = $1`, msg.ID).Scan(&deletedAt))
+	assert.True(t, deletedAt.Valid)
+	lastUpdate := func(userID ulid.ULID, updateType string) *Update {
+		updates := notifier.updates[userID]
+		for i := len(updates) - 1; i >= 0; i-- {
+			if updates[i].Type == updateType {
+				return &updates[i]
+			}
+		}
+		return nil
+	}
+	for _, userID := range []ulid.ULID{ownerID, memberID} {
+		update := lastUpdate(userID, UpdateMessagesDeleted)
+		require.NotNil(t, update)
+		assert.Contains(t, string(update.Payload), msg.ID)
+	}
+
+	// The scheduled message failed and the draft was cleared.
+	update := lastUpdate(memberID, UpdateScheduled)
+	require.NotNil(t, update)
+	assert.Contains(t, string(update.Payload), later.ID)
+	assert.Contains(t, string(update.Payload), "THREAD_NOT_FOUND")
+	require.NotNil(t, lastUpdate(ownerID, UpdateDraft))
+	page, err := drafts.ListDrafts(ctx, ownerID, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+
+	// The topic is gone and takes no new messages.
+	topics, err := service.ListTopics(ctx, ownerID, groupID, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, topics.Items)
+	_, _, err = messages.SendMessage(ctx, memberID, groupID, SendMessageParams{
+		SenderDeviceID: memberDevice,
+		ThreadID:       &topicID,
+		ContentType:    "text",
+		Ciphertext:     testCiphertext(),
+	})
+	requireBusinessCode(t, err, "THREAD_NOT_FOUND")
+}
+
+func TestGetOrCreateReplyThread_RealDB(t *testing.T) {
+	service := setupThreadsService()
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	group, err := service.chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team"})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+
+	_, _, err = service.GetOrCreateReplyThread(ctx, ownerID, groupID, ulid.Make())
+	requireBusinessCode(t, err, "MESSAGE_NOT_FOUND")
+
+	messages := setupMessagesService(service.chats)
+	deviceID := createDevice(t, ctx, ownerID)
+	root, _, err := messages.SendMessage(ctx, ownerID, groupID, SendMessageParams{
+		SenderDeviceID: deviceID,
+		ContentType:    "text",
+		Ciphertext:     testCiphertext(),
+	})
+	require.NoError(t, err)
+	rootID := ulid.MustParse(root.ID)
+	thread, created, err := service.GetOrCreateReplyThread(ctx, ownerID, groupID, rootID)
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	again, created, err := service.GetOrCreateReplyThread(ctx, ownerID, groupID, rootID)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, thread.ID, again.ID)
+
+	// A deleted message cannot start a thread.
+	deleted, _, err := messages.SendMessage(ctx, ownerID, groupID, SendMessageParams{
+		SenderDeviceID: deviceID,
+		ContentType:    "text",
+		Ciphertext:     testCiphertext(),
+	})
+	require.NoError(t, err)
+	deletedID := ulid.MustParse(deleted.ID)
+	require.NoError(t, messages.DeleteMessages(ctx, ownerID, groupID, []ulid.ULID{deletedID}, true))
+	_, _, err = service.GetOrCreateReplyThread(ctx, ownerID, groupID, deletedID)
+	requireBusinessCode(t, err, "MESSAGE_NOT_FOUND")
+}
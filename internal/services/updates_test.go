@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDifference_CatchesUpMembershipAndMessages_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	messages := setupMessagesService(chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	memberID := createUser(t, ctx, "member")
+	ownerDevice := createDevice(t, ctx, ownerID)
+
+	state, err := chats.updates.GetState(ctx, memberID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, state)
+
+	group, err := chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{memberID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+	for i := 0; i < 2; i++ {
+		_, _, err := messages.SendMessage(ctx, ownerID, groupID, SendMessageParams{SenderDeviceID: ownerDevice, ContentType: "text", Ciphertext: testCiphertext()})
+		require.NoError(t, err)
+	}
+	require.NoError(t, chats.RemoveMember(ctx, ownerID, groupID, memberID))
+
+	diff, err := chats.updates.GetDifference(ctx, memberID, 0, nil, 2)
+	require.NoError(t, err)
+	require.Len(t, diff.Updates, 2)
+	assert.True(t, diff.HasMore)
+	assert.Equal(t, UpdateNewChat, diff.Updates[0].Type)
+	assert.Equal(t, UpdateNewMessage, diff.Updates[1].Type)
+
+	diff, err = chats.updates.GetDifference(ctx, memberID, diff.Seq, nil, 10)
+	require.NoError(t, err)
+	require.Len(t, diff.Updates, 2)
+	assert.False(t, diff.HasMore)
+	assert.Equal(t, UpdateNewMessage, diff.Updates[0].Type)
+	// The removed member learns about the removal although they left the chat.
+	assert.Equal(t, UpdateChatMember, diff.Updates[1].Type)
+	var change ChatMemberUpdate
+	require.NoError(t, json.Unmarshal(diff.Updates[1].Payload, &change))
+	assert.Equal(t, MemberRemoved, change.Status)
+	assert.EqualValues(t, 4, diff.Seq)
+
+	_, err = chats.updates.GetDifference(ctx, memberID, 99, nil, 10)
+	requireBusinessCode(t, err, "VALIDATION_ERROR")
+}
+
+func TestUpdates_CommitWithTheirChange_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	notifier := &recordingNotifier{}
+	chats.updates.notifier = notifier
+	messages := setupMessagesService(chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	memberID := createUser(t, ctx, "member")
+	ownerDevice := createDevice(t, ctx, ownerID)
+	group, err := chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team", MemberIDs: []ulid.ULID{memberID}})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+	before, err := chats.updates.GetState(ctx, memberID)
+	require.NoError(t, err)
+	pushed := len(notifier.updates[memberID])
+
+	// A message whose transaction fails leaves neither the message nor its
+	// update behind, and nothing is pushed.
+	failed := errors.New("failed")
+	err = chats.updates.InTx(ctx, func(ctx context.Context) error {
+		msg, err := messages.repo.CreateMessage(ctx, repos.NewMessage{ChatID: groupID, SenderID: ownerID, SenderDeviceID: ownerDevice, ContentType: "text", Ciphertext: testCiphertext()})
+		require.NoError(t, err)
+		require.NoError(t, chats.updates.PublishToChat(ctx, groupID, UpdateNewMessage, newMessageUpdate(msg)))
+		return failed
+	})
+	require.ErrorIs(t, err, failed)
+	state, err := chats.updates.GetState(ctx, memberID)
+	require.NoError(t, err)
+	assert.Equal(t, before, state)
+	assert.Len(t, notifier.updates[memberID], pushed)
+	page, err := messages.ListHistory(ctx, memberID, groupID, HistoryQuery{Direction: HistoryBefore, Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+
+	// A retried send replays the stored message, whose update was stored
+	// with it.
+	params := SendMessageParams{SenderDeviceID: ownerDevice, ContentType: "text", Ciphertext: testCiphertext(), ClientMessageID: "retry-1"}
+	_, created, err := messages.SendMessage(ctx, ownerID, groupID, params)
+	require.NoError(t, err)
+	assert.True(t, created)
+	_, created, err = messages.SendMessage(ctx, ownerID, groupID, params)
+	require.NoError(t, err)
+	assert.False(t, created)
+	diff, err := chats.updates.GetDifference(ctx, memberID, before, nil, 10)
+	require.NoError(t, err)
+	require.Len(t, diff.Updates, 1)
+	assert.Equal(t, UpdateNewMessage, diff.Updates[0].Type)
+	assert.Len(t, notifier.updates[memberID], pushed+1)
+}
+
+func TestInTx_NestedFailureKeepsOuterTransaction_RealDB(t *testing.T) {
+	chats := setupChatsService()
+	notifier := &recordingNotifier{}
+	chats.updates.notifier = notifier
+	messages := setupMessagesService(chats)
+	ctx := context.Background()
+	require.NoError(t, truncateTables(ctx, testPool))
+
+	ownerID := createUser(t, ctx, "owner")
+	ownerDevice := createDevice(t, ctx, ownerID)
+	group, err := chats.CreateGroup(ctx, ownerID, CreateGroupParams{Title: "Team"})
+	require.NoError(t, err)
+	groupID := ulid.MustParse(group.ID)
+	newMessage := repos.NewMessage{ChatID: groupID, SenderID: ownerID, SenderDeviceID: ownerDevice, ContentType: "text", Ciphertext: testCiphertext(), ClientMessageID: "dup-1"}
+	_, err = messages.repo.CreateMessage(ctx, newMessage)
+	require.NoError(t, err)
+	pushed := len(notifier.updates[ownerID])
+
+	// The duplicate insert and the update published before it are undone,
+	// and the outer transaction goes on to commit.
+	err = chats.updates.InTx(ctx, func(ctx context.Context) error {
+		err := chats.updates.InTx(ctx, func(ctx context.Context) error {
+			require.NoError(t, chats.updates.Publish(ctx, ownerID, UpdateDraft, map[string]string{}))
+			_, err := messages.repo.CreateMessage(ctx, newMessage)
+			return err
+		})
+		require.ErrorIs(t, err, repos.ErrAlreadyExists)
+		_, err = messages.repo.GetMessageByClientID(ctx, groupID, ownerID, newMessage.ClientMessageID)
+		return err
+	})
+	require.NoError(t, err)
+	assert.Len(t, notifier.updates[ownerID], pushed)
+}
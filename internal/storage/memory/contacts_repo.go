@@ -2,10 +2,14 @@ package memory
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/messenger/backend/internal/db"
 	"github.com/messenger/backend/internal/repos"
 	"github.com/oklog/ulid/v2"
@@ -26,15 +30,12 @@ func NewInMemoryContactRepository() *InMemoryContactRepository {
 	user1 := ulid.MustParse("01H8XGJWBWBAQ1JBS9M6S3S2A1")
 	user2 := ulid.MustParse("01H8XGJWBXBAQ1JBS9M6S3S2A2")
 	user3 := ulid.MustParse("01H8XGJWBZBAQ1JBS9M6S3S2A3")
-	username1 := "user1"
-	username2 := "user2"
-	username3 := "user3"
 
 	return &InMemoryContactRepository{
 		users: map[ulid.ULID]*db.User{
-			user1: {ID: user1, Username: &username1, Status: db.UserStatusActive},
-			user2: {ID: user2, Username: &username2, Status: db.UserStatusActive},
-			user3: {ID: user3, Username: &username3, Status: db.UserStatusActive},
+			user1: {ID: user1.String(), Username: "user1"},
+			user2: {ID: user2.String(), Username: "user2"},
+			user3: {ID: user3.String(), Username: "user3"},
 		},
 		contactRequests: make(map[ulid.ULID]*db.ContactRequest),
 		contacts:        make(map[ulid.ULID]map[ulid.ULID]*db.Contact),
@@ -44,30 +45,57 @@ func NewInMemoryContactRepository() *InMemoryContactRepository {
 
 var _ repos.ContactRepository = (*InMemoryContactRepository)(nil)
 
-func (r *InMemoryContactRepository) FindUserByIdentifier(ctx context.Context, identifier string) (*db.User, error) {
+func now() pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: time.Now(), Valid: true}
+}
+
+func (r *InMemoryContactRepository) FindUserByIdentifier(ctx context.Context, username, email, phone sql.NullString) (*db.User, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	for _, user := range r.users {
-		if user.Username != nil && *user.Username == identifier {
+		switch {
+		case username.Valid && user.Username == username.String,
+			email.Valid && user.Email.Valid && user.Email.String == email.String,
+			phone.Valid && user.Phone.Valid && user.Phone.String == phone.String:
 			return user, nil
 		}
 	}
-	return nil, fmt.Errorf("user with identifier '%s' not found", identifier)
+	return nil, fmt.Errorf("user with identifier '%s' not found", username.String)
 }
 
-func (r *InMemoryContactRepository) CreateContactRequest(ctx context.Context, from, to ulid.ULID, message *string) (*db.ContactRequest, error) {
+func (r *InMemoryContactRepository) FindUsersByContactInfo(ctx context.Context, phones, emails []string) ([]db.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	wanted := make(map[string]bool, len(phones)+len(emails))
+	for _, p := range phones {
+		wanted["p:"+p] = true
+	}
+	for _, e := range emails {
+		wanted["e:"+e] = true
+	}
+	result := []db.User{}
+	for _, user := range r.users {
+		if (user.Phone.Valid && wanted["p:"+user.Phone.String]) ||
+			(user.Email.Valid && wanted["e:"+strings.ToLower(user.Email.String)]) {
+			result = append(result, *user)
+		}
+	}
+	return result, nil
+}
+
+func (r *InMemoryContactRepository) CreateContactRequest(ctx context.Context, from, to ulid.ULID, message sql.NullString) (*db.ContactRequest, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	id := ulid.Make()
 	req := &db.ContactRequest{
-		ID:        ulid.MustNew(ulid.MaxTime(), nil),
-		FromUser:  from,
-		ToUser:    to,
-		State:     db.ContactStatePending,
-		Message:   message,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-	r.contactRequests[req.ID] = req
+		ID:         id.String(),
+		FromUserID: from.String(),
+		ToUserID:   to.String(),
+		State:      db.ContactRequestStatePending,
+		Message:    pgtype.Text{String: message.String, Valid: message.Valid},
+		CreatedAt:  now(),
+	}
+	r.contactRequests[id] = req
 	return req, nil
 }
 
@@ -81,7 +109,7 @@ func (r *InMemoryContactRepository) GetContactRequest(ctx context.Context, reque
 	return req, nil
 }
 
-func (r *InMemoryContactRepository) UpdateContactRequestState(ctx context.Context, requestID ulid.ULID, state db.ContactState) error {
+func (r *InMemoryContactRepository) UpdateContactRequestState(ctx context.Context, requestID ulid.ULID, state db.ContactRequestState) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	req, ok := r.contactRequests[requestID]
@@ -89,10 +117,20 @@ func (r *InMemoryContactRepository) UpdateContactRequestState(ctx context.Contex
 		return fmt.Errorf("contact request not found")
 	}
 	req.State = state
-	req.UpdatedAt = time.Now()
 	return nil
 }
 
+func (r *InMemoryContactRepository) GetContactRequestState(ctx context.Context, from, to ulid.ULID) (db.ContactRequestState, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, req := range r.contactRequests {
+		if req.FromUserID == from.String() && req.ToUserID == to.String() {
+			return req.State, nil
+		}
+	}
+	return "", repos.ErrNotFound
+}
+
 func (r *InMemoryContactRepository) CreateContact(ctx context.Context, ownerID, peerID ulid.ULID) (*db.Contact, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -100,12 +138,12 @@ func (r *InMemoryContactRepository) CreateContact(ctx context.Context, ownerID,
 		r.contacts[ownerID] = make(map[ulid.ULID]*db.Contact)
 	}
 	contact := &db.Contact{
-		ID:        ulid.MustNew(ulid.MaxTime(), nil),
-		OwnerID:   ownerID,
-		PeerID:    peerID,
+		ID:        ulid.Make().String(),
+		OwnerID:   ownerID.String(),
+		PeerID:    peerID.String(),
 		State:     db.ContactStateAccepted,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		CreatedAt: now(),
+		UpdatedAt: now(),
 	}
 	r.contacts[ownerID][peerID] = contact
 	return contact, nil
@@ -125,6 +163,27 @@ func (r *InMemoryContactRepository) ListContacts(ctx context.Context, ownerID ul
 	return result, nil
 }
 
+func (r *InMemoryContactRepository) ListContactsWithUsers(ctx context.Context, ownerID ulid.ULID) ([]db.ListAcceptedContactsWithUsersRow, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := []db.ListAcceptedContactsWithUsersRow{}
+	for peerID, contact := range r.contacts[ownerID] {
+		user, ok := r.users[peerID]
+		if !ok || contact.State != db.ContactStateAccepted {
+			continue
+		}
+		result = append(result, db.ListAcceptedContactsWithUsersRow{
+			PeerID:   contact.PeerID,
+			Alias:    contact.Alias,
+			Username: user.Username,
+			Phone:    user.Phone,
+			Email:    user.Email,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Username < result[j].Username })
+	return result, nil
+}
+
 func (r *InMemoryContactRepository) DeleteContact(ctx context.Context, ownerID, peerID ulid.ULID) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -153,13 +212,9 @@ func (r *InMemoryContactRepository) DeleteBlock(ctx context.Context, ownerID, ta
 	return nil
 }
 
+// IsBlocked mirrors the SQL query and checks both directions.
 func (r *InMemoryContactRepository) IsBlocked(ctx context.Context, ownerID, targetID ulid.ULID) (bool, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	if userBlocks, ok := r.blocks[ownerID]; ok {
-		if blocked, ok := userBlocks[targetID]; ok && blocked {
-			return true, nil
-		}
-	}
-	return false, nil
+	return r.blocks[ownerID][targetID] || r.blocks[targetID][ownerID], nil
 }
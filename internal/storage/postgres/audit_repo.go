@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// PostgresAuditRepository is a PostgreSQL implementation of the AuditRepository.
+type PostgresAuditRepository struct {
+	q *db.Queries
+}
+
+// NewPostgresAuditRepository creates a new instance of PostgresAuditRepository.
+func NewPostgresAuditRepository(d *db.Queries) *PostgresAuditRepository {
+	return &PostgresAuditRepository{q: d}
+}
+
+// Statically check that PostgresAuditRepository implements AuditRepository.
+var _ repos.AuditRepository = (*PostgresAuditRepository)(nil)
+
+func (r *PostgresAuditRepository) CreateAuditEvent(ctx context.Context, params repos.AuditEventParams) (*db.ChatAuditEvent, error) {
+	event, err := r.q.CreateAuditEvent(ctx, db.CreateAuditEventParams{
+		ID:           ulid.Make().String(),
+		ChatID:       params.ChatID.String(),
+		ActorID:      pgtype.Text{String: params.ActorID.String(), Valid: true},
+		Action:       params.Action,
+		TargetUserID: optionalULID(params.TargetUserID),
+		TargetID:     pgtype.Text{String: params.TargetID, Valid: params.TargetID != ""},
+		Before:       params.Before,
+		After:        params.After,
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &event, nil
+}
+
+func (r *PostgresAuditRepository) ListAuditEvents(ctx context.Context, chatID ulid.ULID, filter repos.AuditFilter, cursor *repos.AuditCursor, limit int32) ([]db.ListAuditEventsRow, error) {
+	params := db.ListAuditEventsParams{
+		ChatID:        chatID.String(),
+		RetainedSince: pgtype.Timestamptz{Time: filter.RetainedSince, Valid: true},
+		Actions:       filter.Actions,
+		ActorID:       optionalULID(filter.ActorID),
+		TargetUserID:  optionalULID(filter.TargetUserID),
+		PageSize:      limit,
+	}
+	if params.Actions == nil {
+		params.Actions = []string{}
+	}
+	if cursor != nil {
+		params.CursorCreatedAt = pgtype.Timestamptz{Time: cursor.CreatedAt, Valid: true}
+		params.CursorID = pgtype.Text{String: cursor.EventID, Valid: true}
+	}
+	return r.q.ListAuditEvents(ctx, params)
+}
+
+func (r *PostgresAuditRepository) PurgeAuditEvents(ctx context.Context, olderThan time.Time) (int64, error) {
+	return r.q.PurgeAuditEvents(ctx, pgtype.Timestamptz{Time: olderThan, Valid: true})
+}
+
+func optionalULID(id *ulid.ULID) pgtype.Text {
+	if id == nil {
+		return pgtype.Text{}
+	}
+	return pgtype.Text{String: id.String(), Valid: true}
+}
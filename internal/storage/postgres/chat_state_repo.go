@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// PostgresChatStateRepository is a PostgreSQL implementation of the ChatStateRepository.
+type PostgresChatStateRepository struct {
+	q *db.Queries
+}
+
+// NewPostgresChatStateRepository creates a new instance of PostgresChatStateRepository.
+func NewPostgresChatStateRepository(d *db.Queries) *PostgresChatStateRepository {
+	return &PostgresChatStateRepository{q: d}
+}
+
+// Statically check that PostgresChatStateRepository implements ChatStateRepository.
+var _ repos.ChatStateRepository = (*PostgresChatStateRepository)(nil)
+
+func (r *PostgresChatStateRepository) GetChatState(ctx context.Context, userID, chatID ulid.ULID) (*db.ChatUserState, error) {
+	state, err := r.q.GetChatState(ctx, db.GetChatStateParams{
+		UserID: userID.String(),
+		ChatID: chatID.String(),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &state, nil
+}
+
+func (r *PostgresChatStateRepository) UpdateChatState(ctx context.Context, userID, chatID ulid.ULID, update repos.ChatStateUpdate) (*db.ChatUserState, error) {
+	params := db.UpsertChatStateParams{
+		UserID:       userID.String(),
+		ChatID:       chatID.String(),
+		Archived:     pgtype.Bool{Bool: update.Archived.Bool, Valid: update.Archived.Valid},
+		MarkedUnread: pgtype.Bool{Bool: update.MarkedUnread.Bool, Valid: update.MarkedUnread.Valid},
+		SetMute:      update.SetMute,
+		MutedUntil:   pgtype.Timestamptz{Time: update.MutedUntil.Time, Valid: update.MutedUntil.Valid},
+		MentionNotifications: pgtype.Bool{
+			Bool:  update.MentionNotifications.Bool,
+			Valid: update.MentionNotifications.Valid,
+		},
+	}
+	if update.MuteForever {
+		params.MutedUntil = pgtype.Timestamptz{InfinityModifier: pgtype.Infinity, Valid: true}
+	}
+	state, err := r.q.UpsertChatState(ctx, params)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &state, nil
+}
+
+// PinChat returns repos.ErrLimitExceeded when maxPinned chats are already
+// pinned.
+func (r *PostgresChatStateRepository) PinChat(ctx context.Context, userID, chatID ulid.ULID, maxPinned int) (*db.ChatUserState, error) {
+	state, err := r.q.PinChat(ctx, db.PinChatParams{
+		UserID:    userID.String(),
+		ChatID:    chatID.String(),
+		MaxPinned: int32(maxPinned),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repos.ErrLimitExceeded
+	}
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &state, nil
+}
+
+func (r *PostgresChatStateRepository) UnpinChat(ctx context.Context, userID, chatID ulid.ULID) (*db.ChatUserState, error) {
+	state, err := r.q.UnpinChat(ctx, db.UnpinChatParams{
+		UserID: userID.String(),
+		ChatID: chatID.String(),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &state, nil
+}
+
+func (r *PostgresChatStateRepository) ListPinnedChatIDs(ctx context.Context, userID ulid.ULID) ([]string, error) {
+	return r.q.ListPinnedChatIDs(ctx, userID.String())
+}
+
+func (r *PostgresChatStateRepository) ReorderPinnedChats(ctx context.Context, userID ulid.ULID, chatIDs []ulid.ULID) error {
+	return r.q.ReorderPinnedChats(ctx, db.ReorderPinnedChatsParams{
+		UserID:  userID.String(),
+		ChatIds: ulidStrings(chatIDs),
+	})
+}
+
+// CreateChatFolder returns repos.ErrLimitExceeded when the user already has
+// maxFolders folders.
+func (r *PostgresChatStateRepository) CreateChatFolder(ctx context.Context, userID ulid.ULID, params repos.ChatFolderParams, maxFolders int) (*db.ChatFolder, error) {
+	folder, err := r.q.CreateChatFolder(ctx, db.CreateChatFolderParams{
+		ID:              ulid.Make().String(),
+		UserID:          userID.String(),
+		Title:           params.Title,
+		IncludeRules:    params.IncludeRules,
+		ExcludeRules:    params.ExcludeRules,
+		IncludedChatIds: ulidStrings(params.IncludedChatIDs),
+		ExcludedChatIds: ulidStrings(params.ExcludedChatIDs),
+		MaxFolders:      int32(maxFolders),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repos.ErrLimitExceeded
+	}
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &folder, nil
+}
+
+func (r *PostgresChatStateRepository) UpdateChatFolder(ctx context.Context, userID, folderID ulid.ULID, params repos.ChatFolderParams) (*db.ChatFolder, error) {
+	folder, err := r.q.UpdateChatFolder(ctx, db.UpdateChatFolderParams{
+		ID:              folderID.String(),
+		UserID:          userID.String(),
+		Title:           params.Title,
+		IncludeRules:    params.IncludeRules,
+		ExcludeRules:    params.ExcludeRules,
+		IncludedChatIds: ulidStrings(params.IncludedChatIDs),
+		ExcludedChatIds: ulidStrings(params.ExcludedChatIDs),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &folder, nil
+}
+
+func (r *PostgresChatStateRepository) GetChatFolder(ctx context.Context, userID, folderID ulid.ULID) (*db.ChatFolder, error) {
+	folder, err := r.q.GetChatFolder(ctx, db.GetChatFolderParams{
+		ID:     folderID.String(),
+		UserID: userID.String(),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &folder, nil
+}
+
+func (r *PostgresChatStateRepository) ListChatFolders(ctx context.Context, userID ulid.ULID) ([]db.ChatFolder, error) {
+	return r.q.ListChatFolders(ctx, userID.String())
+}
+
+func (r *PostgresChatStateRepository) DeleteChatFolder(ctx context.Context, userID, folderID ulid.ULID) error {
+	n, err := r.q.DeleteChatFolder(ctx, db.DeleteChatFolderParams{
+		ID:     folderID.String(),
+		UserID: userID.String(),
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresChatStateRepository) ReorderChatFolders(ctx context.Context, userID ulid.ULID, folderIDs []ulid.ULID) error {
+	return r.q.ReorderChatFolders(ctx, db.ReorderChatFoldersParams{
+		UserID:    userID.String(),
+		FolderIds: ulidStrings(folderIDs),
+	})
+}
@@ -0,0 +1,417 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// PostgresChatRepository is a PostgreSQL implementation of the ChatRepository.
+type PostgresChatRepository struct {
+	q *db.Queries
+}
+
+// NewPostgresChatRepository creates a new instance of PostgresChatRepository.
+func NewPostgresChatRepository(d *db.Queries) *PostgresChatRepository {
+	return &PostgresChatRepository{q: d}
+}
+
+// Statically check that PostgresChatRepository implements ChatRepository.
+var _ repos.ChatRepository = (*PostgresChatRepository)(nil)
+
+func (r *PostgresChatRepository) GetUser(ctx context.Context, userID ulid.ULID) (*db.User, error) {
+	user, err := r.q.GetUserByID(ctx, userID.String())
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &user, nil
+}
+
+// CreateUniqueChat creates a chat keyed by directKey together with its
+// members in a single statement. It returns repos.ErrAlreadyExists when a
+// chat with the same key already exists.
+func (r *PostgresChatRepository) CreateUniqueChat(ctx context.Context, chatType db.ChatType, directKey string, createdBy ulid.ULID, memberIDs []ulid.ULID, perms repos.ChatPermissions) (*db.Chat, error) {
+	row, err := r.q.CreateChatWithMembers(ctx, db.CreateChatWithMembersParams{
+		ID:                ulid.Make().String(),
+		Type:              chatType,
+		DirectKey:         pgtype.Text{String: directKey, Valid: true},
+		CreatedBy:         pgtype.Text{String: createdBy.String(), Valid: true},
+		MemberIds:         ulidStrings(memberIDs),
+		MemberPermissions: perms.Member,
+		AdminPermissions:  perms.Admin,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// ON CONFLICT DO NOTHING returned no row.
+		return nil, repos.ErrAlreadyExists
+	}
+	if err != nil {
+		return nil, mapError(err)
+	}
+	chat := db.Chat(row)
+	return &chat, nil
+}
+
+func (r *PostgresChatRepository) CreateGroupChat(ctx context.Context, ownerID ulid.ULID, title string, photoURL sql.NullString, memberIDs []ulid.ULID, perms repos.ChatPermissions) (*db.Chat, error) {
+	row, err := r.q.CreateGroupChat(ctx, db.CreateGroupChatParams{
+		ID:                ulid.Make().String(),
+		Title:             pgtype.Text{String: title, Valid: true},
+		PhotoUrl:          pgtype.Text{String: photoURL.String, Valid: photoURL.Valid},
+		OwnerID:           ownerID.String(),
+		MemberIds:         ulidStrings(memberIDs),
+		MemberPermissions: perms.Member,
+		AdminPermissions:  perms.Admin,
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	chat := db.Chat(row)
+	return &chat, nil
+}
+
+func (r *PostgresChatRepository) CreateChannelChat(ctx context.Context, ownerID ulid.ULID, title string, photoURL, username sql.NullString, perms repos.ChatPermissions) (*db.Chat, error) {
+	row, err := r.q.CreateChannelChat(ctx, db.CreateChannelChatParams{
+		ID:                ulid.Make().String(),
+		Title:             pgtype.Text{String: title, Valid: true},
+		PhotoUrl:          pgtype.Text{String: photoURL.String, Valid: photoURL.Valid},
+		Username:          pgtype.Text{String: username.String, Valid: username.Valid},
+		OwnerID:           ownerID.String(),
+		MemberPermissions: perms.Member,
+		AdminPermissions:  perms.Admin,
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	chat := db.Chat(row)
+	return &chat, nil
+}
+
+func (r *PostgresChatRepository) GetChannelByUsername(ctx context.Context, username string) (*db.Chat, error) {
+	chat, err := r.q.GetChannelByUsername(ctx, username)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &chat, nil
+}
+
+// UpdateChatUsername returns repos.ErrAlreadyExists when another chat holds
+// the username.
+func (r *PostgresChatRepository) UpdateChatUsername(ctx context.Context, chatID ulid.ULID, username sql.NullString) error {
+	n, err := r.q.UpdateChatUsername(ctx, db.UpdateChatUsernameParams{
+		ID:       chatID.String(),
+		Username: pgtype.Text{String: username.String, Valid: username.Valid},
+	})
+	if err != nil {
+		return mapError(err)
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresChatRepository) RecordPostViews(ctx context.Context, chatID, userID ulid.ULID, messageIDs []ulid.ULID) ([]db.RecordPostViewsRow, error) {
+	return r.q.RecordPostViews(ctx, db.RecordPostViewsParams{
+		ChatID:     chatID.String(),
+		UserID:     userID.String(),
+		MessageIds: ulidStrings(messageIDs),
+	})
+}
+
+func (r *PostgresChatRepository) UpdateChatInfo(ctx context.Context, chatID ulid.ULID, title, photoURL sql.NullString) (*db.Chat, error) {
+	chat, err := r.q.UpdateChatInfo(ctx, db.UpdateChatInfoParams{
+		ID:       chatID.String(),
+		Title:    pgtype.Text{String: title.String, Valid: title.Valid},
+		PhotoUrl: pgtype.Text{String: photoURL.String, Valid: photoURL.Valid},
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &chat, nil
+}
+
+func (r *PostgresChatRepository) UpdateChatPermissions(ctx context.Context, chatID ulid.ULID, perms repos.ChatPermissions) error {
+	return r.q.UpdateChatPermissions(ctx, db.UpdateChatPermissionsParams{
+		ID:                chatID.String(),
+		MemberPermissions: perms.Member,
+		AdminPermissions:  perms.Admin,
+	})
+}
+
+func (r *PostgresChatRepository) DeleteChat(ctx context.Context, chatID ulid.ULID) error {
+	return r.q.DeleteChat(ctx, chatID.String())
+}
+
+func (r *PostgresChatRepository) GetChat(ctx context.Context, chatID ulid.ULID) (*db.Chat, error) {
+	chat, err := r.q.GetChat(ctx, chatID.String())
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &chat, nil
+}
+
+func (r *PostgresChatRepository) GetChatByDirectKey(ctx context.Context, directKey string) (*db.Chat, error) {
+	chat, err := r.q.GetChatByDirectKey(ctx, pgtype.Text{String: directKey, Valid: true})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &chat, nil
+}
+
+func (r *PostgresChatRepository) ListUserChats(ctx context.Context, userID ulid.ULID, filter repos.ChatListFilter, cursor *repos.ChatCursor, limit int32) ([]db.ListUserChatsRow, error) {
+	params := db.ListUserChatsParams{
+		UserID:          userID.String(),
+		Archived:        pgtype.Bool{Bool: filter.Archived.Bool, Valid: filter.Archived.Valid},
+		Pinned:          pgtype.Bool{Bool: filter.Pinned.Bool, Valid: filter.Pinned.Valid},
+		IncludeRules:    []string{},
+		ExcludeRules:    []string{},
+		IncludedChatIds: []string{},
+		ExcludedChatIds: []string{},
+		PageSize:        limit,
+	}
+	if f := filter.Folder; f != nil {
+		params.UseFolder = true
+		params.IncludeRules = f.IncludeRules
+		params.ExcludeRules = f.ExcludeRules
+		params.IncludedChatIds = f.IncludedChatIds
+		params.ExcludedChatIds = f.ExcludedChatIds
+	}
+	if cursor != nil {
+		params.CursorActivity = pgtype.Timestamptz{Time: cursor.LastActivityAt, Valid: true}
+		params.CursorID = pgtype.Text{String: cursor.ChatID, Valid: true}
+	}
+	return r.q.ListUserChats(ctx, params)
+}
+
+func (r *PostgresChatRepository) ListTopicSummaries(ctx context.Context, userID ulid.ULID, chatIDs []string, perChat int32) ([]db.ListTopicSummariesRow, error) {
+	return r.q.ListTopicSummaries(ctx, db.ListTopicSummariesParams{
+		UserID:  userID.String(),
+		ChatIds: chatIDs,
+		PerChat: perChat,
+	})
+}
+
+func (r *PostgresChatRepository) ListChatDrafts(ctx context.Context, userID ulid.ULID, chatIDs []string) ([]db.Draft, error) {
+	return r.q.ListChatDrafts(ctx, db.ListChatDraftsParams{
+		UserID:  userID.String(),
+		ChatIds: chatIDs,
+	})
+}
+
+func (r *PostgresChatRepository) IsChatMember(ctx context.Context, chatID, userID ulid.ULID) (bool, error) {
+	return r.q.IsChatMember(ctx, db.IsChatMemberParams{
+		ChatID: chatID.String(),
+		UserID: userID.String(),
+	})
+}
+
+func (r *PostgresChatRepository) GetChatMember(ctx context.Context, chatID, userID ulid.ULID) (*db.ChatMember, error) {
+	member, err := r.q.GetChatMember(ctx, db.GetChatMemberParams{
+		ChatID: chatID.String(),
+		UserID: userID.String(),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &member, nil
+}
+
+// AddChatMember returns repos.ErrAlreadyExists for existing members and
+// repos.ErrLimitExceeded when the chat already has maxMembers members.
+func (r *PostgresChatRepository) AddChatMember(ctx context.Context, chatID, userID ulid.ULID, role db.ChatMemberRole, invitedBy ulid.ULID, maxMembers int) error {
+	n, err := r.q.AddChatMember(ctx, db.AddChatMemberParams{
+		ChatID:     chatID.String(),
+		UserID:     userID.String(),
+		Role:       role,
+		InvitedBy:  pgtype.Text{String: invitedBy.String(), Valid: invitedBy != ulid.ULID{}},
+		MaxMembers: int32(maxMembers),
+	})
+	if err != nil {
+		return mapError(err)
+	}
+	if n == 0 {
+		return repos.ErrLimitExceeded
+	}
+	return nil
+}
+
+func (r *PostgresChatRepository) RemoveChatMember(ctx context.Context, chatID, userID ulid.ULID) error {
+	n, err := r.q.RemoveChatMember(ctx, db.RemoveChatMemberParams{
+		ChatID: chatID.String(),
+		UserID: userID.String(),
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresChatRepository) UpdateChatMemberRole(ctx context.Context, chatID, userID ulid.ULID, role db.ChatMemberRole) error {
+	n, err := r.q.UpdateChatMemberRole(ctx, db.UpdateChatMemberRoleParams{
+		ChatID: chatID.String(),
+		UserID: userID.String(),
+		Role:   role,
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresChatRepository) UpdateChatMemberPermissions(ctx context.Context, chatID, userID ulid.ULID, permissions sql.NullInt32, customTitle sql.NullString) error {
+	n, err := r.q.UpdateChatMemberPermissions(ctx, db.UpdateChatMemberPermissionsParams{
+		ChatID:      chatID.String(),
+		UserID:      userID.String(),
+		Permissions: pgtype.Int4{Int32: permissions.Int32, Valid: permissions.Valid},
+		CustomTitle: pgtype.Text{String: customTitle.String, Valid: customTitle.Valid},
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+// TransferChatOwnership returns repos.ErrNotFound unless the current owner
+// still owns the chat and the new owner is a member.
+func (r *PostgresChatRepository) TransferChatOwnership(ctx context.Context, chatID, currentOwnerID, newOwnerID ulid.ULID) error {
+	n, err := r.q.TransferChatOwnership(ctx, db.TransferChatOwnershipParams{
+		ChatID:         chatID.String(),
+		CurrentOwnerID: currentOwnerID.String(),
+		NewOwnerID:     newOwnerID.String(),
+	})
+	if err != nil {
+		return err
+	}
+	if n != 2 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresChatRepository) ListChatMembers(ctx context.Context, chatID ulid.ULID, cursor *repos.MemberCursor, limit int32) ([]db.ListChatMembersRow, error) {
+	params := db.ListChatMembersParams{
+		ChatID:   chatID.String(),
+		PageSize: limit,
+	}
+	if cursor != nil {
+		params.CursorJoinedAt = pgtype.Timestamptz{Time: cursor.JoinedAt, Valid: true}
+		params.CursorUserID = pgtype.Text{String: cursor.UserID, Valid: true}
+	}
+	return r.q.ListChatMembers(ctx, params)
+}
+
+func (r *PostgresChatRepository) ListChatMemberIDs(ctx context.Context, chatID ulid.ULID) ([]string, error) {
+	return r.q.ListChatMemberIDs(ctx, chatID.String())
+}
+
+func (r *PostgresChatRepository) IsBannedFromChat(ctx context.Context, chatID, userID ulid.ULID) (bool, error) {
+	return r.q.IsBannedFromChat(ctx, db.IsBannedFromChatParams{
+		ChatID: chatID.String(),
+		UserID: userID.String(),
+	})
+}
+
+// BanChatMember bans a user and removes their membership, if any.
+func (r *PostgresChatRepository) BanChatMember(ctx context.Context, chatID, userID, bannedBy ulid.ULID, until sql.NullTime) (*db.ChatBan, error) {
+	ban, err := r.q.BanChatMember(ctx, db.BanChatMemberParams{
+		ChatID:   chatID.String(),
+		UserID:   userID.String(),
+		BannedBy: pgtype.Text{String: bannedBy.String(), Valid: true},
+		UntilAt:  pgtype.Timestamptz{Time: until.Time, Valid: until.Valid},
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &ban, nil
+}
+
+func (r *PostgresChatRepository) UnbanChatMember(ctx context.Context, chatID, userID ulid.ULID) error {
+	n, err := r.q.UnbanChatMember(ctx, db.UnbanChatMemberParams{
+		ChatID: chatID.String(),
+		UserID: userID.String(),
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresChatRepository) ListChatBans(ctx context.Context, chatID ulid.ULID, cursor *repos.MemberCursor, limit int32) ([]db.ListChatBansRow, error) {
+	params := db.ListChatBansParams{
+		ChatID:   chatID.String(),
+		PageSize: limit,
+	}
+	if cursor != nil {
+		params.CursorCreatedAt = pgtype.Timestamptz{Time: cursor.JoinedAt, Valid: true}
+		params.CursorUserID = pgtype.Text{String: cursor.UserID, Valid: true}
+	}
+	return r.q.ListChatBans(ctx, params)
+}
+
+func (r *PostgresChatRepository) RestrictChatMember(ctx context.Context, chatID, userID ulid.ULID, until sql.NullTime) error {
+	n, err := r.q.RestrictChatMember(ctx, db.RestrictChatMemberParams{
+		ChatID:          chatID.String(),
+		UserID:          userID.String(),
+		RestrictedUntil: pgtype.Timestamptz{Time: until.Time, Valid: until.Valid},
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresChatRepository) SetChatSlowMode(ctx context.Context, chatID ulid.ULID, seconds int32) error {
+	n, err := r.q.SetChatSlowMode(ctx, db.SetChatSlowModeParams{
+		ID:              chatID.String(),
+		SlowModeSeconds: seconds,
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+// ClaimSlowModeSlot records a send unless the member's previous one is
+// younger than intervalSeconds; the result reports which happened.
+func (r *PostgresChatRepository) ClaimSlowModeSlot(ctx context.Context, chatID, userID ulid.ULID, intervalSeconds int32) (*db.ClaimSlowModeSlotRow, error) {
+	row, err := r.q.ClaimSlowModeSlot(ctx, db.ClaimSlowModeSlotParams{
+		ChatID:          chatID.String(),
+		UserID:          userID.String(),
+		IntervalSeconds: intervalSeconds,
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &row, nil
+}
+
+func ulidStrings(ids []ulid.ULID) []string {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = id.String()
+	}
+	return out
+}
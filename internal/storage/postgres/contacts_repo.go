@@ -35,6 +35,13 @@ func (r *PostgresContactRepository) FindUserByIdentifier(ctx context.Context, us
 	return &user, nil
 }
 
+func (r *PostgresContactRepository) FindUsersByContactInfo(ctx context.Context, phones, emails []string) ([]db.User, error) {
+	return r.q.FindUsersByContactInfo(ctx, db.FindUsersByContactInfoParams{
+		Phones: phones,
+		Emails: emails,
+	})
+}
+
 func (r *PostgresContactRepository) CreateContactRequest(ctx context.Context, from, to ulid.ULID, message sql.NullString) (*db.ContactRequest, error) {
 	newID, _ := ulid.New(ulid.Now(), nil)
 	req, err := r.q.CreateContactRequest(ctx, db.CreateContactRequestParams{
@@ -64,6 +71,17 @@ func (r *PostgresContactRepository) UpdateContactRequestState(ctx context.Contex
 	})
 }
 
+func (r *PostgresContactRepository) GetContactRequestState(ctx context.Context, from, to ulid.ULID) (db.ContactRequestState, error) {
+	state, err := r.q.GetContactRequestState(ctx, db.GetContactRequestStateParams{
+		FromUserID: from.String(),
+		ToUserID:   to.String(),
+	})
+	if err != nil {
+		return "", mapError(err)
+	}
+	return state, nil
+}
+
 func (r *PostgresContactRepository) CreateContact(ctx context.Context, ownerID, peerID ulid.ULID) (*db.Contact, error) {
 	newID, _ := ulid.New(ulid.Now(), nil)
 	contact, err := r.q.CreateContact(ctx, db.CreateContactParams{
@@ -84,6 +102,10 @@ func (r *PostgresContactRepository) ListContacts(ctx context.Context, ownerID ul
 	})
 }
 
+func (r *PostgresContactRepository) ListContactsWithUsers(ctx context.Context, ownerID ulid.ULID) ([]db.ListAcceptedContactsWithUsersRow, error) {
+	return r.q.ListAcceptedContactsWithUsers(ctx, ownerID.String())
+}
+
 func (r *PostgresContactRepository) DeleteContact(ctx context.Context, ownerID, peerID ulid.ULID) error {
 	return r.q.DeleteContact(ctx, db.DeleteContactParams{
 		OwnerID: ownerID.String(),
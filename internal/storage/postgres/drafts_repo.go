@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// PostgresDraftRepository is a PostgreSQL implementation of the
+// DraftRepository.
+type PostgresDraftRepository struct {
+	q *db.Queries
+}
+
+// NewPostgresDraftRepository creates a new instance of
+// PostgresDraftRepository.
+func NewPostgresDraftRepository(d *db.Queries) *PostgresDraftRepository {
+	return &PostgresDraftRepository{q: d}
+}
+
+// Statically check that PostgresDraftRepository implements DraftRepository.
+var _ repos.DraftRepository = (*PostgresDraftRepository)(nil)
+
+func (r *PostgresDraftRepository) SaveDraft(ctx context.Context, draft repos.NewDraft) (*db.Draft, error) {
+	ciphertext := draft.Ciphertext
+	if ciphertext == nil {
+		// A cleared draft is stored as an empty, not a NULL, blob.
+		ciphertext = []byte{}
+	}
+	d, err := r.q.SaveDraft(ctx, db.SaveDraftParams{
+		UserID:          draft.UserID.String(),
+		ChatID:          draft.ChatID.String(),
+		ThreadID:        optionalULID(draft.ThreadID),
+		Ciphertext:      ciphertext,
+		ReplyToID:       optionalULID(draft.ReplyToID),
+		ClientUpdatedAt: pgtype.Timestamptz{Time: draft.ClientUpdatedAt, Valid: true},
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &d, nil
+}
+
+func (r *PostgresDraftRepository) GetDraft(ctx context.Context, userID, chatID ulid.ULID, threadID *ulid.ULID) (*db.Draft, error) {
+	d, err := r.q.GetDraft(ctx, db.GetDraftParams{
+		UserID:   userID.String(),
+		ChatID:   chatID.String(),
+		ThreadID: optionalULID(threadID),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &d, nil
+}
+
+func (r *PostgresDraftRepository) ListDrafts(ctx context.Context, userID ulid.ULID, cursor *repos.DraftCursor, limit int32) ([]db.Draft, error) {
+	params := db.ListDraftsParams{
+		UserID:   userID.String(),
+		PageSize: limit,
+	}
+	if cursor != nil {
+		params.CursorUpdatedAt = pgtype.Timestamptz{Time: cursor.UpdatedAt, Valid: true}
+		params.CursorChatID = pgtype.Text{String: cursor.ChatID, Valid: true}
+		params.CursorThreadID = pgtype.Text{String: cursor.ThreadID, Valid: true}
+	}
+	return r.q.ListDrafts(ctx, params)
+}
+
+func (r *PostgresDraftRepository) ClearThreadDrafts(ctx context.Context, threadID ulid.ULID) ([]db.Draft, error) {
+	return r.q.ClearThreadDrafts(ctx, pgtype.Text{String: threadID.String(), Valid: true})
+}
@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/messenger/backend/internal/repos"
+)
+
+// uniqueViolation is the PostgreSQL SQLSTATE for unique constraint violations.
+const uniqueViolation = "23505"
+
+// PostgreSQL SQLSTATEs for transactions that lost to a concurrent one.
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// mapError translates driver errors into the repository sentinel errors.
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return repos.ErrNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return repos.ErrAlreadyExists
+	}
+	return err
+}
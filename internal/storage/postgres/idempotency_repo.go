@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// PostgresIdempotencyRepository is a PostgreSQL implementation of the IdempotencyRepository.
+type PostgresIdempotencyRepository struct {
+	q *db.Queries
+}
+
+// NewPostgresIdempotencyRepository creates a new instance of PostgresIdempotencyRepository.
+func NewPostgresIdempotencyRepository(d *db.Queries) *PostgresIdempotencyRepository {
+	return &PostgresIdempotencyRepository{q: d}
+}
+
+// Statically check that PostgresIdempotencyRepository implements IdempotencyRepository.
+var _ repos.IdempotencyRepository = (*PostgresIdempotencyRepository)(nil)
+
+func (r *PostgresIdempotencyRepository) ClaimIdempotencyKey(ctx context.Context, userID ulid.ULID, key, requestHash string, expiresAt, staleBefore time.Time) error {
+	n, err := r.q.ClaimIdempotencyKey(ctx, db.ClaimIdempotencyKeyParams{
+		UserID:      userID.String(),
+		Key:         key,
+		RequestHash: requestHash,
+		ExpiresAt:   pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		StaleBefore: pgtype.Timestamptz{Time: staleBefore, Valid: true},
+	})
+	if err != nil {
+		return mapError(err)
+	}
+	if n == 0 {
+		return repos.ErrAlreadyExists
+	}
+	return nil
+}
+
+func (r *PostgresIdempotencyRepository) GetIdempotencyKey(ctx context.Context, userID ulid.ULID, key string) (*db.IdempotencyKey, error) {
+	entry, err := r.q.GetIdempotencyKey(ctx, db.GetIdempotencyKeyParams{
+		UserID: userID.String(),
+		Key:    key,
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &entry, nil
+}
+
+func (r *PostgresIdempotencyRepository) CompleteIdempotencyKey(ctx context.Context, userID ulid.ULID, key string, statusCode int, contentType string, body []byte) error {
+	n, err := r.q.CompleteIdempotencyKey(ctx, db.CompleteIdempotencyKeyParams{
+		UserID:       userID.String(),
+		Key:          key,
+		StatusCode:   pgtype.Int4{Int32: int32(statusCode), Valid: true},
+		ContentType:  pgtype.Text{String: contentType, Valid: contentType != ""},
+		ResponseBody: body,
+	})
+	if err != nil {
+		return mapError(err)
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresIdempotencyRepository) ReleaseIdempotencyKey(ctx context.Context, userID ulid.ULID, key string) error {
+	return r.q.ReleaseIdempotencyKey(ctx, db.ReleaseIdempotencyKeyParams{
+		UserID: userID.String(),
+		Key:    key,
+	})
+}
+
+func (r *PostgresIdempotencyRepository) PurgeExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	return r.q.PurgeExpiredIdempotencyKeys(ctx)
+}
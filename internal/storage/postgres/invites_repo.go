@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// PostgresInviteRepository is a PostgreSQL implementation of the InviteRepository.
+type PostgresInviteRepository struct {
+	q *db.Queries
+}
+
+// NewPostgresInviteRepository creates a new instance of PostgresInviteRepository.
+func NewPostgresInviteRepository(d *db.Queries) *PostgresInviteRepository {
+	return &PostgresInviteRepository{q: d}
+}
+
+// Statically check that PostgresInviteRepository implements InviteRepository.
+var _ repos.InviteRepository = (*PostgresInviteRepository)(nil)
+
+func (r *PostgresInviteRepository) CreateInviteLink(ctx context.Context, chatID, createdBy ulid.ULID, token string, params repos.InviteLinkParams) (*db.ChatInviteLink, error) {
+	link, err := r.q.CreateInviteLink(ctx, db.CreateInviteLinkParams{
+		ID:               ulid.Make().String(),
+		ChatID:           chatID.String(),
+		Token:            token,
+		CreatedBy:        pgtype.Text{String: createdBy.String(), Valid: true},
+		RequiresApproval: params.RequiresApproval,
+		Name:             pgtype.Text{String: params.Name.String, Valid: params.Name.Valid},
+		ExpiresAt:        pgtype.Timestamptz{Time: params.ExpiresAt.Time, Valid: params.ExpiresAt.Valid},
+		UsageLimit:       pgtype.Int4{Int32: params.UsageLimit.Int32, Valid: params.UsageLimit.Valid},
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &link, nil
+}
+
+func (r *PostgresInviteRepository) GetInviteLinkByToken(ctx context.Context, token string) (*db.ChatInviteLink, error) {
+	link, err := r.q.GetInviteLinkByToken(ctx, token)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &link, nil
+}
+
+func (r *PostgresInviteRepository) ListInviteLinks(ctx context.Context, chatID ulid.ULID) ([]db.ChatInviteLink, error) {
+	return r.q.ListInviteLinks(ctx, chatID.String())
+}
+
+func (r *PostgresInviteRepository) RevokeInviteLink(ctx context.Context, chatID, linkID ulid.ULID) error {
+	n, err := r.q.RevokeInviteLink(ctx, db.RevokeInviteLinkParams{
+		ID:     linkID.String(),
+		ChatID: chatID.String(),
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+// ConsumeInviteLink takes one use of the link. It returns repos.ErrNotFound
+// when the link is revoked, expired or used up.
+func (r *PostgresInviteRepository) ConsumeInviteLink(ctx context.Context, linkID ulid.ULID) (*db.ChatInviteLink, error) {
+	link, err := r.q.ConsumeInviteLink(ctx, linkID.String())
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &link, nil
+}
+
+// CreateJoinRequest opens a pending join request, reopening a previously
+// decided one. It returns repos.ErrAlreadyExists if one is already pending.
+func (r *PostgresInviteRepository) CreateJoinRequest(ctx context.Context, chatID, userID, linkID ulid.ULID) (*db.ChatJoinRequest, error) {
+	req, err := r.q.UpsertJoinRequest(ctx, db.UpsertJoinRequestParams{
+		ChatID:       chatID.String(),
+		UserID:       userID.String(),
+		InviteLinkID: pgtype.Text{String: linkID.String(), Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repos.ErrAlreadyExists
+	}
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &req, nil
+}
+
+func (r *PostgresInviteRepository) GetJoinRequest(ctx context.Context, chatID, userID ulid.ULID) (*db.ChatJoinRequest, error) {
+	req, err := r.q.GetJoinRequest(ctx, db.GetJoinRequestParams{
+		ChatID: chatID.String(),
+		UserID: userID.String(),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &req, nil
+}
+
+// DecideJoinRequest returns repos.ErrNotFound unless the request is pending.
+func (r *PostgresInviteRepository) DecideJoinRequest(ctx context.Context, chatID, userID ulid.ULID, state db.JoinRequestState, decidedBy ulid.ULID) error {
+	n, err := r.q.DecideJoinRequest(ctx, db.DecideJoinRequestParams{
+		ChatID:    chatID.String(),
+		UserID:    userID.String(),
+		State:     state,
+		DecidedBy: pgtype.Text{String: decidedBy.String(), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresInviteRepository) ListPendingJoinRequests(ctx context.Context, chatID ulid.ULID, cursor *repos.MemberCursor, limit int32) ([]db.ListPendingJoinRequestsRow, error) {
+	params := db.ListPendingJoinRequestsParams{
+		ChatID:   chatID.String(),
+		PageSize: limit,
+	}
+	if cursor != nil {
+		params.CursorCreatedAt = pgtype.Timestamptz{Time: cursor.JoinedAt, Valid: true}
+		params.CursorUserID = pgtype.Text{String: cursor.UserID, Valid: true}
+	}
+	return r.q.ListPendingJoinRequests(ctx, params)
+}
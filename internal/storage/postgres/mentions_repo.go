@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// PostgresMentionRepository is a PostgreSQL implementation of the
+// MentionRepository.
+type PostgresMentionRepository struct {
+	q *db.Queries
+}
+
+// NewPostgresMentionRepository creates a new instance of
+// PostgresMentionRepository.
+func NewPostgresMentionRepository(d *db.Queries) *PostgresMentionRepository {
+	return &PostgresMentionRepository{q: d}
+}
+
+// Statically check that PostgresMentionRepository implements
+// MentionRepository.
+var _ repos.MentionRepository = (*PostgresMentionRepository)(nil)
+
+func (r *PostgresMentionRepository) ListMentions(ctx context.Context, userID ulid.ULID, filter repos.MentionFilter, deviceID *ulid.ULID, cursor *repos.MentionCursor, limit int32) ([]db.ListMentionsRow, error) {
+	params := db.ListMentionsParams{
+		UserID:     userID.String(),
+		ChatID:     optionalULID(filter.ChatID),
+		UnreadOnly: filter.UnreadOnly,
+		DeviceID:   optionalULID(deviceID),
+		PageSize:   limit,
+	}
+	if cursor != nil {
+		params.CursorMentionedAt = pgtype.Timestamptz{Time: cursor.MentionedAt, Valid: true}
+		params.CursorMessageID = pgtype.Text{String: cursor.MessageID, Valid: true}
+	}
+	return r.q.ListMentions(ctx, params)
+}
+
+func (r *PostgresMentionRepository) ReadChatMentions(ctx context.Context, userID, chatID ulid.ULID) (bool, error) {
+	n, err := r.q.ReadChatMentions(ctx, db.ReadChatMentionsParams{
+		UserID: userID.String(),
+		ChatID: chatID.String(),
+	})
+	if err != nil {
+		return false, mapError(err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,295 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// PostgresMessageRepository is a PostgreSQL implementation of the MessageRepository.
+type PostgresMessageRepository struct {
+	q *db.Queries
+}
+
+// NewPostgresMessageRepository creates a new instance of PostgresMessageRepository.
+func NewPostgresMessageRepository(d *db.Queries) *PostgresMessageRepository {
+	return &PostgresMessageRepository{q: d}
+}
+
+// Statically check that PostgresMessageRepository implements MessageRepository.
+var _ repos.MessageRepository = (*PostgresMessageRepository)(nil)
+
+func (r *PostgresMessageRepository) CreateMessage(ctx context.Context, msg repos.NewMessage) (*db.Message, error) {
+	params := db.CreateMessageParams{
+		ID:                 ulid.Make().String(),
+		ChatID:             msg.ChatID.String(),
+		SenderID:           msg.SenderID.String(),
+		ThreadID:           optionalULID(msg.ThreadID),
+		ContentType:        msg.ContentType,
+		Ciphertext:         msg.Ciphertext,
+		ClientMessageID:    pgtype.Text{String: msg.ClientMessageID, Valid: msg.ClientMessageID != ""},
+		ReplyToID:          optionalULID(msg.ReplyToID),
+		ViewOnce:           msg.ViewOnce,
+		Service:            msg.Service,
+		MentionedUserIds:   make([]string, len(msg.MentionedUserIDs)),
+		MentionedUsernames: make([]string, len(msg.MentionedUsernames)),
+		DeviceIds:          make([]string, len(msg.DevicePayloads)),
+		DeviceCiphertexts:  make([][]byte, len(msg.DevicePayloads)),
+	}
+	if msg.SenderDeviceID != (ulid.ULID{}) {
+		params.SenderDeviceID = pgtype.Text{String: msg.SenderDeviceID.String(), Valid: true}
+	}
+	if msg.TTL > 0 {
+		params.TtlSeconds = pgtype.Int4{Int32: int32(msg.TTL / time.Second), Valid: true}
+	}
+	if f := msg.Forward; f != nil {
+		params.ForwardFromUserID = optionalULID(f.UserID)
+		params.ForwardFromChatID = optionalULID(f.ChatID)
+		params.ForwardFromMessageID = optionalULID(f.MessageID)
+		params.ForwardDate = pgtype.Timestamptz{Time: f.Date, Valid: true}
+	}
+	if p := msg.Poll; p != nil {
+		params.PollOptionCount = pgtype.Int2{Int16: int16(p.Options), Valid: true}
+		params.PollMultipleChoice = p.MultipleChoice
+		params.PollAnonymous = p.Anonymous
+		if p.CorrectOption != nil {
+			params.PollCorrectOption = pgtype.Int2{Int16: int16(*p.CorrectOption), Valid: true}
+		}
+		params.PollClosesAt = optionalTime(p.ClosesAt)
+	}
+	if params.Ciphertext == nil {
+		// Messages carried only by per-device payloads have no shared body.
+		params.Ciphertext = []byte{}
+	}
+	for i, id := range msg.MentionedUserIDs {
+		params.MentionedUserIds[i] = id.String()
+	}
+	for i, name := range msg.MentionedUsernames {
+		params.MentionedUsernames[i] = strings.ToLower(name)
+	}
+	for i, p := range msg.DevicePayloads {
+		params.DeviceIds[i] = p.DeviceID.String()
+		params.DeviceCiphertexts[i] = p.Ciphertext
+	}
+	row, err := r.q.CreateMessage(ctx, params)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	message := db.Message(row)
+	return &message, nil
+}
+
+func (r *PostgresMessageRepository) GetMessage(ctx context.Context, chatID, messageID ulid.ULID) (*db.Message, error) {
+	message, err := r.q.GetMessage(ctx, db.GetMessageParams{
+		ChatID: chatID.String(),
+		ID:     messageID.String(),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &message, nil
+}
+
+func (r *PostgresMessageRepository) GetMessageByClientID(ctx context.Context, chatID, senderID ulid.ULID, clientMessageID string) (*db.Message, error) {
+	message, err := r.q.GetMessageByClientID(ctx, db.GetMessageByClientIDParams{
+		ChatID:          chatID.String(),
+		SenderID:        pgtype.Text{String: senderID.String(), Valid: true},
+		ClientMessageID: pgtype.Text{String: clientMessageID, Valid: true},
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &message, nil
+}
+
+func (r *PostgresMessageRepository) EditMessage(ctx context.Context, edit repos.MessageEdit) (*db.Message, error) {
+	params := db.EditMessageParams{
+		ChatID:            edit.ChatID.String(),
+		ID:                edit.MessageID.String(),
+		SenderID:          edit.SenderID.String(),
+		SenderDeviceID:    edit.SenderDeviceID.String(),
+		EditableAfter:     pgtype.Timestamptz{Time: edit.EditableAfter, Valid: true},
+		Ciphertext:        edit.Ciphertext,
+		DeviceIds:         make([]string, len(edit.DevicePayloads)),
+		DeviceCiphertexts: make([][]byte, len(edit.DevicePayloads)),
+	}
+	if params.Ciphertext == nil {
+		params.Ciphertext = []byte{}
+	}
+	for i, p := range edit.DevicePayloads {
+		params.DeviceIds[i] = p.DeviceID.String()
+		params.DeviceCiphertexts[i] = p.Ciphertext
+	}
+	row, err := r.q.EditMessage(ctx, params)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	message := db.Message(row)
+	return &message, nil
+}
+
+func (r *PostgresMessageRepository) ListMessageEdits(ctx context.Context, messageID, userID ulid.ULID, deviceID *ulid.ULID) ([]db.ListMessageEditsRow, error) {
+	return r.q.ListMessageEdits(ctx, db.ListMessageEditsParams{
+		MessageID: messageID.String(),
+		UserID:    userID.String(),
+		DeviceID:  optionalULID(deviceID),
+	})
+}
+
+func (r *PostgresMessageRepository) GetMessageForDevice(ctx context.Context, chatID, messageID, userID ulid.ULID, deviceID *ulid.ULID) (*db.GetMessageForDeviceRow, error) {
+	row, err := r.q.GetMessageForDevice(ctx, db.GetMessageForDeviceParams{
+		ChatID:   chatID.String(),
+		ID:       messageID.String(),
+		UserID:   userID.String(),
+		DeviceID: optionalULID(deviceID),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &row, nil
+}
+
+func (r *PostgresMessageRepository) ListMessagesBefore(ctx context.Context, query repos.MessageListQuery, beforeSeq int64, limit int) ([]db.GetMessageForDeviceRow, error) {
+	rows, err := r.q.ListMessagesBefore(ctx, db.ListMessagesBeforeParams{
+		ChatID:    query.ChatID.String(),
+		UserID:    query.UserID.String(),
+		DeviceID:  optionalULID(query.DeviceID),
+		ThreadID:  optionalULID(query.ThreadID),
+		BeforeSeq: beforeSeq,
+		Lim:       int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]db.GetMessageForDeviceRow, len(rows))
+	for i, row := range rows {
+		messages[i] = db.GetMessageForDeviceRow(row)
+	}
+	return messages, nil
+}
+
+func (r *PostgresMessageRepository) ListMessagesAfter(ctx context.Context, query repos.MessageListQuery, afterSeq int64, limit int) ([]db.GetMessageForDeviceRow, error) {
+	rows, err := r.q.ListMessagesAfter(ctx, db.ListMessagesAfterParams{
+		ChatID:   query.ChatID.String(),
+		UserID:   query.UserID.String(),
+		DeviceID: optionalULID(query.DeviceID),
+		ThreadID: optionalULID(query.ThreadID),
+		AfterSeq: afterSeq,
+		Lim:      int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]db.GetMessageForDeviceRow, len(rows))
+	for i, row := range rows {
+		messages[i] = db.GetMessageForDeviceRow(row)
+	}
+	return messages, nil
+}
+
+func (r *PostgresMessageRepository) ListVisibleMessages(ctx context.Context, chatID, userID ulid.ULID, messageIDs []ulid.ULID) ([]db.Message, error) {
+	return r.q.ListVisibleMessages(ctx, db.ListVisibleMessagesParams{
+		ChatID: chatID.String(),
+		UserID: userID.String(),
+		Ids:    ulidStrings(messageIDs),
+	})
+}
+
+func (r *PostgresMessageRepository) DeleteMessagesForEveryone(ctx context.Context, chatID ulid.ULID, messageIDs []ulid.ULID) ([]db.Message, error) {
+	return r.q.DeleteMessagesForEveryone(ctx, db.DeleteMessagesForEveryoneParams{
+		ChatID: chatID.String(),
+		Ids:    ulidStrings(messageIDs),
+	})
+}
+
+func (r *PostgresMessageRepository) DeleteThreadMessages(ctx context.Context, chatID, threadID ulid.ULID) ([]db.Message, error) {
+	ids, err := r.q.ListThreadMessageIDs(ctx, pgtype.Text{String: threadID.String(), Valid: true})
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	return r.q.DeleteMessagesForEveryone(ctx, db.DeleteMessagesForEveryoneParams{
+		ChatID: chatID.String(),
+		Ids:    ids,
+	})
+}
+
+func (r *PostgresMessageRepository) HideMessages(ctx context.Context, chatID, userID ulid.ULID, messageIDs []ulid.ULID) error {
+	return r.q.HideMessages(ctx, db.HideMessagesParams{
+		UserID: userID.String(),
+		ChatID: chatID.String(),
+		Ids:    ulidStrings(messageIDs),
+	})
+}
+
+func (r *PostgresMessageRepository) ClearChatHistory(ctx context.Context, chatID, userID ulid.ULID) (int64, error) {
+	seq, err := r.q.ClearChatHistory(ctx, db.ClearChatHistoryParams{
+		UserID: userID.String(),
+		ChatID: chatID.String(),
+	})
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return seq, nil
+}
+
+func (r *PostgresMessageRepository) PurgeUnreferencedMessages(ctx context.Context, chatID ulid.ULID, maxSeq int64, messageIDs []ulid.ULID) ([]string, error) {
+	var ids []string
+	if messageIDs != nil {
+		ids = ulidStrings(messageIDs)
+	}
+	return r.q.PurgeUnreferencedMessages(ctx, db.PurgeUnreferencedMessagesParams{
+		ChatID: chatID.String(),
+		MaxSeq: maxSeq,
+		Ids:    ids,
+	})
+}
+
+func (r *PostgresMessageRepository) GetActiveUserDevice(ctx context.Context, userID, deviceID ulid.ULID) (*db.Device, error) {
+	device, err := r.q.GetActiveUserDevice(ctx, db.GetActiveUserDeviceParams{
+		ID:     deviceID.String(),
+		UserID: userID.String(),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &device, nil
+}
+
+func (r *PostgresMessageRepository) FilterMemberDevices(ctx context.Context, chatID ulid.ULID, deviceIDs []ulid.ULID) ([]string, error) {
+	return r.q.FilterMemberDevices(ctx, db.FilterMemberDevicesParams{
+		ChatID:    chatID.String(),
+		DeviceIds: ulidStrings(deviceIDs),
+	})
+}
+
+func (r *PostgresMessageRepository) ExpireMessages(ctx context.Context, limit int32) ([]db.ExpireMessagesRow, error) {
+	rows, err := r.q.ExpireMessages(ctx, limit)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return rows, nil
+}
+
+func (r *PostgresMessageRepository) SetChatMessageTTL(ctx context.Context, chatID ulid.ULID, ttl time.Duration) error {
+	params := db.SetChatMessageTTLParams{ID: chatID.String()}
+	if ttl > 0 {
+		params.MessageTtlSeconds = pgtype.Int4{Int32: int32(ttl / time.Second), Valid: true}
+	}
+	n, err := r.q.SetChatMessageTTL(ctx, params)
+	if err != nil {
+		return mapError(err)
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresMessageRepository) ListMessageMentions(ctx context.Context, messageID ulid.ULID) ([]db.ListMessageMentionsRow, error) {
+	return r.q.ListMessageMentions(ctx, messageID.String())
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// PostgresPinRepository is a PostgreSQL implementation of the PinRepository.
+type PostgresPinRepository struct {
+	q *db.Queries
+}
+
+// NewPostgresPinRepository creates a new instance of PostgresPinRepository.
+func NewPostgresPinRepository(d *db.Queries) *PostgresPinRepository {
+	return &PostgresPinRepository{q: d}
+}
+
+// Statically check that PostgresPinRepository implements PinRepository.
+var _ repos.PinRepository = (*PostgresPinRepository)(nil)
+
+func (r *PostgresPinRepository) PinMessage(ctx context.Context, chatID, messageID, userID ulid.ULID) (bool, error) {
+	n, err := r.q.PinMessage(ctx, db.PinMessageParams{
+		ChatID:    chatID.String(),
+		MessageID: messageID.String(),
+		PinnedBy:  userID.String(),
+	})
+	if err != nil {
+		return false, mapError(err)
+	}
+	return n > 0, nil
+}
+
+func (r *PostgresPinRepository) UnpinMessage(ctx context.Context, chatID, messageID ulid.ULID) error {
+	n, err := r.q.UnpinMessage(ctx, db.UnpinMessageParams{
+		ChatID:    chatID.String(),
+		MessageID: messageID.String(),
+	})
+	if err != nil {
+		return mapError(err)
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresPinRepository) UnpinAllMessages(ctx context.Context, chatID ulid.ULID) ([]string, error) {
+	ids, err := r.q.UnpinAllMessages(ctx, chatID.String())
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return ids, nil
+}
+
+func (r *PostgresPinRepository) ListPinnedMessages(ctx context.Context, chatID, userID ulid.ULID, deviceID *ulid.ULID, cursor *repos.PinCursor, limit int32) ([]db.ListPinnedMessagesRow, error) {
+	params := db.ListPinnedMessagesParams{
+		ChatID:   chatID.String(),
+		UserID:   userID.String(),
+		DeviceID: optionalULID(deviceID),
+		PageSize: limit,
+	}
+	if cursor != nil {
+		params.CursorPinnedAt = pgtype.Timestamptz{Time: cursor.PinnedAt, Valid: true}
+		params.CursorMessageID = pgtype.Text{String: cursor.MessageID, Valid: true}
+	}
+	return r.q.ListPinnedMessages(ctx, params)
+}
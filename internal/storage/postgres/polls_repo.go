@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// PostgresPollRepository is a PostgreSQL implementation of the
+// PollRepository.
+type PostgresPollRepository struct {
+	q *db.Queries
+}
+
+// NewPostgresPollRepository creates a new instance of PostgresPollRepository.
+func NewPostgresPollRepository(d *db.Queries) *PostgresPollRepository {
+	return &PostgresPollRepository{q: d}
+}
+
+// Statically check that PostgresPollRepository implements PollRepository.
+var _ repos.PollRepository = (*PostgresPollRepository)(nil)
+
+func (r *PostgresPollRepository) GetPoll(ctx context.Context, chatID, messageID, userID ulid.ULID) (*db.GetPollRow, error) {
+	poll, err := r.q.GetPoll(ctx, db.GetPollParams{
+		ChatID:    chatID.String(),
+		MessageID: messageID.String(),
+		UserID:    userID.String(),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &poll, nil
+}
+
+func (r *PostgresPollRepository) Vote(ctx context.Context, chatID, messageID, userID ulid.ULID, options []int16) (bool, error) {
+	n, err := r.q.VotePoll(ctx, db.VotePollParams{
+		ChatID:    chatID.String(),
+		MessageID: messageID.String(),
+		UserID:    userID.String(),
+		Options:   options,
+	})
+	if err != nil {
+		return false, mapError(err)
+	}
+	return n > 0, nil
+}
+
+func (r *PostgresPollRepository) RetractVote(ctx context.Context, chatID, messageID, userID ulid.ULID) (bool, error) {
+	n, err := r.q.RetractPollVote(ctx, db.RetractPollVoteParams{
+		ChatID:    chatID.String(),
+		MessageID: messageID.String(),
+		UserID:    userID.String(),
+	})
+	if err != nil {
+		return false, mapError(err)
+	}
+	return n > 0, nil
+}
+
+func (r *PostgresPollRepository) ClosePoll(ctx context.Context, chatID, messageID ulid.ULID) error {
+	n, err := r.q.ClosePoll(ctx, db.ClosePollParams{
+		ChatID:    chatID.String(),
+		MessageID: messageID.String(),
+	})
+	if err != nil {
+		return mapError(err)
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresPollRepository) ListVoters(ctx context.Context, messageID ulid.ULID, option *int16, cursor *repos.PollVoterCursor, limit int32) ([]db.ListPollVotersRow, error) {
+	params := db.ListPollVotersParams{
+		MessageID: messageID.String(),
+		PageSize:  limit,
+	}
+	if option != nil {
+		params.Option = pgtype.Int2{Int16: *option, Valid: true}
+	}
+	if cursor != nil {
+		params.CursorVotedAt = pgtype.Timestamptz{Time: cursor.VotedAt, Valid: true}
+		params.CursorUserID = pgtype.Text{String: cursor.UserID, Valid: true}
+	}
+	return r.q.ListPollVoters(ctx, params)
+}
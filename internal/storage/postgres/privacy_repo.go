@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// PostgresPrivacyRepository is a PostgreSQL implementation of the PrivacyRepository.
+type PostgresPrivacyRepository struct {
+	q *db.Queries
+}
+
+// NewPostgresPrivacyRepository creates a new instance of PostgresPrivacyRepository.
+func NewPostgresPrivacyRepository(d *db.Queries) *PostgresPrivacyRepository {
+	return &PostgresPrivacyRepository{q: d}
+}
+
+// Statically check that PostgresPrivacyRepository implements PrivacyRepository.
+var _ repos.PrivacyRepository = (*PostgresPrivacyRepository)(nil)
+
+func (r *PostgresPrivacyRepository) GetPrivacySettings(ctx context.Context, userID ulid.ULID) (*db.UserPrivacySetting, error) {
+	settings, err := r.q.GetPrivacySettings(ctx, userID.String())
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &settings, nil
+}
+
+func (r *PostgresPrivacyRepository) UpdatePrivacySettings(ctx context.Context, userID ulid.ULID, update repos.PrivacySettingsUpdate) (*db.UserPrivacySetting, error) {
+	settings, err := r.q.UpsertPrivacySettings(ctx, db.UpsertPrivacySettingsParams{
+		UserID:             userID.String(),
+		ReadReceipts:       pgtype.Bool{Bool: update.ReadReceipts.Bool, Valid: update.ReadReceipts.Valid},
+		ForwardAttribution: pgtype.Bool{Bool: update.ForwardAttribution.Bool, Valid: update.ForwardAttribution.Valid},
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &settings, nil
+}
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// PostgresReactionRepository is a PostgreSQL implementation of the ReactionRepository.
+type PostgresReactionRepository struct {
+	q *db.Queries
+}
+
+// NewPostgresReactionRepository creates a new instance of PostgresReactionRepository.
+func NewPostgresReactionRepository(d *db.Queries) *PostgresReactionRepository {
+	return &PostgresReactionRepository{q: d}
+}
+
+// Statically check that PostgresReactionRepository implements ReactionRepository.
+var _ repos.ReactionRepository = (*PostgresReactionRepository)(nil)
+
+func (r *PostgresReactionRepository) LockMessageReactions(ctx context.Context, chatID, messageID ulid.ULID) (json.RawMessage, error) {
+	counts, err := r.q.LockMessageReactions(ctx, db.LockMessageReactionsParams{
+		ChatID:    chatID.String(),
+		MessageID: messageID.String(),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return counts, nil
+}
+
+func (r *PostgresReactionRepository) AddReaction(ctx context.Context, chatID, messageID, userID ulid.ULID, emoji string, maxPerUser, maxPerMessage int) (json.RawMessage, error) {
+	counts, err := r.q.AddReaction(ctx, db.AddReactionParams{
+		ChatID:        chatID.String(),
+		MessageID:     messageID.String(),
+		UserID:        userID.String(),
+		Emoji:         emoji,
+		MaxPerUser:    int32(maxPerUser),
+		MaxPerMessage: int32(maxPerMessage),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return counts, nil
+}
+
+func (r *PostgresReactionRepository) RemoveReaction(ctx context.Context, chatID, messageID, userID ulid.ULID, emoji string) (json.RawMessage, error) {
+	counts, err := r.q.RemoveReaction(ctx, db.RemoveReactionParams{
+		ChatID:    chatID.String(),
+		MessageID: messageID.String(),
+		UserID:    userID.String(),
+		Emoji:     emoji,
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return counts, nil
+}
+
+func (r *PostgresReactionRepository) ListUserReactions(ctx context.Context, messageID, userID ulid.ULID) ([]string, error) {
+	return r.q.ListUserReactions(ctx, db.ListUserReactionsParams{
+		MessageID: messageID.String(),
+		UserID:    userID.String(),
+	})
+}
+
+func (r *PostgresReactionRepository) ListReactions(ctx context.Context, messageID ulid.ULID, emoji string, cursor *repos.ReactionCursor, limit int32) ([]db.ListReactionsRow, error) {
+	params := db.ListReactionsParams{
+		MessageID: messageID.String(),
+		Emoji:     pgtype.Text{String: emoji, Valid: emoji != ""},
+		PageSize:  limit,
+	}
+	if cursor != nil {
+		params.CursorCreatedAt = pgtype.Timestamptz{Time: cursor.CreatedAt, Valid: true}
+		params.CursorUserID = pgtype.Text{String: cursor.UserID, Valid: true}
+		params.CursorEmoji = pgtype.Text{String: cursor.Emoji, Valid: true}
+	}
+	return r.q.ListReactions(ctx, params)
+}
+
+func (r *PostgresReactionRepository) SetChatAllowedReactions(ctx context.Context, chatID ulid.ULID, emoji []string) error {
+	n, err := r.q.SetChatAllowedReactions(ctx, db.SetChatAllowedReactionsParams{
+		ID:               chatID.String(),
+		AllowedReactions: emoji,
+	})
+	if err != nil {
+		return mapError(err)
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// PostgresReceiptRepository is a PostgreSQL implementation of the ReceiptRepository.
+type PostgresReceiptRepository struct {
+	q *db.Queries
+}
+
+// NewPostgresReceiptRepository creates a new instance of PostgresReceiptRepository.
+func NewPostgresReceiptRepository(d *db.Queries) *PostgresReceiptRepository {
+	return &PostgresReceiptRepository{q: d}
+}
+
+// Statically check that PostgresReceiptRepository implements ReceiptRepository.
+var _ repos.ReceiptRepository = (*PostgresReceiptRepository)(nil)
+
+func (r *PostgresReceiptRepository) MarkChatRead(ctx context.Context, userID, chatID ulid.ULID, seq int64) (*db.MarkChatReadRow, error) {
+	row, err := r.q.MarkChatRead(ctx, db.MarkChatReadParams{
+		UserID:  userID.String(),
+		ChatID:  chatID.String(),
+		ReadSeq: seq,
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &row, nil
+}
+
+func (r *PostgresReceiptRepository) MarkChatDelivered(ctx context.Context, userID, chatID, deviceID ulid.ULID, seq int64) (*db.MarkChatDeliveredRow, error) {
+	row, err := r.q.MarkChatDelivered(ctx, db.MarkChatDeliveredParams{
+		UserID:       userID.String(),
+		ChatID:       chatID.String(),
+		DeviceID:     deviceID.String(),
+		DeliveredSeq: seq,
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &row, nil
+}
+
+func (r *PostgresReceiptRepository) ListMessageSenders(ctx context.Context, chatID, userID ulid.ULID, afterSeq, upToSeq int64) ([]db.ListMessageSendersRow, error) {
+	return r.q.ListMessageSenders(ctx, db.ListMessageSendersParams{
+		ChatID:   chatID.String(),
+		UserID:   userID.String(),
+		AfterSeq: afterSeq,
+		UpToSeq:  upToSeq,
+	})
+}
+
+func (r *PostgresReceiptRepository) GetChatReceipts(ctx context.Context, chatID, userID ulid.ULID) (*db.GetChatReceiptsRow, error) {
+	row, err := r.q.GetChatReceipts(ctx, db.GetChatReceiptsParams{
+		ChatID: chatID.String(),
+		UserID: userID.String(),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &row, nil
+}
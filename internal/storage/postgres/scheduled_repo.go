@@ -0,0 +1,157 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// PostgresScheduledMessageRepository is a PostgreSQL implementation of the ScheduledMessageRepository.
+type PostgresScheduledMessageRepository struct {
+	q *db.Queries
+}
+
+// NewPostgresScheduledMessageRepository creates a new instance of PostgresScheduledMessageRepository.
+func NewPostgresScheduledMessageRepository(d *db.Queries) *PostgresScheduledMessageRepository {
+	return &PostgresScheduledMessageRepository{q: d}
+}
+
+// Statically check that PostgresScheduledMessageRepository implements ScheduledMessageRepository.
+var _ repos.ScheduledMessageRepository = (*PostgresScheduledMessageRepository)(nil)
+
+func (r *PostgresScheduledMessageRepository) CreateScheduledMessage(ctx context.Context, msg repos.NewScheduledMessage) (*db.ScheduledMessage, error) {
+	ciphertext, deviceIDs, deviceCiphertexts := splitPayloads(msg.Ciphertext, msg.DevicePayloads)
+	row, err := r.q.CreateScheduledMessage(ctx, db.CreateScheduledMessageParams{
+		ID:                ulid.Make().String(),
+		ChatID:            msg.ChatID.String(),
+		SenderID:          msg.SenderID.String(),
+		SenderDeviceID:    msg.SenderDeviceID.String(),
+		ThreadID:          optionalULID(msg.ThreadID),
+		ReplyToID:         optionalULID(msg.ReplyToID),
+		ContentType:       msg.ContentType,
+		Ciphertext:        ciphertext,
+		DeviceIds:         deviceIDs,
+		DeviceCiphertexts: deviceCiphertexts,
+		SendAt:            optionalTime(msg.SendAt),
+		OnlineUserID:      optionalULID(msg.OnlineUserID),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &row, nil
+}
+
+func (r *PostgresScheduledMessageRepository) CountScheduledMessages(ctx context.Context, chatID, senderID ulid.ULID) (int64, error) {
+	n, err := r.q.CountScheduledMessages(ctx, db.CountScheduledMessagesParams{
+		ChatID:   chatID.String(),
+		SenderID: senderID.String(),
+	})
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return n, nil
+}
+
+func (r *PostgresScheduledMessageRepository) ListScheduledMessages(ctx context.Context, chatID, senderID ulid.ULID) ([]db.ScheduledMessage, error) {
+	rows, err := r.q.ListScheduledMessages(ctx, db.ListScheduledMessagesParams{
+		ChatID:   chatID.String(),
+		SenderID: senderID.String(),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return rows, nil
+}
+
+func (r *PostgresScheduledMessageRepository) UpdateScheduledMessage(ctx context.Context, chatID, senderID, id ulid.ULID, update repos.ScheduledMessageUpdate) (*db.ScheduledMessage, error) {
+	params := db.UpdateScheduledMessageParams{
+		ID:       id.String(),
+		ChatID:   chatID.String(),
+		SenderID: senderID.String(),
+		SendAt:   optionalTime(update.SendAt),
+	}
+	if c := update.Content; c != nil {
+		params.SenderDeviceID = pgtype.Text{String: c.SenderDeviceID.String(), Valid: true}
+		params.Ciphertext, params.DeviceIds, params.DeviceCiphertexts = splitPayloads(c.Ciphertext, c.DevicePayloads)
+	}
+	row, err := r.q.UpdateScheduledMessage(ctx, params)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &row, nil
+}
+
+func (r *PostgresScheduledMessageRepository) DeleteScheduledMessage(ctx context.Context, chatID, senderID, id ulid.ULID) error {
+	n, err := r.q.DeleteScheduledMessage(ctx, db.DeleteScheduledMessageParams{
+		ID:       id.String(),
+		ChatID:   chatID.String(),
+		SenderID: senderID.String(),
+	})
+	if err != nil {
+		return mapError(err)
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresScheduledMessageRepository) ClaimDueScheduledMessages(ctx context.Context, lease time.Duration, limit int32) ([]db.ScheduledMessage, error) {
+	rows, err := r.q.ClaimDueScheduledMessages(ctx, db.ClaimDueScheduledMessagesParams{
+		LeaseSeconds: lease.Seconds(),
+		BatchSize:    limit,
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return rows, nil
+}
+
+func (r *PostgresScheduledMessageRepository) FinishScheduledMessage(ctx context.Context, id string) error {
+	return mapError(r.q.FinishScheduledMessage(ctx, id))
+}
+
+func (r *PostgresScheduledMessageRepository) FailScheduledMessage(ctx context.Context, id, errorCode string) error {
+	return mapError(r.q.FailScheduledMessage(ctx, db.FailScheduledMessageParams{
+		ID:        id,
+		ErrorCode: pgtype.Text{String: errorCode, Valid: true},
+	}))
+}
+
+func (r *PostgresScheduledMessageRepository) FailThreadScheduledMessages(ctx context.Context, threadID ulid.ULID, errorCode string) ([]db.ScheduledMessage, error) {
+	return r.q.FailThreadScheduledMessages(ctx, db.FailThreadScheduledMessagesParams{
+		ThreadID:  pgtype.Text{String: threadID.String(), Valid: true},
+		ErrorCode: pgtype.Text{String: errorCode, Valid: true},
+	})
+}
+
+func (r *PostgresScheduledMessageRepository) TouchUserPresence(ctx context.Context, userID ulid.ULID) error {
+	return mapError(r.q.TouchUserPresence(ctx, userID.String()))
+}
+
+// splitPayloads turns a message body and its per-device payloads into the
+// column values; messages carried only by per-device payloads have an
+// empty shared body.
+func splitPayloads(ciphertext []byte, payloads []repos.DevicePayload) ([]byte, []string, [][]byte) {
+	if ciphertext == nil {
+		ciphertext = []byte{}
+	}
+	deviceIDs := make([]string, len(payloads))
+	deviceCiphertexts := make([][]byte, len(payloads))
+	for i, p := range payloads {
+		deviceIDs[i] = p.DeviceID.String()
+		deviceCiphertexts[i] = p.Ciphertext
+	}
+	return ciphertext, deviceIDs, deviceCiphertexts
+}
+
+func optionalTime(t *time.Time) pgtype.Timestamptz {
+	if t == nil {
+		return pgtype.Timestamptz{}
+	}
+	return pgtype.Timestamptz{Time: *t, Valid: true}
+}
@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// PostgresThreadRepository is a PostgreSQL implementation of the ThreadRepository.
+type PostgresThreadRepository struct {
+	q *db.Queries
+}
+
+// NewPostgresThreadRepository creates a new instance of PostgresThreadRepository.
+func NewPostgresThreadRepository(d *db.Queries) *PostgresThreadRepository {
+	return &PostgresThreadRepository{q: d}
+}
+
+// Statically check that PostgresThreadRepository implements ThreadRepository.
+var _ repos.ThreadRepository = (*PostgresThreadRepository)(nil)
+
+// SetChatForum returns repos.ErrNotFound unless the chat is a group.
+func (r *PostgresThreadRepository) SetChatForum(ctx context.Context, chatID ulid.ULID, enabled bool) error {
+	n, err := r.q.SetChatForum(ctx, db.SetChatForumParams{
+		ID:      chatID.String(),
+		IsForum: enabled,
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresThreadRepository) CreateTopic(ctx context.Context, chatID, createdBy ulid.ULID, title string, iconColor sql.NullInt32) (*db.ChatThread, error) {
+	thread, err := r.q.CreateTopic(ctx, db.CreateTopicParams{
+		ID:        ulid.Make().String(),
+		ChatID:    chatID.String(),
+		Title:     pgtype.Text{String: title, Valid: true},
+		IconColor: pgtype.Int4{Int32: iconColor.Int32, Valid: iconColor.Valid},
+		CreatedBy: pgtype.Text{String: createdBy.String(), Valid: true},
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &thread, nil
+}
+
+// CreateReplyThread returns repos.ErrAlreadyExists when the message already
+// has a thread.
+func (r *PostgresThreadRepository) CreateReplyThread(ctx context.Context, chatID, rootMessageID, createdBy ulid.ULID) (*db.ChatThread, error) {
+	thread, err := r.q.CreateReplyThread(ctx, db.CreateReplyThreadParams{
+		ID:            ulid.Make().String(),
+		ChatID:        chatID.String(),
+		RootMessageID: pgtype.Text{String: rootMessageID.String(), Valid: true},
+		CreatedBy:     pgtype.Text{String: createdBy.String(), Valid: true},
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &thread, nil
+}
+
+func (r *PostgresThreadRepository) GetThread(ctx context.Context, chatID, threadID ulid.ULID) (*db.ChatThread, error) {
+	thread, err := r.q.GetThread(ctx, db.GetThreadParams{
+		ID:     threadID.String(),
+		ChatID: chatID.String(),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &thread, nil
+}
+
+func (r *PostgresThreadRepository) GetReplyThread(ctx context.Context, chatID, rootMessageID ulid.ULID) (*db.ChatThread, error) {
+	thread, err := r.q.GetReplyThread(ctx, db.GetReplyThreadParams{
+		ChatID:        chatID.String(),
+		RootMessageID: pgtype.Text{String: rootMessageID.String(), Valid: true},
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &thread, nil
+}
+
+func (r *PostgresThreadRepository) UpdateTopic(ctx context.Context, chatID, threadID ulid.ULID, update repos.TopicUpdate) (*db.ChatThread, error) {
+	thread, err := r.q.UpdateTopic(ctx, db.UpdateTopicParams{
+		ID:        threadID.String(),
+		ChatID:    chatID.String(),
+		Title:     pgtype.Text{String: update.Title.String, Valid: update.Title.Valid},
+		IconColor: pgtype.Int4{Int32: update.IconColor.Int32, Valid: update.IconColor.Valid},
+		IsClosed:  pgtype.Bool{Bool: update.Closed.Bool, Valid: update.Closed.Valid},
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &thread, nil
+}
+
+func (r *PostgresThreadRepository) DeleteTopic(ctx context.Context, chatID, threadID ulid.ULID) error {
+	n, err := r.q.DeleteTopic(ctx, db.DeleteTopicParams{
+		ID:     threadID.String(),
+		ChatID: chatID.String(),
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repos.ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresThreadRepository) GetThreadWithState(ctx context.Context, chatID, threadID, userID ulid.ULID) (*db.ListThreadsWithStateRow, error) {
+	row, err := r.q.GetThreadWithState(ctx, db.GetThreadWithStateParams{
+		ID:     threadID.String(),
+		ChatID: chatID.String(),
+		UserID: userID.String(),
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	thread := db.ListThreadsWithStateRow(row)
+	return &thread, nil
+}
+
+func (r *PostgresThreadRepository) ListThreadsWithState(ctx context.Context, chatID, userID ulid.ULID, kind db.ThreadKind, cursor *repos.ThreadCursor, limit int32) ([]db.ListThreadsWithStateRow, error) {
+	params := db.ListThreadsWithStateParams{
+		ChatID:   chatID.String(),
+		UserID:   userID.String(),
+		Kind:     kind,
+		PageSize: limit,
+	}
+	if cursor != nil {
+		params.CursorActivity = pgtype.Timestamptz{Time: cursor.LastActivityAt, Valid: true}
+		params.CursorID = pgtype.Text{String: cursor.ThreadID, Valid: true}
+	}
+	return r.q.ListThreadsWithState(ctx, params)
+}
+
+func (r *PostgresThreadRepository) MarkThreadRead(ctx context.Context, threadID, userID ulid.ULID, seq int64) (int64, error) {
+	readSeq, err := r.q.MarkThreadRead(ctx, db.MarkThreadReadParams{
+		ThreadID: threadID.String(),
+		UserID:   userID.String(),
+		Seq:      seq,
+	})
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return readSeq, nil
+}
+
+func (r *PostgresThreadRepository) UpdateThreadNotifications(ctx context.Context, threadID, userID ulid.ULID, level db.ThreadNotifyLevel, mutedUntil sql.NullTime) error {
+	return r.q.UpdateThreadNotifications(ctx, db.UpdateThreadNotificationsParams{
+		ThreadID:    threadID.String(),
+		UserID:      userID.String(),
+		NotifyLevel: level,
+		MutedUntil:  pgtype.Timestamptz{Time: mutedUntil.Time, Valid: mutedUntil.Valid},
+	})
+}
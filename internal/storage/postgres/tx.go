@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+)
+
+// maxTxAttempts is how often InTx runs a transaction that lost a deadlock
+// or serialization conflict before giving up.
+const maxTxAttempts = 3
+
+type txKey struct{}
+
+// DB runs queries in the transaction that InTx bound to their context and
+// on the pool otherwise. Queries built on it let repositories take part in
+// transactions without knowing about them.
+type DB struct {
+	pool *pgxpool.Pool
+}
+
+// NewDB creates a new DB on top of pool.
+func NewDB(pool *pgxpool.Pool) *DB {
+	return &DB{pool: pool}
+}
+
+// Statically check that DB can back db.Queries and implements Transactor.
+var (
+	_ db.DBTX          = (*DB)(nil)
+	_ repos.Transactor = (*DB)(nil)
+)
+
+func (d *DB) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return d.runNested(ctx, tx, fn)
+	}
+	var err error
+	for range maxTxAttempts {
+		if err = d.runTx(ctx, fn); !retryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (d *DB) runTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := d.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rolling back a committed transaction is a no-op.
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	txCtx, runHooks := repos.WithCommitHooks(context.WithValue(ctx, txKey{}, tx))
+	if err := fn(txCtx); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return mapError(err)
+	}
+	runHooks()
+	return nil
+}
+
+// runNested runs fn in a savepoint of tx. The commit hooks of fn run with
+// those of tx, and are dropped when the savepoint is rolled back.
+func (d *DB) runNested(ctx context.Context, tx pgx.Tx, fn func(ctx context.Context) error) error {
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return mapError(err)
+	}
+	// Rolling back a released savepoint is a no-op.
+	defer savepoint.Rollback(context.WithoutCancel(ctx))
+
+	spCtx, runHooks := repos.WithCommitHooks(context.WithValue(ctx, txKey{}, savepoint))
+	if err := fn(spCtx); err != nil {
+		return err
+	}
+	if err := savepoint.Commit(ctx); err != nil {
+		return mapError(err)
+	}
+	repos.AfterCommit(ctx, runHooks)
+	return nil
+}
+
+func (d *DB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return d.conn(ctx).Exec(ctx, sql, args...)
+}
+
+func (d *DB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return d.conn(ctx).Query(ctx, sql, args...)
+}
+
+func (d *DB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return d.conn(ctx).QueryRow(ctx, sql, args...)
+}
+
+func (d *DB) conn(ctx context.Context) db.DBTX {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return d.pool
+}
+
+// retryable reports whether a transaction failed only because it conflicted
+// with a concurrent one, so that running it again can succeed.
+func retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == deadlockDetected || pgErr.Code == serializationFailure)
+}
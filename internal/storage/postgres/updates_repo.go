@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/messenger/backend/internal/db"
+	"github.com/messenger/backend/internal/repos"
+	"github.com/oklog/ulid/v2"
+)
+
+// PostgresUpdateRepository is a PostgreSQL implementation of the UpdateRepository.
+type PostgresUpdateRepository struct {
+	q *db.Queries
+}
+
+// NewPostgresUpdateRepository creates a new instance of PostgresUpdateRepository.
+func NewPostgresUpdateRepository(d *db.Queries) *PostgresUpdateRepository {
+	return &PostgresUpdateRepository{q: d}
+}
+
+// Statically check that PostgresUpdateRepository implements UpdateRepository.
+var _ repos.UpdateRepository = (*PostgresUpdateRepository)(nil)
+
+func (r *PostgresUpdateRepository) AppendUserUpdate(ctx context.Context, userID ulid.ULID, updateType string, payload []byte) (*db.UserUpdate, error) {
+	update, err := r.q.AppendUserUpdate(ctx, db.AppendUserUpdateParams{
+		UserID:  userID.String(),
+		Type:    updateType,
+		Payload: payload,
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &update, nil
+}
+
+// AppendChatUpdate appends an update to the log of every member of a chat.
+func (r *PostgresUpdateRepository) AppendChatUpdate(ctx context.Context, chatID ulid.ULID, updateType string, payload []byte) ([]db.UserUpdate, error) {
+	return r.q.AppendChatUpdate(ctx, db.AppendChatUpdateParams{
+		ChatID:  chatID.String(),
+		Type:    updateType,
+		Payload: payload,
+	})
+}
+
+func (r *PostgresUpdateRepository) GetUserUpdateSeq(ctx context.Context, userID ulid.ULID) (int64, error) {
+	return r.q.GetUserUpdateSeq(ctx, userID.String())
+}
+
+func (r *PostgresUpdateRepository) ListUserUpdates(ctx context.Context, userID ulid.ULID, afterSeq int64, limit int) ([]db.UserUpdate, error) {
+	return r.q.ListUserUpdates(ctx, db.ListUserUpdatesParams{
+		UserID:   userID.String(),
+		AfterSeq: afterSeq,
+		Lim:      int32(limit),
+	})
+}
+
+func (r *PostgresUpdateRepository) AppendChannelUpdate(ctx context.Context, chatID ulid.ULID, updateType string, payload []byte) (*db.ChatUpdate, error) {
+	update, err := r.q.AppendChannelUpdate(ctx, db.AppendChannelUpdateParams{
+		ChatID:  chatID.String(),
+		Type:    updateType,
+		Payload: payload,
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &update, nil
+}
+
+func (r *PostgresUpdateRepository) ListUserChannelSeqs(ctx context.Context, userID ulid.ULID) ([]db.ListUserChannelSeqsRow, error) {
+	return r.q.ListUserChannelSeqs(ctx, userID.String())
+}
+
+func (r *PostgresUpdateRepository) ListChannelUpdates(ctx context.Context, chatID ulid.ULID, afterSeq int64, limit int) ([]db.ChatUpdate, error) {
+	return r.q.ListChannelUpdates(ctx, db.ListChannelUpdatesParams{
+		ChatID:   chatID.String(),
+		AfterSeq: afterSeq,
+		Lim:      int32(limit),
+	})
+}
+
+func (r *PostgresUpdateRepository) ListChatMemberIDs(ctx context.Context, chatID ulid.ULID) ([]string, error) {
+	return r.q.ListChatMemberIDs(ctx, chatID.String())
+}
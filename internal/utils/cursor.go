@@ -0,0 +1,29 @@
+// Package utils internal/utils/cursor.go
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor packs pagination state into an opaque, URL-safe token.
+func EncodeCursor(parts ...string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(parts, "|")))
+}
+
+// DecodeCursor unpacks a token produced by EncodeCursor and checks that it
+// carries exactly n parts.
+func DecodeCursor(cursor string, n int) ([]string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != n {
+		return nil, ErrInvalidCursor
+	}
+	return parts, nil
+}
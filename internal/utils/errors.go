@@ -31,14 +31,28 @@ const (
 	ErrChatAlreadyExists ErrorCode = "CHAT_ALREADY_EXISTS"
 	ErrSelfChatExists    ErrorCode = "SELF_CHAT_EXISTS"
 	ErrForbiddenRole     ErrorCode = "FORBIDDEN_ROLE"
+	ErrUsernameTaken     ErrorCode = "USERNAME_TAKEN"
+	ErrThreadNotFound    ErrorCode = "THREAD_NOT_FOUND"
 
 	// ErrMessageNotFound Messages
-	ErrMessageNotFound   ErrorCode = "MESSAGE_NOT_FOUND"
-	ErrInvalidCiphertext ErrorCode = "INVALID_CIPHERTEXT"
+	ErrMessageNotFound     ErrorCode = "MESSAGE_NOT_FOUND"
+	ErrInvalidCiphertext   ErrorCode = "INVALID_CIPHERTEXT"
+	ErrMessageNotEditable  ErrorCode = "MESSAGE_NOT_EDITABLE"
+	ErrMessageNotDeletable ErrorCode = "MESSAGE_NOT_DELETABLE"
+	ErrReactionNotAllowed  ErrorCode = "REACTION_NOT_ALLOWED"
+	ErrReactionLimit       ErrorCode = "REACTION_LIMIT_REACHED"
+	ErrPollClosed          ErrorCode = "POLL_CLOSED"
+	ErrPollAnswered        ErrorCode = "POLL_ALREADY_ANSWERED"
 
 	// ErrMemberExists Members
-	ErrMemberExists   ErrorCode = "MEMBER_EXISTS"
-	ErrMemberNotFound ErrorCode = "MEMBER_NOT_FOUND"
+	ErrMemberExists       ErrorCode = "MEMBER_EXISTS"
+	ErrMemberNotFound     ErrorCode = "MEMBER_NOT_FOUND"
+	ErrMemberLimitReached ErrorCode = "MEMBER_LIMIT_REACHED"
+	ErrUserBanned         ErrorCode = "USER_BANNED"
+
+	// ErrInviteInvalid Invites
+	ErrInviteInvalid ErrorCode = "INVITE_INVALID"
+	ErrInviteExpired ErrorCode = "INVITE_EXPIRED"
 
 	// ErrMLSStateMismatch Keys / MLS
 	ErrMLSStateMismatch ErrorCode = "MLS_STATE_MISMATCH"
@@ -0,0 +1,47 @@
+// Package utils internal/utils/normalize.go
+package utils
+
+import (
+	"strings"
+)
+
+// NormalizePhone converts a user-entered phone number into E.164 form.
+// Spaces, dashes, dots and parentheses are dropped and a leading "00"
+// international prefix is rewritten to "+". Numbers without a country code
+// cannot be resolved reliably and are reported as invalid.
+func NormalizePhone(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimPrefix(raw, "tel:")
+
+	var b strings.Builder
+	for i, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+			// formatting characters
+		default:
+			return "", false
+		}
+	}
+
+	phone := b.String()
+	if strings.HasPrefix(phone, "00") {
+		phone = "+" + phone[2:]
+	}
+	if !phoneRegex.MatchString(phone) {
+		return "", false
+	}
+	return phone, true
+}
+
+// NormalizeEmail trims and lower-cases an email address and checks its syntax.
+func NormalizeEmail(raw string) (string, bool) {
+	email := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(raw, "mailto:")))
+	if email == "" || validate.Var(email, "email") != nil {
+		return "", false
+	}
+	return email, true
+}
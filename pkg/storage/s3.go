@@ -1 +1,8 @@
+// Package storage will hold the object store for message attachments.
+//
+// TODO: Attachments are not stored yet: there is no upload API, attachment
+// table or S3 client. When they are added, every path that tombstones or
+// purges messages must also delete the attachment objects no message
+// references any more. Until then the server only ever holds message
+// ciphertext, which those paths already remove.
 package storage
@@ -0,0 +1,241 @@
+// Package vcard implements the subset of vCard 3.0 and 4.0 (RFC 2426, RFC 6350)
+// needed to import and export address books.
+package vcard
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxLineLength is the folding limit in octets recommended by both RFCs.
+const maxLineLength = 75
+
+var (
+	ErrUnsupportedVersion = errors.New("vcard: unsupported version")
+	ErrMalformed          = errors.New("vcard: malformed input")
+)
+
+// Card is a single contact entry.
+type Card struct {
+	FormattedName string
+	Nickname      string
+	Phones        []string
+	Emails        []string
+}
+
+// Decode reads every card from r. Only VERSION 3.0 and 4.0 are accepted.
+func Decode(r io.Reader) ([]Card, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		cards   []Card
+		current *Card
+		version string
+		names   string
+	)
+	for n, line := range lines {
+		if line == "" {
+			continue
+		}
+		name, value, ok := splitLine(line)
+		if !ok {
+			return nil, fmt.Errorf("%w: line %d", ErrMalformed, n+1)
+		}
+
+		switch name {
+		case "BEGIN":
+			if !strings.EqualFold(value, "VCARD") || current != nil {
+				return nil, fmt.Errorf("%w: unexpected BEGIN on line %d", ErrMalformed, n+1)
+			}
+			current, version, names = &Card{}, "", ""
+			continue
+		case "END":
+			if !strings.EqualFold(value, "VCARD") || current == nil {
+				return nil, fmt.Errorf("%w: unexpected END on line %d", ErrMalformed, n+1)
+			}
+			if version != "3.0" && version != "4.0" {
+				return nil, fmt.Errorf("%w %q", ErrUnsupportedVersion, version)
+			}
+			if current.FormattedName == "" {
+				current.FormattedName = names
+			}
+			cards = append(cards, *current)
+			current = nil
+			continue
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("%w: property outside of a card on line %d", ErrMalformed, n+1)
+		}
+
+		switch name {
+		case "VERSION":
+			version = value
+		case "FN":
+			current.FormattedName = unescape(value)
+		case "N":
+			names = structuredName(value)
+		case "NICKNAME":
+			current.Nickname = unescape(value)
+		case "TEL":
+			if v := strings.TrimSpace(unescape(value)); v != "" {
+				current.Phones = append(current.Phones, v)
+			}
+		case "EMAIL":
+			if v := strings.TrimSpace(unescape(value)); v != "" {
+				current.Emails = append(current.Emails, v)
+			}
+		}
+	}
+
+	if current != nil {
+		return nil, fmt.Errorf("%w: missing END:VCARD", ErrMalformed)
+	}
+	return cards, nil
+}
+
+// Encode writes cards to w as vCard 3.0, which has the widest client support.
+func Encode(w io.Writer, cards []Card) error {
+	bw := bufio.NewWriter(w)
+	for _, card := range cards {
+		writeLine(bw, "BEGIN:VCARD")
+		writeLine(bw, "VERSION:3.0")
+		writeLine(bw, "FN:"+escape(card.FormattedName))
+		writeLine(bw, "N:"+escape(card.FormattedName)+";;;;")
+		if card.Nickname != "" {
+			writeLine(bw, "NICKNAME:"+escape(card.Nickname))
+		}
+		for _, phone := range card.Phones {
+			writeLine(bw, "TEL;TYPE=CELL:"+escape(phone))
+		}
+		for _, email := range card.Emails {
+			writeLine(bw, "EMAIL;TYPE=INTERNET:"+escape(email))
+		}
+		writeLine(bw, "END:VCARD")
+	}
+	return bw.Flush()
+}
+
+// unfold joins continuation lines (those starting with a space or tab) onto
+// the preceding line.
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// splitLine breaks a content line into its upper-cased property name and
+// value. Group prefixes ("item1.TEL") and parameters ("TEL;TYPE=cell") are
+// dropped because import only needs the values.
+func splitLine(line string) (name, value string, ok bool) {
+	colon := indexUnquoted(line, ':')
+	if colon < 0 {
+		return "", "", false
+	}
+	name, value = line[:colon], line[colon+1:]
+
+	if semi := strings.IndexByte(name, ';'); semi >= 0 {
+		name = name[:semi]
+	}
+	if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+		name = name[dot+1:]
+	}
+	name = strings.ToUpper(strings.TrimSpace(name))
+	return name, value, name != ""
+}
+
+// indexUnquoted finds sep outside of double-quoted parameter values.
+func indexUnquoted(s string, sep byte) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// structuredName renders "Family;Given;Additional;Prefix;Suffix" as a
+// display name.
+func structuredName(value string) string {
+	parts := strings.Split(value, ";")
+	order := []int{3, 1, 2, 0, 4}
+	var out []string
+	for _, i := range order {
+		if i < len(parts) {
+			if p := strings.TrimSpace(unescape(parts[i])); p != "" {
+				out = append(out, p)
+			}
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// writeLine folds a content line at maxLineLength octets without splitting
+// multi-byte characters.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines lose one octet to the leading space.
+		limit = maxLineLength - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
@@ -0,0 +1,73 @@
+package vcard
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode_V3AndV4(t *testing.T) {
+	input := strings.Join([]string{
+		"BEGIN:VCARD",
+		"VERSION:3.0",
+		"N:Doe;John;;;",
+		"TEL;TYPE=CELL:+1 (212) 555-2368",
+		"item1.EMAIL;TYPE=INTERNET:John.Doe@Example.com",
+		"END:VCARD",
+		"BEGIN:VCARD",
+		"VERSION:4.0",
+		"FN:Jane\\, the",
+		"  Second",
+		"TEL;VALUE=uri;TYPE=\"voice,cell\":tel:+44-20-7946-0958",
+		"END:VCARD",
+		"",
+	}, "\r\n")
+
+	cards, err := Decode(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, cards, 2)
+
+	assert.Equal(t, "John Doe", cards[0].FormattedName)
+	assert.Equal(t, []string{"+1 (212) 555-2368"}, cards[0].Phones)
+	assert.Equal(t, []string{"John.Doe@Example.com"}, cards[0].Emails)
+
+	assert.Equal(t, "Jane, the Second", cards[1].FormattedName)
+	assert.Equal(t, []string{"tel:+44-20-7946-0958"}, cards[1].Phones)
+}
+
+func TestDecode_RejectsUnsupportedVersion(t *testing.T) {
+	input := "BEGIN:VCARD\nVERSION:2.1\nFN:Old\nEND:VCARD\n"
+
+	_, err := Decode(strings.NewReader(input))
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+}
+
+func TestDecode_RejectsUnterminatedCard(t *testing.T) {
+	input := "BEGIN:VCARD\nVERSION:3.0\nFN:Half\n"
+
+	_, err := Decode(strings.NewReader(input))
+	assert.ErrorIs(t, err, ErrMalformed)
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	cards := []Card{{
+		FormattedName: "Алиса; " + strings.Repeat("очень длинное имя ", 5),
+		Nickname:      "alice",
+		Phones:        []string{"+12125552368"},
+		Emails:        []string{"alice@example.com"},
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, cards))
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), maxLineLength)
+	}
+
+	decoded, err := Decode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, cards, decoded)
+}